@@ -2,6 +2,7 @@ package benchmark
 
 import (
 	"flag"
+	"time"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -15,12 +16,16 @@ func TestBenchmark(t *testing.T) {
 }
 
 var (
-	size    int // file size in MB, will be read from flags
-	samples int // number of samples for Measure, will be read from flags
+	size    int           // file size in MB, will be read from flags
+	samples int           // number of samples for Measure, will be read from flags
+	rtt     time.Duration // RTT of the proxy used for the congestion control benchmarks, will be read from flags
+	loss    float64       // packet loss rate of the proxy used for the congestion control benchmarks, will be read from flags
 )
 
 func init() {
 	flag.IntVar(&size, "size", 50, "data length (in MB)")
 	flag.IntVar(&samples, "samples", 6, "number of samples")
+	flag.DurationVar(&rtt, "rtt", 50*time.Millisecond, "RTT of the proxy used for the congestion control benchmarks")
+	flag.Float64Var(&loss, "loss", 0.01, "packet loss rate (between 0 and 1) of the proxy used for the congestion control benchmarks")
 	flag.Parse()
 }
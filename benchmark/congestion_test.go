@@ -0,0 +1,91 @@
+package benchmark
+
+import (
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"time"
+
+	quic "github.com/lucas-clemente/quic-go"
+	"github.com/lucas-clemente/quic-go/congestion"
+	quicproxy "github.com/lucas-clemente/quic-go/integrationtests/tools/proxy"
+	"github.com/lucas-clemente/quic-go/internal/testdata"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func init() {
+	var _ = Describe("Congestion Control Benchmarks", func() {
+		dataLen := size * /* MB */ 1e6
+		data := make([]byte, dataLen)
+		rand.Seed(GinkgoRandomSeed())
+		rand.Read(data) // no need to check for an error. math.Rand.Read never errors
+
+		for _, a := range []struct {
+			name              string
+			congestionControl func(congestion.RTTStats) congestion.SendAlgorithm
+		}{
+			{name: "Cubic", congestionControl: congestion.NewCubic},
+			{name: "BBR", congestionControl: congestion.NewBBR},
+		} {
+			congestionControl := a.congestionControl
+
+			Context(fmt.Sprintf("using %s", a.name), func() {
+				Measure(fmt.Sprintf("transferring a %d MB file over a path with %s RTT and %.1f%% packet loss", size, rtt, loss*100), func(b Benchmarker) {
+					ln, err := quic.ListenAddr(
+						"localhost:0",
+						testdata.GetTLSConfig(),
+						&quic.Config{CongestionControl: congestionControl},
+					)
+					Expect(err).ToNot(HaveOccurred())
+					defer ln.Close()
+					go func() {
+						defer GinkgoRecover()
+						sess, err := ln.Accept()
+						Expect(err).ToNot(HaveOccurred())
+						str, err := sess.OpenStream()
+						Expect(err).ToNot(HaveOccurred())
+						_, err = str.Write(data)
+						Expect(err).ToNot(HaveOccurred())
+						Expect(str.Close()).To(Succeed())
+					}()
+
+					proxy, err := quicproxy.NewQuicProxy("localhost:0", &quicproxy.Opts{
+						RemoteAddr: fmt.Sprintf("localhost:%d", ln.Addr().(*net.UDPAddr).Port),
+						DelayPacket: func(quicproxy.Direction, uint64) time.Duration {
+							return rtt / 2
+						},
+						DropPacket: func(_ quicproxy.Direction, packetCount uint64) bool {
+							// don't drop packets during the handshake
+							return packetCount > 10 && rand.Float64() < loss
+						},
+					})
+					Expect(err).ToNot(HaveOccurred())
+					defer proxy.Close()
+
+					sess, err := quic.DialAddr(
+						fmt.Sprintf("localhost:%d", proxy.LocalPort()),
+						&tls.Config{InsecureSkipVerify: true},
+						nil,
+					)
+					Expect(err).ToNot(HaveOccurred())
+					defer sess.Close()
+					str, err := sess.AcceptStream()
+					Expect(err).ToNot(HaveOccurred())
+
+					buf := &bytes.Buffer{}
+					runtime := b.Time("transfer time", func() {
+						_, err := io.Copy(buf, str)
+						Expect(err).NotTo(HaveOccurred())
+					})
+					Expect(buf.Bytes()).To(Equal(data))
+
+					b.RecordValue("transfer rate [MB/s]", float64(dataLen)/1e6/runtime.Seconds())
+				}, samples)
+			})
+		}
+	})
+}
@@ -0,0 +1,74 @@
+package benchmark
+
+import (
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"math/rand"
+
+	quic "github.com/lucas-clemente/quic-go"
+	"github.com/lucas-clemente/quic-go/internal/testdata"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func init() {
+	var _ = Describe("Batched I/O Benchmarks", func() {
+		dataLen := size * /* MB */ 1e6
+		data := make([]byte, dataLen)
+		rand.Seed(GinkgoRandomSeed())
+		rand.Read(data) // no need to check for an error. math.Rand.Read never errors
+
+		for _, a := range []struct {
+			name      string
+			batchedIO bool
+		}{
+			{name: "without batched I/O", batchedIO: false},
+			{name: "with batched I/O", batchedIO: true},
+		} {
+			batchedIO := a.batchedIO
+
+			Context(a.name, func() {
+				Measure(fmt.Sprintf("transferring a %d MB file", size), func(b Benchmarker) {
+					ln, err := quic.ListenAddr(
+						"localhost:0",
+						testdata.GetTLSConfig(),
+						&quic.Config{EnableBatchedIO: batchedIO},
+					)
+					Expect(err).ToNot(HaveOccurred())
+					defer ln.Close()
+					go func() {
+						defer GinkgoRecover()
+						sess, err := ln.Accept()
+						Expect(err).ToNot(HaveOccurred())
+						str, err := sess.OpenStream()
+						Expect(err).ToNot(HaveOccurred())
+						_, err = str.Write(data)
+						Expect(err).ToNot(HaveOccurred())
+						Expect(str.Close()).To(Succeed())
+					}()
+
+					sess, err := quic.DialAddr(
+						ln.Addr().String(),
+						&tls.Config{InsecureSkipVerify: true},
+						&quic.Config{EnableBatchedIO: batchedIO},
+					)
+					Expect(err).ToNot(HaveOccurred())
+					defer sess.Close()
+					str, err := sess.AcceptStream()
+					Expect(err).ToNot(HaveOccurred())
+
+					buf := &bytes.Buffer{}
+					runtime := b.Time("transfer time", func() {
+						_, err := io.Copy(buf, str)
+						Expect(err).NotTo(HaveOccurred())
+					})
+					Expect(buf.Bytes()).To(Equal(data))
+
+					b.RecordValue("transfer rate [MB/s]", float64(dataLen)/1e6/runtime.Seconds())
+				}, samples)
+			})
+		}
+	})
+}
@@ -51,13 +51,17 @@ func (b *packetBuffer) Release() {
 }
 
 func (b *packetBuffer) putBack() {
-	if cap(b.Slice) != int(protocol.MaxReceivePacketSize) {
+	switch cap(b.Slice) {
+	case int(protocol.MaxReceivePacketSize):
+		bufferPool.Put(b)
+	case int(protocol.MaxPacketSizePMTUD):
+		largeBufferPool.Put(b)
+	default:
 		panic("putPacketBuffer called with packet of wrong size!")
 	}
-	bufferPool.Put(b)
 }
 
-var bufferPool sync.Pool
+var bufferPool, largeBufferPool sync.Pool
 
 func getPacketBuffer() *packetBuffer {
 	buf := bufferPool.Get().(*packetBuffer)
@@ -66,10 +70,24 @@ func getPacketBuffer() *packetBuffer {
 	return buf
 }
 
+// getLargePacketBuffer gets a buffer that is large enough to hold packets of MaxPacketSizePMTUD bytes.
+// It is only needed when Path MTU Discovery is enabled.
+func getLargePacketBuffer() *packetBuffer {
+	buf := largeBufferPool.Get().(*packetBuffer)
+	buf.refCount = 1
+	buf.Slice = buf.Slice[:protocol.MaxPacketSizePMTUD]
+	return buf
+}
+
 func init() {
 	bufferPool.New = func() interface{} {
 		return &packetBuffer{
 			Slice: make([]byte, 0, protocol.MaxReceivePacketSize),
 		}
 	}
+	largeBufferPool.New = func() interface{} {
+		return &packetBuffer{
+			Slice: make([]byte, 0, protocol.MaxPacketSizePMTUD),
+		}
+	}
 }
@@ -13,11 +13,22 @@ var _ = Describe("Buffer Pool", func() {
 		Expect(buf.Slice).To(HaveCap(int(protocol.MaxReceivePacketSize)))
 	})
 
+	It("returns large buffers", func() {
+		buf := getLargePacketBuffer()
+		Expect(buf.Slice).To(HaveCap(int(protocol.MaxPacketSizePMTUD)))
+	})
+
 	It("releases buffers", func() {
 		buf := getPacketBuffer()
 		buf.Release()
 	})
 
+	It("releases large buffers", func() {
+		buf := getLargePacketBuffer()
+		buf.Release()
+		Expect(getLargePacketBuffer().Slice).To(HaveCap(int(protocol.MaxPacketSizePMTUD)))
+	})
+
 	It("panics if wrong-sized buffers are passed", func() {
 		buf := getPacketBuffer()
 		buf.Slice = make([]byte, 10)
@@ -7,6 +7,7 @@ import (
 	"net"
 	"sync"
 
+	"github.com/lucas-clemente/quic-go/congestion"
 	"github.com/lucas-clemente/quic-go/internal/handshake"
 	"github.com/lucas-clemente/quic-go/internal/protocol"
 	"github.com/lucas-clemente/quic-go/internal/utils"
@@ -86,6 +87,7 @@ func DialAddrContext(
 // Dial establishes a new QUIC connection to a server using a net.PacketConn.
 // The same PacketConn can be used for multiple calls to Dial and Listen,
 // QUIC connection IDs are used for demultiplexing the different connections.
+// To make sure that Dial and Listen use consistent settings on the PacketConn, use a Transport.
 // The host parameter is used for SNI.
 func Dial(
 	pconn net.PacketConn,
@@ -120,7 +122,7 @@ func dialContext(
 	createdPacketConn bool,
 ) (Session, error) {
 	config = populateClientConfig(config, createdPacketConn)
-	packetHandlers, err := getMultiplexer().AddConn(pconn, config.ConnectionIDLength, config.StatelessResetKey)
+	packetHandlers, err := getMultiplexer().AddConn(pconn, config.ConnectionIDLength, config.StatelessResetKey, config.Metrics, config.EnableBatchedIO, !config.DisablePathMTUDiscovery, config.EnableECN)
 	if err != nil {
 		return nil, err
 	}
@@ -174,7 +176,7 @@ func newClient(
 	c := &client{
 		srcConnID:         srcConnID,
 		destConnID:        destConnID,
-		conn:              &conn{pconn: pconn, currentAddr: remoteAddr},
+		conn:              &conn{pconn: pconn, currentAddr: remoteAddr, batchWriter: getBatchWriter(pconn, config.EnableBatchedIO)},
 		createdPacketConn: createdPacketConn,
 		tlsConf:           tlsConf,
 		config:            config,
@@ -225,6 +227,14 @@ func populateClientConfig(config *Config, createdPacketConn bool) *Config {
 	} else if maxIncomingUniStreams < 0 {
 		maxIncomingUniStreams = 0
 	}
+	keyUpdateInterval := config.KeyUpdateInterval
+	if keyUpdateInterval == 0 {
+		keyUpdateInterval = protocol.DefaultKeyUpdateInterval
+	}
+	congestionControl := config.CongestionControl
+	if congestionControl == nil {
+		congestionControl = congestion.NewCubic
+	}
 	connIDLen := config.ConnectionIDLength
 	if connIDLen == 0 && !createdPacketConn {
 		connIDLen = protocol.DefaultConnectionIDLength
@@ -241,6 +251,14 @@ func populateClientConfig(config *Config, createdPacketConn bool) *Config {
 		MaxIncomingUniStreams:                 maxIncomingUniStreams,
 		KeepAlive:                             config.KeepAlive,
 		StatelessResetKey:                     config.StatelessResetKey,
+		KeyUpdateInterval:                     keyUpdateInterval,
+		CongestionControl:                     congestionControl,
+		EnableDatagrams:                       config.EnableDatagrams,
+		DisablePathMTUDiscovery:               config.DisablePathMTUDiscovery,
+		EnableBatchedIO:                       config.EnableBatchedIO,
+		EnableECN:                             config.EnableECN,
+		Tracer:                                config.Tracer,
+		Metrics:                               config.Metrics,
 	}
 }
 
@@ -355,6 +373,12 @@ func (c *client) createNewTLSSession(version protocol.VersionNumber) error {
 		AckDelayExponent:               protocol.AckDelayExponent,
 		DisableMigration:               true,
 	}
+	if !c.config.DisablePathMTUDiscovery {
+		params.MaxPacketSize = protocol.MaxPacketSizePMTUD
+	}
+	if c.config.EnableDatagrams {
+		params.MaxDatagramFrameSize = protocol.MaxDatagramFrameSize
+	}
 
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
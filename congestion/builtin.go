@@ -0,0 +1,38 @@
+package congestion
+
+import (
+	"github.com/lucas-clemente/quic-go/internal/congestion"
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+)
+
+// NewCubic creates a SendAlgorithm that uses CUBIC.
+// This is the default congestion control algorithm.
+func NewCubic(rttStats RTTStats) SendAlgorithm {
+	return newCubicSender(rttStats, false)
+}
+
+// NewReno creates a SendAlgorithm that uses NewReno.
+func NewReno(rttStats RTTStats) SendAlgorithm {
+	return newCubicSender(rttStats, true)
+}
+
+// NewBBR creates a SendAlgorithm that uses BBR.
+// BBR paces packets at the estimated bottleneck bandwidth, and doesn't reduce
+// the congestion window when packets are lost.
+func NewBBR(rttStats RTTStats) SendAlgorithm {
+	return congestion.NewBBRSender(
+		rttStats,
+		protocol.InitialCongestionWindow,
+		protocol.DefaultMaxCongestionWindow,
+	)
+}
+
+func newCubicSender(rttStats RTTStats, reno bool) SendAlgorithm {
+	return congestion.NewCubicSender(
+		congestion.DefaultClock{},
+		rttStats,
+		reno,
+		protocol.InitialCongestionWindow,
+		protocol.DefaultMaxCongestionWindow,
+	)
+}
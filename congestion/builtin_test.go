@@ -0,0 +1,124 @@
+package congestion_test
+
+import (
+	"time"
+
+	"github.com/lucas-clemente/quic-go/congestion"
+	"github.com/lucas-clemente/quic-go/congestion/congestiontest"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+const packetSize congestion.ByteCount = 1460
+
+var _ = Describe("Built-in congestion control algorithms", func() {
+	for _, a := range []struct {
+		name         string
+		newAlgorithm func(congestion.RTTStats) congestion.SendAlgorithm
+	}{
+		{name: "Cubic", newAlgorithm: congestion.NewCubic},
+		{name: "Reno", newAlgorithm: congestion.NewReno},
+	} {
+		newAlgorithm := a.newAlgorithm
+
+		Context(a.name, func() {
+			var harness *congestiontest.Harness
+
+			// sendRound sends a full congestion window and acknowledges all packets one RTT later.
+			sendRound := func() {
+				pns := harness.FillCongestionWindow(packetSize)
+				Expect(pns).ToNot(BeEmpty())
+				harness.AdvanceTime(50 * time.Millisecond)
+				harness.Ack(pns...)
+			}
+
+			BeforeEach(func() {
+				harness = congestiontest.NewHarness(newAlgorithm)
+			})
+
+			It("doubles the congestion window every RTT in slow start", func() {
+				initialWindow := harness.CongestionWindow()
+				sendRound()
+				Expect(harness.CongestionWindow()).To(Equal(2 * initialWindow))
+				sendRound()
+				Expect(harness.CongestionWindow()).To(Equal(4 * initialWindow))
+				Expect(harness.BytesInFlight()).To(BeZero())
+			})
+
+			It("reduces the congestion window when a packet is lost", func() {
+				sendRound()
+				pns := harness.FillCongestionWindow(packetSize)
+				window := harness.CongestionWindow()
+				harness.AdvanceTime(50 * time.Millisecond)
+				harness.Lose(pns[0])
+				Expect(harness.CongestionWindow()).To(BeNumerically("<", window))
+				Expect(harness.CongestionWindow()).To(BeNumerically(">", window/2))
+			})
+
+			It("treats multiple losses in the same window as a single loss event", func() {
+				sendRound()
+				pns := harness.FillCongestionWindow(packetSize)
+				harness.AdvanceTime(50 * time.Millisecond)
+				harness.Lose(pns[0])
+				window := harness.CongestionWindow()
+				harness.Lose(pns[1:5]...)
+				Expect(harness.CongestionWindow()).To(Equal(window))
+			})
+
+			It("collapses the congestion window on a retransmission timeout", func() {
+				sendRound()
+				harness.FillCongestionWindow(packetSize)
+				harness.RetransmissionTimeout(true)
+				Expect(harness.CongestionWindow()).To(Equal(2 * packetSize))
+			})
+
+			It("resets the congestion window when the connection migrates", func() {
+				initialWindow := harness.CongestionWindow()
+				sendRound()
+				Expect(harness.CongestionWindow()).To(BeNumerically(">", initialWindow))
+				harness.Migrate()
+				Expect(harness.CongestionWindow()).To(Equal(initialWindow))
+			})
+		})
+	}
+
+	Context("BBR", func() {
+		var harness *congestiontest.Harness
+
+		BeforeEach(func() {
+			harness = congestiontest.NewHarness(congestion.NewBBR)
+		})
+
+		It("grows the congestion window in STARTUP", func() {
+			initialWindow := harness.CongestionWindow()
+			pns := harness.FillCongestionWindow(packetSize)
+			harness.AdvanceTime(50 * time.Millisecond)
+			harness.Ack(pns...)
+			Expect(harness.CongestionWindow()).To(BeNumerically(">", initialWindow))
+		})
+
+		It("paces packets", func() {
+			Expect(harness.Algorithm().TimeUntilSend(0)).ToNot(BeZero())
+		})
+
+		It("doesn't reduce the congestion window when a packet is lost", func() {
+			pns := harness.FillCongestionWindow(packetSize)
+			window := harness.CongestionWindow()
+			harness.AdvanceTime(50 * time.Millisecond)
+			harness.Lose(pns[0])
+			Expect(harness.CongestionWindow()).To(Equal(window))
+			harness.RetransmissionTimeout(true)
+			Expect(harness.CongestionWindow()).To(Equal(window))
+		})
+	})
+
+	It("reduces the congestion window by the Reno backoff factor", func() {
+		harness := congestiontest.NewHarness(congestion.NewReno)
+		pns := harness.FillCongestionWindow(packetSize)
+		window := harness.CongestionWindow()
+		harness.AdvanceTime(50 * time.Millisecond)
+		harness.Lose(pns[0])
+		// Reno emulates 2 connections, so the window is reduced by (1 + 0.7) / 2
+		Expect(harness.CongestionWindow()).To(Equal(congestion.ByteCount(float32(window) * 0.85)))
+	})
+})
@@ -0,0 +1,13 @@
+package congestion_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestCongestion(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Congestion Suite")
+}
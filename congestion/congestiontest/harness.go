@@ -0,0 +1,158 @@
+// Package congestiontest provides utilities for testing congestion control algorithms.
+package congestiontest
+
+import (
+	"sort"
+	"time"
+
+	"github.com/lucas-clemente/quic-go/congestion"
+	internalcongestion "github.com/lucas-clemente/quic-go/internal/congestion"
+)
+
+type sentPacket struct {
+	size     congestion.ByteCount
+	sendTime time.Time
+}
+
+// A Harness drives a congestion.SendAlgorithm with synthetic sequences of sent, acknowledged and lost packets.
+// It keeps track of the bytes in flight, and updates the RTT estimate when packets are acknowledged,
+// in the same way a QUIC connection does.
+// Time is simulated: it only advances when AdvanceTime is called.
+type Harness struct {
+	algorithm congestion.SendAlgorithm
+	rttStats  *internalcongestion.RTTStats
+
+	now              time.Time
+	nextPacketNumber congestion.PacketNumber
+	bytesInFlight    congestion.ByteCount
+	outstanding      map[congestion.PacketNumber]*sentPacket
+}
+
+// NewHarness creates a new Harness.
+// newAlgorithm is called once to create the SendAlgorithm that is tested, e.g. congestion.NewCubic.
+func NewHarness(newAlgorithm func(congestion.RTTStats) congestion.SendAlgorithm) *Harness {
+	rttStats := &internalcongestion.RTTStats{}
+	return &Harness{
+		algorithm:        newAlgorithm(rttStats),
+		rttStats:         rttStats,
+		now:              time.Unix(0, 0),
+		nextPacketNumber: 1,
+		outstanding:      make(map[congestion.PacketNumber]*sentPacket),
+	}
+}
+
+// Algorithm returns the SendAlgorithm driven by the harness.
+func (h *Harness) Algorithm() congestion.SendAlgorithm {
+	return h.algorithm
+}
+
+// RTTStats returns the RTT estimates, as seen by the SendAlgorithm.
+func (h *Harness) RTTStats() congestion.RTTStats {
+	return h.rttStats
+}
+
+// Now returns the current (simulated) time.
+func (h *Harness) Now() time.Time {
+	return h.now
+}
+
+// AdvanceTime advances the simulated time.
+func (h *Harness) AdvanceTime(d time.Duration) {
+	h.now = h.now.Add(d)
+}
+
+// BytesInFlight returns the number of bytes that were sent, but neither acknowledged nor declared lost.
+func (h *Harness) BytesInFlight() congestion.ByteCount {
+	return h.bytesInFlight
+}
+
+// CongestionWindow returns the congestion window of the SendAlgorithm.
+func (h *Harness) CongestionWindow() congestion.ByteCount {
+	return h.algorithm.GetCongestionWindow()
+}
+
+// SendPacket sends a retransmittable packet of the given size.
+// It returns the packet number of the packet.
+func (h *Harness) SendPacket(size congestion.ByteCount) congestion.PacketNumber {
+	pn := h.nextPacketNumber
+	h.nextPacketNumber++
+	h.outstanding[pn] = &sentPacket{size: size, sendTime: h.now}
+	h.bytesInFlight += size
+	h.algorithm.OnPacketSent(h.now, h.bytesInFlight, pn, size, true)
+	return pn
+}
+
+// FillCongestionWindow sends packets of the given size until the congestion window is used up.
+// It returns the packet numbers of the packets that were sent.
+func (h *Harness) FillCongestionWindow(size congestion.ByteCount) []congestion.PacketNumber {
+	var pns []congestion.PacketNumber
+	for h.bytesInFlight+size <= h.algorithm.GetCongestionWindow() {
+		pns = append(pns, h.SendPacket(size))
+	}
+	return pns
+}
+
+// Ack acknowledges packets.
+// The RTT estimate is updated using the largest acknowledged packet.
+// Packets that are not outstanding are ignored.
+func (h *Harness) Ack(pns ...congestion.PacketNumber) {
+	acked := h.filterOutstanding(pns)
+	if len(acked) == 0 {
+		return
+	}
+	largest := h.outstanding[acked[len(acked)-1]]
+	h.rttStats.UpdateRTT(h.now.Sub(largest.sendTime), 0, h.now)
+	if c, ok := h.algorithm.(internalcongestion.ExtendedSendAlgorithm); ok {
+		c.MaybeExitSlowStart()
+	}
+	priorInFlight := h.bytesInFlight
+	for _, pn := range acked {
+		p := h.outstanding[pn]
+		h.bytesInFlight -= p.size
+		h.algorithm.OnPacketAcked(pn, p.size, priorInFlight, h.now)
+		delete(h.outstanding, pn)
+	}
+}
+
+// Lose declares packets lost.
+// Packets that are not outstanding are ignored.
+func (h *Harness) Lose(pns ...congestion.PacketNumber) {
+	priorInFlight := h.bytesInFlight
+	for _, pn := range h.filterOutstanding(pns) {
+		p := h.outstanding[pn]
+		h.bytesInFlight -= p.size
+		h.algorithm.OnPacketLost(pn, p.size, priorInFlight)
+		delete(h.outstanding, pn)
+	}
+}
+
+// RetransmissionTimeout simulates a retransmission timeout.
+func (h *Harness) RetransmissionTimeout(packetsRetransmitted bool) {
+	h.algorithm.OnRetransmissionTimeout(packetsRetransmitted)
+}
+
+// Migrate simulates a connection migration.
+// The RTT estimates are reset, and the SendAlgorithm is notified if it implements the congestion.MigrationAwareSendAlgorithm.
+func (h *Harness) Migrate() {
+	h.rttStats.OnConnectionMigration()
+	if c, ok := h.algorithm.(congestion.MigrationAwareSendAlgorithm); ok {
+		c.OnConnectionMigration()
+	}
+}
+
+// filterOutstanding returns the outstanding packets among pns, in ascending order.
+func (h *Harness) filterOutstanding(pns []congestion.PacketNumber) []congestion.PacketNumber {
+	var ret []congestion.PacketNumber
+	seen := make(map[congestion.PacketNumber]struct{}, len(pns))
+	for _, pn := range pns {
+		if _, ok := seen[pn]; ok {
+			continue
+		}
+		seen[pn] = struct{}{}
+		if _, ok := h.outstanding[pn]; ok {
+			ret = append(ret, pn)
+		}
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i] < ret[j] })
+	return ret
+}
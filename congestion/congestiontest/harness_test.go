@@ -0,0 +1,105 @@
+package congestiontest
+
+import (
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/lucas-clemente/quic-go/congestion"
+	"github.com/lucas-clemente/quic-go/internal/mocks"
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Harness", func() {
+	var (
+		harness *Harness
+		alg     *mocks.MockExtendedSendAlgorithm
+	)
+
+	BeforeEach(func() {
+		alg = mocks.NewMockExtendedSendAlgorithm(mockCtrl)
+		harness = NewHarness(func(rttStats congestion.RTTStats) congestion.SendAlgorithm {
+			Expect(rttStats).ToNot(BeNil())
+			return alg
+		})
+	})
+
+	It("returns the algorithm", func() {
+		Expect(harness.Algorithm()).To(Equal(alg))
+	})
+
+	It("advances the time", func() {
+		start := harness.Now()
+		harness.AdvanceTime(time.Second)
+		Expect(harness.Now()).To(Equal(start.Add(time.Second)))
+	})
+
+	It("sends packets", func() {
+		gomock.InOrder(
+			alg.EXPECT().OnPacketSent(harness.Now(), protocol.ByteCount(1000), protocol.PacketNumber(1), protocol.ByteCount(1000), true),
+			alg.EXPECT().OnPacketSent(harness.Now(), protocol.ByteCount(1500), protocol.PacketNumber(2), protocol.ByteCount(500), true),
+		)
+		Expect(harness.SendPacket(1000)).To(Equal(protocol.PacketNumber(1)))
+		Expect(harness.SendPacket(500)).To(Equal(protocol.PacketNumber(2)))
+		Expect(harness.BytesInFlight()).To(Equal(protocol.ByteCount(1500)))
+	})
+
+	It("fills the congestion window", func() {
+		alg.EXPECT().GetCongestionWindow().Return(protocol.ByteCount(3500)).AnyTimes()
+		alg.EXPECT().OnPacketSent(gomock.Any(), gomock.Any(), gomock.Any(), protocol.ByteCount(1000), true).Times(3)
+		Expect(harness.FillCongestionWindow(1000)).To(Equal([]protocol.PacketNumber{1, 2, 3}))
+		Expect(harness.BytesInFlight()).To(Equal(protocol.ByteCount(3000)))
+		Expect(harness.CongestionWindow()).To(Equal(protocol.ByteCount(3500)))
+	})
+
+	It("acknowledges packets and updates the RTT", func() {
+		alg.EXPECT().OnPacketSent(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(3)
+		harness.SendPacket(1000)
+		harness.AdvanceTime(10 * time.Millisecond)
+		harness.SendPacket(1000)
+		harness.SendPacket(1000)
+		harness.AdvanceTime(50 * time.Millisecond)
+		gomock.InOrder(
+			alg.EXPECT().MaybeExitSlowStart(),
+			alg.EXPECT().OnPacketAcked(protocol.PacketNumber(1), protocol.ByteCount(1000), protocol.ByteCount(3000), harness.Now()),
+			alg.EXPECT().OnPacketAcked(protocol.PacketNumber(3), protocol.ByteCount(1000), protocol.ByteCount(3000), harness.Now()),
+		)
+		harness.Ack(3, 1, 3, 42)
+		Expect(harness.RTTStats().LatestRTT()).To(Equal(50 * time.Millisecond))
+		Expect(harness.BytesInFlight()).To(Equal(protocol.ByteCount(1000)))
+		// packet 1 was already acknowledged
+		harness.Ack(1)
+	})
+
+	It("declares packets lost", func() {
+		alg.EXPECT().OnPacketSent(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(3)
+		harness.SendPacket(1000)
+		harness.SendPacket(1000)
+		harness.SendPacket(1000)
+		gomock.InOrder(
+			alg.EXPECT().OnPacketLost(protocol.PacketNumber(1), protocol.ByteCount(1000), protocol.ByteCount(3000)),
+			alg.EXPECT().OnPacketLost(protocol.PacketNumber(2), protocol.ByteCount(1000), protocol.ByteCount(3000)),
+		)
+		harness.Lose(2, 1)
+		Expect(harness.BytesInFlight()).To(Equal(protocol.ByteCount(1000)))
+	})
+
+	It("simulates retransmission timeouts", func() {
+		alg.EXPECT().OnRetransmissionTimeout(true)
+		harness.RetransmissionTimeout(true)
+	})
+
+	It("simulates connection migrations", func() {
+		alg.EXPECT().OnPacketSent(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any())
+		alg.EXPECT().MaybeExitSlowStart()
+		alg.EXPECT().OnPacketAcked(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any())
+		pn := harness.SendPacket(1000)
+		harness.AdvanceTime(50 * time.Millisecond)
+		harness.Ack(pn)
+		Expect(harness.RTTStats().SmoothedRTT()).ToNot(BeZero())
+		alg.EXPECT().OnConnectionMigration()
+		harness.Migrate()
+		Expect(harness.RTTStats().SmoothedRTT()).To(BeZero())
+	})
+})
@@ -0,0 +1,69 @@
+// Package congestion defines the interface that congestion control algorithms used by quic-go implement.
+//
+// A congestion control algorithm is selected using the CongestionControl field of the quic.Config.
+package congestion
+
+import (
+	"time"
+
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+)
+
+// A ByteCount is a number of bytes.
+type ByteCount = protocol.ByteCount
+
+// A PacketNumber is a QUIC packet number.
+type PacketNumber = protocol.PacketNumber
+
+// A SendAlgorithm performs congestion control and calculates the congestion window.
+// A new SendAlgorithm is created for every connection.
+// The methods are called from a single go routine and don't need to be safe for concurrent use.
+type SendAlgorithm interface {
+	// TimeUntilSend returns the pacing delay until the next packet may be sent.
+	TimeUntilSend(bytesInFlight ByteCount) time.Duration
+	// OnPacketSent is called for every packet that is sent.
+	// bytesInFlight is the number of bytes in flight, including this packet.
+	OnPacketSent(sentTime time.Time, bytesInFlight ByteCount, packetNumber PacketNumber, bytes ByteCount, isRetransmittable bool)
+	// GetCongestionWindow returns the current congestion window.
+	GetCongestionWindow() ByteCount
+	// OnPacketAcked is called for every packet that is newly acknowledged.
+	// priorInFlight is the number of bytes in flight before the ACK frame was processed.
+	OnPacketAcked(number PacketNumber, ackedBytes ByteCount, priorInFlight ByteCount, eventTime time.Time)
+	// OnPacketLost is called for every packet that is declared lost.
+	OnPacketLost(number PacketNumber, lostBytes ByteCount, priorInFlight ByteCount)
+	// OnRetransmissionTimeout is called when a retransmission timeout fires.
+	OnRetransmissionTimeout(packetsRetransmitted bool)
+}
+
+// An ECNSendAlgorithm is a SendAlgorithm that reacts to Explicit Congestion Notification (ECN).
+// Implementing this interface is optional.
+// If a SendAlgorithm doesn't implement it, ECN is not used, even if it is enabled in the quic.Config.
+type ECNSendAlgorithm interface {
+	SendAlgorithm
+	// OnCongestionEvent is called when the peer reports that packets were marked ECN-CE.
+	// largestAcked is the largest packet number acknowledged by the ACK frame that reported the marks.
+	OnCongestionEvent(largestAcked PacketNumber, priorInFlight ByteCount)
+}
+
+// A MigrationAwareSendAlgorithm is a SendAlgorithm that is notified when the connection migrates to a new path.
+// Implementing this interface is optional.
+// If a SendAlgorithm doesn't implement it, it keeps the state it built up for the old path.
+// The RTT estimates are reset on a migration in any case.
+type MigrationAwareSendAlgorithm interface {
+	SendAlgorithm
+	// OnConnectionMigration is called when the connection migrates to a new path.
+	// Since nothing is known about the capacity of the new path, the congestion window should be reset.
+	OnConnectionMigration()
+}
+
+// RTTStats provides the RTT estimates of a connection.
+type RTTStats interface {
+	// MinRTT is the smallest RTT sample observed on the connection.
+	MinRTT() time.Duration
+	// LatestRTT is the most recent RTT sample.
+	LatestRTT() time.Duration
+	// SmoothedRTT is the exponentially weighted moving average of the RTT samples.
+	SmoothedRTT() time.Duration
+	// MeanDeviation is the mean deviation of the RTT samples.
+	MeanDeviation() time.Duration
+}
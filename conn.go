@@ -3,15 +3,60 @@ package quic
 import (
 	"net"
 	"sync"
+
+	"golang.org/x/net/ipv4"
 )
 
 type connection interface {
 	Write([]byte) error
+	// WriteBatch writes multiple packets.
+	// If batched I/O is enabled, this uses as few system calls as possible.
+	WriteBatch([][]byte) error
+	WriteTo([]byte, net.Addr) error
 	Read([]byte) (int, net.Addr, error)
 	Close() error
 	LocalAddr() net.Addr
 	RemoteAddr() net.Addr
 	SetCurrentRemoteAddr(net.Addr)
+	// SetDF sets the Don't Fragment bit on outgoing packets.
+	// It returns false if this is not supported.
+	SetDF() bool
+	// SetECN marks outgoing packets with ECT(0), and enables reading the ECN codepoint of received packets.
+	// It returns false if this is not supported.
+	SetECN() bool
+	// SetECNMarking enables or disables the ECT(0) marking of packets written on this connection.
+	// It has no effect if SetECN failed.
+	SetECNMarking(bool)
+}
+
+// The size of the buffer for the control messages received along with a packet.
+// It needs to be large enough to hold the TOS / Traffic Class field, which contains the ECN codepoint.
+const ecnControlMsgSize = 64
+
+// A batchReader reads multiple packets with a single system call.
+// It is implemented by ipv4.PacketConn and ipv6.PacketConn.
+type batchReader interface {
+	ReadBatch(ms []ipv4.Message, flags int) (int, error)
+}
+
+// A batchConn reads and writes multiple packets with a single system call.
+type batchConn interface {
+	batchReader
+	WriteBatch(ms []ipv4.Message, flags int) (int, error)
+}
+
+// A batchWriter writes multiple packets to the same address.
+type batchWriter interface {
+	WriteBatch(ps [][]byte, addr net.Addr) error
+}
+
+// getBatchWriter returns the batchWriter for a packet conn.
+// It returns nil if batched I/O is disabled or not supported.
+func getBatchWriter(pconn net.PacketConn, enabled bool) batchWriter {
+	if !enabled {
+		return nil
+	}
+	return newBatchWriter(pconn)
 }
 
 type conn struct {
@@ -19,12 +64,44 @@ type conn struct {
 
 	pconn       net.PacketConn
 	currentAddr net.Addr
+	batchWriter batchWriter // nil if batched I/O is not used
+
+	ecn      bool // does the socket mark packets ECT(0)
+	clearECN bool // if set, packets are sent as Not-ECT, overriding the socket option
 }
 
 var _ connection = &conn{}
 
 func (c *conn) Write(p []byte) error {
-	_, err := c.pconn.WriteTo(p, c.currentAddr)
+	c.mutex.RLock()
+	addr := c.currentAddr
+	clearECN := c.clearECN
+	c.mutex.RUnlock()
+	if clearECN {
+		return writeWithoutECN(c.pconn, p, addr)
+	}
+	_, err := c.pconn.WriteTo(p, addr)
+	return err
+}
+
+func (c *conn) WriteBatch(ps [][]byte) error {
+	c.mutex.RLock()
+	clearECN := c.clearECN
+	c.mutex.RUnlock()
+	// The batchWriter uses the ECN marking of the socket.
+	if c.batchWriter == nil || clearECN {
+		for _, p := range ps {
+			if err := c.Write(p); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return c.batchWriter.WriteBatch(ps, c.RemoteAddr())
+}
+
+func (c *conn) WriteTo(p []byte, addr net.Addr) error {
+	_, err := c.pconn.WriteTo(p, addr)
 	return err
 }
 
@@ -38,6 +115,24 @@ func (c *conn) SetCurrentRemoteAddr(addr net.Addr) {
 	c.mutex.Unlock()
 }
 
+func (c *conn) SetDF() bool {
+	return setDF(c.pconn)
+}
+
+func (c *conn) SetECN() bool {
+	ecn := setECN(c.pconn)
+	c.mutex.Lock()
+	c.ecn = ecn
+	c.mutex.Unlock()
+	return ecn
+}
+
+func (c *conn) SetECNMarking(enabled bool) {
+	c.mutex.Lock()
+	c.clearECN = c.ecn && !enabled
+	c.mutex.Unlock()
+}
+
 func (c *conn) LocalAddr() net.Addr {
 	return c.pconn.LocalAddr()
 }
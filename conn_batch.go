@@ -0,0 +1,9 @@
+// +build !linux
+
+package quic
+
+import "net"
+
+func newBatchReader(net.PacketConn) batchReader { return nil }
+
+func newBatchWriter(net.PacketConn) batchWriter { return nil }
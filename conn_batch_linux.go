@@ -0,0 +1,188 @@
+// +build linux
+
+package quic
+
+import (
+	"net"
+	"os"
+	"sync"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/net/ipv4"
+)
+
+const (
+	// UDP_SEGMENT is not defined in the syscall package
+	udpSegment = 103
+	// The kernel doesn't accept more than 64 segments in a single GSO buffer.
+	maxGSOSegments = 64
+	// The payload of a GSO buffer must fit into a single UDP datagram.
+	maxGSOSize = 65000
+)
+
+func newBatchReader(pconn net.PacketConn) batchReader {
+	c, ok := pconn.(*net.UDPConn)
+	if !ok {
+		return nil
+	}
+	// The ipv4.PacketConn works for IPv6 sockets as well.
+	// The addresses of received packets are parsed based on the address family of the sockaddr.
+	return ipv4.NewPacketConn(c)
+}
+
+// The mmsgWriter writes packets using sendmmsg.
+// If the kernel supports UDP generic segmentation offload (GSO),
+// consecutive packets of the same size are passed to the kernel in a single buffer,
+// which is then split into packets by the kernel (or by the NIC).
+type mmsgWriter struct {
+	mutex sync.Mutex
+
+	pconn net.PacketConn
+	conn  batchConn
+	ipv4  bool // is this an IPv4 socket
+	gso   bool
+
+	msgs   []ipv4.Message
+	gsoBuf []byte
+}
+
+func newBatchWriter(pconn net.PacketConn) batchWriter {
+	c, ok := pconn.(*net.UDPConn)
+	if !ok {
+		return nil
+	}
+	localAddr, ok := c.LocalAddr().(*net.UDPAddr)
+	if !ok {
+		return nil
+	}
+	return &mmsgWriter{
+		pconn: pconn,
+		conn:  ipv4.NewPacketConn(c),
+		ipv4:  localAddr.IP.To4() != nil,
+		gso:   isGSOSupported(c),
+	}
+}
+
+func (w *mmsgWriter) WriteBatch(ps [][]byte, addr net.Addr) error {
+	udpAddr, ok := addr.(*net.UDPAddr)
+	// sendmmsg encodes IPv4 addresses as a sockaddr_in, which is rejected by dual-stack IPv6 sockets.
+	if !ok || (udpAddr.IP.To4() != nil) != w.ipv4 {
+		for _, p := range ps {
+			if _, err := w.pconn.WriteTo(p, addr); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	err := w.writeBatch(ps, addr)
+	if err != nil && w.gso && isGSOErr(err) {
+		// GSO is supported by the kernel, but not by the network interface.
+		// Some packets might have been sent already, but sending a packet twice is harmless.
+		w.gso = false
+		return w.writeBatch(ps, addr)
+	}
+	return err
+}
+
+func (w *mmsgWriter) writeBatch(ps [][]byte, addr net.Addr) error {
+	w.msgs = w.msgs[:0]
+	if w.gso {
+		w.appendGSOMessages(ps, addr)
+	} else {
+		for _, p := range ps {
+			w.msgs = append(w.msgs, ipv4.Message{Buffers: [][]byte{p}, Addr: addr})
+		}
+	}
+	msgs := w.msgs
+	for len(msgs) > 0 {
+		n, err := w.conn.WriteBatch(msgs, 0)
+		if err != nil {
+			return err
+		}
+		msgs = msgs[n:]
+	}
+	return nil
+}
+
+func (w *mmsgWriter) appendGSOMessages(ps [][]byte, addr net.Addr) {
+	var size int
+	for _, p := range ps {
+		size += len(p)
+	}
+	// Make sure that the buffer is never reallocated, since the messages reference it.
+	if cap(w.gsoBuf) < size {
+		w.gsoBuf = make([]byte, 0, size)
+	}
+	w.gsoBuf = w.gsoBuf[:0]
+	for len(ps) > 0 {
+		segmentSize := len(ps[0])
+		n := 1
+		total := segmentSize
+		// All segments have the same size, only the last segment may be shorter.
+		for n < len(ps) && n < maxGSOSegments && len(ps[n]) <= segmentSize && total+len(ps[n]) <= maxGSOSize {
+			total += len(ps[n])
+			n++
+			if len(ps[n-1]) < segmentSize {
+				break
+			}
+		}
+		if n == 1 {
+			w.msgs = append(w.msgs, ipv4.Message{Buffers: [][]byte{ps[0]}, Addr: addr})
+			ps = ps[1:]
+			continue
+		}
+		start := len(w.gsoBuf)
+		for _, p := range ps[:n] {
+			w.gsoBuf = append(w.gsoBuf, p...)
+		}
+		w.msgs = append(w.msgs, ipv4.Message{
+			Buffers: [][]byte{w.gsoBuf[start:]},
+			OOB:     appendUDPSegmentSizeMsg(nil, uint16(segmentSize)),
+			Addr:    addr,
+		})
+		ps = ps[n:]
+	}
+}
+
+func isGSOSupported(c *net.UDPConn) bool {
+	rawConn, err := c.SyscallConn()
+	if err != nil {
+		return false
+	}
+	var serr error
+	if err := rawConn.Control(func(fd uintptr) {
+		_, serr = syscall.GetsockoptInt(int(fd), syscall.IPPROTO_UDP, udpSegment)
+	}); err != nil {
+		return false
+	}
+	return serr == nil
+}
+
+func isGSOErr(err error) bool {
+	opErr, ok := err.(*net.OpError)
+	if !ok {
+		return false
+	}
+	if sysErr, ok := opErr.Err.(*os.SyscallError); ok {
+		return sysErr.Err == syscall.EIO
+	}
+	return opErr.Err == syscall.EIO
+}
+
+// appendUDPSegmentSizeMsg appends a control message that sets the GSO segment size.
+func appendUDPSegmentSizeMsg(b []byte, size uint16) []byte {
+	const dataLen = 2 // the segment size is a uint16
+	startLen := len(b)
+	b = append(b, make([]byte, syscall.CmsgSpace(dataLen))...)
+	h := (*syscall.Cmsghdr)(unsafe.Pointer(&b[startLen]))
+	h.Level = syscall.IPPROTO_UDP
+	h.Type = udpSegment
+	h.SetLen(syscall.CmsgLen(dataLen))
+	*(*uint16)(unsafe.Pointer(&b[startLen+syscall.CmsgSpace(0)])) = size
+	return b
+}
@@ -0,0 +1,212 @@
+// +build linux
+
+package quic
+
+import (
+	"bytes"
+	"net"
+	"os"
+	"syscall"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"golang.org/x/net/ipv4"
+)
+
+type mockBatchConn struct {
+	written [][]ipv4.Message
+	err     func([]ipv4.Message) error
+}
+
+func (c *mockBatchConn) ReadBatch([]ipv4.Message, int) (int, error) { panic("not implemented") }
+
+func (c *mockBatchConn) WriteBatch(ms []ipv4.Message, _ int) (int, error) {
+	if c.err != nil {
+		if err := c.err(ms); err != nil {
+			return 0, err
+		}
+	}
+	c.written = append(c.written, ms)
+	return len(ms), nil
+}
+
+var _ = Describe("Batched I/O", func() {
+	getPackets := func(sizes ...int) [][]byte {
+		ps := make([][]byte, len(sizes))
+		for i, s := range sizes {
+			ps[i] = bytes.Repeat([]byte{byte(i)}, s)
+		}
+		return ps
+	}
+
+	receive := func(conn *net.UDPConn, num int) [][]byte {
+		var ps [][]byte
+		Expect(conn.SetReadDeadline(time.Now().Add(time.Second))).To(Succeed())
+		for i := 0; i < num; i++ {
+			b := make([]byte, 2000)
+			n, _, err := conn.ReadFrom(b)
+			Expect(err).ToNot(HaveOccurred())
+			ps = append(ps, b[:n])
+		}
+		return ps
+	}
+
+	listen := func() *net.UDPConn {
+		conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+		Expect(err).ToNot(HaveOccurred())
+		return conn
+	}
+
+	It("reads packets in batches", func() {
+		server := listen()
+		defer server.Close()
+		client := listen()
+		defer client.Close()
+		packets := getPackets(100, 200, 300)
+		for _, p := range packets {
+			_, err := client.WriteTo(p, server.LocalAddr())
+			Expect(err).ToNot(HaveOccurred())
+		}
+		r := newBatchReader(server)
+		Expect(r).ToNot(BeNil())
+		msgs := make([]ipv4.Message, 10)
+		for i := range msgs {
+			msgs[i].Buffers = [][]byte{make([]byte, 1000)}
+		}
+		var received [][]byte
+		Expect(server.SetReadDeadline(time.Now().Add(time.Second))).To(Succeed())
+		for len(received) < len(packets) {
+			n, err := r.ReadBatch(msgs, 0)
+			Expect(err).ToNot(HaveOccurred())
+			for _, msg := range msgs[:n] {
+				Expect(msg.Addr.String()).To(Equal(client.LocalAddr().String()))
+				received = append(received, msg.Buffers[0][:msg.N])
+			}
+		}
+		Expect(received).To(Equal(packets))
+	})
+
+	It("doesn't read in batches if the connection is not a UDP connection", func() {
+		Expect(newBatchReader(newMockPacketConn())).To(BeNil())
+	})
+
+	It("writes packets using sendmmsg", func() {
+		server := listen()
+		defer server.Close()
+		client := listen()
+		defer client.Close()
+		w := newBatchWriter(client).(*mmsgWriter)
+		w.gso = false
+		packets := getPackets(1000, 1000, 1000, 500)
+		Expect(w.WriteBatch(packets, server.LocalAddr())).To(Succeed())
+		Expect(receive(server, len(packets))).To(Equal(packets))
+	})
+
+	It("writes packets using GSO", func() {
+		server := listen()
+		defer server.Close()
+		client := listen()
+		defer client.Close()
+		w := newBatchWriter(client).(*mmsgWriter)
+		if !w.gso {
+			Skip("GSO not supported")
+		}
+		packets := getPackets(1000, 1000, 1000, 500, 1200)
+		Expect(w.WriteBatch(packets, server.LocalAddr())).To(Succeed())
+		Expect(receive(server, len(packets))).To(Equal(packets))
+	})
+
+	It("writes packets one by one, if the address family doesn't match", func() {
+		client, err := net.ListenUDP("udp", &net.UDPAddr{})
+		if err != nil {
+			Skip("IPv6 not supported")
+		}
+		defer client.Close()
+		if client.LocalAddr().(*net.UDPAddr).IP.To4() != nil {
+			Skip("not a dual-stack socket")
+		}
+		server := listen()
+		defer server.Close()
+		packets := getPackets(1000, 1000)
+		Expect(newBatchWriter(client).WriteBatch(packets, server.LocalAddr())).To(Succeed())
+		Expect(receive(server, len(packets))).To(Equal(packets))
+	})
+
+	Context("coalescing packets for GSO", func() {
+		var (
+			conn *mockBatchConn
+			w    *mmsgWriter
+			addr *net.UDPAddr
+		)
+
+		BeforeEach(func() {
+			conn = &mockBatchConn{}
+			w = &mmsgWriter{conn: conn, ipv4: true, gso: true}
+			addr = &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 1234}
+		})
+
+		It("coalesces packets of the same size", func() {
+			packets := getPackets(1000, 1000, 1000, 500, 1000, 1000)
+			Expect(w.WriteBatch(packets, addr)).To(Succeed())
+			Expect(conn.written).To(HaveLen(1))
+			msgs := conn.written[0]
+			Expect(msgs).To(HaveLen(2))
+			Expect(msgs[0].Buffers[0]).To(Equal(bytes.Join(packets[:4], nil)))
+			Expect(msgs[0].OOB).To(Equal(appendUDPSegmentSizeMsg(nil, 1000)))
+			Expect(msgs[1].Buffers[0]).To(Equal(bytes.Join(packets[4:], nil)))
+			Expect(msgs[1].OOB).To(Equal(appendUDPSegmentSizeMsg(nil, 1000)))
+		})
+
+		It("doesn't use GSO for single packets", func() {
+			packets := getPackets(1000, 1200)
+			Expect(w.WriteBatch(packets, addr)).To(Succeed())
+			msgs := conn.written[0]
+			Expect(msgs).To(HaveLen(2))
+			for i, msg := range msgs {
+				Expect(msg.Buffers[0]).To(Equal(packets[i]))
+				Expect(msg.OOB).To(BeEmpty())
+			}
+		})
+
+		It("limits the number of segments", func() {
+			sizes := make([]int, 100)
+			for i := range sizes {
+				sizes[i] = 600
+			}
+			Expect(w.WriteBatch(getPackets(sizes...), addr)).To(Succeed())
+			msgs := conn.written[0]
+			Expect(msgs).To(HaveLen(2))
+			Expect(msgs[0].Buffers[0]).To(HaveLen(maxGSOSegments * 600))
+			Expect(msgs[1].Buffers[0]).To(HaveLen((100 - maxGSOSegments) * 600))
+		})
+
+		It("limits the size of a GSO buffer", func() {
+			sizes := make([]int, 50)
+			for i := range sizes {
+				sizes[i] = 1400
+			}
+			Expect(w.WriteBatch(getPackets(sizes...), addr)).To(Succeed())
+			msgs := conn.written[0]
+			Expect(msgs).To(HaveLen(2))
+			Expect(len(msgs[0].Buffers[0])).To(BeNumerically("<=", maxGSOSize))
+			Expect(len(msgs[0].Buffers[0]) + len(msgs[1].Buffers[0])).To(Equal(50 * 1400))
+		})
+
+		It("disables GSO if the network interface doesn't support it", func() {
+			conn.err = func(ms []ipv4.Message) error {
+				for _, m := range ms {
+					if len(m.OOB) > 0 {
+						return &net.OpError{Op: "write", Err: os.NewSyscallError("sendmmsg", syscall.EIO)}
+					}
+				}
+				return nil
+			}
+			packets := getPackets(1000, 1000)
+			Expect(w.WriteBatch(packets, addr)).To(Succeed())
+			Expect(w.gso).To(BeFalse())
+			Expect(conn.written).To(HaveLen(1))
+			Expect(conn.written[0]).To(HaveLen(2))
+		})
+	})
+})
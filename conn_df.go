@@ -0,0 +1,11 @@
+// +build !linux
+
+package quic
+
+import "net"
+
+// setDF sets the Don't Fragment (DF) bit on packets sent on this connection.
+// It is not implemented on this platform, which disables Path MTU Discovery.
+func setDF(net.PacketConn) bool { return false }
+
+func isMsgSizeErr(error) bool { return false }
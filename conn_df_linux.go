@@ -0,0 +1,48 @@
+// +build linux
+
+package quic
+
+import (
+	"net"
+	"os"
+	"syscall"
+)
+
+// setDF sets the Don't Fragment (DF) bit on packets sent on this connection.
+// This is necessary for Path MTU Discovery: without the DF bit, probe packets
+// could be fragmented by the network, and the probe would wrongly succeed.
+// It returns false if the DF bit couldn't be set.
+func setDF(pconn net.PacketConn) bool {
+	c, ok := pconn.(interface {
+		SyscallConn() (syscall.RawConn, error)
+	})
+	if !ok {
+		return false
+	}
+	rawConn, err := c.SyscallConn()
+	if err != nil {
+		return false
+	}
+	var errDFIPv4, errDFIPv6 error
+	if err := rawConn.Control(func(fd uintptr) {
+		errDFIPv4 = syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IP, syscall.IP_MTU_DISCOVER, syscall.IP_PMTUDISC_DO)
+		errDFIPv6 = syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IPV6, syscall.IPV6_MTU_DISCOVER, syscall.IPV6_PMTUDISC_DO)
+	}); err != nil {
+		return false
+	}
+	// For IPv4 sockets, setting the IPv6 option fails, and vice versa.
+	return errDFIPv4 == nil || errDFIPv6 == nil
+}
+
+// isMsgSizeErr says if sending a packet failed because it was larger than the MTU of the interface.
+func isMsgSizeErr(err error) bool {
+	opErr, ok := err.(*net.OpError)
+	if !ok {
+		return false
+	}
+	syscallErr, ok := opErr.Err.(*os.SyscallError)
+	if !ok {
+		return false
+	}
+	return syscallErr.Err == syscall.EMSGSIZE
+}
@@ -0,0 +1,36 @@
+// +build linux
+
+package quic
+
+import (
+	"errors"
+	"net"
+	"os"
+	"syscall"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Setting the DF bit", func() {
+	It("sets the DF bit on an IPv4 UDP connection", func() {
+		conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+		Expect(err).ToNot(HaveOccurred())
+		defer conn.Close()
+		Expect(setDF(conn)).To(BeTrue())
+		rawConn, err := conn.SyscallConn()
+		Expect(err).ToNot(HaveOccurred())
+		var val int
+		Expect(rawConn.Control(func(fd uintptr) {
+			val, err = syscall.GetsockoptInt(int(fd), syscall.IPPROTO_IP, syscall.IP_MTU_DISCOVER)
+		})).To(Succeed())
+		Expect(err).ToNot(HaveOccurred())
+		Expect(val).To(Equal(syscall.IP_PMTUDISC_DO))
+	})
+
+	It("detects EMSGSIZE errors", func() {
+		Expect(isMsgSizeErr(&net.OpError{Op: "write", Err: os.NewSyscallError("sendto", syscall.EMSGSIZE)})).To(BeTrue())
+		Expect(isMsgSizeErr(&net.OpError{Op: "write", Err: os.NewSyscallError("sendto", syscall.ECONNREFUSED)})).To(BeFalse())
+		Expect(isMsgSizeErr(errors.New("foobar"))).To(BeFalse())
+	})
+})
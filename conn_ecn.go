@@ -0,0 +1,20 @@
+// +build !linux
+
+package quic
+
+import (
+	"net"
+
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+)
+
+// setECN enables ECN on the connection.
+// It is not implemented on this platform, so packets are never marked.
+func setECN(net.PacketConn) bool { return false }
+
+func parseECN([]byte) protocol.ECN { return protocol.ECNNon }
+
+func writeWithoutECN(pconn net.PacketConn, p []byte, addr net.Addr) error {
+	_, err := pconn.WriteTo(p, addr)
+	return err
+}
@@ -0,0 +1,93 @@
+// +build linux
+
+package quic
+
+import (
+	"net"
+	"syscall"
+	"unsafe"
+
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+)
+
+// The ECN codepoint occupies the two least significant bits of the TOS / Traffic Class field.
+const ecnMask = 0x3
+
+// notECTControlMsg is the control message that overrides the ECN marking configured on the socket.
+// Depending on the address family, the kernel only evaluates one of the two messages, and ignores the other one.
+var notECTControlMsg = appendTOSMsg(appendTOSMsg(nil, syscall.IPPROTO_IP, syscall.IP_TOS, 0), syscall.IPPROTO_IPV6, syscall.IPV6_TCLASS, 0)
+
+// setECN configures the socket to mark outgoing packets with ECT(0),
+// and to report the TOS / Traffic Class field of received packets.
+// It returns false if ECN couldn't be enabled.
+func setECN(pconn net.PacketConn) bool {
+	c, ok := pconn.(interface {
+		SyscallConn() (syscall.RawConn, error)
+	})
+	if !ok {
+		return false
+	}
+	rawConn, err := c.SyscallConn()
+	if err != nil {
+		return false
+	}
+	var errIPv4, errIPv6 error
+	if err := rawConn.Control(func(fd uintptr) {
+		errIPv4 = syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IP, syscall.IP_TOS, int(protocol.ECT0))
+		if errIPv4 == nil {
+			errIPv4 = syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IP, syscall.IP_RECVTOS, 1)
+		}
+		errIPv6 = syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IPV6, syscall.IPV6_TCLASS, int(protocol.ECT0))
+		if errIPv6 == nil {
+			errIPv6 = syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IPV6, syscall.IPV6_RECVTCLASS, 1)
+		}
+	}); err != nil {
+		return false
+	}
+	// For IPv4 sockets, setting the IPv6 options fails.
+	// Dual-stack IPv6 sockets accept both.
+	return errIPv4 == nil || errIPv6 == nil
+}
+
+// parseECN parses the ECN codepoint from the control messages received along with a packet.
+func parseECN(oob []byte) protocol.ECN {
+	msgs, err := syscall.ParseSocketControlMessage(oob)
+	if err != nil {
+		return protocol.ECNNon
+	}
+	for _, msg := range msgs {
+		switch {
+		case msg.Header.Level == syscall.IPPROTO_IP && msg.Header.Type == syscall.IP_TOS && len(msg.Data) >= 1:
+			return protocol.ECN(msg.Data[0] & ecnMask)
+		case msg.Header.Level == syscall.IPPROTO_IPV6 && msg.Header.Type == syscall.IPV6_TCLASS && len(msg.Data) >= 4:
+			// The Traffic Class is an int, in host byte order.
+			return protocol.ECN(*(*int32)(unsafe.Pointer(&msg.Data[0])) & ecnMask)
+		}
+	}
+	return protocol.ECNNon
+}
+
+// writeWithoutECN writes a packet as Not-ECT, even if the socket is configured to mark packets ECT(0).
+// This is used when ECN validation failed for a connection that shares the socket with other connections.
+func writeWithoutECN(pconn net.PacketConn, p []byte, addr net.Addr) error {
+	c, ok := pconn.(*net.UDPConn)
+	udpAddr, ok2 := addr.(*net.UDPAddr)
+	if !ok || !ok2 {
+		_, err := pconn.WriteTo(p, addr)
+		return err
+	}
+	_, _, err := c.WriteMsgUDP(p, notECTControlMsg, udpAddr)
+	return err
+}
+
+func appendTOSMsg(b []byte, level, typ int32, tos int32) []byte {
+	const dataLen = 4 // both IP_TOS and IPV6_TCLASS accept an int
+	startLen := len(b)
+	b = append(b, make([]byte, syscall.CmsgSpace(dataLen))...)
+	h := (*syscall.Cmsghdr)(unsafe.Pointer(&b[startLen]))
+	h.Level = level
+	h.Type = typ
+	h.SetLen(syscall.CmsgLen(dataLen))
+	*(*int32)(unsafe.Pointer(&b[startLen+syscall.CmsgSpace(0)])) = tos
+	return b
+}
@@ -0,0 +1,148 @@
+// +build linux
+
+package quic
+
+import (
+	"fmt"
+	"net"
+	"syscall"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+	"github.com/lucas-clemente/quic-go/internal/utils"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ECN", func() {
+	listen := func(network string, ip net.IP) *net.UDPConn {
+		conn, err := net.ListenUDP(network, &net.UDPAddr{IP: ip})
+		if err != nil {
+			Skip("address family not supported")
+		}
+		return conn
+	}
+
+	receive := func(conn *net.UDPConn) ([]byte, protocol.ECN) {
+		b := make([]byte, 100)
+		oob := make([]byte, ecnControlMsgSize)
+		Expect(conn.SetReadDeadline(time.Now().Add(time.Second))).To(Succeed())
+		n, oobn, _, _, err := conn.ReadMsgUDP(b, oob)
+		Expect(err).ToNot(HaveOccurred())
+		return b[:n], parseECN(oob[:oobn])
+	}
+
+	for _, f := range []struct {
+		name    string
+		network string
+		ip      net.IP
+	}{
+		{name: "IPv4", network: "udp4", ip: net.IPv4(127, 0, 0, 1)},
+		{name: "IPv6", network: "udp6", ip: net.IPv6loopback},
+	} {
+		network := f.network
+		ip := f.ip
+
+		Context(f.name, func() {
+			It("marks packets ECT(0)", func() {
+				server := listen(network, ip)
+				defer server.Close()
+				client := listen(network, ip)
+				defer client.Close()
+				Expect(setECN(server)).To(BeTrue())
+				Expect(setECN(client)).To(BeTrue())
+				_, err := client.WriteTo([]byte("foobar"), server.LocalAddr())
+				Expect(err).ToNot(HaveOccurred())
+				data, ecn := receive(server)
+				Expect(data).To(Equal([]byte("foobar")))
+				Expect(ecn).To(Equal(protocol.ECT0))
+			})
+
+			It("sends packets without ECN marking", func() {
+				server := listen(network, ip)
+				defer server.Close()
+				client := listen(network, ip)
+				defer client.Close()
+				Expect(setECN(server)).To(BeTrue())
+				c := &conn{pconn: client, currentAddr: server.LocalAddr()}
+				Expect(c.SetECN()).To(BeTrue())
+				c.SetECNMarking(false)
+				Expect(c.Write([]byte("foobar"))).To(Succeed())
+				data, ecn := receive(server)
+				Expect(data).To(Equal([]byte("foobar")))
+				Expect(ecn).To(Equal(protocol.ECNNon))
+				c.SetECNMarking(true)
+				Expect(c.Write([]byte("raboof"))).To(Succeed())
+				data, ecn = receive(server)
+				Expect(data).To(Equal([]byte("raboof")))
+				Expect(ecn).To(Equal(protocol.ECT0))
+			})
+		})
+	}
+
+	It("reads the ECN codepoint of IPv4 packets on a dual-stack socket", func() {
+		server := listen("udp", net.IPv6unspecified)
+		defer server.Close()
+		if server.LocalAddr().(*net.UDPAddr).IP.To4() != nil {
+			Skip("not a dual-stack socket")
+		}
+		client := listen("udp4", net.IPv4(127, 0, 0, 1))
+		defer client.Close()
+		Expect(setECN(server)).To(BeTrue())
+		Expect(setECN(client)).To(BeTrue())
+		_, err := client.WriteTo([]byte("foobar"), &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: server.LocalAddr().(*net.UDPAddr).Port})
+		Expect(err).ToNot(HaveOccurred())
+		_, ecn := receive(server)
+		Expect(ecn).To(Equal(protocol.ECT0))
+	})
+
+	for _, batched := range []bool{false, true} {
+		batchedIO := batched
+
+		It(fmt.Sprintf("passes the ECN codepoint of received packets to the session (batched I/O: %t)", batchedIO), func() {
+			server := listen("udp4", net.IPv4(127, 0, 0, 1))
+			client := listen("udp4", net.IPv4(127, 0, 0, 1))
+			defer client.Close()
+			Expect(setECN(client)).To(BeTrue())
+			handler := newPacketHandlerMap(server, 4, nil, nil, batchedIO, false, true, utils.DefaultLogger).(*packetHandlerMap)
+			connID := protocol.ConnectionID{1, 2, 3, 4}
+			packetHandler := NewMockPacketHandler(mockCtrl)
+			handled := make(chan *receivedPacket, 1)
+			packetHandler.EXPECT().handlePacket(gomock.Any()).Do(func(p *receivedPacket) { handled <- p })
+			handler.Add(connID, packetHandler)
+			_, err := client.WriteTo(append([]byte{0x40}, append(connID, make([]byte, 20)...)...), server.LocalAddr())
+			Expect(err).ToNot(HaveOccurred())
+			var p *receivedPacket
+			Eventually(handled).Should(Receive(&p))
+			Expect(p.ecn).To(Equal(protocol.ECT0))
+			handler.Remove(connID)
+			Expect(handler.Close()).To(Succeed())
+		})
+	}
+
+	It("doesn't change the socket options if ECN is disabled", func() {
+		server := listen("udp4", net.IPv4(127, 0, 0, 1))
+		handler := newPacketHandlerMap(server, 4, nil, nil, false, false, false, utils.DefaultLogger).(*packetHandlerMap)
+		Expect(handler.ecn).To(BeFalse())
+		rawConn, err := server.SyscallConn()
+		Expect(err).ToNot(HaveOccurred())
+		var tos int
+		Expect(rawConn.Control(func(fd uintptr) {
+			tos, err = syscall.GetsockoptInt(int(fd), syscall.IPPROTO_IP, syscall.IP_TOS)
+		})).To(Succeed())
+		Expect(err).ToNot(HaveOccurred())
+		Expect(tos).To(BeZero())
+		Expect(handler.Close()).To(Succeed())
+	})
+
+	It("doesn't enable ECN if the connection is not a UDP connection", func() {
+		Expect(setECN(newMockPacketConn())).To(BeFalse())
+	})
+
+	It("ignores unrelated control messages", func() {
+		Expect(parseECN(appendUDPSegmentSizeMsg(nil, 1000))).To(Equal(protocol.ECNNon))
+		Expect(parseECN(nil)).To(Equal(protocol.ECNNon))
+	})
+})
@@ -0,0 +1,116 @@
+package quic
+
+import (
+	"fmt"
+
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+	"github.com/lucas-clemente/quic-go/internal/qerr"
+	"github.com/lucas-clemente/quic-go/internal/wire"
+)
+
+// The connIDGenerator issues connection IDs to the peer (using NEW_CONNECTION_ID frames),
+// and handles the RETIRE_CONNECTION_ID frames the peer sends for them.
+type connIDGenerator struct {
+	connIDLen  int
+	highestSeq uint64
+
+	activeSrcConnIDs map[uint64]protocol.ConnectionID
+
+	addConnectionID    func(protocol.ConnectionID) [16]byte
+	retireConnectionID func(protocol.ConnectionID)
+	removeConnectionID func(protocol.ConnectionID)
+	queueControlFrame  func(wire.Frame)
+}
+
+func newConnIDGenerator(
+	initialConnectionID protocol.ConnectionID,
+	addConnectionID func(protocol.ConnectionID) [16]byte,
+	retireConnectionID func(protocol.ConnectionID),
+	removeConnectionID func(protocol.ConnectionID),
+	queueControlFrame func(wire.Frame),
+) *connIDGenerator {
+	return &connIDGenerator{
+		connIDLen:          initialConnectionID.Len(),
+		activeSrcConnIDs:   map[uint64]protocol.ConnectionID{0: initialConnectionID},
+		addConnectionID:    addConnectionID,
+		retireConnectionID: retireConnectionID,
+		removeConnectionID: removeConnectionID,
+		queueControlFrame:  queueControlFrame,
+	}
+}
+
+// IssueConnectionIDs issues new connection IDs, until the peer has protocol.MaxActiveConnectionIDs connection IDs.
+// It must only be called after the handshake completed.
+func (m *connIDGenerator) IssueConnectionIDs() error {
+	// A peer that uses a zero-length connection ID can't change it.
+	if m.connIDLen == 0 {
+		return nil
+	}
+	for len(m.activeSrcConnIDs) < protocol.MaxActiveConnectionIDs {
+		if err := m.issueNewConnID(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Retire is called when the peer retires a connection ID, by sending a RETIRE_CONNECTION_ID frame.
+// The retired connection ID is replaced by a new one.
+func (m *connIDGenerator) Retire(seq uint64) error {
+	if seq > m.highestSeq {
+		return qerr.Error(qerr.ProtocolViolation, fmt.Sprintf("tried to retire connection ID %d. Highest issued: %d", seq, m.highestSeq))
+	}
+	connID, ok := m.activeSrcConnIDs[seq]
+	// We might already have deleted this connection ID, if this is a duplicate frame.
+	if !ok {
+		return nil
+	}
+	m.retireConnectionID(connID)
+	delete(m.activeSrcConnIDs, seq)
+	return m.issueNewConnID()
+}
+
+func (m *connIDGenerator) issueNewConnID() error {
+	connID, err := protocol.GenerateConnectionID(m.connIDLen)
+	if err != nil {
+		return err
+	}
+	m.highestSeq++
+	m.activeSrcConnIDs[m.highestSeq] = connID
+	token := m.addConnectionID(connID)
+	m.queueControlFrame(&wire.NewConnectionIDFrame{
+		SequenceNumber:      m.highestSeq,
+		ConnectionID:        connID,
+		StatelessResetToken: token,
+	})
+	return nil
+}
+
+// ConnectionIDs returns all connection IDs that the peer might use.
+func (m *connIDGenerator) ConnectionIDs() []protocol.ConnectionID {
+	connIDs := make([]protocol.ConnectionID, 0, len(m.activeSrcConnIDs))
+	for _, connID := range m.activeSrcConnIDs {
+		connIDs = append(connIDs, connID)
+	}
+	return connIDs
+}
+
+// RetireAll retires all connection IDs issued in NEW_CONNECTION_ID frames.
+// The initial connection ID is not retired, since it is handled by the session when it is closed.
+func (m *connIDGenerator) RetireAll() {
+	for seq, connID := range m.activeSrcConnIDs {
+		if seq != 0 {
+			m.retireConnectionID(connID)
+		}
+	}
+}
+
+// RemoveAll removes all connection IDs issued in NEW_CONNECTION_ID frames.
+// The initial connection ID is not removed, since it is handled by the session when it is closed.
+func (m *connIDGenerator) RemoveAll() {
+	for seq, connID := range m.activeSrcConnIDs {
+		if seq != 0 {
+			m.removeConnectionID(connID)
+		}
+	}
+}
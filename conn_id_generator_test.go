@@ -0,0 +1,102 @@
+package quic
+
+import (
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+	"github.com/lucas-clemente/quic-go/internal/wire"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Connection ID Generator", func() {
+	var (
+		addedConnIDs   []protocol.ConnectionID
+		retiredConnIDs []protocol.ConnectionID
+		removedConnIDs []protocol.ConnectionID
+		queuedFrames   []wire.Frame
+		g              *connIDGenerator
+	)
+	initialConnID := protocol.ConnectionID{1, 2, 3, 4, 5, 6, 7}
+
+	connIDToToken := func(c protocol.ConnectionID) [16]byte {
+		return [16]byte{c[0], c[1], c[2], c[3]}
+	}
+
+	BeforeEach(func() {
+		addedConnIDs = nil
+		retiredConnIDs = nil
+		removedConnIDs = nil
+		queuedFrames = nil
+		g = newConnIDGenerator(
+			initialConnID,
+			func(c protocol.ConnectionID) [16]byte {
+				addedConnIDs = append(addedConnIDs, c)
+				return connIDToToken(c)
+			},
+			func(c protocol.ConnectionID) { retiredConnIDs = append(retiredConnIDs, c) },
+			func(c protocol.ConnectionID) { removedConnIDs = append(removedConnIDs, c) },
+			func(f wire.Frame) { queuedFrames = append(queuedFrames, f) },
+		)
+	})
+
+	It("issues new connection IDs", func() {
+		Expect(g.IssueConnectionIDs()).To(Succeed())
+		Expect(addedConnIDs).To(HaveLen(protocol.MaxActiveConnectionIDs - 1))
+		Expect(queuedFrames).To(HaveLen(protocol.MaxActiveConnectionIDs - 1))
+		for i, f := range queuedFrames {
+			Expect(f).To(BeAssignableToTypeOf(&wire.NewConnectionIDFrame{}))
+			nf := f.(*wire.NewConnectionIDFrame)
+			Expect(nf.SequenceNumber).To(BeEquivalentTo(i + 1))
+			Expect(nf.ConnectionID).To(Equal(addedConnIDs[i]))
+			Expect(nf.ConnectionID.Len()).To(Equal(initialConnID.Len()))
+			Expect(nf.StatelessResetToken).To(Equal(connIDToToken(nf.ConnectionID)))
+		}
+		Expect(g.ConnectionIDs()).To(HaveLen(protocol.MaxActiveConnectionIDs))
+		Expect(g.ConnectionIDs()).To(ContainElement(initialConnID))
+	})
+
+	It("doesn't issue connection IDs when using zero-length connection IDs", func() {
+		g.connIDLen = 0
+		Expect(g.IssueConnectionIDs()).To(Succeed())
+		Expect(addedConnIDs).To(BeEmpty())
+		Expect(queuedFrames).To(BeEmpty())
+	})
+
+	It("errors when the peer retires a connection ID that wasn't issued yet", func() {
+		Expect(g.IssueConnectionIDs()).To(Succeed())
+		Expect(g.Retire(protocol.MaxActiveConnectionIDs)).To(MatchError("PROTOCOL_VIOLATION: tried to retire connection ID 4. Highest issued: 3"))
+	})
+
+	It("retires connection IDs and issues a new one", func() {
+		Expect(g.IssueConnectionIDs()).To(Succeed())
+		queuedFrames = nil
+		Expect(g.Retire(0)).To(Succeed())
+		Expect(retiredConnIDs).To(Equal([]protocol.ConnectionID{initialConnID}))
+		Expect(queuedFrames).To(HaveLen(1))
+		Expect(queuedFrames[0].(*wire.NewConnectionIDFrame).SequenceNumber).To(BeEquivalentTo(protocol.MaxActiveConnectionIDs))
+		Expect(g.ConnectionIDs()).To(HaveLen(protocol.MaxActiveConnectionIDs))
+		Expect(g.ConnectionIDs()).ToNot(ContainElement(initialConnID))
+	})
+
+	It("ignores duplicate retirements", func() {
+		Expect(g.IssueConnectionIDs()).To(Succeed())
+		Expect(g.Retire(2)).To(Succeed())
+		Expect(retiredConnIDs).To(HaveLen(1))
+		Expect(g.Retire(2)).To(Succeed())
+		Expect(retiredConnIDs).To(HaveLen(1))
+	})
+
+	It("retires all connection IDs, except for the initial one", func() {
+		Expect(g.IssueConnectionIDs()).To(Succeed())
+		g.RetireAll()
+		Expect(retiredConnIDs).To(HaveLen(protocol.MaxActiveConnectionIDs - 1))
+		Expect(retiredConnIDs).To(ConsistOf(addedConnIDs))
+	})
+
+	It("removes all connection IDs, except for the initial one", func() {
+		Expect(g.IssueConnectionIDs()).To(Succeed())
+		g.RemoveAll()
+		Expect(removedConnIDs).To(HaveLen(protocol.MaxActiveConnectionIDs - 1))
+		Expect(removedConnIDs).To(ConsistOf(addedConnIDs))
+	})
+})
@@ -0,0 +1,117 @@
+package quic
+
+import (
+	"fmt"
+
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+	"github.com/lucas-clemente/quic-go/internal/qerr"
+	"github.com/lucas-clemente/quic-go/internal/wire"
+)
+
+// The connIDManager stores the connection IDs that the peer issued to us in NEW_CONNECTION_ID frames.
+// It switches to a new connection ID when the session migrates,
+// and after sending protocol.PacketsPerConnectionID packets using the same connection ID.
+type connIDManager struct {
+	queue []*wire.NewConnectionIDFrame // sorted by sequence number
+
+	activeSequenceNumber      uint64
+	activeStatelessResetToken *[16]byte
+	packetsSinceLastChange    uint64
+
+	addStatelessResetToken    func([16]byte)
+	removeStatelessResetToken func([16]byte)
+	changeConnectionID        func(protocol.ConnectionID)
+	queueControlFrame         func(wire.Frame)
+}
+
+func newConnIDManager(
+	addStatelessResetToken func([16]byte),
+	removeStatelessResetToken func([16]byte),
+	changeConnectionID func(protocol.ConnectionID),
+	queueControlFrame func(wire.Frame),
+) *connIDManager {
+	return &connIDManager{
+		addStatelessResetToken:    addStatelessResetToken,
+		removeStatelessResetToken: removeStatelessResetToken,
+		changeConnectionID:        changeConnectionID,
+		queueControlFrame:         queueControlFrame,
+	}
+}
+
+// Add adds a connection ID issued by the peer.
+func (m *connIDManager) Add(f *wire.NewConnectionIDFrame) error {
+	if f.SequenceNumber == m.activeSequenceNumber {
+		return nil
+	}
+	// We already switched to a later connection ID.
+	// Retire this connection ID, so the peer can stop keeping state for it.
+	if f.SequenceNumber < m.activeSequenceNumber {
+		m.queueControlFrame(&wire.RetireConnectionIDFrame{SequenceNumber: f.SequenceNumber})
+		return nil
+	}
+	i := 0
+	for ; i < len(m.queue); i++ {
+		e := m.queue[i]
+		if e.SequenceNumber == f.SequenceNumber {
+			if !e.ConnectionID.Equal(f.ConnectionID) || e.StatelessResetToken != f.StatelessResetToken {
+				return qerr.Error(qerr.ProtocolViolation, fmt.Sprintf("received conflicting connection IDs for sequence number %d", f.SequenceNumber))
+			}
+			// This is a retransmission of a NEW_CONNECTION_ID frame that we already received.
+			return nil
+		}
+		if e.SequenceNumber > f.SequenceNumber {
+			break
+		}
+	}
+	if len(m.queue) >= protocol.MaxPeerConnectionIDs {
+		m.queueControlFrame(&wire.RetireConnectionIDFrame{SequenceNumber: f.SequenceNumber})
+		return nil
+	}
+	m.queue = append(m.queue, nil)
+	copy(m.queue[i+1:], m.queue[i:])
+	m.queue[i] = f
+	return nil
+}
+
+// SetStatelessResetToken sets the stateless reset token for the initial connection ID.
+// The server sends it in the transport parameters.
+func (m *connIDManager) SetStatelessResetToken(token [16]byte) {
+	m.activeStatelessResetToken = &token
+	m.addStatelessResetToken(token)
+}
+
+// SentPacket is called for every packet sent.
+// After sending protocol.PacketsPerConnectionID packets, the connection ID is changed.
+func (m *connIDManager) SentPacket() {
+	m.packetsSinceLastChange++
+	if m.packetsSinceLastChange >= protocol.PacketsPerConnectionID && len(m.queue) > 0 {
+		m.Rotate()
+	}
+}
+
+// Rotate switches to the next connection ID issued by the peer, and retires the current one.
+// It returns false if the peer didn't issue any unused connection IDs.
+func (m *connIDManager) Rotate() bool {
+	if len(m.queue) == 0 {
+		return false
+	}
+	m.queueControlFrame(&wire.RetireConnectionIDFrame{SequenceNumber: m.activeSequenceNumber})
+	if m.activeStatelessResetToken != nil {
+		m.removeStatelessResetToken(*m.activeStatelessResetToken)
+	}
+	f := m.queue[0]
+	m.queue = m.queue[1:]
+	m.activeSequenceNumber = f.SequenceNumber
+	m.activeStatelessResetToken = &f.StatelessResetToken
+	m.packetsSinceLastChange = 0
+	m.addStatelessResetToken(f.StatelessResetToken)
+	m.changeConnectionID(f.ConnectionID)
+	return true
+}
+
+// Close removes the stateless reset token of the active connection ID.
+func (m *connIDManager) Close() {
+	if m.activeStatelessResetToken != nil {
+		m.removeStatelessResetToken(*m.activeStatelessResetToken)
+	}
+}
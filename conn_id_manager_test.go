@@ -0,0 +1,118 @@
+package quic
+
+import (
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+	"github.com/lucas-clemente/quic-go/internal/wire"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Connection ID Manager", func() {
+	var (
+		m             *connIDManager
+		addedTokens   [][16]byte
+		removedTokens [][16]byte
+		connIDs       []protocol.ConnectionID
+		queuedFrames  []wire.Frame
+	)
+
+	BeforeEach(func() {
+		addedTokens = nil
+		removedTokens = nil
+		connIDs = nil
+		queuedFrames = nil
+		m = newConnIDManager(
+			func(token [16]byte) { addedTokens = append(addedTokens, token) },
+			func(token [16]byte) { removedTokens = append(removedTokens, token) },
+			func(c protocol.ConnectionID) { connIDs = append(connIDs, c) },
+			func(f wire.Frame) { queuedFrames = append(queuedFrames, f) },
+		)
+	})
+
+	It("doesn't rotate if the peer didn't issue any connection IDs", func() {
+		Expect(m.Rotate()).To(BeFalse())
+		Expect(connIDs).To(BeEmpty())
+		Expect(queuedFrames).To(BeEmpty())
+	})
+
+	It("stores connection IDs, sorted by sequence number", func() {
+		f3 := &wire.NewConnectionIDFrame{SequenceNumber: 3, ConnectionID: protocol.ConnectionID{3, 3, 3, 3}}
+		f1 := &wire.NewConnectionIDFrame{SequenceNumber: 1, ConnectionID: protocol.ConnectionID{1, 1, 1, 1}}
+		f2 := &wire.NewConnectionIDFrame{SequenceNumber: 2, ConnectionID: protocol.ConnectionID{2, 2, 2, 2}}
+		Expect(m.Add(f3)).To(Succeed())
+		Expect(m.Add(f1)).To(Succeed())
+		Expect(m.Add(f2)).To(Succeed())
+		Expect(m.queue).To(Equal([]*wire.NewConnectionIDFrame{f1, f2, f3}))
+	})
+
+	It("ignores retransmissions of NEW_CONNECTION_ID frames", func() {
+		f := &wire.NewConnectionIDFrame{SequenceNumber: 1, ConnectionID: protocol.ConnectionID{1, 2, 3, 4}}
+		Expect(m.Add(f)).To(Succeed())
+		Expect(m.Add(&wire.NewConnectionIDFrame{SequenceNumber: 1, ConnectionID: protocol.ConnectionID{1, 2, 3, 4}})).To(Succeed())
+		Expect(m.queue).To(HaveLen(1))
+	})
+
+	It("errors when receiving conflicting connection IDs for the same sequence number", func() {
+		Expect(m.Add(&wire.NewConnectionIDFrame{SequenceNumber: 1, ConnectionID: protocol.ConnectionID{1, 2, 3, 4}})).To(Succeed())
+		err := m.Add(&wire.NewConnectionIDFrame{SequenceNumber: 1, ConnectionID: protocol.ConnectionID{4, 3, 2, 1}})
+		Expect(err).To(MatchError("PROTOCOL_VIOLATION: received conflicting connection IDs for sequence number 1"))
+	})
+
+	It("retires connection IDs that exceed the limit", func() {
+		for i := 1; i <= protocol.MaxPeerConnectionIDs+1; i++ {
+			Expect(m.Add(&wire.NewConnectionIDFrame{SequenceNumber: uint64(i), ConnectionID: protocol.ConnectionID{1, 2, 3, byte(i)}})).To(Succeed())
+		}
+		Expect(m.queue).To(HaveLen(protocol.MaxPeerConnectionIDs))
+		Expect(queuedFrames).To(Equal([]wire.Frame{&wire.RetireConnectionIDFrame{SequenceNumber: protocol.MaxPeerConnectionIDs + 1}}))
+	})
+
+	It("rotates connection IDs", func() {
+		token0 := [16]byte{0xde, 0xad, 0xbe, 0xef}
+		m.SetStatelessResetToken(token0)
+		Expect(addedTokens).To(Equal([][16]byte{token0}))
+		f1 := &wire.NewConnectionIDFrame{SequenceNumber: 1, ConnectionID: protocol.ConnectionID{1, 1, 1, 1}, StatelessResetToken: [16]byte{1}}
+		f2 := &wire.NewConnectionIDFrame{SequenceNumber: 2, ConnectionID: protocol.ConnectionID{2, 2, 2, 2}, StatelessResetToken: [16]byte{2}}
+		Expect(m.Add(f1)).To(Succeed())
+		Expect(m.Add(f2)).To(Succeed())
+		Expect(m.Rotate()).To(BeTrue())
+		Expect(connIDs).To(Equal([]protocol.ConnectionID{f1.ConnectionID}))
+		Expect(queuedFrames).To(Equal([]wire.Frame{&wire.RetireConnectionIDFrame{SequenceNumber: 0}}))
+		Expect(removedTokens).To(Equal([][16]byte{token0}))
+		Expect(addedTokens).To(Equal([][16]byte{token0, f1.StatelessResetToken}))
+		Expect(m.Rotate()).To(BeTrue())
+		Expect(connIDs).To(Equal([]protocol.ConnectionID{f1.ConnectionID, f2.ConnectionID}))
+		Expect(queuedFrames).To(Equal([]wire.Frame{
+			&wire.RetireConnectionIDFrame{SequenceNumber: 0},
+			&wire.RetireConnectionIDFrame{SequenceNumber: 1},
+		}))
+		Expect(removedTokens).To(Equal([][16]byte{token0, f1.StatelessResetToken}))
+		Expect(m.Rotate()).To(BeFalse())
+	})
+
+	It("retires connection IDs that are received after rotating to a later one", func() {
+		Expect(m.Add(&wire.NewConnectionIDFrame{SequenceNumber: 2, ConnectionID: protocol.ConnectionID{2, 2, 2, 2}})).To(Succeed())
+		Expect(m.Rotate()).To(BeTrue())
+		queuedFrames = nil
+		Expect(m.Add(&wire.NewConnectionIDFrame{SequenceNumber: 1, ConnectionID: protocol.ConnectionID{1, 1, 1, 1}})).To(Succeed())
+		Expect(m.queue).To(BeEmpty())
+		Expect(queuedFrames).To(Equal([]wire.Frame{&wire.RetireConnectionIDFrame{SequenceNumber: 1}}))
+	})
+
+	It("rotates the connection ID after sending a number of packets", func() {
+		Expect(m.Add(&wire.NewConnectionIDFrame{SequenceNumber: 1, ConnectionID: protocol.ConnectionID{1, 1, 1, 1}})).To(Succeed())
+		for i := 0; i < protocol.PacketsPerConnectionID-1; i++ {
+			m.SentPacket()
+		}
+		Expect(connIDs).To(BeEmpty())
+		m.SentPacket()
+		Expect(connIDs).To(Equal([]protocol.ConnectionID{{1, 1, 1, 1}}))
+	})
+
+	It("removes the stateless reset token when closing", func() {
+		token := [16]byte{0xde, 0xca, 0xfb, 0xad}
+		m.SetStatelessResetToken(token)
+		m.Close()
+		Expect(removedTokens).To(Equal([][16]byte{token}))
+	})
+})
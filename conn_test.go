@@ -3,6 +3,7 @@ package quic
 import (
 	"errors"
 	"net"
+	"sync"
 	"time"
 
 	. "github.com/onsi/ginkgo"
@@ -21,12 +22,16 @@ type mockPacketConn struct {
 	readErr      error
 	dataWritten  chan mockPacketConnWrite
 	closed       bool
+
+	deadlineMutex    sync.Mutex
+	deadlineExceeded chan struct{} // closed while the read deadline is in the past
 }
 
 func newMockPacketConn() *mockPacketConn {
 	return &mockPacketConn{
-		dataToRead:  make(chan []byte, 1000),
-		dataWritten: make(chan mockPacketConnWrite, 1000),
+		dataToRead:       make(chan []byte, 1000),
+		dataWritten:      make(chan mockPacketConnWrite, 1000),
+		deadlineExceeded: make(chan struct{}),
 	}
 }
 
@@ -34,12 +39,19 @@ func (c *mockPacketConn) ReadFrom(b []byte) (int, net.Addr, error) {
 	if c.readErr != nil {
 		return 0, nil, c.readErr
 	}
-	data, ok := <-c.dataToRead
-	if !ok {
-		return 0, nil, errors.New("connection closed")
+	c.deadlineMutex.Lock()
+	deadlineExceeded := c.deadlineExceeded
+	c.deadlineMutex.Unlock()
+	select {
+	case data, ok := <-c.dataToRead:
+		if !ok {
+			return 0, nil, errors.New("connection closed")
+		}
+		n := copy(b, data)
+		return n, c.dataReadFrom, nil
+	case <-deadlineExceeded:
+		return 0, nil, errors.New("deadline exceeded")
 	}
-	n := copy(b, data)
-	return n, c.dataReadFrom, nil
 }
 
 func (c *mockPacketConn) WriteTo(b []byte, addr net.Addr) (n int, err error) {
@@ -60,9 +72,24 @@ func (c *mockPacketConn) Close() error {
 }
 func (c *mockPacketConn) LocalAddr() net.Addr                { return c.addr }
 func (c *mockPacketConn) SetDeadline(t time.Time) error      { panic("not implemented") }
-func (c *mockPacketConn) SetReadDeadline(t time.Time) error  { panic("not implemented") }
 func (c *mockPacketConn) SetWriteDeadline(t time.Time) error { panic("not implemented") }
 
+// SetReadDeadline only supports deadlines in the past, which make ReadFrom return immediately.
+// Any other deadline removes the deadline.
+func (c *mockPacketConn) SetReadDeadline(t time.Time) error {
+	c.deadlineMutex.Lock()
+	defer c.deadlineMutex.Unlock()
+	select {
+	case <-c.deadlineExceeded:
+		c.deadlineExceeded = make(chan struct{})
+	default:
+	}
+	if !t.IsZero() && !t.After(time.Now()) {
+		close(c.deadlineExceeded)
+	}
+	return nil
+}
+
 var _ net.PacketConn = &mockPacketConn{}
 
 var _ = Describe("Connection", func() {
@@ -89,6 +116,51 @@ var _ = Describe("Connection", func() {
 		Expect(write.data).To(Equal([]byte("foobar")))
 	})
 
+	It("writes to a different address", func() {
+		addr := &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 7331}
+		Expect(c.WriteTo([]byte("foobar"), addr)).To(Succeed())
+		var write mockPacketConnWrite
+		Expect(packetConn.dataWritten).To(Receive(&write))
+		Expect(write.to).To(Equal(addr))
+		Expect(write.data).To(Equal([]byte("foobar")))
+		Expect(c.RemoteAddr().String()).To(Equal("192.168.100.200:1337"))
+	})
+
+	It("writes batches packet by packet, if batched I/O is not used", func() {
+		Expect(c.WriteBatch([][]byte{[]byte("foo"), []byte("bar")})).To(Succeed())
+		var write mockPacketConnWrite
+		Expect(packetConn.dataWritten).To(Receive(&write))
+		Expect(write.to.String()).To(Equal("192.168.100.200:1337"))
+		Expect(write.data).To(Equal([]byte("foo")))
+		Expect(packetConn.dataWritten).To(Receive(&write))
+		Expect(write.data).To(Equal([]byte("bar")))
+		Expect(packetConn.dataWritten).ToNot(Receive())
+	})
+
+	It("doesn't use batched I/O if the connection is not a UDP connection", func() {
+		Expect(getBatchWriter(packetConn, true)).To(BeNil())
+	})
+
+	It("doesn't use batched I/O if it's disabled", func() {
+		udpConn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+		Expect(err).ToNot(HaveOccurred())
+		defer udpConn.Close()
+		Expect(getBatchWriter(udpConn, false)).To(BeNil())
+	})
+
+	It("doesn't set the DF bit if the connection is not a UDP connection", func() {
+		Expect(c.SetDF()).To(BeFalse())
+	})
+
+	It("doesn't enable ECN if the connection is not a UDP connection", func() {
+		Expect(c.SetECN()).To(BeFalse())
+		c.SetECNMarking(false)
+		Expect(c.Write([]byte("foobar"))).To(Succeed())
+		var write mockPacketConnWrite
+		Expect(packetConn.dataWritten).To(Receive(&write))
+		Expect(write.data).To(Equal([]byte("foobar")))
+	})
+
 	It("reads", func() {
 		packetConn.dataToRead <- []byte("foo")
 		packetConn.dataReadFrom = &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 1336}
@@ -0,0 +1,130 @@
+package quic
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+	"github.com/lucas-clemente/quic-go/internal/wire"
+)
+
+type datagram struct {
+	frame       *wire.DatagramFrame
+	ackCallback func(acked bool)
+}
+
+type datagramQueue struct {
+	sendQueue chan *datagram
+	nextFrame *datagram // only accessed from the packer
+	rcvQueue  chan []byte
+
+	mutex          sync.Mutex
+	peerMaxSize    protocol.ByteCount // the max_datagram_frame_size of the peer. 0 if the peer doesn't support DATAGRAM frames.
+	receivedParams bool
+
+	closeErr error
+	closed   chan struct{}
+
+	hasData func()
+	version protocol.VersionNumber
+}
+
+func newDatagramQueue(hasData func(), v protocol.VersionNumber) *datagramQueue {
+	return &datagramQueue{
+		sendQueue: make(chan *datagram, protocol.DatagramSendQueueLen),
+		rcvQueue:  make(chan []byte, protocol.DatagramRcvQueueLen),
+		closed:    make(chan struct{}),
+		hasData:   hasData,
+		version:   v,
+	}
+}
+
+// SetPeerMaxFrameSize sets the maximum size of DATAGRAM frames the peer accepts.
+// It is called when the transport parameters of the peer are received.
+func (h *datagramQueue) SetPeerMaxFrameSize(size protocol.ByteCount) {
+	h.mutex.Lock()
+	h.peerMaxSize = size
+	h.receivedParams = true
+	h.mutex.Unlock()
+}
+
+// AddAndWait queues a new DATAGRAM frame for sending.
+// If the send queue is full, it blocks until a frame was dequeued, or the queue was closed.
+func (h *datagramQueue) AddAndWait(data []byte, ackCallback func(acked bool)) error {
+	h.mutex.Lock()
+	receivedParams := h.receivedParams
+	maxSize := h.peerMaxSize
+	h.mutex.Unlock()
+	if !receivedParams {
+		return errors.New("can't send datagrams before the transport parameters of the peer were received")
+	}
+	if maxSize == 0 {
+		return errors.New("peer doesn't support receiving datagrams")
+	}
+	f := &wire.DatagramFrame{
+		DataLenPresent: true,
+		Data:           make([]byte, len(data)),
+	}
+	copy(f.Data, data)
+	if l := f.Length(h.version); l > maxSize || l > protocol.MaxDatagramFrameSize {
+		return errors.New("message too large")
+	}
+
+	select {
+	case h.sendQueue <- &datagram{frame: f, ackCallback: ackCallback}:
+		h.hasData()
+		return nil
+	case <-h.closed:
+		return h.closeErr
+	}
+}
+
+// Peek gets the next DATAGRAM frame for sending, without removing it from the queue.
+// If there are no frames queued, it returns nil.
+func (h *datagramQueue) Peek() *wire.DatagramFrame {
+	if h.nextFrame == nil {
+		select {
+		case h.nextFrame = <-h.sendQueue:
+		default:
+			return nil
+		}
+	}
+	return h.nextFrame.frame
+}
+
+// Pop removes the frame returned by Peek from the queue.
+// It returns the callback that is to be called when the frame is acknowledged or declared lost.
+func (h *datagramQueue) Pop() func(acked bool) {
+	if h.nextFrame == nil {
+		return nil
+	}
+	cb := h.nextFrame.ackCallback
+	h.nextFrame = nil
+	return cb
+}
+
+// HandleDatagramFrame handles a received DATAGRAM frame.
+// If the receive queue is full, the frame is dropped.
+func (h *datagramQueue) HandleDatagramFrame(f *wire.DatagramFrame) {
+	select {
+	case h.rcvQueue <- f.Data:
+	default:
+	}
+}
+
+// Receive blocks until a DATAGRAM frame was received, or the queue was closed.
+func (h *datagramQueue) Receive() ([]byte, error) {
+	select {
+	case data := <-h.rcvQueue:
+		return data, nil
+	case <-h.closed:
+		return nil, h.closeErr
+	}
+}
+
+// CloseWithError closes the queue.
+// Calls to AddAndWait and Receive return the error.
+func (h *datagramQueue) CloseWithError(e error) {
+	h.closeErr = e
+	close(h.closed)
+}
@@ -0,0 +1,151 @@
+package quic
+
+import (
+	"errors"
+
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+	"github.com/lucas-clemente/quic-go/internal/wire"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Datagram Queue", func() {
+	var queue *datagramQueue
+	var queued chan struct{}
+
+	BeforeEach(func() {
+		queued = make(chan struct{}, 100)
+		queue = newDatagramQueue(func() { queued <- struct{}{} }, protocol.VersionWhatever)
+	})
+
+	Context("sending", func() {
+		It("errors before the transport parameters were received", func() {
+			Expect(queue.AddAndWait([]byte("foobar"), nil)).To(MatchError("can't send datagrams before the transport parameters of the peer were received"))
+		})
+
+		It("errors if the peer doesn't support datagrams", func() {
+			queue.SetPeerMaxFrameSize(0)
+			Expect(queue.AddAndWait([]byte("foobar"), nil)).To(MatchError("peer doesn't support receiving datagrams"))
+		})
+
+		It("errors if the message is larger than the peer's limit", func() {
+			queue.SetPeerMaxFrameSize(100)
+			f := &wire.DatagramFrame{DataLenPresent: true, Data: make([]byte, 100)}
+			maxLen := f.MaxDataLen(100, protocol.VersionWhatever)
+			Expect(queue.AddAndWait(make([]byte, maxLen+1), nil)).To(MatchError("message too large"))
+			Expect(queue.AddAndWait(make([]byte, maxLen), nil)).To(Succeed())
+		})
+
+		It("errors if the message is larger than the maximum DATAGRAM frame size", func() {
+			queue.SetPeerMaxFrameSize(10 * protocol.MaxDatagramFrameSize)
+			Expect(queue.AddAndWait(make([]byte, protocol.MaxDatagramFrameSize), nil)).To(MatchError("message too large"))
+		})
+
+		It("queues a datagram", func() {
+			queue.SetPeerMaxFrameSize(protocol.MaxDatagramFrameSize)
+			Expect(queue.Peek()).To(BeNil())
+			var acked []bool
+			Expect(queue.AddAndWait([]byte("foobar"), func(a bool) { acked = append(acked, a) })).To(Succeed())
+			Eventually(queued).Should(Receive())
+			f := queue.Peek()
+			Expect(f).ToNot(BeNil())
+			Expect(f.Data).To(Equal([]byte("foobar")))
+			Expect(f.DataLenPresent).To(BeTrue())
+			// peeking again returns the same frame
+			Expect(queue.Peek()).To(Equal(f))
+			cb := queue.Pop()
+			Expect(cb).ToNot(BeNil())
+			cb(false)
+			Expect(acked).To(Equal([]bool{false}))
+			Expect(queue.Peek()).To(BeNil())
+			Expect(queue.Pop()).To(BeNil())
+		})
+
+		It("copies the data", func() {
+			queue.SetPeerMaxFrameSize(protocol.MaxDatagramFrameSize)
+			data := []byte("foobar")
+			Expect(queue.AddAndWait(data, nil)).To(Succeed())
+			data[0] = 'x'
+			Expect(queue.Peek().Data).To(Equal([]byte("foobar")))
+		})
+
+		It("blocks when the send queue is full", func() {
+			queue.SetPeerMaxFrameSize(protocol.MaxDatagramFrameSize)
+			for i := 0; i < protocol.DatagramSendQueueLen; i++ {
+				Expect(queue.AddAndWait([]byte{byte(i)}, nil)).To(Succeed())
+			}
+			done := make(chan struct{})
+			go func() {
+				defer GinkgoRecover()
+				defer close(done)
+				Expect(queue.AddAndWait([]byte("foobar"), nil)).To(Succeed())
+			}()
+			Consistently(done).ShouldNot(BeClosed())
+			Expect(queue.Peek().Data).To(Equal([]byte{0}))
+			queue.Pop()
+			Eventually(done).Should(BeClosed())
+		})
+
+		It("returns the error when the queue is closed while blocked", func() {
+			queue.SetPeerMaxFrameSize(protocol.MaxDatagramFrameSize)
+			for i := 0; i < protocol.DatagramSendQueueLen; i++ {
+				Expect(queue.AddAndWait([]byte{byte(i)}, nil)).To(Succeed())
+			}
+			errChan := make(chan error, 1)
+			go func() { errChan <- queue.AddAndWait([]byte("foobar"), nil) }()
+			Consistently(errChan).ShouldNot(Receive())
+			testErr := errors.New("test error")
+			queue.CloseWithError(testErr)
+			Eventually(errChan).Should(Receive(MatchError(testErr)))
+		})
+	})
+
+	Context("receiving", func() {
+		It("receives DATAGRAM frames", func() {
+			queue.HandleDatagramFrame(&wire.DatagramFrame{Data: []byte("foo")})
+			queue.HandleDatagramFrame(&wire.DatagramFrame{Data: []byte("bar")})
+			data, err := queue.Receive()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(data).To(Equal([]byte("foo")))
+			data, err = queue.Receive()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(data).To(Equal([]byte("bar")))
+		})
+
+		It("blocks until a frame is received", func() {
+			dataChan := make(chan []byte, 1)
+			go func() {
+				defer GinkgoRecover()
+				data, err := queue.Receive()
+				Expect(err).ToNot(HaveOccurred())
+				dataChan <- data
+			}()
+			Consistently(dataChan).ShouldNot(Receive())
+			queue.HandleDatagramFrame(&wire.DatagramFrame{Data: []byte("foobar")})
+			Eventually(dataChan).Should(Receive(Equal([]byte("foobar"))))
+		})
+
+		It("drops DATAGRAM frames when the receive queue is full", func() {
+			for i := 0; i < protocol.DatagramRcvQueueLen+10; i++ {
+				queue.HandleDatagramFrame(&wire.DatagramFrame{Data: []byte{byte(i)}})
+			}
+			Expect(queue.rcvQueue).To(HaveLen(protocol.DatagramRcvQueueLen))
+			data, err := queue.Receive()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(data).To(Equal([]byte{0}))
+		})
+
+		It("returns the error when the queue is closed", func() {
+			errChan := make(chan error, 1)
+			go func() {
+				_, err := queue.Receive()
+				errChan <- err
+			}()
+			Consistently(errChan).ShouldNot(Receive())
+			testErr := errors.New("test error")
+			queue.CloseWithError(testErr)
+			Eventually(errChan).Should(Receive(MatchError(testErr)))
+		})
+	})
+})
@@ -12,6 +12,8 @@ type framer interface {
 	AppendControlFrames([]wire.Frame, protocol.ByteCount) ([]wire.Frame, protocol.ByteCount)
 
 	AddActiveStream(protocol.StreamID)
+	SetStreamPriority(protocol.StreamID, StreamPriority)
+	RemoveStream(protocol.StreamID)
 	AppendStreamFrames([]wire.Frame, protocol.ByteCount) []wire.Frame
 }
 
@@ -22,7 +24,15 @@ type framerI struct {
 	version      protocol.VersionNumber
 
 	activeStreams map[protocol.StreamID]struct{}
-	streamQueue   []protocol.StreamID
+	// The streamQueue is ordered by urgency.
+	// Streams of the same urgency are served in the order they're queued.
+	streamQueue []protocol.StreamID
+
+	// The priorities are protected by a separate mutex,
+	// since streams are removed from within AppendStreamFrames when they complete.
+	priorityMutex sync.Mutex
+	// priorities only contains streams that don't use the default priority
+	priorities map[protocol.StreamID]StreamPriority
 
 	controlFrameMutex sync.Mutex
 	controlFrames     []wire.Frame
@@ -30,6 +40,8 @@ type framerI struct {
 
 var _ framer = &framerI{}
 
+var defaultStreamPriority = StreamPriority{Urgency: 3, Incremental: true}
+
 func newFramer(
 	streamGetter streamGetter,
 	v protocol.VersionNumber,
@@ -37,6 +49,7 @@ func newFramer(
 	return &framerI{
 		streamGetter:  streamGetter,
 		activeStreams: make(map[protocol.StreamID]struct{}),
+		priorities:    make(map[protocol.StreamID]StreamPriority),
 		version:       v,
 	}
 }
@@ -67,15 +80,80 @@ func (f *framerI) AppendControlFrames(frames []wire.Frame, maxLen protocol.ByteC
 func (f *framerI) AddActiveStream(id protocol.StreamID) {
 	f.mutex.Lock()
 	if _, ok := f.activeStreams[id]; !ok {
-		f.streamQueue = append(f.streamQueue, id)
+		f.queueStream(id, false)
 		f.activeStreams[id] = struct{}{}
 	}
 	f.mutex.Unlock()
 }
 
+func (f *framerI) SetStreamPriority(id protocol.StreamID, p StreamPriority) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.priorityMutex.Lock()
+	// The priority of a stream that already completed would never be removed.
+	if str, err := f.streamGetter.GetOrOpenSendStream(id); str == nil || err != nil {
+		f.priorityMutex.Unlock()
+		return
+	}
+	if p == defaultStreamPriority {
+		delete(f.priorities, id)
+	} else {
+		f.priorities[id] = p
+	}
+	f.priorityMutex.Unlock()
+	if _, ok := f.activeStreams[id]; !ok {
+		return
+	}
+	// move the stream to the right position in the queue
+	for i, sid := range f.streamQueue {
+		if sid == id {
+			f.streamQueue = append(f.streamQueue[:i], f.streamQueue[i+1:]...)
+			break
+		}
+	}
+	f.queueStream(id, false)
+}
+
+func (f *framerI) RemoveStream(id protocol.StreamID) {
+	f.priorityMutex.Lock()
+	delete(f.priorities, id)
+	f.priorityMutex.Unlock()
+}
+
+func (f *framerI) getPriority(id protocol.StreamID) StreamPriority {
+	f.priorityMutex.Lock()
+	defer f.priorityMutex.Unlock()
+	if p, ok := f.priorities[id]; ok {
+		return p
+	}
+	return defaultStreamPriority
+}
+
+// queueStream inserts a stream into the streamQueue.
+// If front is set, it is inserted in front of all streams of the same urgency,
+// otherwise it is inserted behind them.
+func (f *framerI) queueStream(id protocol.StreamID, front bool) {
+	urgency := f.getPriority(id).Urgency
+	pos := len(f.streamQueue)
+	for i, sid := range f.streamQueue {
+		u := f.getPriority(sid).Urgency
+		if u > urgency || (front && u == urgency) {
+			pos = i
+			break
+		}
+	}
+	f.streamQueue = append(f.streamQueue, 0)
+	copy(f.streamQueue[pos+1:], f.streamQueue[pos:])
+	f.streamQueue[pos] = id
+}
+
 func (f *framerI) AppendStreamFrames(frames []wire.Frame, maxLen protocol.ByteCount) []wire.Frame {
 	var length protocol.ByteCount
 	f.mutex.Lock()
+	// Streams that still have data after being popped are only re-queued once all streams were considered,
+	// so that every stream is asked for data at most once per packet.
+	var requeue []protocol.StreamID
 	// pop STREAM frames, until less than MinStreamFrameSize bytes are left in the packet
 	numActiveStreams := len(f.streamQueue)
 	for i := 0; i < numActiveStreams; i++ {
@@ -93,8 +171,8 @@ func (f *framerI) AppendStreamFrames(frames []wire.Frame, maxLen protocol.ByteCo
 			continue
 		}
 		frame, hasMoreData := str.popStreamFrame(maxLen - length)
-		if hasMoreData { // put the stream back in the queue (at the end)
-			f.streamQueue = append(f.streamQueue, id)
+		if hasMoreData {
+			requeue = append(requeue, id)
 		} else { // no more data to send. Stream is not active any more
 			delete(f.activeStreams, id)
 		}
@@ -104,6 +182,19 @@ func (f *framerI) AppendStreamFrames(frames []wire.Frame, maxLen protocol.ByteCo
 		frames = append(frames, frame)
 		length += frame.Length(f.version)
 	}
+	// Incremental streams are put back at the end of their urgency level.
+	// Non-incremental streams stay at the front, such that they're sent to completion before the next stream.
+	// Iterate backwards, so that non-incremental streams of the same urgency keep their relative order.
+	for i := len(requeue) - 1; i >= 0; i-- {
+		if id := requeue[i]; !f.getPriority(id).Incremental {
+			f.queueStream(id, true)
+		}
+	}
+	for _, id := range requeue {
+		if f.getPriority(id).Incremental {
+			f.queueStream(id, false)
+		}
+	}
 	f.mutex.Unlock()
 	return frames
 }
@@ -226,4 +226,107 @@ var _ = Describe("Stream Framer", func() {
 			Expect(fs).To(Equal([]wire.Frame{f}))
 		})
 	})
+
+	Context("stream priorities", func() {
+		It("sends data on streams with a lower urgency first", func() {
+			streamGetter.EXPECT().GetOrOpenSendStream(id1).Return(stream1, nil)
+			streamGetter.EXPECT().GetOrOpenSendStream(id2).Return(stream2, nil).Times(2)
+			f1 := &wire.StreamFrame{StreamID: id1, Data: []byte("foobar")}
+			f2 := &wire.StreamFrame{StreamID: id2, Data: []byte("raboof")}
+			gomock.InOrder(
+				stream2.EXPECT().popStreamFrame(gomock.Any()).Return(f2, false),
+				stream1.EXPECT().popStreamFrame(gomock.Any()).Return(f1, false),
+			)
+			framer.SetStreamPriority(id2, StreamPriority{Urgency: 1, Incremental: true})
+			framer.AddActiveStream(id1)
+			framer.AddActiveStream(id2)
+			Expect(framer.AppendStreamFrames(nil, 1000)).To(Equal([]wire.Frame{f2, f1}))
+		})
+
+		It("doesn't starve urgent streams when a bulk stream has a lot of data", func() {
+			streamGetter.EXPECT().GetOrOpenSendStream(id1).Return(stream1, nil).Times(4)
+			streamGetter.EXPECT().GetOrOpenSendStream(id2).Return(stream2, nil).Times(2)
+			bulk := &wire.StreamFrame{StreamID: id1, Data: []byte("bulk")}
+			f21 := &wire.StreamFrame{StreamID: id2, Data: []byte("foo")}
+			f22 := &wire.StreamFrame{StreamID: id2, Data: []byte("bar")}
+			stream1.EXPECT().popStreamFrame(gomock.Any()).Return(bulk, true).Times(3)
+			stream2.EXPECT().popStreamFrame(gomock.Any()).Return(f21, true)
+			stream2.EXPECT().popStreamFrame(gomock.Any()).Return(f22, false)
+			framer.SetStreamPriority(id1, StreamPriority{Urgency: 7, Incremental: true})
+			framer.AddActiveStream(id1)
+			Expect(framer.AppendStreamFrames(nil, protocol.MinStreamFrameSize)).To(Equal([]wire.Frame{bulk}))
+			// the urgent stream becomes active after the bulk stream
+			framer.AddActiveStream(id2)
+			Expect(framer.AppendStreamFrames(nil, protocol.MinStreamFrameSize)).To(Equal([]wire.Frame{f21}))
+			Expect(framer.AppendStreamFrames(nil, protocol.MinStreamFrameSize)).To(Equal([]wire.Frame{f22}))
+			Expect(framer.AppendStreamFrames(nil, protocol.MinStreamFrameSize)).To(Equal([]wire.Frame{bulk}))
+			Expect(framer.AppendStreamFrames(nil, protocol.MinStreamFrameSize)).To(Equal([]wire.Frame{bulk}))
+		})
+
+		It("sends non-incremental streams one after the other", func() {
+			streamGetter.EXPECT().GetOrOpenSendStream(id1).Return(stream1, nil).Times(3)
+			streamGetter.EXPECT().GetOrOpenSendStream(id2).Return(stream2, nil).Times(3)
+			f11 := &wire.StreamFrame{StreamID: id1, Data: []byte("foobar")}
+			f12 := &wire.StreamFrame{StreamID: id1, Data: []byte("foobaz")}
+			f21 := &wire.StreamFrame{StreamID: id2, Data: []byte("raboof")}
+			f22 := &wire.StreamFrame{StreamID: id2, Data: []byte("zaboof")}
+			stream1.EXPECT().popStreamFrame(gomock.Any()).Return(f11, true)
+			stream1.EXPECT().popStreamFrame(gomock.Any()).Return(f12, false)
+			stream2.EXPECT().popStreamFrame(gomock.Any()).Return(f21, true)
+			stream2.EXPECT().popStreamFrame(gomock.Any()).Return(f22, false)
+			framer.SetStreamPriority(id1, StreamPriority{Urgency: 3})
+			framer.SetStreamPriority(id2, StreamPriority{Urgency: 3})
+			framer.AddActiveStream(id1)
+			framer.AddActiveStream(id2)
+			Expect(framer.AppendStreamFrames(nil, protocol.MinStreamFrameSize)).To(Equal([]wire.Frame{f11}))
+			Expect(framer.AppendStreamFrames(nil, protocol.MinStreamFrameSize)).To(Equal([]wire.Frame{f12}))
+			Expect(framer.AppendStreamFrames(nil, protocol.MinStreamFrameSize)).To(Equal([]wire.Frame{f21}))
+			Expect(framer.AppendStreamFrames(nil, protocol.MinStreamFrameSize)).To(Equal([]wire.Frame{f22}))
+		})
+
+		It("re-orders an active stream when its priority changes", func() {
+			streamGetter.EXPECT().GetOrOpenSendStream(id1).Return(stream1, nil)
+			streamGetter.EXPECT().GetOrOpenSendStream(id2).Return(stream2, nil).Times(2)
+			f1 := &wire.StreamFrame{StreamID: id1, Data: []byte("foobar")}
+			f2 := &wire.StreamFrame{StreamID: id2, Data: []byte("raboof")}
+			stream1.EXPECT().popStreamFrame(gomock.Any()).Return(f1, false)
+			stream2.EXPECT().popStreamFrame(gomock.Any()).Return(f2, false)
+			framer.AddActiveStream(id1)
+			framer.AddActiveStream(id2)
+			framer.SetStreamPriority(id2, StreamPriority{Urgency: 0})
+			Expect(framer.AppendStreamFrames(nil, 1000)).To(Equal([]wire.Frame{f2, f1}))
+		})
+
+		It("forgets the priority of a stream when it is removed", func() {
+			streamGetter.EXPECT().GetOrOpenSendStream(id1).Return(stream1, nil).Times(2)
+			streamGetter.EXPECT().GetOrOpenSendStream(id2).Return(stream2, nil)
+			f1 := &wire.StreamFrame{StreamID: id1, Data: []byte("foobar")}
+			f2 := &wire.StreamFrame{StreamID: id2, Data: []byte("raboof")}
+			stream1.EXPECT().popStreamFrame(gomock.Any()).Return(f1, false)
+			stream2.EXPECT().popStreamFrame(gomock.Any()).Return(f2, false)
+			framer.SetStreamPriority(id1, StreamPriority{Urgency: 7})
+			framer.RemoveStream(id1)
+			framer.AddActiveStream(id1)
+			framer.AddActiveStream(id2)
+			Expect(framer.AppendStreamFrames(nil, 1000)).To(Equal([]wire.Frame{f1, f2}))
+		})
+
+		It("removes a stream that completes while its frame is popped", func() {
+			streamGetter.EXPECT().GetOrOpenSendStream(id1).Return(stream1, nil).Times(2)
+			f := &wire.StreamFrame{StreamID: id1, Data: []byte("foobar"), FinBit: true}
+			stream1.EXPECT().popStreamFrame(gomock.Any()).DoAndReturn(func(protocol.ByteCount) (*wire.StreamFrame, bool) {
+				framer.RemoveStream(id1)
+				return f, false
+			})
+			framer.SetStreamPriority(id1, StreamPriority{Urgency: 7})
+			framer.AddActiveStream(id1)
+			Expect(framer.AppendStreamFrames(nil, 1000)).To(Equal([]wire.Frame{f}))
+		})
+
+		It("ignores priority changes for streams that already completed", func() {
+			streamGetter.EXPECT().GetOrOpenSendStream(id1)
+			framer.SetStreamPriority(id1, StreamPriority{Urgency: 7})
+			Expect(framer.(*framerI).priorities).To(BeEmpty())
+		})
+	})
 })
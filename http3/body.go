@@ -2,6 +2,7 @@ package http3
 
 import (
 	"errors"
+	"fmt"
 	"io"
 )
 
@@ -10,6 +11,14 @@ type body struct {
 	str io.ReadCloser
 
 	isRequest bool
+	// onPushPromise is called when a PUSH_PROMISE frame is received on a response stream.
+	// If nil, PUSH_PROMISE frames are not allowed.
+	onPushPromise func(*pushPromiseFrame) error
+	// onTrailers is called with the header block of the HEADERS frame following the DATA frames.
+	// If nil, trailers are not allowed.
+	onTrailers       func(headerBlock []byte) error
+	maxTrailerBytes  uint64 // the maximum size of the HEADERS frame containing the trailers, 0 means no limit
+	receivedTrailers bool
 
 	bytesRemainingInFrame uint64
 }
@@ -37,11 +46,26 @@ func (r *body) Read(b []byte) (int, error) {
 			}
 			switch f := frame.(type) {
 			case *headersFrame:
-				// skip HEADERS frames
-				continue
+				if err := r.readTrailers(f); err != nil {
+					return 0, err
+				}
 			case *dataFrame:
+				if r.receivedTrailers {
+					return 0, errors.New("received a DATA frame after the trailers")
+				}
 				r.bytesRemainingInFrame = f.Length
 				break parseLoop
+			case *pushPromiseFrame:
+				if r.onPushPromise == nil {
+					return 0, errors.New("unexpected frame")
+				}
+				if err := r.onPushPromise(f); err != nil {
+					return 0, err
+				}
+			case *duplicatePushFrame:
+				if r.isRequest {
+					return 0, errors.New("unexpected frame")
+				}
 			default:
 				return 0, errors.New("unexpected frame")
 			}
@@ -59,6 +83,22 @@ func (r *body) Read(b []byte) (int, error) {
 	return n, err
 }
 
+// readTrailers reads the trailers. The stream must end after the trailers.
+func (r *body) readTrailers(f *headersFrame) error {
+	if r.onTrailers == nil || r.receivedTrailers {
+		return errors.New("unexpected HEADERS frame")
+	}
+	r.receivedTrailers = true
+	if r.maxTrailerBytes > 0 && f.Length > r.maxTrailerBytes {
+		return fmt.Errorf("HEADERS frame too large: %d bytes (max: %d)", f.Length, r.maxTrailerBytes)
+	}
+	headerBlock := make([]byte, f.Length)
+	if _, err := io.ReadFull(r.str, headerBlock); err != nil {
+		return err
+	}
+	return r.onTrailers(headerBlock)
+}
+
 func (r *body) Close() error {
 	// quic.Stream.Close() closes the write side, not the read side
 	if r.isRequest {
@@ -2,7 +2,8 @@ package http3
 
 import (
 	"bytes"
-	"io"
+	"errors"
+	"io/ioutil"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -109,16 +110,59 @@ var _ = Describe("Body", func() {
 			Expect(b[:n]).To(Equal([]byte("bar")))
 		})
 
-		It("skips HEADERS frames", func() {
-			buf.Write(getDataFrame([]byte("foo")))
-			(&headersFrame{Length: 10}).Write(buf)
-			buf.Write(make([]byte, 10))
-			buf.Write(getDataFrame([]byte("bar")))
-			b := make([]byte, 6)
-			n, err := io.ReadFull(rb, b)
+		It("reads trailers", func() {
+			var trailers []byte
+			rb.onTrailers = func(headerBlock []byte) error {
+				trailers = headerBlock
+				return nil
+			}
+			buf.Write(getDataFrame([]byte("foobar")))
+			buf.Write(headersFrameBytes([]byte("trailers")))
+			data, err := ioutil.ReadAll(rb)
 			Expect(err).ToNot(HaveOccurred())
-			Expect(n).To(Equal(6))
-			Expect(b).To(Equal([]byte("foobar")))
+			Expect(data).To(Equal([]byte("foobar")))
+			Expect(trailers).To(Equal([]byte("trailers")))
+		})
+
+		It("returns the error that occurred when processing the trailers", func() {
+			rb.onTrailers = func([]byte) error { return errors.New("invalid trailers") }
+			buf.Write(headersFrameBytes([]byte("trailers")))
+			_, err := rb.Read([]byte{0})
+			Expect(err).To(MatchError("invalid trailers"))
+		})
+
+		It("errors when the trailers are too large", func() {
+			rb.onTrailers = func([]byte) error {
+				Fail("onTrailers should not be called")
+				return nil
+			}
+			rb.maxTrailerBytes = 7
+			buf.Write(headersFrameBytes([]byte("trailers")))
+			_, err := rb.Read([]byte{0})
+			Expect(err).To(MatchError("HEADERS frame too large: 8 bytes (max: 7)"))
+		})
+
+		It("errors on HEADERS frames if trailers are not allowed", func() {
+			buf.Write(getDataFrame([]byte("foo")))
+			buf.Write(headersFrameBytes([]byte("trailers")))
+			_, err := ioutil.ReadAll(rb)
+			Expect(err).To(MatchError("unexpected HEADERS frame"))
+		})
+
+		It("errors on DATA frames after the trailers", func() {
+			rb.onTrailers = func([]byte) error { return nil }
+			buf.Write(headersFrameBytes([]byte("trailers")))
+			buf.Write(getDataFrame([]byte("foo")))
+			_, err := rb.Read([]byte{0})
+			Expect(err).To(MatchError("received a DATA frame after the trailers"))
+		})
+
+		It("errors on a second HEADERS frame after the trailers", func() {
+			rb.onTrailers = func([]byte) error { return nil }
+			buf.Write(headersFrameBytes([]byte("trailers")))
+			buf.Write(headersFrameBytes([]byte("trailers")))
+			_, err := rb.Read([]byte{0})
+			Expect(err).To(MatchError("unexpected HEADERS frame"))
 		})
 
 		It("errors when it can't parse the frame", func() {
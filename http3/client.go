@@ -2,6 +2,7 @@ package http3
 
 import (
 	"bytes"
+	"context"
 	"crypto/tls"
 	"errors"
 	"fmt"
@@ -11,8 +12,8 @@ import (
 	"sync"
 
 	"github.com/lucas-clemente/quic-go"
+	"github.com/lucas-clemente/quic-go/internal/protocol"
 	"github.com/lucas-clemente/quic-go/internal/utils"
-	"github.com/marten-seemann/qpack"
 )
 
 const defaultUserAgent = "quic-go HTTP/3"
@@ -21,8 +22,28 @@ var defaultQuicConfig = &quic.Config{KeepAlive: true}
 
 var dialAddr = quic.DialAddr
 
+// defaultMaxResponseHeaderBytes is the default limit for the size of the response header.
+const defaultMaxResponseHeaderBytes = 10 << 20 // 10 MB
+
+// errGoAway is returned by the client when the server didn't process a request,
+// because it is shutting down the connection.
+// It is safe to retry the request on a new connection.
+var errGoAway = errors.New("http3: server is going away, request was not processed")
+
+// A requestNotSentError is returned by the client when a request wasn't sent,
+// because the QUIC connection couldn't be established, or no stream could be opened on it.
+// The RoundTripper returns the underlying error to the application.
+type requestNotSentError struct {
+	err error
+}
+
+func (e *requestNotSentError) Error() string { return e.err.Error() }
+
 type roundTripperOpts struct {
 	DisableCompression bool
+	OnPush             func(*http.Request, *http.Response)
+	MaxHeaderTableSize int
+	MaxHeaderBytes     int64
 }
 
 // client is a HTTP3 client doing requests
@@ -37,11 +58,23 @@ type client struct {
 
 	requestWriter *requestWriter
 
-	decoder *qpack.Decoder
+	encoder *qpackEncoder
+	decoder *qpackDecoder
 
 	hostname string
 	session  quic.Session
 
+	controlStrMutex sync.Mutex
+	controlStr      quic.SendStream
+
+	pushMutex      sync.Mutex
+	maxPushID      uint64 // the maximum push ID sent in a MAX_PUSH_ID frame
+	promisedPushes map[uint64]*promisedPush
+
+	mutex          sync.Mutex
+	goingAway      bool
+	goAwayStreamID protocol.StreamID
+
 	logger utils.Logger
 }
 
@@ -55,23 +88,34 @@ func newClient(
 	if tlsConf == nil {
 		tlsConf = &tls.Config{}
 	}
-	tlsConf.NextProtos = []string{"h3-19"}
+	tlsConf.NextProtos = []string{nextProtoH3}
 	if quicConfig == nil {
 		quicConfig = defaultQuicConfig
 	}
 	quicConfig.MaxIncomingStreams = -1 // don't allow any bidirectional streams
 	logger := utils.DefaultLogger.WithPrefix("h3 client")
 
-	return &client{
-		hostname:      authorityAddr("https", hostname),
-		tlsConf:       tlsConf,
-		requestWriter: newRequestWriter(logger),
-		decoder:       qpack.NewDecoder(func(hf qpack.HeaderField) {}),
-		config:        quicConfig,
-		opts:          opts,
-		dialer:        dialer,
-		logger:        logger,
+	var maxPushID uint64
+	if opts.OnPush != nil {
+		maxPushID = maxConcurrentPushes - 1
 	}
+	c := &client{
+		hostname:       authorityAddr("https", hostname),
+		tlsConf:        tlsConf,
+		config:         quicConfig,
+		opts:           opts,
+		dialer:         dialer,
+		maxPushID:      maxPushID,
+		promisedPushes: make(map[uint64]*promisedPush),
+		logger:         logger,
+	}
+	// The QPACK streams are opened when the first instruction is sent, which only happens after dialing.
+	openUniStream := func() (quic.SendStream, error) { return c.session.OpenUniStreamSync() }
+	tableCapacity := qpackTableCapacity(opts.MaxHeaderTableSize)
+	c.encoder = newQPACKEncoder(tableCapacity, newQPACKStream(streamTypeQPACKEncoderStream, openUniStream))
+	c.decoder = newQPACKDecoder(tableCapacity, newQPACKStream(streamTypeQPACKDecoderStream, openUniStream))
+	c.requestWriter = newRequestWriter(c.encoder, logger)
+	return c
 }
 
 func (c *client) dial() error {
@@ -90,8 +134,7 @@ func (c *client) dial() error {
 			c.session.CloseWithError(quic.ErrorCode(errorInternalError), err)
 		}
 	}()
-
-	// TODO: send a SETTINGS frame
+	go c.handleUnidirectionalStreams()
 	return nil
 }
 
@@ -101,16 +144,101 @@ func (c *client) setupSession() error {
 	if err != nil {
 		return err
 	}
+	c.controlStrMutex.Lock()
+	c.controlStr = str
+	c.controlStrMutex.Unlock()
+
 	buf := &bytes.Buffer{}
 	// write the type byte
-	buf.Write([]byte{0x0})
+	utils.WriteVarInt(buf, streamTypeControlStream)
 	// send the SETTINGS frame
-	(&settingsFrame{}).Write(buf)
-	if _, err := str.Write(buf.Bytes()); err != nil {
-		return err
+	settings := map[uint64]uint64{settingMaxHeaderListSize: c.maxHeaderBytes()}
+	for id, val := range qpackSettings(qpackTableCapacity(c.opts.MaxHeaderTableSize)) {
+		settings[id] = val
+	}
+	(&settingsFrame{settings: settings}).Write(buf)
+	if c.pushEnabled() {
+		c.pushMutex.Lock()
+		(&maxPushIDFrame{PushID: c.maxPushID}).Write(buf)
+		c.pushMutex.Unlock()
 	}
+	return c.writeControlStream(buf.Bytes())
+}
 
-	return nil
+func (c *client) maxHeaderBytes() uint64 {
+	if c.opts.MaxHeaderBytes <= 0 {
+		return defaultMaxResponseHeaderBytes
+	}
+	return uint64(c.opts.MaxHeaderBytes)
+}
+
+func (c *client) writeControlStream(b []byte) error {
+	c.controlStrMutex.Lock()
+	defer c.controlStrMutex.Unlock()
+	if c.controlStr == nil {
+		return errors.New("control stream not yet opened")
+	}
+	_, err := c.controlStr.Write(b)
+	return err
+}
+
+func (c *client) handleUnidirectionalStreams() {
+	h := &uniStreamHandler{
+		sess:               c.session,
+		perspective:        protocol.PerspectiveClient,
+		encoder:            c.encoder,
+		decoder:            c.decoder,
+		logger:             c.logger,
+		handleSettings:     c.handleSettings,
+		handleControlFrame: c.handleControlFrame,
+		handlePushStream:   c.handlePushStream,
+	}
+	h.run()
+}
+
+func (c *client) handleSettings(settings map[uint64]uint64) {
+	if size, ok := settings[settingMaxHeaderListSize]; ok {
+		c.requestWriter.setPeerMaxHeaderListSize(size)
+	}
+}
+
+// handleControlFrame handles the GOAWAY and CANCEL_PUSH frames sent on the server's control stream.
+func (c *client) handleControlFrame(f frame) (errorCode, error) {
+	switch f := f.(type) {
+	case *cancelPushFrame:
+		if err := c.cancelPush(f.PushID); err != nil {
+			return errorLimitExceeded, err
+		}
+	case *goAwayFrame:
+		if f.StreamID.InitiatedBy() != protocol.PerspectiveClient || f.StreamID.Type() != protocol.StreamTypeBidi {
+			return errorWrongStream, fmt.Errorf("received a GOAWAY frame for an invalid stream ID: %d", f.StreamID)
+		}
+		c.logger.Debugf("Received GOAWAY for stream %d", f.StreamID)
+		c.mutex.Lock()
+		// The stream ID in subsequent GOAWAY frames is not allowed to increase.
+		if !c.goingAway || f.StreamID < c.goAwayStreamID {
+			c.goAwayStreamID = f.StreamID
+		}
+		c.goingAway = true
+		c.mutex.Unlock()
+	}
+	return 0, nil
+}
+
+// requestProcessed says if the server will process (or already has processed) a request on this stream,
+// taking into account GOAWAY frames that the server might have sent.
+func (c *client) requestProcessed(id protocol.StreamID) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return !c.goingAway || id < c.goAwayStreamID
+}
+
+// connect establishes the QUIC connection, if that didn't happen yet.
+func (c *client) connect() error {
+	c.dialOnce.Do(func() {
+		c.handshakeErr = c.dial()
+	})
+	return c.handshakeErr
 }
 
 func (c *client) Close() error {
@@ -118,7 +246,6 @@ func (c *client) Close() error {
 }
 
 // Roundtrip executes a request and returns a response
-// TODO: handle request cancelations
 func (c *client) RoundTrip(req *http.Request) (*http.Response, error) {
 	if req.URL.Scheme != "https" {
 		return nil, errors.New("http3: unsupported scheme")
@@ -127,44 +254,155 @@ func (c *client) RoundTrip(req *http.Request) (*http.Response, error) {
 		return nil, fmt.Errorf("http3 client BUG: RoundTrip called for the wrong client (expected %s, got %s)", c.hostname, req.Host)
 	}
 
-	c.dialOnce.Do(func() {
-		c.handshakeErr = c.dial()
-	})
+	if err := c.connect(); err != nil {
+		return nil, &requestNotSentError{err: err}
+	}
+
+	ctx := req.Context()
+	str, err := c.openRequestStream(ctx)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, err
+		}
+		return nil, &requestNotSentError{err: err}
+	}
+	if !c.requestProcessed(str.StreamID()) {
+		str.CancelRead(quic.ErrorCode(errorRequestCanceled))
+		str.CancelWrite(quic.ErrorCode(errorRequestCanceled))
+		return nil, errGoAway
+	}
 
-	if c.handshakeErr != nil {
-		return nil, c.handshakeErr
+	// Request Cancellation:
+	// This go routine keeps running even after RoundTrip() returns.
+	// It is shut down when the application is done processing the body.
+	reqDone := make(chan struct{})
+	if ctx.Done() != nil {
+		go func() {
+			select {
+			case <-ctx.Done():
+				// If both channels are closed, select picks one of them at random.
+				select {
+				case <-reqDone:
+					return
+				default:
+				}
+				str.CancelWrite(quic.ErrorCode(errorRequestCanceled))
+				str.CancelRead(quic.ErrorCode(errorRequestCanceled))
+			case <-reqDone:
+			}
+		}()
 	}
 
-	str, err := c.session.OpenStreamSync()
+	rsp, err := c.doRequest(req, str, reqDone)
 	if err != nil {
+		close(reqDone)
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		// The server rejects requests it didn't process after sending a GOAWAY frame.
+		if serr, ok := err.(quic.StreamError); ok && serr.ErrorCode() == quic.ErrorCode(errorRequestRejected) && !c.requestProcessed(str.StreamID()) {
+			return nil, errGoAway
+		}
 		return nil, err
 	}
+	return rsp, nil
+}
+
+// openRequestStream opens a new bidirectional stream.
+// If the context is canceled before the stream can be opened, ctx.Err() is returned.
+func (c *client) openRequestStream(ctx context.Context) (quic.Stream, error) {
+	if ctx.Done() == nil { // the context can never be canceled
+		return c.session.OpenStreamSync()
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	type result struct {
+		str quic.Stream
+		err error
+	}
+	resultChan := make(chan result, 1)
+	go func() {
+		str, err := c.session.OpenStreamSync()
+		resultChan <- result{str: str, err: err}
+	}()
+	select {
+	case r := <-resultChan:
+		return r.str, r.err
+	case <-ctx.Done():
+		// The stream will be opened eventually, but it's not needed any more.
+		go func() {
+			if r := <-resultChan; r.err == nil {
+				r.str.CancelRead(quic.ErrorCode(errorRequestCanceled))
+				r.str.CancelWrite(quic.ErrorCode(errorRequestCanceled))
+			}
+		}()
+		return nil, ctx.Err()
+	}
+}
 
+func (c *client) doRequest(req *http.Request, str quic.Stream, reqDone chan<- struct{}) (*http.Response, error) {
 	var requestGzip bool
 	if !c.opts.DisableCompression && req.Method != "HEAD" && req.Header.Get("Accept-Encoding") == "" && req.Header.Get("Range") == "" {
 		requestGzip = true
 	}
+	if prio, ok := priorityFromHeader(req.Header); ok {
+		str.SetPriority(prio)
+	}
 	if err := c.requestWriter.WriteRequest(str, req, requestGzip); err != nil {
 		return nil, err
 	}
 
-	frame, err := parseNextFrame(str)
-	if err != nil {
-		return nil, err
+	return c.readResponse(str, requestGzip, reqDone, func(f *pushPromiseFrame) error {
+		return c.handlePushPromise(str, f)
+	})
+}
+
+// readResponse reads the response from a request stream or a push stream.
+// reqDone is closed once the application is done with the response body. It is nil for push streams.
+// If onPushPromise is nil, PUSH_PROMISE frames are not allowed on this stream.
+func (c *client) readResponse(str quic.ReceiveStream, requestGzip bool, reqDone chan<- struct{}, onPushPromise func(*pushPromiseFrame) error) (*http.Response, error) {
+	var hf *headersFrame
+	for hf == nil {
+		frame, err := parseNextFrame(str)
+		if err != nil {
+			return nil, err
+		}
+		switch f := frame.(type) {
+		case *headersFrame:
+			hf = f
+		case *pushPromiseFrame:
+			if onPushPromise == nil {
+				return nil, errors.New("unexpected PUSH_PROMISE frame")
+			}
+			if err := onPushPromise(f); err != nil {
+				return nil, err
+			}
+		case *duplicatePushFrame:
+			// The push was already promised on a different request stream.
+		default:
+			return nil, errors.New("not a HEADERS frame")
+		}
 	}
-	hf, ok := frame.(*headersFrame)
-	if !ok {
-		return nil, errors.New("not a HEADERS frame")
+	if hf.Length > c.maxHeaderBytes() {
+		str.CancelRead(quic.ErrorCode(errorExcessiveLoad))
+		return nil, fmt.Errorf("HEADERS frame too large: %d bytes (max: %d)", hf.Length, c.maxHeaderBytes())
 	}
-	// TODO: check size
 	headerBlock := make([]byte, hf.Length)
 	if _, err := io.ReadFull(str, headerBlock); err != nil {
 		return nil, err
 	}
-	hfs, err := c.decoder.DecodeFull(headerBlock)
+	hfs, err := c.decoder.decode(str.StreamID(), headerBlock)
 	if err != nil {
+		c.session.CloseWithError(quic.ErrorCode(errorQPACKDecompressionFailed), err)
 		return nil, err
 	}
+	// The decompressed header list can be a lot larger than the header block.
+	if size := headerListSize(hfs); size > c.maxHeaderBytes() {
+		str.CancelRead(quic.ErrorCode(errorExcessiveLoad))
+		return nil, fmt.Errorf("header list too large: %d bytes (max: %d)", size, c.maxHeaderBytes())
+	}
 	res := &http.Response{
 		Proto:      "HTTP/3",
 		ProtoMajor: 3,
@@ -179,11 +417,35 @@ func (c *client) RoundTrip(req *http.Request) (*http.Response, error) {
 			}
 			res.StatusCode = status
 			res.Status = hf.Value + " " + http.StatusText(status)
+		case "trailer":
+			if res.Trailer == nil {
+				res.Trailer = http.Header{}
+			}
+			for _, key := range declaredTrailers([]string{hf.Value}) {
+				res.Trailer[key] = nil
+			}
 		default:
 			res.Header.Add(hf.Name, hf.Value)
 		}
 	}
-	respBody := newResponseBody(&responseBody{str})
+	respBody := newResponseBody(&responseBody{ReceiveStream: str, reqDone: reqDone})
+	respBody.onPushPromise = onPushPromise
+	// The trailers are available once the body returned io.EOF.
+	respBody.maxTrailerBytes = c.maxHeaderBytes()
+	respBody.onTrailers = func(headerBlock []byte) error {
+		hfs, err := c.decoder.decode(str.StreamID(), headerBlock)
+		if err != nil {
+			c.session.CloseWithError(quic.ErrorCode(errorQPACKDecompressionFailed), err)
+			return err
+		}
+		if size := headerListSize(hfs); size > c.maxHeaderBytes() {
+			return fmt.Errorf("trailers too large: %d bytes (max: %d)", size, c.maxHeaderBytes())
+		}
+		if res.Trailer == nil {
+			res.Trailer = http.Header{}
+		}
+		return addTrailers(res.Trailer, hfs)
+	}
 	if requestGzip && res.Header.Get("Content-Encoding") == "gzip" {
 		res.Header.Del("Content-Encoding")
 		res.Header.Del("Content-Length")
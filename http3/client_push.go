@@ -0,0 +1,180 @@
+package http3
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/lucas-clemente/quic-go"
+	"github.com/lucas-clemente/quic-go/internal/utils"
+)
+
+// maxConcurrentPushes is the number of pushed responses that the server is allowed to send,
+// before the client has delivered them to the application.
+const maxConcurrentPushes = 16
+
+// A promisedPush is created when either the PUSH_PROMISE frame or the push stream is received.
+// The push is delivered to the application once both of them have been received.
+type promisedPush struct {
+	req *http.Request
+	str quic.ReceiveStream
+
+	done bool // delivered or canceled
+}
+
+// pushEnabled says if the server is allowed to push responses.
+func (c *client) pushEnabled() bool {
+	return c.opts.OnPush != nil
+}
+
+// handlePushPromise handles a PUSH_PROMISE frame received on a request stream.
+// Errors returned are connection errors.
+func (c *client) handlePushPromise(str quic.ReceiveStream, f *pushPromiseFrame) error {
+	if err := c.checkPushID(f.PushID); err != nil {
+		return err
+	}
+	if f.Length > c.maxHeaderBytes() {
+		str.CancelRead(quic.ErrorCode(errorExcessiveLoad))
+		return fmt.Errorf("PUSH_PROMISE frame too large: %d bytes (max: %d)", f.Length, c.maxHeaderBytes())
+	}
+	headerBlock := make([]byte, f.Length)
+	if _, err := io.ReadFull(str, headerBlock); err != nil {
+		return err
+	}
+	hfs, err := c.decoder.decode(str.StreamID(), headerBlock)
+	if err != nil {
+		c.session.CloseWithError(quic.ErrorCode(errorQPACKDecompressionFailed), err)
+		return err
+	}
+	req, err := requestFromHeaders(hfs)
+	if err != nil {
+		c.session.CloseWithError(quic.ErrorCode(errorGeneralProtocolError), err)
+		return err
+	}
+	req.URL.Scheme = "https"
+	req.URL.Host = req.Host
+
+	c.pushMutex.Lock()
+	p, ok := c.promisedPushes[f.PushID]
+	if !ok {
+		p = &promisedPush{}
+		c.promisedPushes[f.PushID] = p
+	}
+	// The same push might be promised multiple times.
+	if p.done || p.req != nil {
+		c.pushMutex.Unlock()
+		return nil
+	}
+	p.req = req
+	deliver := p.str != nil
+	c.pushMutex.Unlock()
+
+	if deliver {
+		go c.deliverPush(f.PushID, p)
+	}
+	return nil
+}
+
+// handlePushStream handles a push stream.
+// The stream type has already been read from the stream.
+func (c *client) handlePushStream(str quic.ReceiveStream) {
+	pushID, err := utils.ReadVarInt(&byteReaderImpl{str})
+	if err != nil {
+		c.logger.Debugf("Reading the push ID on stream %d failed: %s", str.StreamID(), err)
+		return
+	}
+	if err := c.checkPushID(pushID); err != nil {
+		return
+	}
+
+	c.pushMutex.Lock()
+	p, ok := c.promisedPushes[pushID]
+	if !ok {
+		p = &promisedPush{}
+		c.promisedPushes[pushID] = p
+	}
+	if p.done || p.str != nil {
+		c.pushMutex.Unlock()
+		str.CancelRead(quic.ErrorCode(errorDuplicatePush))
+		return
+	}
+	p.str = str
+	deliver := p.req != nil
+	c.pushMutex.Unlock()
+
+	if deliver {
+		c.deliverPush(pushID, p)
+	}
+}
+
+// checkPushID checks that the server didn't use a push ID that's larger than allowed.
+// If it did, the connection is closed.
+func (c *client) checkPushID(id uint64) error {
+	c.pushMutex.Lock()
+	defer c.pushMutex.Unlock()
+
+	if !c.pushEnabled() || id > c.maxPushID {
+		err := fmt.Errorf("received push ID %d, but MAX_PUSH_ID is %d", id, c.maxPushID)
+		c.session.CloseWithError(quic.ErrorCode(errorLimitExceeded), err)
+		return err
+	}
+	return nil
+}
+
+// deliverPush passes a pushed response to the application.
+func (c *client) deliverPush(pushID uint64, p *promisedPush) {
+	defer c.completePush(p)
+
+	rsp, err := c.readResponse(p.str, false, nil, nil)
+	if err != nil {
+		c.logger.Debugf("Reading the response for push %d failed: %s", pushID, err)
+		p.str.CancelRead(quic.ErrorCode(errorGeneralProtocolError))
+		return
+	}
+	rsp.Request = p.req
+	c.opts.OnPush(p.req, rsp)
+	rsp.Body.Close()
+}
+
+// completePush is called when a push was delivered to the application, or canceled.
+// The server is then allowed to push another response.
+func (c *client) completePush(p *promisedPush) {
+	c.pushMutex.Lock()
+	p.done = true
+	p.req = nil
+	p.str = nil
+	c.maxPushID++
+	maxPushID := c.maxPushID
+	c.pushMutex.Unlock()
+
+	buf := &bytes.Buffer{}
+	(&maxPushIDFrame{PushID: maxPushID}).Write(buf)
+	if err := c.writeControlStream(buf.Bytes()); err != nil {
+		c.logger.Debugf("Sending MAX_PUSH_ID failed: %s", err)
+	}
+}
+
+// cancelPush handles a CANCEL_PUSH frame received from the server.
+func (c *client) cancelPush(pushID uint64) error {
+	if err := c.checkPushID(pushID); err != nil {
+		return err
+	}
+	c.pushMutex.Lock()
+	p, ok := c.promisedPushes[pushID]
+	if !ok {
+		p = &promisedPush{}
+		c.promisedPushes[pushID] = p
+	}
+	// If both the PUSH_PROMISE and the push stream were received, the push is already being delivered.
+	if p.done || (p.req != nil && p.str != nil) {
+		c.pushMutex.Unlock()
+		return nil
+	}
+	if p.str != nil {
+		p.str.CancelRead(quic.ErrorCode(errorRequestCanceled))
+	}
+	c.pushMutex.Unlock()
+	c.completePush(p)
+	return nil
+}
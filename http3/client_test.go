@@ -3,16 +3,21 @@ package http3
 import (
 	"bytes"
 	"compress/gzip"
+	"context"
 	"crypto/tls"
 	"errors"
+	"fmt"
 	"io"
 	"io/ioutil"
 	"net/http"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/golang/mock/gomock"
 	quic "github.com/lucas-clemente/quic-go"
 	mockquic "github.com/lucas-clemente/quic-go/internal/mocks/quic"
+	"github.com/lucas-clemente/quic-go/internal/protocol"
 	"github.com/lucas-clemente/quic-go/internal/utils"
 	"github.com/marten-seemann/qpack"
 
@@ -20,6 +25,18 @@ import (
 	. "github.com/onsi/gomega"
 )
 
+type streamCanceledError struct {
+	errorCode quic.ErrorCode
+}
+
+var _ quic.StreamError = &streamCanceledError{}
+
+func (e *streamCanceledError) Error() string {
+	return fmt.Sprintf("stream canceled with error code %d", e.errorCode)
+}
+func (e *streamCanceledError) Canceled() bool            { return true }
+func (e *streamCanceledError) ErrorCode() quic.ErrorCode { return e.errorCode }
+
 var _ = Describe("Client", func() {
 	var (
 		client       *client
@@ -103,7 +120,7 @@ var _ = Describe("Client", func() {
 		}
 		client = newClient("localhost:1337", tlsConf, &roundTripperOpts{}, quicConf, dialer)
 		_, err := client.RoundTrip(req)
-		Expect(err).To(MatchError(testErr))
+		Expect(err).To(MatchError(&requestNotSentError{err: testErr}))
 		Expect(dialerCalled).To(BeTrue())
 	})
 
@@ -114,7 +131,7 @@ var _ = Describe("Client", func() {
 			return nil, testErr
 		}
 		_, err := client.RoundTrip(req)
-		Expect(err).To(MatchError(testErr))
+		Expect(err).To(MatchError(&requestNotSentError{err: testErr}))
 	})
 
 	It("errors if it can't open a stream", func() {
@@ -122,6 +139,7 @@ var _ = Describe("Client", func() {
 		client = newClient("localhost:1337", nil, &roundTripperOpts{}, nil, nil)
 		session := mockquic.NewMockSession(mockCtrl)
 		session.EXPECT().OpenUniStreamSync().Return(nil, testErr).MaxTimes(1)
+		session.EXPECT().AcceptUniStream().Return(nil, testErr).MaxTimes(1)
 		session.EXPECT().OpenStreamSync().Return(nil, testErr).MaxTimes(1)
 		session.EXPECT().CloseWithError(gomock.Any(), gomock.Any()).MaxTimes(1)
 		dialAddr = func(hostname string, _ *tls.Config, _ *quic.Config) (quic.Session, error) {
@@ -129,14 +147,19 @@ var _ = Describe("Client", func() {
 		}
 		defer GinkgoRecover()
 		_, err := client.RoundTrip(req)
-		Expect(err).To(MatchError(testErr))
+		Expect(err).To(MatchError(&requestNotSentError{err: testErr}))
 	})
 
 	Context("Doing requests", func() {
 		var (
-			request *http.Request
-			str     *mockquic.MockStream
-			sess    *mockquic.MockSession
+			request    *http.Request
+			str        *mockquic.MockStream
+			sess       *mockquic.MockSession
+			uniStreams chan quic.ReceiveStream
+			testDone   chan struct{}
+
+			controlStrMutex sync.Mutex
+			controlStrBuf   *bytes.Buffer
 		)
 
 		decodeHeader := func(str io.Reader) map[string]string {
@@ -160,11 +183,28 @@ var _ = Describe("Client", func() {
 
 		BeforeEach(func() {
 			controlStr := mockquic.NewMockStream(mockCtrl)
-			controlStr.EXPECT().Write([]byte{0x0}).Return(1, nil).MaxTimes(1)
-			controlStr.EXPECT().Write(gomock.Any()).MaxTimes(1) // SETTINGS frame
+			controlStrBuf = &bytes.Buffer{}
+			b := controlStrBuf
+			controlStr.EXPECT().Write(gomock.Any()).DoAndReturn(func(p []byte) (int, error) {
+				controlStrMutex.Lock()
+				defer controlStrMutex.Unlock()
+				return b.Write(p)
+			}).AnyTimes()
 			str = mockquic.NewMockStream(mockCtrl)
+			str.EXPECT().StreamID().AnyTimes()
 			sess = mockquic.NewMockSession(mockCtrl)
 			sess.EXPECT().OpenUniStreamSync().Return(controlStr, nil).MaxTimes(1)
+			uniStreams = make(chan quic.ReceiveStream, 1)
+			testDone = make(chan struct{})
+			strs, done := uniStreams, testDone
+			sess.EXPECT().AcceptUniStream().DoAndReturn(func() (quic.ReceiveStream, error) {
+				select {
+				case str := <-strs:
+					return str, nil
+				case <-done:
+					return nil, errors.New("test done")
+				}
+			}).AnyTimes()
 			dialAddr = func(hostname string, _ *tls.Config, _ *quic.Config) (quic.Session, error) {
 				return sess, nil
 			}
@@ -173,6 +213,12 @@ var _ = Describe("Client", func() {
 			Expect(err).ToNot(HaveOccurred())
 		})
 
+		AfterEach(func() {
+			// reading from the control stream fails after the test is over, which leads to the session being closed
+			sess.EXPECT().CloseWithError(gomock.Any(), gomock.Any()).AnyTimes()
+			close(testDone)
+		})
+
 		It("sends a request", func() {
 			sess.EXPECT().OpenStreamSync().Return(str, nil)
 			buf := &bytes.Buffer{}
@@ -192,7 +238,7 @@ var _ = Describe("Client", func() {
 
 		It("returns a response", func() {
 			rspBuf := &bytes.Buffer{}
-			rw := newResponseWriter(rspBuf, utils.DefaultLogger)
+			rw := newResponseWriter(rspBuf, newQPACKEncoder(0, nil), 0, utils.DefaultLogger)
 			rw.WriteHeader(418)
 
 			sess.EXPECT().OpenStreamSync().Return(str, nil)
@@ -208,6 +254,302 @@ var _ = Describe("Client", func() {
 			Expect(rsp.StatusCode).To(Equal(418))
 		})
 
+		It("returns the trailers after the body was read", func() {
+			rspBuf := &bytes.Buffer{}
+			rw := newResponseWriter(rspBuf, newQPACKEncoder(0, nil), 0, utils.DefaultLogger)
+			rw.Header().Set("Trailer", "Foo")
+			rw.Write([]byte("foobar"))
+			rw.Header().Set("Foo", "bar")
+			rw.writeTrailers()
+
+			sess.EXPECT().OpenStreamSync().Return(str, nil)
+			str.EXPECT().Write(gomock.Any()).AnyTimes()
+			str.EXPECT().Close()
+			str.EXPECT().Read(gomock.Any()).DoAndReturn(rspBuf.Read).AnyTimes()
+			rsp, err := client.RoundTrip(request)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(rsp.Header).ToNot(HaveKey("Trailer"))
+			Expect(rsp.Trailer).To(Equal(http.Header{"Foo": nil}))
+			body, err := ioutil.ReadAll(rsp.Body)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(body).To(Equal([]byte("foobar")))
+			Expect(rsp.Trailer).To(Equal(http.Header{"Foo": {"bar"}}))
+		})
+
+		It("rejects trailers containing pseudo headers", func() {
+			rspBuf := &bytes.Buffer{}
+			newResponseWriter(rspBuf, newQPACKEncoder(0, nil), 0, utils.DefaultLogger).WriteHeader(200)
+			headerBlock := &bytes.Buffer{}
+			Expect(qpack.NewEncoder(headerBlock).WriteField(qpack.HeaderField{Name: ":status", Value: "200"})).To(Succeed())
+			rspBuf.Write(headersFrameBytes(headerBlock.Bytes()))
+
+			sess.EXPECT().OpenStreamSync().Return(str, nil)
+			str.EXPECT().Write(gomock.Any()).AnyTimes()
+			str.EXPECT().Close()
+			str.EXPECT().Read(gomock.Any()).DoAndReturn(rspBuf.Read).AnyTimes()
+			rsp, err := client.RoundTrip(request)
+			Expect(err).ToNot(HaveOccurred())
+			_, err = ioutil.ReadAll(rsp.Body)
+			Expect(err).To(MatchError(`trailers must not contain pseudo header ":status"`))
+		})
+
+		Context("header size limits", func() {
+			It("advertises the maximum header list size in the SETTINGS", func() {
+				client.opts.MaxHeaderBytes = 1337
+				sess.EXPECT().OpenStreamSync().Return(str, nil)
+				str.EXPECT().Write(gomock.Any()).AnyTimes()
+				str.EXPECT().Close()
+				str.EXPECT().Read(gomock.Any()).Return(0, errors.New("test done"))
+				_, err := client.RoundTrip(request)
+				Expect(err).To(MatchError("test done"))
+				// the control stream is opened asynchronously
+				Eventually(func() int {
+					controlStrMutex.Lock()
+					defer controlStrMutex.Unlock()
+					return controlStrBuf.Len()
+				}).ShouldNot(BeZero())
+				controlStrMutex.Lock()
+				r := bytes.NewReader(controlStrBuf.Bytes())
+				controlStrMutex.Unlock()
+				_, err = utils.ReadVarInt(r) // stream type
+				Expect(err).ToNot(HaveOccurred())
+				f, err := parseNextFrame(r)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(f).To(BeAssignableToTypeOf(&settingsFrame{}))
+				Expect(f.(*settingsFrame).settings).To(HaveKeyWithValue(uint64(settingMaxHeaderListSize), uint64(1337)))
+			})
+
+			It("rejects a HEADERS frame larger than MaxResponseHeaderBytes", func() {
+				client.opts.MaxHeaderBytes = 100
+				rspBuf := &bytes.Buffer{}
+				(&headersFrame{Length: 101}).Write(rspBuf)
+
+				sess.EXPECT().OpenStreamSync().Return(str, nil)
+				str.EXPECT().Write(gomock.Any()).AnyTimes()
+				str.EXPECT().Close()
+				str.EXPECT().Read(gomock.Any()).DoAndReturn(rspBuf.Read).AnyTimes()
+				str.EXPECT().CancelRead(quic.ErrorCode(errorExcessiveLoad))
+				_, err := client.RoundTrip(request)
+				Expect(err).To(MatchError("HEADERS frame too large: 101 bytes (max: 100)"))
+			})
+
+			It("rejects a header list larger than MaxResponseHeaderBytes", func() {
+				client.opts.MaxHeaderBytes = 1000
+				rspBuf := &bytes.Buffer{}
+				rw := newResponseWriter(rspBuf, newQPACKEncoder(0, nil), 0, utils.DefaultLogger)
+				rw.Header().Set("Foo", strings.Repeat("a", 1000))
+				rw.WriteHeader(200)
+
+				sess.EXPECT().OpenStreamSync().Return(str, nil)
+				str.EXPECT().Write(gomock.Any()).AnyTimes()
+				str.EXPECT().Close()
+				str.EXPECT().Read(gomock.Any()).DoAndReturn(rspBuf.Read).AnyTimes()
+				str.EXPECT().CancelRead(quic.ErrorCode(errorExcessiveLoad))
+				_, err := client.RoundTrip(request)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(HavePrefix("header list too large"))
+			})
+
+			It("doesn't send requests larger than the server's maximum header list size", func() {
+				client.requestWriter.setPeerMaxHeaderListSize(100)
+				request.Header.Set("Foo", strings.Repeat("a", 100))
+				sess.EXPECT().OpenStreamSync().Return(str, nil)
+				_, err := client.RoundTrip(request)
+				Expect(err).To(MatchError(errRequestHeaderListSize))
+			})
+		})
+
+		Context("handling GOAWAY", func() {
+			// newControlStream creates a control stream, sending a SETTINGS and a GOAWAY frame
+			newControlStream := func(goAwayStreamID protocol.StreamID) quic.ReceiveStream {
+				buf := &bytes.Buffer{}
+				utils.WriteVarInt(buf, streamTypeControlStream)
+				(&settingsFrame{}).Write(buf)
+				(&goAwayFrame{StreamID: goAwayStreamID}).Write(buf)
+				controlStr := mockquic.NewMockStream(mockCtrl)
+				done := testDone
+				controlStr.EXPECT().Read(gomock.Any()).DoAndReturn(func(p []byte) (int, error) {
+					if buf.Len() == 0 {
+						<-done
+						return 0, errors.New("test done")
+					}
+					return buf.Read(p)
+				}).AnyTimes()
+				return controlStr
+			}
+
+			It("doesn't send requests after receiving a GOAWAY", func() {
+				client.session = sess
+				go client.handleUnidirectionalStreams()
+				uniStreams <- newControlStream(0)
+				Eventually(func() bool { return client.requestProcessed(0) }).Should(BeFalse())
+
+				sess.EXPECT().OpenStreamSync().Return(str, nil)
+				str.EXPECT().CancelRead(quic.ErrorCode(errorRequestCanceled))
+				str.EXPECT().CancelWrite(quic.ErrorCode(errorRequestCanceled))
+				_, err := client.RoundTrip(request)
+				Expect(err).To(MatchError(errGoAway))
+			})
+
+			It("sends requests on streams that the server will process", func() {
+				client.session = sess
+				go client.handleUnidirectionalStreams()
+				uniStreams <- newControlStream(4)
+				Eventually(func() bool { return client.requestProcessed(4) }).Should(BeFalse())
+
+				sess.EXPECT().OpenStreamSync().Return(str, nil)
+				str.EXPECT().Write(gomock.Any()).AnyTimes()
+				str.EXPECT().Close()
+				str.EXPECT().Read(gomock.Any()).Return(0, errors.New("test done"))
+				_, err := client.RoundTrip(request)
+				Expect(err).To(MatchError("test done"))
+			})
+
+			It("returns errGoAway when the server rejects a request after sending a GOAWAY", func() {
+				sess.EXPECT().OpenStreamSync().Return(str, nil)
+				str.EXPECT().Write(gomock.Any()).AnyTimes()
+				str.EXPECT().Close()
+				str.EXPECT().Read(gomock.Any()).DoAndReturn(func([]byte) (int, error) {
+					uniStreams <- newControlStream(0)
+					Eventually(func() bool { return client.requestProcessed(0) }).Should(BeFalse())
+					return 0, &streamCanceledError{errorCode: quic.ErrorCode(errorRequestRejected)}
+				})
+				_, err := client.RoundTrip(request)
+				Expect(err).To(MatchError(errGoAway))
+			})
+
+			It("closes the connection when the GOAWAY frame contains an invalid stream ID", func() {
+				client.session = sess
+				go client.handleUnidirectionalStreams()
+				closed := make(chan struct{})
+				sess.EXPECT().CloseWithError(quic.ErrorCode(errorWrongStream), gomock.Any()).Do(func(quic.ErrorCode, error) { close(closed) })
+				uniStreams <- newControlStream(3) // a server-initiated unidirectional stream
+				Eventually(closed).Should(BeClosed())
+			})
+		})
+
+		Context("server push", func() {
+			var pushes chan *http.Response
+
+			// newPushStream creates a push stream containing the given data.
+			newPushStream := func(pushID uint64, data []byte) *mockquic.MockStream {
+				buf := &bytes.Buffer{}
+				utils.WriteVarInt(buf, streamTypePushStream)
+				utils.WriteVarInt(buf, pushID)
+				buf.Write(data)
+				str := mockquic.NewMockStream(mockCtrl)
+				str.EXPECT().Read(gomock.Any()).DoAndReturn(buf.Read).AnyTimes()
+				str.EXPECT().StreamID().AnyTimes()
+				str.EXPECT().CancelRead(gomock.Any()).AnyTimes()
+				return str
+			}
+
+			// getMaxPushIDs parses the MAX_PUSH_ID frames sent on the control stream
+			getMaxPushIDs := func() []uint64 {
+				controlStrMutex.Lock()
+				defer controlStrMutex.Unlock()
+				// the control stream is opened asynchronously
+				if controlStrBuf.Len() == 0 {
+					return nil
+				}
+				r := bytes.NewReader(controlStrBuf.Bytes())
+				_, err := utils.ReadVarInt(r) // stream type
+				Expect(err).ToNot(HaveOccurred())
+				var ids []uint64
+				for {
+					f, err := parseNextFrame(r)
+					if err == io.EOF {
+						return ids
+					}
+					Expect(err).ToNot(HaveOccurred())
+					if mf, ok := f.(*maxPushIDFrame); ok {
+						ids = append(ids, mf.PushID)
+					}
+				}
+			}
+
+			BeforeEach(func() {
+				pushes = make(chan *http.Response, 1)
+				p := pushes
+				client = newClient("quic.clemente.io:1337", nil, &roundTripperOpts{
+					OnPush: func(req *http.Request, rsp *http.Response) {
+						defer GinkgoRecover()
+						Expect(rsp.Request).To(Equal(req))
+						data, err := ioutil.ReadAll(rsp.Body)
+						Expect(err).ToNot(HaveOccurred())
+						Expect(string(data)).To(Equal("foobar"))
+						p <- rsp
+					},
+				}, nil, nil)
+			})
+
+			It("sends a MAX_PUSH_ID frame", func() {
+				sess.EXPECT().OpenStreamSync().Return(str, nil)
+				str.EXPECT().Write(gomock.Any()).AnyTimes()
+				str.EXPECT().Close()
+				str.EXPECT().Read(gomock.Any()).Return(0, errors.New("test done"))
+				_, err := client.RoundTrip(request)
+				Expect(err).To(MatchError("test done"))
+				Eventually(getMaxPushIDs).Should(Equal([]uint64{maxConcurrentPushes - 1}))
+			})
+
+			It("delivers pushed responses", func() {
+				headerBlock := &bytes.Buffer{}
+				enc := qpack.NewEncoder(headerBlock)
+				Expect(enc.WriteField(qpack.HeaderField{Name: ":method", Value: "GET"})).To(Succeed())
+				Expect(enc.WriteField(qpack.HeaderField{Name: ":scheme", Value: "https"})).To(Succeed())
+				Expect(enc.WriteField(qpack.HeaderField{Name: ":authority", Value: "quic.clemente.io:1337"})).To(Succeed())
+				Expect(enc.WriteField(qpack.HeaderField{Name: ":path", Value: "/style.css"})).To(Succeed())
+				rspBuf := &bytes.Buffer{}
+				(&pushPromiseFrame{PushID: 0, Length: uint64(headerBlock.Len())}).Write(rspBuf)
+				rspBuf.Write(headerBlock.Bytes())
+				newResponseWriter(rspBuf, newQPACKEncoder(0, nil), 0, utils.DefaultLogger).WriteHeader(200)
+
+				pushBuf := &bytes.Buffer{}
+				pushRW := newResponseWriter(pushBuf, newQPACKEncoder(0, nil), 0, utils.DefaultLogger)
+				pushRW.Write([]byte("foobar"))
+				pushRW.Flush()
+				uniStreams <- newPushStream(0, pushBuf.Bytes())
+
+				sess.EXPECT().OpenStreamSync().Return(str, nil)
+				str.EXPECT().Write(gomock.Any()).AnyTimes()
+				str.EXPECT().Close()
+				str.EXPECT().Read(gomock.Any()).DoAndReturn(rspBuf.Read).AnyTimes()
+				rsp, err := client.RoundTrip(request)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(rsp.StatusCode).To(Equal(200))
+
+				var pushed *http.Response
+				Eventually(pushes).Should(Receive(&pushed))
+				Expect(pushed.StatusCode).To(Equal(200))
+				Expect(pushed.Request.Method).To(Equal("GET"))
+				Expect(pushed.Request.URL.String()).To(Equal("https://quic.clemente.io:1337/style.css"))
+				// the server is allowed to push another response
+				Eventually(func() uint64 {
+					ids := getMaxPushIDs()
+					if len(ids) == 0 {
+						return 0
+					}
+					return ids[len(ids)-1]
+				}).Should(BeEquivalentTo(maxConcurrentPushes))
+			})
+
+			It("closes the connection when the server uses a push ID larger than allowed", func() {
+				done := make(chan struct{})
+				sess.EXPECT().CloseWithError(quic.ErrorCode(errorLimitExceeded), gomock.Any()).Do(func(quic.ErrorCode, error) { close(done) })
+				sess.EXPECT().OpenStreamSync().Return(str, nil)
+				str.EXPECT().Write(gomock.Any()).AnyTimes()
+				str.EXPECT().Close()
+				str.EXPECT().Read(gomock.Any()).DoAndReturn(func([]byte) (int, error) {
+					<-done
+					return 0, errors.New("test done")
+				})
+				uniStreams <- newPushStream(maxConcurrentPushes, nil)
+				_, err := client.RoundTrip(request)
+				Expect(err).To(MatchError("test done"))
+			})
+		})
+
 		Context("validating the address", func() {
 			It("refuses to do requests for the wrong host", func() {
 				req, err := http.NewRequest("https", "https://quic.clemente.io:1336/foobar.html", nil)
@@ -255,6 +597,21 @@ var _ = Describe("Client", func() {
 				Expect(hfs).To(HaveKeyWithValue(":path", "/upload"))
 			})
 
+			It("sets the priority of the request stream", func() {
+				request.Header.Set("Priority", "u=1, i")
+				done := make(chan struct{})
+				str.EXPECT().SetPriority(quic.StreamPriority{Urgency: 1, Incremental: true})
+				str.EXPECT().Close().Do(func() { close(done) })
+				str.EXPECT().Read(gomock.Any()).DoAndReturn(func([]byte) (int, error) {
+					<-done
+					return 0, errors.New("test done")
+				})
+				_, err := client.RoundTrip(request)
+				Expect(err).To(MatchError("test done"))
+				hfs := decodeHeader(strBuf)
+				Expect(hfs).To(HaveKeyWithValue("priority", "u=1, i"))
+			})
+
 			It("returns the error that occurred when reading the body", func() {
 				request.Body.(*mockBody).readErr = errors.New("testErr")
 				done := make(chan struct{})
@@ -271,6 +628,98 @@ var _ = Describe("Client", func() {
 			})
 		})
 
+		Context("request cancellation", func() {
+			var (
+				ctx    context.Context
+				cancel context.CancelFunc
+			)
+
+			BeforeEach(func() {
+				ctx, cancel = context.WithCancel(context.Background())
+				request = request.WithContext(ctx)
+			})
+
+			It("cancels a request while waiting for the response", func() {
+				canceled := make(chan struct{})
+				sess.EXPECT().OpenStreamSync().Return(str, nil)
+				str.EXPECT().Write(gomock.Any()).AnyTimes()
+				str.EXPECT().Close()
+				str.EXPECT().CancelWrite(quic.ErrorCode(errorRequestCanceled))
+				str.EXPECT().CancelRead(quic.ErrorCode(errorRequestCanceled)).Do(func(quic.ErrorCode) { close(canceled) })
+				str.EXPECT().Read(gomock.Any()).DoAndReturn(func([]byte) (int, error) {
+					cancel()
+					<-canceled
+					return 0, errors.New("read canceled")
+				})
+				_, err := client.RoundTrip(request)
+				Expect(err).To(MatchError(context.Canceled))
+			})
+
+			It("cancels a request after the response was received", func() {
+				rspBuf := &bytes.Buffer{}
+				newResponseWriter(rspBuf, newQPACKEncoder(0, nil), 0, utils.DefaultLogger).WriteHeader(200)
+				canceled := make(chan struct{})
+				sess.EXPECT().OpenStreamSync().Return(str, nil)
+				str.EXPECT().Write(gomock.Any()).AnyTimes()
+				str.EXPECT().Close()
+				str.EXPECT().CancelWrite(quic.ErrorCode(errorRequestCanceled))
+				str.EXPECT().CancelRead(quic.ErrorCode(errorRequestCanceled)).Do(func(quic.ErrorCode) { close(canceled) })
+				str.EXPECT().Read(gomock.Any()).DoAndReturn(func(p []byte) (int, error) {
+					if rspBuf.Len() > 0 {
+						return rspBuf.Read(p)
+					}
+					<-canceled
+					return 0, errors.New("read canceled")
+				}).AnyTimes()
+				rsp, err := client.RoundTrip(request)
+				Expect(err).ToNot(HaveOccurred())
+				cancel()
+				_, err = rsp.Body.Read([]byte{0})
+				Expect(err).To(MatchError("read canceled"))
+			})
+
+			It("doesn't cancel a request after the response body was closed", func() {
+				rspBuf := &bytes.Buffer{}
+				newResponseWriter(rspBuf, newQPACKEncoder(0, nil), 0, utils.DefaultLogger).WriteHeader(200)
+				sess.EXPECT().OpenStreamSync().Return(str, nil)
+				str.EXPECT().Write(gomock.Any()).AnyTimes()
+				str.EXPECT().Close()
+				str.EXPECT().Read(gomock.Any()).DoAndReturn(rspBuf.Read).AnyTimes()
+				rsp, err := client.RoundTrip(request)
+				Expect(err).ToNot(HaveOccurred())
+				str.EXPECT().CancelRead(quic.ErrorCode(0))
+				Expect(rsp.Body.Close()).To(Succeed())
+				cancel()
+				time.Sleep(10 * time.Millisecond) // make sure the stream is not canceled
+			})
+
+			It("cancels a request while opening the stream", func() {
+				openStream := make(chan struct{})
+				sess.EXPECT().OpenStreamSync().DoAndReturn(func() (quic.Stream, error) {
+					<-openStream
+					return str, nil
+				})
+				canceled := make(chan struct{})
+				str.EXPECT().CancelWrite(quic.ErrorCode(errorRequestCanceled))
+				str.EXPECT().CancelRead(quic.ErrorCode(errorRequestCanceled)).Do(func(quic.ErrorCode) { close(canceled) })
+				go func() {
+					time.Sleep(10 * time.Millisecond)
+					cancel()
+				}()
+				_, err := client.RoundTrip(request)
+				Expect(err).To(MatchError(context.Canceled))
+				// the stream is canceled as soon as it is opened
+				close(openStream)
+				Eventually(canceled).Should(BeClosed())
+			})
+
+			It("doesn't open a stream if the context is already canceled", func() {
+				cancel()
+				_, err := client.RoundTrip(request)
+				Expect(err).To(MatchError(context.Canceled))
+			})
+		})
+
 		Context("gzip compression", func() {
 			var gzippedData []byte // a gzipped foobar
 			var response *http.Response
@@ -321,11 +770,12 @@ var _ = Describe("Client", func() {
 			It("decompresses the response", func() {
 				sess.EXPECT().OpenStreamSync().Return(str, nil)
 				buf := &bytes.Buffer{}
-				rw := newResponseWriter(buf, utils.DefaultLogger)
+				rw := newResponseWriter(buf, newQPACKEncoder(0, nil), 0, utils.DefaultLogger)
 				rw.Header().Set("Content-Encoding", "gzip")
 				gz := gzip.NewWriter(rw)
 				gz.Write([]byte("gzipped response"))
 				gz.Close()
+				rw.Flush()
 				str.EXPECT().Write(gomock.Any()).AnyTimes()
 				str.EXPECT().Read(gomock.Any()).DoAndReturn(func(p []byte) (int, error) {
 					return buf.Read(p)
@@ -345,8 +795,9 @@ var _ = Describe("Client", func() {
 			It("only decompresses the response if the response contains the right content-encoding header", func() {
 				sess.EXPECT().OpenStreamSync().Return(str, nil)
 				buf := &bytes.Buffer{}
-				rw := newResponseWriter(buf, utils.DefaultLogger)
+				rw := newResponseWriter(buf, newQPACKEncoder(0, nil), 0, utils.DefaultLogger)
 				rw.Write([]byte("not gzipped"))
+				rw.Flush()
 				str.EXPECT().Write(gomock.Any()).AnyTimes()
 				str.EXPECT().Read(gomock.Any()).DoAndReturn(func(p []byte) (int, error) {
 					return buf.Read(p)
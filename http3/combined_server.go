@@ -0,0 +1,146 @@
+package http3
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"sync/atomic"
+)
+
+// CombinedServer serves HTTP/1.1 and HTTP/2 over TCP and HTTP/3 over QUIC, on the same address.
+// Responses sent over TCP carry an Alt-Svc header, announcing that the server supports HTTP/3.
+type CombinedServer struct {
+	// TCPServer serves HTTP/1.1 and HTTP/2 over TLS.
+	TCPServer *http.Server
+	// QUICServer serves HTTP/3.
+	QUICServer *Server
+
+	inShutdown int32 // used atomically
+}
+
+// NewCombinedServer creates a new CombinedServer for the given address.
+// http.DefaultServeMux is used when handler is nil.
+func NewCombinedServer(addr string, tlsConf *tls.Config, handler http.Handler) *CombinedServer {
+	if handler == nil {
+		handler = http.DefaultServeMux
+	}
+	quicServer := &Server{
+		Server: &http.Server{
+			Addr:      addr,
+			TLSConfig: tlsConf,
+			Handler:   handler,
+		},
+	}
+	tcpServer := &http.Server{
+		Addr:      addr,
+		TLSConfig: tlsConf,
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			quicServer.SetQuicHeaders(w.Header())
+			handler.ServeHTTP(w, r)
+		}),
+	}
+	return &CombinedServer{
+		TCPServer:  tcpServer,
+		QUICServer: quicServer,
+	}
+}
+
+// ListenAndServeTLS listens on the TCP and the UDP address s.TCPServer.Addr,
+// and serves requests using the certificate and key in certFile and keyFile.
+func (s *CombinedServer) ListenAndServeTLS(certFile, keyFile string) error {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return err
+	}
+	config := &tls.Config{}
+	if s.TCPServer.TLSConfig != nil {
+		config = s.TCPServer.TLSConfig.Clone()
+	}
+	config.Certificates = []tls.Certificate{cert}
+	s.TCPServer.TLSConfig = config
+	s.QUICServer.TLSConfig = config
+
+	// Open the listeners
+	udpAddr, err := net.ResolveUDPAddr("udp", s.TCPServer.Addr)
+	if err != nil {
+		return err
+	}
+	udpConn, err := net.ListenUDP("udp", udpAddr)
+	if err != nil {
+		return err
+	}
+	defer udpConn.Close()
+
+	tcpAddr, err := net.ResolveTCPAddr("tcp", s.TCPServer.Addr)
+	if err != nil {
+		return err
+	}
+	tcpLn, err := net.ListenTCP("tcp", tcpAddr)
+	if err != nil {
+		return err
+	}
+	defer tcpLn.Close()
+
+	return s.Serve(tcpLn, udpConn)
+}
+
+// Serve accepts TLS connections on tcpLn and QUIC connections on udpConn.
+// Both servers use the tls.Config of the respective server, which must contain a certificate.
+// If one of the servers fails, the other one is closed, and the error is returned.
+// After Shutdown or Close, the returned error is http.ErrServerClosed.
+func (s *CombinedServer) Serve(tcpLn net.Listener, udpConn net.PacketConn) error {
+	hErr := make(chan error, 1)
+	qErr := make(chan error, 1)
+	go func() {
+		hErr <- s.TCPServer.ServeTLS(tcpLn, "", "")
+	}()
+	go func() {
+		qErr <- s.QUICServer.Serve(udpConn)
+	}()
+
+	var err error
+	select {
+	case err = <-hErr:
+		if s.shuttingDown() {
+			return http.ErrServerClosed
+		}
+		s.QUICServer.Close()
+	case err = <-qErr:
+		if s.shuttingDown() {
+			return http.ErrServerClosed
+		}
+		s.TCPServer.Close()
+	}
+	return err
+}
+
+// Shutdown gracefully shuts down both servers.
+// It stops accepting new connections on the TCP server, and sends a GOAWAY frame on all HTTP/3 connections.
+// It then waits for all requests to complete, or until ctx expires, whichever happens first.
+func (s *CombinedServer) Shutdown(ctx context.Context) error {
+	atomic.StoreInt32(&s.inShutdown, 1)
+	qErr := make(chan error, 1)
+	go func() {
+		qErr <- s.QUICServer.Shutdown(ctx)
+	}()
+	err := s.TCPServer.Shutdown(ctx)
+	if qerr := <-qErr; err == nil {
+		err = qerr
+	}
+	return err
+}
+
+// Close immediately closes both servers.
+func (s *CombinedServer) Close() error {
+	atomic.StoreInt32(&s.inShutdown, 1)
+	err := s.TCPServer.Close()
+	if qerr := s.QUICServer.Close(); err == nil {
+		err = qerr
+	}
+	return err
+}
+
+func (s *CombinedServer) shuttingDown() bool {
+	return atomic.LoadInt32(&s.inShutdown) != 0
+}
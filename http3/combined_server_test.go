@@ -0,0 +1,79 @@
+package http3
+
+import (
+	"context"
+	"crypto/tls"
+	"io/ioutil"
+	"net"
+	"net/http"
+
+	"github.com/lucas-clemente/quic-go/internal/testdata"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Combined Server", func() {
+	var (
+		s        *CombinedServer
+		tcpLn    net.Listener
+		udpConn  net.PacketConn
+		serveErr chan error
+	)
+
+	BeforeEach(func() {
+		var err error
+		tcpLn, err = net.Listen("tcp", "localhost:0")
+		Expect(err).ToNot(HaveOccurred())
+		udpConn, err = net.ListenPacket("udp", "localhost:0")
+		Expect(err).ToNot(HaveOccurred())
+		s = NewCombinedServer(tcpLn.Addr().String(), testdata.GetTLSConfig(), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("foobar"))
+		}))
+		serveErr = make(chan error, 1)
+		go func() { serveErr <- s.Serve(tcpLn, udpConn) }()
+	})
+
+	AfterEach(func() {
+		s.Close()
+		udpConn.Close()
+	})
+
+	get := func() *http.Response {
+		client := &http.Client{
+			Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}},
+		}
+		_, port, err := net.SplitHostPort(tcpLn.Addr().String())
+		Expect(err).ToNot(HaveOccurred())
+		rsp, err := client.Get("https://localhost:" + port)
+		Expect(err).ToNot(HaveOccurred())
+		return rsp
+	}
+
+	It("announces HTTP/3 on TCP responses", func() {
+		rsp := get()
+		defer rsp.Body.Close()
+		body, err := ioutil.ReadAll(rsp.Body)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(body).To(Equal([]byte("foobar")))
+		_, port, err := net.SplitHostPort(tcpLn.Addr().String())
+		Expect(err).ToNot(HaveOccurred())
+		Expect(rsp.Header.Get("Alt-Svc")).To(Equal(`h3-19=":` + port + `"; ma=2592000`))
+	})
+
+	It("shuts down both servers", func() {
+		get().Body.Close()
+		Expect(s.Shutdown(context.Background())).To(Succeed())
+		Eventually(serveErr).Should(Receive(Equal(http.ErrServerClosed)))
+	})
+
+	It("closes the QUIC server when the TCP server fails", func() {
+		// make sure the servers are running
+		get().Body.Close()
+		tcpLn.Close()
+		var err error
+		Eventually(serveErr).Should(Receive(&err))
+		Expect(err).ToNot(Equal(http.ErrServerClosed))
+		Expect(s.QUICServer.Serve(udpConn)).To(MatchError("Server is already closed"))
+	})
+})
@@ -31,6 +31,11 @@ const (
 	errorUnexpectedFrame        errorCode = 0x13
 	errorRequestRejected        errorCode = 0x14
 	errorGeneralProtocolError   errorCode = 0xff
+	errorMalformedFrame         errorCode = 0x100
+
+	errorQPACKDecompressionFailed errorCode = 0x200
+	errorQPACKEncoderStreamError  errorCode = 0x201
+	errorQPACKDecoderStreamError  errorCode = 0x202
 )
 
 func (e errorCode) String() string {
@@ -79,10 +84,21 @@ func (e errorCode) String() string {
 		return "HTTP_REQUEST_REJECTED"
 	case errorGeneralProtocolError:
 		return "HTTP_GENERAL_PROTOCOL_ERROR"
+	case errorQPACKDecompressionFailed:
+		return "HTTP_QPACK_DECOMPRESSION_FAILED"
+	case errorQPACKEncoderStreamError:
+		return "HTTP_QPACK_ENCODER_STREAM_ERROR"
+	case errorQPACKDecoderStreamError:
+		return "HTTP_QPACK_DECODER_STREAM_ERROR"
 	default:
-		if e >= 0x100 && e < 0x200 {
-			return fmt.Sprintf("HTTP_MALFORMED_FRAME: %#x", uint16(e-0x100))
+		if e >= errorMalformedFrame && e < errorMalformedFrame+0x100 {
+			return fmt.Sprintf("HTTP_MALFORMED_FRAME: %#x", uint16(e-errorMalformedFrame))
 		}
 		return fmt.Sprintf("unknown error code: %#x", uint16(e))
 	}
 }
+
+// errorMalformedFrameType returns the HTTP_MALFORMED_FRAME error code for a frame of the given type.
+func errorMalformedFrameType(frameType uint64) errorCode {
+	return errorMalformedFrame + errorCode(frameType)
+}
@@ -37,6 +37,10 @@ var _ = Describe("error codes", func() {
 		Expect(errorCode(0x142).String()).To(Equal("HTTP_MALFORMED_FRAME: 0x42"))
 	})
 
+	It("calculates the error code for frame parsing errors", func() {
+		Expect(errorMalformedFrameType(0xd).String()).To(Equal("HTTP_MALFORMED_FRAME: 0xd"))
+	})
+
 	It("has a string representation for unknown error codes", func() {
 		Expect(errorCode(0x1337).String()).To(Equal("unknown error code: 0x1337"))
 	})
@@ -2,6 +2,7 @@ package http3
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -27,6 +28,21 @@ func (br *byteReaderImpl) ReadByte() (byte, error) {
 
 type frame interface{}
 
+// A malformedFrameError is returned when the contents of a frame can't be parsed.
+// It results in a HTTP_MALFORMED_FRAME error for the respective frame type.
+type malformedFrameError struct {
+	FrameType uint64
+	Err       error
+}
+
+func (e *malformedFrameError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *malformedFrameError) ErrorCode() errorCode {
+	return errorMalformedFrameType(e.FrameType)
+}
+
 func parseNextFrame(b io.Reader) (frame, error) {
 	br, ok := b.(byteReader)
 	if !ok {
@@ -48,18 +64,34 @@ func parseNextFrame(b io.Reader) (frame, error) {
 		return &headersFrame{Length: l}, nil
 	case 0x4:
 		return parseSettingsFrame(br, l)
+	case 0x3:
+		pushID, err := parseVarIntFrame(br, t, l)
+		if err != nil {
+			return nil, err
+		}
+		return &cancelPushFrame{PushID: pushID}, nil
+	case 0x5:
+		return parsePushPromiseFrame(br, l)
+	case 0x7:
+		id, err := parseVarIntFrame(br, t, l)
+		if err != nil {
+			return nil, err
+		}
+		return &goAwayFrame{StreamID: protocol.StreamID(id)}, nil
+	case 0xd:
+		pushID, err := parseVarIntFrame(br, t, l)
+		if err != nil {
+			return nil, err
+		}
+		return &maxPushIDFrame{PushID: pushID}, nil
+	case 0xe:
+		pushID, err := parseVarIntFrame(br, t, l)
+		if err != nil {
+			return nil, err
+		}
+		return &duplicatePushFrame{PushID: pushID}, nil
 	case 0x2: // PRIORITY
 		fallthrough
-	case 0x3: // CANCEL_PUSH
-		fallthrough
-	case 0x5: // PUSH_PROMISE
-		fallthrough
-	case 0x7: // GOAWAY
-		fallthrough
-	case 0xd: // MAX_PUSH_ID
-		fallthrough
-	case 0xe: // DUPLICATE_PUSH
-		fallthrough
 	default:
 		// skip over unknown frames
 		if _, err := io.CopyN(ioutil.Discard, br, int64(l)); err != nil {
@@ -87,13 +119,16 @@ func (f *headersFrame) Write(b *bytes.Buffer) {
 	utils.WriteVarInt(b, f.Length)
 }
 
+// settingMaxHeaderListSize is the SETTINGS_MAX_HEADER_LIST_SIZE setting.
+const settingMaxHeaderListSize = 0x6
+
 type settingsFrame struct {
 	settings map[uint64]uint64
 }
 
 func parseSettingsFrame(r io.Reader, l uint64) (*settingsFrame, error) {
 	if l > 8*(1<<10) {
-		return nil, fmt.Errorf("unexpected size for SETTINGS frame: %d", l)
+		return nil, &malformedFrameError{FrameType: 0x4, Err: fmt.Errorf("unexpected size for SETTINGS frame: %d", l)}
 	}
 	buf := make([]byte, l)
 	if _, err := io.ReadFull(r, buf); err != nil {
@@ -114,7 +149,7 @@ func parseSettingsFrame(r io.Reader, l uint64) (*settingsFrame, error) {
 			return nil, err
 		}
 		if _, ok := frame.settings[id]; ok {
-			return nil, fmt.Errorf("duplicate setting: %d", id)
+			return nil, &malformedFrameError{FrameType: 0x4, Err: fmt.Errorf("duplicate setting: %d", id)}
 		}
 		frame.settings[id] = val
 	}
@@ -133,3 +168,87 @@ func (f *settingsFrame) Write(b *bytes.Buffer) {
 		utils.WriteVarInt(b, val)
 	}
 }
+
+// parseVarIntFrame parses the payload of a frame that consists of a single variable-length integer,
+// like GOAWAY, CANCEL_PUSH, MAX_PUSH_ID and DUPLICATE_PUSH frames.
+func parseVarIntFrame(r io.Reader, t, l uint64) (uint64, error) {
+	if l > 8 {
+		return 0, &malformedFrameError{FrameType: t, Err: fmt.Errorf("unexpected size for frame of type %#x: %d", t, l)}
+	}
+	buf := make([]byte, l)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return 0, io.EOF
+		}
+		return 0, err
+	}
+	b := bytes.NewReader(buf)
+	val, err := utils.ReadVarInt(b)
+	if err != nil || b.Len() > 0 {
+		return 0, &malformedFrameError{FrameType: t, Err: fmt.Errorf("invalid frame of type %#x", t)}
+	}
+	return val, nil
+}
+
+func writeVarIntFrame(b *bytes.Buffer, t, val uint64) {
+	utils.WriteVarInt(b, t)
+	utils.WriteVarInt(b, uint64(utils.VarIntLen(val)))
+	utils.WriteVarInt(b, val)
+}
+
+type goAwayFrame struct {
+	StreamID protocol.StreamID
+}
+
+func (f *goAwayFrame) Write(b *bytes.Buffer) {
+	writeVarIntFrame(b, 0x7, uint64(f.StreamID))
+}
+
+type cancelPushFrame struct {
+	PushID uint64
+}
+
+func (f *cancelPushFrame) Write(b *bytes.Buffer) {
+	writeVarIntFrame(b, 0x3, f.PushID)
+}
+
+type maxPushIDFrame struct {
+	PushID uint64
+}
+
+func (f *maxPushIDFrame) Write(b *bytes.Buffer) {
+	writeVarIntFrame(b, 0xd, f.PushID)
+}
+
+type duplicatePushFrame struct {
+	PushID uint64
+}
+
+func (f *duplicatePushFrame) Write(b *bytes.Buffer) {
+	writeVarIntFrame(b, 0xe, f.PushID)
+}
+
+// A pushPromiseFrame is followed by the header block of the promised request.
+// Length is the length of that header block.
+type pushPromiseFrame struct {
+	PushID uint64
+	Length uint64
+}
+
+func parsePushPromiseFrame(r byteReader, l uint64) (*pushPromiseFrame, error) {
+	pushID, err := utils.ReadVarInt(r)
+	if err != nil {
+		return nil, err
+	}
+	idLen := uint64(utils.VarIntLen(pushID))
+	if idLen > l {
+		return nil, &malformedFrameError{FrameType: 0x5, Err: errors.New("PUSH_PROMISE frame too short")}
+	}
+	return &pushPromiseFrame{PushID: pushID, Length: l - idLen}, nil
+}
+
+func (f *pushPromiseFrame) Write(b *bytes.Buffer) {
+	utils.WriteVarInt(b, 0x5)
+	utils.WriteVarInt(b, uint64(utils.VarIntLen(f.PushID))+f.Length)
+	utils.WriteVarInt(b, f.PushID)
+}
@@ -98,6 +98,8 @@ var _ = Describe("Frames", func() {
 			data = append(data, settings...)
 			_, err := parseNextFrame(bytes.NewReader(data))
 			Expect(err).To(MatchError("duplicate setting: 13"))
+			Expect(err).To(BeAssignableToTypeOf(&malformedFrameError{}))
+			Expect(err.(*malformedFrameError).ErrorCode()).To(Equal(errorMalformedFrameType(0x4)))
 		})
 
 		It("writes", func() {
@@ -133,4 +135,100 @@ var _ = Describe("Frames", func() {
 			}
 		})
 	})
+
+	Context("GOAWAY frames", func() {
+		It("parses", func() {
+			data := appendVarInt(nil, 7) // type byte
+			data = appendVarInt(data, uint64(utils.VarIntLen(100)))
+			data = appendVarInt(data, 100)
+			frame, err := parseNextFrame(bytes.NewReader(data))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(frame).To(Equal(&goAwayFrame{StreamID: 100}))
+		})
+
+		It("writes", func() {
+			buf := &bytes.Buffer{}
+			(&goAwayFrame{StreamID: 0x1337}).Write(buf)
+			frame, err := parseNextFrame(buf)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(frame).To(Equal(&goAwayFrame{StreamID: 0x1337}))
+		})
+
+		It("errors on EOF", func() {
+			buf := &bytes.Buffer{}
+			(&goAwayFrame{StreamID: 0xdeadbeef}).Write(buf)
+			data := buf.Bytes()
+			for i := range data {
+				_, err := parseNextFrame(bytes.NewReader(data[:i]))
+				Expect(err).To(MatchError(io.EOF))
+			}
+		})
+
+		It("rejects frames with trailing data", func() {
+			data := appendVarInt(nil, 7) // type byte
+			data = appendVarInt(data, 2)
+			data = appendVarInt(data, 4)
+			data = append(data, 0)
+			_, err := parseNextFrame(bytes.NewReader(data))
+			Expect(err).To(BeAssignableToTypeOf(&malformedFrameError{}))
+			Expect(err.(*malformedFrameError).ErrorCode()).To(Equal(errorMalformedFrameType(0x7)))
+		})
+	})
+
+	Context("push frames", func() {
+		It("writes and parses CANCEL_PUSH frames", func() {
+			buf := &bytes.Buffer{}
+			(&cancelPushFrame{PushID: 0x1337}).Write(buf)
+			frame, err := parseNextFrame(buf)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(frame).To(Equal(&cancelPushFrame{PushID: 0x1337}))
+		})
+
+		It("writes and parses MAX_PUSH_ID frames", func() {
+			buf := &bytes.Buffer{}
+			(&maxPushIDFrame{PushID: 0xdeadbeef}).Write(buf)
+			frame, err := parseNextFrame(buf)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(frame).To(Equal(&maxPushIDFrame{PushID: 0xdeadbeef}))
+		})
+
+		It("writes and parses DUPLICATE_PUSH frames", func() {
+			buf := &bytes.Buffer{}
+			(&duplicatePushFrame{PushID: 42}).Write(buf)
+			frame, err := parseNextFrame(buf)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(frame).To(Equal(&duplicatePushFrame{PushID: 42}))
+		})
+
+		It("writes and parses PUSH_PROMISE frames", func() {
+			buf := &bytes.Buffer{}
+			(&pushPromiseFrame{PushID: 0x1337, Length: 100}).Write(buf)
+			buf.Write([]byte("foobar"))
+			frame, err := parseNextFrame(buf)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(frame).To(Equal(&pushPromiseFrame{PushID: 0x1337, Length: 100}))
+			// the header block is not consumed
+			Expect(buf.String()).To(Equal("foobar"))
+		})
+
+		It("errors on EOF", func() {
+			buf := &bytes.Buffer{}
+			(&pushPromiseFrame{PushID: 0x1337, Length: 100}).Write(buf)
+			data := buf.Bytes()
+			for i := range data {
+				_, err := parseNextFrame(bytes.NewReader(data[:i]))
+				Expect(err).To(MatchError(io.EOF))
+			}
+		})
+
+		It("rejects MAX_PUSH_ID frames with trailing data", func() {
+			data := appendVarInt(nil, 0xd) // type byte
+			data = appendVarInt(data, 2)
+			data = appendVarInt(data, 4)
+			data = append(data, 0)
+			_, err := parseNextFrame(bytes.NewReader(data))
+			Expect(err).To(BeAssignableToTypeOf(&malformedFrameError{}))
+			Expect(err.(*malformedFrameError).ErrorCode()).To(Equal(errorMalformedFrameType(0xd)))
+		})
+	})
 })
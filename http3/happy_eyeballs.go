@@ -0,0 +1,353 @@
+package http3
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	quic "github.com/lucas-clemente/quic-go"
+	"github.com/lucas-clemente/quic-go/internal/utils"
+
+	"golang.org/x/net/http2"
+)
+
+const (
+	// defaultFallbackDelay is the head start that QUIC gets before a request is sent over TCP.
+	defaultFallbackDelay = 300 * time.Millisecond
+	// defaultAltSvcClientMaxAge is used if an Alt-Svc header doesn't contain a max-age (see RFC 7838, section 3.1).
+	defaultAltSvcClientMaxAge = 24 * time.Hour
+	// quicBrokenDuration is the time that HTTP/3 isn't used for an origin, after a QUIC connection attempt failed.
+	quicBrokenDuration = 5 * time.Minute
+)
+
+// altService is an alternative service announced by an origin using the Alt-Svc header.
+type altService struct {
+	protocolID string
+	host       string // might be empty, if the alternative service is located on the same host
+	port       string
+	maxAge     time.Duration
+}
+
+// parseAltSvc parses the values of the Alt-Svc header (see RFC 7838, section 3).
+// It returns the first alternative service that offers a supported version of HTTP/3.
+// If the origin cleared all alternative services, clear is true.
+func parseAltSvc(values []string) (alt *altService, clear bool) {
+	for _, value := range values {
+		for _, entry := range strings.Split(value, ",") {
+			entry = strings.TrimSpace(entry)
+			if entry == "clear" {
+				return nil, true
+			}
+			if alt != nil {
+				continue
+			}
+			params := strings.Split(entry, ";")
+			a, ok := parseAltValue(params[0])
+			if !ok {
+				continue
+			}
+			a.maxAge = defaultAltSvcClientMaxAge
+			for _, param := range params[1:] {
+				kv := strings.SplitN(strings.TrimSpace(param), "=", 2)
+				if len(kv) != 2 || kv[0] != "ma" {
+					continue
+				}
+				if ma, err := strconv.ParseUint(strings.Trim(kv[1], `"`), 10, 32); err == nil {
+					a.maxAge = time.Duration(ma) * time.Second
+				}
+			}
+			alt = a
+		}
+	}
+	return alt, false
+}
+
+// parseAltValue parses a protocol-id="alt-authority" pair.
+// It only accepts supported versions of HTTP/3.
+func parseAltValue(s string) (*altService, bool) {
+	kv := strings.SplitN(strings.TrimSpace(s), "=", 2)
+	if len(kv) != 2 {
+		return nil, false
+	}
+	var supported bool
+	for _, proto := range supportedNextProtos {
+		if kv[0] == proto {
+			supported = true
+			break
+		}
+	}
+	if !supported {
+		return nil, false
+	}
+	host, port, err := net.SplitHostPort(strings.Trim(kv[1], `"`))
+	if err != nil || port == "" {
+		return nil, false
+	}
+	return &altService{protocolID: kv[0], host: host, port: port}, true
+}
+
+// originState is the HTTP/3 state of an origin.
+type originState struct {
+	addr    string    // the address of the HTTP/3 endpoint, as announced in the Alt-Svc header
+	expires time.Time // when the Alt-Svc entry expires
+
+	connecting  bool      // a QUIC connection is currently being established
+	connected   bool      // a QUIC connection was established
+	brokenUntil time.Time // HTTP/3 is not used until then, since the last connection attempt failed
+}
+
+// markBroken stops using HTTP/3 for the origin for a while, after the QUIC connection failed.
+func (s *originState) markBroken() {
+	s.connected = false
+	s.brokenUntil = time.Now().Add(quicBrokenDuration)
+}
+
+// HappyEyeballsRoundTripper is a http.RoundTripper that uses HTTP/3 for origins that announce support for it using the Alt-Svc header.
+// Requests to other origins are sent over TCP, using HTTP/2 (or HTTP/1.1, if the server doesn't support HTTP/2).
+// The first time an origin is contacted after learning that it supports HTTP/3, establishing a QUIC connection is raced against TCP:
+// QUIC gets a head start of FallbackDelay. If the handshake doesn't complete within that time (e.g. because UDP is blocked),
+// the request is sent over TCP, and HTTP/3 is used for later requests once the handshake completes.
+// If the handshake fails, or a request can't be sent on an established QUIC connection, HTTP/3 is not used for that origin for a while.
+// Such requests are retried over TCP.
+// The protocol used for a request can be determined from the Proto field of the response.
+type HappyEyeballsRoundTripper struct {
+	// TLSClientConfig specifies the TLS configuration used for both QUIC and TCP connections.
+	// If nil, the default configuration is used.
+	TLSClientConfig *tls.Config
+
+	// QuicConfig is the quic.Config used for dialing new QUIC connections.
+	// If nil, reasonable default values will be used.
+	QuicConfig *quic.Config
+
+	// FallbackDelay is the time to wait for the QUIC handshake to complete, before sending a request over TCP.
+	// If zero, a default delay of 300 milliseconds is used.
+	FallbackDelay time.Duration
+
+	// TCPRoundTripper is used to send requests over TCP.
+	// If nil, a http.Transport that supports HTTP/2 is used.
+	TCPRoundTripper http.RoundTripper
+
+	initOnce sync.Once
+	h3       *RoundTripper
+	tcp      http.RoundTripper
+
+	mutex   sync.Mutex
+	origins map[string]*originState
+
+	logger utils.Logger
+}
+
+var _ http.RoundTripper = &HappyEyeballsRoundTripper{}
+
+func (r *HappyEyeballsRoundTripper) init() {
+	r.initOnce.Do(func() {
+		r.logger = utils.DefaultLogger.WithPrefix("happy eyeballs")
+		r.origins = make(map[string]*originState)
+		r.tcp = r.TCPRoundTripper
+		if r.tcp == nil {
+			t := &http.Transport{TLSClientConfig: r.tlsConfig()}
+			// ConfigureTransport only fails if the transport already supports HTTP/2.
+			http2.ConfigureTransport(t)
+			r.tcp = t
+		}
+		r.h3 = &RoundTripper{
+			TLSClientConfig: r.tlsConfig(),
+			QuicConfig:      r.QuicConfig,
+			Dial:            r.dialQUIC,
+		}
+	})
+}
+
+func (r *HappyEyeballsRoundTripper) tlsConfig() *tls.Config {
+	if r.TLSClientConfig == nil {
+		return &tls.Config{}
+	}
+	return r.TLSClientConfig.Clone()
+}
+
+// RoundTrip does a round trip.
+func (r *HappyEyeballsRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	r.init()
+	if req.URL == nil || req.URL.Scheme != "https" {
+		return r.tcp.RoundTrip(req)
+	}
+	origin := authorityAddr("https", hostnameFromRequest(req))
+
+	r.mutex.Lock()
+	state := r.getOriginState(origin)
+	var useH3, race bool
+	if state != nil && time.Now().After(state.brokenUntil) {
+		if state.connected {
+			useH3 = true
+		} else if !state.connecting {
+			state.connecting = true
+			race = true
+		}
+	}
+	r.mutex.Unlock()
+
+	switch {
+	case useH3:
+		return r.roundTripH3(origin, req)
+	case race:
+		return r.race(origin, req)
+	default:
+		return r.roundTripTCP(origin, req)
+	}
+}
+
+// getOriginState returns the state of origin, or nil if it didn't announce HTTP/3 support.
+// It must be called with the mutex held.
+func (r *HappyEyeballsRoundTripper) getOriginState(origin string) *originState {
+	state, ok := r.origins[origin]
+	if !ok {
+		return nil
+	}
+	// Keep using an established connection, even if the Alt-Svc entry expired.
+	if !state.connected && !state.connecting && time.Now().After(state.expires) {
+		delete(r.origins, origin)
+		return nil
+	}
+	return state
+}
+
+// race establishes a QUIC connection to origin, while giving it a head start over TCP.
+func (r *HappyEyeballsRoundTripper) race(origin string, req *http.Request) (*http.Response, error) {
+	connected := make(chan error, 1)
+	go func() {
+		err := r.h3.dial(origin)
+		r.mutex.Lock()
+		if state, ok := r.origins[origin]; ok {
+			state.connecting = false
+			if err == nil {
+				state.connected = true
+			} else {
+				state.markBroken()
+			}
+		}
+		r.mutex.Unlock()
+		if err != nil {
+			r.logger.Debugf("Establishing a QUIC connection to %s failed: %s", origin, err)
+		}
+		connected <- err
+	}()
+
+	delay := r.FallbackDelay
+	if delay == 0 {
+		delay = defaultFallbackDelay
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case err := <-connected:
+		if err == nil {
+			return r.roundTripH3(origin, req)
+		}
+	case <-timer.C:
+		// QUIC lost the race. It will be used for later requests, once the handshake completes.
+	case <-req.Context().Done():
+		return nil, req.Context().Err()
+	}
+	return r.roundTripTCP(origin, req)
+}
+
+// roundTripH3 sends a request over an established QUIC connection.
+// If the request wasn't processed, because the connection failed or the server is going away,
+// HTTP/3 is not used for the origin for a while, and the request is retried over TCP.
+func (r *HappyEyeballsRoundTripper) roundTripH3(origin string, req *http.Request) (*http.Response, error) {
+	rsp, err := r.h3.roundTripOpt(req, RoundTripOpt{})
+	if err == nil {
+		r.handleAltSvc(origin, rsp.Header["Alt-Svc"])
+		return rsp, nil
+	}
+	if err == errGoAway {
+		r.logger.Debugf("%s is going away. Retrying the request over TCP.", origin)
+		req, err = rewindBody(req)
+		if err != nil {
+			return nil, err
+		}
+	} else if e, ok := err.(*requestNotSentError); ok {
+		// The request body wasn't read, so the request can be sent as is.
+		r.logger.Debugf("Sending the request to %s over HTTP/3 failed: %s. Retrying over TCP.", origin, e.err)
+		r.h3.forgetClient(origin)
+	} else {
+		return nil, err
+	}
+	r.mutex.Lock()
+	if state, ok := r.origins[origin]; ok {
+		state.markBroken()
+	}
+	r.mutex.Unlock()
+	return r.roundTripTCP(origin, req)
+}
+
+func (r *HappyEyeballsRoundTripper) roundTripTCP(origin string, req *http.Request) (*http.Response, error) {
+	rsp, err := r.tcp.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	r.handleAltSvc(origin, rsp.Header["Alt-Svc"])
+	return rsp, nil
+}
+
+// handleAltSvc updates the HTTP/3 state of origin, when receiving an Alt-Svc header.
+func (r *HappyEyeballsRoundTripper) handleAltSvc(origin string, values []string) {
+	if len(values) == 0 {
+		return
+	}
+	alt, clear := parseAltSvc(values)
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	state, ok := r.origins[origin]
+	if clear {
+		// Established QUIC connections are not closed, since they might still be used by requests that are being processed.
+		delete(r.origins, origin)
+		return
+	}
+	if alt == nil {
+		return
+	}
+	host := alt.host
+	if host == "" {
+		host, _, _ = net.SplitHostPort(origin)
+	}
+	if !ok {
+		state = &originState{}
+		r.origins[origin] = state
+	}
+	state.addr = net.JoinHostPort(host, alt.port)
+	state.expires = time.Now().Add(alt.maxAge)
+}
+
+// dialQUIC dials the HTTP/3 endpoint of an origin.
+func (r *HappyEyeballsRoundTripper) dialQUIC(network, origin string, tlsConf *tls.Config, config *quic.Config) (quic.Session, error) {
+	r.mutex.Lock()
+	addr := origin
+	if state, ok := r.origins[origin]; ok {
+		addr = state.addr
+	}
+	r.mutex.Unlock()
+	// The alternative service must present a certificate that is valid for the origin.
+	if tlsConf.ServerName == "" {
+		host, _, err := net.SplitHostPort(origin)
+		if err != nil {
+			return nil, err
+		}
+		tlsConf = tlsConf.Clone()
+		tlsConf.ServerName = host
+	}
+	return dialAddr(addr, tlsConf, config)
+}
+
+// Close closes all QUIC connections, as well as idle TCP connections.
+func (r *HappyEyeballsRoundTripper) Close() error {
+	r.init()
+	if t, ok := r.tcp.(interface{ CloseIdleConnections() }); ok {
+		t.CloseIdleConnections()
+	}
+	return r.h3.Close()
+}
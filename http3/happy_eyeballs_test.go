@@ -0,0 +1,297 @@
+package http3
+
+import (
+	"crypto/tls"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	quic "github.com/lucas-clemente/quic-go"
+	mockquic "github.com/lucas-clemente/quic-go/internal/mocks/quic"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) { return f(req) }
+
+// h3ClientFunc is a client with an established QUIC connection.
+type h3ClientFunc func(*http.Request) (*http.Response, error)
+
+func (f h3ClientFunc) RoundTrip(req *http.Request) (*http.Response, error) { return f(req) }
+func (f h3ClientFunc) connect() error                                      { return nil }
+func (f h3ClientFunc) Close() error                                        { return nil }
+
+var _ roundTripCloser = h3ClientFunc(nil)
+
+var _ = Describe("Happy Eyeballs RoundTripper", func() {
+	Context("parsing Alt-Svc", func() {
+		It("parses an alternative on the same host", func() {
+			alt, clear := parseAltSvc([]string{`h3-19=":443"; ma=3600`})
+			Expect(clear).To(BeFalse())
+			Expect(alt).To(Equal(&altService{protocolID: "h3-19", port: "443", maxAge: time.Hour}))
+		})
+
+		It("uses the default max-age", func() {
+			alt, _ := parseAltSvc([]string{`h3-19="quic.clemente.io:8443"`})
+			Expect(alt).To(Equal(&altService{protocolID: "h3-19", host: "quic.clemente.io", port: "8443", maxAge: 24 * time.Hour}))
+		})
+
+		It("skips unsupported protocols", func() {
+			alt, _ := parseAltSvc([]string{`h2=":443", quic=":443"; ma=2592000; v="46"`, `h3-42=":443", h3-19=":1337"; persist=1`})
+			Expect(alt).ToNot(BeNil())
+			Expect(alt.port).To(Equal("1337"))
+		})
+
+		It("skips invalid values", func() {
+			alt, _ := parseAltSvc([]string{`h3-19`, `h3-19="foobar"`, `h3-19=":443"; ma=foo`})
+			Expect(alt).To(Equal(&altService{protocolID: "h3-19", port: "443", maxAge: 24 * time.Hour}))
+		})
+
+		It("parses clear", func() {
+			alt, clear := parseAltSvc([]string{"clear"})
+			Expect(alt).To(BeNil())
+			Expect(clear).To(BeTrue())
+		})
+	})
+
+	Context("doing requests", func() {
+		var (
+			rt           *HappyEyeballsRoundTripper
+			tcpRequests  chan *http.Request
+			h3Requests   chan struct{}
+			altSvc       string
+			session      *mockquic.MockSession
+			origDialAddr = dialAddr
+			testErr      = errors.New("test err")
+		)
+
+		newRequest := func() *http.Request {
+			req, err := http.NewRequest("GET", "https://quic.clemente.io/foobar.html", nil)
+			Expect(err).ToNot(HaveOccurred())
+			return req
+		}
+
+		BeforeEach(func() {
+			origDialAddr = dialAddr
+			altSvc = `h3-19=":443"`
+			tcpRequests = make(chan *http.Request, 100)
+			rt = &HappyEyeballsRoundTripper{
+				FallbackDelay: 100 * time.Millisecond,
+				TCPRoundTripper: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+					tcpRequests <- req
+					return &http.Response{
+						Proto:   "HTTP/2.0",
+						Header:  http.Header{"Alt-Svc": {altSvc}},
+						Request: req,
+					}, nil
+				}),
+			}
+			// Requests sent over HTTP/3 fail when opening the stream.
+			// That's enough to tell that HTTP/3 was used.
+			h3Requests = make(chan struct{}, 100)
+			session = mockquic.NewMockSession(mockCtrl)
+			session.EXPECT().OpenUniStreamSync().Return(nil, testErr).AnyTimes()
+			session.EXPECT().AcceptUniStream().Return(nil, testErr).AnyTimes()
+			session.EXPECT().OpenStreamSync().DoAndReturn(func() (quic.Stream, error) {
+				h3Requests <- struct{}{}
+				return nil, testErr
+			}).AnyTimes()
+			session.EXPECT().CloseWithError(gomock.Any(), gomock.Any()).AnyTimes()
+		})
+
+		AfterEach(func() {
+			dialAddr = origDialAddr
+		})
+
+		It("uses TCP if the origin didn't announce HTTP/3", func() {
+			altSvc = `h2=":443"`
+			dialAddr = func(string, *tls.Config, *quic.Config) (quic.Session, error) {
+				Fail("didn't expect any QUIC connection attempts")
+				return nil, nil
+			}
+			for i := 0; i < 2; i++ {
+				rsp, err := rt.RoundTrip(newRequest())
+				Expect(err).ToNot(HaveOccurred())
+				Expect(rsp.Proto).To(Equal("HTTP/2.0"))
+			}
+			Expect(tcpRequests).To(HaveLen(2))
+		})
+
+		It("uses HTTP/3 once the origin announced it", func() {
+			var dialedAddr string
+			var tlsConf *tls.Config
+			dialAddr = func(addr string, conf *tls.Config, _ *quic.Config) (quic.Session, error) {
+				dialedAddr = addr
+				tlsConf = conf
+				return session, nil
+			}
+			altSvc = `h3-19="alt.clemente.io:1337"`
+			_, err := rt.RoundTrip(newRequest())
+			Expect(err).ToNot(HaveOccurred())
+			Expect(tcpRequests).To(HaveLen(1))
+			_, err = rt.RoundTrip(newRequest())
+			Expect(err).ToNot(HaveOccurred())
+			Expect(h3Requests).To(HaveLen(1))
+			Expect(dialedAddr).To(Equal("alt.clemente.io:1337"))
+			Expect(tlsConf.ServerName).To(Equal("quic.clemente.io"))
+			Expect(tlsConf.NextProtos).To(Equal([]string{nextProtoH3}))
+		})
+
+		It("uses TCP while the QUIC handshake is in progress", func() {
+			handshakeDone := make(chan struct{})
+			dialAddr = func(string, *tls.Config, *quic.Config) (quic.Session, error) {
+				<-handshakeDone
+				return session, nil
+			}
+			_, err := rt.RoundTrip(newRequest())
+			Expect(err).ToNot(HaveOccurred())
+			start := time.Now()
+			rsp, err := rt.RoundTrip(newRequest())
+			Expect(err).ToNot(HaveOccurred())
+			Expect(time.Since(start)).To(BeNumerically(">=", 100*time.Millisecond))
+			Expect(rsp.Proto).To(Equal("HTTP/2.0"))
+			// no need to wait for the fallback delay, if the handshake is already in progress
+			start = time.Now()
+			_, err = rt.RoundTrip(newRequest())
+			Expect(err).ToNot(HaveOccurred())
+			Expect(time.Since(start)).To(BeNumerically("<", 100*time.Millisecond))
+			Expect(tcpRequests).To(HaveLen(3))
+			close(handshakeDone)
+			Eventually(func() int {
+				_, err := rt.RoundTrip(newRequest())
+				Expect(err).ToNot(HaveOccurred())
+				return len(h3Requests)
+			}).Should(Equal(1))
+		})
+
+		It("falls back to TCP if the QUIC handshake fails", func() {
+			dialed := make(chan struct{}, 10)
+			dialAddr = func(string, *tls.Config, *quic.Config) (quic.Session, error) {
+				dialed <- struct{}{}
+				return nil, errors.New("handshake failed")
+			}
+			for i := 0; i < 3; i++ {
+				rsp, err := rt.RoundTrip(newRequest())
+				Expect(err).ToNot(HaveOccurred())
+				Expect(rsp.Proto).To(Equal("HTTP/2.0"))
+			}
+			Expect(tcpRequests).To(HaveLen(3))
+			Expect(dialed).To(HaveLen(1))
+		})
+
+		It("retries over TCP if a request can't be sent over HTTP/3", func() {
+			dialed := make(chan struct{}, 10)
+			dialAddr = func(string, *tls.Config, *quic.Config) (quic.Session, error) {
+				dialed <- struct{}{}
+				return session, nil
+			}
+			_, err := rt.RoundTrip(newRequest())
+			Expect(err).ToNot(HaveOccurred())
+			Expect(tcpRequests).To(HaveLen(1))
+			rsp, err := rt.RoundTrip(newRequest())
+			Expect(err).ToNot(HaveOccurred())
+			Expect(rsp.Proto).To(Equal("HTTP/2.0"))
+			Expect(h3Requests).To(HaveLen(1))
+			Expect(tcpRequests).To(HaveLen(2))
+			state := rt.origins["quic.clemente.io:443"]
+			Expect(state.connected).To(BeFalse())
+			Expect(state.brokenUntil).To(BeTemporally("~", time.Now().Add(quicBrokenDuration), time.Second))
+			Expect(rt.h3.clients).To(BeEmpty())
+			// HTTP/3 is not used for a while
+			for i := 0; i < 3; i++ {
+				rsp, err := rt.RoundTrip(newRequest())
+				Expect(err).ToNot(HaveOccurred())
+				Expect(rsp.Proto).To(Equal("HTTP/2.0"))
+			}
+			Expect(h3Requests).To(HaveLen(1))
+			Expect(dialed).To(HaveLen(1))
+			// once that time has passed, a new QUIC connection is established
+			rt.mutex.Lock()
+			state.brokenUntil = time.Now().Add(-time.Second)
+			rt.mutex.Unlock()
+			_, err = rt.RoundTrip(newRequest())
+			Expect(err).ToNot(HaveOccurred())
+			Expect(dialed).To(HaveLen(2))
+			Expect(h3Requests).To(HaveLen(2))
+		})
+
+		It("doesn't retry over TCP if the request failed after it was sent over HTTP/3", func() {
+			_, err := rt.RoundTrip(newRequest())
+			Expect(err).ToNot(HaveOccurred())
+			rt.h3.clients = map[string]roundTripCloser{"quic.clemente.io:443": h3ClientFunc(func(*http.Request) (*http.Response, error) {
+				return nil, testErr
+			})}
+			_, err = rt.RoundTrip(newRequest())
+			Expect(err).To(MatchError(testErr))
+			Expect(tcpRequests).To(HaveLen(1))
+			Expect(rt.origins["quic.clemente.io:443"].connected).To(BeTrue())
+		})
+
+		It("handles Alt-Svc headers on HTTP/3 responses", func() {
+			_, err := rt.RoundTrip(newRequest())
+			Expect(err).ToNot(HaveOccurred())
+			h3AltSvc := `h3-19=":443"; ma=1234`
+			rt.h3.clients = map[string]roundTripCloser{"quic.clemente.io:443": h3ClientFunc(func(req *http.Request) (*http.Response, error) {
+				return &http.Response{
+					Proto:   "HTTP/3",
+					Header:  http.Header{"Alt-Svc": {h3AltSvc}},
+					Request: req,
+				}, nil
+			})}
+			rsp, err := rt.RoundTrip(newRequest())
+			Expect(err).ToNot(HaveOccurred())
+			Expect(rsp.Proto).To(Equal("HTTP/3"))
+			Expect(rt.origins["quic.clemente.io:443"].expires).To(BeTemporally("~", time.Now().Add(1234*time.Second), time.Second))
+			// the origin clears the Alt-Svc
+			h3AltSvc = "clear"
+			rsp, err = rt.RoundTrip(newRequest())
+			Expect(err).ToNot(HaveOccurred())
+			Expect(rsp.Proto).To(Equal("HTTP/3"))
+			Expect(rt.origins).ToNot(HaveKey("quic.clemente.io:443"))
+			altSvc = ""
+			rsp, err = rt.RoundTrip(newRequest())
+			Expect(err).ToNot(HaveOccurred())
+			Expect(rsp.Proto).To(Equal("HTTP/2.0"))
+			Expect(tcpRequests).To(HaveLen(2))
+		})
+
+		It("doesn't use HTTP/3 after the Alt-Svc entry expired", func() {
+			altSvc = `h3-19=":443"; ma=0`
+			dialAddr = func(string, *tls.Config, *quic.Config) (quic.Session, error) {
+				Fail("didn't expect any QUIC connection attempts")
+				return nil, nil
+			}
+			for i := 0; i < 2; i++ {
+				_, err := rt.RoundTrip(newRequest())
+				Expect(err).ToNot(HaveOccurred())
+			}
+			Expect(tcpRequests).To(HaveLen(2))
+		})
+
+		It("forgets about HTTP/3 when the origin clears the Alt-Svc", func() {
+			dialAddr = func(string, *tls.Config, *quic.Config) (quic.Session, error) {
+				Fail("didn't expect any QUIC connection attempts")
+				return nil, nil
+			}
+			_, err := rt.RoundTrip(newRequest())
+			Expect(err).ToNot(HaveOccurred())
+			rt.handleAltSvc("quic.clemente.io:443", []string{"clear"})
+			altSvc = ""
+			_, err = rt.RoundTrip(newRequest())
+			Expect(err).ToNot(HaveOccurred())
+			Expect(tcpRequests).To(HaveLen(2))
+		})
+
+		It("sends plain HTTP requests over TCP", func() {
+			req, err := http.NewRequest("GET", "http://quic.clemente.io/foobar.html", nil)
+			Expect(err).ToNot(HaveOccurred())
+			_, err = rt.RoundTrip(req)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(tcpRequests).To(HaveLen(1))
+		})
+	})
+})
@@ -0,0 +1,52 @@
+package http3
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	quic "github.com/lucas-clemente/quic-go"
+)
+
+// The default priority of an HTTP request, see section 4 of RFC 9218.
+const (
+	defaultUrgency = 3
+	maxUrgency     = 7
+)
+
+// priorityFromHeader parses the Priority header field, as defined in RFC 9218.
+// It returns false if the header is not set, in which case the priority of the stream shouldn't be changed.
+// Unknown and malformed parameters are ignored.
+func priorityFromHeader(hdr http.Header) (quic.StreamPriority, bool) {
+	values, ok := hdr["Priority"]
+	if !ok {
+		return quic.StreamPriority{}, false
+	}
+	prio := quic.StreamPriority{Urgency: defaultUrgency}
+	for _, member := range strings.Split(strings.Join(values, ","), ",") {
+		// strip parameters
+		if i := strings.IndexByte(member, ';'); i >= 0 {
+			member = member[:i]
+		}
+		key, value := strings.TrimSpace(member), ""
+		if i := strings.IndexByte(key, '='); i >= 0 {
+			key, value = key[:i], key[i+1:]
+		}
+		switch key {
+		case "u":
+			u, err := strconv.ParseUint(value, 10, 8)
+			if err != nil || u > maxUrgency {
+				continue
+			}
+			prio.Urgency = uint8(u)
+		case "i":
+			switch value {
+			case "", "?1":
+				prio.Incremental = true
+			case "?0":
+				prio.Incremental = false
+			}
+		}
+	}
+	return prio, true
+}
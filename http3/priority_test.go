@@ -0,0 +1,73 @@
+package http3
+
+import (
+	"net/http"
+
+	quic "github.com/lucas-clemente/quic-go"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Priority", func() {
+	parse := func(values ...string) (quic.StreamPriority, bool) {
+		hdr := http.Header{}
+		for _, v := range values {
+			hdr.Add("Priority", v)
+		}
+		return priorityFromHeader(hdr)
+	}
+
+	It("doesn't return a priority if the header is not set", func() {
+		_, ok := priorityFromHeader(http.Header{})
+		Expect(ok).To(BeFalse())
+	})
+
+	It("uses the defaults for an empty header", func() {
+		p, ok := parse("")
+		Expect(ok).To(BeTrue())
+		Expect(p).To(Equal(quic.StreamPriority{Urgency: 3}))
+	})
+
+	It("parses the urgency", func() {
+		p, ok := parse("u=5")
+		Expect(ok).To(BeTrue())
+		Expect(p).To(Equal(quic.StreamPriority{Urgency: 5}))
+	})
+
+	It("parses the incremental flag", func() {
+		p, _ := parse("i")
+		Expect(p).To(Equal(quic.StreamPriority{Urgency: 3, Incremental: true}))
+		p, _ = parse("i=?1")
+		Expect(p.Incremental).To(BeTrue())
+		p, _ = parse("i=?0")
+		Expect(p.Incremental).To(BeFalse())
+	})
+
+	It("parses both parameters", func() {
+		p, _ := parse("u=1, i")
+		Expect(p).To(Equal(quic.StreamPriority{Urgency: 1, Incremental: true}))
+	})
+
+	It("combines multiple header fields", func() {
+		p, _ := parse("u=6", "i")
+		Expect(p).To(Equal(quic.StreamPriority{Urgency: 6, Incremental: true}))
+	})
+
+	It("uses the last value if a parameter is repeated", func() {
+		p, _ := parse("u=1, u=2")
+		Expect(p.Urgency).To(BeEquivalentTo(2))
+	})
+
+	It("ignores invalid and unknown parameters", func() {
+		p, _ := parse("u=8, i=foo, foo=bar, u;bar=baz")
+		Expect(p).To(Equal(quic.StreamPriority{Urgency: 3}))
+		p, _ = parse("u=-1")
+		Expect(p.Urgency).To(BeEquivalentTo(3))
+	})
+
+	It("ignores parameters of members", func() {
+		p, _ := parse("u=2;foo=bar")
+		Expect(p.Urgency).To(BeEquivalentTo(2))
+	})
+})
@@ -0,0 +1,182 @@
+package http3
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"sync"
+
+	"github.com/lucas-clemente/quic-go"
+	"github.com/lucas-clemente/quic-go/internal/utils"
+	"golang.org/x/net/http2/hpack"
+)
+
+// The marten-seemann/qpack package only implements the static table.
+// This file and the qpack_*.go files implement the dynamic table, the encoder and decoder streams,
+// and the handling of blocked streams, as described in draft-ietf-quic-qpack-07.
+
+const (
+	settingQPACKMaxTableCapacity = 0x1
+	settingQPACKBlockedStreams   = 0x7
+)
+
+// defaultMaxHeaderTableSize is the default size of the QPACK dynamic table.
+const defaultMaxHeaderTableSize = 4096
+
+// qpackMaxBlockedStreams is the number of streams that are allowed to be blocked
+// waiting for instructions on the encoder stream.
+const qpackMaxBlockedStreams = 16
+
+var errQPACKVarintOverflow = errors.New("qpack: integer overflow")
+
+// qpackTableCapacity converts the configured table size to the capacity used for the dynamic table.
+func qpackTableCapacity(maxHeaderTableSize int) uint64 {
+	switch {
+	case maxHeaderTableSize < 0:
+		return 0
+	case maxHeaderTableSize == 0:
+		return defaultMaxHeaderTableSize
+	default:
+		return uint64(maxHeaderTableSize)
+	}
+}
+
+// qpackSettings returns the settings that need to be sent for a dynamic table of the given capacity.
+func qpackSettings(capacity uint64) map[uint64]uint64 {
+	if capacity == 0 {
+		return nil
+	}
+	return map[uint64]uint64{
+		settingQPACKMaxTableCapacity: capacity,
+		settingQPACKBlockedStreams:   qpackMaxBlockedStreams,
+	}
+}
+
+// appendQPACKInt appends i, using an n bit prefix, to dst.
+// The bits of the first byte that are not part of the prefix are taken from flags.
+// Copied from the Go standard library HPACK implementation.
+func appendQPACKInt(dst []byte, flags byte, n byte, i uint64) []byte {
+	k := uint64((1 << n) - 1)
+	if i < k {
+		return append(dst, flags|byte(i))
+	}
+	dst = append(dst, flags|byte(k))
+	i -= k
+	for ; i >= 128; i >>= 7 {
+		dst = append(dst, byte(0x80|(i&0x7f)))
+	}
+	return append(dst, byte(i))
+}
+
+// readQPACKInt reads an integer with an n bit prefix.
+// The first byte b has already been read from r.
+func readQPACKInt(r byteReader, b byte, n byte) (uint64, error) {
+	i := uint64(b)
+	if n < 8 {
+		i &= (1 << n) - 1
+	}
+	if i < (1<<n)-1 {
+		return i, nil
+	}
+	var m uint
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		i += uint64(b&0x7f) << m
+		if b&0x80 == 0 {
+			return i, nil
+		}
+		m += 7
+		if m >= 63 {
+			return 0, errQPACKVarintOverflow
+		}
+	}
+}
+
+// appendQPACKString appends a string literal, with the length using an n bit prefix.
+// The Huffman encoding is used if it is shorter.
+// The Huffman flag is the bit directly preceding the prefix.
+func appendQPACKString(dst []byte, flags byte, n byte, s string) []byte {
+	if l := hpack.HuffmanEncodeLength(s); l < uint64(len(s)) {
+		dst = appendQPACKInt(dst, flags|1<<n, n, l)
+		return hpack.AppendHuffmanString(dst, s)
+	}
+	dst = appendQPACKInt(dst, flags, n, uint64(len(s)))
+	return append(dst, s...)
+}
+
+// readQPACKString reads a string literal, with the length using an n bit prefix.
+// The first byte b has already been read from r.
+func readQPACKString(r byteReader, b byte, n byte, maxLen uint64) (string, error) {
+	l, err := readQPACKInt(r, b, n)
+	if err != nil {
+		return "", err
+	}
+	if l > maxLen {
+		return "", errors.New("qpack: string literal too long")
+	}
+	buf := make([]byte, l)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return "", io.EOF
+		}
+		return "", err
+	}
+	if b&(1<<n) > 0 {
+		return hpack.HuffmanDecodeToString(buf)
+	}
+	return string(buf), nil
+}
+
+// A qpackStream is the encoder or the decoder stream.
+// The stream is only opened once the first instruction needs to be sent.
+type qpackStream struct {
+	mutex      sync.Mutex
+	streamType uint64
+	open       func() (quic.SendStream, error)
+	str        quic.SendStream
+}
+
+func newQPACKStream(streamType uint64, open func() (quic.SendStream, error)) *qpackStream {
+	return &qpackStream{streamType: streamType, open: open}
+}
+
+func (s *qpackStream) Write(b []byte) (int, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.str == nil {
+		str, err := s.open()
+		if err != nil {
+			return 0, err
+		}
+		buf := &bytes.Buffer{}
+		utils.WriteVarInt(buf, s.streamType)
+		if _, err := str.Write(buf.Bytes()); err != nil {
+			return 0, err
+		}
+		s.str = str
+	}
+	return s.str.Write(b)
+}
+
+// handleQPACKStream processes the peer's encoder or decoder stream.
+// The stream type has already been read from the stream.
+// It only returns when the connection needs to be closed, along with the error code to close it with.
+func handleQPACKStream(streamType uint64, str quic.ReceiveStream, encoder *qpackEncoder, decoder *qpackDecoder) (errorCode, error) {
+	var err error
+	errCode := errorQPACKEncoderStreamError
+	if streamType == streamTypeQPACKEncoderStream {
+		err = decoder.handleEncoderStream(bufio.NewReader(str))
+	} else {
+		errCode = errorQPACKDecoderStreamError
+		err = encoder.handleDecoderStream(bufio.NewReader(str))
+	}
+	if err == io.EOF {
+		return errorClosedCriticalStream, errors.New("QPACK stream closed")
+	}
+	return errCode, err
+}
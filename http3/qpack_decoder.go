@@ -0,0 +1,397 @@
+package http3
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+	"github.com/marten-seemann/qpack"
+)
+
+var errQPACKDecoderClosed = errors.New("qpack: decoder closed")
+
+// A qpackDecoder decodes the header blocks received on all streams of a connection.
+type qpackDecoder struct {
+	mutex sync.Mutex
+
+	table *qpackDynamicTable
+	str   io.Writer // the decoder stream
+
+	// The number of streams waiting for instructions on the encoder stream.
+	blockedStreams uint64
+	// inserted is closed (and replaced) every time entries are inserted into the dynamic table.
+	inserted chan struct{}
+	// acknowledgedInsertCount is the insert count that the encoder knows we've received.
+	acknowledgedInsertCount uint64
+
+	closeOnce sync.Once
+	closed    chan struct{}
+}
+
+// A qpackEncoderInstruction is an instruction received on the encoder stream.
+type qpackEncoderInstruction struct {
+	setCapacity bool
+	capacity    uint64
+
+	duplicate bool
+	index     uint64 // for Duplicate and Insert With Name Reference
+	hasName   bool   // if the name is referenced using index
+	static    bool   // if the index refers to the static table
+	name      string // for Insert Without Name Reference
+	value     string
+}
+
+func newQPACKDecoder(maxCapacity uint64, str io.Writer) *qpackDecoder {
+	return &qpackDecoder{
+		table:    newQPACKDynamicTable(maxCapacity),
+		str:      str,
+		inserted: make(chan struct{}),
+		closed:   make(chan struct{}),
+	}
+}
+
+// close unblocks all streams that are waiting for entries to be inserted into the dynamic table.
+// It is called when the connection is closed.
+func (d *qpackDecoder) close() {
+	d.closeOnce.Do(func() { close(d.closed) })
+}
+
+// decode decodes the header block of a HEADERS or PUSH_PROMISE frame received on stream id.
+// If the header block references entries that weren't received yet, it blocks until they arrive.
+func (d *qpackDecoder) decode(id protocol.StreamID, headerBlock []byte) ([]qpack.HeaderField, error) {
+	if len(headerBlock) == 0 {
+		return nil, nil
+	}
+	r := bytes.NewReader(headerBlock)
+	b, _ := r.ReadByte()
+	encodedInsertCount, err := readQPACKInt(r, b, 8)
+	if err != nil {
+		return nil, err
+	}
+	b, err = r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	deltaBase, err := readQPACKInt(r, b, 7)
+	if err != nil {
+		return nil, err
+	}
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	requiredInsertCount, err := d.decodeRequiredInsertCount(encodedInsertCount)
+	if err != nil {
+		return nil, err
+	}
+	var base uint64
+	if b&0x80 == 0 {
+		base = requiredInsertCount + deltaBase
+	} else {
+		if deltaBase >= requiredInsertCount {
+			return nil, errors.New("qpack: invalid Base")
+		}
+		base = requiredInsertCount - deltaBase - 1
+	}
+	if err := d.waitForInserts(requiredInsertCount); err != nil {
+		return nil, err
+	}
+	hfs, err := d.decodeFieldLines(r, requiredInsertCount, base)
+	if err != nil {
+		return nil, err
+	}
+	if requiredInsertCount > 0 {
+		// Header Acknowledgement
+		if _, err := d.str.Write(appendQPACKInt(nil, 0x80, 7, uint64(id))); err != nil {
+			return nil, err
+		}
+		if requiredInsertCount > d.acknowledgedInsertCount {
+			d.acknowledgedInsertCount = requiredInsertCount
+		}
+	}
+	return hfs, nil
+}
+
+func (d *qpackDecoder) decodeRequiredInsertCount(encoded uint64) (uint64, error) {
+	if encoded == 0 {
+		return 0, nil
+	}
+	maxEntries := d.table.maxEntries()
+	fullRange := 2 * maxEntries
+	if encoded > fullRange {
+		return 0, fmt.Errorf("qpack: invalid Required Insert Count: %d", encoded)
+	}
+	maxValue := d.table.insertCount() + maxEntries
+	maxWrapped := (maxValue / fullRange) * fullRange
+	requiredInsertCount := maxWrapped + encoded - 1
+	if requiredInsertCount > maxValue {
+		if requiredInsertCount <= fullRange {
+			return 0, fmt.Errorf("qpack: invalid Required Insert Count: %d", encoded)
+		}
+		requiredInsertCount -= fullRange
+	}
+	if requiredInsertCount == 0 {
+		return 0, fmt.Errorf("qpack: invalid Required Insert Count: %d", encoded)
+	}
+	return requiredInsertCount, nil
+}
+
+// waitForInserts blocks until the dynamic table contains enough entries to decode a header block.
+// It must be called with the mutex held.
+func (d *qpackDecoder) waitForInserts(requiredInsertCount uint64) error {
+	if requiredInsertCount <= d.table.insertCount() {
+		return nil
+	}
+	if d.blockedStreams >= qpackMaxBlockedStreams {
+		return errors.New("qpack: too many blocked streams")
+	}
+	d.blockedStreams++
+	defer func() { d.blockedStreams-- }()
+	for requiredInsertCount > d.table.insertCount() {
+		inserted := d.inserted
+		d.mutex.Unlock()
+		select {
+		case <-inserted:
+		case <-d.closed:
+		}
+		d.mutex.Lock()
+		select {
+		case <-d.closed:
+			return errQPACKDecoderClosed
+		default:
+		}
+	}
+	return nil
+}
+
+// decodeFieldLines decodes the representations of the header fields, following the header block prefix.
+func (d *qpackDecoder) decodeFieldLines(r *bytes.Reader, requiredInsertCount, base uint64) ([]qpack.HeaderField, error) {
+	maxLen := uint64(r.Len())
+	getDynamic := func(index uint64) (qpack.HeaderField, error) {
+		if index >= requiredInsertCount {
+			return qpack.HeaderField{}, fmt.Errorf("qpack: reference to entry %d exceeds the Required Insert Count", index)
+		}
+		hf, ok := d.table.get(index)
+		if !ok {
+			return qpack.HeaderField{}, fmt.Errorf("qpack: invalid dynamic table index %d", index)
+		}
+		return hf, nil
+	}
+	getRelative := func(index uint64) (qpack.HeaderField, error) {
+		if index >= base {
+			return qpack.HeaderField{}, fmt.Errorf("qpack: invalid relative index %d", index)
+		}
+		return getDynamic(base - 1 - index)
+	}
+
+	var hfs []qpack.HeaderField
+	for r.Len() > 0 {
+		b, _ := r.ReadByte()
+		var hf qpack.HeaderField
+		var err error
+		switch {
+		case b&0x80 > 0: // Indexed Header Field
+			var index uint64
+			index, err = readQPACKInt(r, b, 6)
+			if err != nil {
+				break
+			}
+			if b&0x40 > 0 {
+				hf, err = qpackStaticEntry(index)
+			} else {
+				hf, err = getRelative(index)
+			}
+		case b&0xc0 == 0x40: // Literal Header Field With Name Reference
+			var index uint64
+			index, err = readQPACKInt(r, b, 4)
+			if err != nil {
+				break
+			}
+			if b&0x10 > 0 {
+				hf, err = qpackStaticEntry(index)
+			} else {
+				hf, err = getRelative(index)
+			}
+			if err != nil {
+				break
+			}
+			hf.Value, err = readQPACKValue(r, maxLen)
+		case b&0xe0 == 0x20: // Literal Header Field Without Name Reference
+			hf.Name, err = readQPACKString(r, b, 3, maxLen)
+			if err != nil {
+				break
+			}
+			hf.Value, err = readQPACKValue(r, maxLen)
+		case b&0xf0 == 0x10: // Indexed Header Field With Post-Base Index
+			var index uint64
+			index, err = readQPACKInt(r, b, 4)
+			if err != nil {
+				break
+			}
+			hf, err = getDynamic(base + index)
+		default: // Literal Header Field With Post-Base Name Reference
+			var index uint64
+			index, err = readQPACKInt(r, b, 3)
+			if err != nil {
+				break
+			}
+			if hf, err = getDynamic(base + index); err != nil {
+				break
+			}
+			hf.Value, err = readQPACKValue(r, maxLen)
+		}
+		if err != nil {
+			return nil, err
+		}
+		hfs = append(hfs, hf)
+	}
+	return hfs, nil
+}
+
+func qpackStaticEntry(index uint64) (qpack.HeaderField, error) {
+	if index >= uint64(len(qpackStaticTable)) {
+		return qpack.HeaderField{}, fmt.Errorf("qpack: invalid static table index %d", index)
+	}
+	return qpackStaticTable[index], nil
+}
+
+// readQPACKValue reads a header field value, which uses a 7 bit prefix for the length.
+func readQPACKValue(r byteReader, maxLen uint64) (string, error) {
+	b, err := r.ReadByte()
+	if err != nil {
+		return "", err
+	}
+	return readQPACKString(r, b, 7, maxLen)
+}
+
+// cancelStream is called when a stream is reset before its header block was decoded.
+// The encoder is then able to evict entries referenced by the header block.
+func (d *qpackDecoder) cancelStream(id protocol.StreamID) error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	// If the dynamic table is disabled, the encoder can't have referenced it.
+	if d.table.maxCapacity == 0 {
+		return nil
+	}
+	// Stream Cancellation
+	_, err := d.str.Write(appendQPACKInt(nil, 0x40, 6, uint64(id)))
+	return err
+}
+
+// handleEncoderStream processes the instructions sent on the peer's encoder stream.
+// It only returns when reading from the stream fails, or when an invalid instruction is received.
+func (d *qpackDecoder) handleEncoderStream(r *bufio.Reader) error {
+	for {
+		inst, err := d.readEncoderInstruction(r)
+		if err != nil {
+			return err
+		}
+		if err := d.applyEncoderInstruction(inst); err != nil {
+			return err
+		}
+		// Acknowledge the new entries, once all instructions that were received have been processed.
+		if r.Buffered() == 0 {
+			if err := d.acknowledgeInserts(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (d *qpackDecoder) readEncoderInstruction(r byteReader) (*qpackEncoderInstruction, error) {
+	maxLen := d.table.maxCapacity
+	b, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	inst := &qpackEncoderInstruction{}
+	switch {
+	case b&0x80 > 0: // Insert With Name Reference
+		inst.hasName = true
+		inst.static = b&0x40 > 0
+		if inst.index, err = readQPACKInt(r, b, 6); err != nil {
+			return nil, err
+		}
+		if inst.value, err = readQPACKValue(r, maxLen); err != nil {
+			return nil, err
+		}
+	case b&0x40 > 0: // Insert Without Name Reference
+		if inst.name, err = readQPACKString(r, b, 5, maxLen); err != nil {
+			return nil, err
+		}
+		if inst.value, err = readQPACKValue(r, maxLen); err != nil {
+			return nil, err
+		}
+	case b&0x20 > 0: // Set Dynamic Table Capacity
+		inst.setCapacity = true
+		if inst.capacity, err = readQPACKInt(r, b, 5); err != nil {
+			return nil, err
+		}
+	default: // Duplicate
+		inst.duplicate = true
+		if inst.index, err = readQPACKInt(r, b, 5); err != nil {
+			return nil, err
+		}
+	}
+	return inst, nil
+}
+
+func (d *qpackDecoder) applyEncoderInstruction(inst *qpackEncoderInstruction) error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if inst.setCapacity {
+		return d.table.setCapacity(inst.capacity)
+	}
+	hf := qpack.HeaderField{Name: inst.name, Value: inst.value}
+	if inst.hasName || inst.duplicate {
+		var ref qpack.HeaderField
+		var err error
+		if inst.static {
+			ref, err = qpackStaticEntry(inst.index)
+		} else {
+			// relative to the number of entries inserted
+			insertCount := d.table.insertCount()
+			if inst.index >= insertCount {
+				return fmt.Errorf("qpack: invalid relative index %d", inst.index)
+			}
+			var ok bool
+			if ref, ok = d.table.get(insertCount - 1 - inst.index); !ok {
+				err = fmt.Errorf("qpack: invalid relative index %d", inst.index)
+			}
+		}
+		if err != nil {
+			return err
+		}
+		hf.Name = ref.Name
+		if inst.duplicate {
+			hf.Value = ref.Value
+		}
+	}
+	if err := d.table.insert(hf); err != nil {
+		return err
+	}
+	close(d.inserted)
+	d.inserted = make(chan struct{})
+	return nil
+}
+
+// acknowledgeInserts sends an Insert Count Increment, if the encoder doesn't know about all inserted entries yet.
+func (d *qpackDecoder) acknowledgeInserts() error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	insertCount := d.table.insertCount()
+	if insertCount <= d.acknowledgedInsertCount {
+		return nil
+	}
+	increment := insertCount - d.acknowledgedInsertCount
+	d.acknowledgedInsertCount = insertCount
+	_, err := d.str.Write(appendQPACKInt(nil, 0, 6, increment))
+	return err
+}
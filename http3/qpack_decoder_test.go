@@ -0,0 +1,167 @@
+package http3
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+
+	"github.com/marten-seemann/qpack"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("QPACK decoder", func() {
+	var (
+		decoder       *qpackDecoder
+		decoderStream *bytes.Buffer
+	)
+
+	headers := []qpack.HeaderField{
+		{Name: ":method", Value: "GET"},
+		{Name: ":authority", Value: "quic.clemente.io"},
+		{Name: "authorization", Value: "Bearer 0123456789abcdef"},
+		{Name: "x-custom-header", Value: "foobar"},
+	}
+
+	// sendEncoderInstructions passes the instructions written by an encoder to the decoder
+	sendEncoderInstructions := func(data []byte) {
+		Expect(decoder.handleEncoderStream(bufio.NewReader(bytes.NewReader(data)))).To(MatchError(io.EOF))
+	}
+
+	BeforeEach(func() {
+		decoderStream = &bytes.Buffer{}
+		decoder = newQPACKDecoder(1000, decoderStream)
+	})
+
+	It("decodes header blocks that only use the static table", func() {
+		data, err := newQPACKEncoder(0, nil).encode(0, headers)
+		Expect(err).ToNot(HaveOccurred())
+		hfs, err := decoder.decode(0, data)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(hfs).To(Equal(headers))
+		Expect(decoderStream.Len()).To(BeZero())
+	})
+
+	Context("using the dynamic table", func() {
+		var (
+			encoder       *qpackEncoder
+			encoderStream *bytes.Buffer
+		)
+
+		BeforeEach(func() {
+			encoderStream = &bytes.Buffer{}
+			encoder = newQPACKEncoder(1000, encoderStream)
+			Expect(encoder.setPeerSettings(qpackSettings(1000))).To(Succeed())
+		})
+
+		It("decodes header blocks", func() {
+			data, err := encoder.encode(4, headers)
+			Expect(err).ToNot(HaveOccurred())
+			sendEncoderInstructions(encoderStream.Bytes())
+			Expect(decoder.table.insertCount()).To(BeEquivalentTo(3))
+			hfs, err := decoder.decode(4, data)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(hfs).To(Equal(headers))
+			// The encoder processes the Insert Count Increment and the Header Acknowledgement.
+			Expect(encoder.handleDecoderStream(bufio.NewReader(decoderStream))).To(MatchError(io.EOF))
+			Expect(encoder.knownReceivedCount).To(BeEquivalentTo(3))
+			Expect(encoder.blocks).To(BeEmpty())
+		})
+
+		It("acknowledges inserted entries", func() {
+			_, err := encoder.encode(4, headers)
+			Expect(err).ToNot(HaveOccurred())
+			sendEncoderInstructions(encoderStream.Bytes())
+			Expect(decoderStream.Bytes()).To(Equal(appendQPACKInt(nil, 0, 6, 3)))
+		})
+
+		It("acknowledges header blocks", func() {
+			data, err := encoder.encode(4, headers)
+			Expect(err).ToNot(HaveOccurred())
+			sendEncoderInstructions(encoderStream.Bytes())
+			decoderStream.Reset()
+			_, err = decoder.decode(4, data)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(decoderStream.Bytes()).To(Equal(appendQPACKInt(nil, 0x80, 7, 4)))
+		})
+
+		It("blocks until the referenced entries are received", func() {
+			data, err := encoder.encode(4, headers)
+			Expect(err).ToNot(HaveOccurred())
+			done := make(chan struct{})
+			go func() {
+				defer GinkgoRecover()
+				defer close(done)
+				hfs, err := decoder.decode(4, data)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(hfs).To(Equal(headers))
+			}()
+			Consistently(done).ShouldNot(BeClosed())
+			sendEncoderInstructions(encoderStream.Bytes())
+			Eventually(done).Should(BeClosed())
+		})
+
+		It("unblocks streams when it is closed", func() {
+			data, err := encoder.encode(4, headers)
+			Expect(err).ToNot(HaveOccurred())
+			done := make(chan struct{})
+			go func() {
+				defer GinkgoRecover()
+				defer close(done)
+				_, err := decoder.decode(4, data)
+				Expect(err).To(MatchError(errQPACKDecoderClosed))
+			}()
+			Consistently(done).ShouldNot(BeClosed())
+			decoder.close()
+			Eventually(done).Should(BeClosed())
+		})
+	})
+
+	It("decodes header blocks using post-base indices", func() {
+		instructions := appendQPACKInt(nil, 0x20, 5, 1000)             // Set Dynamic Table Capacity
+		instructions = appendQPACKString(instructions, 0x40, 5, "foo") // Insert Without Name Reference
+		instructions = appendQPACKString(instructions, 0, 7, "bar")
+		sendEncoderInstructions(instructions)
+		data := appendQPACKInt(nil, 0, 8, 2)        // Required Insert Count 1
+		data = append(data, 0x80)                   // Base 0
+		data = append(data, 0x10)                   // Indexed Header Field With Post-Base Index 0
+		data = append(data, 0x00)                   // Literal Header Field With Post-Base Name Reference 0
+		data = appendQPACKString(data, 0, 7, "baz") // value
+		hfs, err := decoder.decode(0, data)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(hfs).To(Equal([]qpack.HeaderField{
+			{Name: "foo", Value: "bar"},
+			{Name: "foo", Value: "baz"},
+		}))
+	})
+
+	It("errors on invalid Required Insert Counts", func() {
+		_, err := decoder.decode(0, []byte{63, 0})
+		Expect(err).To(MatchError("qpack: invalid Required Insert Count: 63"))
+	})
+
+	It("errors on references to entries that don't exist", func() {
+		data := appendQPACKInt(nil, 0, 8, 0)
+		data = append(data, 0)
+		data = append(data, 0x80) // Indexed Header Field, relative index 0
+		_, err := decoder.decode(0, data)
+		Expect(err).To(MatchError("qpack: invalid relative index 0"))
+	})
+
+	It("errors when the encoder sets a capacity larger than the maximum", func() {
+		err := decoder.handleEncoderStream(bufio.NewReader(bytes.NewReader(appendQPACKInt(nil, 0x20, 5, 1001))))
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("sends Stream Cancellations", func() {
+		Expect(decoder.cancelStream(8)).To(Succeed())
+		Expect(decoderStream.Bytes()).To(Equal(appendQPACKInt(nil, 0x40, 6, 8)))
+	})
+
+	It("doesn't send Stream Cancellations if the dynamic table is disabled", func() {
+		decoder = newQPACKDecoder(0, decoderStream)
+		Expect(decoder.cancelStream(8)).To(Succeed())
+		Expect(decoderStream.Len()).To(BeZero())
+	})
+})
@@ -0,0 +1,312 @@
+package http3
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sync"
+
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+	"github.com/marten-seemann/qpack"
+)
+
+// A qpackEncoder encodes the header blocks sent on all streams of a connection.
+// The dynamic table is only used after the peer's SETTINGS were received.
+type qpackEncoder struct {
+	mutex sync.Mutex
+
+	maxCapacity uint64    // the maximum capacity of the dynamic table we're willing to use
+	str         io.Writer // the encoder stream
+
+	table              *qpackDynamicTable // nil, if the dynamic table is not used
+	maxBlockedStreams  uint64
+	knownReceivedCount uint64
+	// header blocks that reference the dynamic table, and haven't been acknowledged yet, oldest first
+	blocks map[protocol.StreamID][]qpackHeaderBlock
+}
+
+type qpackHeaderBlock struct {
+	requiredInsertCount uint64
+	minIndex            uint64 // the smallest absolute index referenced
+}
+
+// A qpackFieldLine is the representation chosen for a header field.
+type qpackFieldLine struct {
+	hf      qpack.HeaderField
+	indexed bool   // if false, it's a literal
+	static  bool   // if the index refers to the static table
+	hasName bool   // for literals: if the name is referenced using the static table
+	index   uint64 // the index in the static table, or the absolute index in the dynamic table
+}
+
+func newQPACKEncoder(maxCapacity uint64, str io.Writer) *qpackEncoder {
+	return &qpackEncoder{
+		maxCapacity: maxCapacity,
+		str:         str,
+		blocks:      make(map[protocol.StreamID][]qpackHeaderBlock),
+	}
+}
+
+// setPeerSettings processes the QPACK settings sent by the peer.
+// If both endpoints allow the use of the dynamic table, the capacity of the table is set.
+func (e *qpackEncoder) setPeerSettings(settings map[uint64]uint64) error {
+	peerMaxCapacity := settings[settingQPACKMaxTableCapacity]
+	capacity := peerMaxCapacity
+	if e.maxCapacity < capacity {
+		capacity = e.maxCapacity
+	}
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	e.maxBlockedStreams = settings[settingQPACKBlockedStreams]
+	if capacity == 0 {
+		return nil
+	}
+	table := newQPACKDynamicTable(peerMaxCapacity)
+	if err := table.setCapacity(capacity); err != nil {
+		return err
+	}
+	// Set Dynamic Table Capacity
+	if _, err := e.str.Write(appendQPACKInt(nil, 0x20, 5, capacity)); err != nil {
+		return err
+	}
+	e.table = table
+	return nil
+}
+
+// encode encodes the header block of a HEADERS or PUSH_PROMISE frame sent on stream id.
+// Instructions for inserting new entries into the dynamic table are sent on the encoder stream.
+func (e *qpackEncoder) encode(id protocol.StreamID, hfs []qpack.HeaderField) ([]byte, error) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	// Referencing entries that the decoder might not have received yet can block the stream.
+	mayBlock := e.table != nil && (e.isBlocking(id) || e.numBlockingStreams() < e.maxBlockedStreams)
+	evictionLimit := e.evictionLimit()
+
+	var instructions []byte
+	requiredInsertCount := uint64(0)
+	minIndex := uint64(math.MaxUint64)
+	lines := make([]qpackFieldLine, 0, len(hfs))
+	for _, hf := range hfs {
+		line := e.fieldLine(hf, mayBlock)
+		if !line.indexed && e.shouldInsert(hf, evictionLimit, minIndex) {
+			instructions = e.insert(instructions, hf)
+			if mayBlock {
+				line = qpackFieldLine{hf: hf, indexed: true, index: e.table.insertCount() - 1}
+			}
+		}
+		if line.indexed && !line.static {
+			if line.index+1 > requiredInsertCount {
+				requiredInsertCount = line.index + 1
+			}
+			if line.index < minIndex {
+				minIndex = line.index
+			}
+		}
+		lines = append(lines, line)
+	}
+
+	if len(instructions) > 0 {
+		if _, err := e.str.Write(instructions); err != nil {
+			return nil, err
+		}
+	}
+	if requiredInsertCount > 0 {
+		e.blocks[id] = append(e.blocks[id], qpackHeaderBlock{
+			requiredInsertCount: requiredInsertCount,
+			minIndex:            minIndex,
+		})
+	}
+	return e.writeHeaderBlock(requiredInsertCount, lines), nil
+}
+
+// fieldLine chooses the representation of a header field, using the entries that are already present in the tables.
+func (e *qpackEncoder) fieldLine(hf qpack.HeaderField, mayBlock bool) qpackFieldLine {
+	if index, ok := qpackStaticFieldIndex[hf]; ok {
+		return qpackFieldLine{hf: hf, indexed: true, static: true, index: index}
+	}
+	if e.table != nil {
+		if index, nameOnly, ok := e.table.find(hf); ok && !nameOnly && (index < e.knownReceivedCount || mayBlock) {
+			return qpackFieldLine{hf: hf, indexed: true, index: index}
+		}
+	}
+	if index, ok := qpackStaticNameIndex[hf.Name]; ok {
+		return qpackFieldLine{hf: hf, hasName: true, index: index}
+	}
+	return qpackFieldLine{hf: hf}
+}
+
+// shouldInsert says if a header field should be inserted into the dynamic table.
+// Entries that are referenced by the header block currently being encoded must not be evicted.
+func (e *qpackEncoder) shouldInsert(hf qpack.HeaderField, evictionLimit, minIndex uint64) bool {
+	if e.table == nil {
+		return false
+	}
+	// Large entries would evict most of the table.
+	if qpackEntrySize(hf) > e.table.capacity/2 {
+		return false
+	}
+	// The entry might already have been inserted, but the decoder didn't acknowledge it yet.
+	if _, nameOnly, ok := e.table.find(hf); ok && !nameOnly {
+		return false
+	}
+	if minIndex < evictionLimit {
+		evictionLimit = minIndex
+	}
+	return e.table.canInsert(hf, evictionLimit)
+}
+
+// insert inserts a header field into the dynamic table, and appends the instruction for the decoder.
+func (e *qpackEncoder) insert(instructions []byte, hf qpack.HeaderField) []byte {
+	if index, ok := qpackStaticNameIndex[hf.Name]; ok {
+		// Insert With Name Reference, referencing the static table
+		instructions = appendQPACKInt(instructions, 0xc0, 6, index)
+	} else {
+		// Insert Without Name Reference
+		instructions = appendQPACKString(instructions, 0x40, 5, hf.Name)
+	}
+	instructions = appendQPACKString(instructions, 0, 7, hf.Value)
+	// This can't fail, shouldInsert already checked that the entry fits.
+	_ = e.table.insert(hf)
+	return instructions
+}
+
+func (e *qpackEncoder) writeHeaderBlock(requiredInsertCount uint64, lines []qpackFieldLine) []byte {
+	var base uint64
+	var encodedInsertCount uint64
+	if requiredInsertCount > 0 {
+		// All references to the dynamic table are relative to the Base, which is the current insert count.
+		base = e.table.insertCount()
+		encodedInsertCount = requiredInsertCount%(2*e.table.maxEntries()) + 1
+	}
+	b := appendQPACKInt(nil, 0, 8, encodedInsertCount)
+	b = appendQPACKInt(b, 0, 7, base-requiredInsertCount) // Delta Base, with the sign bit unset
+	for _, l := range lines {
+		switch {
+		case l.indexed && l.static:
+			b = appendQPACKInt(b, 0xc0, 6, l.index)
+		case l.indexed:
+			b = appendQPACKInt(b, 0x80, 6, base-1-l.index)
+		case l.hasName: // literal with a name reference to the static table
+			b = appendQPACKInt(b, 0x50, 4, l.index)
+			b = appendQPACKString(b, 0, 7, l.hf.Value)
+		default:
+			b = appendQPACKString(b, 0x20, 3, l.hf.Name)
+			b = appendQPACKString(b, 0, 7, l.hf.Value)
+		}
+	}
+	return b
+}
+
+// isBlocking says if the decoder might need to wait for instructions on the encoder stream,
+// before it can decode the header blocks sent on this stream.
+func (e *qpackEncoder) isBlocking(id protocol.StreamID) bool {
+	for _, b := range e.blocks[id] {
+		if b.requiredInsertCount > e.knownReceivedCount {
+			return true
+		}
+	}
+	return false
+}
+
+func (e *qpackEncoder) numBlockingStreams() uint64 {
+	var n uint64
+	for id := range e.blocks {
+		if e.isBlocking(id) {
+			n++
+		}
+	}
+	return n
+}
+
+// evictionLimit is the smallest absolute index of an entry that must not be evicted.
+// Entries are only evicted if the decoder has received them, and they're not referenced by any unacknowledged header block.
+func (e *qpackEncoder) evictionLimit() uint64 {
+	limit := e.knownReceivedCount
+	for _, blocks := range e.blocks {
+		for _, b := range blocks {
+			if b.minIndex < limit {
+				limit = b.minIndex
+			}
+		}
+	}
+	return limit
+}
+
+// handleDecoderStream processes the instructions sent on the peer's decoder stream.
+// It only returns when reading from the stream fails, or when an invalid instruction is received.
+func (e *qpackEncoder) handleDecoderStream(r byteReader) error {
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return err
+		}
+		switch {
+		case b&0x80 > 0: // Header Acknowledgement
+			id, err := readQPACKInt(r, b, 7)
+			if err != nil {
+				return err
+			}
+			if err := e.acknowledge(protocol.StreamID(id)); err != nil {
+				return err
+			}
+		case b&0x40 > 0: // Stream Cancellation
+			id, err := readQPACKInt(r, b, 6)
+			if err != nil {
+				return err
+			}
+			e.cancelStream(protocol.StreamID(id))
+		default: // Insert Count Increment
+			increment, err := readQPACKInt(r, b, 6)
+			if err != nil {
+				return err
+			}
+			if err := e.incrementKnownReceivedCount(increment); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (e *qpackEncoder) acknowledge(id protocol.StreamID) error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	blocks := e.blocks[id]
+	if len(blocks) == 0 {
+		return fmt.Errorf("qpack: unexpected Header Acknowledgement for stream %d", id)
+	}
+	if len(blocks) == 1 {
+		delete(e.blocks, id)
+	} else {
+		e.blocks[id] = blocks[1:]
+	}
+	if blocks[0].requiredInsertCount > e.knownReceivedCount {
+		e.knownReceivedCount = blocks[0].requiredInsertCount
+	}
+	return nil
+}
+
+func (e *qpackEncoder) cancelStream(id protocol.StreamID) {
+	e.mutex.Lock()
+	delete(e.blocks, id)
+	e.mutex.Unlock()
+}
+
+func (e *qpackEncoder) incrementKnownReceivedCount(increment uint64) error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	var insertCount uint64
+	if e.table != nil {
+		insertCount = e.table.insertCount()
+	}
+	if increment == 0 || increment > insertCount-e.knownReceivedCount {
+		return errors.New("qpack: invalid Insert Count Increment")
+	}
+	e.knownReceivedCount += increment
+	return nil
+}
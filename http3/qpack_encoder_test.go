@@ -0,0 +1,170 @@
+package http3
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+
+	"github.com/marten-seemann/qpack"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("QPACK encoder", func() {
+	var (
+		encoder       *qpackEncoder
+		encoderStream *bytes.Buffer
+	)
+
+	headers := []qpack.HeaderField{
+		{Name: ":method", Value: "GET"},
+		{Name: ":authority", Value: "quic.clemente.io"},
+		{Name: "authorization", Value: "Bearer 0123456789abcdef"},
+		{Name: "x-custom-header", Value: "foobar"},
+	}
+
+	// sendDecoderInstructions passes the instructions written by a decoder to the encoder
+	sendDecoderInstructions := func(data []byte) {
+		Expect(encoder.handleDecoderStream(bufio.NewReader(bytes.NewReader(data)))).To(MatchError(io.EOF))
+	}
+
+	BeforeEach(func() {
+		encoderStream = &bytes.Buffer{}
+		encoder = newQPACKEncoder(1000, encoderStream)
+	})
+
+	It("only uses the static table before receiving the SETTINGS", func() {
+		data, err := encoder.encode(0, headers)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(encoderStream.Len()).To(BeZero())
+		// the marten-seemann/qpack decoder doesn't support the dynamic table
+		hfs, err := qpack.NewDecoder(nil).DecodeFull(data)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(hfs).To(Equal(headers))
+	})
+
+	It("only uses the static table if the peer disabled the dynamic table", func() {
+		Expect(encoder.setPeerSettings(map[uint64]uint64{})).To(Succeed())
+		data, err := encoder.encode(0, headers)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(encoderStream.Len()).To(BeZero())
+		_, err = qpack.NewDecoder(nil).DecodeFull(data)
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	It("sets the capacity of the dynamic table", func() {
+		Expect(encoder.setPeerSettings(map[uint64]uint64{settingQPACKMaxTableCapacity: 4096})).To(Succeed())
+		// Set Dynamic Table Capacity, 1000
+		Expect(encoderStream.Bytes()).To(Equal(appendQPACKInt(nil, 0x20, 5, 1000)))
+	})
+
+	Context("using the dynamic table", func() {
+		BeforeEach(func() {
+			Expect(encoder.setPeerSettings(map[uint64]uint64{
+				settingQPACKMaxTableCapacity: 1000,
+				settingQPACKBlockedStreams:   1,
+			})).To(Succeed())
+			encoderStream.Reset()
+		})
+
+		It("inserts header fields into the dynamic table", func() {
+			data, err := encoder.encode(0, headers)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(encoderStream.Len()).ToNot(BeZero())
+			// :method: GET is in the static table
+			Expect(encoder.table.insertCount()).To(BeEquivalentTo(3))
+			Expect(encoder.isBlocking(0)).To(BeTrue())
+			// The second header block (e.g. the trailers) references the same entries, without inserting them again.
+			encoderStream.Reset()
+			data2, err := encoder.encode(0, headers)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(encoderStream.Len()).To(BeZero())
+			Expect(data2).To(Equal(data))
+		})
+
+		It("doesn't insert large header fields", func() {
+			_, err := encoder.encode(0, []qpack.HeaderField{{Name: "cookie", Value: string(bytes.Repeat([]byte{'a'}, 500))}})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(encoder.table.insertCount()).To(BeZero())
+		})
+
+		It("doesn't block more streams than allowed", func() {
+			_, err := encoder.encode(0, headers[:3])
+			Expect(err).ToNot(HaveOccurred())
+			Expect(encoder.table.insertCount()).To(BeEquivalentTo(2))
+			// Stream 4 isn't allowed to reference any entries that the decoder might not have received yet.
+			// The new entry is still inserted, so it can be used after it was acknowledged.
+			_, err = encoder.encode(4, headers)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(encoder.table.insertCount()).To(BeEquivalentTo(3))
+			Expect(encoder.isBlocking(4)).To(BeFalse())
+			Expect(encoder.blocks).ToNot(HaveKey(BeEquivalentTo(4)))
+		})
+
+		It("references entries that the decoder received", func() {
+			_, err := encoder.encode(0, headers)
+			Expect(err).ToNot(HaveOccurred())
+			sendDecoderInstructions(appendQPACKInt(nil, 0, 6, 3)) // Insert Count Increment
+			Expect(encoder.knownReceivedCount).To(BeEquivalentTo(3))
+			Expect(encoder.isBlocking(0)).To(BeFalse())
+			_, err = encoder.encode(4, headers)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(encoder.isBlocking(4)).To(BeFalse())
+			Expect(encoder.blocks).To(HaveKey(BeEquivalentTo(4)))
+		})
+
+		It("processes Header Acknowledgements", func() {
+			_, err := encoder.encode(8, headers)
+			Expect(err).ToNot(HaveOccurred())
+			sendDecoderInstructions(appendQPACKInt(nil, 0x80, 7, 8))
+			Expect(encoder.knownReceivedCount).To(BeEquivalentTo(3))
+			Expect(encoder.blocks).To(BeEmpty())
+		})
+
+		It("processes Stream Cancellations", func() {
+			_, err := encoder.encode(8, headers)
+			Expect(err).ToNot(HaveOccurred())
+			sendDecoderInstructions(appendQPACKInt(nil, 0x40, 6, 8))
+			Expect(encoder.knownReceivedCount).To(BeZero())
+			Expect(encoder.blocks).To(BeEmpty())
+		})
+
+		It("doesn't evict entries that are referenced by unacknowledged header blocks", func() {
+			hf := func(i int) qpack.HeaderField {
+				return qpack.HeaderField{Name: "x-header", Value: string(bytes.Repeat([]byte{byte('a' + i)}, 200))}
+			}
+			_, err := encoder.encode(0, []qpack.HeaderField{hf(0)})
+			Expect(err).ToNot(HaveOccurred())
+			sendDecoderInstructions(appendQPACKInt(nil, 0, 6, 1))
+			for i := 1; i < 10; i++ {
+				_, err := encoder.encode(4, []qpack.HeaderField{hf(0), hf(i)})
+				Expect(err).ToNot(HaveOccurred())
+				_, ok := encoder.table.get(0)
+				Expect(ok).To(BeTrue())
+			}
+			// After the header blocks are acknowledged, the entry can be evicted.
+			sendDecoderInstructions(appendQPACKInt(nil, 0x80, 7, 0))
+			sendDecoderInstructions(appendQPACKInt(nil, 0x40, 6, 4))
+			sendDecoderInstructions(appendQPACKInt(nil, 0, 6, encoder.table.insertCount()-1))
+			_, err = encoder.encode(8, []qpack.HeaderField{hf(10)})
+			Expect(err).ToNot(HaveOccurred())
+			_, ok := encoder.table.get(0)
+			Expect(ok).To(BeFalse())
+		})
+
+		It("errors on Header Acknowledgements for unknown streams", func() {
+			err := encoder.handleDecoderStream(bufio.NewReader(bytes.NewReader(appendQPACKInt(nil, 0x80, 7, 42))))
+			Expect(err).To(MatchError("qpack: unexpected Header Acknowledgement for stream 42"))
+		})
+
+		It("errors on invalid Insert Count Increments", func() {
+			_, err := encoder.encode(0, headers)
+			Expect(err).ToNot(HaveOccurred())
+			err = encoder.handleDecoderStream(bufio.NewReader(bytes.NewReader(appendQPACKInt(nil, 0, 6, 4))))
+			Expect(err).To(MatchError("qpack: invalid Insert Count Increment"))
+			err = encoder.handleDecoderStream(bufio.NewReader(bytes.NewReader(appendQPACKInt(nil, 0, 6, 0))))
+			Expect(err).To(MatchError("qpack: invalid Insert Count Increment"))
+		})
+	})
+})
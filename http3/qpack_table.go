@@ -0,0 +1,236 @@
+package http3
+
+import (
+	"errors"
+
+	"github.com/marten-seemann/qpack"
+)
+
+// qpackEntryOverhead is the overhead of every entry in the dynamic table, in addition to the length of name and value.
+const qpackEntryOverhead = 32
+
+// qpackStaticTable is the QPACK static table.
+// Copied from the marten-seemann/qpack package, which doesn't export it.
+var qpackStaticTable = [...]qpack.HeaderField{
+	{Name: ":authority"},
+	{Name: ":path", Value: "/"},
+	{Name: "age", Value: "0"},
+	{Name: "content-disposition"},
+	{Name: "content-length", Value: "0"},
+	{Name: "cookie"},
+	{Name: "date"},
+	{Name: "etag"},
+	{Name: "if-modified-since"},
+	{Name: "if-none-match"},
+	{Name: "last-modified"},
+	{Name: "link"},
+	{Name: "location"},
+	{Name: "referer"},
+	{Name: "set-cookie"},
+	{Name: ":method", Value: "CONNECT"},
+	{Name: ":method", Value: "DELETE"},
+	{Name: ":method", Value: "GET"},
+	{Name: ":method", Value: "HEAD"},
+	{Name: ":method", Value: "OPTIONS"},
+	{Name: ":method", Value: "POST"},
+	{Name: ":method", Value: "PUT"},
+	{Name: ":scheme", Value: "http"},
+	{Name: ":scheme", Value: "https"},
+	{Name: ":status", Value: "103"},
+	{Name: ":status", Value: "200"},
+	{Name: ":status", Value: "304"},
+	{Name: ":status", Value: "404"},
+	{Name: ":status", Value: "503"},
+	{Name: "accept", Value: "*/*"},
+	{Name: "accept", Value: "application/dns-message"},
+	{Name: "accept-encoding", Value: "gzip, deflate, br"},
+	{Name: "accept-ranges", Value: "bytes"},
+	{Name: "access-control-allow-headers", Value: "cache-control"},
+	{Name: "access-control-allow-headers", Value: "content-type"},
+	{Name: "access-control-allow-origin", Value: "*"},
+	{Name: "cache-control", Value: "max-age=0"},
+	{Name: "cache-control", Value: "max-age=2592000"},
+	{Name: "cache-control", Value: "max-age=604800"},
+	{Name: "cache-control", Value: "no-cache"},
+	{Name: "cache-control", Value: "no-store"},
+	{Name: "cache-control", Value: "public, max-age=31536000"},
+	{Name: "content-encoding", Value: "br"},
+	{Name: "content-encoding", Value: "gzip"},
+	{Name: "content-type", Value: "application/dns-message"},
+	{Name: "content-type", Value: "application/javascript"},
+	{Name: "content-type", Value: "application/json"},
+	{Name: "content-type", Value: "application/x-www-form-urlencoded"},
+	{Name: "content-type", Value: "image/gif"},
+	{Name: "content-type", Value: "image/jpeg"},
+	{Name: "content-type", Value: "image/png"},
+	{Name: "content-type", Value: "text/css"},
+	{Name: "content-type", Value: "text/html; charset=utf-8"},
+	{Name: "content-type", Value: "text/plain"},
+	{Name: "content-type", Value: "text/plain;charset=utf-8"},
+	{Name: "range", Value: "bytes=0-"},
+	{Name: "strict-transport-security", Value: "max-age=31536000"},
+	{Name: "strict-transport-security", Value: "max-age=31536000; includesubdomains"},
+	{Name: "strict-transport-security", Value: "max-age=31536000; includesubdomains; preload"},
+	{Name: "vary", Value: "accept-encoding"},
+	{Name: "vary", Value: "origin"},
+	{Name: "x-content-type-options", Value: "nosniff"},
+	{Name: "x-xss-protection", Value: "1; mode=block"},
+	{Name: ":status", Value: "100"},
+	{Name: ":status", Value: "204"},
+	{Name: ":status", Value: "206"},
+	{Name: ":status", Value: "302"},
+	{Name: ":status", Value: "400"},
+	{Name: ":status", Value: "403"},
+	{Name: ":status", Value: "421"},
+	{Name: ":status", Value: "425"},
+	{Name: ":status", Value: "500"},
+	{Name: "accept-language"},
+	{Name: "access-control-allow-credentials", Value: "FALSE"},
+	{Name: "access-control-allow-credentials", Value: "TRUE"},
+	{Name: "access-control-allow-headers", Value: "*"},
+	{Name: "access-control-allow-methods", Value: "get"},
+	{Name: "access-control-allow-methods", Value: "get, post, options"},
+	{Name: "access-control-allow-methods", Value: "options"},
+	{Name: "access-control-expose-headers", Value: "content-length"},
+	{Name: "access-control-expose-headers", Value: "content-type"},
+	{Name: "access-control-request-method", Value: "get"},
+	{Name: "access-control-request-method", Value: "post"},
+	{Name: "alt-svc", Value: "clear"},
+	{Name: "authorization"},
+	{Name: "content-security-policy", Value: "script-src 'none'; object-src 'none'; base-uri 'none'"},
+	{Name: "early-data", Value: "1"},
+	{Name: "expect-ct"},
+	{Name: "forwarded"},
+	{Name: "if-range"},
+	{Name: "origin"},
+	{Name: "purpose", Value: "prefetch"},
+	{Name: "server"},
+	{Name: "timing-allow-origin", Value: "*"},
+	{Name: "upgrade-insecure-requests", Value: "1"},
+	{Name: "user-agent"},
+	{Name: "x-forwarded-for"},
+	{Name: "x-frame-options", Value: "deny"},
+	{Name: "x-frame-options", Value: "sameorigin"},
+}
+
+var (
+	// qpackStaticFieldIndex maps header fields to their index in the static table.
+	qpackStaticFieldIndex map[qpack.HeaderField]uint64
+	// qpackStaticNameIndex maps header names to the first index using that name in the static table.
+	qpackStaticNameIndex map[string]uint64
+)
+
+func init() {
+	qpackStaticFieldIndex = make(map[qpack.HeaderField]uint64, len(qpackStaticTable))
+	qpackStaticNameIndex = make(map[string]uint64)
+	for i, hf := range qpackStaticTable {
+		if _, ok := qpackStaticFieldIndex[hf]; !ok {
+			qpackStaticFieldIndex[hf] = uint64(i)
+		}
+		if _, ok := qpackStaticNameIndex[hf.Name]; !ok {
+			qpackStaticNameIndex[hf.Name] = uint64(i)
+		}
+	}
+}
+
+func qpackEntrySize(hf qpack.HeaderField) uint64 {
+	return uint64(len(hf.Name)+len(hf.Value)) + qpackEntryOverhead
+}
+
+// The qpackDynamicTable is the QPACK dynamic table.
+// Entries are identified by their absolute index, which is the number of entries inserted before them.
+type qpackDynamicTable struct {
+	maxCapacity uint64 // the maximum capacity, as advertised in the SETTINGS
+	capacity    uint64
+	size        uint64
+
+	entries []qpack.HeaderField // the oldest entry first
+	evicted uint64              // the number of entries that have been evicted
+}
+
+func newQPACKDynamicTable(maxCapacity uint64) *qpackDynamicTable {
+	return &qpackDynamicTable{maxCapacity: maxCapacity}
+}
+
+// maxEntries is the maximum number of entries the dynamic table can hold.
+// It is used to encode the Required Insert Count.
+func (t *qpackDynamicTable) maxEntries() uint64 {
+	return t.maxCapacity / qpackEntryOverhead
+}
+
+// insertCount is the total number of insertions into the dynamic table.
+func (t *qpackDynamicTable) insertCount() uint64 {
+	return t.evicted + uint64(len(t.entries))
+}
+
+// get returns the entry with the given absolute index.
+func (t *qpackDynamicTable) get(index uint64) (qpack.HeaderField, bool) {
+	if index < t.evicted || index >= t.insertCount() {
+		return qpack.HeaderField{}, false
+	}
+	return t.entries[index-t.evicted], true
+}
+
+// find returns the absolute index of the newest entry that matches the header field.
+// If there's no entry with the same value, it returns the index of the newest entry with the same name.
+func (t *qpackDynamicTable) find(hf qpack.HeaderField) (index uint64, nameOnly bool, ok bool) {
+	for i := len(t.entries) - 1; i >= 0; i-- {
+		e := t.entries[i]
+		if e.Name != hf.Name {
+			continue
+		}
+		if e.Value == hf.Value {
+			return t.evicted + uint64(i), false, true
+		}
+		if !ok {
+			index, nameOnly, ok = t.evicted+uint64(i), true, true
+		}
+	}
+	return
+}
+
+// canInsert says if an entry can be inserted, without evicting any entry with an absolute index of evictionLimit or larger.
+func (t *qpackDynamicTable) canInsert(hf qpack.HeaderField, evictionLimit uint64) bool {
+	needed := qpackEntrySize(hf)
+	if needed > t.capacity {
+		return false
+	}
+	available := t.capacity - t.size
+	for i := uint64(0); available < needed; i++ {
+		if i >= uint64(len(t.entries)) || t.evicted+i >= evictionLimit {
+			return false
+		}
+		available += qpackEntrySize(t.entries[i])
+	}
+	return true
+}
+
+func (t *qpackDynamicTable) insert(hf qpack.HeaderField) error {
+	size := qpackEntrySize(hf)
+	if size > t.capacity {
+		return errors.New("qpack: entry larger than the dynamic table capacity")
+	}
+	t.evict(t.capacity - size)
+	t.entries = append(t.entries, hf)
+	t.size += size
+	return nil
+}
+
+func (t *qpackDynamicTable) setCapacity(capacity uint64) error {
+	if capacity > t.maxCapacity {
+		return errors.New("qpack: dynamic table capacity exceeds the maximum")
+	}
+	t.capacity = capacity
+	t.evict(capacity)
+	return nil
+}
+
+// evict evicts the oldest entries until the size of the table is not larger than maxSize.
+func (t *qpackDynamicTable) evict(maxSize uint64) {
+	for t.size > maxSize {
+		t.size -= qpackEntrySize(t.entries[0])
+		t.entries[0] = qpack.HeaderField{}
+		t.entries = t.entries[1:]
+		t.evicted++
+	}
+}
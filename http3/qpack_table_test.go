@@ -0,0 +1,96 @@
+package http3
+
+import (
+	"github.com/marten-seemann/qpack"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("QPACK dynamic table", func() {
+	var table *qpackDynamicTable
+
+	// all entries used in these tests have a size of 32 + 4 = 36 bytes
+	entry := func(name string) qpack.HeaderField {
+		return qpack.HeaderField{Name: name, Value: "va"}
+	}
+
+	BeforeEach(func() {
+		table = newQPACKDynamicTable(200)
+		Expect(table.setCapacity(100)).To(Succeed())
+	})
+
+	It("has a static table", func() {
+		Expect(qpackStaticTable).To(HaveLen(99))
+		Expect(qpackStaticFieldIndex[qpack.HeaderField{Name: ":method", Value: "GET"}]).To(BeEquivalentTo(17))
+		Expect(qpackStaticNameIndex["content-type"]).To(BeEquivalentTo(44))
+	})
+
+	It("inserts entries", func() {
+		Expect(table.insert(entry("n1"))).To(Succeed())
+		Expect(table.insert(entry("n2"))).To(Succeed())
+		Expect(table.insertCount()).To(BeEquivalentTo(2))
+		Expect(table.size).To(BeEquivalentTo(72))
+		hf, ok := table.get(1)
+		Expect(ok).To(BeTrue())
+		Expect(hf).To(Equal(entry("n2")))
+		_, ok = table.get(2)
+		Expect(ok).To(BeFalse())
+	})
+
+	It("evicts the oldest entries", func() {
+		Expect(table.insert(entry("n1"))).To(Succeed())
+		Expect(table.insert(entry("n2"))).To(Succeed())
+		Expect(table.insert(entry("n3"))).To(Succeed())
+		Expect(table.insertCount()).To(BeEquivalentTo(3))
+		Expect(table.size).To(BeEquivalentTo(72))
+		_, ok := table.get(0)
+		Expect(ok).To(BeFalse())
+		hf, ok := table.get(2)
+		Expect(ok).To(BeTrue())
+		Expect(hf).To(Equal(entry("n3")))
+	})
+
+	It("rejects entries larger than the capacity", func() {
+		Expect(table.insert(qpack.HeaderField{Name: "name", Value: string(make([]byte, 100))})).ToNot(Succeed())
+	})
+
+	It("finds entries", func() {
+		Expect(table.insert(entry("n1"))).To(Succeed())
+		Expect(table.insert(qpack.HeaderField{Name: "n1", Value: "foo"})).To(Succeed())
+		index, nameOnly, ok := table.find(entry("n1"))
+		Expect(ok).To(BeTrue())
+		Expect(nameOnly).To(BeFalse())
+		Expect(index).To(BeZero())
+		index, nameOnly, ok = table.find(qpack.HeaderField{Name: "n1", Value: "bar"})
+		Expect(ok).To(BeTrue())
+		Expect(nameOnly).To(BeTrue())
+		Expect(index).To(BeEquivalentTo(1))
+		_, _, ok = table.find(entry("n2"))
+		Expect(ok).To(BeFalse())
+	})
+
+	It("says if an entry can be inserted without evicting entries that are still needed", func() {
+		Expect(table.insert(entry("n1"))).To(Succeed())
+		Expect(table.insert(entry("n2"))).To(Succeed())
+		Expect(table.canInsert(entry("n3"), 0)).To(BeFalse())
+		Expect(table.canInsert(entry("n3"), 1)).To(BeTrue())
+		Expect(table.canInsert(qpack.HeaderField{Name: "n3", Value: string(make([]byte, 60))}, 1)).To(BeFalse())
+		Expect(table.canInsert(qpack.HeaderField{Name: "n3", Value: string(make([]byte, 60))}, 2)).To(BeTrue())
+	})
+
+	It("evicts entries when the capacity is reduced", func() {
+		Expect(table.insert(entry("n1"))).To(Succeed())
+		Expect(table.insert(entry("n2"))).To(Succeed())
+		Expect(table.setCapacity(40)).To(Succeed())
+		Expect(table.insertCount()).To(BeEquivalentTo(2))
+		_, ok := table.get(0)
+		Expect(ok).To(BeFalse())
+		_, ok = table.get(1)
+		Expect(ok).To(BeTrue())
+	})
+
+	It("doesn't allow setting a capacity larger than the maximum", func() {
+		Expect(table.setCapacity(201)).ToNot(Succeed())
+	})
+})
@@ -37,6 +37,15 @@ func requestFromHeaders(headers []qpack.HeaderField) (*http.Request, error) {
 		httpHeaders.Set("Cookie", strings.Join(httpHeaders["Cookie"], "; "))
 	}
 
+	var trailer http.Header
+	if vv, ok := httpHeaders["Trailer"]; ok {
+		trailer = http.Header{}
+		for _, key := range declaredTrailers(vv) {
+			trailer[key] = nil
+		}
+		delete(httpHeaders, "Trailer")
+	}
+
 	if len(path) == 0 || len(authority) == 0 || len(method) == 0 {
 		return nil, errors.New(":path, :authority and :method must not be empty")
 	}
@@ -63,12 +72,23 @@ func requestFromHeaders(headers []qpack.HeaderField) (*http.Request, error) {
 		Header:        httpHeaders,
 		Body:          nil,
 		ContentLength: contentLength,
+		Trailer:       trailer,
 		Host:          authority,
 		RequestURI:    path,
 		TLS:           &tls.ConnectionState{},
 	}, nil
 }
 
+// headerListSize calculates the size of a header list.
+// Every header field has an overhead of 32 bytes.
+func headerListSize(hfs []qpack.HeaderField) uint64 {
+	var size uint64
+	for _, hf := range hfs {
+		size += uint64(len(hf.Name) + len(hf.Value) + 32)
+	}
+	return size
+}
+
 func hostnameFromRequest(req *http.Request) string {
 	if req.URL != nil {
 		return req.URL.Host
@@ -2,15 +2,18 @@ package http3
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
+	"math"
 	"net"
 	"net/http"
 	"strconv"
 	"strings"
-	"sync"
+	"sync/atomic"
 
 	"github.com/lucas-clemente/quic-go"
+	"github.com/lucas-clemente/quic-go/internal/protocol"
 	"github.com/lucas-clemente/quic-go/internal/utils"
 	"github.com/marten-seemann/qpack"
 	"golang.org/x/net/http/httpguts"
@@ -18,34 +21,42 @@ import (
 	"golang.org/x/net/idna"
 )
 
+// errRequestHeaderListSize is returned when the request header is larger than the limit advertised by the server.
+var errRequestHeaderListSize = errors.New("http3: request header list larger than peer's advertised limit")
+
 type requestWriter struct {
-	mutex     sync.Mutex
-	encoder   *qpack.Encoder
-	headerBuf *bytes.Buffer
+	encoder *qpackEncoder
+
+	peerMaxHeaderListSize uint64 // accessed atomically
 
 	logger utils.Logger
 }
 
-func newRequestWriter(logger utils.Logger) *requestWriter {
-	headerBuf := &bytes.Buffer{}
-	encoder := qpack.NewEncoder(headerBuf)
+func newRequestWriter(encoder *qpackEncoder, logger utils.Logger) *requestWriter {
 	return &requestWriter{
-		encoder:   encoder,
-		headerBuf: headerBuf,
-		logger:    logger,
+		encoder:               encoder,
+		peerMaxHeaderListSize: math.MaxUint64,
+		logger:                logger,
 	}
 }
 
+// setPeerMaxHeaderListSize sets the SETTINGS_MAX_HEADER_LIST_SIZE sent by the server.
+func (w *requestWriter) setPeerMaxHeaderListSize(size uint64) {
+	atomic.StoreUint64(&w.peerMaxHeaderListSize, size)
+}
+
 func (w *requestWriter) WriteRequest(str quic.Stream, req *http.Request, gzip bool) error {
-	headers, err := w.getHeaders(req, gzip)
+	headers, err := w.getHeaders(str.StreamID(), req, gzip)
 	if err != nil {
 		return err
 	}
 	if _, err := str.Write(headers); err != nil {
 		return err
 	}
-	// TODO: add support for trailers
 	if req.Body == nil {
+		if err := w.writeTrailers(str, req); err != nil {
+			return err
+		}
 		str.Close()
 		return nil
 	}
@@ -56,29 +67,49 @@ func (w *requestWriter) WriteRequest(str quic.Stream, req *http.Request, gzip bo
 			w.logger.Errorf("Error writing request: %s", err)
 			return
 		}
+		// The trailers might have been set while the body was sent.
+		if err := w.writeTrailers(str, req); err != nil {
+			w.logger.Errorf("Error writing trailers: %s", err)
+			str.CancelWrite(quic.ErrorCode(errorRequestCanceled))
+			return
+		}
 		str.Close()
 	}()
 
 	return nil
 }
 
-func (w *requestWriter) getHeaders(req *http.Request, gzip bool) ([]byte, error) {
-	w.mutex.Lock()
-	defer w.mutex.Unlock()
-	defer w.encoder.Close()
-
-	if err := w.encodeHeaders(req, gzip, "", actualContentLength(req)); err != nil {
+func (w *requestWriter) getHeaders(id protocol.StreamID, req *http.Request, gzip bool) ([]byte, error) {
+	trailers, err := commaSeparatedTrailers(req)
+	if err != nil {
 		return nil, err
 	}
-
-	buf := &bytes.Buffer{}
-	hf := headersFrame{Length: uint64(w.headerBuf.Len())}
-	hf.Write(buf)
-	if _, err := io.Copy(buf, w.headerBuf); err != nil {
+	hfs, err := w.encodeHeaders(req, gzip, trailers, actualContentLength(req))
+	if err != nil {
+		return nil, err
+	}
+	headerBlock, err := w.encoder.encode(id, hfs)
+	if err != nil {
 		return nil, err
 	}
-	w.headerBuf.Reset()
-	return buf.Bytes(), nil
+	return headersFrameBytes(headerBlock), nil
+}
+
+// writeTrailers sends the trailers of a request, if there are any.
+func (w *requestWriter) writeTrailers(str quic.Stream, req *http.Request) error {
+	if len(req.Trailer) == 0 {
+		return nil
+	}
+	hfs, err := encodeTrailers(req.Trailer)
+	if err != nil {
+		return err
+	}
+	headerBlock, err := w.encoder.encode(str.StreamID(), hfs)
+	if err != nil {
+		return err
+	}
+	_, err = str.Write(headersFrameBytes(headerBlock))
+	return err
 }
 
 func (w *requestWriter) sendRequestBody(req io.ReadCloser, str quic.Stream) error {
@@ -107,14 +138,14 @@ func (w *requestWriter) sendRequestBody(req io.ReadCloser, str quic.Stream) erro
 
 // copied from net/transport.go
 
-func (w *requestWriter) encodeHeaders(req *http.Request, addGzipHeader bool, trailers string, contentLength int64) error {
+func (w *requestWriter) encodeHeaders(req *http.Request, addGzipHeader bool, trailers string, contentLength int64) ([]qpack.HeaderField, error) {
 	host := req.Host
 	if host == "" {
 		host = req.URL.Host
 	}
 	host, err := httpguts.PunycodeHostPort(host)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	var path string
@@ -125,9 +156,9 @@ func (w *requestWriter) encodeHeaders(req *http.Request, addGzipHeader bool, tra
 			path = strings.TrimPrefix(path, req.URL.Scheme+"://"+host)
 			if !validPseudoPath(path) {
 				if req.URL.Opaque != "" {
-					return fmt.Errorf("invalid request :path %q from URL.Opaque = %q", orig, req.URL.Opaque)
+					return nil, fmt.Errorf("invalid request :path %q from URL.Opaque = %q", orig, req.URL.Opaque)
 				} else {
-					return fmt.Errorf("invalid request :path %q", orig)
+					return nil, fmt.Errorf("invalid request :path %q", orig)
 				}
 			}
 		}
@@ -138,11 +169,11 @@ func (w *requestWriter) encodeHeaders(req *http.Request, addGzipHeader bool, tra
 	// continue to reuse the hpack encoder for future requests)
 	for k, vv := range req.Header {
 		if !httpguts.ValidHeaderFieldName(k) {
-			return fmt.Errorf("invalid HTTP header name %q", k)
+			return nil, fmt.Errorf("invalid HTTP header name %q", k)
 		}
 		for _, v := range vv {
 			if !httpguts.ValidHeaderFieldValue(v) {
-				return fmt.Errorf("invalid HTTP header value %q for header %q", v, k)
+				return nil, fmt.Errorf("invalid HTTP header value %q for header %q", v, k)
 			}
 		}
 	}
@@ -209,33 +240,33 @@ func (w *requestWriter) encodeHeaders(req *http.Request, addGzipHeader bool, tra
 	}
 
 	// Do a first pass over the headers counting bytes to ensure
-	// we don't exceed cc.peerMaxHeaderListSize. This is done as a
+	// we don't exceed the peer's SETTINGS_MAX_HEADER_LIST_SIZE. This is done as a
 	// separate pass before encoding the headers to prevent
-	// modifying the hpack state.
+	// modifying the QPACK state.
 	hlSize := uint64(0)
 	enumerateHeaders(func(name, value string) {
 		hf := hpack.HeaderField{Name: name, Value: value}
 		hlSize += uint64(hf.Size())
 	})
 
-	// TODO: check maximum header list size
-	// if hlSize > cc.peerMaxHeaderListSize {
-	// 	return errRequestHeaderListSize
-	// }
+	if hlSize > atomic.LoadUint64(&w.peerMaxHeaderListSize) {
+		return nil, errRequestHeaderListSize
+	}
 
 	// trace := httptrace.ContextClientTrace(req.Context())
 	// traceHeaders := traceHasWroteHeaderField(trace)
 
 	// Header list size is ok. Write the headers.
+	var hfs []qpack.HeaderField
 	enumerateHeaders(func(name, value string) {
 		name = strings.ToLower(name)
-		w.encoder.WriteField(qpack.HeaderField{Name: name, Value: value})
+		hfs = append(hfs, qpack.HeaderField{Name: name, Value: value})
 		// if traceHeaders {
 		// 	traceWroteHeaderField(trace, name, value)
 		// }
 	})
 
-	return nil
+	return hfs, nil
 }
 
 // authorityAddr returns a given authority (a host/IP, or host:port / ip:port)
@@ -262,8 +293,8 @@ func authorityAddr(scheme string, authority string) (addr string) {
 // validPseudoPath reports whether v is a valid :path pseudo-header
 // value. It must be either:
 //
-//     *) a non-empty string starting with '/'
-//     *) the string '*', for OPTIONS requests.
+//	*) a non-empty string starting with '/'
+//	*) the string '*', for OPTIONS requests.
 //
 // For now this is only used a quick check for deciding when to clean
 // up Opaque URLs before sending requests from the Transport.
@@ -42,9 +42,10 @@ var _ = Describe("Request Writer", func() {
 	}
 
 	BeforeEach(func() {
-		rw = newRequestWriter(utils.DefaultLogger)
+		rw = newRequestWriter(newQPACKEncoder(0, nil), utils.DefaultLogger)
 		strBuf = &bytes.Buffer{}
 		str = mockquic.NewMockStream(mockCtrl)
+		str.EXPECT().StreamID().AnyTimes()
 		str.EXPECT().Write(gomock.Any()).DoAndReturn(func(p []byte) (int, error) {
 			return strBuf.Write(p)
 		}).AnyTimes()
@@ -84,6 +85,34 @@ var _ = Describe("Request Writer", func() {
 		Expect(frame.(*dataFrame).Length).To(BeEquivalentTo(6))
 	})
 
+	It("sends trailers", func() {
+		closed := make(chan struct{})
+		str.EXPECT().Close().Do(func() { close(closed) })
+		req, err := http.NewRequest("POST", "https://quic.clemente.io/upload.html", bytes.NewReader([]byte("foobar")))
+		Expect(err).ToNot(HaveOccurred())
+		req.Trailer = http.Header{"Foo": nil, "Bar": nil}
+		Expect(rw.WriteRequest(str, req, false)).To(Succeed())
+		// the values of the trailers are set while the body is sent
+		req.Trailer.Set("Foo", "foo")
+		req.Trailer.Set("Bar", "bar")
+		Eventually(closed).Should(BeClosed())
+		headerFields := decode(strBuf)
+		Expect(headerFields).To(HaveKeyWithValue("trailer", "Bar,Foo"))
+		frame, err := parseNextFrame(strBuf)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(frame).To(BeAssignableToTypeOf(&dataFrame{}))
+		strBuf.Next(int(frame.(*dataFrame).Length))
+		trailers := decode(strBuf)
+		Expect(trailers).To(Equal(map[string]string{"foo": "foo", "bar": "bar"}))
+	})
+
+	It("rejects invalid trailers", func() {
+		req, err := http.NewRequest("POST", "https://quic.clemente.io/upload.html", bytes.NewReader([]byte("foobar")))
+		Expect(err).ToNot(HaveOccurred())
+		req.Trailer = http.Header{"Content-Length": nil}
+		Expect(rw.WriteRequest(str, req, false)).To(MatchError(`invalid Trailer key "Content-Length"`))
+	})
+
 	It("sends cookies", func() {
 		str.EXPECT().Close()
 		req, err := http.NewRequest("GET", "https://quic.clemente.io/", nil)
@@ -7,12 +7,34 @@ import (
 )
 
 type responseBody struct {
-	quic.Stream
+	quic.ReceiveStream
+
+	// reqDone is closed when the application is done with the response body.
+	// It is nil for pushed responses.
+	reqDone       chan<- struct{}
+	reqDoneClosed bool
 }
 
 var _ io.ReadCloser = &responseBody{}
 
+func (rb *responseBody) requestDone() {
+	if rb.reqDone == nil || rb.reqDoneClosed {
+		return
+	}
+	close(rb.reqDone)
+	rb.reqDoneClosed = true
+}
+
+func (rb *responseBody) Read(b []byte) (int, error) {
+	n, err := rb.ReceiveStream.Read(b)
+	if err != nil {
+		rb.requestDone()
+	}
+	return n, err
+}
+
 func (rb *responseBody) Close() error {
-	rb.Stream.CancelRead(0)
+	rb.requestDone()
+	rb.ReceiveStream.CancelRead(0)
 	return nil
 }
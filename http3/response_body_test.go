@@ -1,6 +1,8 @@
 package http3
 
 import (
+	"io"
+
 	"github.com/golang/mock/gomock"
 	mockquic "github.com/lucas-clemente/quic-go/internal/mocks/quic"
 
@@ -16,11 +18,35 @@ var _ = Describe("Response Body", func() {
 
 	BeforeEach(func() {
 		stream = mockquic.NewMockStream(mockCtrl)
-		body = &responseBody{stream}
+		body = &responseBody{ReceiveStream: stream}
 	})
 
 	It("calls CancelRead when closing", func() {
 		stream.EXPECT().CancelRead(gomock.Any())
 		Expect(body.Close()).To(Succeed())
 	})
+
+	It("signals that the request is done when closing", func() {
+		reqDone := make(chan struct{})
+		body.reqDone = reqDone
+		stream.EXPECT().CancelRead(gomock.Any()).Times(2)
+		Expect(body.Close()).To(Succeed())
+		Expect(reqDone).To(BeClosed())
+		// closing the body again doesn't panic
+		Expect(body.Close()).To(Succeed())
+	})
+
+	It("signals that the request is done when reading fails", func() {
+		reqDone := make(chan struct{})
+		body.reqDone = reqDone
+		stream.EXPECT().Read(gomock.Any()).Return(2, nil)
+		n, err := body.Read(make([]byte, 2))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(n).To(Equal(2))
+		Expect(reqDone).ToNot(BeClosed())
+		stream.EXPECT().Read(gomock.Any()).Return(0, io.EOF)
+		_, err = body.Read(make([]byte, 2))
+		Expect(err).To(MatchError(io.EOF))
+		Expect(reqDone).To(BeClosed())
+	})
 })
@@ -2,35 +2,62 @@ package http3
 
 import (
 	"bytes"
+	"context"
+	"errors"
+	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"strconv"
 	"strings"
 
+	"github.com/lucas-clemente/quic-go/internal/protocol"
 	"github.com/lucas-clemente/quic-go/internal/utils"
 	"github.com/marten-seemann/qpack"
+	"golang.org/x/net/http/httpguts"
 )
 
+// responseWriterBufferSize is the size of the buffer used to coalesce small writes into a single DATA frame.
+const responseWriterBufferSize = 4096
+
 type responseWriter struct {
-	stream io.Writer
+	stream   io.Writer
+	streamID protocol.StreamID
+	encoder  *qpackEncoder
+
+	buf []byte // body data that was written, but not yet sent in a DATA frame
 
 	header        http.Header
 	status        int // status code passed to WriteHeader
 	headerWritten bool
+	trailers      []string // the trailers declared in the Trailer header
+
+	// pusher is nil if pushing is not possible on this stream,
+	// e.g. for responses that are pushed themselves.
+	pusher    pusher
+	authority string // the authority of the request, used for pushing relative paths
 
 	logger utils.Logger
 }
 
 var _ http.ResponseWriter = &responseWriter{}
+var _ http.Pusher = &responseWriter{}
 
-func newResponseWriter(stream io.Writer, logger utils.Logger) *responseWriter {
+func newResponseWriter(stream io.Writer, encoder *qpackEncoder, streamID protocol.StreamID, logger utils.Logger) *responseWriter {
 	return &responseWriter{
-		header: http.Header{},
-		stream: stream,
-		logger: logger,
+		header:   http.Header{},
+		stream:   stream,
+		streamID: streamID,
+		encoder:  encoder,
+		logger:   logger,
 	}
 }
 
+func (w *responseWriter) enablePush(p pusher, authority string) {
+	w.pusher = p
+	w.authority = authority
+}
+
 func (w *responseWriter) Header() http.Header {
 	return w.header
 }
@@ -42,27 +69,76 @@ func (w *responseWriter) WriteHeader(status int) {
 	w.headerWritten = true
 	w.status = status
 
-	var headers bytes.Buffer
-	enc := qpack.NewEncoder(&headers)
-	enc.WriteField(qpack.HeaderField{Name: ":status", Value: strconv.Itoa(status)})
-
+	hfs := []qpack.HeaderField{{Name: ":status", Value: strconv.Itoa(status)}}
 	for k, v := range w.header {
+		// Headers using the http.TrailerPrefix are sent as trailers.
+		if strings.HasPrefix(k, http.TrailerPrefix) {
+			continue
+		}
+		if k == "Trailer" {
+			w.trailers = declaredTrailers(v)
+		}
 		for index := range v {
-			enc.WriteField(qpack.HeaderField{Name: strings.ToLower(k), Value: v[index]})
+			hfs = append(hfs, qpack.HeaderField{Name: strings.ToLower(k), Value: v[index]})
 		}
 	}
+	headers, err := w.encoder.encode(w.streamID, hfs)
+	if err != nil {
+		w.logger.Errorf("could not encode headers: %s", err.Error())
+		return
+	}
 
 	buf := &bytes.Buffer{}
-	(&headersFrame{Length: uint64(headers.Len())}).Write(buf)
+	(&headersFrame{Length: uint64(len(headers))}).Write(buf)
 	w.logger.Infof("Responding with %d", status)
 	if _, err := w.stream.Write(buf.Bytes()); err != nil {
 		w.logger.Errorf("could not write headers frame: %s", err.Error())
 	}
-	if _, err := w.stream.Write(headers.Bytes()); err != nil {
+	if _, err := w.stream.Write(headers); err != nil {
 		w.logger.Errorf("could not write header frame payload: %s", err.Error())
 	}
 }
 
+// writeTrailers sends the trailers, after the handler returned.
+// Trailers are either declared in the Trailer header before writing the header,
+// or set using the http.TrailerPrefix.
+func (w *responseWriter) writeTrailers() {
+	// The trailers are sent after all DATA frames.
+	if err := w.flushData(); err != nil {
+		w.logger.Errorf("could not flush to stream: %s", err.Error())
+		return
+	}
+	trailer := http.Header{}
+	for _, k := range w.trailers {
+		if vv, ok := w.header[k]; ok {
+			trailer[k] = vv
+		}
+	}
+	for k, vv := range w.header {
+		if strings.HasPrefix(k, http.TrailerPrefix) {
+			trailer[http.CanonicalHeaderKey(strings.TrimPrefix(k, http.TrailerPrefix))] = vv
+		}
+	}
+	if len(trailer) == 0 {
+		return
+	}
+	hfs, err := encodeTrailers(trailer)
+	if err != nil {
+		w.logger.Errorf("could not encode trailers: %s", err.Error())
+		return
+	}
+	headerBlock, err := w.encoder.encode(w.streamID, hfs)
+	if err != nil {
+		w.logger.Errorf("could not encode trailers: %s", err.Error())
+		return
+	}
+	if _, err := w.stream.Write(headersFrameBytes(headerBlock)); err != nil {
+		w.logger.Errorf("could not write trailers: %s", err.Error())
+	}
+}
+
+// Write buffers small writes, such that they are sent in a single DATA frame.
+// Call Flush to send the buffered data.
 func (w *responseWriter) Write(p []byte) (int, error) {
 	if !w.headerWritten {
 		w.WriteHeader(200)
@@ -70,19 +146,137 @@ func (w *responseWriter) Write(p []byte) (int, error) {
 	if !bodyAllowedForStatus(w.status) {
 		return 0, http.ErrBodyNotAllowed
 	}
-	df := &dataFrame{Length: uint64(len(p))}
+	if len(w.buf)+len(p) <= responseWriterBufferSize {
+		w.buf = append(w.buf, p...)
+		return len(p), nil
+	}
+	if err := w.flushData(); err != nil {
+		return 0, err
+	}
+	if len(p) < responseWriterBufferSize {
+		w.buf = append(w.buf, p...)
+		return len(p), nil
+	}
+	if err := w.writeDataFrame(p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// flushData sends the buffered data in a DATA frame.
+func (w *responseWriter) flushData() error {
+	if len(w.buf) == 0 {
+		return nil
+	}
+	err := w.writeDataFrame(w.buf)
+	w.buf = w.buf[:0]
+	return err
+}
+
+func (w *responseWriter) writeDataFrame(p []byte) error {
 	buf := &bytes.Buffer{}
-	df.Write(buf)
+	(&dataFrame{Length: uint64(len(p))}).Write(buf)
 	if _, err := w.stream.Write(buf.Bytes()); err != nil {
-		return 0, err
+		return err
+	}
+	_, err := w.stream.Write(p)
+	return err
+}
+
+// Flush sends the header and all buffered data.
+func (w *responseWriter) Flush() {
+	if !w.headerWritten {
+		w.WriteHeader(200)
+	}
+	if err := w.flushData(); err != nil {
+		w.logger.Errorf("could not flush to stream: %s", err.Error())
 	}
-	return w.stream.Write(p)
 }
 
-func (w *responseWriter) Flush() {}
+// Push implements the http.Pusher interface.
+// The validation of the promised request is copied from http2/server.go.
+func (w *responseWriter) Push(target string, opts *http.PushOptions) error {
+	if w.pusher == nil {
+		return http.ErrNotSupported
+	}
+	if opts == nil {
+		opts = &http.PushOptions{}
+	}
+	if opts.Method == "" {
+		opts.Method = "GET"
+	}
+	// The RFC effectively limits promised requests to GET and HEAD.
+	if opts.Method != "GET" && opts.Method != "HEAD" {
+		return fmt.Errorf("method %q must be GET or HEAD", opts.Method)
+	}
+
+	u, err := url.Parse(target)
+	if err != nil {
+		return err
+	}
+	if u.Scheme == "" {
+		if !strings.HasPrefix(target, "/") {
+			return fmt.Errorf("target must be an absolute URL or an absolute path: %q", target)
+		}
+		u.Scheme = "https"
+		u.Host = w.authority
+	} else {
+		if u.Scheme != "https" {
+			return fmt.Errorf("cannot push URL with scheme %q from request with scheme %q", u.Scheme, "https")
+		}
+		if u.Host == "" {
+			return errors.New("URL must have a host")
+		}
+	}
+
+	headers := []qpack.HeaderField{
+		{Name: ":method", Value: opts.Method},
+		{Name: ":scheme", Value: u.Scheme},
+		{Name: ":authority", Value: u.Host},
+		{Name: ":path", Value: u.RequestURI()},
+	}
+	for k, vv := range opts.Header {
+		if strings.HasPrefix(k, ":") {
+			return fmt.Errorf("promised request headers cannot include pseudo header %q", k)
+		}
+		// These headers are meaningful only if the request has a body,
+		// but PUSH_PROMISE requests cannot have a body.
+		switch strings.ToLower(k) {
+		case "content-length", "content-encoding", "trailer", "te", "expect", "host":
+			return fmt.Errorf("promised request headers cannot include %q", k)
+		}
+		if !httpguts.ValidHeaderFieldName(k) {
+			return fmt.Errorf("invalid HTTP header name %q", k)
+		}
+		for _, v := range vv {
+			if !httpguts.ValidHeaderFieldValue(v) {
+				return fmt.Errorf("invalid HTTP header value %q for header %q", v, k)
+			}
+			headers = append(headers, qpack.HeaderField{Name: strings.ToLower(k), Value: v})
+		}
+	}
+	// Send the PUSH_PROMISE after the data that was written before.
+	if err := w.flushData(); err != nil {
+		return err
+	}
+	return w.pusher.push(w.stream, w.streamID, headers)
+}
 
-// This is a NOP. Use http.Request.Context
-func (w *responseWriter) CloseNotify() <-chan bool { return make(<-chan bool) }
+// CloseNotify implements the http.CloseNotifier interface.
+// The channel fires when the stream is closed. Handlers should use http.Request.Context instead.
+func (w *responseWriter) CloseNotify() <-chan bool {
+	c := make(chan bool, 1)
+	// The context of a QUIC stream is canceled when the stream is closed.
+	str, ok := w.stream.(interface{ Context() context.Context })
+	if !ok {
+		return c
+	}
+	go func() {
+		<-str.Context().Done()
+		c <- true
+	}()
+	return c
+}
 
 // test that we implement http.Flusher
 var _ http.Flusher = &responseWriter{}
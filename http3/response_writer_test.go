@@ -2,9 +2,12 @@ package http3
 
 import (
 	"bytes"
+	"context"
 	"io"
 	"net/http"
 
+	mockquic "github.com/lucas-clemente/quic-go/internal/mocks/quic"
+	"github.com/lucas-clemente/quic-go/internal/protocol"
 	"github.com/lucas-clemente/quic-go/internal/utils"
 	"github.com/marten-seemann/qpack"
 
@@ -20,7 +23,7 @@ var _ = Describe("Response Writer", func() {
 
 	BeforeEach(func() {
 		strBuf = &bytes.Buffer{}
-		rw = newResponseWriter(strBuf, utils.DefaultLogger)
+		rw = newResponseWriter(strBuf, newQPACKEncoder(0, nil), 0, utils.DefaultLogger)
 	})
 
 	decodeHeader := func(str io.Reader) map[string][]string {
@@ -84,6 +87,7 @@ var _ = Describe("Response Writer", func() {
 		n, err := rw.Write([]byte("foobar"))
 		Expect(n).To(Equal(6))
 		Expect(err).ToNot(HaveOccurred())
+		rw.Flush()
 		// Should have written 200 on the header stream
 		fields := decodeHeader(strBuf)
 		Expect(fields).To(HaveKeyWithValue(":status", []string{"200"}))
@@ -96,6 +100,7 @@ var _ = Describe("Response Writer", func() {
 		n, err := rw.Write([]byte("foobar"))
 		Expect(n).To(Equal(6))
 		Expect(err).ToNot(HaveOccurred())
+		rw.Flush()
 		// Should have written 418 on the header stream
 		fields := decodeHeader(strBuf)
 		Expect(fields).To(HaveKeyWithValue(":status", []string{"418"}))
@@ -111,10 +116,165 @@ var _ = Describe("Response Writer", func() {
 		Expect(fields).To(HaveKeyWithValue(":status", []string{"200"}))
 	})
 
+	It("writes trailers", func() {
+		rw.Header().Set("Trailer", "Declared, Content-Length")
+		rw.Header().Set(http.TrailerPrefix+"Undeclared", "bar")
+		rw.WriteHeader(http.StatusOK)
+		rw.Header().Set("Declared", "foo")
+		rw.writeTrailers()
+		fields := decodeHeader(strBuf)
+		Expect(fields).To(HaveKeyWithValue("trailer", []string{"Declared, Content-Length"}))
+		Expect(fields).ToNot(HaveKey("trailer:undeclared"))
+		trailers := decodeHeader(strBuf)
+		Expect(trailers).To(Equal(map[string][]string{
+			"declared":   {"foo"},
+			"undeclared": {"bar"},
+		}))
+	})
+
+	It("doesn't write trailers if there are none", func() {
+		rw.WriteHeader(http.StatusOK)
+		decodeHeader(strBuf)
+		rw.writeTrailers()
+		Expect(strBuf.Len()).To(BeZero())
+	})
+
+	It("coalesces small writes into a single DATA frame", func() {
+		rw.Write([]byte("foo"))
+		rw.Write([]byte("bar"))
+		fields := decodeHeader(strBuf)
+		Expect(fields).To(HaveKeyWithValue(":status", []string{"200"}))
+		Expect(strBuf.Len()).To(BeZero())
+		rw.Flush()
+		Expect(getData(strBuf)).To(Equal([]byte("foobar")))
+		Expect(strBuf.Len()).To(BeZero())
+	})
+
+	It("sends the buffered data when the buffer is full", func() {
+		rw.WriteHeader(http.StatusOK)
+		decodeHeader(strBuf)
+		data := bytes.Repeat([]byte{'a'}, responseWriterBufferSize-2)
+		rw.Write(data)
+		Expect(strBuf.Len()).To(BeZero())
+		rw.Write([]byte("foobar"))
+		Expect(getData(strBuf)).To(Equal(data))
+		Expect(strBuf.Len()).To(BeZero())
+		rw.Flush()
+		Expect(getData(strBuf)).To(Equal([]byte("foobar")))
+	})
+
+	It("doesn't buffer large writes", func() {
+		rw.Write([]byte("foo"))
+		data := bytes.Repeat([]byte{'a'}, responseWriterBufferSize)
+		n, err := rw.Write(data)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(n).To(Equal(len(data)))
+		decodeHeader(strBuf)
+		Expect(getData(strBuf)).To(Equal([]byte("foo")))
+		Expect(getData(strBuf)).To(Equal(data))
+	})
+
+	It("writes the header when flushing", func() {
+		rw.Flush()
+		fields := decodeHeader(strBuf)
+		Expect(fields).To(HaveKeyWithValue(":status", []string{"200"}))
+		Expect(strBuf.Len()).To(BeZero())
+	})
+
+	It("sends the buffered data before the trailers", func() {
+		rw.Header().Set(http.TrailerPrefix+"Foo", "bar")
+		rw.Write([]byte("foobar"))
+		rw.writeTrailers()
+		decodeHeader(strBuf)
+		Expect(getData(strBuf)).To(Equal([]byte("foobar")))
+		Expect(decodeHeader(strBuf)).To(HaveKeyWithValue("foo", []string{"bar"}))
+	})
+
+	Context("CloseNotify", func() {
+		It("fires when the stream is closed", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			str := mockquic.NewMockStream(mockCtrl)
+			str.EXPECT().Context().Return(ctx)
+			rw = newResponseWriter(str, newQPACKEncoder(0, nil), 0, utils.DefaultLogger)
+			closeNotify := rw.CloseNotify()
+			Consistently(closeNotify).ShouldNot(Receive())
+			cancel()
+			Eventually(closeNotify).Should(Receive(BeTrue()))
+		})
+
+		It("never fires if the writer is not a stream", func() {
+			Consistently(rw.CloseNotify()).ShouldNot(Receive())
+		})
+	})
+
 	It("doesn't allow writes if the status code doesn't allow a body", func() {
 		rw.WriteHeader(304)
 		n, err := rw.Write([]byte("foobar"))
 		Expect(n).To(BeZero())
 		Expect(err).To(MatchError(http.ErrBodyNotAllowed))
 	})
+
+	Context("pushing", func() {
+		var pushedHeaders []qpack.HeaderField
+
+		BeforeEach(func() {
+			pushedHeaders = nil
+			rw.enablePush(pusherFunc(func(reqStr io.Writer, headers []qpack.HeaderField) error {
+				Expect(reqStr).To(Equal(strBuf))
+				pushedHeaders = headers
+				return nil
+			}), "example.com")
+		})
+
+		It("returns ErrNotSupported if push is disabled", func() {
+			rw = newResponseWriter(strBuf, newQPACKEncoder(0, nil), 0, utils.DefaultLogger)
+			Expect(rw.Push("/foo", nil)).To(MatchError(http.ErrNotSupported))
+		})
+
+		It("pushes a relative path", func() {
+			Expect(rw.Push("/foo?bar=baz", &http.PushOptions{
+				Header: http.Header{"Accept-Encoding": []string{"gzip"}},
+			})).To(Succeed())
+			Expect(pushedHeaders).To(Equal([]qpack.HeaderField{
+				{Name: ":method", Value: "GET"},
+				{Name: ":scheme", Value: "https"},
+				{Name: ":authority", Value: "example.com"},
+				{Name: ":path", Value: "/foo?bar=baz"},
+				{Name: "accept-encoding", Value: "gzip"},
+			}))
+		})
+
+		It("sends the buffered data before the PUSH_PROMISE", func() {
+			rw.Write([]byte("foobar"))
+			rw.enablePush(pusherFunc(func(reqStr io.Writer, headers []qpack.HeaderField) error {
+				decodeHeader(strBuf)
+				Expect(getData(strBuf)).To(Equal([]byte("foobar")))
+				return nil
+			}), "example.com")
+			Expect(rw.Push("/foo", nil)).To(Succeed())
+		})
+
+		It("pushes an absolute URL", func() {
+			Expect(rw.Push("https://quic.clemente.io/foo", &http.PushOptions{Method: "HEAD"})).To(Succeed())
+			Expect(pushedHeaders).To(ContainElement(qpack.HeaderField{Name: ":method", Value: "HEAD"}))
+			Expect(pushedHeaders).To(ContainElement(qpack.HeaderField{Name: ":authority", Value: "quic.clemente.io"}))
+			Expect(pushedHeaders).To(ContainElement(qpack.HeaderField{Name: ":path", Value: "/foo"}))
+		})
+
+		It("rejects invalid promised requests", func() {
+			Expect(rw.Push("/foo", &http.PushOptions{Method: "POST"})).To(MatchError(`method "POST" must be GET or HEAD`))
+			Expect(rw.Push("foo", nil)).To(MatchError(`target must be an absolute URL or an absolute path: "foo"`))
+			Expect(rw.Push("http://example.com/foo", nil)).To(MatchError(`cannot push URL with scheme "http" from request with scheme "https"`))
+			Expect(rw.Push("/foo", &http.PushOptions{
+				Header: http.Header{"Content-Length": []string{"42"}},
+			})).To(MatchError(`promised request headers cannot include "Content-Length"`))
+			Expect(pushedHeaders).To(BeNil())
+		})
+	})
 })
+
+type pusherFunc func(io.Writer, []qpack.HeaderField) error
+
+func (f pusherFunc) push(reqStr io.Writer, _ protocol.StreamID, headers []qpack.HeaderField) error {
+	return f(reqStr, headers)
+}
@@ -14,9 +14,14 @@ import (
 	"golang.org/x/net/http/httpguts"
 )
 
+// maxGoAwayRetries is the number of times a request is retried on a new connection,
+// when the server doesn't process it because it is shutting down the connection.
+const maxGoAwayRetries = 3
+
 type roundTripCloser interface {
 	http.RoundTripper
 	io.Closer
+	connect() error
 }
 
 // RoundTripper implements the http.RoundTripper interface
@@ -46,6 +51,22 @@ type RoundTripper struct {
 	// If Dial is nil, quic.DialAddr will be used.
 	Dial func(network, addr string, tlsCfg *tls.Config, cfg *quic.Config) (quic.Session, error)
 
+	// OnPush enables server push, if set.
+	// It is called for every response pushed by the server, with the promised request and the pushed response.
+	// The response body is closed when OnPush returns.
+	// At most 16 pushed responses can be outstanding at the same time.
+	OnPush func(*http.Request, *http.Response)
+
+	// MaxHeaderTableSize is the maximum size of the QPACK dynamic table, in bytes.
+	// The dynamic table is used to compress header fields, in both directions.
+	// If not set, it will default to 4096 bytes. If set to a negative value, the dynamic table is not used.
+	MaxHeaderTableSize int
+
+	// MaxResponseHeaderBytes specifies a limit on how many response bytes are
+	// allowed in the server's response header.
+	// Zero means to use a default limit.
+	MaxResponseHeaderBytes int64
+
 	clients map[string]roundTripCloser
 }
 
@@ -58,13 +79,24 @@ type RoundTripOpt struct {
 	OnlyCachedConn bool
 }
 
-var _ roundTripCloser = &RoundTripper{}
+var _ http.RoundTripper = &RoundTripper{}
+var _ io.Closer = &RoundTripper{}
 
 // ErrNoCachedConn is returned when RoundTripper.OnlyCachedConn is set
 var ErrNoCachedConn = errors.New("http3: no cached connection was available")
 
 // RoundTripOpt is like RoundTrip, but takes options.
 func (r *RoundTripper) RoundTripOpt(req *http.Request, opt RoundTripOpt) (*http.Response, error) {
+	rsp, err := r.roundTripOpt(req, opt)
+	if e, ok := err.(*requestNotSentError); ok {
+		return nil, e.err
+	}
+	return rsp, err
+}
+
+// roundTripOpt does a round trip.
+// If the request wasn't sent because of a problem with the QUIC connection, the error is a *requestNotSentError.
+func (r *RoundTripper) roundTripOpt(req *http.Request, opt RoundTripOpt) (*http.Response, error) {
 	if req.URL == nil {
 		closeRequestBody(req)
 		return nil, errors.New("http3: nil Request.URL")
@@ -100,11 +132,23 @@ func (r *RoundTripper) RoundTripOpt(req *http.Request, opt RoundTripOpt) (*http.
 	}
 
 	hostname := authorityAddr("https", hostnameFromRequest(req))
-	cl, err := r.getClient(hostname, opt.OnlyCachedConn)
-	if err != nil {
-		return nil, err
+	for i := 0; ; i++ {
+		cl, err := r.getClient(hostname, opt.OnlyCachedConn)
+		if err != nil {
+			return nil, err
+		}
+		rsp, err := cl.RoundTrip(req)
+		if err != errGoAway || i >= maxGoAwayRetries {
+			return rsp, err
+		}
+		// The server is shutting down this connection, and didn't process the request.
+		// Retry it on a new connection.
+		r.removeClient(hostname, cl)
+		req, err = rewindBody(req)
+		if err != nil {
+			return nil, err
+		}
 	}
-	return cl.RoundTrip(req)
 }
 
 // RoundTrip does a round trip.
@@ -112,7 +156,7 @@ func (r *RoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
 	return r.RoundTripOpt(req, RoundTripOpt{})
 }
 
-func (r *RoundTripper) getClient(hostname string, onlyCached bool) (http.RoundTripper, error) {
+func (r *RoundTripper) getClient(hostname string, onlyCached bool) (roundTripCloser, error) {
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
 
@@ -128,7 +172,12 @@ func (r *RoundTripper) getClient(hostname string, onlyCached bool) (http.RoundTr
 		client = newClient(
 			hostname,
 			r.TLSClientConfig,
-			&roundTripperOpts{DisableCompression: r.DisableCompression},
+			&roundTripperOpts{
+				DisableCompression: r.DisableCompression,
+				OnPush:             r.OnPush,
+				MaxHeaderTableSize: r.MaxHeaderTableSize,
+				MaxHeaderBytes:     r.MaxResponseHeaderBytes,
+			},
 			r.QuicConfig,
 			r.Dial,
 		)
@@ -137,6 +186,39 @@ func (r *RoundTripper) getClient(hostname string, onlyCached bool) (http.RoundTr
 	return client, nil
 }
 
+// dial establishes a QUIC connection to hostname, if there's none yet.
+// If the handshake fails, the client is removed, such that a later request can try again.
+func (r *RoundTripper) dial(hostname string) error {
+	cl, err := r.getClient(hostname, false)
+	if err != nil {
+		return err
+	}
+	if err := cl.connect(); err != nil {
+		r.removeClient(hostname, cl)
+		return err
+	}
+	return nil
+}
+
+// forgetClient removes the client for hostname, such that the next request establishes a new connection.
+// It is used when the QUIC connection of that client failed.
+func (r *RoundTripper) forgetClient(hostname string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	delete(r.clients, hostname)
+}
+
+// removeClient removes a client that received a GOAWAY frame, such that new requests use a new connection.
+// Its connection is not closed, since it might still be used by requests that are being processed.
+// The server will close it once these requests are completed.
+func (r *RoundTripper) removeClient(hostname string, cl http.RoundTripper) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.clients[hostname] == cl {
+		delete(r.clients, hostname)
+	}
+}
+
 // Close closes the QUIC connections that this RoundTripper has used
 func (r *RoundTripper) Close() error {
 	r.mutex.Lock()
@@ -150,6 +232,23 @@ func (r *RoundTripper) Close() error {
 	return nil
 }
 
+// rewindBody prepares a request for being sent again.
+func rewindBody(req *http.Request) (*http.Request, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return req, nil
+	}
+	if req.GetBody == nil {
+		return nil, errors.New("http3: server is going away, can't retry request with body (GetBody is nil)")
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, err
+	}
+	newReq := *req
+	newReq.Body = body
+	return &newReq, nil
+}
+
 func closeRequestBody(req *http.Request) {
 	if req.Body != nil {
 		req.Body.Close()
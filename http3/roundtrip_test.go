@@ -16,10 +16,17 @@ import (
 )
 
 type mockClient struct {
-	closed bool
+	closed       bool
+	connectErr   error
+	roundTripErr error
 }
 
+func (m *mockClient) connect() error { return m.connectErr }
+
 func (m *mockClient) RoundTrip(req *http.Request) (*http.Response, error) {
+	if m.roundTripErr != nil {
+		return nil, m.roundTripErr
+	}
 	return &http.Response{Request: req}, nil
 }
 func (m *mockClient) Close() error {
@@ -92,6 +99,7 @@ var _ = Describe("RoundTripper", func() {
 			req, err := http.NewRequest("GET", "https://quic.clemente.io/foobar.html", nil)
 			Expect(err).ToNot(HaveOccurred())
 			session.EXPECT().OpenUniStreamSync().AnyTimes().Return(nil, testErr)
+			session.EXPECT().AcceptUniStream().AnyTimes().Return(nil, testErr)
 			session.EXPECT().OpenStreamSync().Return(nil, testErr)
 			session.EXPECT().CloseWithError(gomock.Any(), gomock.Any()).Do(func(quic.ErrorCode, error) { close(closed) })
 			_, err = rt.RoundTrip(req)
@@ -125,10 +133,20 @@ var _ = Describe("RoundTripper", func() {
 			Expect(dialed).To(BeTrue())
 		})
 
+		It("returns the error that prevented the request from being sent", func() {
+			testErr := errors.New("handshake error")
+			rt.Dial = func(_, _ string, _ *tls.Config, _ *quic.Config) (quic.Session, error) {
+				return nil, testErr
+			}
+			_, err := rt.RoundTrip(req1)
+			Expect(err).To(MatchError(testErr))
+		})
+
 		It("reuses existing clients", func() {
 			closed := make(chan struct{})
 			testErr := errors.New("test err")
 			session.EXPECT().OpenUniStreamSync().AnyTimes().Return(nil, testErr)
+			session.EXPECT().AcceptUniStream().AnyTimes().Return(nil, testErr)
 			session.EXPECT().OpenStreamSync().Return(nil, testErr).Times(2)
 			session.EXPECT().CloseWithError(gomock.Any(), gomock.Any()).Do(func(quic.ErrorCode, error) { close(closed) })
 			req, err := http.NewRequest("GET", "https://quic.clemente.io/file1.html", nil)
@@ -144,6 +162,14 @@ var _ = Describe("RoundTripper", func() {
 			Eventually(closed).Should(BeClosed())
 		})
 
+		It("removes the client if establishing the connection fails", func() {
+			dialAddr = func(addr string, tlsConf *tls.Config, config *quic.Config) (quic.Session, error) {
+				return nil, errors.New("handshake error")
+			}
+			Expect(rt.dial("quic.clemente.io:443")).To(MatchError("handshake error"))
+			Expect(rt.clients).To(BeEmpty())
+		})
+
 		It("doesn't create new clients if RoundTripOpt.OnlyCachedConn is set", func() {
 			req, err := http.NewRequest("GET", "https://quic.clemente.io/foobar.html", nil)
 			Expect(err).ToNot(HaveOccurred())
@@ -152,6 +178,49 @@ var _ = Describe("RoundTripper", func() {
 		})
 	})
 
+	Context("handling GOAWAY", func() {
+		var (
+			cl     *mockClient
+			dialed chan struct{}
+		)
+
+		BeforeEach(func() {
+			cl = &mockClient{roundTripErr: errGoAway}
+			rt.clients = map[string]roundTripCloser{"www.example.org:443": cl}
+			dialed = make(chan struct{}, 10)
+			rt.Dial = func(_, _ string, _ *tls.Config, _ *quic.Config) (quic.Session, error) {
+				dialed <- struct{}{}
+				return nil, errors.New("handshake error")
+			}
+		})
+
+		It("retries requests on a new connection", func() {
+			_, err := rt.RoundTrip(req1)
+			Expect(err).To(MatchError("handshake error"))
+			Expect(dialed).To(HaveLen(1))
+			Expect(rt.clients).To(HaveKey("www.example.org:443"))
+			Expect(rt.clients["www.example.org:443"]).ToNot(Equal(cl))
+			// the old connection might still be used by other requests
+			Expect(cl.closed).To(BeFalse())
+		})
+
+		It("rewinds the request body", func() {
+			req, err := http.NewRequest("POST", "https://www.example.org/upload", bytes.NewReader([]byte("foobar")))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(req.GetBody).ToNot(BeNil())
+			_, err = rt.RoundTrip(req)
+			Expect(err).To(MatchError("handshake error"))
+			Expect(dialed).To(HaveLen(1))
+		})
+
+		It("doesn't retry requests with a body that can't be rewound", func() {
+			req1.Body = &mockBody{}
+			_, err := rt.RoundTrip(req1)
+			Expect(err).To(MatchError("http3: server is going away, can't retry request with body (GetBody is nil)"))
+			Expect(dialed).To(BeEmpty())
+		})
+	})
+
 	Context("validating request", func() {
 		It("rejects plain HTTP requests", func() {
 			req, err := http.NewRequest("GET", "http://www.example.org/", nil)
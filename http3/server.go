@@ -1,6 +1,8 @@
 package http3
 
 import (
+	"bytes"
+	"context"
 	"crypto/tls"
 	"errors"
 	"fmt"
@@ -16,9 +18,25 @@ import (
 	"github.com/lucas-clemente/quic-go"
 	"github.com/lucas-clemente/quic-go/internal/protocol"
 	"github.com/lucas-clemente/quic-go/internal/utils"
-	"github.com/marten-seemann/qpack"
 )
 
+// The stream types of unidirectional streams, as defined in draft-ietf-quic-http-19, section 3.2.
+const (
+	streamTypeControlStream      = 0x0
+	streamTypePushStream         = 0x1
+	streamTypeQPACKEncoderStream = 0x2
+	streamTypeQPACKDecoderStream = 0x3
+)
+
+// nextProtoH3 is the ALPN token of the HTTP/3 draft version implemented by this package.
+const nextProtoH3 = "h3-19"
+
+// supportedNextProtos are the ALPN tokens announced in the Alt-Svc header.
+var supportedNextProtos = []string{nextProtoH3}
+
+// defaultAltSvcMaxAge is the max-age used in the Alt-Svc header, if Server.AltSvcMaxAge is not set.
+const defaultAltSvcMaxAge = 30 * 24 * time.Hour
+
 // allows mocking of quic.Listen and quic.ListenAddr
 var (
 	quicListen     = quic.Listen
@@ -33,13 +51,28 @@ type Server struct {
 	// If nil, it uses reasonable default values.
 	QuicConfig *quic.Config
 
+	// MaxHeaderTableSize is the maximum size of the QPACK dynamic table, in bytes.
+	// The dynamic table is used to compress header fields, in both directions.
+	// If not set, it will default to 4096 bytes. If set to a negative value, the dynamic table is not used.
+	MaxHeaderTableSize int
+
+	// AltSvcMaxAge is the time that the Alt-Svc header set by SetQuicHeaders
+	// allows clients to remember that HTTP/3 is available.
+	// If zero, it defaults to 30 days.
+	AltSvcMaxAge time.Duration
+
 	port uint32 // used atomically
 
-	listenerMutex sync.Mutex
+	listenerMutex sync.Mutex // protects the listener, the sessions map, closed and closing, as well as the active requests
 	listener      quic.Listener
+	sessions      map[*serverSession]struct{}
 	closed        bool
+	closing       bool
 
-	supportedVersionsAsString string
+	// the number of requests (and pushes) that are currently being handled
+	activeRequests int
+	// closed when the server is shutting down and all active requests have completed
+	requestsDone chan struct{}
 
 	logger utils.Logger
 }
@@ -111,9 +144,84 @@ func (s *Server) serveImpl(tlsConfig *tls.Config, conn net.PacketConn) error {
 	}
 }
 
+// A serverSession is a HTTP/3 connection accepted by the Server.
+type serverSession struct {
+	quic.Session
+
+	server     *Server
+	controlStr quic.SendStream
+	encoder    *qpackEncoder
+	decoder    *qpackDecoder
+
+	mutex sync.Mutex
+	// the ID of the next request stream that will be accepted
+	nextStreamID protocol.StreamID
+	goingAway    bool
+
+	// Server push is only possible after the client sent a MAX_PUSH_ID frame.
+	maxPushIDReceived bool
+	maxPushID         uint64
+	nextPushID        uint64
+	// push streams that are still being served, or that the client canceled before they were opened
+	pushStreams map[uint64]quic.SendStream
+}
+
+// acceptRequestStream decides if a request stream is processed.
+// After sending a GOAWAY frame, only streams with IDs lower than the one sent in that frame are processed.
+func (s *serverSession) acceptRequestStream(id protocol.StreamID) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.goingAway && id >= s.nextStreamID {
+		return false
+	}
+	if id >= s.nextStreamID {
+		s.nextStreamID = id + 4
+	}
+	return true
+}
+
+// goAway sends a GOAWAY frame on the control stream.
+// Requests on streams that have already been accepted are still processed.
+func (s *serverSession) goAway() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.goingAway {
+		return nil
+	}
+	s.goingAway = true
+	buf := &bytes.Buffer{}
+	(&goAwayFrame{StreamID: s.nextStreamID}).Write(buf)
+	_, err := s.controlStr.Write(buf.Bytes())
+	return err
+}
+
 func (s *Server) handleConn(sess quic.Session) {
-	// TODO: accept control streams
-	decoder := qpack.NewDecoder(nil)
+	tableCapacity := qpackTableCapacity(s.MaxHeaderTableSize)
+	settings := map[uint64]uint64{settingMaxHeaderListSize: s.maxHeaderBytes()}
+	for id, val := range qpackSettings(tableCapacity) {
+		settings[id] = val
+	}
+	// send a SETTINGS frame
+	controlStr, err := s.openControlStream(sess, settings)
+	if err != nil {
+		s.logger.Debugf("Opening the control stream failed: %s", err)
+		sess.CloseWithError(quic.ErrorCode(errorInternalError), err)
+		return
+	}
+	serverSess := &serverSession{
+		Session:     sess,
+		server:      s,
+		controlStr:  controlStr,
+		encoder:     newQPACKEncoder(tableCapacity, newQPACKStream(streamTypeQPACKEncoderStream, sess.OpenUniStreamSync)),
+		decoder:     newQPACKDecoder(tableCapacity, newQPACKStream(streamTypeQPACKDecoderStream, sess.OpenUniStreamSync)),
+		pushStreams: make(map[uint64]quic.SendStream),
+	}
+	s.addSession(serverSess)
+	defer s.removeSession(serverSess)
+
+	go s.handleUnidirectionalStreams(serverSess)
 
 	for {
 		str, err := sess.AcceptStream()
@@ -121,11 +229,16 @@ func (s *Server) handleConn(sess quic.Session) {
 			s.logger.Debugf("Accepting stream failed: %s", err)
 			return
 		}
-		// TODO: handle error
+		if !serverSess.acceptRequestStream(str.StreamID()) || !s.startRequest() {
+			s.logger.Debugf("Rejecting request on stream %d, since the server is going away", str.StreamID())
+			str.CancelRead(quic.ErrorCode(errorRequestRejected))
+			str.CancelWrite(quic.ErrorCode(errorRequestRejected))
+			continue
+		}
 		go func() {
-			if err := s.handleRequest(str, decoder); err != nil {
+			defer s.finishRequest()
+			if err := s.handleRequest(str, serverSess); err != nil {
 				s.logger.Debugf("Handling request failed: %s", err)
-				str.CancelWrite(quic.ErrorCode(errorGeneralProtocolError))
 				return
 			}
 			str.Close()
@@ -133,12 +246,127 @@ func (s *Server) handleConn(sess quic.Session) {
 	}
 }
 
-// TODO: improve error handling.
-// Most (but not all) of the errors occurring here are connection-level erros.
-func (s *Server) handleRequest(str quic.Stream, decoder *qpack.Decoder) error {
+func (s *Server) addSession(sess *serverSession) {
+	s.listenerMutex.Lock()
+	defer s.listenerMutex.Unlock()
+
+	if s.sessions == nil {
+		s.sessions = make(map[*serverSession]struct{})
+	}
+	s.sessions[sess] = struct{}{}
+	// If the server is already shutting down, tell the client right away that no requests will be processed.
+	if s.closing {
+		if err := sess.goAway(); err != nil {
+			s.logger.Debugf("Sending GOAWAY failed: %s", err)
+		}
+	}
+}
+
+func (s *Server) removeSession(sess *serverSession) {
+	s.listenerMutex.Lock()
+	delete(s.sessions, sess)
+	s.listenerMutex.Unlock()
+}
+
+// startRequest registers a request (or a push) that is about to be handled.
+// Once the server is shutting down and all active requests have completed, no new requests are started.
+func (s *Server) startRequest() bool {
+	s.listenerMutex.Lock()
+	defer s.listenerMutex.Unlock()
+
+	if s.closed || (s.closing && s.activeRequests == 0) {
+		return false
+	}
+	s.activeRequests++
+	return true
+}
+
+func (s *Server) finishRequest() {
+	s.listenerMutex.Lock()
+	defer s.listenerMutex.Unlock()
+
+	s.activeRequests--
+	s.maybeSignalRequestsDone()
+}
+
+// getRequestsDone returns the channel that is closed when the server is shutting down and all active requests have completed.
+// It must be called with the listenerMutex held.
+func (s *Server) getRequestsDone() chan struct{} {
+	if s.requestsDone == nil {
+		s.requestsDone = make(chan struct{})
+	}
+	return s.requestsDone
+}
+
+// maybeSignalRequestsDone must be called with the listenerMutex held.
+func (s *Server) maybeSignalRequestsDone() {
+	if !s.closing || s.activeRequests > 0 {
+		return
+	}
+	done := s.getRequestsDone()
+	select {
+	case <-done:
+	default:
+		close(done)
+	}
+}
+
+func (s *Server) openControlStream(sess quic.Session, settings map[uint64]uint64) (quic.SendStream, error) {
+	str, err := sess.OpenUniStream()
+	if err != nil {
+		return nil, err
+	}
+	buf := &bytes.Buffer{}
+	utils.WriteVarInt(buf, streamTypeControlStream)
+	(&settingsFrame{settings: settings}).Write(buf)
+	if _, err := str.Write(buf.Bytes()); err != nil {
+		return nil, err
+	}
+	return str, nil
+}
+
+func (s *Server) handleUnidirectionalStreams(sess *serverSession) {
+	h := &uniStreamHandler{
+		sess:               sess,
+		perspective:        protocol.PerspectiveServer,
+		encoder:            sess.encoder,
+		decoder:            sess.decoder,
+		logger:             s.logger,
+		handleControlFrame: sess.handleControlFrame,
+	}
+	h.run()
+}
+
+// handleControlFrame handles the MAX_PUSH_ID and CANCEL_PUSH frames sent on the client's control stream.
+func (s *serverSession) handleControlFrame(f frame) (errorCode, error) {
+	switch f := f.(type) {
+	case *maxPushIDFrame:
+		if err := s.setMaxPushID(f.PushID); err != nil {
+			return errorMalformedFrameType(0xd), err // MAX_PUSH_ID
+		}
+	case *cancelPushFrame:
+		s.cancelPush(f.PushID)
+	}
+	return 0, nil
+}
+
+func (s *Server) maxHeaderBytes() uint64 {
+	if s.Server == nil || s.Server.MaxHeaderBytes <= 0 {
+		return http.DefaultMaxHeaderBytes
+	}
+	return uint64(s.Server.MaxHeaderBytes)
+}
+
+// handleRequest reads a request from a request stream, and calls the handler.
+// If an error is returned, the stream (or the connection) has already been closed with the appropriate error code.
+func (s *Server) handleRequest(str quic.Stream, sess *serverSession) error {
 	frame, err := parseNextFrame(str)
 	if err != nil {
-		str.CancelWrite(quic.ErrorCode(errorRequestCanceled))
+		errCode := errorRequestCanceled
+		if mErr, ok := err.(*malformedFrameError); ok {
+			errCode = mErr.ErrorCode()
+		}
+		str.CancelWrite(quic.ErrorCode(errCode))
 		return err
 	}
 	hf, ok := frame.(*headersFrame)
@@ -146,23 +374,58 @@ func (s *Server) handleRequest(str quic.Stream, decoder *qpack.Decoder) error {
 		str.CancelWrite(quic.ErrorCode(errorUnexpectedFrame))
 		return errors.New("expected first frame to be a headers frame")
 	}
-	// TODO: check length
+	if hf.Length > s.maxHeaderBytes() {
+		str.CancelRead(quic.ErrorCode(errorExcessiveLoad))
+		str.CancelWrite(quic.ErrorCode(errorExcessiveLoad))
+		return fmt.Errorf("HEADERS frame too large: %d bytes (max: %d)", hf.Length, s.maxHeaderBytes())
+	}
 	headerBlock := make([]byte, hf.Length)
 	if _, err := io.ReadFull(str, headerBlock); err != nil {
+		// The header block might have referenced the dynamic table.
+		if err := sess.decoder.cancelStream(str.StreamID()); err != nil {
+			s.logger.Debugf("Sending Stream Cancellation failed: %s", err)
+		}
 		str.CancelWrite(quic.ErrorCode(errorIncompleteRequest))
 		return err
 	}
-	hfs, err := decoder.DecodeFull(headerBlock)
+	hfs, err := sess.decoder.decode(str.StreamID(), headerBlock)
 	if err != nil {
-		// TODO: use the right error code
-		str.CancelWrite(quic.ErrorCode(errorGeneralProtocolError))
+		sess.CloseWithError(quic.ErrorCode(errorQPACKDecompressionFailed), err)
 		return err
 	}
+	// The decompressed header list can be a lot larger than the header block.
+	if size := headerListSize(hfs); size > s.maxHeaderBytes() {
+		str.CancelRead(quic.ErrorCode(errorExcessiveLoad))
+		str.CancelWrite(quic.ErrorCode(errorExcessiveLoad))
+		return fmt.Errorf("header list too large: %d bytes (max: %d)", size, s.maxHeaderBytes())
+	}
 	req, err := requestFromHeaders(hfs)
 	if err != nil {
+		// malformed request
+		str.CancelRead(quic.ErrorCode(errorGeneralProtocolError))
+		str.CancelWrite(quic.ErrorCode(errorGeneralProtocolError))
 		return err
 	}
-	req.Body = newRequestBody(str)
+	if prio, ok := priorityFromHeader(req.Header); ok {
+		str.SetPriority(prio)
+	}
+	body := newRequestBody(str)
+	body.maxTrailerBytes = s.maxHeaderBytes()
+	body.onTrailers = func(headerBlock []byte) error {
+		hfs, err := sess.decoder.decode(str.StreamID(), headerBlock)
+		if err != nil {
+			sess.CloseWithError(quic.ErrorCode(errorQPACKDecompressionFailed), err)
+			return err
+		}
+		if size := headerListSize(hfs); size > s.maxHeaderBytes() {
+			return fmt.Errorf("trailers too large: %d bytes (max: %d)", size, s.maxHeaderBytes())
+		}
+		if req.Trailer == nil {
+			req.Trailer = http.Header{}
+		}
+		return addTrailers(req.Trailer, hfs)
+	}
+	req.Body = body
 
 	if s.logger.Debug() {
 		s.logger.Infof("%s %s%s, on stream %d", req.Method, req.Host, req.RequestURI, str.StreamID())
@@ -171,35 +434,24 @@ func (s *Server) handleRequest(str quic.Stream, decoder *qpack.Decoder) error {
 	}
 
 	req = req.WithContext(str.Context())
-	responseWriter := newResponseWriter(str, s.logger)
-	handler := s.Handler
-	if handler == nil {
-		handler = http.DefaultServeMux
-	}
+	responseWriter := newResponseWriter(str, sess.encoder, str.StreamID(), s.logger)
+	responseWriter.enablePush(sess, req.Host)
 
-	var panicked, readEOF bool
-	func() {
-		defer func() {
-			if p := recover(); p != nil {
-				// Copied from net/http/server.go
-				const size = 64 << 10
-				buf := make([]byte, size)
-				buf = buf[:runtime.Stack(buf, false)]
-				s.logger.Errorf("http: panic serving: %v\n%s", p, buf)
-				panicked = true
-			}
-		}()
-		handler.ServeHTTP(responseWriter, req)
+	panicked := s.serveHTTP(responseWriter, req)
+	var readEOF bool
+	if !panicked {
 		// read the eof
 		if _, err = str.Read([]byte{}); err == io.EOF {
 			readEOF = true
 		}
-	}()
+	}
 
 	if panicked {
 		responseWriter.WriteHeader(500)
+		responseWriter.Flush()
 	} else {
-		responseWriter.WriteHeader(200)
+		responseWriter.Flush()
+		responseWriter.writeTrailers()
 	}
 
 	if !readEOF {
@@ -208,6 +460,27 @@ func (s *Server) handleRequest(str quic.Stream, decoder *qpack.Decoder) error {
 	return nil
 }
 
+// serveHTTP calls the handler, and reports if it panicked.
+func (s *Server) serveHTTP(w http.ResponseWriter, req *http.Request) (panicked bool) {
+	handler := s.Handler
+	if handler == nil {
+		handler = http.DefaultServeMux
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			// Copied from net/http/server.go
+			const size = 64 << 10
+			buf := make([]byte, size)
+			buf = buf[:runtime.Stack(buf, false)]
+			s.logger.Errorf("http: panic serving: %v\n%s", p, buf)
+			panicked = true
+		}
+	}()
+	handler.ServeHTTP(w, req)
+	return false
+}
+
 // Close the server immediately, aborting requests and sending CONNECTION_CLOSE frames to connected clients.
 // Close in combination with ListenAndServe() (instead of Serve()) may race if it is called before a UDP socket is established.
 func (s *Server) Close() error {
@@ -225,13 +498,46 @@ func (s *Server) Close() error {
 // CloseGracefully shuts down the server gracefully. The server sends a GOAWAY frame first, then waits for either timeout to trigger, or for all running requests to complete.
 // CloseGracefully in combination with ListenAndServe() (instead of Serve()) may race if it is called before a UDP socket is established.
 func (s *Server) CloseGracefully(timeout time.Duration) error {
-	// TODO: implement
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := s.Shutdown(ctx); err != nil && err != context.DeadlineExceeded {
+		return err
+	}
 	return nil
 }
 
-// SetQuicHeaders can be used to set the proper headers that announce that this server supports QUIC.
+// Shutdown shuts down the server gracefully. The server sends a GOAWAY frame first, then waits for all running requests to complete.
+// If ctx expires before that, the server is closed immediately, and the context's error is returned.
+// Shutdown in combination with ListenAndServe() (instead of Serve()) may race if it is called before a UDP socket is established.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.listenerMutex.Lock()
+	s.closing = true
+	for sess := range s.sessions {
+		if err := sess.goAway(); err != nil {
+			s.logger.Debugf("Sending GOAWAY failed: %s", err)
+		}
+	}
+	// After sending the GOAWAY frames, no new requests are accepted.
+	// Wait for the ones that are currently being processed.
+	done := s.getRequestsDone()
+	s.maybeSignalRequestsDone()
+	s.listenerMutex.Unlock()
+
+	select {
+	case <-done:
+		return s.Close()
+	case <-ctx.Done():
+		s.Close()
+		return ctx.Err()
+	}
+}
+
+// SetQuicHeaders can be used to set the proper headers that announce that this server supports HTTP/3.
 // The values that are set depend on the port information from s.Server.Addr, and currently look like this (if Addr has port 443):
-//  Alt-Svc: quic=":443"; ma=2592000; v="33,32,31,30"
+//
+//	Alt-Svc: h3-19=":443"; ma=2592000
+//
+// The max-age can be configured using s.AltSvcMaxAge.
 func (s *Server) SetQuicHeaders(hdr http.Header) error {
 	port := atomic.LoadUint32(&s.port)
 
@@ -249,19 +555,23 @@ func (s *Server) SetQuicHeaders(hdr http.Header) error {
 		atomic.StoreUint32(&s.port, port)
 	}
 
-	if s.supportedVersionsAsString == "" {
-		var versions []string
-		for _, v := range protocol.SupportedVersions {
-			versions = append(versions, v.ToAltSvc())
-		}
-		s.supportedVersionsAsString = strings.Join(versions, ",")
+	maxAge := s.AltSvcMaxAge
+	if maxAge == 0 {
+		maxAge = defaultAltSvcMaxAge
 	}
-
-	hdr.Add("Alt-Svc", fmt.Sprintf(`quic=":%d"; ma=2592000; v="%s"`, port, s.supportedVersionsAsString))
-
+	hdr.Add("Alt-Svc", altSvcHeader(port, maxAge))
 	return nil
 }
 
+// altSvcHeader generates the value of the Alt-Svc header (see RFC 7838) for a server listening on port.
+func altSvcHeader(port uint32, maxAge time.Duration) string {
+	values := make([]string, len(supportedNextProtos))
+	for i, proto := range supportedNextProtos {
+		values[i] = fmt.Sprintf(`%s=":%d"; ma=%d`, proto, port, int64(maxAge/time.Second))
+	}
+	return strings.Join(values, ", ")
+}
+
 // ListenAndServeQUIC listens on the UDP network address addr and calls the
 // handler for HTTP/3 requests on incoming connections. http.DefaultServeMux is
 // used when handler is nil.
@@ -280,76 +590,5 @@ func ListenAndServeQUIC(addr, certFile, keyFile string, handler http.Handler) er
 // http.DefaultServeMux is used when handler is nil.
 // The correct Alt-Svc headers for QUIC are set.
 func ListenAndServe(addr, certFile, keyFile string, handler http.Handler) error {
-	// Load certs
-	var err error
-	certs := make([]tls.Certificate, 1)
-	certs[0], err = tls.LoadX509KeyPair(certFile, keyFile)
-	if err != nil {
-		return err
-	}
-	// We currently only use the cert-related stuff from tls.Config,
-	// so we don't need to make a full copy.
-	config := &tls.Config{
-		Certificates: certs,
-	}
-
-	// Open the listeners
-	udpAddr, err := net.ResolveUDPAddr("udp", addr)
-	if err != nil {
-		return err
-	}
-	udpConn, err := net.ListenUDP("udp", udpAddr)
-	if err != nil {
-		return err
-	}
-	defer udpConn.Close()
-
-	tcpAddr, err := net.ResolveTCPAddr("tcp", addr)
-	if err != nil {
-		return err
-	}
-	tcpConn, err := net.ListenTCP("tcp", tcpAddr)
-	if err != nil {
-		return err
-	}
-	defer tcpConn.Close()
-
-	tlsConn := tls.NewListener(tcpConn, config)
-	defer tlsConn.Close()
-
-	// Start the servers
-	httpServer := &http.Server{
-		Addr:      addr,
-		TLSConfig: config,
-	}
-
-	quicServer := &Server{
-		Server: httpServer,
-	}
-
-	if handler == nil {
-		handler = http.DefaultServeMux
-	}
-	httpServer.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		quicServer.SetQuicHeaders(w.Header())
-		handler.ServeHTTP(w, r)
-	})
-
-	hErr := make(chan error)
-	qErr := make(chan error)
-	go func() {
-		hErr <- httpServer.Serve(tlsConn)
-	}()
-	go func() {
-		qErr <- quicServer.Serve(udpConn)
-	}()
-
-	select {
-	case err := <-hErr:
-		quicServer.Close()
-		return err
-	case err := <-qErr:
-		// Cannot close the HTTP server or wait for requests to complete properly :/
-		return err
-	}
+	return NewCombinedServer(addr, nil, handler).ListenAndServeTLS(certFile, keyFile)
 }
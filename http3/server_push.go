@@ -0,0 +1,147 @@
+package http3
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/lucas-clemente/quic-go"
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+	"github.com/lucas-clemente/quic-go/internal/utils"
+	"github.com/marten-seemann/qpack"
+)
+
+var errPushLimitReached = errors.New("http3: push would exceed the client's MAX_PUSH_ID")
+
+// A pusher pushes responses to the client.
+type pusher interface {
+	// push sends a PUSH_PROMISE frame for the promised request on the request stream,
+	// and serves the promised request on a new push stream.
+	push(reqStr io.Writer, reqStreamID protocol.StreamID, headers []qpack.HeaderField) error
+}
+
+var _ pusher = &serverSession{}
+
+func (s *serverSession) push(reqStr io.Writer, reqStreamID protocol.StreamID, headers []qpack.HeaderField) error {
+	req, err := requestFromHeaders(headers)
+	if err != nil {
+		return err
+	}
+
+	s.mutex.Lock()
+	if !s.maxPushIDReceived {
+		s.mutex.Unlock()
+		return http.ErrNotSupported
+	}
+	if s.nextPushID > s.maxPushID {
+		s.mutex.Unlock()
+		return errPushLimitReached
+	}
+	pushID := s.nextPushID
+	s.nextPushID++
+	s.mutex.Unlock()
+
+	headerBlock, err := s.encoder.encode(reqStreamID, headers)
+	if err != nil {
+		return err
+	}
+	buf := &bytes.Buffer{}
+	(&pushPromiseFrame{PushID: pushID, Length: uint64(len(headerBlock))}).Write(buf)
+	buf.Write(headerBlock)
+	if _, err := reqStr.Write(buf.Bytes()); err != nil {
+		return err
+	}
+
+	str, err := s.OpenUniStream()
+	if err != nil {
+		return err
+	}
+	buf.Reset()
+	utils.WriteVarInt(buf, streamTypePushStream)
+	utils.WriteVarInt(buf, pushID)
+	if _, err := str.Write(buf.Bytes()); err != nil {
+		return err
+	}
+
+	s.mutex.Lock()
+	if _, canceled := s.pushStreams[pushID]; canceled {
+		delete(s.pushStreams, pushID)
+		s.mutex.Unlock()
+		str.CancelWrite(quic.ErrorCode(errorRequestCanceled))
+		return nil
+	}
+	s.pushStreams[pushID] = str
+	s.mutex.Unlock()
+
+	// Pushes are initiated by handlers, so there's at least one active request at this point,
+	// and the server won't stop waiting for requests before the push was registered.
+	if !s.server.startRequest() {
+		s.mutex.Lock()
+		delete(s.pushStreams, pushID)
+		s.mutex.Unlock()
+		str.CancelWrite(quic.ErrorCode(errorRequestRejected))
+		return nil
+	}
+	go func() {
+		defer s.server.finishRequest()
+		s.server.servePush(str, s.encoder, req.WithContext(str.Context()))
+		s.mutex.Lock()
+		delete(s.pushStreams, pushID)
+		s.mutex.Unlock()
+	}()
+	return nil
+}
+
+// setMaxPushID processes a MAX_PUSH_ID frame received from the client.
+func (s *serverSession) setMaxPushID(id uint64) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.maxPushIDReceived && id < s.maxPushID {
+		return fmt.Errorf("MAX_PUSH_ID reduced the maximum push ID (%d to %d)", s.maxPushID, id)
+	}
+	s.maxPushIDReceived = true
+	s.maxPushID = id
+	return nil
+}
+
+// cancelPush processes a CANCEL_PUSH frame received from the client.
+func (s *serverSession) cancelPush(id uint64) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if id >= s.nextPushID {
+		return
+	}
+	str, ok := s.pushStreams[id]
+	if !ok {
+		// The push stream hasn't been opened yet.
+		s.pushStreams[id] = nil
+		return
+	}
+	if str != nil {
+		str.CancelWrite(quic.ErrorCode(errorRequestCanceled))
+	}
+}
+
+// servePush serves a promised request on a push stream.
+func (s *Server) servePush(str quic.SendStream, encoder *qpackEncoder, req *http.Request) {
+	if s.logger.Debug() {
+		s.logger.Infof("Pushing %s %s%s, on stream %d", req.Method, req.Host, req.RequestURI, str.StreamID())
+	} else {
+		s.logger.Infof("Pushing %s %s%s", req.Method, req.Host, req.RequestURI)
+	}
+
+	req.Body = http.NoBody
+	responseWriter := newResponseWriter(str, encoder, str.StreamID(), s.logger)
+	if s.serveHTTP(responseWriter, req) {
+		responseWriter.WriteHeader(500)
+		responseWriter.Flush()
+	} else {
+		responseWriter.Flush()
+		responseWriter.writeTrailers()
+	}
+	str.Close()
+}
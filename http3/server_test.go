@@ -5,8 +5,8 @@ import (
 	"context"
 	"crypto/tls"
 	"errors"
-	"fmt"
 	"io"
+	"io/ioutil"
 	"net/http"
 	"strings"
 	"time"
@@ -46,7 +46,7 @@ var _ = Describe("Server", func() {
 
 	Context("handling requests", func() {
 		var (
-			qpackDecoder       *qpack.Decoder
+			serverSess         *serverSession
 			str                *mockquic.MockStream
 			exampleGetRequest  *http.Request
 			examplePostRequest *http.Request
@@ -75,12 +75,13 @@ var _ = Describe("Server", func() {
 		encodeRequest := func(req *http.Request) []byte {
 			buf := &bytes.Buffer{}
 			str := mockquic.NewMockStream(mockCtrl)
+			str.EXPECT().StreamID().AnyTimes()
 			str.EXPECT().Write(gomock.Any()).DoAndReturn(func(p []byte) (int, error) {
 				return buf.Write(p)
 			}).AnyTimes()
 			closed := make(chan struct{})
 			str.EXPECT().Close().Do(func() { close(closed) })
-			rw := newRequestWriter(utils.DefaultLogger)
+			rw := newRequestWriter(newQPACKEncoder(0, nil), utils.DefaultLogger)
 			Expect(rw.WriteRequest(str, req, false)).To(Succeed())
 			Eventually(closed).Should(BeClosed())
 			return buf.Bytes()
@@ -103,8 +104,15 @@ var _ = Describe("Server", func() {
 			examplePostRequest, err = http.NewRequest("POST", "https://www.example.com", bytes.NewReader([]byte("foobar")))
 			Expect(err).ToNot(HaveOccurred())
 
-			qpackDecoder = qpack.NewDecoder(nil)
+			serverSess = &serverSession{
+				Session:     mockquic.NewMockSession(mockCtrl),
+				server:      s,
+				encoder:     newQPACKEncoder(0, nil),
+				decoder:     newQPACKDecoder(0, nil),
+				pushStreams: make(map[uint64]quic.SendStream),
+			}
 			str = mockquic.NewMockStream(mockCtrl)
+			str.EXPECT().StreamID().AnyTimes()
 		})
 
 		It("calls the HTTP handler function", func() {
@@ -119,12 +127,26 @@ var _ = Describe("Server", func() {
 				return len(p), nil
 			}).AnyTimes()
 
-			Expect(s.handleRequest(str, qpackDecoder)).To(Succeed())
+			Expect(s.handleRequest(str, serverSess)).To(Succeed())
 			var req *http.Request
 			Eventually(requestChan).Should(Receive(&req))
 			Expect(req.Host).To(Equal("www.example.com"))
 		})
 
+		It("sets the priority of the stream", func() {
+			s.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+			exampleGetRequest.Header.Set("Priority", "u=0")
+			setRequest(encodeRequest(exampleGetRequest))
+			str.EXPECT().SetPriority(quic.StreamPriority{Urgency: 0})
+			str.EXPECT().Context().Return(reqContext)
+			str.EXPECT().Write(gomock.Any()).DoAndReturn(func(p []byte) (int, error) {
+				return len(p), nil
+			}).AnyTimes()
+
+			Expect(s.handleRequest(str, serverSess)).To(Succeed())
+		})
+
 		It("returns 200 with an empty handler", func() {
 			s.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
 
@@ -135,11 +157,46 @@ var _ = Describe("Server", func() {
 				return responseBuf.Write(p)
 			}).AnyTimes()
 
-			Expect(s.handleRequest(str, qpackDecoder)).To(Succeed())
+			Expect(s.handleRequest(str, serverSess)).To(Succeed())
 			hfs := decodeHeader(responseBuf)
 			Expect(hfs).To(HaveKeyWithValue(":status", []string{"200"}))
 		})
 
+		It("handles trailers", func() {
+			handlerCalled := make(chan struct{})
+			s.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				defer GinkgoRecover()
+				Expect(r.Trailer).To(Equal(http.Header{"Foo": nil}))
+				body, err := ioutil.ReadAll(r.Body)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(body).To(Equal([]byte("foobar")))
+				Expect(r.Trailer).To(Equal(http.Header{"Foo": {"bar"}}))
+				w.Header().Set("Trailer", "Declared")
+				w.Header().Set("Declared", "value")
+				w.Header().Set(http.TrailerPrefix+"Undeclared", "value")
+				close(handlerCalled)
+			})
+
+			examplePostRequest.Trailer = http.Header{"Foo": {"bar"}}
+			setRequest(encodeRequest(examplePostRequest))
+			responseBuf := &bytes.Buffer{}
+			str.EXPECT().Context().Return(reqContext)
+			str.EXPECT().Write(gomock.Any()).DoAndReturn(func(p []byte) (int, error) {
+				return responseBuf.Write(p)
+			}).AnyTimes()
+
+			Expect(s.handleRequest(str, serverSess)).To(Succeed())
+			Eventually(handlerCalled).Should(BeClosed())
+			hfs := decodeHeader(responseBuf)
+			Expect(hfs).To(HaveKeyWithValue(":status", []string{"200"}))
+			Expect(hfs).To(HaveKeyWithValue("trailer", []string{"Declared"}))
+			Expect(hfs).ToNot(HaveKey("trailer:undeclared"))
+			trailers := decodeHeader(responseBuf)
+			Expect(trailers).To(HaveLen(2))
+			Expect(trailers).To(HaveKeyWithValue("declared", []string{"value"}))
+			Expect(trailers).To(HaveKeyWithValue("undeclared", []string{"value"}))
+		})
+
 		It("handles a panicking handler", func() {
 			s.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 				panic("foobar")
@@ -153,7 +210,7 @@ var _ = Describe("Server", func() {
 			}).AnyTimes()
 			str.EXPECT().CancelRead(gomock.Any())
 
-			Expect(s.handleRequest(str, qpackDecoder)).To(Succeed())
+			Expect(s.handleRequest(str, serverSess)).To(Succeed())
 			hfs := decodeHeader(responseBuf)
 			Expect(hfs).To(HaveKeyWithValue(":status", []string{"500"}))
 		})
@@ -176,7 +233,7 @@ var _ = Describe("Server", func() {
 			}).AnyTimes()
 			str.EXPECT().CancelRead(quic.ErrorCode(errorEarlyResponse))
 
-			Expect(s.handleRequest(str, qpackDecoder)).To(Succeed())
+			Expect(s.handleRequest(str, serverSess)).To(Succeed())
 			hfs := decodeHeader(responseBuf)
 			Expect(hfs).To(HaveKeyWithValue(":status", []string{"200"}))
 		})
@@ -196,7 +253,7 @@ var _ = Describe("Server", func() {
 			}).AnyTimes()
 			str.EXPECT().CancelRead(quic.ErrorCode(errorEarlyResponse))
 
-			Expect(s.handleRequest(str, qpackDecoder)).To(Succeed())
+			Expect(s.handleRequest(str, serverSess)).To(Succeed())
 			Eventually(handlerCalled).Should(BeClosed())
 		})
 
@@ -210,10 +267,66 @@ var _ = Describe("Server", func() {
 			str.EXPECT().Read(gomock.Any()).Return(0, testErr)
 			str.EXPECT().CancelWrite(quic.ErrorCode(errorRequestCanceled))
 
-			Expect(s.handleRequest(str, qpackDecoder)).To(MatchError(testErr))
+			Expect(s.handleRequest(str, serverSess)).To(MatchError(testErr))
 			Consistently(handlerCalled).ShouldNot(BeClosed())
 		})
 
+		It("rejects a HEADERS frame larger than MaxHeaderBytes", func() {
+			s.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				Fail("handler should not be called")
+			})
+			s.MaxHeaderBytes = 100
+			buf := &bytes.Buffer{}
+			(&headersFrame{Length: 101}).Write(buf)
+			setRequest(buf.Bytes())
+			str.EXPECT().CancelRead(quic.ErrorCode(errorExcessiveLoad))
+			str.EXPECT().CancelWrite(quic.ErrorCode(errorExcessiveLoad))
+
+			Expect(s.handleRequest(str, serverSess)).To(MatchError("HEADERS frame too large: 101 bytes (max: 100)"))
+		})
+
+		It("rejects a header list larger than MaxHeaderBytes", func() {
+			s.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				Fail("handler should not be called")
+			})
+			s.MaxHeaderBytes = 1000
+			exampleGetRequest.Header.Set("Foo", strings.Repeat("a", 1000))
+			// the header block is smaller than the header list, since the value is Huffman encoded
+			setRequest(encodeRequest(exampleGetRequest))
+			str.EXPECT().CancelRead(quic.ErrorCode(errorExcessiveLoad))
+			str.EXPECT().CancelWrite(quic.ErrorCode(errorExcessiveLoad))
+
+			err := s.handleRequest(str, serverSess)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(HavePrefix("header list too large"))
+		})
+
+		It("resets the stream when the first frame is not a HEADERS frame", func() {
+			buf := &bytes.Buffer{}
+			(&dataFrame{Length: 6}).Write(buf)
+			buf.WriteString("foobar")
+			setRequest(buf.Bytes())
+			str.EXPECT().CancelWrite(quic.ErrorCode(errorUnexpectedFrame))
+
+			Expect(s.handleRequest(str, serverSess)).To(MatchError("expected first frame to be a headers frame"))
+		})
+
+		It("resets the stream with GENERAL_PROTOCOL_ERROR when the request is malformed", func() {
+			s.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				Fail("handler should not be called")
+			})
+			headerBlock, err := newQPACKEncoder(0, nil).encode(0, []qpack.HeaderField{
+				{Name: ":method", Value: "GET"},
+				{Name: ":authority", Value: "example.com"},
+			})
+			Expect(err).ToNot(HaveOccurred())
+			setRequest(headersFrameBytes(headerBlock))
+			str.EXPECT().CancelRead(quic.ErrorCode(errorGeneralProtocolError))
+			str.EXPECT().CancelWrite(quic.ErrorCode(errorGeneralProtocolError))
+
+			Expect(s.handleRequest(str, serverSess)).ToNot(Succeed())
+		})
+
 		It("resets the stream when the body of POST request is not read, and the request handler replaces the request.Body", func() {
 			handlerCalled := make(chan struct{})
 			s.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -231,7 +344,7 @@ var _ = Describe("Server", func() {
 			}).AnyTimes()
 			str.EXPECT().CancelRead(quic.ErrorCode(errorEarlyResponse))
 
-			Expect(s.handleRequest(str, qpackDecoder)).To(Succeed())
+			Expect(s.handleRequest(str, serverSess)).To(Succeed())
 			Eventually(handlerCalled).Should(BeClosed())
 		})
 
@@ -253,29 +366,522 @@ var _ = Describe("Server", func() {
 			}).AnyTimes()
 			str.EXPECT().CancelRead(quic.ErrorCode(errorEarlyResponse))
 
-			Expect(s.handleRequest(str, qpackDecoder)).To(Succeed())
+			Expect(s.handleRequest(str, serverSess)).To(Succeed())
 			Eventually(handlerCalled).Should(BeClosed())
 		})
 	})
 
-	Context("setting http headers", func() {
-		var expected http.Header
+	Context("closing gracefully", func() {
+		var (
+			sess      *mockquic.MockSession
+			goAway    chan []byte
+			sessDone  chan struct{}
+			acceptStr chan quic.Stream
+		)
 
-		getExpectedHeader := func(versions []protocol.VersionNumber) http.Header {
-			var versionsAsString []string
-			for _, v := range versions {
-				versionsAsString = append(versionsAsString, v.ToAltSvc())
-			}
-			return http.Header{
-				"Alt-Svc": {fmt.Sprintf(`quic=":443"; ma=2592000; v="%s"`, strings.Join(versionsAsString, ","))},
+		BeforeEach(func() {
+			sess = mockquic.NewMockSession(mockCtrl)
+			controlStr := mockquic.NewMockStream(mockCtrl)
+			controlStr.EXPECT().Write(gomock.Any()).DoAndReturn(func(p []byte) (int, error) { return len(p), nil }) // SETTINGS
+			goAway = make(chan []byte, 1)
+			goAwayChan := goAway
+			controlStr.EXPECT().Write(gomock.Any()).DoAndReturn(func(p []byte) (int, error) {
+				goAwayChan <- p
+				return len(p), nil
+			}).MaxTimes(1)
+			sess.EXPECT().OpenUniStream().Return(controlStr, nil)
+			sess.EXPECT().AcceptUniStream().Return(nil, errors.New("done")).MaxTimes(1)
+			sessDone = make(chan struct{})
+			acceptStr = make(chan quic.Stream, 10)
+			strs, done := acceptStr, sessDone
+			sess.EXPECT().AcceptStream().DoAndReturn(func() (quic.Stream, error) {
+				select {
+				case str := <-strs:
+					return str, nil
+				case <-done:
+					return nil, errors.New("test done")
+				}
+			}).AnyTimes()
+		})
+
+		AfterEach(func() {
+			close(sessDone)
+		})
+
+		// newRequestStream creates a stream that is used for a GET request
+		newRequestStream := func(id protocol.StreamID) *mockquic.MockStream {
+			str := mockquic.NewMockStream(mockCtrl)
+			str.EXPECT().StreamID().Return(id).AnyTimes()
+			reqBuf := &bytes.Buffer{}
+			rw := newRequestWriter(newQPACKEncoder(0, nil), utils.DefaultLogger)
+			reqStr := mockquic.NewMockStream(mockCtrl)
+			reqStr.EXPECT().StreamID().AnyTimes()
+			reqStr.EXPECT().Write(gomock.Any()).DoAndReturn(reqBuf.Write).AnyTimes()
+			reqStr.EXPECT().Close()
+			req, err := http.NewRequest("GET", "https://www.example.com", nil)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(rw.WriteRequest(reqStr, req, false)).To(Succeed())
+			str.EXPECT().Read(gomock.Any()).DoAndReturn(func(p []byte) (int, error) {
+				if reqBuf.Len() == 0 {
+					return 0, io.EOF
+				}
+				return reqBuf.Read(p)
+			}).AnyTimes()
+			str.EXPECT().Context().Return(context.Background()).AnyTimes()
+			str.EXPECT().Write(gomock.Any()).DoAndReturn(func(p []byte) (int, error) { return len(p), nil }).AnyTimes()
+			return str
+		}
+
+		It("sends a GOAWAY frame, rejects new requests and waits for running requests", func() {
+			handlerRunning := make(chan struct{})
+			finishHandler := make(chan struct{})
+			s.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				close(handlerRunning)
+				<-finishHandler
+			})
+			str := newRequestStream(0)
+			str.EXPECT().Close()
+			acceptStr <- str
+			go s.handleConn(sess)
+			Eventually(handlerRunning).Should(BeClosed())
+
+			closed := make(chan struct{})
+			go func() {
+				defer GinkgoRecover()
+				Expect(s.CloseGracefully(time.Hour)).To(Succeed())
+				close(closed)
+			}()
+			var data []byte
+			Eventually(goAway).Should(Receive(&data))
+			f, err := parseNextFrame(bytes.NewReader(data))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(f).To(Equal(&goAwayFrame{StreamID: 4}))
+
+			// requests on streams with higher stream IDs are rejected
+			rejected := make(chan struct{})
+			rejectedStr := mockquic.NewMockStream(mockCtrl)
+			rejectedStr.EXPECT().StreamID().Return(protocol.StreamID(4)).AnyTimes()
+			rejectedStr.EXPECT().CancelRead(quic.ErrorCode(errorRequestRejected))
+			rejectedStr.EXPECT().CancelWrite(quic.ErrorCode(errorRequestRejected)).Do(func(quic.ErrorCode) { close(rejected) })
+			acceptStr <- rejectedStr
+			Eventually(rejected).Should(BeClosed())
+
+			Consistently(closed).ShouldNot(BeClosed())
+			close(finishHandler)
+			Eventually(closed).Should(BeClosed())
+		})
+
+		It("stops waiting for running requests after the timeout", func() {
+			handlerRunning := make(chan struct{})
+			finishHandler := make(chan struct{})
+			defer close(finishHandler)
+			s.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				close(handlerRunning)
+				<-finishHandler
+			})
+			str := newRequestStream(0)
+			str.EXPECT().Close().MaxTimes(1)
+			acceptStr <- str
+			go s.handleConn(sess)
+			Eventually(handlerRunning).Should(BeClosed())
+
+			start := time.Now()
+			Expect(s.CloseGracefully(100 * time.Millisecond)).To(Succeed())
+			Expect(time.Since(start)).To(BeNumerically(">=", 100*time.Millisecond))
+			Eventually(goAway).Should(Receive())
+		})
+
+		It("returns the context's error when shutting down takes too long", func() {
+			handlerRunning := make(chan struct{})
+			finishHandler := make(chan struct{})
+			defer close(finishHandler)
+			s.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				close(handlerRunning)
+				<-finishHandler
+			})
+			str := newRequestStream(0)
+			str.EXPECT().Close().MaxTimes(1)
+			acceptStr <- str
+			go s.handleConn(sess)
+			Eventually(handlerRunning).Should(BeClosed())
+
+			ctx, cancel := context.WithCancel(context.Background())
+			errChan := make(chan error, 1)
+			go func() { errChan <- s.Shutdown(ctx) }()
+			Eventually(goAway).Should(Receive())
+			Consistently(errChan).ShouldNot(Receive())
+			cancel()
+			Eventually(errChan).Should(Receive(MatchError(context.Canceled)))
+		})
+
+		It("rejects requests that are accepted after all running requests completed", func() {
+			handlerCalled := make(chan struct{}, 1)
+			s.Handler = http.HandlerFunc(func(http.ResponseWriter, *http.Request) { handlerCalled <- struct{}{} })
+			// Stream 8 is accepted before stream 4, for example because the packets were reordered.
+			str := newRequestStream(8)
+			str.EXPECT().Close()
+			acceptStr <- str
+			go s.handleConn(sess)
+			Eventually(handlerCalled).Should(Receive())
+
+			Expect(s.CloseGracefully(time.Hour)).To(Succeed())
+			var data []byte
+			Eventually(goAway).Should(Receive(&data))
+			f, err := parseNextFrame(bytes.NewReader(data))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(f).To(Equal(&goAwayFrame{StreamID: 12}))
+
+			// Stream 4 would be allowed by the GOAWAY frame, but the server already stopped waiting for requests.
+			rejected := make(chan struct{})
+			rejectedStr := mockquic.NewMockStream(mockCtrl)
+			rejectedStr.EXPECT().StreamID().Return(protocol.StreamID(4)).AnyTimes()
+			rejectedStr.EXPECT().CancelRead(quic.ErrorCode(errorRequestRejected))
+			rejectedStr.EXPECT().CancelWrite(quic.ErrorCode(errorRequestRejected)).Do(func(quic.ErrorCode) { close(rejected) })
+			acceptStr <- rejectedStr
+			Eventually(rejected).Should(BeClosed())
+			Consistently(handlerCalled).ShouldNot(Receive())
+		})
+
+		It("sends a GOAWAY frame on new connections", func() {
+			Expect(s.CloseGracefully(0)).To(Succeed())
+			go s.handleConn(sess)
+			var data []byte
+			Eventually(goAway).Should(Receive(&data))
+			f, err := parseNextFrame(bytes.NewReader(data))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(f).To(Equal(&goAwayFrame{StreamID: 0}))
+		})
+	})
+
+	Context("control stream handling", func() {
+		var (
+			sess              *mockquic.MockSession
+			controlStrBuf     *bytes.Buffer
+			controlStrWritten chan struct{}
+			closed            chan struct{}
+		)
+
+		// newUniStream creates a unidirectional stream that the peer opens.
+		// After data is consumed, reading returns io.EOF if fin is set,
+		// and blocks until the test is over otherwise.
+		newUniStream := func(data []byte, fin bool) *mockquic.MockStream {
+			str := mockquic.NewMockStream(mockCtrl)
+			buf := bytes.NewBuffer(data)
+			testDone := closed // the Read call might happen after the next test started
+			str.EXPECT().Read(gomock.Any()).DoAndReturn(func(p []byte) (int, error) {
+				if buf.Len() == 0 {
+					if fin {
+						return 0, io.EOF
+					}
+					<-testDone
+					return 0, errors.New("test done")
+				}
+				return buf.Read(p)
+			}).AnyTimes()
+			str.EXPECT().StreamID().AnyTimes()
+			return str
+		}
+
+		// acceptUniStreams makes the session return the given streams,
+		// and then blocks until the test is over.
+		acceptUniStreams := func(strs ...quic.ReceiveStream) {
+			for _, str := range strs {
+				sess.EXPECT().AcceptUniStream().Return(str, nil)
 			}
+			testDone := closed
+			sess.EXPECT().AcceptUniStream().DoAndReturn(func() (quic.ReceiveStream, error) {
+				<-testDone
+				return nil, errors.New("test done")
+			}).MaxTimes(1)
+		}
+
+		encodeSettings := func() []byte {
+			buf := &bytes.Buffer{}
+			(&settingsFrame{}).Write(buf)
+			return buf.Bytes()
+		}
+
+		BeforeEach(func() {
+			closed = make(chan struct{})
+			sess = mockquic.NewMockSession(mockCtrl)
+			controlStr := mockquic.NewMockStream(mockCtrl)
+			controlStrBuf = &bytes.Buffer{}
+			controlStrWritten = make(chan struct{})
+			written, buf := controlStrWritten, controlStrBuf
+			controlStr.EXPECT().Write(gomock.Any()).DoAndReturn(func(p []byte) (int, error) {
+				defer close(written)
+				return buf.Write(p)
+			})
+			sess.EXPECT().OpenUniStream().Return(controlStr, nil)
+			testDone := closed
+			sess.EXPECT().AcceptStream().DoAndReturn(func() (quic.Stream, error) {
+				<-testDone
+				return nil, errors.New("test done")
+			}).MaxTimes(1)
+		})
+
+		AfterEach(func() {
+			// reading from the streams fails after the test is over, which might lead to the session being closed
+			sess.EXPECT().CloseWithError(gomock.Any(), gomock.Any()).AnyTimes()
+			close(closed)
+		})
+
+		It("opens a control stream and sends a SETTINGS frame", func() {
+			acceptUniStreams()
+			go s.handleConn(sess)
+			Eventually(controlStrWritten).Should(BeClosed())
+			streamType, err := utils.ReadVarInt(controlStrBuf)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(streamType).To(BeEquivalentTo(streamTypeControlStream))
+			f, err := parseNextFrame(controlStrBuf)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(f).To(BeAssignableToTypeOf(&settingsFrame{}))
+			Expect(f.(*settingsFrame).settings).To(HaveKeyWithValue(uint64(settingMaxHeaderListSize), uint64(http.DefaultMaxHeaderBytes)))
+		})
+
+		It("accepts the client's control stream", func() {
+			str := newUniStream(append([]byte{streamTypeControlStream}, encodeSettings()...), false)
+			acceptUniStreams(str)
+			go s.handleConn(sess)
+			Consistently(closed).ShouldNot(BeClosed()) // no calls to CloseWithError
+		})
+
+		It("closes the connection when the client opens a second control stream", func() {
+			str1 := newUniStream(append([]byte{streamTypeControlStream}, encodeSettings()...), false)
+			str2 := newUniStream(append([]byte{streamTypeControlStream}, encodeSettings()...), false)
+			acceptUniStreams(str1, str2)
+			done := make(chan struct{})
+			sess.EXPECT().CloseWithError(quic.ErrorCode(errorWrongStreamCount), gomock.Any()).Do(func(quic.ErrorCode, error) { close(done) })
+			go s.handleConn(sess)
+			Eventually(done).Should(BeClosed())
+		})
+
+		It("closes the connection when the first frame on the control stream is not a SETTINGS frame", func() {
+			buf := &bytes.Buffer{}
+			(&dataFrame{Length: 6}).Write(buf)
+			str := newUniStream(append([]byte{streamTypeControlStream}, buf.Bytes()...), false)
+			acceptUniStreams(str)
+			done := make(chan struct{})
+			sess.EXPECT().CloseWithError(quic.ErrorCode(errorMissingSettings), gomock.Any()).Do(func(quic.ErrorCode, error) { close(done) })
+			go s.handleConn(sess)
+			Eventually(done).Should(BeClosed())
+		})
+
+		It("closes the connection when the SETTINGS frame is malformed", func() {
+			buf := &bytes.Buffer{}
+			utils.WriteVarInt(buf, 0x4)
+			utils.WriteVarInt(buf, 4)
+			utils.WriteVarInt(buf, 13)
+			utils.WriteVarInt(buf, 37)
+			utils.WriteVarInt(buf, 13)
+			utils.WriteVarInt(buf, 38)
+			str := newUniStream(append([]byte{streamTypeControlStream}, buf.Bytes()...), false)
+			acceptUniStreams(str)
+			done := make(chan struct{})
+			sess.EXPECT().CloseWithError(quic.ErrorCode(errorMalformedFrameType(0x4)), gomock.Any()).Do(func(quic.ErrorCode, error) { close(done) })
+			go s.handleConn(sess)
+			Eventually(done).Should(BeClosed())
+		})
+
+		It("closes the connection when the client sends a second SETTINGS frame", func() {
+			data := append([]byte{streamTypeControlStream}, encodeSettings()...)
+			str := newUniStream(append(data, encodeSettings()...), false)
+			acceptUniStreams(str)
+			done := make(chan struct{})
+			sess.EXPECT().CloseWithError(quic.ErrorCode(errorUnexpectedFrame), gomock.Any()).Do(func(quic.ErrorCode, error) { close(done) })
+			go s.handleConn(sess)
+			Eventually(done).Should(BeClosed())
+		})
+
+		It("closes the connection when the client sends a GOAWAY frame", func() {
+			buf := &bytes.Buffer{}
+			(&goAwayFrame{StreamID: 4}).Write(buf)
+			data := append([]byte{streamTypeControlStream}, encodeSettings()...)
+			str := newUniStream(append(data, buf.Bytes()...), false)
+			acceptUniStreams(str)
+			done := make(chan struct{})
+			sess.EXPECT().CloseWithError(quic.ErrorCode(errorUnexpectedFrame), gomock.Any()).Do(func(quic.ErrorCode, error) { close(done) })
+			go s.handleConn(sess)
+			Eventually(done).Should(BeClosed())
+		})
+
+		It("closes the connection when the client closes the control stream", func() {
+			str := newUniStream(append([]byte{streamTypeControlStream}, encodeSettings()...), true)
+			acceptUniStreams(str)
+			done := make(chan struct{})
+			sess.EXPECT().CloseWithError(quic.ErrorCode(errorClosedCriticalStream), gomock.Any()).Do(func(quic.ErrorCode, error) { close(done) })
+			go s.handleConn(sess)
+			Eventually(done).Should(BeClosed())
+		})
+
+		It("closes the connection when the client opens a push stream", func() {
+			str := newUniStream([]byte{streamTypePushStream}, false)
+			acceptUniStreams(str)
+			done := make(chan struct{})
+			sess.EXPECT().CloseWithError(quic.ErrorCode(errorWrongStreamDirection), gomock.Any()).Do(func(quic.ErrorCode, error) { close(done) })
+			go s.handleConn(sess)
+			Eventually(done).Should(BeClosed())
+		})
+
+		It("closes the connection when the client opens two QPACK encoder streams", func() {
+			str1 := newUniStream([]byte{streamTypeQPACKEncoderStream}, false)
+			str2 := newUniStream([]byte{streamTypeQPACKEncoderStream}, false)
+			acceptUniStreams(str1, str2)
+			done := make(chan struct{})
+			sess.EXPECT().CloseWithError(quic.ErrorCode(errorWrongStreamCount), gomock.Any()).Do(func(quic.ErrorCode, error) { close(done) })
+			go s.handleConn(sess)
+			Eventually(done).Should(BeClosed())
+		})
+
+		It("closes the connection when the client reduces the MAX_PUSH_ID", func() {
+			buf := &bytes.Buffer{}
+			buf.WriteByte(streamTypeControlStream)
+			(&settingsFrame{}).Write(buf)
+			(&maxPushIDFrame{PushID: 10}).Write(buf)
+			(&maxPushIDFrame{PushID: 9}).Write(buf)
+			str := newUniStream(buf.Bytes(), false)
+			acceptUniStreams(str)
+			done := make(chan struct{})
+			sess.EXPECT().CloseWithError(quic.ErrorCode(errorMalformedFrameType(0xd)), gomock.Any()).Do(func(quic.ErrorCode, error) { close(done) })
+			go s.handleConn(sess)
+			Eventually(done).Should(BeClosed())
+		})
+
+		It("cancels reading on streams of unknown type", func() {
+			str := newUniStream([]byte{0x21}, false)
+			done := make(chan struct{})
+			str.EXPECT().CancelRead(quic.ErrorCode(errorUnknownStreamType)).Do(func(quic.ErrorCode) { close(done) })
+			acceptUniStreams(str)
+			go s.handleConn(sess)
+			Eventually(done).Should(BeClosed())
+		})
+	})
+
+	Context("server push", func() {
+		var (
+			sess       *mockquic.MockSession
+			serverSess *serverSession
+			reqStrBuf  *bytes.Buffer
+		)
+
+		pushHeaders := []qpack.HeaderField{
+			{Name: ":method", Value: "GET"},
+			{Name: ":scheme", Value: "https"},
+			{Name: ":authority", Value: "www.example.com"},
+			{Name: ":path", Value: "/style.css"},
+		}
+
+		// newPushStream creates a push stream and returns a buffer containing the data written to it.
+		newPushStream := func() (*mockquic.MockStream, *bytes.Buffer) {
+			str := mockquic.NewMockStream(mockCtrl)
+			buf := &bytes.Buffer{}
+			str.EXPECT().Write(gomock.Any()).DoAndReturn(buf.Write).AnyTimes()
+			str.EXPECT().Context().Return(context.Background()).AnyTimes()
+			str.EXPECT().StreamID().AnyTimes()
+			return str, buf
+		}
+
+		activeRequests := func() int {
+			s.listenerMutex.Lock()
+			defer s.listenerMutex.Unlock()
+			return s.activeRequests
 		}
 
 		BeforeEach(func() {
-			Expect(getExpectedHeader([]protocol.VersionNumber{99, 90, 9})).To(Equal(http.Header{"Alt-Svc": {`quic=":443"; ma=2592000; v="99,90,9"`}}))
-			expected = getExpectedHeader(protocol.SupportedVersions)
+			sess = mockquic.NewMockSession(mockCtrl)
+			serverSess = &serverSession{
+				Session:     sess,
+				server:      s,
+				encoder:     newQPACKEncoder(0, nil),
+				pushStreams: make(map[uint64]quic.SendStream),
+			}
+			reqStrBuf = &bytes.Buffer{}
 		})
 
+		It("doesn't push before receiving a MAX_PUSH_ID", func() {
+			Expect(serverSess.push(reqStrBuf, 0, pushHeaders)).To(MatchError(http.ErrNotSupported))
+			Expect(reqStrBuf.Len()).To(BeZero())
+		})
+
+		It("sends a PUSH_PROMISE and serves the request on a push stream", func() {
+			pushed := make(chan *http.Request, 1)
+			s.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				pushed <- r
+				w.Write([]byte("foobar"))
+			})
+			str, pushStrBuf := newPushStream()
+			done := make(chan struct{})
+			str.EXPECT().Close().Do(func() { close(done) })
+			sess.EXPECT().OpenUniStream().Return(str, nil)
+
+			Expect(serverSess.setMaxPushID(0)).To(Succeed())
+			Expect(serverSess.push(reqStrBuf, 0, pushHeaders)).To(Succeed())
+			// check the PUSH_PROMISE frame
+			f, err := parseNextFrame(reqStrBuf)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(f).To(BeAssignableToTypeOf(&pushPromiseFrame{}))
+			Expect(f.(*pushPromiseFrame).PushID).To(BeZero())
+			hfs, err := qpack.NewDecoder(nil).DecodeFull(reqStrBuf.Bytes())
+			Expect(err).ToNot(HaveOccurred())
+			Expect(hfs).To(Equal(pushHeaders))
+			// check the promised request that was served
+			var req *http.Request
+			Eventually(pushed).Should(Receive(&req))
+			Expect(req.Method).To(Equal("GET"))
+			Expect(req.Host).To(Equal("www.example.com"))
+			Expect(req.RequestURI).To(Equal("/style.css"))
+			// check the push stream
+			Eventually(done).Should(BeClosed())
+			streamType, err := utils.ReadVarInt(pushStrBuf)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(streamType).To(BeEquivalentTo(streamTypePushStream))
+			pushID, err := utils.ReadVarInt(pushStrBuf)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(pushID).To(BeZero())
+			hf, err := parseNextFrame(pushStrBuf)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(hf).To(BeAssignableToTypeOf(&headersFrame{}))
+			pushStrBuf.Next(int(hf.(*headersFrame).Length))
+			df, err := parseNextFrame(pushStrBuf)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(df).To(Equal(&dataFrame{Length: 6}))
+			Expect(pushStrBuf.String()).To(Equal("foobar"))
+		})
+
+		It("doesn't push more than allowed by the MAX_PUSH_ID", func() {
+			s.Handler = http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})
+			str, _ := newPushStream()
+			str.EXPECT().Close().MaxTimes(1)
+			sess.EXPECT().OpenUniStream().Return(str, nil)
+			Expect(serverSess.setMaxPushID(0)).To(Succeed())
+			Expect(serverSess.push(reqStrBuf, 0, pushHeaders)).To(Succeed())
+			Expect(serverSess.push(reqStrBuf, 0, pushHeaders)).To(MatchError(errPushLimitReached))
+			Eventually(activeRequests).Should(BeZero())
+		})
+
+		It("cancels the push stream when the client sends a CANCEL_PUSH", func() {
+			handlerCalled := make(chan struct{})
+			unblock := make(chan struct{})
+			s.Handler = http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+				close(handlerCalled)
+				<-unblock
+			})
+			str, _ := newPushStream()
+			str.EXPECT().CancelWrite(quic.ErrorCode(errorRequestCanceled))
+			str.EXPECT().Close()
+			sess.EXPECT().OpenUniStream().Return(str, nil)
+			Expect(serverSess.setMaxPushID(0)).To(Succeed())
+			Expect(serverSess.push(reqStrBuf, 0, pushHeaders)).To(Succeed())
+			Eventually(handlerCalled).Should(BeClosed())
+			serverSess.cancelPush(0)
+			close(unblock)
+			Eventually(activeRequests).Should(BeZero())
+		})
+	})
+
+	Context("setting http headers", func() {
+		expected := http.Header{"Alt-Svc": {`h3-19=":443"; ma=2592000`}}
+
 		It("sets proper headers with numeric port", func() {
 			s.Server.Addr = ":443"
 			hdr := http.Header{}
@@ -306,6 +912,14 @@ var _ = Describe("Server", func() {
 			Expect(s.SetQuicHeaders(hdr)).To(Succeed())
 			Expect(hdr).To(Equal(expected))
 		})
+
+		It("uses the configured max-age", func() {
+			s.Server.Addr = ":1337"
+			s.AltSvcMaxAge = time.Hour
+			hdr := http.Header{}
+			Expect(s.SetQuicHeaders(hdr)).To(Succeed())
+			Expect(hdr).To(Equal(http.Header{"Alt-Svc": {`h3-19=":1337"; ma=3600`}}))
+		})
 	})
 
 	It("errors when ListenAndServe is called with s.Server nil", func() {
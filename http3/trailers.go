@@ -0,0 +1,98 @@
+package http3
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/textproto"
+	"sort"
+	"strings"
+
+	"github.com/marten-seemann/qpack"
+	"golang.org/x/net/http/httpguts"
+)
+
+// Trailers are sent in a HEADERS frame following the DATA frames of a request or a response.
+
+// declaredTrailers parses the keys of the Trailer header.
+// copied from http2/server.go
+func declaredTrailers(vv []string) []string {
+	var keys []string
+	for _, v := range vv {
+		for _, key := range strings.Split(v, ",") {
+			key = http.CanonicalHeaderKey(textproto.TrimString(key))
+			switch key {
+			case "Transfer-Encoding", "Trailer", "Content-Length":
+				// Bogus. (copy of http1 rules)
+				// Ignore.
+			default:
+				if key != "" {
+					keys = append(keys, key)
+				}
+			}
+		}
+	}
+	return keys
+}
+
+// commaSeparatedTrailers returns the value of the Trailer header for a request.
+// copied from http2/transport.go
+func commaSeparatedTrailers(req *http.Request) (string, error) {
+	keys := make([]string, 0, len(req.Trailer))
+	for k := range req.Trailer {
+		k = http.CanonicalHeaderKey(k)
+		switch k {
+		case "Transfer-Encoding", "Trailer", "Content-Length":
+			return "", fmt.Errorf("invalid Trailer key %q", k)
+		}
+		keys = append(keys, k)
+	}
+	if len(keys) > 0 {
+		sort.Strings(keys)
+		return strings.Join(keys, ","), nil
+	}
+	return "", nil
+}
+
+// encodeTrailers converts the trailers into header fields.
+func encodeTrailers(trailer http.Header) ([]qpack.HeaderField, error) {
+	var hfs []qpack.HeaderField
+	for k, vv := range trailer {
+		if !httpguts.ValidHeaderFieldName(k) {
+			return nil, fmt.Errorf("invalid HTTP trailer name %q", k)
+		}
+		if !httpguts.ValidTrailerHeader(k) {
+			return nil, fmt.Errorf("invalid HTTP trailer %q", k)
+		}
+		for _, v := range vv {
+			if !httpguts.ValidHeaderFieldValue(v) {
+				return nil, fmt.Errorf("invalid HTTP trailer value %q for trailer %q", v, k)
+			}
+			hfs = append(hfs, qpack.HeaderField{Name: strings.ToLower(k), Value: v})
+		}
+	}
+	return hfs, nil
+}
+
+// addTrailers adds the header fields received in the trailers to trailer.
+func addTrailers(trailer http.Header, hfs []qpack.HeaderField) error {
+	for _, hf := range hfs {
+		if hf.IsPseudo() {
+			return fmt.Errorf("trailers must not contain pseudo header %q", hf.Name)
+		}
+		key := http.CanonicalHeaderKey(hf.Name)
+		if !httpguts.ValidTrailerHeader(key) {
+			return fmt.Errorf("invalid HTTP trailer %q", key)
+		}
+		trailer[key] = append(trailer[key], hf.Value)
+	}
+	return nil
+}
+
+// headersFrameBytes serializes a HEADERS frame, including the header block.
+func headersFrameBytes(headerBlock []byte) []byte {
+	buf := &bytes.Buffer{}
+	(&headersFrame{Length: uint64(len(headerBlock))}).Write(buf)
+	buf.Write(headerBlock)
+	return buf.Bytes()
+}
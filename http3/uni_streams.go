@@ -0,0 +1,143 @@
+package http3
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/lucas-clemente/quic-go"
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+	"github.com/lucas-clemente/quic-go/internal/utils"
+)
+
+// A uniStreamHandler handles the unidirectional streams opened by the peer.
+// It is used by both the client and the server.
+// The frames that are only valid in one direction are handled by the callbacks.
+type uniStreamHandler struct {
+	sess        quic.Session
+	perspective protocol.Perspective
+	encoder     *qpackEncoder
+	decoder     *qpackDecoder
+	logger      utils.Logger
+
+	// handleSettings is called with the SETTINGS received on the control stream. It may be nil.
+	handleSettings func(map[uint64]uint64)
+	// handleControlFrame is called for CANCEL_PUSH frames,
+	// as well as for GOAWAY frames (on the client) and MAX_PUSH_ID frames (on the server).
+	handleControlFrame func(frame) (errorCode, error)
+	// handlePushStream is called for push streams. It is only used by the client.
+	handlePushStream func(quic.ReceiveStream)
+
+	// count the number of streams of each type, to enforce that they are only opened once
+	numControlStreams, numQPACKEncoderStreams, numQPACKDecoderStreams int32
+}
+
+func (h *uniStreamHandler) run() {
+	for {
+		str, err := h.sess.AcceptUniStream()
+		if err != nil {
+			h.logger.Debugf("Accepting unidirectional stream failed: %s", err)
+			h.decoder.close()
+			return
+		}
+		go h.handleStream(str)
+	}
+}
+
+func (h *uniStreamHandler) handleStream(str quic.ReceiveStream) {
+	streamType, err := utils.ReadVarInt(&byteReaderImpl{str})
+	if err != nil {
+		h.logger.Debugf("Reading stream type on stream %d failed: %s", str.StreamID(), err)
+		return
+	}
+	switch streamType {
+	case streamTypeControlStream:
+		if atomic.AddInt32(&h.numControlStreams, 1) > 1 {
+			h.sess.CloseWithError(quic.ErrorCode(errorWrongStreamCount), errors.New("received a second control stream"))
+			return
+		}
+		if errCode, err := h.handleControlStream(str); err != nil {
+			h.logger.Debugf("Handling the control stream failed: %s", err)
+			h.sess.CloseWithError(quic.ErrorCode(errCode), err)
+		}
+	case streamTypePushStream:
+		if h.perspective == protocol.PerspectiveServer { // only the server can push
+			h.sess.CloseWithError(quic.ErrorCode(errorWrongStreamDirection), errors.New("received a push stream from the client"))
+			return
+		}
+		h.handlePushStream(str)
+	case streamTypeQPACKEncoderStream, streamTypeQPACKDecoderStream:
+		counter := &h.numQPACKEncoderStreams
+		if streamType == streamTypeQPACKDecoderStream {
+			counter = &h.numQPACKDecoderStreams
+		}
+		if atomic.AddInt32(counter, 1) > 1 {
+			h.sess.CloseWithError(quic.ErrorCode(errorWrongStreamCount), fmt.Errorf("received a second QPACK stream of type %d", streamType))
+			return
+		}
+		if errCode, err := handleQPACKStream(streamType, str, h.encoder, h.decoder); err != nil {
+			h.logger.Debugf("Handling the QPACK stream failed: %s", err)
+			h.sess.CloseWithError(quic.ErrorCode(errCode), err)
+		}
+	default:
+		str.CancelRead(quic.ErrorCode(errorUnknownStreamType))
+	}
+}
+
+// handleControlStream reads the frames sent on the peer's control stream.
+// It only returns when the connection needs to be closed, along with the error code to close it with.
+func (h *uniStreamHandler) handleControlStream(str quic.ReceiveStream) (errorCode, error) {
+	f, err := parseNextFrame(str)
+	if err != nil {
+		return controlStreamErrorCode(err), err
+	}
+	settings, ok := f.(*settingsFrame)
+	if !ok {
+		return errorMissingSettings, errors.New("first frame on the control stream was not a SETTINGS frame")
+	}
+	h.logger.Debugf("Received SETTINGS: %v", settings.settings)
+	if err := h.encoder.setPeerSettings(settings.settings); err != nil {
+		return errorInternalError, err
+	}
+	if h.handleSettings != nil {
+		h.handleSettings(settings.settings)
+	}
+	for {
+		f, err := parseNextFrame(str)
+		if err != nil {
+			return controlStreamErrorCode(err), err
+		}
+		switch f := f.(type) {
+		case *settingsFrame:
+			return errorUnexpectedFrame, errors.New("received a second SETTINGS frame")
+		case *dataFrame, *headersFrame:
+			return errorWrongStream, fmt.Errorf("received a %T on the control stream", f)
+		case *goAwayFrame:
+			if h.perspective == protocol.PerspectiveServer { // only the server can send a GOAWAY
+				return errorUnexpectedFrame, errors.New("received a GOAWAY frame from the client")
+			}
+			if errCode, err := h.handleControlFrame(f); err != nil {
+				return errCode, err
+			}
+		case *maxPushIDFrame:
+			if h.perspective == protocol.PerspectiveClient { // only the client can send a MAX_PUSH_ID
+				return errorUnexpectedFrame, errors.New("received a MAX_PUSH_ID frame from the server")
+			}
+			if errCode, err := h.handleControlFrame(f); err != nil {
+				return errCode, err
+			}
+		case *cancelPushFrame:
+			if errCode, err := h.handleControlFrame(f); err != nil {
+				return errCode, err
+			}
+		}
+	}
+}
+
+// controlStreamErrorCode determines the error code used when reading from the control stream fails.
+func controlStreamErrorCode(err error) errorCode {
+	if mErr, ok := err.(*malformedFrameError); ok {
+		return mErr.ErrorCode()
+	}
+	return errorClosedCriticalStream
+}
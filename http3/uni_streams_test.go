@@ -0,0 +1,131 @@
+package http3
+
+import (
+	"bytes"
+	"errors"
+
+	"github.com/golang/mock/gomock"
+	quic "github.com/lucas-clemente/quic-go"
+	mockquic "github.com/lucas-clemente/quic-go/internal/mocks/quic"
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+	"github.com/lucas-clemente/quic-go/internal/utils"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Unidirectional Stream Handler", func() {
+	var (
+		sess    *mockquic.MockSession
+		handler *uniStreamHandler
+		frames  []frame
+	)
+
+	newStream := func(data []byte) *mockquic.MockStream {
+		buf := bytes.NewBuffer(data)
+		str := mockquic.NewMockStream(mockCtrl)
+		str.EXPECT().StreamID().AnyTimes()
+		str.EXPECT().Read(gomock.Any()).DoAndReturn(buf.Read).AnyTimes()
+		return str
+	}
+
+	newControlStream := func(fs ...interface{ Write(*bytes.Buffer) }) *mockquic.MockStream {
+		buf := &bytes.Buffer{}
+		utils.WriteVarInt(buf, streamTypeControlStream)
+		(&settingsFrame{}).Write(buf)
+		for _, f := range fs {
+			f.Write(buf)
+		}
+		return newStream(buf.Bytes())
+	}
+
+	newHandler := func(pers protocol.Perspective) *uniStreamHandler {
+		return &uniStreamHandler{
+			sess:        sess,
+			perspective: pers,
+			encoder:     newQPACKEncoder(0, nil),
+			decoder:     newQPACKDecoder(0, nil),
+			logger:      utils.DefaultLogger,
+			handleControlFrame: func(f frame) (errorCode, error) {
+				frames = append(frames, f)
+				return 0, nil
+			},
+		}
+	}
+
+	BeforeEach(func() {
+		sess = mockquic.NewMockSession(mockCtrl)
+		frames = nil
+	})
+
+	It("rejects unknown stream types", func() {
+		handler = newHandler(protocol.PerspectiveServer)
+		str := newStream([]byte{0x21})
+		str.EXPECT().CancelRead(quic.ErrorCode(errorUnknownStreamType))
+		handler.handleStream(str)
+	})
+
+	It("closes the connection with the error code returned for a QPACK stream", func() {
+		handler = newHandler(protocol.PerspectiveServer)
+		sess.EXPECT().CloseWithError(quic.ErrorCode(errorClosedCriticalStream), gomock.Any())
+		handler.handleStream(newStream([]byte{streamTypeQPACKEncoderStream}))
+	})
+
+	Context("for the client", func() {
+		BeforeEach(func() {
+			handler = newHandler(protocol.PerspectiveClient)
+		})
+
+		It("passes push streams to the push stream handler", func() {
+			var pushStr quic.ReceiveStream
+			handler.handlePushStream = func(str quic.ReceiveStream) { pushStr = str }
+			str := newStream([]byte{streamTypePushStream})
+			handler.handleStream(str)
+			Expect(pushStr).To(Equal(str))
+		})
+
+		It("passes GOAWAY and CANCEL_PUSH frames to the control frame handler", func() {
+			var settings map[uint64]uint64
+			handler.handleSettings = func(s map[uint64]uint64) { settings = s }
+			sess.EXPECT().CloseWithError(quic.ErrorCode(errorClosedCriticalStream), gomock.Any())
+			handler.handleStream(newControlStream(&goAwayFrame{StreamID: 4}, &cancelPushFrame{PushID: 1}))
+			Expect(settings).ToNot(BeNil())
+			Expect(frames).To(Equal([]frame{&goAwayFrame{StreamID: 4}, &cancelPushFrame{PushID: 1}}))
+		})
+
+		It("closes the connection when receiving a MAX_PUSH_ID frame", func() {
+			sess.EXPECT().CloseWithError(quic.ErrorCode(errorUnexpectedFrame), gomock.Any())
+			handler.handleStream(newControlStream(&maxPushIDFrame{PushID: 1}))
+			Expect(frames).To(BeEmpty())
+		})
+
+		It("closes the connection when the control frame handler fails", func() {
+			handler.handleControlFrame = func(frame) (errorCode, error) { return errorLimitExceeded, errors.New("limit exceeded") }
+			sess.EXPECT().CloseWithError(quic.ErrorCode(errorLimitExceeded), gomock.Any())
+			handler.handleStream(newControlStream(&cancelPushFrame{PushID: 1}))
+		})
+	})
+
+	Context("for the server", func() {
+		BeforeEach(func() {
+			handler = newHandler(protocol.PerspectiveServer)
+		})
+
+		It("closes the connection when the client opens a push stream", func() {
+			sess.EXPECT().CloseWithError(quic.ErrorCode(errorWrongStreamDirection), gomock.Any())
+			handler.handleStream(newStream([]byte{streamTypePushStream}))
+		})
+
+		It("passes MAX_PUSH_ID and CANCEL_PUSH frames to the control frame handler", func() {
+			sess.EXPECT().CloseWithError(quic.ErrorCode(errorClosedCriticalStream), gomock.Any())
+			handler.handleStream(newControlStream(&maxPushIDFrame{PushID: 10}, &cancelPushFrame{PushID: 1}))
+			Expect(frames).To(Equal([]frame{&maxPushIDFrame{PushID: 10}, &cancelPushFrame{PushID: 1}}))
+		})
+
+		It("closes the connection when receiving a GOAWAY frame", func() {
+			sess.EXPECT().CloseWithError(quic.ErrorCode(errorUnexpectedFrame), gomock.Any())
+			handler.handleStream(newControlStream(&goAwayFrame{StreamID: 4}))
+			Expect(frames).To(BeEmpty())
+		})
+	})
+})
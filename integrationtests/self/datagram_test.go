@@ -0,0 +1,95 @@
+package self_test
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"sync/atomic"
+
+	quic "github.com/lucas-clemente/quic-go"
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+	"github.com/lucas-clemente/quic-go/internal/testdata"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Datagram test", func() {
+	const numMessages = 100
+
+	var server quic.Listener
+
+	startServer := func(enableDatagrams bool) {
+		var err error
+		server, err = quic.ListenAddr(
+			"localhost:0",
+			testdata.GetTLSConfig(),
+			&quic.Config{
+				Versions:        []protocol.VersionNumber{protocol.VersionTLS},
+				EnableDatagrams: enableDatagrams,
+			},
+		)
+		Expect(err).ToNot(HaveOccurred())
+	}
+
+	dial := func() quic.Session {
+		sess, err := quic.DialAddr(
+			fmt.Sprintf("localhost:%d", server.Addr().(*net.UDPAddr).Port),
+			&tls.Config{RootCAs: testdata.GetRootCA(), ServerName: "localhost"},
+			&quic.Config{
+				Versions:        []protocol.VersionNumber{protocol.VersionTLS},
+				EnableDatagrams: true,
+			},
+		)
+		Expect(err).ToNot(HaveOccurred())
+		return sess
+	}
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("sends and receives messages", func() {
+		startServer(true)
+		received := make(chan []byte, numMessages)
+		go func() {
+			defer GinkgoRecover()
+			sess, err := server.Accept()
+			Expect(err).ToNot(HaveOccurred())
+			for {
+				data, err := sess.ReceiveMessage()
+				if err != nil {
+					return
+				}
+				received <- data
+			}
+		}()
+
+		sess := dial()
+		var numAcked, numLost int32
+		for i := 0; i < numMessages; i++ {
+			Expect(sess.SendMessageWithCallback([]byte(fmt.Sprintf("message %d", i)), func(acked bool) {
+				if acked {
+					atomic.AddInt32(&numAcked, 1)
+				} else {
+					atomic.AddInt32(&numLost, 1)
+				}
+			})).To(Succeed())
+		}
+		// The messages are sent over the loopback interface, so we don't expect any of them to be lost.
+		for i := 0; i < numMessages; i++ {
+			var data []byte
+			Eventually(received).Should(Receive(&data))
+			Expect(string(data)).To(HavePrefix("message "))
+		}
+		Eventually(func() int32 { return atomic.LoadInt32(&numAcked) + atomic.LoadInt32(&numLost) }).Should(BeEquivalentTo(numMessages))
+		Expect(sess.Close()).To(Succeed())
+	})
+
+	It("errors when sending messages to a peer that doesn't support them", func() {
+		startServer(false)
+		sess := dial()
+		Expect(sess.SendMessage([]byte("foobar"))).To(MatchError("peer doesn't support receiving datagrams"))
+		Expect(sess.Close()).To(Succeed())
+	})
+})
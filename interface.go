@@ -7,7 +7,9 @@ import (
 	"net"
 	"time"
 
+	"github.com/lucas-clemente/quic-go/congestion"
 	"github.com/lucas-clemente/quic-go/internal/protocol"
+	"github.com/lucas-clemente/quic-go/logging"
 )
 
 // The StreamID is the ID of a QUIC stream.
@@ -25,6 +27,16 @@ type Cookie struct {
 // An ErrorCode is an application-defined error code.
 type ErrorCode = protocol.ApplicationErrorCode
 
+// A StreamPriority determines the order in which data is sent on streams.
+// Streams with a lower Urgency are sent first.
+// Data on streams of the same urgency that are Incremental is interleaved in a round-robin fashion,
+// whereas streams that are not Incremental are sent one after the other, in the order they became active.
+// By default, streams have an urgency of 3 and are incremental.
+type StreamPriority struct {
+	Urgency     uint8
+	Incremental bool
+}
+
 // Stream is the interface implemented by QUIC streams
 type Stream interface {
 	// StreamID returns the stream ID.
@@ -75,6 +87,10 @@ type Stream interface {
 	// some of the data was successfully written.
 	// A zero value for t means Write will not time out.
 	SetWriteDeadline(t time.Time) error
+	// SetPriority sets the priority of the stream.
+	// It only affects the order in which data is sent, and is not signaled to the peer.
+	// Warning: This API should not be considered stable and might change soon.
+	SetPriority(StreamPriority)
 	// SetDeadline sets the read and write deadlines associated
 	// with the connection. It is equivalent to calling both
 	// SetReadDeadline and SetWriteDeadline.
@@ -107,6 +123,8 @@ type SendStream interface {
 	Context() context.Context
 	// see Stream.SetWriteDeadline
 	SetWriteDeadline(t time.Time) error
+	// see Stream.SetPriority
+	SetPriority(StreamPriority)
 }
 
 // StreamError is returned by Read and Write when the peer cancels the stream.
@@ -152,6 +170,13 @@ type Session interface {
 	LocalAddr() net.Addr
 	// RemoteAddr returns the address of the peer.
 	RemoteAddr() net.Addr
+	// MigrateConn migrates the session to a new packet conn, e.g. when switching from Wi-Fi to a cellular network.
+	// The new path is validated before it is used, and it blocks until path validation succeeded or failed.
+	// If it fails, the session continues using the old packet conn.
+	// Only clients can migrate, and only after the handshake completed.
+	// The packet conn is not closed when the session is closed.
+	// Warning: This API should not be considered stable and might change soon.
+	MigrateConn(net.PacketConn) error
 	// Close the connection.
 	io.Closer
 	// Close the connection with an error.
@@ -163,6 +188,65 @@ type Session interface {
 	// ConnectionState returns basic details about the QUIC connection.
 	// Warning: This API should not be considered stable and might change soon.
 	ConnectionState() tls.ConnectionState
+	// Stats returns a snapshot of the statistics of the connection.
+	// It is safe to call Stats concurrently, also after the session was closed.
+	Stats() ConnectionStats
+	// SendMessage sends a message as a DATAGRAM frame (see Config.EnableDatagrams).
+	// The message is sent unreliably: it is not retransmitted if the packet carrying it is lost.
+	// It can only be used after the transport parameters of the peer were received,
+	// i.e. for clients, once the handshake completed.
+	// It returns an error if the peer doesn't support DATAGRAM frames, or if the message is too large to fit into a single packet.
+	// If too many messages are queued for sending, it blocks until there's space in the send queue.
+	// Warning: This API should not be considered stable and might change soon.
+	SendMessage([]byte) error
+	// SendMessageWithCallback sends a message like SendMessage.
+	// The callback is called with acked set to true when the packet containing the message is acknowledged by the peer,
+	// and with acked set to false when it is declared lost. It is not called if the session is closed before that.
+	// The callback is called from the session's run loop, and must not block.
+	// Warning: This API should not be considered stable and might change soon.
+	SendMessageWithCallback(msg []byte, ackCallback func(acked bool)) error
+	// ReceiveMessage gets a message received in a DATAGRAM frame.
+	// It blocks until a message is received, or the session is closed.
+	// Warning: This API should not be considered stable and might change soon.
+	ReceiveMessage() ([]byte, error)
+}
+
+// ConnectionStats are statistics about a QUIC connection.
+type ConnectionStats struct {
+	// SmoothedRTT, MinRTT and LatestRTT are the current RTT estimates.
+	// They are zero as long as no RTT sample was taken.
+	SmoothedRTT time.Duration
+	MinRTT      time.Duration
+	LatestRTT   time.Duration
+
+	// CongestionWindow is the current congestion window, in bytes.
+	CongestionWindow uint64
+	// BytesInFlight is the number of bytes that were sent, but not yet acknowledged or declared lost.
+	BytesInFlight uint64
+
+	// BytesSent and PacketsSent count all QUIC packets sent, including retransmissions.
+	BytesSent   uint64
+	PacketsSent uint64
+	// BytesReceived and PacketsReceived count all QUIC packets received.
+	// Packets that couldn't be decrypted are not counted.
+	BytesReceived   uint64
+	PacketsReceived uint64
+
+	// PacketsLost is the number of packets that were declared lost.
+	PacketsLost uint64
+	// PacketsRetransmitted is the number of packets whose data was retransmitted.
+	PacketsRetransmitted uint64
+	// PTOCount is the number of times the probe timeout (PTO) fired.
+	PTOCount uint64
+
+	// OpenStreams and OpenUniStreams are the number of open bidirectional
+	// and unidirectional streams, opened by either peer.
+	OpenStreams    int
+	OpenUniStreams int
+
+	// FlowControlBlockedTime is the total time that sending was blocked
+	// by the connection-level flow control limit of the peer.
+	FlowControlBlockedTime time.Duration
 }
 
 // Config contains all configuration data needed for a QUIC server or client.
@@ -211,6 +295,41 @@ type Config struct {
 	StatelessResetKey []byte
 	// KeepAlive defines whether this peer will periodically send a packet to keep the connection alive.
 	KeepAlive bool
+	// KeyUpdateInterval is the number of packets that are sent or received with the same 1-RTT key,
+	// before a key update is initiated.
+	// If not set, it will default to 100,000 packets.
+	KeyUpdateInterval uint64
+	// CongestionControl creates the congestion control algorithm used for a connection.
+	// It is called once for every new connection.
+	// If not set, it will default to congestion.NewCubic.
+	CongestionControl func(congestion.RTTStats) congestion.SendAlgorithm
+	// EnableDatagrams enables support for the unreliable DATAGRAM frame extension.
+	// It is announced to the peer in the max_datagram_frame_size transport parameter.
+	// Messages can only be sent if the peer enabled the extension as well.
+	EnableDatagrams bool
+	// DisablePathMTUDiscovery disables Path MTU Discovery (DPLPMTUD).
+	// By default, quic-go probes if the path supports packets larger than the initial packet size
+	// (1252 bytes for IPv4, 1232 bytes for IPv6), up to the maximum packet size allowed by the peer.
+	// When enabled, the peer is allowed to send packets of up to 8952 bytes, so larger receive buffers are used.
+	DisablePathMTUDiscovery bool
+	// EnableBatchedIO enables reading and writing multiple packets with a single system call
+	// (using recvmmsg and sendmmsg), and UDP generic segmentation offload (GSO), if supported by the kernel.
+	// This is only supported on Linux, and only if the net.PacketConn is a *net.UDPConn.
+	// Otherwise, this option has no effect.
+	EnableBatchedIO bool
+	// EnableECN enables Explicit Congestion Notification (ECN).
+	// Outgoing packets are marked ECT(0), and the ECN codepoints of received packets are reported to the peer.
+	// This sets the IP_TOS / IPV6_TCLASS socket options, which affects all packets sent on the net.PacketConn.
+	// This is only supported on Linux. Otherwise, this option has no effect.
+	// ECN is only used if the congestion controller implements the congestion.ECNSendAlgorithm.
+	EnableECN bool
+	// Tracer is used to trace the events of every connection, e.g. to write a qlog.
+	// It is optional.
+	Tracer logging.Tracer
+	// Metrics collects metrics about the handshakes and the packets handled by this endpoint.
+	// It is optional. The metrics package contains an implementation that exposes them to Prometheus.
+	// All Listeners and clients sharing a net.PacketConn must use the same Metrics.
+	Metrics logging.Metrics
 }
 
 // A Listener for incoming QUIC connections
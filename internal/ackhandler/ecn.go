@@ -0,0 +1,112 @@
+package ackhandler
+
+import (
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+	"github.com/lucas-clemente/quic-go/internal/utils"
+	"github.com/lucas-clemente/quic-go/internal/wire"
+)
+
+type ecnState uint8
+
+const (
+	// ECN is not used, either because it is not supported on this platform, or because validation failed.
+	ecnStateDisabled ecnState = iota
+	// Packets are marked ECT(0), but we haven't yet received an ACK that confirms that the path supports ECN.
+	ecnStateTesting
+	// The peer correctly reported the ECN counts for our ECT(0) marked packets.
+	ecnStateCapable
+)
+
+// If this many ECT(0) marked packets are lost before one of them is acknowledged,
+// we assume that the path drops ECN marked packets.
+const numECNTestingPackets = 10
+
+// The ecnCounts are the ECN counts reported by the peer in an ACK frame.
+type ecnCounts struct {
+	ect0, ect1, ecnce uint64
+}
+
+// The ecnTracker performs ECN validation, see section 13.4.2 of the QUIC transport draft.
+type ecnTracker struct {
+	enabled bool
+	state   ecnState
+
+	// only counted while testing
+	numMarkedLost uint64
+
+	logger utils.Logger
+}
+
+func newECNTracker(enabled bool, logger utils.Logger) *ecnTracker {
+	t := &ecnTracker{enabled: enabled, logger: logger}
+	t.reset()
+	return t
+}
+
+// reset starts a new ECN validation.
+// It is called when the connection is migrated to a new path.
+func (t *ecnTracker) reset() {
+	t.numMarkedLost = 0
+	if t.enabled {
+		t.state = ecnStateTesting
+	} else {
+		t.state = ecnStateDisabled
+	}
+}
+
+// Mode returns the ECN codepoint that outgoing packets are marked with.
+func (t *ecnTracker) Mode() protocol.ECN {
+	if t.state == ecnStateDisabled {
+		return protocol.ECNNon
+	}
+	return protocol.ECT0
+}
+
+// LostPacket is called for every packet that is declared lost.
+func (t *ecnTracker) LostPacket(p *Packet) {
+	if t.state != ecnStateTesting || !p.ecnMarked {
+		return
+	}
+	t.numMarkedLost++
+	if t.numMarkedLost >= numECNTestingPackets {
+		t.fail("all ECN testing packets were lost")
+	}
+}
+
+// HandleNewlyAcked validates the ECN counts of an ACK frame that newly acknowledged packets.
+// counts are the counts reported by the last ACK frame in the same packet number space. They are updated.
+// numMarkedAcked is the number of ECT(0) marked packets newly acknowledged by this ACK frame.
+// It returns true if the ECN-CE count increased, i.e. if the peer reported congestion.
+func (t *ecnTracker) HandleNewlyAcked(counts *ecnCounts, ack *wire.AckFrame, numMarkedAcked uint64) bool {
+	if t.state == ecnStateDisabled {
+		return false
+	}
+	if ack.ECT0 < counts.ect0 || ack.ECT1 < counts.ect1 || ack.ECNCE < counts.ecnce {
+		t.fail("ECN counts decreased")
+		return false
+	}
+	// We never send ECT(1) marked packets.
+	if ack.ECT1 > counts.ect1 {
+		t.fail("peer reported ECT(1) marked packets")
+		return false
+	}
+	newECT0 := ack.ECT0 - counts.ect0
+	newECNCE := ack.ECNCE - counts.ecnce
+	// This also catches the case where the ACK frame doesn't contain any ECN counts,
+	// for example because ECN markings are cleared on the path.
+	if newECT0+newECNCE < numMarkedAcked {
+		t.fail("ECN counts don't account for all newly acknowledged ECT(0) marked packets")
+		return false
+	}
+	*counts = ecnCounts{ect0: ack.ECT0, ect1: ack.ECT1, ecnce: ack.ECNCE}
+	if t.state == ecnStateTesting && numMarkedAcked > 0 {
+		t.logger.Debugf("ECN validation succeeded.")
+		t.state = ecnStateCapable
+	}
+	return newECNCE > 0
+}
+
+func (t *ecnTracker) fail(reason string) {
+	t.logger.Debugf("ECN validation failed: %s. Disabling ECN.", reason)
+	t.state = ecnStateDisabled
+}
@@ -0,0 +1,100 @@
+package ackhandler
+
+import (
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+	"github.com/lucas-clemente/quic-go/internal/utils"
+	"github.com/lucas-clemente/quic-go/internal/wire"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ECN tracker", func() {
+	var (
+		tracker *ecnTracker
+		counts  *ecnCounts
+	)
+
+	BeforeEach(func() {
+		tracker = newECNTracker(true, utils.DefaultLogger)
+		counts = &ecnCounts{}
+	})
+
+	It("doesn't mark packets if ECN is disabled", func() {
+		tracker = newECNTracker(false, utils.DefaultLogger)
+		Expect(tracker.Mode()).To(Equal(protocol.ECNNon))
+		Expect(tracker.HandleNewlyAcked(counts, &wire.AckFrame{ECNCE: 10}, 10)).To(BeFalse())
+	})
+
+	It("marks packets ECT(0) while testing", func() {
+		Expect(tracker.state).To(Equal(ecnStateTesting))
+		Expect(tracker.Mode()).To(Equal(protocol.ECT0))
+	})
+
+	It("validates ECN when marked packets are acknowledged", func() {
+		Expect(tracker.HandleNewlyAcked(counts, &wire.AckFrame{ECT0: 3}, 3)).To(BeFalse())
+		Expect(tracker.state).To(Equal(ecnStateCapable))
+		Expect(tracker.Mode()).To(Equal(protocol.ECT0))
+		Expect(*counts).To(Equal(ecnCounts{ect0: 3}))
+	})
+
+	It("doesn't validate ECN if no marked packets were acknowledged", func() {
+		Expect(tracker.HandleNewlyAcked(counts, &wire.AckFrame{}, 0)).To(BeFalse())
+		Expect(tracker.state).To(Equal(ecnStateTesting))
+	})
+
+	It("reports ECN-CE marks", func() {
+		Expect(tracker.HandleNewlyAcked(counts, &wire.AckFrame{ECT0: 2, ECNCE: 1}, 3)).To(BeTrue())
+		Expect(tracker.state).To(Equal(ecnStateCapable))
+		Expect(tracker.HandleNewlyAcked(counts, &wire.AckFrame{ECT0: 4, ECNCE: 1}, 2)).To(BeFalse())
+		Expect(tracker.HandleNewlyAcked(counts, &wire.AckFrame{ECT0: 4, ECNCE: 3}, 2)).To(BeTrue())
+		Expect(*counts).To(Equal(ecnCounts{ect0: 4, ecnce: 3}))
+	})
+
+	It("fails validation if the ACK doesn't contain ECN counts", func() {
+		Expect(tracker.HandleNewlyAcked(counts, &wire.AckFrame{}, 2)).To(BeFalse())
+		Expect(tracker.Mode()).To(Equal(protocol.ECNNon))
+	})
+
+	It("fails validation if the counts don't account for all marked packets", func() {
+		Expect(tracker.HandleNewlyAcked(counts, &wire.AckFrame{ECT0: 1, ECNCE: 1}, 3)).To(BeFalse())
+		Expect(tracker.Mode()).To(Equal(protocol.ECNNon))
+	})
+
+	It("fails validation if the counts decrease", func() {
+		Expect(tracker.HandleNewlyAcked(counts, &wire.AckFrame{ECT0: 5}, 5)).To(BeFalse())
+		Expect(tracker.HandleNewlyAcked(counts, &wire.AckFrame{ECT0: 4, ECNCE: 2}, 1)).To(BeFalse())
+		Expect(tracker.Mode()).To(Equal(protocol.ECNNon))
+	})
+
+	It("fails validation if the peer reports ECT(1) marks", func() {
+		Expect(tracker.HandleNewlyAcked(counts, &wire.AckFrame{ECT0: 2, ECT1: 1}, 2)).To(BeFalse())
+		Expect(tracker.Mode()).To(Equal(protocol.ECNNon))
+	})
+
+	It("fails validation if all testing packets are lost", func() {
+		for i := 0; i < numECNTestingPackets-1; i++ {
+			tracker.LostPacket(&Packet{ecnMarked: true})
+			tracker.LostPacket(&Packet{}) // not marked
+		}
+		Expect(tracker.Mode()).To(Equal(protocol.ECT0))
+		tracker.LostPacket(&Packet{ecnMarked: true})
+		Expect(tracker.Mode()).To(Equal(protocol.ECNNon))
+	})
+
+	It("doesn't count lost packets once ECN was validated", func() {
+		Expect(tracker.HandleNewlyAcked(counts, &wire.AckFrame{ECT0: 1}, 1)).To(BeFalse())
+		for i := 0; i < 2*numECNTestingPackets; i++ {
+			tracker.LostPacket(&Packet{ecnMarked: true})
+		}
+		Expect(tracker.Mode()).To(Equal(protocol.ECT0))
+	})
+
+	It("restarts validation when reset", func() {
+		Expect(tracker.HandleNewlyAcked(counts, &wire.AckFrame{}, 1)).To(BeFalse())
+		Expect(tracker.Mode()).To(Equal(protocol.ECNNon))
+		tracker.reset()
+		Expect(tracker.state).To(Equal(ecnStateTesting))
+		Expect(tracker.Mode()).To(Equal(protocol.ECT0))
+	})
+})
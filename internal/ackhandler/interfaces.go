@@ -15,6 +15,13 @@ type SentPacketHandler interface {
 	ReceivedAck(ackFrame *wire.AckFrame, withPacketNumber protocol.PacketNumber, encLevel protocol.EncryptionLevel, recvTime time.Time) error
 	SetHandshakeComplete()
 	ResetForRetry() error
+	// OnConnectionMigration resets the congestion controller and the RTT estimate,
+	// and restarts ECN validation.
+	// It is called when the connection is migrated to a new path.
+	OnConnectionMigration()
+	// ECNMode returns the ECN codepoint that packets should be marked with.
+	// It returns protocol.ECNNon if ECN is disabled, or if ECN validation failed.
+	ECNMode() protocol.ECN
 
 	// The SendMode determines if and what kind of packets can be sent.
 	SendMode() SendMode
@@ -38,11 +45,26 @@ type SentPacketHandler interface {
 
 	GetAlarmTimeout() time.Time
 	OnAlarm() error
+
+	// GetStats returns statistics about the packets sent so far.
+	GetStats() Stats
+}
+
+// Stats are statistics about sent packets and loss recovery.
+type Stats struct {
+	CongestionWindow protocol.ByteCount
+	BytesInFlight    protocol.ByteCount
+	// PacketsLost is the number of packets that were declared lost.
+	PacketsLost uint64
+	// PacketsRetransmitted is the number of packets whose frames were queued for retransmission.
+	PacketsRetransmitted uint64
+	// PTOCount is the number of times the probe timeout fired.
+	PTOCount uint64
 }
 
 // ReceivedPacketHandler handles ACKs needed to send for incoming packets
 type ReceivedPacketHandler interface {
-	ReceivedPacket(pn protocol.PacketNumber, encLevel protocol.EncryptionLevel, rcvTime time.Time, shouldInstigateAck bool) error
+	ReceivedPacket(pn protocol.PacketNumber, ecn protocol.ECN, encLevel protocol.EncryptionLevel, rcvTime time.Time, shouldInstigateAck bool) error
 	IgnoreBelow(protocol.PacketNumber)
 
 	GetAlarmTimeout() time.Time
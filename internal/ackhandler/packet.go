@@ -16,7 +16,15 @@ type Packet struct {
 	EncryptionLevel protocol.EncryptionLevel
 	SendTime        time.Time
 
+	// AckCallback is called when the packet is acknowledged (acked is true), or declared lost (acked is false).
+	// It is only called once, and it is not called for the retransmissions of the packet. It is optional.
+	AckCallback func(acked bool)
+	// IsPathMTUProbePacket is set for packets sent to probe for a larger Path MTU.
+	// The loss of such a packet is not a sign of congestion, and its frames are not retransmitted.
+	IsPathMTUProbePacket bool
+
 	largestAcked protocol.PacketNumber // if the packet contains an ACK, the LargestAcked value of that ACK
+	ecnMarked    bool                  // was the packet sent with an ECT(0) marking
 
 	// There are two reasons why a packet cannot be retransmitted:
 	// * it was already retransmitted
@@ -56,17 +56,18 @@ func NewReceivedPacketHandler(
 
 func (h *receivedPacketHandler) ReceivedPacket(
 	pn protocol.PacketNumber,
+	ecn protocol.ECN,
 	encLevel protocol.EncryptionLevel,
 	rcvTime time.Time,
 	shouldInstigateAck bool,
 ) error {
 	switch encLevel {
 	case protocol.EncryptionInitial:
-		return h.initialPackets.ReceivedPacket(pn, rcvTime, shouldInstigateAck)
+		return h.initialPackets.ReceivedPacket(pn, ecn, rcvTime, shouldInstigateAck)
 	case protocol.EncryptionHandshake:
-		return h.handshakePackets.ReceivedPacket(pn, rcvTime, shouldInstigateAck)
+		return h.handshakePackets.ReceivedPacket(pn, ecn, rcvTime, shouldInstigateAck)
 	case protocol.Encryption1RTT:
-		return h.oneRTTPackets.ReceivedPacket(pn, rcvTime, shouldInstigateAck)
+		return h.oneRTTPackets.ReceivedPacket(pn, ecn, rcvTime, shouldInstigateAck)
 	default:
 		return fmt.Errorf("received packet with unknown encryption level: %s", encLevel)
 	}
@@ -25,6 +25,9 @@ type receivedPacketTracker struct {
 	ackAlarm                                time.Time
 	lastAck                                 *wire.AckFrame
 
+	// the number of packets received with the respective ECN codepoint
+	ect0, ect1, ecnce uint64
+
 	logger utils.Logger
 
 	version protocol.VersionNumber
@@ -44,7 +47,7 @@ func newReceivedPacketTracker(
 	}
 }
 
-func (h *receivedPacketTracker) ReceivedPacket(packetNumber protocol.PacketNumber, rcvTime time.Time, shouldInstigateAck bool) error {
+func (h *receivedPacketTracker) ReceivedPacket(packetNumber protocol.PacketNumber, ecn protocol.ECN, rcvTime time.Time, shouldInstigateAck bool) error {
 	if packetNumber < h.ignoreBelow {
 		return nil
 	}
@@ -58,7 +61,22 @@ func (h *receivedPacketTracker) ReceivedPacket(packetNumber protocol.PacketNumbe
 	if err := h.packetHistory.ReceivedPacket(packetNumber); err != nil {
 		return err
 	}
+	switch ecn {
+	case protocol.ECT0:
+		h.ect0++
+	case protocol.ECT1:
+		h.ect1++
+	case protocol.ECNCE:
+		h.ecnce++
+	}
 	h.maybeQueueAck(packetNumber, rcvTime, shouldInstigateAck, isMissing)
+	// Send an ACK immediately when a packet is received with the ECN-CE codepoint,
+	// so that the peer can react to the congestion as early as possible.
+	if ecn == protocol.ECNCE && !h.ackQueued {
+		h.logger.Debugf("\tQueueing ACK because packet %#x was marked ECN-CE.", packetNumber)
+		h.ackQueued = true
+		h.ackAlarm = time.Time{}
+	}
 	return nil
 }
 
@@ -178,6 +196,9 @@ func (h *receivedPacketTracker) GetAckFrame() *wire.AckFrame {
 	ack := &wire.AckFrame{
 		AckRanges: h.packetHistory.GetAckRanges(),
 		DelayTime: now.Sub(h.largestObservedReceivedTime),
+		ECT0:      h.ect0,
+		ECT1:      h.ect1,
+		ECNCE:     h.ecnce,
 	}
 
 	h.lastAck = ack
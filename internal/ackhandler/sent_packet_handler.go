@@ -11,6 +11,7 @@ import (
 	"github.com/lucas-clemente/quic-go/internal/qerr"
 	"github.com/lucas-clemente/quic-go/internal/utils"
 	"github.com/lucas-clemente/quic-go/internal/wire"
+	"github.com/lucas-clemente/quic-go/logging"
 )
 
 const (
@@ -27,6 +28,9 @@ type packetNumberSpace struct {
 
 	largestAcked protocol.PacketNumber
 	largestSent  protocol.PacketNumber
+
+	// the ECN counts reported by the peer in the last ACK frame
+	ecnCounts ecnCounts
 }
 
 func newPacketNumberSpace(initialPN protocol.PacketNumber) *packetNumberSpace {
@@ -58,6 +62,10 @@ type sentPacketHandler struct {
 
 	congestion congestion.SendAlgorithm
 	rttStats   *congestion.RTTStats
+	// the congestion state that was last reported to the tracer
+	congestionState logging.CongestionState
+
+	ecn *ecnTracker
 
 	handshakeComplete bool
 
@@ -69,12 +77,18 @@ type sentPacketHandler struct {
 	// Only applies to the application-data packet number space.
 	numProbesToSend int
 
+	// statistics, reported by GetStats
+	numPacketsLost          uint64
+	numPacketsRetransmitted uint64
+	numPTOs                 uint64
+
 	// The time at which the next packet will be considered lost based on early transmit or exceeding the reordering window in time.
 	lossTime time.Time
 
 	// The alarm timeout
 	alarm time.Time
 
+	tracer logging.ConnectionTracer
 	logger utils.Logger
 }
 
@@ -82,22 +96,20 @@ type sentPacketHandler struct {
 func NewSentPacketHandler(
 	initialPacketNumber protocol.PacketNumber,
 	rttStats *congestion.RTTStats,
+	congestionControl congestion.SendAlgorithm,
+	enableECN bool,
+	tracer logging.ConnectionTracer,
 	logger utils.Logger,
 ) SentPacketHandler {
-	congestion := congestion.NewCubicSender(
-		congestion.DefaultClock{},
-		rttStats,
-		false, /* don't use reno since chromium doesn't (why?) */
-		protocol.InitialCongestionWindow,
-		protocol.DefaultMaxCongestionWindow,
-	)
-
 	return &sentPacketHandler{
 		initialPackets:   newPacketNumberSpace(initialPacketNumber),
 		handshakePackets: newPacketNumberSpace(0),
 		oneRTTPackets:    newPacketNumberSpace(0),
 		rttStats:         rttStats,
-		congestion:       congestion,
+		congestion:       congestionControl,
+		congestionState:  logging.CongestionStateSlowStart,
+		ecn:              newECNTracker(enableECN && congestion.SupportsECN(congestionControl), logger),
+		tracer:           tracer,
 		logger:           logger,
 	}
 }
@@ -165,6 +177,7 @@ func (h *sentPacketHandler) sentPacketImpl(packet *Packet) bool /* is ack-elicit
 	}
 
 	pnSpace.largestSent = packet.PacketNumber
+	packet.ecnMarked = h.ecn.Mode() == protocol.ECT0
 
 	if len(packet.Frames) > 0 {
 		if ackFrame, ok := packet.Frames[0].(*wire.AckFrame); ok {
@@ -213,7 +226,9 @@ func (h *sentPacketHandler) ReceivedAck(ackFrame *wire.AckFrame, withPacketNumbe
 		if h.logger.Debug() {
 			h.logger.Debugf("\tupdated RTT: %s (σ: %s)", h.rttStats.SmoothedRTT(), h.rttStats.MeanDeviation())
 		}
-		h.congestion.MaybeExitSlowStart()
+		if c, ok := h.congestion.(congestion.ExtendedSendAlgorithm); ok {
+			c.MaybeExitSlowStart()
+		}
 	}
 
 	ackedPackets, err := h.determineNewlyAckedPackets(ackFrame, encLevel)
@@ -225,7 +240,11 @@ func (h *sentPacketHandler) ReceivedAck(ackFrame *wire.AckFrame, withPacketNumbe
 	}
 
 	priorInFlight := h.bytesInFlight
+	var numECNMarkedAcked uint64
 	for _, p := range ackedPackets {
+		if p.ecnMarked {
+			numECNMarkedAcked++
+		}
 		// largestAcked == 0 either means that the packet didn't contain an ACK, or it just acked packet 0
 		// It is safe to ignore the corner case of packets that just acked packet 0, because
 		// the lowestPacketNotConfirmedAcked is only used to limit the number of ACK ranges we will send.
@@ -240,10 +259,20 @@ func (h *sentPacketHandler) ReceivedAck(ackFrame *wire.AckFrame, withPacketNumbe
 		}
 	}
 
+	if h.ecn.HandleNewlyAcked(&pnSpace.ecnCounts, ackFrame, numECNMarkedAcked) {
+		h.onECNCongestionEvent(largestAcked, priorInFlight)
+	}
+
 	if err := h.detectLostPackets(rcvTime, encLevel, priorInFlight); err != nil {
 		return err
 	}
 
+	if h.tracer != nil {
+		if h.ptoCount != 0 {
+			h.tracer.UpdatedPTOCount(0)
+		}
+		h.traceMetrics()
+	}
 	h.ptoCount = 0
 	h.cryptoCount = 0
 	h.numProbesToSend = 0
@@ -252,6 +281,13 @@ func (h *sentPacketHandler) ReceivedAck(ackFrame *wire.AckFrame, withPacketNumbe
 	return nil
 }
 
+func (h *sentPacketHandler) onECNCongestionEvent(largestAcked protocol.PacketNumber, priorInFlight protocol.ByteCount) {
+	h.logger.Debugf("\tpeer reported ECN-CE marks")
+	if c, ok := h.congestion.(congestion.ECNSendAlgorithm); ok {
+		c.OnCongestionEvent(largestAcked, priorInFlight)
+	}
+}
+
 func (h *sentPacketHandler) GetLowestPacketNotConfirmedAcked() protocol.PacketNumber {
 	return h.lowestNotConfirmedAcked
 }
@@ -372,13 +408,24 @@ func (h *sentPacketHandler) detectLostPackets(
 		h.logger.Debugf("\tlost packets (%d): %#x", len(pns), pns)
 	}
 
+	h.numPacketsLost += uint64(len(lostPackets))
 	for _, p := range lostPackets {
+		if h.tracer != nil {
+			h.tracer.LostPacket(p.EncryptionLevel, p.PacketNumber, logging.PacketLossTimeThreshold)
+		}
 		// the bytes in flight need to be reduced no matter if this packet will be retransmitted
 		if p.includedInBytesInFlight {
 			h.bytesInFlight -= p.Length
-			h.congestion.OnPacketLost(p.PacketNumber, p.Length, priorInFlight)
+			// Path MTU probe packets are expected to be lost if the path doesn't support their size.
+			if !p.IsPathMTUProbePacket {
+				h.congestion.OnPacketLost(p.PacketNumber, p.Length, priorInFlight)
+			}
 		}
-		if p.canBeRetransmitted {
+		h.ecn.LostPacket(p)
+		if p.AckCallback != nil {
+			p.AckCallback(false)
+		}
+		if p.canBeRetransmitted && !p.IsPathMTUProbePacket {
 			// queue the packet for retransmission, and report the loss to the congestion controller
 			if err := h.queuePacketForRetransmission(p, pnSpace); err != nil {
 				return err
@@ -417,20 +464,60 @@ func (h *sentPacketHandler) onVerifiedAlarm() error {
 		}
 		// Early retransmit or time loss detection
 		err = h.detectLostPackets(time.Now(), protocol.Encryption1RTT, h.bytesInFlight)
+		if h.tracer != nil {
+			h.traceMetrics()
+		}
 	} else { // PTO
 		if h.logger.Debug() {
 			h.logger.Debugf("Loss detection alarm fired in PTO mode. PTO count: %d", h.ptoCount)
 		}
 		h.ptoCount++
+		h.numPTOs++
 		h.numProbesToSend += 2
+		if h.tracer != nil {
+			h.tracer.UpdatedPTOCount(h.ptoCount)
+		}
 	}
 	return err
 }
 
+// traceMetrics passes the RTT estimate and the state of the congestion controller to the tracer.
+func (h *sentPacketHandler) traceMetrics() {
+	h.tracer.UpdatedMetrics(h.rttStats, h.congestion.GetCongestionWindow(), h.bytesInFlight, h.packetsInFlight())
+	c, ok := h.congestion.(congestion.StatefulSendAlgorithm)
+	if !ok {
+		return
+	}
+	state := logging.CongestionStateCongestionAvoidance
+	if c.InRecovery() {
+		state = logging.CongestionStateRecovery
+	} else if c.InSlowStart() {
+		state = logging.CongestionStateSlowStart
+	}
+	if state != h.congestionState {
+		h.congestionState = state
+		h.tracer.UpdatedCongestionState(state)
+	}
+}
+
+func (h *sentPacketHandler) packetsInFlight() int {
+	return h.initialPackets.history.Len() + h.handshakePackets.history.Len() + h.oneRTTPackets.history.Len()
+}
+
 func (h *sentPacketHandler) GetAlarmTimeout() time.Time {
 	return h.alarm
 }
 
+func (h *sentPacketHandler) GetStats() Stats {
+	return Stats{
+		CongestionWindow:     h.congestion.GetCongestionWindow(),
+		BytesInFlight:        h.bytesInFlight,
+		PacketsLost:          h.numPacketsLost,
+		PacketsRetransmitted: h.numPacketsRetransmitted,
+		PTOCount:             h.numPTOs,
+	}
+}
+
 func (h *sentPacketHandler) onPacketAcked(p *Packet, rcvTime time.Time) error {
 	pnSpace := h.getPacketNumberSpace(p.EncryptionLevel)
 	// This happens if a packet and its retransmissions is acked in the same ACK.
@@ -464,6 +551,9 @@ func (h *sentPacketHandler) onPacketAcked(p *Packet, rcvTime time.Time) error {
 	if p.includedInBytesInFlight {
 		h.bytesInFlight -= p.Length
 	}
+	if p.AckCallback != nil {
+		p.AckCallback(true)
+	}
 	if err := h.stopRetransmissionsFor(p, pnSpace); err != nil {
 		return err
 	}
@@ -614,6 +704,7 @@ func (h *sentPacketHandler) queuePacketForRetransmission(p *Packet, pnSpace *pac
 		return err
 	}
 	h.retransmissionQueue = append(h.retransmissionQueue, p)
+	h.numPacketsRetransmitted++
 	return nil
 }
 
@@ -630,6 +721,19 @@ func (h *sentPacketHandler) computePTOTimeout() time.Duration {
 	return duration << h.ptoCount
 }
 
+func (h *sentPacketHandler) OnConnectionMigration() {
+	h.logger.Debugf("Connection migrated. Resetting the congestion controller and the RTT estimate.")
+	if c, ok := h.congestion.(congestion.MigrationAwareSendAlgorithm); ok {
+		c.OnConnectionMigration()
+	}
+	h.rttStats.OnConnectionMigration()
+	h.ecn.reset()
+}
+
+func (h *sentPacketHandler) ECNMode() protocol.ECN {
+	return h.ecn.Mode()
+}
+
 func (h *sentPacketHandler) ResetForRetry() error {
 	h.cryptoCount = 0
 	h.bytesInFlight = 0
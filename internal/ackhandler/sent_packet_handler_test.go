@@ -6,9 +6,11 @@ import (
 	"github.com/golang/mock/gomock"
 	"github.com/lucas-clemente/quic-go/internal/congestion"
 	"github.com/lucas-clemente/quic-go/internal/mocks"
+	mocklogging "github.com/lucas-clemente/quic-go/internal/mocks/logging"
 	"github.com/lucas-clemente/quic-go/internal/protocol"
 	"github.com/lucas-clemente/quic-go/internal/utils"
 	"github.com/lucas-clemente/quic-go/internal/wire"
+	"github.com/lucas-clemente/quic-go/logging"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 )
@@ -41,6 +43,18 @@ func cryptoPacket(p *Packet) *Packet {
 	return p
 }
 
+type mockECNSendAlgorithm struct {
+	*mocks.MockExtendedSendAlgorithm
+
+	congestionEvents []protocol.PacketNumber
+	priorInFlight    protocol.ByteCount
+}
+
+func (a *mockECNSendAlgorithm) OnCongestionEvent(largestAcked protocol.PacketNumber, priorInFlight protocol.ByteCount) {
+	a.congestionEvents = append(a.congestionEvents, largestAcked)
+	a.priorInFlight = priorInFlight
+}
+
 var _ = Describe("SentPacketHandler", func() {
 	var (
 		handler     *sentPacketHandler
@@ -49,7 +63,14 @@ var _ = Describe("SentPacketHandler", func() {
 
 	BeforeEach(func() {
 		rttStats := &congestion.RTTStats{}
-		handler = NewSentPacketHandler(42, rttStats, utils.DefaultLogger).(*sentPacketHandler)
+		cong := congestion.NewCubicSender(
+			congestion.DefaultClock{},
+			rttStats,
+			false,
+			protocol.InitialCongestionWindow,
+			protocol.DefaultMaxCongestionWindow,
+		)
+		handler = NewSentPacketHandler(42, rttStats, cong, false, nil, utils.DefaultLogger).(*sentPacketHandler)
 		handler.SetHandshakeComplete()
 		streamFrame = wire.StreamFrame{
 			StreamID: 5,
@@ -398,10 +419,10 @@ var _ = Describe("SentPacketHandler", func() {
 	})
 
 	Context("congestion", func() {
-		var cong *mocks.MockSendAlgorithm
+		var cong *mocks.MockExtendedSendAlgorithm
 
 		BeforeEach(func() {
-			cong = mocks.NewMockSendAlgorithm(mockCtrl)
+			cong = mocks.NewMockExtendedSendAlgorithm(mockCtrl)
 			handler.congestion = cong
 		})
 
@@ -486,6 +507,30 @@ var _ = Describe("SentPacketHandler", func() {
 			Expect(err).ToNot(HaveOccurred())
 		})
 
+		It("doesn't call OnPacketLost when a Path MTU probe packet is lost", func() {
+			cong.EXPECT().OnPacketSent(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(2)
+			cong.EXPECT().TimeUntilSend(gomock.Any()).Times(2)
+			var acked []bool
+			handler.SentPacket(ackElicitingPacket(&Packet{
+				PacketNumber:         1,
+				SendTime:             time.Now().Add(-time.Hour),
+				IsPathMTUProbePacket: true,
+				AckCallback:          func(a bool) { acked = append(acked, a) },
+			}))
+			handler.SentPacket(ackElicitingPacket(&Packet{PacketNumber: 2}))
+			// lose packet 1, but don't EXPECT a call to OnPacketLost
+			gomock.InOrder(
+				cong.EXPECT().MaybeExitSlowStart(),
+				cong.EXPECT().OnPacketAcked(protocol.PacketNumber(2), protocol.ByteCount(1), protocol.ByteCount(2), gomock.Any()),
+			)
+			ack := &wire.AckFrame{AckRanges: []wire.AckRange{{Smallest: 2, Largest: 2}}}
+			Expect(handler.ReceivedAck(ack, 1, protocol.Encryption1RTT, time.Now())).To(Succeed())
+			Expect(acked).To(Equal([]bool{false}))
+			Expect(handler.bytesInFlight).To(BeZero())
+			// the probe packet is not retransmitted
+			Expect(handler.DequeuePacketForRetransmission()).To(BeNil())
+		})
+
 		It("only allows sending of ACKs when congestion limited", func() {
 			handler.bytesInFlight = 100
 			cong.EXPECT().GetCongestionWindow().Return(protocol.ByteCount(200))
@@ -566,6 +611,27 @@ var _ = Describe("SentPacketHandler", func() {
 			cong.EXPECT().TimeUntilSend(gomock.Any()).Return(pacingDelay)
 			Expect(handler.ShouldSendNumPackets()).To(Equal(3))
 		})
+
+		It("resets the congestion controller and the RTT on connection migration", func() {
+			updateRTT(time.Hour)
+			cong.EXPECT().OnConnectionMigration()
+			handler.OnConnectionMigration()
+			Expect(handler.rttStats.SmoothedRTT()).To(BeZero())
+			Expect(handler.rttStats.MinRTT()).To(BeZero())
+		})
+
+		It("works with congestion controllers that only implement the SendAlgorithm interface", func() {
+			// hide the methods of the ExtendedSendAlgorithm
+			handler.congestion = struct{ congestion.SendAlgorithm }{cong}
+			cong.EXPECT().OnPacketSent(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any())
+			cong.EXPECT().TimeUntilSend(gomock.Any())
+			handler.SentPacket(ackElicitingPacket(&Packet{PacketNumber: 1}))
+			cong.EXPECT().OnPacketAcked(protocol.PacketNumber(1), gomock.Any(), gomock.Any(), gomock.Any())
+			ack := &wire.AckFrame{AckRanges: []wire.AckRange{{Smallest: 1, Largest: 1}}}
+			Expect(handler.ReceivedAck(ack, 1, protocol.Encryption1RTT, time.Now())).To(Succeed())
+			handler.OnConnectionMigration()
+			Expect(handler.rttStats.SmoothedRTT()).To(BeZero())
+		})
 	})
 
 	It("doesn't set an alarm if there are no outstanding packets", func() {
@@ -582,6 +648,90 @@ var _ = Describe("SentPacketHandler", func() {
 		Expect(handler.SendMode()).To(Equal(SendAny))
 	})
 
+	Context("ECN", func() {
+		var cong *mocks.MockExtendedSendAlgorithm
+
+		BeforeEach(func() {
+			cong = mocks.NewMockExtendedSendAlgorithm(mockCtrl)
+			handler.congestion = cong
+			handler.ecn = newECNTracker(true, utils.DefaultLogger)
+			cong.EXPECT().OnPacketSent(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).AnyTimes()
+			cong.EXPECT().TimeUntilSend(gomock.Any()).AnyTimes()
+			cong.EXPECT().OnPacketAcked(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).AnyTimes()
+			cong.EXPECT().MaybeExitSlowStart().AnyTimes()
+		})
+
+		It("marks packets ECT(0)", func() {
+			Expect(handler.ECNMode()).To(Equal(protocol.ECT0))
+			p := ackElicitingPacket(&Packet{PacketNumber: 1})
+			handler.SentPacket(p)
+			Expect(p.ecnMarked).To(BeTrue())
+		})
+
+		It("doesn't mark packets if ECN is disabled", func() {
+			handler.ecn = newECNTracker(false, utils.DefaultLogger)
+			Expect(handler.ECNMode()).To(Equal(protocol.ECNNon))
+			p := ackElicitingPacket(&Packet{PacketNumber: 1})
+			handler.SentPacket(p)
+			Expect(p.ecnMarked).To(BeFalse())
+		})
+
+		It("disables ECN if the peer doesn't report ECN counts", func() {
+			handler.SentPacket(ackElicitingPacket(&Packet{PacketNumber: 1}))
+			handler.SentPacket(ackElicitingPacket(&Packet{PacketNumber: 2}))
+			ack := &wire.AckFrame{AckRanges: []wire.AckRange{{Smallest: 1, Largest: 2}}}
+			Expect(handler.ReceivedAck(ack, 1, protocol.Encryption1RTT, time.Now())).To(Succeed())
+			Expect(handler.ECNMode()).To(Equal(protocol.ECNNon))
+			p := ackElicitingPacket(&Packet{PacketNumber: 3})
+			handler.SentPacket(p)
+			Expect(p.ecnMarked).To(BeFalse())
+		})
+
+		It("tracks the ECN counts per packet number space", func() {
+			handler.SentPacket(ackElicitingPacket(&Packet{PacketNumber: 1, EncryptionLevel: protocol.EncryptionHandshake}))
+			handler.SentPacket(ackElicitingPacket(&Packet{PacketNumber: 1}))
+			ack := &wire.AckFrame{AckRanges: []wire.AckRange{{Smallest: 1, Largest: 1}}, ECT0: 1}
+			Expect(handler.ReceivedAck(ack, 1, protocol.EncryptionHandshake, time.Now())).To(Succeed())
+			Expect(handler.ReceivedAck(ack, 1, protocol.Encryption1RTT, time.Now())).To(Succeed())
+			Expect(handler.ECNMode()).To(Equal(protocol.ECT0))
+			Expect(handler.handshakePackets.ecnCounts).To(Equal(ecnCounts{ect0: 1}))
+			Expect(handler.oneRTTPackets.ecnCounts).To(Equal(ecnCounts{ect0: 1}))
+		})
+
+		It("reports ECN-CE marks to the congestion controller", func() {
+			c := &mockECNSendAlgorithm{MockExtendedSendAlgorithm: cong}
+			handler.congestion = c
+			handler.SentPacket(ackElicitingPacket(&Packet{PacketNumber: 1, Length: 100}))
+			handler.SentPacket(ackElicitingPacket(&Packet{PacketNumber: 2, Length: 100}))
+			ack := &wire.AckFrame{AckRanges: []wire.AckRange{{Smallest: 1, Largest: 2}}, ECT0: 1, ECNCE: 1}
+			Expect(handler.ReceivedAck(ack, 1, protocol.Encryption1RTT, time.Now())).To(Succeed())
+			Expect(c.congestionEvents).To(Equal([]protocol.PacketNumber{2}))
+			Expect(c.priorInFlight).To(Equal(protocol.ByteCount(200)))
+			Expect(handler.ECNMode()).To(Equal(protocol.ECT0))
+		})
+
+		It("uses ECN if the congestion controller handles ECN-CE marks", func() {
+			c := &mockECNSendAlgorithm{MockExtendedSendAlgorithm: cong}
+			h := NewSentPacketHandler(0, &congestion.RTTStats{}, c, true, nil, utils.DefaultLogger)
+			Expect(h.ECNMode()).To(Equal(protocol.ECT0))
+		})
+
+		It("doesn't use ECN if the congestion controller doesn't handle ECN-CE marks", func() {
+			h := NewSentPacketHandler(0, &congestion.RTTStats{}, cong, true, nil, utils.DefaultLogger)
+			Expect(h.ECNMode()).To(Equal(protocol.ECNNon))
+		})
+
+		It("restarts ECN validation on connection migration", func() {
+			handler.SentPacket(ackElicitingPacket(&Packet{PacketNumber: 1}))
+			ack := &wire.AckFrame{AckRanges: []wire.AckRange{{Smallest: 1, Largest: 1}}}
+			Expect(handler.ReceivedAck(ack, 1, protocol.Encryption1RTT, time.Now())).To(Succeed())
+			Expect(handler.ECNMode()).To(Equal(protocol.ECNNon))
+			cong.EXPECT().OnConnectionMigration()
+			handler.OnConnectionMigration()
+			Expect(handler.ECNMode()).To(Equal(protocol.ECT0))
+		})
+	})
+
 	Context("probe packets", func() {
 		It("uses the RTT from RTT stats", func() {
 			rtt := 2 * time.Second
@@ -739,6 +889,83 @@ var _ = Describe("SentPacketHandler", func() {
 		})
 	})
 
+	Context("statistics", func() {
+		It("reports the congestion window and the bytes in flight", func() {
+			handler.SentPacket(ackElicitingPacket(&Packet{PacketNumber: 1, Length: 42}))
+			stats := handler.GetStats()
+			Expect(stats.CongestionWindow).To(Equal(handler.congestion.GetCongestionWindow()))
+			Expect(stats.BytesInFlight).To(Equal(protocol.ByteCount(42)))
+		})
+
+		It("counts lost and retransmitted packets", func() {
+			now := time.Now()
+			handler.SentPacket(ackElicitingPacket(&Packet{PacketNumber: 1, SendTime: now.Add(-time.Hour)}))
+			handler.SentPacket(ackElicitingPacket(&Packet{PacketNumber: 2, SendTime: now.Add(-time.Hour)}))
+			handler.SentPacket(ackElicitingPacket(&Packet{PacketNumber: 3, SendTime: now.Add(-time.Second)}))
+			ack := &wire.AckFrame{AckRanges: []wire.AckRange{{Smallest: 3, Largest: 3}}}
+			Expect(handler.ReceivedAck(ack, 1, protocol.Encryption1RTT, now)).To(Succeed())
+			stats := handler.GetStats()
+			Expect(stats.PacketsLost).To(BeEquivalentTo(2))
+			Expect(stats.PacketsRetransmitted).To(BeEquivalentTo(2))
+		})
+
+		It("counts PTOs", func() {
+			handler.SentPacket(ackElicitingPacket(&Packet{PacketNumber: 1, SendTime: time.Now().Add(-time.Hour)}))
+			Expect(handler.OnAlarm()).To(Succeed())
+			Expect(handler.OnAlarm()).To(Succeed())
+			Expect(handler.GetStats().PTOCount).To(BeEquivalentTo(2))
+			// the PTO count is reset when an ACK is received, but the statistics are not
+			ack := &wire.AckFrame{AckRanges: []wire.AckRange{{Smallest: 1, Largest: 1}}}
+			Expect(handler.ReceivedAck(ack, 1, protocol.Encryption1RTT, time.Now())).To(Succeed())
+			Expect(handler.ptoCount).To(BeZero())
+			Expect(handler.GetStats().PTOCount).To(BeEquivalentTo(2))
+		})
+	})
+
+	Context("tracing", func() {
+		var tracer *mocklogging.MockConnectionTracer
+
+		BeforeEach(func() {
+			tracer = mocklogging.NewMockConnectionTracer(mockCtrl)
+			handler.tracer = tracer
+		})
+
+		It("traces the metrics when an ACK is received", func() {
+			handler.SentPacket(ackElicitingPacket(&Packet{PacketNumber: 1}))
+			handler.SentPacket(ackElicitingPacket(&Packet{PacketNumber: 2}))
+			tracer.EXPECT().UpdatedMetrics(handler.rttStats, gomock.Any(), protocol.ByteCount(1), 1)
+			ack := &wire.AckFrame{AckRanges: []wire.AckRange{{Smallest: 1, Largest: 1}}}
+			Expect(handler.ReceivedAck(ack, 1, protocol.Encryption1RTT, time.Now())).To(Succeed())
+		})
+
+		It("traces lost packets and the transition to recovery", func() {
+			now := time.Now()
+			handler.SentPacket(ackElicitingPacket(&Packet{PacketNumber: 1, SendTime: now.Add(-time.Hour)}))
+			handler.SentPacket(ackElicitingPacket(&Packet{PacketNumber: 2, SendTime: now.Add(-time.Second)}))
+			gomock.InOrder(
+				tracer.EXPECT().LostPacket(protocol.Encryption1RTT, protocol.PacketNumber(1), logging.PacketLossTimeThreshold),
+				tracer.EXPECT().UpdatedMetrics(handler.rttStats, gomock.Any(), protocol.ByteCount(0), 0),
+				tracer.EXPECT().UpdatedCongestionState(logging.CongestionStateRecovery),
+			)
+			ack := &wire.AckFrame{AckRanges: []wire.AckRange{{Smallest: 2, Largest: 2}}}
+			Expect(handler.ReceivedAck(ack, 1, protocol.Encryption1RTT, now)).To(Succeed())
+		})
+
+		It("traces the PTO count", func() {
+			handler.SentPacket(ackElicitingPacket(&Packet{PacketNumber: 1, SendTime: time.Now().Add(-time.Hour)}))
+			tracer.EXPECT().UpdatedPTOCount(uint32(1))
+			Expect(handler.OnAlarm()).To(Succeed())
+			tracer.EXPECT().UpdatedPTOCount(uint32(2))
+			Expect(handler.OnAlarm()).To(Succeed())
+			gomock.InOrder(
+				tracer.EXPECT().UpdatedPTOCount(uint32(0)),
+				tracer.EXPECT().UpdatedMetrics(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()),
+			)
+			ack := &wire.AckFrame{AckRanges: []wire.AckRange{{Smallest: 1, Largest: 1}}}
+			Expect(handler.ReceivedAck(ack, 1, protocol.Encryption1RTT, time.Now())).To(Succeed())
+		})
+	})
+
 	Context("Delay-based loss detection", func() {
 		It("immediately detects old packets as lost when receiving an ACK", func() {
 			now := time.Now()
@@ -778,6 +1005,49 @@ var _ = Describe("SentPacketHandler", func() {
 		})
 	})
 
+	Context("ack callbacks", func() {
+		It("calls the callback when a packet is acknowledged", func() {
+			var calls []bool
+			handler.SentPacket(ackElicitingPacket(&Packet{
+				PacketNumber: 1,
+				AckCallback:  func(acked bool) { calls = append(calls, acked) },
+			}))
+			ack := &wire.AckFrame{AckRanges: []wire.AckRange{{Smallest: 1, Largest: 1}}}
+			Expect(handler.ReceivedAck(ack, 1, protocol.Encryption1RTT, time.Now())).To(Succeed())
+			Expect(calls).To(Equal([]bool{true}))
+			// receiving a duplicate ACK doesn't call the callback again
+			Expect(handler.ReceivedAck(ack, 2, protocol.Encryption1RTT, time.Now())).To(Succeed())
+			Expect(calls).To(HaveLen(1))
+		})
+
+		It("calls the callback when a packet is declared lost", func() {
+			now := time.Now()
+			var calls []bool
+			handler.SentPacket(ackElicitingPacket(&Packet{
+				PacketNumber: 1,
+				SendTime:     now.Add(-time.Hour),
+				AckCallback:  func(acked bool) { calls = append(calls, acked) },
+			}))
+			handler.SentPacket(ackElicitingPacket(&Packet{PacketNumber: 2, SendTime: now.Add(-time.Second)}))
+			ack := &wire.AckFrame{AckRanges: []wire.AckRange{{Smallest: 2, Largest: 2}}}
+			Expect(handler.ReceivedAck(ack, 1, protocol.Encryption1RTT, now)).To(Succeed())
+			Expect(calls).To(Equal([]bool{false}))
+		})
+
+		It("doesn't call the callback when a retransmission of the packet is acknowledged", func() {
+			var calls []bool
+			handler.SentPacket(ackElicitingPacket(&Packet{
+				PacketNumber: 1,
+				AckCallback:  func(acked bool) { calls = append(calls, acked) },
+			}))
+			losePacket(1, protocol.Encryption1RTT)
+			handler.SentPacketsAsRetransmission([]*Packet{ackElicitingPacket(&Packet{PacketNumber: 2})}, 1)
+			ack := &wire.AckFrame{AckRanges: []wire.AckRange{{Smallest: 2, Largest: 2}}}
+			Expect(handler.ReceivedAck(ack, 1, protocol.Encryption1RTT, time.Now())).To(Succeed())
+			Expect(calls).To(BeEmpty())
+		})
+	})
+
 	Context("crypto packets", func() {
 		BeforeEach(func() {
 			handler.handshakeComplete = false
@@ -0,0 +1,149 @@
+package congestion
+
+import (
+	"time"
+
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+)
+
+// A bandwidthSample is a bandwidth estimate, taken when a packet is acknowledged.
+type bandwidthSample struct {
+	// The bandwidth at the time the packet was sent.
+	bandwidth Bandwidth
+	// The RTT of the packet.
+	rtt time.Duration
+	// Set if the sample was taken while the sender was application limited.
+	// The bandwidth of such samples underestimates the bandwidth of the path.
+	isAppLimited bool
+}
+
+// The state of the connection at the time a packet was sent.
+type sendTimeState struct {
+	sentTime time.Time
+	size     protocol.ByteCount
+
+	totalBytesSent                  protocol.ByteCount
+	totalBytesSentAtLastAckedPacket protocol.ByteCount
+	lastAckedPacketSentTime         time.Time
+	totalBytesAcked                 protocol.ByteCount
+	lastAckedPacketAckTime          time.Time
+	isAppLimited                    bool
+}
+
+// A bandwidthSampler estimates the delivery rate of a connection.
+// For every packet that is acknowledged, it computes the rate at which data was sent
+// and the rate at which data was acknowledged in the interval between the acknowledgement
+// of the last packet acknowledged before the packet was sent and the acknowledgement of the packet.
+// The minimum of these two rates is the bandwidth sample.
+type bandwidthSampler struct {
+	totalBytesSent  protocol.ByteCount
+	totalBytesAcked protocol.ByteCount
+
+	// The total number of bytes sent at the time the last acknowledged packet was sent.
+	totalBytesSentAtLastAckedPacket protocol.ByteCount
+	// The send time of the last acknowledged packet.
+	lastAckedPacketSentTime time.Time
+	// The time the last packet was acknowledged.
+	lastAckedPacketAckTime time.Time
+
+	lastSentPacket protocol.PacketNumber
+
+	// Set when the sender is application limited.
+	// It is reset once a packet sent after that point is acknowledged.
+	isAppLimited         bool
+	endOfAppLimitedPhase protocol.PacketNumber
+
+	packets map[protocol.PacketNumber]*sendTimeState
+}
+
+func newBandwidthSampler() *bandwidthSampler {
+	return &bandwidthSampler{packets: make(map[protocol.PacketNumber]*sendTimeState)}
+}
+
+// OnPacketSent is called for every ack-eliciting packet that is sent.
+// bytesInFlight is the number of bytes in flight, including this packet.
+func (s *bandwidthSampler) OnPacketSent(sentTime time.Time, pn protocol.PacketNumber, bytes, bytesInFlight protocol.ByteCount) {
+	s.lastSentPacket = pn
+	s.totalBytesSent += bytes
+
+	// If there are no packets in flight, the time between the last acknowledgement and the sending
+	// of this packet would be counted as transmission time, which would underestimate the bandwidth.
+	// Pretend that the last packet was acknowledged just now.
+	if bytesInFlight <= bytes {
+		s.lastAckedPacketAckTime = sentTime
+		s.totalBytesSentAtLastAckedPacket = s.totalBytesSent
+		s.lastAckedPacketSentTime = sentTime
+	}
+
+	s.packets[pn] = &sendTimeState{
+		sentTime:                        sentTime,
+		size:                            bytes,
+		totalBytesSent:                  s.totalBytesSent,
+		totalBytesSentAtLastAckedPacket: s.totalBytesSentAtLastAckedPacket,
+		lastAckedPacketSentTime:         s.lastAckedPacketSentTime,
+		totalBytesAcked:                 s.totalBytesAcked,
+		lastAckedPacketAckTime:          s.lastAckedPacketAckTime,
+		isAppLimited:                    s.isAppLimited,
+	}
+}
+
+// OnPacketAcked is called when a packet is acknowledged.
+// It returns false if no bandwidth sample could be taken.
+func (s *bandwidthSampler) OnPacketAcked(ackTime time.Time, pn protocol.PacketNumber) (bandwidthSample, bool) {
+	p, ok := s.packets[pn]
+	if !ok {
+		return bandwidthSample{}, false
+	}
+	delete(s.packets, pn)
+
+	s.totalBytesAcked += p.size
+	s.totalBytesSentAtLastAckedPacket = p.totalBytesSent
+	s.lastAckedPacketSentTime = p.sentTime
+	s.lastAckedPacketAckTime = ackTime
+
+	if s.isAppLimited && pn > s.endOfAppLimitedPhase {
+		s.isAppLimited = false
+	}
+
+	// There's no reference point for packets sent before the first packet was acknowledged.
+	if p.lastAckedPacketAckTime.IsZero() {
+		return bandwidthSample{}, false
+	}
+
+	sendRate := infBandwidth
+	if p.sentTime.After(p.lastAckedPacketSentTime) {
+		sendRate = BandwidthFromDelta(p.totalBytesSent-p.totalBytesSentAtLastAckedPacket, p.sentTime.Sub(p.lastAckedPacketSentTime))
+	}
+	ackDuration := ackTime.Sub(p.lastAckedPacketAckTime)
+	if ackDuration <= 0 {
+		return bandwidthSample{}, false
+	}
+	ackRate := BandwidthFromDelta(s.totalBytesAcked-p.totalBytesAcked, ackDuration)
+
+	bandwidth := ackRate
+	if sendRate < ackRate {
+		bandwidth = sendRate
+	}
+	return bandwidthSample{
+		bandwidth:    bandwidth,
+		rtt:          ackTime.Sub(p.sentTime),
+		isAppLimited: p.isAppLimited,
+	}, true
+}
+
+// OnPacketLost is called when a packet is declared lost.
+func (s *bandwidthSampler) OnPacketLost(pn protocol.PacketNumber) {
+	delete(s.packets, pn)
+}
+
+// OnAppLimited is called when the sender doesn't have enough data to fill the congestion window.
+// Bandwidth samples of packets sent until then are marked as application limited.
+func (s *bandwidthSampler) OnAppLimited() {
+	s.isAppLimited = true
+	s.endOfAppLimitedPhase = s.lastSentPacket
+}
+
+// IsAppLimited says if the sender is currently application limited.
+func (s *bandwidthSampler) IsAppLimited() bool {
+	return s.isAppLimited
+}
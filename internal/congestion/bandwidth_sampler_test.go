@@ -0,0 +1,120 @@
+package congestion
+
+import (
+	"time"
+
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Bandwidth Sampler", func() {
+	var (
+		sampler       *bandwidthSampler
+		now           time.Time
+		bytesInFlight protocol.ByteCount
+	)
+
+	const packetSize protocol.ByteCount = 1000
+
+	sendPacket := func(pn protocol.PacketNumber) {
+		bytesInFlight += packetSize
+		sampler.OnPacketSent(now, pn, packetSize, bytesInFlight)
+	}
+
+	ackPacket := func(pn protocol.PacketNumber) (bandwidthSample, bool) {
+		bytesInFlight -= packetSize
+		return sampler.OnPacketAcked(now, pn)
+	}
+
+	BeforeEach(func() {
+		sampler = newBandwidthSampler()
+		now = time.Now()
+		bytesInFlight = 0
+	})
+
+	// run sends a packet every sendInterval for 100ms.
+	// Starting after 50ms, one packet is acknowledged every ackInterval.
+	// It returns the bandwidth samples.
+	run := func(sendInterval, ackInterval time.Duration) map[protocol.PacketNumber]bandwidthSample {
+		samples := make(map[protocol.PacketNumber]bandwidthSample)
+		start := now
+		nextSendTime := start
+		nextAckTime := start.Add(50 * time.Millisecond)
+		var lastSent, lastAcked protocol.PacketNumber
+		for now.Sub(start) < 200*time.Millisecond {
+			if !now.Before(nextSendTime) && now.Sub(start) < 100*time.Millisecond {
+				lastSent++
+				sendPacket(lastSent)
+				nextSendTime = nextSendTime.Add(sendInterval)
+			}
+			if !now.Before(nextAckTime) && lastAcked < lastSent {
+				lastAcked++
+				sample, ok := ackPacket(lastAcked)
+				Expect(ok).To(BeTrue())
+				samples[lastAcked] = sample
+				nextAckTime = nextAckTime.Add(ackInterval)
+			}
+			now = now.Add(time.Millisecond)
+		}
+		return samples
+	}
+
+	It("measures the bandwidth", func() {
+		samples := run(time.Millisecond, time.Millisecond)
+		Expect(samples).To(HaveLen(100))
+		for pn, sample := range samples {
+			Expect(sample.rtt).To(Equal(50 * time.Millisecond))
+			Expect(sample.isAppLimited).To(BeFalse())
+			// packets sent in the first round trip don't allow an accurate measurement yet
+			if pn > 60 {
+				Expect(sample.bandwidth).To(Equal(BandwidthFromDelta(packetSize, time.Millisecond)))
+			}
+		}
+	})
+
+	It("uses the lower of send and ack rate", func() {
+		// the packets are acknowledged at half the rate they are sent
+		samples := run(time.Millisecond, 2*time.Millisecond)
+		Expect(samples).To(HaveKey(protocol.PacketNumber(70)))
+		Expect(samples[70].bandwidth).To(Equal(BandwidthFromDelta(packetSize, 2*time.Millisecond)))
+	})
+
+	It("doesn't return samples for unknown packets", func() {
+		sendPacket(1)
+		now = now.Add(time.Millisecond)
+		_, ok := sampler.OnPacketAcked(now, 2)
+		Expect(ok).To(BeFalse())
+	})
+
+	It("doesn't return samples for lost packets", func() {
+		sendPacket(1)
+		sendPacket(2)
+		sampler.OnPacketLost(1)
+		bytesInFlight -= packetSize
+		now = now.Add(time.Millisecond)
+		_, ok := sampler.OnPacketAcked(now, 1)
+		Expect(ok).To(BeFalse())
+		_, ok = ackPacket(2)
+		Expect(ok).To(BeTrue())
+	})
+
+	It("marks samples as application limited", func() {
+		sendPacket(1)
+		sendPacket(2)
+		sampler.OnAppLimited()
+		Expect(sampler.IsAppLimited()).To(BeTrue())
+		sendPacket(3)
+		now = now.Add(10 * time.Millisecond)
+		sample, ok := ackPacket(1)
+		Expect(ok).To(BeTrue())
+		Expect(sample.isAppLimited).To(BeFalse())
+		// packet 2 was sent before the sender became application limited, so it doesn't end the app-limited phase
+		Expect(sampler.IsAppLimited()).To(BeTrue())
+		now = now.Add(time.Millisecond)
+		sample, ok = ackPacket(3)
+		Expect(ok).To(BeTrue())
+		Expect(sample.isAppLimited).To(BeTrue())
+		Expect(sampler.IsAppLimited()).To(BeFalse())
+	})
+})
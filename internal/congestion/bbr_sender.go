@@ -0,0 +1,433 @@
+package congestion
+
+import (
+	"math/rand"
+	"time"
+
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+	"github.com/lucas-clemente/quic-go/internal/utils"
+)
+
+// This file implements BBR (version 1), as described in
+// https://tools.ietf.org/html/draft-cardwell-iccrg-bbr-congestion-control-00.
+// Losses don't reduce the congestion window, and there's no special treatment of recovery periods.
+
+const (
+	// The gain used in STARTUP to double the sending rate every round trip: 2/ln(2).
+	bbrHighGain = 2.885
+	// The gain used in DRAIN to drain the queue built up in STARTUP.
+	bbrDrainGain = 1 / bbrHighGain
+	// The congestion window gain used in PROBE_BW.
+	bbrCongestionWindowGain = 2.0
+	// The number of rounds the maximum bandwidth filter remembers a sample.
+	bbrBandwidthWindowSize = uint64(len(bbrPacingGainCycle) + 2)
+	// If no smaller RTT was observed during this time, BBR enters PROBE_RTT.
+	bbrMinRTTExpiry = 10 * time.Second
+	// The time spent in PROBE_RTT.
+	bbrProbeRTTTime = 200 * time.Millisecond
+	// STARTUP ends when the bandwidth doesn't grow by this factor for bbrRoundTripsWithoutGrowthBeforeExitingStartup rounds.
+	bbrStartupGrowthTarget                         = 1.25
+	bbrRoundTripsWithoutGrowthBeforeExitingStartup = 3
+
+	bbrMinCongestionWindow = 4 * protocol.DefaultTCPMSS
+)
+
+// The pacing gains used in PROBE_BW:
+// One round probes for more bandwidth, the next one drains the queue that might have been built up.
+var bbrPacingGainCycle = [...]float64{1.25, 0.75, 1, 1, 1, 1, 1, 1}
+
+type bbrMode uint8
+
+const (
+	// bbrModeStartup ramps up the sending rate quickly, until the bandwidth stops growing.
+	bbrModeStartup bbrMode = iota
+	// bbrModeDrain drains the queue created in STARTUP.
+	bbrModeDrain
+	// bbrModeProbeBW cycles the pacing gain to probe for more bandwidth.
+	bbrModeProbeBW
+	// bbrModeProbeRTT reduces the congestion window to measure the minimum RTT.
+	bbrModeProbeRTT
+)
+
+func (m bbrMode) String() string {
+	switch m {
+	case bbrModeStartup:
+		return "STARTUP"
+	case bbrModeDrain:
+		return "DRAIN"
+	case bbrModeProbeBW:
+		return "PROBE_BW"
+	case bbrModeProbeRTT:
+		return "PROBE_RTT"
+	default:
+		return "unknown mode"
+	}
+}
+
+type bbrSender struct {
+	rttStats RTTStatsProvider
+	sampler  *bandwidthSampler
+
+	mode bbrMode
+
+	// The maximum bandwidth, windowed by round trips.
+	maxBandwidth *windowedFilter
+	// The minimum RTT, windowed by time (in nanoseconds since the Unix epoch).
+	minRTT *windowedFilter
+	// The time when the minimum RTT was last updated.
+	// If it was not updated for bbrMinRTTExpiry, BBR enters PROBE_RTT.
+	minRTTTimestamp time.Time
+
+	roundCount          uint64
+	currentRoundTripEnd protocol.PacketNumber
+	lastSentPacket      protocol.PacketNumber
+	lossInRound         bool
+
+	bytesInFlight   protocol.ByteCount
+	totalBytesAcked protocol.ByteCount
+
+	pacingRate       Bandwidth
+	pacingGain       float64
+	congestionWindow protocol.ByteCount
+	cwndGain         float64
+
+	initialCongestionWindow protocol.ByteCount
+	maxCongestionWindow     protocol.ByteCount
+
+	// STARTUP
+	fullBandwidthReached         bool
+	fullBandwidth                Bandwidth
+	roundsWithoutBandwidthGrowth int
+	lastSampleIsAppLimited       bool
+
+	// PROBE_BW
+	cycleOffset    int
+	lastCycleStart time.Time
+
+	// PROBE_RTT
+	probeRTTDoneTime    time.Time
+	probeRTTRoundPassed bool
+}
+
+var _ ExtendedSendAlgorithm = &bbrSender{}
+var _ StatefulSendAlgorithm = &bbrSender{}
+var _ ECNSendAlgorithm = &bbrSender{}
+
+// NewBBRSender makes a new BBR sender
+func NewBBRSender(rttStats RTTStatsProvider, initialCongestionWindow, initialMaxCongestionWindow protocol.ByteCount) ExtendedSendAlgorithm {
+	b := &bbrSender{
+		rttStats:                rttStats,
+		initialCongestionWindow: initialCongestionWindow,
+		maxCongestionWindow:     initialMaxCongestionWindow,
+	}
+	b.reset()
+	return b
+}
+
+func (b *bbrSender) reset() {
+	b.sampler = newBandwidthSampler()
+	b.maxBandwidth = newWindowedMaxFilter(bbrBandwidthWindowSize)
+	b.minRTT = newWindowedMinFilter(uint64(bbrMinRTTExpiry))
+	b.minRTTTimestamp = time.Time{}
+	b.roundCount = 0
+	b.currentRoundTripEnd = 0
+	b.lossInRound = false
+	b.bytesInFlight = 0
+	b.totalBytesAcked = 0
+	b.congestionWindow = b.initialCongestionWindow
+	b.pacingRate = Bandwidth(bbrHighGain * float64(BandwidthFromDelta(b.initialCongestionWindow, defaultInitialRTT)))
+	b.fullBandwidthReached = false
+	b.fullBandwidth = 0
+	b.roundsWithoutBandwidthGrowth = 0
+	b.lastSampleIsAppLimited = false
+	b.enterStartupMode()
+}
+
+// TimeUntilSend returns the pacing delay between two packets.
+// It assumes that packets have the maximum packet size. Assuming a larger size would
+// make the sender send slower than the pacing rate, which would drag down the bandwidth estimate.
+func (b *bbrSender) TimeUntilSend(bytesInFlight protocol.ByteCount) time.Duration {
+	if b.pacingRate == 0 {
+		return 0
+	}
+	return time.Duration(uint64(protocol.MaxPacketSizeIPv4) * uint64(time.Second) * uint64(BytesPerSecond) / uint64(b.pacingRate))
+}
+
+func (b *bbrSender) OnPacketSent(
+	sentTime time.Time,
+	bytesInFlight protocol.ByteCount,
+	packetNumber protocol.PacketNumber,
+	bytes protocol.ByteCount,
+	isRetransmittable bool,
+) {
+	if !isRetransmittable {
+		return
+	}
+	// quic-go doesn't tell the congestion controller when it runs out of data to send.
+	// If the pipe was drained before this packet was sent, the sender was application limited.
+	if bytesInFlight <= bytes && b.totalBytesAcked > 0 {
+		b.sampler.OnAppLimited()
+	}
+	b.lastSentPacket = packetNumber
+	b.bytesInFlight = bytesInFlight
+	b.sampler.OnPacketSent(sentTime, packetNumber, bytes, bytesInFlight)
+}
+
+func (b *bbrSender) GetCongestionWindow() protocol.ByteCount {
+	if b.mode == bbrModeProbeRTT {
+		return utils.MinByteCount(b.congestionWindow, bbrMinCongestionWindow)
+	}
+	return b.congestionWindow
+}
+
+func (b *bbrSender) OnPacketAcked(
+	ackedPacketNumber protocol.PacketNumber,
+	ackedBytes protocol.ByteCount,
+	priorInFlight protocol.ByteCount,
+	eventTime time.Time,
+) {
+	b.bytesInFlight = b.bytesInFlight - utils.MinByteCount(ackedBytes, b.bytesInFlight)
+	b.totalBytesAcked += ackedBytes
+
+	isRoundStart := b.updateRoundTripCounter(ackedPacketNumber)
+	var minRTTExpired bool
+	if sample, ok := b.sampler.OnPacketAcked(eventTime, ackedPacketNumber); ok {
+		b.lastSampleIsAppLimited = sample.isAppLimited
+		// Application limited samples underestimate the bandwidth.
+		if !sample.isAppLimited || sample.bandwidth >= b.BandwidthEstimate() {
+			b.maxBandwidth.Update(uint64(sample.bandwidth), b.roundCount)
+		}
+		minRTTExpired = b.updateMinRTT(sample.rtt, eventTime)
+	}
+
+	if b.mode == bbrModeProbeBW {
+		b.updateGainCyclePhase(eventTime, priorInFlight)
+	}
+	if isRoundStart && !b.fullBandwidthReached {
+		b.checkIfFullBandwidthReached()
+	}
+	b.maybeExitStartupOrDrain(eventTime)
+	b.maybeEnterOrExitProbeRTT(eventTime, isRoundStart, minRTTExpired)
+
+	b.calculatePacingRate()
+	b.calculateCongestionWindow(ackedBytes)
+}
+
+// OnPacketLost is called when a packet is lost.
+// BBR doesn't reduce the congestion window.
+func (b *bbrSender) OnPacketLost(
+	packetNumber protocol.PacketNumber,
+	lostBytes protocol.ByteCount,
+	priorInFlight protocol.ByteCount,
+) {
+	b.bytesInFlight = b.bytesInFlight - utils.MinByteCount(lostBytes, b.bytesInFlight)
+	b.lossInRound = true
+	b.sampler.OnPacketLost(packetNumber)
+}
+
+// OnCongestionEvent is called when the peer reports that a packet was marked ECN-CE.
+// BBR doesn't react to ECN.
+func (b *bbrSender) OnCongestionEvent(protocol.PacketNumber, protocol.ByteCount) {}
+
+// OnRetransmissionTimeout is called on an retransmission timeout.
+// BBR doesn't reduce the congestion window.
+func (b *bbrSender) OnRetransmissionTimeout(packetsRetransmitted bool) {}
+
+// MaybeExitSlowStart is not used by BBR.
+// STARTUP ends when the bandwidth stops growing.
+func (b *bbrSender) MaybeExitSlowStart() {}
+
+// SetNumEmulatedConnections is not used by BBR.
+func (b *bbrSender) SetNumEmulatedConnections(n int) {}
+
+// SetSlowStartLargeReduction is not used by BBR.
+func (b *bbrSender) SetSlowStartLargeReduction(enabled bool) {}
+
+// OnConnectionMigration resets the bandwidth and RTT estimates.
+func (b *bbrSender) OnConnectionMigration() {
+	b.reset()
+}
+
+// InSlowStart says if the sender is in STARTUP.
+func (b *bbrSender) InSlowStart() bool {
+	return b.mode == bbrModeStartup
+}
+
+// InRecovery always returns false, since BBR doesn't enter a recovery phase after a loss.
+func (b *bbrSender) InRecovery() bool {
+	return false
+}
+
+// BandwidthEstimate returns the current bandwidth estimate
+func (b *bbrSender) BandwidthEstimate() Bandwidth {
+	return Bandwidth(b.maxBandwidth.GetBest())
+}
+
+func (b *bbrSender) getMinRTT() time.Duration {
+	if minRTT := time.Duration(b.minRTT.GetBest()); minRTT != 0 {
+		return minRTT
+	}
+	if minRTT := b.rttStats.MinRTT(); minRTT != 0 {
+		return minRTT
+	}
+	return defaultInitialRTT
+}
+
+// updateRoundTripCounter starts a new round trip when a packet sent in the current round is acknowledged.
+func (b *bbrSender) updateRoundTripCounter(ackedPacketNumber protocol.PacketNumber) bool {
+	if ackedPacketNumber <= b.currentRoundTripEnd {
+		return false
+	}
+	b.roundCount++
+	b.currentRoundTripEnd = b.lastSentPacket
+	b.lossInRound = false
+	return true
+}
+
+// updateMinRTT updates the minimum RTT estimate.
+// It returns true if the minimum RTT expired.
+func (b *bbrSender) updateMinRTT(rtt time.Duration, now time.Time) bool {
+	if rtt <= 0 {
+		return false
+	}
+	expired := !b.minRTTTimestamp.IsZero() && now.Sub(b.minRTTTimestamp) > bbrMinRTTExpiry
+	if b.minRTTTimestamp.IsZero() || rtt <= b.getMinRTT() || expired {
+		b.minRTTTimestamp = now
+	}
+	b.minRTT.Update(uint64(rtt), uint64(now.UnixNano()))
+	return expired
+}
+
+// getTargetCongestionWindow returns the bandwidth delay product, multiplied by gain.
+func (b *bbrSender) getTargetCongestionWindow(gain float64) protocol.ByteCount {
+	bdp := protocol.ByteCount(float64(b.BandwidthEstimate()) / float64(BytesPerSecond) * b.getMinRTT().Seconds())
+	cwnd := protocol.ByteCount(gain * float64(bdp))
+	// There's no usable bandwidth estimate yet.
+	if cwnd == 0 {
+		cwnd = protocol.ByteCount(gain * float64(b.initialCongestionWindow))
+	}
+	return utils.MaxByteCount(cwnd, bbrMinCongestionWindow)
+}
+
+func (b *bbrSender) enterStartupMode() {
+	b.mode = bbrModeStartup
+	b.pacingGain = bbrHighGain
+	b.cwndGain = bbrHighGain
+}
+
+func (b *bbrSender) enterProbeBandwidthMode(now time.Time) {
+	b.mode = bbrModeProbeBW
+	b.cwndGain = bbrCongestionWindowGain
+	// Pick a random offset for the gain cycle, but don't start with the phase that drains the queue.
+	b.cycleOffset = rand.Intn(len(bbrPacingGainCycle) - 1)
+	if b.cycleOffset >= 1 {
+		b.cycleOffset++
+	}
+	b.lastCycleStart = now
+	b.pacingGain = bbrPacingGainCycle[b.cycleOffset]
+}
+
+func (b *bbrSender) updateGainCyclePhase(now time.Time, priorInFlight protocol.ByteCount) {
+	// Each phase of the cycle lasts for one minimum RTT.
+	shouldAdvance := now.Sub(b.lastCycleStart) > b.getMinRTT()
+	// When probing for more bandwidth, keep the gain until the pipe is full, or until a packet is lost.
+	if b.pacingGain > 1 && !b.lossInRound && priorInFlight < b.getTargetCongestionWindow(b.pacingGain) {
+		shouldAdvance = false
+	}
+	// When draining the queue, move on as soon as it is drained.
+	if b.pacingGain < 1 && priorInFlight <= b.getTargetCongestionWindow(1) {
+		shouldAdvance = true
+	}
+	if shouldAdvance {
+		b.cycleOffset = (b.cycleOffset + 1) % len(bbrPacingGainCycle)
+		b.lastCycleStart = now
+		b.pacingGain = bbrPacingGainCycle[b.cycleOffset]
+	}
+}
+
+func (b *bbrSender) checkIfFullBandwidthReached() {
+	if b.lastSampleIsAppLimited {
+		return
+	}
+	if bw := b.BandwidthEstimate(); float64(bw) >= float64(b.fullBandwidth)*bbrStartupGrowthTarget {
+		b.fullBandwidth = bw
+		b.roundsWithoutBandwidthGrowth = 0
+		return
+	}
+	b.roundsWithoutBandwidthGrowth++
+	if b.roundsWithoutBandwidthGrowth >= bbrRoundTripsWithoutGrowthBeforeExitingStartup {
+		b.fullBandwidthReached = true
+	}
+}
+
+func (b *bbrSender) maybeExitStartupOrDrain(now time.Time) {
+	if b.mode == bbrModeStartup && b.fullBandwidthReached {
+		b.mode = bbrModeDrain
+		b.pacingGain = bbrDrainGain
+		b.cwndGain = bbrHighGain
+	}
+	if b.mode == bbrModeDrain && b.bytesInFlight <= b.getTargetCongestionWindow(1) {
+		b.enterProbeBandwidthMode(now)
+	}
+}
+
+func (b *bbrSender) maybeEnterOrExitProbeRTT(now time.Time, isRoundStart, minRTTExpired bool) {
+	if minRTTExpired && b.mode != bbrModeProbeRTT {
+		b.mode = bbrModeProbeRTT
+		b.pacingGain = 1
+		b.probeRTTDoneTime = time.Time{}
+	}
+	if b.mode != bbrModeProbeRTT {
+		return
+	}
+	// The bandwidth samples taken in PROBE_RTT underestimate the bandwidth.
+	b.sampler.OnAppLimited()
+	if b.probeRTTDoneTime.IsZero() {
+		// Wait until the number of bytes in flight was reduced to the PROBE_RTT congestion window.
+		if b.bytesInFlight <= bbrMinCongestionWindow {
+			b.probeRTTDoneTime = now.Add(bbrProbeRTTTime)
+			b.probeRTTRoundPassed = false
+			b.currentRoundTripEnd = b.lastSentPacket
+		}
+		return
+	}
+	if isRoundStart {
+		b.probeRTTRoundPassed = true
+	}
+	if b.probeRTTRoundPassed && !now.Before(b.probeRTTDoneTime) {
+		b.minRTTTimestamp = now
+		if b.fullBandwidthReached {
+			b.enterProbeBandwidthMode(now)
+		} else {
+			b.enterStartupMode()
+		}
+	}
+}
+
+func (b *bbrSender) calculatePacingRate() {
+	bw := b.BandwidthEstimate()
+	if bw == 0 {
+		return
+	}
+	target := Bandwidth(b.pacingGain * float64(bw))
+	// Don't decrease the pacing rate in STARTUP.
+	if !b.fullBandwidthReached && target < b.pacingRate {
+		return
+	}
+	b.pacingRate = target
+}
+
+func (b *bbrSender) calculateCongestionWindow(ackedBytes protocol.ByteCount) {
+	if b.mode == bbrModeProbeRTT {
+		return
+	}
+	target := b.getTargetCongestionWindow(b.cwndGain)
+	if b.fullBandwidthReached {
+		b.congestionWindow = utils.MinByteCount(target, b.congestionWindow+ackedBytes)
+	} else if b.congestionWindow < target || b.totalBytesAcked < b.initialCongestionWindow {
+		// In STARTUP, grow the congestion window by the number of bytes acknowledged.
+		b.congestionWindow += ackedBytes
+	}
+	b.congestionWindow = utils.MaxByteCount(b.congestionWindow, bbrMinCongestionWindow)
+	b.congestionWindow = utils.MinByteCount(b.congestionWindow, b.maxCongestionWindow)
+}
@@ -0,0 +1,246 @@
+package congestion
+
+import (
+	"time"
+
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// A bottleneckLink simulates a sender that is limited by the congestion window and the pacing rate
+// of a BBR sender, sending over a path with a bottleneck of a fixed bandwidth and a fixed RTT.
+// The queue at the bottleneck is unlimited, so no packets are lost.
+type bottleneckLink struct {
+	sender    *bbrSender
+	bandwidth Bandwidth
+	rtt       time.Duration
+
+	now                time.Time
+	nextSendTime       time.Time
+	lastDeparture      time.Time
+	lastSent           protocol.PacketNumber
+	bytesInFlight      protocol.ByteCount
+	inFlight           []inFlightPacket
+	onEvent            func()
+	packetsTransferred int
+}
+
+type inFlightPacket struct {
+	pn      protocol.PacketNumber
+	ackTime time.Time
+}
+
+const bbrPacketSize = protocol.MaxPacketSizeIPv4
+
+func newBottleneckLink(sender *bbrSender, bandwidth Bandwidth, rtt time.Duration) *bottleneckLink {
+	now := time.Now()
+	return &bottleneckLink{
+		sender:       sender,
+		bandwidth:    bandwidth,
+		rtt:          rtt,
+		now:          now,
+		nextSendTime: now,
+	}
+}
+
+func (l *bottleneckLink) transmissionTime() time.Duration {
+	return time.Duration(uint64(bbrPacketSize) * uint64(BytesPerSecond) * uint64(time.Second) / uint64(l.bandwidth))
+}
+
+func (l *bottleneckLink) canSend() bool {
+	return l.bytesInFlight+bbrPacketSize <= l.sender.GetCongestionWindow()
+}
+
+func (l *bottleneckLink) run(d time.Duration) {
+	end := l.now.Add(d)
+	for l.now.Before(end) {
+		// determine the next event
+		var next time.Time
+		send := l.canSend()
+		if send {
+			next = l.nextSendTime
+		}
+		if len(l.inFlight) > 0 && (!send || l.inFlight[0].ackTime.Before(next)) {
+			next = l.inFlight[0].ackTime
+			send = false
+		}
+		if next.Before(l.now) {
+			next = l.now
+		}
+		l.now = next
+		if send {
+			l.sendPacket()
+		} else {
+			l.ackPacket()
+		}
+		if l.onEvent != nil {
+			l.onEvent()
+		}
+	}
+}
+
+func (l *bottleneckLink) sendPacket() {
+	l.lastSent++
+	l.bytesInFlight += bbrPacketSize
+	l.sender.OnPacketSent(l.now, l.bytesInFlight, l.lastSent, bbrPacketSize, true)
+	departure := l.now
+	if l.lastDeparture.After(departure) {
+		departure = l.lastDeparture
+	}
+	departure = departure.Add(l.transmissionTime())
+	l.lastDeparture = departure
+	l.inFlight = append(l.inFlight, inFlightPacket{pn: l.lastSent, ackTime: departure.Add(l.rtt)})
+	l.nextSendTime = l.now.Add(l.sender.TimeUntilSend(l.bytesInFlight))
+}
+
+func (l *bottleneckLink) ackPacket() {
+	p := l.inFlight[0]
+	l.inFlight = l.inFlight[1:]
+	priorInFlight := l.bytesInFlight
+	l.bytesInFlight -= bbrPacketSize
+	l.packetsTransferred++
+	l.sender.OnPacketAcked(p.pn, bbrPacketSize, priorInFlight, l.now)
+}
+
+var _ = Describe("BBR Sender", func() {
+	const (
+		bandwidth = 10 * 1000 * 1000 * BitsPerSecond
+		rtt       = 50 * time.Millisecond
+	)
+
+	var (
+		sender   *bbrSender
+		rttStats *RTTStats
+		link     *bottleneckLink
+	)
+
+	BeforeEach(func() {
+		rttStats = &RTTStats{}
+		sender = NewBBRSender(rttStats, protocol.InitialCongestionWindow, protocol.DefaultMaxCongestionWindow).(*bbrSender)
+		link = newBottleneckLink(sender, bandwidth, rtt)
+	})
+
+	It("has the right values at startup", func() {
+		Expect(sender.mode).To(Equal(bbrModeStartup))
+		Expect(sender.GetCongestionWindow()).To(Equal(protocol.InitialCongestionWindow))
+		Expect(sender.BandwidthEstimate()).To(BeZero())
+		Expect(sender.TimeUntilSend(0)).ToNot(BeZero())
+	})
+
+	It("paces packets according to the pacing rate", func() {
+		sender.pacingRate = BandwidthFromDelta(bbrPacketSize, time.Millisecond)
+		Expect(sender.TimeUntilSend(0)).To(Equal(time.Millisecond))
+	})
+
+	It("has a string representation for the modes", func() {
+		Expect(bbrModeStartup.String()).To(Equal("STARTUP"))
+		Expect(bbrModeDrain.String()).To(Equal("DRAIN"))
+		Expect(bbrModeProbeBW.String()).To(Equal("PROBE_BW"))
+		Expect(bbrModeProbeRTT.String()).To(Equal("PROBE_RTT"))
+		Expect(bbrMode(42).String()).To(Equal("unknown mode"))
+	})
+
+	It("reports STARTUP as slow start", func() {
+		Expect(sender.InSlowStart()).To(BeTrue())
+		Expect(sender.InRecovery()).To(BeFalse())
+		link.run(3 * time.Second)
+		Expect(sender.InSlowStart()).To(BeFalse())
+		Expect(sender.InRecovery()).To(BeFalse())
+	})
+
+	It("grows the congestion window in STARTUP", func() {
+		link.run(2 * rtt)
+		Expect(sender.mode).To(Equal(bbrModeStartup))
+		Expect(sender.GetCongestionWindow()).To(BeNumerically(">", protocol.InitialCongestionWindow))
+	})
+
+	It("goes through STARTUP and DRAIN, and then probes the bandwidth", func() {
+		modes := []bbrMode{sender.mode}
+		link.onEvent = func() {
+			if mode := sender.mode; modes[len(modes)-1] != mode {
+				modes = append(modes, mode)
+			}
+		}
+		link.run(3 * time.Second)
+		Expect(modes[:3]).To(Equal([]bbrMode{bbrModeStartup, bbrModeDrain, bbrModeProbeBW}))
+		Expect(sender.fullBandwidthReached).To(BeTrue())
+		Expect(sender.mode).To(Equal(bbrModeProbeBW))
+	})
+
+	It("estimates the bandwidth and the minimum RTT", func() {
+		link.run(3 * time.Second)
+		Expect(sender.BandwidthEstimate()).To(BeNumerically("~", bandwidth, bandwidth/10))
+		Expect(sender.getMinRTT()).To(BeNumerically(">=", rtt))
+		Expect(sender.getMinRTT()).To(BeNumerically("<", rtt+5*link.transmissionTime()))
+		// the congestion window is twice the bandwidth delay product
+		bdp := protocol.ByteCount(float64(bandwidth) / float64(BytesPerSecond) * rtt.Seconds())
+		Expect(sender.GetCongestionWindow()).To(BeNumerically("~", 2*bdp, bdp/2))
+	})
+
+	It("utilizes the link", func() {
+		link.run(time.Second)
+		link.packetsTransferred = 0
+		link.run(5 * time.Second)
+		throughput := BandwidthFromDelta(protocol.ByteCount(link.packetsTransferred)*bbrPacketSize, 5*time.Second)
+		Expect(throughput).To(BeNumerically(">", bandwidth*9/10))
+	})
+
+	It("doesn't build up a large queue", func() {
+		link.run(time.Second)
+		var maxQueueingDelay time.Duration
+		link.onEvent = func() {
+			if d := link.lastDeparture.Sub(link.now); d > maxQueueingDelay {
+				maxQueueingDelay = d
+			}
+		}
+		link.run(5 * time.Second)
+		Expect(maxQueueingDelay).To(BeNumerically("<", 2*rtt))
+	})
+
+	It("enters PROBE_RTT when the minimum RTT expires", func() {
+		link.run(time.Second)
+		Expect(sender.mode).To(Equal(bbrModeProbeBW))
+		// pretend that the minimum RTT was measured a long time ago
+		sender.minRTTTimestamp = link.now.Add(-bbrMinRTTExpiry - time.Second)
+		var probeRTTStart, probeRTTEnd time.Time
+		var minCongestionWindow protocol.ByteCount
+		link.onEvent = func() {
+			if sender.mode == bbrModeProbeRTT {
+				if probeRTTStart.IsZero() {
+					probeRTTStart = link.now
+					minCongestionWindow = sender.GetCongestionWindow()
+				}
+				return
+			}
+			if !probeRTTStart.IsZero() && probeRTTEnd.IsZero() {
+				probeRTTEnd = link.now
+			}
+		}
+		link.run(time.Second)
+		Expect(probeRTTStart).ToNot(BeZero())
+		Expect(probeRTTEnd).ToNot(BeZero())
+		Expect(minCongestionWindow).To(Equal(bbrMinCongestionWindow))
+		Expect(probeRTTEnd.Sub(probeRTTStart)).To(BeNumerically(">=", bbrProbeRTTTime))
+		Expect(sender.mode).To(Equal(bbrModeProbeBW))
+		Expect(sender.minRTTTimestamp).To(BeTemporally(">=", probeRTTEnd))
+	})
+
+	It("doesn't reduce the congestion window when a packet is lost", func() {
+		link.run(time.Second)
+		cwnd := sender.GetCongestionWindow()
+		sender.OnPacketLost(link.inFlight[0].pn, bbrPacketSize, link.bytesInFlight)
+		Expect(sender.GetCongestionWindow()).To(Equal(cwnd))
+		sender.OnRetransmissionTimeout(true)
+		Expect(sender.GetCongestionWindow()).To(Equal(cwnd))
+	})
+
+	It("resets on connection migration", func() {
+		link.run(time.Second)
+		Expect(sender.BandwidthEstimate()).ToNot(BeZero())
+		sender.OnConnectionMigration()
+		Expect(sender.mode).To(Equal(bbrModeStartup))
+		Expect(sender.BandwidthEstimate()).To(BeZero())
+		Expect(sender.GetCongestionWindow()).To(Equal(protocol.InitialCongestionWindow))
+	})
+})
@@ -16,7 +16,7 @@ const (
 type cubicSender struct {
 	hybridSlowStart HybridSlowStart
 	prr             PrrSender
-	rttStats        *RTTStats
+	rttStats        RTTStatsProvider
 	stats           connectionStats
 	cubic           *Cubic
 
@@ -64,9 +64,11 @@ type cubicSender struct {
 
 var _ SendAlgorithm = &cubicSender{}
 var _ SendAlgorithmWithDebugInfo = &cubicSender{}
+var _ StatefulSendAlgorithm = &cubicSender{}
+var _ ECNSendAlgorithm = &cubicSender{}
 
 // NewCubicSender makes a new cubic sender
-func NewCubicSender(clock Clock, rttStats *RTTStats, reno bool, initialCongestionWindow, initialMaxCongestionWindow protocol.ByteCount) SendAlgorithmWithDebugInfo {
+func NewCubicSender(clock Clock, rttStats RTTStatsProvider, reno bool, initialCongestionWindow, initialMaxCongestionWindow protocol.ByteCount) SendAlgorithmWithDebugInfo {
 	return &cubicSender{
 		rttStats:                   rttStats,
 		initialCongestionWindow:    initialCongestionWindow,
@@ -177,11 +179,24 @@ func (c *cubicSender) OnPacketLost(
 		}
 		return
 	}
-	c.lastCutbackExitedSlowstart = c.InSlowStart()
 	if c.InSlowStart() {
 		c.stats.slowstartPacketsLost++
 	}
+	c.reduceCongestionWindow(priorInFlight)
+}
+
+// OnCongestionEvent is called when the peer reports that a packet was marked ECN-CE.
+// This is treated like a packet loss (see RFC 3168), but since no data was lost,
+// the congestion window is only reduced once per window.
+func (c *cubicSender) OnCongestionEvent(packetNumber protocol.PacketNumber, priorInFlight protocol.ByteCount) {
+	if packetNumber <= c.largestSentAtLastCutback {
+		return
+	}
+	c.reduceCongestionWindow(priorInFlight)
+}
 
+func (c *cubicSender) reduceCongestionWindow(priorInFlight protocol.ByteCount) {
+	c.lastCutbackExitedSlowstart = c.InSlowStart()
 	c.prr.OnPacketLost(priorInFlight)
 
 	// TODO(chromium): Separate out all of slow start into a separate class.
@@ -458,6 +458,21 @@ var _ = Describe("Cubic Sender", func() {
 		Expect(postLossWindow).To(BeNumerically(">", sender.GetCongestionWindow()))
 	})
 
+	It("reduces the congestion window once per window on ECN-CE marks", func() {
+		SendAvailableSendWindow()
+		initialWindow := sender.GetCongestionWindow()
+		sender.(ECNSendAlgorithm).OnCongestionEvent(ackedPacketNumber+1, bytesInFlight)
+		postCongestionWindow := sender.GetCongestionWindow()
+		Expect(initialWindow).To(BeNumerically(">", postCongestionWindow))
+		sender.(ECNSendAlgorithm).OnCongestionEvent(packetNumber-1, bytesInFlight)
+		Expect(sender.GetCongestionWindow()).To(Equal(postCongestionWindow))
+		// A CE mark on a packet sent after the cutback reduces the window again.
+		AckNPackets(int(bytesInFlight / protocol.DefaultTCPMSS))
+		SendAvailableSendWindow()
+		sender.(ECNSendAlgorithm).OnCongestionEvent(packetNumber-1, bytesInFlight)
+		Expect(postCongestionWindow).To(BeNumerically(">", sender.GetCongestionWindow()))
+	})
+
 	It("2 connection congestion avoidance at end of recovery", func() {
 		sender.SetNumEmulatedConnections(2)
 		// Ack 10 packets in 5 acks to raise the CWND to 20.
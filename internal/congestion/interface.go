@@ -11,20 +11,51 @@ type SendAlgorithm interface {
 	TimeUntilSend(bytesInFlight protocol.ByteCount) time.Duration
 	OnPacketSent(sentTime time.Time, bytesInFlight protocol.ByteCount, packetNumber protocol.PacketNumber, bytes protocol.ByteCount, isRetransmittable bool)
 	GetCongestionWindow() protocol.ByteCount
-	MaybeExitSlowStart()
 	OnPacketAcked(number protocol.PacketNumber, ackedBytes protocol.ByteCount, priorInFlight protocol.ByteCount, eventTime time.Time)
 	OnPacketLost(number protocol.PacketNumber, lostBytes protocol.ByteCount, priorInFlight protocol.ByteCount)
-	SetNumEmulatedConnections(n int)
 	OnRetransmissionTimeout(packetsRetransmitted bool)
-	OnConnectionMigration()
+}
+
+// An ExtendedSendAlgorithm is a SendAlgorithm that is also notified about RTT updates and connection migrations.
+type ExtendedSendAlgorithm interface {
+	MigrationAwareSendAlgorithm
+	MaybeExitSlowStart()
+	SetNumEmulatedConnections(n int)
 
 	// Experiments
 	SetSlowStartLargeReduction(enabled bool)
 }
 
+// A MigrationAwareSendAlgorithm is a SendAlgorithm that is notified when the connection migrates to a new path.
+type MigrationAwareSendAlgorithm interface {
+	SendAlgorithm
+	OnConnectionMigration()
+}
+
+// A StatefulSendAlgorithm is a SendAlgorithm that reports if it is in slow start or in recovery.
+// This is used for tracing the congestion state.
+type StatefulSendAlgorithm interface {
+	SendAlgorithm
+	InSlowStart() bool
+	InRecovery() bool
+}
+
+// An ECNSendAlgorithm is a SendAlgorithm that is notified when the peer reports ECN-CE marks.
+type ECNSendAlgorithm interface {
+	SendAlgorithm
+	OnCongestionEvent(largestAcked protocol.PacketNumber, priorInFlight protocol.ByteCount)
+}
+
+// SupportsECN says if a SendAlgorithm reacts to ECN-CE marks.
+// Packets must only be marked ECT(0) if it does.
+func SupportsECN(c SendAlgorithm) bool {
+	_, ok := c.(ECNSendAlgorithm)
+	return ok
+}
+
 // SendAlgorithmWithDebugInfo adds some debug functions to SendAlgorithm
 type SendAlgorithmWithDebugInfo interface {
-	SendAlgorithm
+	ExtendedSendAlgorithm
 	BandwidthEstimate() Bandwidth
 
 	// Stuff only used in testing
@@ -34,3 +65,13 @@ type SendAlgorithmWithDebugInfo interface {
 	RenoBeta() float32
 	InRecovery() bool
 }
+
+// An RTTStatsProvider provides the RTT estimates of a connection.
+type RTTStatsProvider interface {
+	MinRTT() time.Duration
+	LatestRTT() time.Duration
+	SmoothedRTT() time.Duration
+	MeanDeviation() time.Duration
+}
+
+var _ RTTStatsProvider = &RTTStats{}
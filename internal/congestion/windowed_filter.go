@@ -0,0 +1,105 @@
+package congestion
+
+// A windowedFilter tracks the best (i.e. the maximum or the minimum) of a stream of samples over a window.
+// The window is measured in an arbitrary unit of time, e.g. in round trips or in nanoseconds.
+// It implements Kathleen Nichols' algorithm, which is also used by the Linux implementation of BBR:
+// It keeps track of the best, second best and third best sample, such that the second and third best
+// were taken after the best sample. When the best sample expires, the second best replaces it.
+type windowedFilter struct {
+	windowLength uint64
+	isBetter     func(a, b uint64) bool
+
+	hasSample bool
+	estimates [3]filterSample
+}
+
+type filterSample struct {
+	sample uint64
+	time   uint64
+}
+
+func newWindowedMaxFilter(windowLength uint64) *windowedFilter {
+	return &windowedFilter{
+		windowLength: windowLength,
+		isBetter:     func(a, b uint64) bool { return a >= b },
+	}
+}
+
+func newWindowedMinFilter(windowLength uint64) *windowedFilter {
+	return &windowedFilter{
+		windowLength: windowLength,
+		isBetter:     func(a, b uint64) bool { return a <= b },
+	}
+}
+
+// Update adds a new sample.
+// Samples must be added in chronological order.
+func (f *windowedFilter) Update(sample, time uint64) {
+	newSample := filterSample{sample: sample, time: time}
+	if !f.hasSample || f.isBetter(sample, f.estimates[0].sample) || time-f.estimates[2].time > f.windowLength {
+		f.Reset(sample, time)
+		return
+	}
+
+	if f.isBetter(sample, f.estimates[1].sample) {
+		f.estimates[1] = newSample
+		f.estimates[2] = newSample
+	} else if f.isBetter(sample, f.estimates[2].sample) {
+		f.estimates[2] = newSample
+	}
+
+	// Expire and update estimates as necessary.
+	if time-f.estimates[0].time > f.windowLength {
+		// The best estimate hasn't been updated for an entire window, so promote the second and third best estimates.
+		f.estimates[0] = f.estimates[1]
+		f.estimates[1] = f.estimates[2]
+		f.estimates[2] = newSample
+		// Need to iterate one more time.
+		// Check if the new best estimate is outside the window as well,
+		// since it may also have been recorded a long time ago.
+		if time-f.estimates[0].time > f.windowLength {
+			f.estimates[0] = f.estimates[1]
+			f.estimates[1] = f.estimates[2]
+		}
+		return
+	}
+	if f.estimates[1].sample == f.estimates[0].sample && time-f.estimates[1].time > f.windowLength>>2 {
+		// A quarter of the window has passed without a better sample, so the second best estimate
+		// is taken from the second quarter of the window.
+		f.estimates[1] = newSample
+		f.estimates[2] = newSample
+		return
+	}
+	if f.estimates[2].sample == f.estimates[1].sample && time-f.estimates[2].time > f.windowLength>>1 {
+		// We've passed a half of the window without a better estimate, so take a third best estimate
+		// from the second half of the window.
+		f.estimates[2] = newSample
+	}
+}
+
+// Reset resets all estimates to the given sample.
+func (f *windowedFilter) Reset(sample, time uint64) {
+	f.hasSample = true
+	s := filterSample{sample: sample, time: time}
+	f.estimates = [3]filterSample{s, s, s}
+}
+
+// GetBest returns the best sample in the window.
+func (f *windowedFilter) GetBest() uint64 {
+	return f.estimates[0].sample
+}
+
+// GetBestTime returns the time at which the best sample was taken.
+func (f *windowedFilter) GetBestTime() uint64 {
+	return f.estimates[0].time
+}
+
+// GetSecondBest returns the second best sample.
+func (f *windowedFilter) GetSecondBest() uint64 {
+	return f.estimates[1].sample
+}
+
+// GetThirdBest returns the third best sample.
+func (f *windowedFilter) GetThirdBest() uint64 {
+	return f.estimates[2].sample
+}
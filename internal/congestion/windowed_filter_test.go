@@ -0,0 +1,91 @@
+package congestion
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Windowed Filter", func() {
+	Context("max filter", func() {
+		var f *windowedFilter
+
+		BeforeEach(func() {
+			f = newWindowedMaxFilter(100)
+		})
+
+		It("returns the first sample", func() {
+			f.Update(10, 0)
+			Expect(f.GetBest()).To(Equal(uint64(10)))
+			Expect(f.GetSecondBest()).To(Equal(uint64(10)))
+			Expect(f.GetThirdBest()).To(Equal(uint64(10)))
+			Expect(f.GetBestTime()).To(BeZero())
+		})
+
+		It("replaces all estimates with a better sample", func() {
+			f.Update(10, 0)
+			f.Update(5, 10)
+			f.Update(20, 20)
+			Expect(f.GetBest()).To(Equal(uint64(20)))
+			Expect(f.GetSecondBest()).To(Equal(uint64(20)))
+			Expect(f.GetThirdBest()).To(Equal(uint64(20)))
+			Expect(f.GetBestTime()).To(Equal(uint64(20)))
+		})
+
+		It("keeps the best sample within the window", func() {
+			f.Update(10, 0)
+			f.Update(5, 50)
+			f.Update(8, 100)
+			Expect(f.GetBest()).To(Equal(uint64(10)))
+		})
+
+		It("expires the best sample", func() {
+			f.Update(10, 0)
+			f.Update(9, 30)
+			f.Update(7, 60)
+			f.Update(5, 101)
+			Expect(f.GetBest()).To(Equal(uint64(9)))
+			Expect(f.GetBestTime()).To(Equal(uint64(30)))
+			// the 7 was never recorded as an estimate, since it was taken in the first half of the window
+			f.Update(5, 131)
+			Expect(f.GetBest()).To(Equal(uint64(5)))
+		})
+
+		It("resets when all samples expired", func() {
+			f.Update(10, 0)
+			f.Update(3, 1000)
+			Expect(f.GetBest()).To(Equal(uint64(3)))
+			Expect(f.GetBestTime()).To(Equal(uint64(1000)))
+		})
+
+		It("resets", func() {
+			f.Update(10, 0)
+			f.Reset(3, 5)
+			Expect(f.GetBest()).To(Equal(uint64(3)))
+			Expect(f.GetThirdBest()).To(Equal(uint64(3)))
+		})
+	})
+
+	Context("min filter", func() {
+		var f *windowedFilter
+
+		BeforeEach(func() {
+			f = newWindowedMinFilter(100)
+		})
+
+		It("keeps the minimum within the window", func() {
+			f.Update(10, 0)
+			f.Update(20, 10)
+			f.Update(5, 20)
+			f.Update(30, 30)
+			Expect(f.GetBest()).To(Equal(uint64(5)))
+		})
+
+		It("expires the minimum", func() {
+			f.Update(5, 0)
+			f.Update(20, 40)
+			f.Update(30, 70)
+			f.Update(40, 101)
+			Expect(f.GetBest()).To(Equal(uint64(20)))
+		})
+	})
+})
@@ -1,7 +1,6 @@
 package handshake
 
 import (
-	"crypto/aes"
 	"crypto/tls"
 	"errors"
 	"fmt"
@@ -10,6 +9,7 @@ import (
 	"sync"
 	"unsafe"
 
+	"github.com/lucas-clemente/quic-go/internal/congestion"
 	"github.com/lucas-clemente/quic-go/internal/protocol"
 	"github.com/lucas-clemente/quic-go/internal/qerr"
 	"github.com/lucas-clemente/quic-go/internal/utils"
@@ -87,6 +87,7 @@ type cryptoSetup struct {
 	// for clients: to see if a ServerHello is a HelloRetryRequest
 	writeRecord chan struct{}
 
+	tracer KeyTracer
 	logger utils.Logger
 
 	perspective protocol.Perspective
@@ -104,9 +105,10 @@ type cryptoSetup struct {
 	handshakeOpener Opener
 	handshakeSealer Sealer
 
-	oneRTTStream io.Writer
-	opener       Opener
-	sealer       Sealer
+	oneRTTStream  io.Writer
+	aead          *updatableAEAD
+	has1RTTSealer bool
+	has1RTTOpener bool
 }
 
 var _ qtls.RecordLayer = &cryptoSetup{}
@@ -122,6 +124,9 @@ func NewCryptoSetupClient(
 	tp *TransportParameters,
 	handleParams func([]byte),
 	tlsConf *tls.Config,
+	rttStats *congestion.RTTStats,
+	keyUpdateInterval uint64,
+	tracer KeyTracer,
 	logger utils.Logger,
 ) (CryptoSetup, <-chan struct{} /* ClientHello written */, error) {
 	cs, clientHelloWritten, err := newCryptoSetup(
@@ -132,6 +137,9 @@ func NewCryptoSetupClient(
 		tp,
 		handleParams,
 		tlsConf,
+		rttStats,
+		keyUpdateInterval,
+		tracer,
 		logger,
 		protocol.PerspectiveClient,
 	)
@@ -152,6 +160,9 @@ func NewCryptoSetupServer(
 	tp *TransportParameters,
 	handleParams func([]byte),
 	tlsConf *tls.Config,
+	rttStats *congestion.RTTStats,
+	keyUpdateInterval uint64,
+	tracer KeyTracer,
 	logger utils.Logger,
 ) (CryptoSetup, error) {
 	cs, _, err := newCryptoSetup(
@@ -162,6 +173,9 @@ func NewCryptoSetupServer(
 		tp,
 		handleParams,
 		tlsConf,
+		rttStats,
+		keyUpdateInterval,
+		tracer,
 		logger,
 		protocol.PerspectiveServer,
 	)
@@ -180,6 +194,9 @@ func newCryptoSetup(
 	tp *TransportParameters,
 	handleParams func([]byte),
 	tlsConf *tls.Config,
+	rttStats *congestion.RTTStats,
+	keyUpdateInterval uint64,
+	tracer KeyTracer,
 	logger utils.Logger,
 	perspective protocol.Perspective,
 ) (*cryptoSetup, <-chan struct{} /* ClientHello written */, error) {
@@ -187,6 +204,10 @@ func newCryptoSetup(
 	if err != nil {
 		return nil, nil, err
 	}
+	if tracer != nil {
+		tracer.UpdatedKeyFromTLS(protocol.EncryptionInitial, protocol.PerspectiveClient)
+		tracer.UpdatedKeyFromTLS(protocol.EncryptionInitial, protocol.PerspectiveServer)
+	}
 	extHandler := newExtensionHandler(tp.Marshal(), perspective)
 	cs := &cryptoSetup{
 		initialStream:          initialStream,
@@ -194,10 +215,12 @@ func newCryptoSetup(
 		initialOpener:          initialOpener,
 		handshakeStream:        handshakeStream,
 		oneRTTStream:           oneRTTStream,
+		aead:                   newUpdatableAEAD(rttStats, keyUpdateInterval, tracer, logger),
 		readEncLevel:           protocol.EncryptionInitial,
 		writeEncLevel:          protocol.EncryptionInitial,
 		handleParamsCallback:   handleParams,
 		paramsChan:             extHandler.TransportParameters(),
+		tracer:                 tracer,
 		logger:                 logger,
 		perspective:            perspective,
 		handshakeDone:          make(chan struct{}),
@@ -222,6 +245,10 @@ func (h *cryptoSetup) ChangeConnectionID(id protocol.ConnectionID) error {
 	}
 	h.initialSealer = initialSealer
 	h.initialOpener = initialOpener
+	if h.tracer != nil {
+		h.tracer.UpdatedKeyFromTLS(protocol.EncryptionInitial, protocol.PerspectiveClient)
+		h.tracer.UpdatedKeyFromTLS(protocol.EncryptionInitial, protocol.PerspectiveServer)
+	}
 	return nil
 }
 
@@ -426,54 +453,46 @@ func (h *cryptoSetup) ReadHandshakeMessage() ([]byte, error) {
 }
 
 func (h *cryptoSetup) SetReadKey(suite *qtls.CipherSuite, trafficSecret []byte) {
-	key := qtls.HkdfExpandLabel(suite.Hash(), trafficSecret, []byte{}, "quic key", suite.KeyLen())
-	iv := qtls.HkdfExpandLabel(suite.Hash(), trafficSecret, []byte{}, "quic iv", suite.IVLen())
-	hpKey := qtls.HkdfExpandLabel(suite.Hash(), trafficSecret, []byte{}, "quic hp", suite.KeyLen())
-	hpDecrypter, err := aes.NewCipher(hpKey)
-	if err != nil {
-		panic(fmt.Sprintf("error creating new AES cipher: %s", err))
-	}
-
 	h.mutex.Lock()
 	switch h.readEncLevel {
 	case protocol.EncryptionInitial:
 		h.readEncLevel = protocol.EncryptionHandshake
-		h.handshakeOpener = newOpener(suite.AEAD(key, iv), hpDecrypter, false)
+		h.handshakeOpener = newOpener(createAEAD(suite, trafficSecret), createHeaderProtector(suite, trafficSecret), false)
 		h.logger.Debugf("Installed Handshake Read keys")
 	case protocol.EncryptionHandshake:
 		h.readEncLevel = protocol.Encryption1RTT
-		h.opener = newOpener(suite.AEAD(key, iv), hpDecrypter, true)
+		h.aead.SetReadKey(suite, trafficSecret)
+		h.has1RTTOpener = true
 		h.logger.Debugf("Installed 1-RTT Read keys")
 	default:
 		panic("unexpected read encryption level")
 	}
 	h.mutex.Unlock()
+	if h.tracer != nil {
+		h.tracer.UpdatedKeyFromTLS(h.readEncLevel, h.perspective.Opposite())
+	}
 	h.receivedReadKey <- struct{}{}
 }
 
 func (h *cryptoSetup) SetWriteKey(suite *qtls.CipherSuite, trafficSecret []byte) {
-	key := qtls.HkdfExpandLabel(suite.Hash(), trafficSecret, []byte{}, "quic key", suite.KeyLen())
-	iv := qtls.HkdfExpandLabel(suite.Hash(), trafficSecret, []byte{}, "quic iv", suite.IVLen())
-	hpKey := qtls.HkdfExpandLabel(suite.Hash(), trafficSecret, []byte{}, "quic hp", suite.KeyLen())
-	hpEncrypter, err := aes.NewCipher(hpKey)
-	if err != nil {
-		panic(fmt.Sprintf("error creating new AES cipher: %s", err))
-	}
-
 	h.mutex.Lock()
 	switch h.writeEncLevel {
 	case protocol.EncryptionInitial:
 		h.writeEncLevel = protocol.EncryptionHandshake
-		h.handshakeSealer = newSealer(suite.AEAD(key, iv), hpEncrypter, false)
+		h.handshakeSealer = newSealer(createAEAD(suite, trafficSecret), createHeaderProtector(suite, trafficSecret), false)
 		h.logger.Debugf("Installed Handshake Write keys")
 	case protocol.EncryptionHandshake:
 		h.writeEncLevel = protocol.Encryption1RTT
-		h.sealer = newSealer(suite.AEAD(key, iv), hpEncrypter, true)
+		h.aead.SetWriteKey(suite, trafficSecret)
+		h.has1RTTSealer = true
 		h.logger.Debugf("Installed 1-RTT Write keys")
 	default:
 		panic("unexpected write encryption level")
 	}
 	h.mutex.Unlock()
+	if h.tracer != nil {
+		h.tracer.UpdatedKeyFromTLS(h.writeEncLevel, h.perspective)
+	}
 	h.receivedWriteKey <- struct{}{}
 }
 
@@ -515,8 +534,8 @@ func (h *cryptoSetup) GetSealer() (protocol.EncryptionLevel, Sealer) {
 	h.mutex.Lock()
 	defer h.mutex.Unlock()
 
-	if h.sealer != nil {
-		return protocol.Encryption1RTT, h.sealer
+	if h.has1RTTSealer {
+		return protocol.Encryption1RTT, h.aead
 	}
 	if h.handshakeSealer != nil {
 		return protocol.EncryptionHandshake, h.handshakeSealer
@@ -539,10 +558,10 @@ func (h *cryptoSetup) GetSealerWithEncryptionLevel(level protocol.EncryptionLeve
 		}
 		return h.handshakeSealer, nil
 	case protocol.Encryption1RTT:
-		if h.sealer == nil {
+		if !h.has1RTTSealer {
 			return nil, errNoSealer
 		}
-		return h.sealer, nil
+		return h.aead, nil
 	default:
 		return nil, errNoSealer
 	}
@@ -560,16 +579,33 @@ func (h *cryptoSetup) GetOpener(level protocol.EncryptionLevel) (Opener, error)
 			return nil, ErrOpenerNotYetAvailable
 		}
 		return h.handshakeOpener, nil
-	case protocol.Encryption1RTT:
-		if h.opener == nil {
-			return nil, ErrOpenerNotYetAvailable
-		}
-		return h.opener, nil
 	default:
 		return nil, fmt.Errorf("CryptoSetup: no opener with encryption level %s", level)
 	}
 }
 
+func (h *cryptoSetup) Get1RTTOpener() (ShortHeaderOpener, error) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if !h.has1RTTOpener {
+		return nil, ErrOpenerNotYetAvailable
+	}
+	return h.aead, nil
+}
+
+// SetLargestAcked is called with the largest acknowledged packet number of 1-RTT ACK frames.
+// It is used to determine when the next key update may be initiated.
+func (h *cryptoSetup) SetLargestAcked(pn protocol.PacketNumber) {
+	h.aead.SetLargestAcked(pn)
+}
+
+// SetHandshakeConfirmed is called when the handshake is confirmed.
+// Key updates are only initiated after that.
+func (h *cryptoSetup) SetHandshakeConfirmed() {
+	h.aead.SetHandshakeConfirmed()
+}
+
 func (h *cryptoSetup) ConnectionState() tls.ConnectionState {
 	cs := h.conn.ConnectionState()
 	// h.conn is a qtls.Conn, which returns a qtls.ConnectionState.
@@ -12,6 +12,7 @@ import (
 	"math/big"
 	"time"
 
+	"github.com/lucas-clemente/quic-go/internal/congestion"
 	"github.com/lucas-clemente/quic-go/internal/protocol"
 	"github.com/lucas-clemente/quic-go/internal/testdata"
 	"github.com/lucas-clemente/quic-go/internal/utils"
@@ -88,6 +89,9 @@ var _ = Describe("Crypto Setup TLS", func() {
 			&TransportParameters{},
 			func([]byte) {},
 			tlsConf,
+			&congestion.RTTStats{},
+			protocol.DefaultKeyUpdateInterval,
+			nil,
 			utils.DefaultLogger.WithPrefix("server"),
 		)
 		Expect(err).ToNot(HaveOccurred())
@@ -116,6 +120,9 @@ var _ = Describe("Crypto Setup TLS", func() {
 			&TransportParameters{},
 			func([]byte) {},
 			testdata.GetTLSConfig(),
+			&congestion.RTTStats{},
+			protocol.DefaultKeyUpdateInterval,
+			nil,
 			utils.DefaultLogger.WithPrefix("server"),
 		)
 		Expect(err).ToNot(HaveOccurred())
@@ -150,6 +157,9 @@ var _ = Describe("Crypto Setup TLS", func() {
 			&TransportParameters{},
 			func([]byte) {},
 			testdata.GetTLSConfig(),
+			&congestion.RTTStats{},
+			protocol.DefaultKeyUpdateInterval,
+			nil,
 			utils.DefaultLogger.WithPrefix("server"),
 		)
 		Expect(err).ToNot(HaveOccurred())
@@ -178,6 +188,9 @@ var _ = Describe("Crypto Setup TLS", func() {
 			&TransportParameters{},
 			func([]byte) {},
 			testdata.GetTLSConfig(),
+			&congestion.RTTStats{},
+			protocol.DefaultKeyUpdateInterval,
+			nil,
 			utils.DefaultLogger.WithPrefix("server"),
 		)
 		Expect(err).ToNot(HaveOccurred())
@@ -257,6 +270,9 @@ var _ = Describe("Crypto Setup TLS", func() {
 				&TransportParameters{},
 				func([]byte) {},
 				clientConf,
+				&congestion.RTTStats{},
+				protocol.DefaultKeyUpdateInterval,
+				nil,
 				utils.DefaultLogger.WithPrefix("client"),
 			)
 			Expect(err).ToNot(HaveOccurred())
@@ -272,6 +288,9 @@ var _ = Describe("Crypto Setup TLS", func() {
 				&TransportParameters{StatelessResetToken: &token},
 				func([]byte) {},
 				serverConf,
+				&congestion.RTTStats{},
+				protocol.DefaultKeyUpdateInterval,
+				nil,
 				utils.DefaultLogger.WithPrefix("server"),
 			)
 			Expect(err).ToNot(HaveOccurred())
@@ -314,6 +333,9 @@ var _ = Describe("Crypto Setup TLS", func() {
 				&TransportParameters{},
 				func([]byte) {},
 				&tls.Config{InsecureSkipVerify: true},
+				&congestion.RTTStats{},
+				protocol.DefaultKeyUpdateInterval,
+				nil,
 				utils.DefaultLogger.WithPrefix("client"),
 			)
 			Expect(err).ToNot(HaveOccurred())
@@ -351,6 +373,9 @@ var _ = Describe("Crypto Setup TLS", func() {
 				cTransportParameters,
 				func(p []byte) { sTransportParametersRcvd = p },
 				clientConf,
+				&congestion.RTTStats{},
+				protocol.DefaultKeyUpdateInterval,
+				nil,
 				utils.DefaultLogger.WithPrefix("client"),
 			)
 			Expect(err).ToNot(HaveOccurred())
@@ -370,6 +395,9 @@ var _ = Describe("Crypto Setup TLS", func() {
 				sTransportParameters,
 				func(p []byte) { cTransportParametersRcvd = p },
 				testdata.GetTLSConfig(),
+				&congestion.RTTStats{},
+				protocol.DefaultKeyUpdateInterval,
+				nil,
 				utils.DefaultLogger.WithPrefix("server"),
 			)
 			Expect(err).ToNot(HaveOccurred())
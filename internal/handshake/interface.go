@@ -4,6 +4,7 @@ import (
 	"crypto/tls"
 	"crypto/x509"
 	"io"
+	"time"
 
 	"github.com/lucas-clemente/quic-go/internal/protocol"
 	"github.com/marten-seemann/qtls"
@@ -15,6 +16,12 @@ type Opener interface {
 	DecryptHeader(sample []byte, firstByte *byte, pnBytes []byte)
 }
 
+// ShortHeaderOpener opens a short header packet
+type ShortHeaderOpener interface {
+	Open(dst, src []byte, rcvTime time.Time, packetNumber protocol.PacketNumber, kp protocol.KeyPhaseBit, associatedData []byte) ([]byte, error)
+	DecryptHeader(sample []byte, firstByte *byte, pnBytes []byte)
+}
+
 // Sealer seals a packet
 type Sealer interface {
 	Seal(dst, src []byte, packetNumber protocol.PacketNumber, associatedData []byte) []byte
@@ -22,6 +29,20 @@ type Sealer interface {
 	Overhead() int
 }
 
+// ShortHeaderSealer seals a short header packet
+type ShortHeaderSealer interface {
+	Sealer
+	// KeyPhase returns the key phase that the next packet will be sealed with.
+	KeyPhase() (protocol.KeyPhaseBit, error)
+}
+
+// A KeyTracer is notified when keys are installed or updated.
+// It is implemented by the logging.ConnectionTracer.
+type KeyTracer interface {
+	UpdatedKeyFromTLS(protocol.EncryptionLevel, protocol.Perspective)
+	UpdatedKey(generation protocol.KeyPhase, remote bool)
+}
+
 // A tlsExtensionHandler sends and received the QUIC TLS extension.
 type tlsExtensionHandler interface {
 	GetExtensions(msgType uint8) []qtls.Extension
@@ -34,6 +55,8 @@ type CryptoSetup interface {
 	RunHandshake() error
 	io.Closer
 	ChangeConnectionID(protocol.ConnectionID) error
+	SetLargestAcked(protocol.PacketNumber)
+	SetHandshakeConfirmed()
 
 	HandleMessage([]byte, protocol.EncryptionLevel) bool
 	ConnectionState() tls.ConnectionState
@@ -41,6 +64,7 @@ type CryptoSetup interface {
 	GetSealer() (protocol.EncryptionLevel, Sealer)
 	GetSealerWithEncryptionLevel(protocol.EncryptionLevel) (Sealer, error)
 	GetOpener(protocol.EncryptionLevel) (Opener, error)
+	Get1RTTOpener() (ShortHeaderOpener, error)
 }
 
 // ConnectionState records basic details about the QUIC connection.
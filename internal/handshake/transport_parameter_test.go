@@ -51,6 +51,22 @@ var _ = Describe("Transport Parameters", func() {
 		Expect(p.String()).To(Equal("&handshake.TransportParameters{OriginalConnectionID: 0xdeadbeef, InitialMaxStreamDataBidiLocal: 0x1234, InitialMaxStreamDataBidiRemote: 0x2345, InitialMaxStreamDataUni: 0x3456, InitialMaxData: 0x4567, MaxBidiStreams: 1337, MaxUniStreams: 7331, IdleTimeout: 42s, AckDelayExponent: 14}"))
 	})
 
+	It("has a string representation, if DATAGRAM frames are supported", func() {
+		p := &TransportParameters{
+			InitialMaxStreamDataBidiLocal:  0x1234,
+			InitialMaxStreamDataBidiRemote: 0x2345,
+			InitialMaxStreamDataUni:        0x3456,
+			InitialMaxData:                 0x4567,
+			MaxBidiStreams:                 1337,
+			MaxUniStreams:                  7331,
+			IdleTimeout:                    42 * time.Second,
+			OriginalConnectionID:           protocol.ConnectionID{0xde, 0xad, 0xbe, 0xef},
+			AckDelayExponent:               14,
+			MaxDatagramFrameSize:           1200,
+		}
+		Expect(p.String()).To(Equal("&handshake.TransportParameters{OriginalConnectionID: 0xdeadbeef, InitialMaxStreamDataBidiLocal: 0x1234, InitialMaxStreamDataBidiRemote: 0x2345, InitialMaxStreamDataUni: 0x3456, InitialMaxData: 0x4567, MaxBidiStreams: 1337, MaxUniStreams: 7331, IdleTimeout: 42s, AckDelayExponent: 14, MaxDatagramFrameSize: 1200}"))
+	})
+
 	getRandomValue := func() uint64 {
 		maxVals := []int64{math.MaxUint8 / 4, math.MaxUint16 / 4, math.MaxUint32 / 4, math.MaxUint64 / 4}
 		rand.Seed(GinkgoRandomSeed())
@@ -72,6 +88,8 @@ var _ = Describe("Transport Parameters", func() {
 			StatelessResetToken:            &token,
 			OriginalConnectionID:           protocol.ConnectionID{0xde, 0xad, 0xbe, 0xef},
 			AckDelayExponent:               13,
+			MaxPacketSize:                  protocol.MaxPacketSizePMTUD,
+			MaxDatagramFrameSize:           protocol.ByteCount(getRandomValue()),
 		}
 		data := params.Marshal()
 
@@ -88,6 +106,24 @@ var _ = Describe("Transport Parameters", func() {
 		Expect(p.StatelessResetToken).To(Equal(params.StatelessResetToken))
 		Expect(p.OriginalConnectionID).To(Equal(protocol.ConnectionID{0xde, 0xad, 0xbe, 0xef}))
 		Expect(p.AckDelayExponent).To(Equal(uint8(13)))
+		Expect(p.MaxPacketSize).To(Equal(protocol.MaxPacketSizePMTUD))
+		Expect(p.MaxDatagramFrameSize).To(Equal(params.MaxDatagramFrameSize))
+	})
+
+	It("sends the default max_packet_size, if none is set", func() {
+		data := (&TransportParameters{}).Marshal()
+		p := &TransportParameters{}
+		Expect(p.Unmarshal(data, protocol.PerspectiveServer)).To(Succeed())
+		Expect(p.MaxPacketSize).To(Equal(protocol.MaxReceivePacketSize))
+	})
+
+	It("doesn't send the max_datagram_frame_size, if DATAGRAM frames are not supported", func() {
+		data := (&TransportParameters{}).Marshal()
+		dataWithDatagrams := (&TransportParameters{MaxDatagramFrameSize: 1200}).Marshal()
+		Expect(len(dataWithDatagrams)).To(Equal(len(data) + 2 /* parameter ID */ + 2 /* length field */ + int(utils.VarIntLen(1200))))
+		p := &TransportParameters{}
+		Expect(p.Unmarshal(data, protocol.PerspectiveServer)).To(Succeed())
+		Expect(p.MaxDatagramFrameSize).To(BeZero())
 	})
 
 	It("errors if the transport parameters are too short to contain the length", func() {
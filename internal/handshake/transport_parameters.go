@@ -28,6 +28,7 @@ const (
 	initialMaxStreamsUniParameterID           transportParameterID = 0x9
 	ackDelayExponentParameterID               transportParameterID = 0xa
 	disableMigrationParameterID               transportParameterID = 0xc
+	maxDatagramFrameSizeParameterID           transportParameterID = 0x20
 )
 
 // TransportParameters are parameters sent to the peer during the handshake
@@ -49,6 +50,10 @@ type TransportParameters struct {
 
 	StatelessResetToken  *[16]byte
 	OriginalConnectionID protocol.ConnectionID
+
+	// MaxDatagramFrameSize is the maximum size of DATAGRAM frames that are accepted.
+	// 0 means that DATAGRAM frames are not supported.
+	MaxDatagramFrameSize protocol.ByteCount
 }
 
 // Unmarshal the transport parameters
@@ -83,7 +88,8 @@ func (p *TransportParameters) Unmarshal(data []byte, sentBy protocol.Perspective
 			initialMaxStreamsBidiParameterID,
 			initialMaxStreamsUniParameterID,
 			idleTimeoutParameterID,
-			maxPacketSizeParameterID:
+			maxPacketSizeParameterID,
+			maxDatagramFrameSizeParameterID:
 			if err := p.readNumericTransportParameter(r, paramID, int(paramLen)); err != nil {
 				return err
 			}
@@ -174,6 +180,8 @@ func (p *TransportParameters) readNumericTransportParameter(
 			return fmt.Errorf("invalid value for ack_delay_exponent: %d (maximum %d)", val, protocol.MaxAckDelayExponent)
 		}
 		p.AckDelayExponent = uint8(val)
+	case maxDatagramFrameSizeParameterID:
+		p.MaxDatagramFrameSize = protocol.ByteCount(val)
 	default:
 		return fmt.Errorf("TransportParameter BUG: transport parameter %d not found", paramID)
 	}
@@ -215,9 +223,13 @@ func (p *TransportParameters) Marshal() []byte {
 	utils.BigEndian.WriteUint16(b, uint16(utils.VarIntLen(idleTimeout)))
 	utils.WriteVarInt(b, idleTimeout)
 	// max_packet_size
+	maxPacketSize := p.MaxPacketSize
+	if maxPacketSize == 0 {
+		maxPacketSize = protocol.MaxReceivePacketSize
+	}
 	utils.BigEndian.WriteUint16(b, uint16(maxPacketSizeParameterID))
-	utils.BigEndian.WriteUint16(b, uint16(utils.VarIntLen(uint64(protocol.MaxReceivePacketSize))))
-	utils.WriteVarInt(b, uint64(protocol.MaxReceivePacketSize))
+	utils.BigEndian.WriteUint16(b, uint16(utils.VarIntLen(uint64(maxPacketSize))))
+	utils.WriteVarInt(b, uint64(maxPacketSize))
 	// ack_delay_exponent
 	// Only send it if is different from the default value.
 	if p.AckDelayExponent != protocol.DefaultAckDelayExponent {
@@ -241,6 +253,13 @@ func (p *TransportParameters) Marshal() []byte {
 		utils.BigEndian.WriteUint16(b, uint16(p.OriginalConnectionID.Len()))
 		b.Write(p.OriginalConnectionID.Bytes())
 	}
+	// max_datagram_frame_size
+	// Only send it if DATAGRAM frames are supported.
+	if p.MaxDatagramFrameSize > 0 {
+		utils.BigEndian.WriteUint16(b, uint16(maxDatagramFrameSizeParameterID))
+		utils.BigEndian.WriteUint16(b, uint16(utils.VarIntLen(uint64(p.MaxDatagramFrameSize))))
+		utils.WriteVarInt(b, uint64(p.MaxDatagramFrameSize))
+	}
 
 	data := b.Bytes()
 	binary.BigEndian.PutUint16(data[:2], uint16(b.Len()-2))
@@ -255,6 +274,10 @@ func (p *TransportParameters) String() string {
 		logString += ", StatelessResetToken: %#x"
 		logParams = append(logParams, *p.StatelessResetToken)
 	}
+	if p.MaxDatagramFrameSize > 0 {
+		logString += ", MaxDatagramFrameSize: %d"
+		logParams = append(logParams, p.MaxDatagramFrameSize)
+	}
 	logString += "}"
 	return fmt.Sprintf(logString, logParams...)
 }
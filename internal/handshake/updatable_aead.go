@@ -0,0 +1,360 @@
+package handshake
+
+import (
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lucas-clemente/quic-go/internal/congestion"
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+	"github.com/lucas-clemente/quic-go/internal/qerr"
+	"github.com/lucas-clemente/quic-go/internal/utils"
+	"github.com/marten-seemann/qtls"
+)
+
+// ErrDecryptionFailed is returned when the AEAD fails to open the packet.
+var ErrDecryptionFailed = errors.New("decryption failed")
+
+// ErrKeysDropped is returned when an opener or a sealer is requested for keys that were already dropped.
+var ErrKeysDropped = errors.New("encryption keys dropped")
+
+// The AEAD limits, as defined in section 6.6 of the QUIC-TLS draft.
+const (
+	// confidentialityLimitAESGCM is the number of packets that can be encrypted with a single AES-GCM key.
+	confidentialityLimitAESGCM = 1 << 23
+	// integrityLimitAESGCM is the number of packets that can fail authentication, when using AES-GCM.
+	integrityLimitAESGCM = 1 << 52
+	// confidentialityLimitChaCha20 is the number of packets that can be encrypted with a single ChaCha20-Poly1305 key.
+	// It's larger than the number of packets that can be sent on a single connection.
+	confidentialityLimitChaCha20 = 1 << 62
+	// integrityLimitChaCha20 is the number of packets that can fail authentication, when using ChaCha20-Poly1305.
+	integrityLimitChaCha20 = 1 << 36
+)
+
+// A cipherSuite is a TLS 1.3 cipher suite.
+// It is implemented by the qtls.CipherSuite.
+type cipherSuite interface {
+	Hash() crypto.Hash
+	KeyLen() int
+	IVLen() int
+	AEAD(key, fixedNonce []byte) cipher.AEAD
+}
+
+var _ cipherSuite = &qtls.CipherSuite{}
+
+// An updatableAEAD seals and opens 1-RTT packets.
+// It keeps track of the key phase, initiates key updates
+// and follows key updates initiated by the peer.
+type updatableAEAD struct {
+	suite cipherSuite
+
+	keyPhase           protocol.KeyPhase
+	largestAcked       protocol.PacketNumber
+	handshakeConfirmed bool
+	keyUpdateInterval  uint64
+
+	confidentialityLimit uint64
+	integrityLimit       uint64
+	// invalidPacketCount counts the packets that failed authentication, across all key phases
+	invalidPacketCount uint64
+
+	// Time when the keys of the previous key phase should be dropped.
+	// They are dropped on the next call to Open().
+	// If zero, the key update hasn't been confirmed by the peer yet.
+	prevRcvAEADExpiry time.Time
+	prevRcvAEAD       cipher.AEAD
+
+	// the lowest packet number received / sent using the keys of the current key phase
+	// only valid if haveRcvdWithCurrentKey / haveSentWithCurrentKey is set
+	firstRcvdWithCurrentKey protocol.PacketNumber
+	firstSentWithCurrentKey protocol.PacketNumber
+	haveRcvdWithCurrentKey  bool
+	haveSentWithCurrentKey  bool
+	numRcvdWithCurrentKey   uint64
+	numSentWithCurrentKey   uint64
+	rcvAEAD                 cipher.AEAD
+	sendAEAD                cipher.AEAD
+
+	nextRcvAEAD           cipher.AEAD
+	nextSendAEAD          cipher.AEAD
+	nextRcvTrafficSecret  []byte
+	nextSendTrafficSecret []byte
+
+	hpDecrypter cipher.Block
+	hpEncrypter cipher.Block
+
+	rttStats *congestion.RTTStats
+
+	tracer KeyTracer
+	logger utils.Logger
+
+	// use a single slice per direction to avoid allocations
+	// The read and the write keys are installed from the TLS go routine, so they use separate slices.
+	sealNonceBuf []byte
+	sealHPMask   []byte
+	openNonceBuf []byte
+	openHPMask   []byte
+}
+
+var _ ShortHeaderOpener = &updatableAEAD{}
+var _ ShortHeaderSealer = &updatableAEAD{}
+
+func newUpdatableAEAD(rttStats *congestion.RTTStats, keyUpdateInterval uint64, tracer KeyTracer, logger utils.Logger) *updatableAEAD {
+	return &updatableAEAD{
+		keyUpdateInterval: keyUpdateInterval,
+		rttStats:          rttStats,
+		tracer:            tracer,
+		logger:            logger,
+	}
+}
+
+func (a *updatableAEAD) rollKeys() {
+	a.keyPhase++
+	a.haveRcvdWithCurrentKey = false
+	a.haveSentWithCurrentKey = false
+	a.numRcvdWithCurrentKey = 0
+	a.numSentWithCurrentKey = 0
+	a.prevRcvAEAD = a.rcvAEAD
+	// The keys of the previous key phase are kept until the peer confirmed the key update.
+	a.prevRcvAEADExpiry = time.Time{}
+	a.rcvAEAD = a.nextRcvAEAD
+	a.sendAEAD = a.nextSendAEAD
+
+	a.nextRcvTrafficSecret = a.getNextTrafficSecret(a.suite.Hash(), a.nextRcvTrafficSecret)
+	a.nextSendTrafficSecret = a.getNextTrafficSecret(a.suite.Hash(), a.nextSendTrafficSecret)
+	a.nextRcvAEAD = createAEAD(a.suite, a.nextRcvTrafficSecret)
+	a.nextSendAEAD = createAEAD(a.suite, a.nextSendTrafficSecret)
+}
+
+// pto is the probe timeout, as used for dropping the keys of the previous key phase
+func (a *updatableAEAD) pto() time.Duration {
+	return a.rttStats.SmoothedOrInitialRTT() + 4*a.rttStats.MeanDeviation()
+}
+
+func (a *updatableAEAD) getNextTrafficSecret(hash crypto.Hash, ts []byte) []byte {
+	return qtls.HkdfExpandLabel(hash, ts, []byte{}, "traffic upd", hash.Size())
+}
+
+// SetReadKey sets the read key.
+// For the client, this function is called before SetWriteKey.
+// For the server, this function is called after SetWriteKey.
+func (a *updatableAEAD) SetReadKey(suite cipherSuite, trafficSecret []byte) {
+	a.rcvAEAD = createAEAD(suite, trafficSecret)
+	a.hpDecrypter = createHeaderProtector(suite, trafficSecret)
+	if a.suite == nil {
+		a.setAEADParameters(suite)
+	}
+
+	a.nextRcvTrafficSecret = a.getNextTrafficSecret(suite.Hash(), trafficSecret)
+	a.nextRcvAEAD = createAEAD(suite, a.nextRcvTrafficSecret)
+	a.openNonceBuf = make([]byte, a.rcvAEAD.NonceSize())
+	a.openHPMask = make([]byte, a.hpDecrypter.BlockSize())
+}
+
+// SetWriteKey sets the write key.
+// For the client, this function is called after SetReadKey.
+// For the server, this function is called before SetReadKey.
+func (a *updatableAEAD) SetWriteKey(suite cipherSuite, trafficSecret []byte) {
+	a.sendAEAD = createAEAD(suite, trafficSecret)
+	a.hpEncrypter = createHeaderProtector(suite, trafficSecret)
+	if a.suite == nil {
+		a.setAEADParameters(suite)
+	}
+
+	a.nextSendTrafficSecret = a.getNextTrafficSecret(suite.Hash(), trafficSecret)
+	a.nextSendAEAD = createAEAD(suite, a.nextSendTrafficSecret)
+	a.sealNonceBuf = make([]byte, a.sendAEAD.NonceSize())
+	a.sealHPMask = make([]byte, a.hpEncrypter.BlockSize())
+}
+
+func (a *updatableAEAD) setAEADParameters(suite cipherSuite) {
+	a.suite = suite
+	// qtls doesn't expose the cipher suite ID.
+	// TLS_CHACHA20_POLY1305_SHA256 is the only TLS 1.3 cipher suite using a 32 byte key with SHA-256.
+	if suite.KeyLen() == 32 && suite.Hash() == crypto.SHA256 {
+		a.confidentialityLimit = confidentialityLimitChaCha20
+		a.integrityLimit = integrityLimitChaCha20
+	} else {
+		a.confidentialityLimit = confidentialityLimitAESGCM
+		a.integrityLimit = integrityLimitAESGCM
+	}
+}
+
+func (a *updatableAEAD) Open(dst, src []byte, rcvTime time.Time, pn protocol.PacketNumber, kp protocol.KeyPhaseBit, ad []byte) ([]byte, error) {
+	dec, err := a.open(dst, src, rcvTime, pn, kp, ad)
+	if err == ErrDecryptionFailed {
+		a.invalidPacketCount++
+		if a.invalidPacketCount >= a.integrityLimit {
+			return nil, qerr.Error(qerr.AEADLimitReached, "integrity limit reached")
+		}
+	}
+	return dec, err
+}
+
+func (a *updatableAEAD) open(dst, src []byte, rcvTime time.Time, pn protocol.PacketNumber, kp protocol.KeyPhaseBit, ad []byte) ([]byte, error) {
+	if a.prevRcvAEAD != nil && !a.prevRcvAEADExpiry.IsZero() && rcvTime.After(a.prevRcvAEADExpiry) {
+		a.prevRcvAEAD = nil
+		a.logger.Debugf("Dropping key phase %d", a.keyPhase-1)
+	}
+	binary.BigEndian.PutUint64(a.openNonceBuf[len(a.openNonceBuf)-8:], uint64(pn))
+	if kp != a.keyPhase.Bit() {
+		if a.keyPhase > 0 && (!a.haveRcvdWithCurrentKey || pn < a.firstRcvdWithCurrentKey) {
+			// This packet was sent before the key update.
+			if a.prevRcvAEAD == nil {
+				return nil, ErrKeysDropped
+			}
+			dec, err := a.prevRcvAEAD.Open(dst, a.openNonceBuf, src, ad)
+			if err != nil {
+				return nil, ErrDecryptionFailed
+			}
+			return dec, nil
+		}
+		// try opening the packet with the keys of the next key phase
+		dec, err := a.nextRcvAEAD.Open(dst, a.openNonceBuf, src, ad)
+		if err != nil {
+			return nil, ErrDecryptionFailed
+		}
+		// Opening succeeded. Check if the peer was allowed to update.
+		if a.keyPhase > 0 && !a.haveSentWithCurrentKey {
+			return nil, qerr.Error(qerr.KeyUpdateError, "keys updated too quickly")
+		}
+		a.rollKeys()
+		a.logger.Debugf("Peer updated keys to %d", a.keyPhase)
+		if a.tracer != nil {
+			a.tracer.UpdatedKey(a.keyPhase, true)
+		}
+		// Keep the keys of the previous key phase for 3 PTOs, to be able to decrypt reordered packets.
+		a.prevRcvAEADExpiry = rcvTime.Add(3 * a.pto())
+		a.haveRcvdWithCurrentKey = true
+		a.firstRcvdWithCurrentKey = pn
+		a.numRcvdWithCurrentKey++
+		return dec, nil
+	}
+	dec, err := a.rcvAEAD.Open(dst, a.openNonceBuf, src, ad)
+	if err != nil {
+		return nil, ErrDecryptionFailed
+	}
+	a.numRcvdWithCurrentKey++
+	if !a.haveRcvdWithCurrentKey {
+		// We initiated the key update, and now we received the first packet protected with the new key phase.
+		// Therefore, we are certain that the peer rolled its keys as well.
+		// Keep the keys of the previous key phase for 3 PTOs, to be able to decrypt reordered packets.
+		if a.keyPhase > 0 {
+			a.logger.Debugf("Peer confirmed key update to phase %d", a.keyPhase)
+			a.prevRcvAEADExpiry = rcvTime.Add(3 * a.pto())
+		}
+		a.haveRcvdWithCurrentKey = true
+		a.firstRcvdWithCurrentKey = pn
+	}
+	return dec, nil
+}
+
+func (a *updatableAEAD) Seal(dst, src []byte, pn protocol.PacketNumber, ad []byte) []byte {
+	if !a.haveSentWithCurrentKey {
+		a.haveSentWithCurrentKey = true
+		a.firstSentWithCurrentKey = pn
+	}
+	a.numSentWithCurrentKey++
+	binary.BigEndian.PutUint64(a.sealNonceBuf[len(a.sealNonceBuf)-8:], uint64(pn))
+	// The AEAD we're using here will be the qtls.aeadAESGCM13.
+	// It uses the nonce provided here and XOR it with the IV.
+	return a.sendAEAD.Seal(dst, a.sealNonceBuf, src, ad)
+}
+
+// SetLargestAcked must be called with the largest acknowledged packet number of every ACK frame received.
+func (a *updatableAEAD) SetLargestAcked(pn protocol.PacketNumber) {
+	a.largestAcked = utils.MaxPacketNumber(a.largestAcked, pn)
+}
+
+// SetHandshakeConfirmed must be called when the handshake is confirmed.
+// Key updates are only initiated after that.
+func (a *updatableAEAD) SetHandshakeConfirmed() {
+	a.handshakeConfirmed = true
+}
+
+func (a *updatableAEAD) updateAllowed() bool {
+	if !a.handshakeConfirmed {
+		return false
+	}
+	// the first key update is allowed as soon as the handshake is confirmed
+	return a.keyPhase == 0 ||
+		// subsequent key updates as soon as a packet sent with that key phase has been acknowledged
+		(a.haveSentWithCurrentKey && a.largestAcked >= a.firstSentWithCurrentKey)
+}
+
+func (a *updatableAEAD) shouldInitiateKeyUpdate() bool {
+	if !a.updateAllowed() {
+		return false
+	}
+	if a.numRcvdWithCurrentKey >= a.keyUpdateInterval {
+		a.logger.Debugf("Received %d packets with current key phase. Initiating key update to the next key phase: %d", a.numRcvdWithCurrentKey, a.keyPhase+1)
+		return true
+	}
+	if a.numSentWithCurrentKey >= a.keyUpdateInterval || a.numSentWithCurrentKey >= a.confidentialityLimit {
+		a.logger.Debugf("Sent %d packets with current key phase. Initiating key update to the next key phase: %d", a.numSentWithCurrentKey, a.keyPhase+1)
+		return true
+	}
+	return false
+}
+
+// KeyPhase returns the key phase bit that the next packet will be sealed with.
+// It initiates a key update, if necessary.
+// If the confidentiality limit of the current key was reached and the key can't be updated yet,
+// it returns an AEAD_LIMIT_REACHED error.
+func (a *updatableAEAD) KeyPhase() (protocol.KeyPhaseBit, error) {
+	if a.shouldInitiateKeyUpdate() {
+		a.rollKeys()
+		if a.tracer != nil {
+			a.tracer.UpdatedKey(a.keyPhase, false)
+		}
+	}
+	if a.numSentWithCurrentKey >= a.confidentialityLimit {
+		return protocol.KeyPhaseZero, qerr.Error(qerr.AEADLimitReached, fmt.Sprintf("sent %d packets with key phase %d", a.numSentWithCurrentKey, a.keyPhase))
+	}
+	return a.keyPhase.Bit(), nil
+}
+
+func (a *updatableAEAD) Overhead() int {
+	return a.sendAEAD.Overhead()
+}
+
+func (a *updatableAEAD) EncryptHeader(sample []byte, firstByte *byte, pnBytes []byte) {
+	if len(sample) != a.hpEncrypter.BlockSize() {
+		panic("invalid sample size")
+	}
+	a.hpEncrypter.Encrypt(a.sealHPMask, sample)
+	*firstByte ^= a.sealHPMask[0] & 0x1f
+	for i := range pnBytes {
+		pnBytes[i] ^= a.sealHPMask[i+1]
+	}
+}
+
+func (a *updatableAEAD) DecryptHeader(sample []byte, firstByte *byte, pnBytes []byte) {
+	if len(sample) != a.hpDecrypter.BlockSize() {
+		panic("invalid sample size")
+	}
+	a.hpDecrypter.Encrypt(a.openHPMask, sample)
+	*firstByte ^= a.openHPMask[0] & 0x1f
+	for i := range pnBytes {
+		pnBytes[i] ^= a.openHPMask[i+1]
+	}
+}
+
+func createAEAD(suite cipherSuite, trafficSecret []byte) cipher.AEAD {
+	key := qtls.HkdfExpandLabel(suite.Hash(), trafficSecret, []byte{}, "quic key", suite.KeyLen())
+	iv := qtls.HkdfExpandLabel(suite.Hash(), trafficSecret, []byte{}, "quic iv", suite.IVLen())
+	return suite.AEAD(key, iv)
+}
+
+func createHeaderProtector(suite cipherSuite, trafficSecret []byte) cipher.Block {
+	hpKey := qtls.HkdfExpandLabel(suite.Hash(), trafficSecret, []byte{}, "quic hp", suite.KeyLen())
+	hp, err := aes.NewCipher(hpKey)
+	if err != nil {
+		panic(fmt.Sprintf("error creating new AES cipher: %s", err))
+	}
+	return hp
+}
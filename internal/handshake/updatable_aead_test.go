@@ -0,0 +1,316 @@
+package handshake
+
+import (
+	"crypto"
+	"crypto/cipher"
+	"crypto/rand"
+	"time"
+
+	"github.com/lucas-clemente/quic-go/internal/congestion"
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+	"github.com/lucas-clemente/quic-go/internal/qerr"
+	"github.com/lucas-clemente/quic-go/internal/utils"
+	"github.com/marten-seemann/qtls"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type mockCipherSuite struct{}
+
+var _ cipherSuite = &mockCipherSuite{}
+
+func (c *mockCipherSuite) Hash() crypto.Hash { return crypto.SHA256 }
+func (c *mockCipherSuite) KeyLen() int       { return 16 }
+func (c *mockCipherSuite) IVLen() int        { return 12 }
+func (c *mockCipherSuite) AEAD(key, fixedNonce []byte) cipher.AEAD {
+	return qtls.AEADAESGCMTLS13(key, fixedNonce)
+}
+
+type keyUpdate struct {
+	generation protocol.KeyPhase
+	remote     bool
+}
+
+// keyTracer records the key updates passed to it
+type keyTracer struct {
+	updates []keyUpdate
+}
+
+var _ KeyTracer = &keyTracer{}
+
+func (t *keyTracer) UpdatedKeyFromTLS(protocol.EncryptionLevel, protocol.Perspective) {}
+func (t *keyTracer) UpdatedKey(generation protocol.KeyPhase, remote bool) {
+	t.updates = append(t.updates, keyUpdate{generation: generation, remote: remote})
+}
+
+var _ = Describe("Updatable AEAD", func() {
+	var (
+		client, server *updatableAEAD
+		rttStats       *congestion.RTTStats
+	)
+
+	msg := []byte("Lorem ipsum dolor sit amet, consectetur adipiscing elit, sed do eiusmod tempor incididunt ut labore et dolore magna aliqua.")
+	ad := []byte("Donec in velit neque.")
+
+	BeforeEach(func() {
+		trafficSecret1 := make([]byte, 16)
+		trafficSecret2 := make([]byte, 16)
+		rand.Read(trafficSecret1)
+		rand.Read(trafficSecret2)
+
+		rttStats = &congestion.RTTStats{}
+		client = newUpdatableAEAD(rttStats, protocol.DefaultKeyUpdateInterval, nil, utils.DefaultLogger)
+		server = newUpdatableAEAD(rttStats, protocol.DefaultKeyUpdateInterval, nil, utils.DefaultLogger)
+		client.SetReadKey(&mockCipherSuite{}, trafficSecret2)
+		client.SetWriteKey(&mockCipherSuite{}, trafficSecret1)
+		server.SetReadKey(&mockCipherSuite{}, trafficSecret1)
+		server.SetWriteKey(&mockCipherSuite{}, trafficSecret2)
+	})
+
+	// sends a packet from the client to the server, using the key phase returned by the client
+	sendPacket := func(pn protocol.PacketNumber) ([]byte, protocol.KeyPhaseBit) {
+		kp, err := client.KeyPhase()
+		Expect(err).ToNot(HaveOccurred())
+		return client.Seal(nil, msg, pn, ad), kp
+	}
+
+	It("uses the AES-GCM limits", func() {
+		Expect(client.confidentialityLimit).To(BeEquivalentTo(confidentialityLimitAESGCM))
+		Expect(client.integrityLimit).To(BeEquivalentTo(integrityLimitAESGCM))
+	})
+
+	Context("message encryption", func() {
+		It("encrypts and decrypts a message", func() {
+			encrypted, kp := sendPacket(0x1337)
+			Expect(kp).To(Equal(protocol.KeyPhaseZero))
+			opened, err := server.Open(nil, encrypted, time.Now(), 0x1337, kp, ad)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(opened).To(Equal(msg))
+		})
+
+		It("fails to open a message if the associated data is not the same", func() {
+			encrypted, kp := sendPacket(0x1337)
+			_, err := server.Open(nil, encrypted, time.Now(), 0x1337, kp, []byte("wrong ad"))
+			Expect(err).To(MatchError(ErrDecryptionFailed))
+		})
+
+		It("fails to open a message if the packet number is not the same", func() {
+			encrypted, kp := sendPacket(0x1337)
+			_, err := server.Open(nil, encrypted, time.Now(), 0x42, kp, ad)
+			Expect(err).To(MatchError(ErrDecryptionFailed))
+		})
+
+		It("encrypts and decrypts the header", func() {
+			var lastFiveBitsDifferent int
+			for i := 0; i < 100; i++ {
+				sample := make([]byte, 16)
+				rand.Read(sample)
+				header := []byte{0xb5, 1, 2, 3, 4, 5, 6, 7, 8, 0xde, 0xad, 0xbe, 0xef}
+				client.EncryptHeader(sample, &header[0], header[9:13])
+				if header[0]&0x1f != 0xb5&0x1f {
+					lastFiveBitsDifferent++
+				}
+				Expect(header[0] & 0xe0).To(Equal(byte(0xb5 & 0xe0)))
+				Expect(header[1:9]).To(Equal([]byte{1, 2, 3, 4, 5, 6, 7, 8}))
+				Expect(header[9:13]).ToNot(Equal([]byte{0xde, 0xad, 0xbe, 0xef}))
+				server.DecryptHeader(sample, &header[0], header[9:13])
+				Expect(header).To(Equal([]byte{0xb5, 1, 2, 3, 4, 5, 6, 7, 8, 0xde, 0xad, 0xbe, 0xef}))
+			}
+			Expect(lastFiveBitsDifferent).To(BeNumerically(">", 75))
+		})
+	})
+
+	Context("key updates", func() {
+		const keyUpdateInterval = 20
+
+		BeforeEach(func() {
+			client.keyUpdateInterval = keyUpdateInterval
+			server.keyUpdateInterval = keyUpdateInterval
+		})
+
+		// sends packets from the client to the server, until the client initiates a key update
+		updateClientKeys := func(firstPN protocol.PacketNumber) protocol.PacketNumber {
+			pn := firstPN
+			for {
+				encrypted, kp := sendPacket(pn)
+				_, err := server.Open(nil, encrypted, time.Now(), pn, kp, ad)
+				Expect(err).ToNot(HaveOccurred())
+				pn++
+				if kp != client.keyPhase.Bit() || client.keyPhase != server.keyPhase {
+					Fail("client and server out of sync")
+				}
+				if client.keyPhase > 0 && client.numSentWithCurrentKey == 1 {
+					return pn
+				}
+				if pn > firstPN+2*keyUpdateInterval {
+					Fail("client didn't initiate a key update")
+				}
+			}
+		}
+
+		It("doesn't initiate a key update before the handshake is confirmed", func() {
+			for i := 0; i < 2*keyUpdateInterval; i++ {
+				_, kp := sendPacket(protocol.PacketNumber(i))
+				Expect(kp).To(Equal(protocol.KeyPhaseZero))
+			}
+		})
+
+		It("initiates a key update after sending the configured number of packets", func() {
+			client.SetHandshakeConfirmed()
+			for i := 0; i < keyUpdateInterval; i++ {
+				_, kp := sendPacket(protocol.PacketNumber(i))
+				Expect(kp).To(Equal(protocol.KeyPhaseZero))
+			}
+			encrypted, kp := sendPacket(keyUpdateInterval)
+			Expect(kp).To(Equal(protocol.KeyPhaseOne))
+			Expect(client.keyPhase).To(BeEquivalentTo(1))
+			// the server follows the key update
+			opened, err := server.Open(nil, encrypted, time.Now(), keyUpdateInterval, kp, ad)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(opened).To(Equal(msg))
+			Expect(server.keyPhase).To(BeEquivalentTo(1))
+		})
+
+		It("traces key updates", func() {
+			clientTracer := &keyTracer{}
+			serverTracer := &keyTracer{}
+			client.tracer = clientTracer
+			server.tracer = serverTracer
+			client.SetHandshakeConfirmed()
+			updateClientKeys(0)
+			Expect(clientTracer.updates).To(Equal([]keyUpdate{{generation: 1, remote: false}}))
+			Expect(serverTracer.updates).To(Equal([]keyUpdate{{generation: 1, remote: true}}))
+		})
+
+		It("initiates a key update after receiving the configured number of packets", func() {
+			server.SetHandshakeConfirmed()
+			for i := 0; i < keyUpdateInterval; i++ {
+				encrypted, kp := sendPacket(protocol.PacketNumber(i))
+				_, err := server.Open(nil, encrypted, time.Now(), protocol.PacketNumber(i), kp, ad)
+				Expect(err).ToNot(HaveOccurred())
+			}
+			kp, err := server.KeyPhase()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(kp).To(Equal(protocol.KeyPhaseOne))
+			// the client follows the key update
+			encrypted := server.Seal(nil, msg, 1, ad)
+			opened, err := client.Open(nil, encrypted, time.Now(), 1, kp, ad)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(opened).To(Equal(msg))
+			Expect(client.keyPhase).To(BeEquivalentTo(1))
+		})
+
+		It("only initiates the next key update after a packet sent with the current key phase was acknowledged", func() {
+			client.SetHandshakeConfirmed()
+			pn := updateClientKeys(0)
+			firstPNWithKeyPhaseOne := pn - 1
+			for i := 0; i < 2*keyUpdateInterval; i++ {
+				_, kp := sendPacket(pn)
+				Expect(kp).To(Equal(protocol.KeyPhaseOne))
+				pn++
+			}
+			client.SetLargestAcked(firstPNWithKeyPhaseOne - 1)
+			_, kp := sendPacket(pn)
+			Expect(kp).To(Equal(protocol.KeyPhaseOne))
+			pn++
+			client.SetLargestAcked(firstPNWithKeyPhaseOne)
+			_, kp = sendPacket(pn)
+			Expect(kp).To(Equal(protocol.KeyPhaseZero))
+			Expect(client.keyPhase).To(BeEquivalentTo(2))
+		})
+
+		It("opens reordered packets sent with the previous key phase", func() {
+			client.SetHandshakeConfirmed()
+			encrypted, kp := sendPacket(0)
+			Expect(kp).To(Equal(protocol.KeyPhaseZero))
+			pn := updateClientKeys(1)
+			Expect(server.keyPhase).To(BeEquivalentTo(1))
+			opened, err := server.Open(nil, encrypted, time.Now(), 0, kp, ad)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(opened).To(Equal(msg))
+			// packets with the current key phase can still be opened
+			encrypted, kp = sendPacket(pn)
+			Expect(kp).To(Equal(protocol.KeyPhaseOne))
+			_, err = server.Open(nil, encrypted, time.Now(), pn, kp, ad)
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("drops the keys of the previous key phase after 3 PTOs", func() {
+			rttStats.UpdateRTT(10*time.Millisecond, 0, time.Now())
+			pto := rttStats.SmoothedOrInitialRTT() + 4*rttStats.MeanDeviation()
+			client.SetHandshakeConfirmed()
+			encrypted, kp := sendPacket(0)
+			updateClientKeys(1)
+			_, err := server.Open(nil, encrypted, time.Now().Add(3*pto+time.Millisecond), 0, kp, ad)
+			Expect(err).To(MatchError(ErrKeysDropped))
+		})
+
+		It("keeps the keys of the previous key phase until the peer follows a key update", func() {
+			rttStats.UpdateRTT(10*time.Millisecond, 0, time.Now())
+			server.SetHandshakeConfirmed()
+			for i := 0; i < keyUpdateInterval; i++ {
+				encrypted, kp := sendPacket(protocol.PacketNumber(i))
+				_, err := server.Open(nil, encrypted, time.Now(), protocol.PacketNumber(i), kp, ad)
+				Expect(err).ToNot(HaveOccurred())
+			}
+			kp, err := server.KeyPhase()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(kp).To(Equal(protocol.KeyPhaseOne))
+			// the client hasn't received the server's packet yet, and continues using the old keys
+			encrypted, kp := sendPacket(100)
+			Expect(kp).To(Equal(protocol.KeyPhaseZero))
+			_, err = server.Open(nil, encrypted, time.Now().Add(time.Hour), 100, kp, ad)
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("errors when the peer updates keys too quickly", func() {
+			client.SetHandshakeConfirmed()
+			pn := updateClientKeys(0)
+			Expect(server.keyPhase).To(BeEquivalentTo(1))
+			// The server hasn't sent any packets with the new key phase.
+			// The client is not allowed to update the keys again.
+			client.rollKeys()
+			encrypted, kp := sendPacket(pn)
+			Expect(kp).To(Equal(protocol.KeyPhaseZero))
+			_, err := server.Open(nil, encrypted, time.Now(), pn, kp, ad)
+			Expect(err).To(HaveOccurred())
+			Expect(err.(*qerr.QuicError).ErrorCode).To(Equal(qerr.KeyUpdateError))
+		})
+	})
+
+	Context("AEAD limits", func() {
+		It("errors when the confidentiality limit is reached and the keys can't be updated", func() {
+			client.confidentialityLimit = 10
+			for i := 0; i < 10; i++ {
+				sendPacket(protocol.PacketNumber(i))
+			}
+			_, err := client.KeyPhase()
+			Expect(err).To(HaveOccurred())
+			Expect(err.(*qerr.QuicError).ErrorCode).To(Equal(qerr.AEADLimitReached))
+		})
+
+		It("updates the keys when the confidentiality limit is reached", func() {
+			client.confidentialityLimit = 10
+			client.SetHandshakeConfirmed()
+			for i := 0; i < 10; i++ {
+				sendPacket(protocol.PacketNumber(i))
+			}
+			kp, err := client.KeyPhase()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(kp).To(Equal(protocol.KeyPhaseOne))
+		})
+
+		It("errors when the integrity limit is reached", func() {
+			server.integrityLimit = 3
+			encrypted, kp := sendPacket(0)
+			for i := 0; i < 2; i++ {
+				_, err := server.Open(nil, encrypted, time.Now(), 0, kp, []byte("wrong ad"))
+				Expect(err).To(MatchError(ErrDecryptionFailed))
+			}
+			_, err := server.Open(nil, encrypted, time.Now(), 0, kp, []byte("wrong ad"))
+			Expect(err).To(HaveOccurred())
+			Expect(err.(*qerr.QuicError).ErrorCode).To(Equal(qerr.AEADLimitReached))
+		})
+	})
+})
@@ -1,5 +1,5 @@
 // Code generated by MockGen. DO NOT EDIT.
-// Source: github.com/lucas-clemente/quic-go/internal/congestion (interfaces: SendAlgorithm)
+// Source: github.com/lucas-clemente/quic-go/internal/congestion (interfaces: ExtendedSendAlgorithm)
 
 // Package mocks is a generated GoMock package.
 package mocks
@@ -12,31 +12,31 @@ import (
 	protocol "github.com/lucas-clemente/quic-go/internal/protocol"
 )
 
-// MockSendAlgorithm is a mock of SendAlgorithm interface
-type MockSendAlgorithm struct {
+// MockExtendedSendAlgorithm is a mock of ExtendedSendAlgorithm interface
+type MockExtendedSendAlgorithm struct {
 	ctrl     *gomock.Controller
-	recorder *MockSendAlgorithmMockRecorder
+	recorder *MockExtendedSendAlgorithmMockRecorder
 }
 
-// MockSendAlgorithmMockRecorder is the mock recorder for MockSendAlgorithm
-type MockSendAlgorithmMockRecorder struct {
-	mock *MockSendAlgorithm
+// MockExtendedSendAlgorithmMockRecorder is the mock recorder for MockExtendedSendAlgorithm
+type MockExtendedSendAlgorithmMockRecorder struct {
+	mock *MockExtendedSendAlgorithm
 }
 
-// NewMockSendAlgorithm creates a new mock instance
-func NewMockSendAlgorithm(ctrl *gomock.Controller) *MockSendAlgorithm {
-	mock := &MockSendAlgorithm{ctrl: ctrl}
-	mock.recorder = &MockSendAlgorithmMockRecorder{mock}
+// NewMockExtendedSendAlgorithm creates a new mock instance
+func NewMockExtendedSendAlgorithm(ctrl *gomock.Controller) *MockExtendedSendAlgorithm {
+	mock := &MockExtendedSendAlgorithm{ctrl: ctrl}
+	mock.recorder = &MockExtendedSendAlgorithmMockRecorder{mock}
 	return mock
 }
 
 // EXPECT returns an object that allows the caller to indicate expected use
-func (m *MockSendAlgorithm) EXPECT() *MockSendAlgorithmMockRecorder {
+func (m *MockExtendedSendAlgorithm) EXPECT() *MockExtendedSendAlgorithmMockRecorder {
 	return m.recorder
 }
 
 // GetCongestionWindow mocks base method
-func (m *MockSendAlgorithm) GetCongestionWindow() protocol.ByteCount {
+func (m *MockExtendedSendAlgorithm) GetCongestionWindow() protocol.ByteCount {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "GetCongestionWindow")
 	ret0, _ := ret[0].(protocol.ByteCount)
@@ -44,109 +44,109 @@ func (m *MockSendAlgorithm) GetCongestionWindow() protocol.ByteCount {
 }
 
 // GetCongestionWindow indicates an expected call of GetCongestionWindow
-func (mr *MockSendAlgorithmMockRecorder) GetCongestionWindow() *gomock.Call {
+func (mr *MockExtendedSendAlgorithmMockRecorder) GetCongestionWindow() *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCongestionWindow", reflect.TypeOf((*MockSendAlgorithm)(nil).GetCongestionWindow))
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCongestionWindow", reflect.TypeOf((*MockExtendedSendAlgorithm)(nil).GetCongestionWindow))
 }
 
 // MaybeExitSlowStart mocks base method
-func (m *MockSendAlgorithm) MaybeExitSlowStart() {
+func (m *MockExtendedSendAlgorithm) MaybeExitSlowStart() {
 	m.ctrl.T.Helper()
 	m.ctrl.Call(m, "MaybeExitSlowStart")
 }
 
 // MaybeExitSlowStart indicates an expected call of MaybeExitSlowStart
-func (mr *MockSendAlgorithmMockRecorder) MaybeExitSlowStart() *gomock.Call {
+func (mr *MockExtendedSendAlgorithmMockRecorder) MaybeExitSlowStart() *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MaybeExitSlowStart", reflect.TypeOf((*MockSendAlgorithm)(nil).MaybeExitSlowStart))
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MaybeExitSlowStart", reflect.TypeOf((*MockExtendedSendAlgorithm)(nil).MaybeExitSlowStart))
 }
 
 // OnConnectionMigration mocks base method
-func (m *MockSendAlgorithm) OnConnectionMigration() {
+func (m *MockExtendedSendAlgorithm) OnConnectionMigration() {
 	m.ctrl.T.Helper()
 	m.ctrl.Call(m, "OnConnectionMigration")
 }
 
 // OnConnectionMigration indicates an expected call of OnConnectionMigration
-func (mr *MockSendAlgorithmMockRecorder) OnConnectionMigration() *gomock.Call {
+func (mr *MockExtendedSendAlgorithmMockRecorder) OnConnectionMigration() *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "OnConnectionMigration", reflect.TypeOf((*MockSendAlgorithm)(nil).OnConnectionMigration))
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "OnConnectionMigration", reflect.TypeOf((*MockExtendedSendAlgorithm)(nil).OnConnectionMigration))
 }
 
 // OnPacketAcked mocks base method
-func (m *MockSendAlgorithm) OnPacketAcked(arg0 protocol.PacketNumber, arg1, arg2 protocol.ByteCount, arg3 time.Time) {
+func (m *MockExtendedSendAlgorithm) OnPacketAcked(arg0 protocol.PacketNumber, arg1, arg2 protocol.ByteCount, arg3 time.Time) {
 	m.ctrl.T.Helper()
 	m.ctrl.Call(m, "OnPacketAcked", arg0, arg1, arg2, arg3)
 }
 
 // OnPacketAcked indicates an expected call of OnPacketAcked
-func (mr *MockSendAlgorithmMockRecorder) OnPacketAcked(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+func (mr *MockExtendedSendAlgorithmMockRecorder) OnPacketAcked(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "OnPacketAcked", reflect.TypeOf((*MockSendAlgorithm)(nil).OnPacketAcked), arg0, arg1, arg2, arg3)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "OnPacketAcked", reflect.TypeOf((*MockExtendedSendAlgorithm)(nil).OnPacketAcked), arg0, arg1, arg2, arg3)
 }
 
 // OnPacketLost mocks base method
-func (m *MockSendAlgorithm) OnPacketLost(arg0 protocol.PacketNumber, arg1, arg2 protocol.ByteCount) {
+func (m *MockExtendedSendAlgorithm) OnPacketLost(arg0 protocol.PacketNumber, arg1, arg2 protocol.ByteCount) {
 	m.ctrl.T.Helper()
 	m.ctrl.Call(m, "OnPacketLost", arg0, arg1, arg2)
 }
 
 // OnPacketLost indicates an expected call of OnPacketLost
-func (mr *MockSendAlgorithmMockRecorder) OnPacketLost(arg0, arg1, arg2 interface{}) *gomock.Call {
+func (mr *MockExtendedSendAlgorithmMockRecorder) OnPacketLost(arg0, arg1, arg2 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "OnPacketLost", reflect.TypeOf((*MockSendAlgorithm)(nil).OnPacketLost), arg0, arg1, arg2)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "OnPacketLost", reflect.TypeOf((*MockExtendedSendAlgorithm)(nil).OnPacketLost), arg0, arg1, arg2)
 }
 
 // OnPacketSent mocks base method
-func (m *MockSendAlgorithm) OnPacketSent(arg0 time.Time, arg1 protocol.ByteCount, arg2 protocol.PacketNumber, arg3 protocol.ByteCount, arg4 bool) {
+func (m *MockExtendedSendAlgorithm) OnPacketSent(arg0 time.Time, arg1 protocol.ByteCount, arg2 protocol.PacketNumber, arg3 protocol.ByteCount, arg4 bool) {
 	m.ctrl.T.Helper()
 	m.ctrl.Call(m, "OnPacketSent", arg0, arg1, arg2, arg3, arg4)
 }
 
 // OnPacketSent indicates an expected call of OnPacketSent
-func (mr *MockSendAlgorithmMockRecorder) OnPacketSent(arg0, arg1, arg2, arg3, arg4 interface{}) *gomock.Call {
+func (mr *MockExtendedSendAlgorithmMockRecorder) OnPacketSent(arg0, arg1, arg2, arg3, arg4 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "OnPacketSent", reflect.TypeOf((*MockSendAlgorithm)(nil).OnPacketSent), arg0, arg1, arg2, arg3, arg4)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "OnPacketSent", reflect.TypeOf((*MockExtendedSendAlgorithm)(nil).OnPacketSent), arg0, arg1, arg2, arg3, arg4)
 }
 
 // OnRetransmissionTimeout mocks base method
-func (m *MockSendAlgorithm) OnRetransmissionTimeout(arg0 bool) {
+func (m *MockExtendedSendAlgorithm) OnRetransmissionTimeout(arg0 bool) {
 	m.ctrl.T.Helper()
 	m.ctrl.Call(m, "OnRetransmissionTimeout", arg0)
 }
 
 // OnRetransmissionTimeout indicates an expected call of OnRetransmissionTimeout
-func (mr *MockSendAlgorithmMockRecorder) OnRetransmissionTimeout(arg0 interface{}) *gomock.Call {
+func (mr *MockExtendedSendAlgorithmMockRecorder) OnRetransmissionTimeout(arg0 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "OnRetransmissionTimeout", reflect.TypeOf((*MockSendAlgorithm)(nil).OnRetransmissionTimeout), arg0)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "OnRetransmissionTimeout", reflect.TypeOf((*MockExtendedSendAlgorithm)(nil).OnRetransmissionTimeout), arg0)
 }
 
 // SetNumEmulatedConnections mocks base method
-func (m *MockSendAlgorithm) SetNumEmulatedConnections(arg0 int) {
+func (m *MockExtendedSendAlgorithm) SetNumEmulatedConnections(arg0 int) {
 	m.ctrl.T.Helper()
 	m.ctrl.Call(m, "SetNumEmulatedConnections", arg0)
 }
 
 // SetNumEmulatedConnections indicates an expected call of SetNumEmulatedConnections
-func (mr *MockSendAlgorithmMockRecorder) SetNumEmulatedConnections(arg0 interface{}) *gomock.Call {
+func (mr *MockExtendedSendAlgorithmMockRecorder) SetNumEmulatedConnections(arg0 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetNumEmulatedConnections", reflect.TypeOf((*MockSendAlgorithm)(nil).SetNumEmulatedConnections), arg0)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetNumEmulatedConnections", reflect.TypeOf((*MockExtendedSendAlgorithm)(nil).SetNumEmulatedConnections), arg0)
 }
 
 // SetSlowStartLargeReduction mocks base method
-func (m *MockSendAlgorithm) SetSlowStartLargeReduction(arg0 bool) {
+func (m *MockExtendedSendAlgorithm) SetSlowStartLargeReduction(arg0 bool) {
 	m.ctrl.T.Helper()
 	m.ctrl.Call(m, "SetSlowStartLargeReduction", arg0)
 }
 
 // SetSlowStartLargeReduction indicates an expected call of SetSlowStartLargeReduction
-func (mr *MockSendAlgorithmMockRecorder) SetSlowStartLargeReduction(arg0 interface{}) *gomock.Call {
+func (mr *MockExtendedSendAlgorithmMockRecorder) SetSlowStartLargeReduction(arg0 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetSlowStartLargeReduction", reflect.TypeOf((*MockSendAlgorithm)(nil).SetSlowStartLargeReduction), arg0)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetSlowStartLargeReduction", reflect.TypeOf((*MockExtendedSendAlgorithm)(nil).SetSlowStartLargeReduction), arg0)
 }
 
 // TimeUntilSend mocks base method
-func (m *MockSendAlgorithm) TimeUntilSend(arg0 protocol.ByteCount) time.Duration {
+func (m *MockExtendedSendAlgorithm) TimeUntilSend(arg0 protocol.ByteCount) time.Duration {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "TimeUntilSend", arg0)
 	ret0, _ := ret[0].(time.Duration)
@@ -154,7 +154,7 @@ func (m *MockSendAlgorithm) TimeUntilSend(arg0 protocol.ByteCount) time.Duration
 }
 
 // TimeUntilSend indicates an expected call of TimeUntilSend
-func (mr *MockSendAlgorithmMockRecorder) TimeUntilSend(arg0 interface{}) *gomock.Call {
+func (mr *MockExtendedSendAlgorithmMockRecorder) TimeUntilSend(arg0 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TimeUntilSend", reflect.TypeOf((*MockSendAlgorithm)(nil).TimeUntilSend), arg0)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TimeUntilSend", reflect.TypeOf((*MockExtendedSendAlgorithm)(nil).TimeUntilSend), arg0)
 }
@@ -138,6 +138,20 @@ func (mr *MockSessionMockRecorder) LocalAddr() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LocalAddr", reflect.TypeOf((*MockSession)(nil).LocalAddr))
 }
 
+// MigrateConn mocks base method
+func (m *MockSession) MigrateConn(arg0 net.PacketConn) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "MigrateConn", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// MigrateConn indicates an expected call of MigrateConn
+func (mr *MockSessionMockRecorder) MigrateConn(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MigrateConn", reflect.TypeOf((*MockSession)(nil).MigrateConn), arg0)
+}
+
 // OpenStream mocks base method
 func (m *MockSession) OpenStream() (quic_go.Stream, error) {
 	m.ctrl.T.Helper()
@@ -198,6 +212,21 @@ func (mr *MockSessionMockRecorder) OpenUniStreamSync() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "OpenUniStreamSync", reflect.TypeOf((*MockSession)(nil).OpenUniStreamSync))
 }
 
+// ReceiveMessage mocks base method
+func (m *MockSession) ReceiveMessage() ([]byte, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReceiveMessage")
+	ret0, _ := ret[0].([]byte)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ReceiveMessage indicates an expected call of ReceiveMessage
+func (mr *MockSessionMockRecorder) ReceiveMessage() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReceiveMessage", reflect.TypeOf((*MockSession)(nil).ReceiveMessage))
+}
+
 // RemoteAddr mocks base method
 func (m *MockSession) RemoteAddr() net.Addr {
 	m.ctrl.T.Helper()
@@ -211,3 +240,45 @@ func (mr *MockSessionMockRecorder) RemoteAddr() *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoteAddr", reflect.TypeOf((*MockSession)(nil).RemoteAddr))
 }
+
+// SendMessage mocks base method
+func (m *MockSession) SendMessage(arg0 []byte) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SendMessage", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SendMessage indicates an expected call of SendMessage
+func (mr *MockSessionMockRecorder) SendMessage(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SendMessage", reflect.TypeOf((*MockSession)(nil).SendMessage), arg0)
+}
+
+// SendMessageWithCallback mocks base method
+func (m *MockSession) SendMessageWithCallback(arg0 []byte, arg1 func(bool)) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SendMessageWithCallback", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SendMessageWithCallback indicates an expected call of SendMessageWithCallback
+func (mr *MockSessionMockRecorder) SendMessageWithCallback(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SendMessageWithCallback", reflect.TypeOf((*MockSession)(nil).SendMessageWithCallback), arg0, arg1)
+}
+
+// Stats mocks base method
+func (m *MockSession) Stats() quic_go.ConnectionStats {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Stats")
+	ret0, _ := ret[0].(quic_go.ConnectionStats)
+	return ret0
+}
+
+// Stats indicates an expected call of Stats
+func (mr *MockSessionMockRecorder) Stats() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Stats", reflect.TypeOf((*MockSession)(nil).Stats))
+}
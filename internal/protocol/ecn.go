@@ -0,0 +1,27 @@
+package protocol
+
+// ECN is the ECN codepoint of an IP packet, as defined in RFC 3168.
+type ECN uint8
+
+// the ECN codepoints
+const (
+	ECNNon ECN = iota // 00: not ECN-capable transport
+	ECT1              // 01: ECN-capable transport, ECT(1)
+	ECT0              // 10: ECN-capable transport, ECT(0)
+	ECNCE             // 11: congestion experienced
+)
+
+func (e ECN) String() string {
+	switch e {
+	case ECNNon:
+		return "Not-ECT"
+	case ECT1:
+		return "ECT(1)"
+	case ECT0:
+		return "ECT(0)"
+	case ECNCE:
+		return "CE"
+	default:
+		return "invalid ECN value"
+	}
+}
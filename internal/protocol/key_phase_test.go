@@ -0,0 +1,21 @@
+package protocol
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Key Phases", func() {
+	It("has a string representation", func() {
+		Expect(KeyPhaseZero.String()).To(Equal("0"))
+		Expect(KeyPhaseOne.String()).To(Equal("1"))
+		Expect(KeyPhaseBit(42).String()).To(Equal("undefined"))
+	})
+
+	It("converts the key phase to the key phase bit", func() {
+		Expect(KeyPhase(0).Bit()).To(Equal(KeyPhaseZero))
+		Expect(KeyPhase(1).Bit()).To(Equal(KeyPhaseOne))
+		Expect(KeyPhase(2).Bit()).To(Equal(KeyPhaseZero))
+		Expect(KeyPhase(3).Bit()).To(Equal(KeyPhaseOne))
+	})
+})
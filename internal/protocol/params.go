@@ -98,6 +98,22 @@ const DefaultHandshakeTimeout = 10 * time.Second
 // after this time all information about the old connection will be deleted
 const RetiredConnectionIDDeleteTimeout = 5 * time.Second
 
+// MinPathValidationTimeout is the minimum time we wait for a PATH_RESPONSE, before considering path validation failed.
+const MinPathValidationTimeout = time.Second
+
+// AmplificationFactor is the maximum ratio of bytes sent to bytes received from a peer address that hasn't been validated yet.
+const AmplificationFactor = 3
+
+// MaxPeerConnectionIDs is the maximum number of unused connection IDs issued by the peer that we store.
+const MaxPeerConnectionIDs = 8
+
+// MaxActiveConnectionIDs is the number of connection IDs (including the initial one) that we issue to the peer.
+const MaxActiveConnectionIDs = 4
+
+// PacketsPerConnectionID is the number of packets we send using one connection ID.
+// After that, we switch to a new connection ID issued by the peer, if one is available.
+const PacketsPerConnectionID = 10000
+
 // MinStreamFrameSize is the minimum size that has to be left in a packet, so that we add another STREAM frame.
 // This avoids splitting up STREAM frames into small pieces, which has 2 advantages:
 // 1. it reduces the framing overhead
@@ -125,3 +141,24 @@ const DefaultConnectionIDLength = 4
 
 // AckDelayExponent is the ack delay exponent used when sending ACKs.
 const AckDelayExponent = 3
+
+// DefaultKeyUpdateInterval is the number of packets that are sent or received with the same 1-RTT key,
+// before a key update is initiated.
+const DefaultKeyUpdateInterval = 100 * 1000
+
+// MaxDatagramFrameSize is the maximum size of DATAGRAM frames that we accept, and that we send.
+// It is chosen such that a DATAGRAM frame always fits into a packet of the minimum packet size,
+// even when using the maximum length connection ID.
+const MaxDatagramFrameSize ByteCount = 1150
+
+// DatagramSendQueueLen is the maximum number of datagrams that are queued for sending.
+// When this limit is reached, sending of datagrams blocks until a packet containing a datagram was sent.
+const DatagramSendQueueLen = 16
+
+// DatagramRcvQueueLen is the maximum number of received datagrams that are queued until the application reads them.
+// Datagrams received when the queue is full are dropped.
+const DatagramRcvQueueLen = 128
+
+// MaxBatchSize is the maximum number of packets that are read with a single system call, if batched I/O is enabled.
+// Every packet uses a buffer of MaxReceivePacketSize bytes (MaxPacketSizePMTUD bytes, if Path MTU Discovery is enabled).
+const MaxBatchSize = 8
@@ -51,6 +51,11 @@ type ApplicationErrorCode uint16
 // Ethernet's max packet size is 1500 bytes,  1500 - 48 = 1452.
 const MaxReceivePacketSize ByteCount = 1452
 
+// MaxPacketSizePMTUD is the maximum packet size used when Path MTU Discovery is enabled.
+// It is based on the max size of an ethernet jumbo frame (9000 bytes), minus the IP and UDP headers.
+// It is the largest packet size that is probed for, and the max_packet_size that is advertised to the peer.
+const MaxPacketSizePMTUD ByteCount = 8952
+
 // DefaultTCPMSS is the default maximum packet size used in the Linux TCP implementation.
 // Used in QUIC for congestion window computations in bytes.
 const DefaultTCPMSS ByteCount = 1460
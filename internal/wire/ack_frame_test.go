@@ -161,6 +161,9 @@ var _ = Describe("ACK Frame (for IETF QUIC)", func() {
 				Expect(frame.LargestAcked()).To(Equal(protocol.PacketNumber(100)))
 				Expect(frame.LowestAcked()).To(Equal(protocol.PacketNumber(90)))
 				Expect(frame.HasMissingRanges()).To(BeFalse())
+				Expect(frame.ECT0).To(BeEquivalentTo(0x42))
+				Expect(frame.ECT1).To(BeEquivalentTo(0x12345))
+				Expect(frame.ECNCE).To(BeEquivalentTo(0x12345678))
 				Expect(b.Len()).To(BeZero())
 			})
 
@@ -202,6 +205,32 @@ var _ = Describe("ACK Frame (for IETF QUIC)", func() {
 			Expect(buf.Bytes()).To(Equal(expected))
 		})
 
+		It("writes an ACK_ECN frame", func() {
+			buf := &bytes.Buffer{}
+			f := &AckFrame{
+				AckRanges: []AckRange{{Smallest: 10, Largest: 2000}},
+				ECT0:      13,
+				ECT1:      37,
+				ECNCE:     12345,
+			}
+			Expect(f.Write(buf, versionIETFFrames)).To(Succeed())
+			Expect(f.Length(versionIETFFrames)).To(BeEquivalentTo(buf.Len()))
+			expected := []byte{0x3}
+			expected = append(expected, encodeVarInt(2000)...) // largest acked
+			expected = append(expected, 0)                     // delay
+			expected = append(expected, encodeVarInt(0)...)    // num ranges
+			expected = append(expected, encodeVarInt(2000-10)...)
+			expected = append(expected, encodeVarInt(13)...)    // ECT(0)
+			expected = append(expected, encodeVarInt(37)...)    // ECT(1)
+			expected = append(expected, encodeVarInt(12345)...) // ECN-CE
+			Expect(buf.Bytes()).To(Equal(expected))
+			b := bytes.NewReader(buf.Bytes())
+			frame, err := parseAckFrame(b, protocol.AckDelayExponent, versionIETFFrames)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(frame).To(Equal(f))
+			Expect(b.Len()).To(BeZero())
+		})
+
 		It("writes a frame that acks a single packet", func() {
 			buf := &bytes.Buffer{}
 			f := &AckFrame{
@@ -300,6 +329,30 @@ var _ = Describe("ACK Frame (for IETF QUIC)", func() {
 			Expect(b.Len()).To(BeZero())
 			Expect(len(frame.AckRanges)).To(BeNumerically("<", numRanges)) // make sure we dropped some ranges
 		})
+
+		It("limits the maximum size of the ACK_ECN frame", func() {
+			buf := &bytes.Buffer{}
+			const numRanges = 1000
+			ackRanges := make([]AckRange, numRanges)
+			for i := protocol.PacketNumber(1); i <= numRanges; i++ {
+				ackRanges[numRanges-i] = AckRange{Smallest: 2 * i, Largest: 2 * i}
+			}
+			f := &AckFrame{
+				AckRanges: ackRanges,
+				ECT0:      1 << 40,
+				ECT1:      1 << 40,
+				ECNCE:     1 << 40,
+			}
+			Expect(f.validateAckRanges()).To(BeTrue())
+			Expect(f.Write(buf, versionIETFFrames)).To(Succeed())
+			Expect(f.Length(versionIETFFrames)).To(BeEquivalentTo(buf.Len()))
+			Expect(buf.Len()).To(BeNumerically(">", protocol.MaxAckFrameSize-5))
+			Expect(buf.Len()).To(BeNumerically("<=", protocol.MaxAckFrameSize))
+			frame, err := parseAckFrame(bytes.NewReader(buf.Bytes()), protocol.AckDelayExponent, versionIETFFrames)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(frame.ECT0).To(Equal(f.ECT0))
+			Expect(frame.ECNCE).To(Equal(f.ECNCE))
+		})
 	})
 
 	Context("ACK range validator", func() {
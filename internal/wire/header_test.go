@@ -425,7 +425,7 @@ var _ = Describe("Header Parsing", func() {
 			b := bytes.NewReader(data)
 			extHdr, err := hdr.ParseExtended(b, versionIETFFrames)
 			Expect(err).ToNot(HaveOccurred())
-			Expect(extHdr.KeyPhase).To(Equal(0))
+			Expect(extHdr.KeyPhase).To(Equal(protocol.KeyPhaseZero))
 			Expect(extHdr.DestConnectionID).To(Equal(connID))
 			Expect(extHdr.SrcConnectionID).To(BeEmpty())
 			Expect(extHdr.PacketNumber).To(Equal(protocol.PacketNumber(0x42)))
@@ -462,7 +462,7 @@ var _ = Describe("Header Parsing", func() {
 			b := bytes.NewReader(data)
 			extHdr, err := hdr.ParseExtended(b, versionIETFFrames)
 			Expect(err).ToNot(HaveOccurred())
-			Expect(extHdr.KeyPhase).To(Equal(0))
+			Expect(extHdr.KeyPhase).To(Equal(protocol.KeyPhaseZero))
 			Expect(extHdr.DestConnectionID).To(Equal(connID))
 			Expect(extHdr.SrcConnectionID).To(BeEmpty())
 			Expect(rest).To(BeEmpty())
@@ -480,7 +480,7 @@ var _ = Describe("Header Parsing", func() {
 			b := bytes.NewReader(data)
 			extHdr, err := hdr.ParseExtended(b, versionIETFFrames)
 			Expect(err).ToNot(HaveOccurred())
-			Expect(extHdr.KeyPhase).To(Equal(1))
+			Expect(extHdr.KeyPhase).To(Equal(protocol.KeyPhaseOne))
 			Expect(b.Len()).To(BeZero())
 		})
 
@@ -24,19 +24,25 @@ func LogFrame(logger utils.Logger, frame Frame, sent bool) {
 	case *StreamFrame:
 		logger.Debugf("\t%s &wire.StreamFrame{StreamID: %d, FinBit: %t, Offset: 0x%x, Data length: 0x%x, Offset + Data length: 0x%x}", dir, f.StreamID, f.FinBit, f.Offset, f.DataLen(), f.Offset+f.DataLen())
 	case *AckFrame:
+		var ecn string
+		if f.hasECN() {
+			ecn = fmt.Sprintf(", ECT0: %d, ECT1: %d, ECNCE: %d", f.ECT0, f.ECT1, f.ECNCE)
+		}
 		if len(f.AckRanges) > 1 {
 			ackRanges := make([]string, len(f.AckRanges))
 			for i, r := range f.AckRanges {
 				ackRanges[i] = fmt.Sprintf("{Largest: %#x, Smallest: %#x}", r.Largest, r.Smallest)
 			}
-			logger.Debugf("\t%s &wire.AckFrame{LargestAcked: %#x, LowestAcked: %#x, AckRanges: {%s}, DelayTime: %s}", dir, f.LargestAcked(), f.LowestAcked(), strings.Join(ackRanges, ", "), f.DelayTime.String())
+			logger.Debugf("\t%s &wire.AckFrame{LargestAcked: %#x, LowestAcked: %#x, AckRanges: {%s}, DelayTime: %s%s}", dir, f.LargestAcked(), f.LowestAcked(), strings.Join(ackRanges, ", "), f.DelayTime.String(), ecn)
 		} else {
-			logger.Debugf("\t%s &wire.AckFrame{LargestAcked: %#x, LowestAcked: %#x, DelayTime: %s}", dir, f.LargestAcked(), f.LowestAcked(), f.DelayTime.String())
+			logger.Debugf("\t%s &wire.AckFrame{LargestAcked: %#x, LowestAcked: %#x, DelayTime: %s%s}", dir, f.LargestAcked(), f.LowestAcked(), f.DelayTime.String(), ecn)
 		}
 	case *NewConnectionIDFrame:
 		logger.Debugf("\t%s &wire.NewConnectionIDFrame{SequenceNumber: %d, ConnectionID: %s, StatelessResetToken: %#x}", dir, f.SequenceNumber, f.ConnectionID, f.StatelessResetToken)
 	case *NewTokenFrame:
 		logger.Debugf("\t%s &wire.NewTokenFrame{Token: %#x}", dir, f.Token)
+	case *DatagramFrame:
+		logger.Debugf("\t%s &wire.DatagramFrame{DataLenPresent: %t, Data length: 0x%x}", dir, f.DataLenPresent, len(f.Data))
 	default:
 		logger.Debugf("\t%s %#v", dir, frame)
 	}
@@ -87,6 +87,18 @@ var _ = Describe("Frame logging", func() {
 		Expect(buf.String()).To(ContainSubstring("\t<- &wire.AckFrame{LargestAcked: 0x8, LowestAcked: 0x2, AckRanges: {{Largest: 0x8, Smallest: 0x5}, {Largest: 0x3, Smallest: 0x2}}, DelayTime: 12ms}\n"))
 	})
 
+	It("logs ACK_ECN frames", func() {
+		frame := &AckFrame{
+			AckRanges: []AckRange{{Smallest: 0x42, Largest: 0x1337}},
+			DelayTime: 1 * time.Millisecond,
+			ECT0:      5,
+			ECT1:      0,
+			ECNCE:     2,
+		}
+		LogFrame(logger, frame, false)
+		Expect(buf.String()).To(ContainSubstring("\t<- &wire.AckFrame{LargestAcked: 0x1337, LowestAcked: 0x42, DelayTime: 1ms, ECT0: 5, ECT1: 0, ECNCE: 2}\n"))
+	})
+
 	It("logs NEW_CONNECTION_ID frames", func() {
 		LogFrame(logger, &NewConnectionIDFrame{
 			SequenceNumber:      42,
@@ -102,4 +114,12 @@ var _ = Describe("Frame logging", func() {
 		}, true)
 		Expect(buf.String()).To(ContainSubstring("\t-> &wire.NewTokenFrame{Token: 0xdeadbeef"))
 	})
+
+	It("logs DATAGRAM frames", func() {
+		LogFrame(logger, &DatagramFrame{
+			DataLenPresent: true,
+			Data:           []byte("foobar"),
+		}, false)
+		Expect(buf.String()).To(ContainSubstring("\t<- &wire.DatagramFrame{DataLenPresent: true, Data length: 0x6}"))
+	})
 })
@@ -0,0 +1,185 @@
+// Package logging defines a logging interface for quic-go.
+// This package should not be considered stable
+package logging
+
+import (
+	"net"
+	"time"
+
+	"github.com/lucas-clemente/quic-go/internal/congestion"
+	"github.com/lucas-clemente/quic-go/internal/handshake"
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+	"github.com/lucas-clemente/quic-go/internal/wire"
+)
+
+type (
+	// A ByteCount is used to count bytes.
+	ByteCount = protocol.ByteCount
+	// A ConnectionID is a QUIC Connection ID.
+	ConnectionID = protocol.ConnectionID
+	// The EncryptionLevel is the encryption level of a packet.
+	EncryptionLevel = protocol.EncryptionLevel
+	// The KeyPhase is the key phase of the 1-RTT keys.
+	KeyPhase = protocol.KeyPhase
+	// The PacketNumber is the packet number of a packet.
+	PacketNumber = protocol.PacketNumber
+	// The Perspective is the role of a QUIC endpoint (client or server).
+	Perspective = protocol.Perspective
+	// The StreamID is the stream ID.
+	StreamID = protocol.StreamID
+	// The StreamType is the type of the stream (unidirectional or bidirectional).
+	StreamType = protocol.StreamType
+	// The VersionNumber is the QUIC version.
+	VersionNumber = protocol.VersionNumber
+
+	// The Header is the QUIC packet header, before removing header protection.
+	Header = wire.Header
+	// The ExtendedHeader is the QUIC packet header, after removing header protection.
+	ExtendedHeader = wire.ExtendedHeader
+	// The TransportParameters are QUIC transport parameters.
+	TransportParameters = handshake.TransportParameters
+	// The RTTStats contain the RTT estimates of a connection.
+	RTTStats = congestion.RTTStats
+
+	// A Frame is a QUIC frame.
+	Frame = wire.Frame
+	// An AckFrame is an ACK frame.
+	AckFrame = wire.AckFrame
+	// An AckRange is an ACK range of an ACK frame.
+	AckRange = wire.AckRange
+	// A ConnectionCloseFrame is a CONNECTION_CLOSE frame.
+	ConnectionCloseFrame = wire.ConnectionCloseFrame
+	// A CryptoFrame is a CRYPTO frame.
+	CryptoFrame = wire.CryptoFrame
+	// A DataBlockedFrame is a DATA_BLOCKED frame.
+	DataBlockedFrame = wire.DataBlockedFrame
+	// A DatagramFrame is a DATAGRAM frame.
+	DatagramFrame = wire.DatagramFrame
+	// A MaxDataFrame is a MAX_DATA frame.
+	MaxDataFrame = wire.MaxDataFrame
+	// A MaxStreamDataFrame is a MAX_STREAM_DATA frame.
+	MaxStreamDataFrame = wire.MaxStreamDataFrame
+	// A MaxStreamsFrame is a MAX_STREAMS frame.
+	MaxStreamsFrame = wire.MaxStreamsFrame
+	// A NewConnectionIDFrame is a NEW_CONNECTION_ID frame.
+	NewConnectionIDFrame = wire.NewConnectionIDFrame
+	// A NewTokenFrame is a NEW_TOKEN frame.
+	NewTokenFrame = wire.NewTokenFrame
+	// A PathChallengeFrame is a PATH_CHALLENGE frame.
+	PathChallengeFrame = wire.PathChallengeFrame
+	// A PathResponseFrame is a PATH_RESPONSE frame.
+	PathResponseFrame = wire.PathResponseFrame
+	// A PingFrame is a PING frame.
+	PingFrame = wire.PingFrame
+	// A ResetStreamFrame is a RESET_STREAM frame.
+	ResetStreamFrame = wire.ResetStreamFrame
+	// A RetireConnectionIDFrame is a RETIRE_CONNECTION_ID frame.
+	RetireConnectionIDFrame = wire.RetireConnectionIDFrame
+	// A StopSendingFrame is a STOP_SENDING frame.
+	StopSendingFrame = wire.StopSendingFrame
+	// A StreamFrame is a STREAM frame.
+	StreamFrame = wire.StreamFrame
+	// A StreamDataBlockedFrame is a STREAM_DATA_BLOCKED frame.
+	StreamDataBlockedFrame = wire.StreamDataBlockedFrame
+	// A StreamsBlockedFrame is a STREAMS_BLOCKED frame.
+	StreamsBlockedFrame = wire.StreamsBlockedFrame
+)
+
+const (
+	// PerspectiveServer is used for a QUIC server
+	PerspectiveServer Perspective = protocol.PerspectiveServer
+	// PerspectiveClient is used for a QUIC client
+	PerspectiveClient Perspective = protocol.PerspectiveClient
+)
+
+const (
+	// EncryptionInitial is the Initial encryption level
+	EncryptionInitial EncryptionLevel = protocol.EncryptionInitial
+	// EncryptionHandshake is the Handshake encryption level
+	EncryptionHandshake EncryptionLevel = protocol.EncryptionHandshake
+	// Encryption1RTT is the 1-RTT encryption level
+	Encryption1RTT EncryptionLevel = protocol.Encryption1RTT
+)
+
+const (
+	// StreamTypeUni is a unidirectional stream
+	StreamTypeUni StreamType = protocol.StreamTypeUni
+	// StreamTypeBidi is a bidirectional stream
+	StreamTypeBidi StreamType = protocol.StreamTypeBidi
+)
+
+// A Tracer traces events.
+type Tracer interface {
+	// TracerForConnection requests a new tracer for a connection.
+	// The ODCID is the original destination connection ID:
+	// The destination connection ID that the client used on the first Initial packet it sent on this connection.
+	// If nil is returned, tracing will be disabled for this connection.
+	TracerForConnection(p Perspective, odcid ConnectionID) ConnectionTracer
+}
+
+// A ConnectionTracer records events of a single connection.
+// Its methods are called from the go routine running the connection,
+// with the exception of the key related events, which may be called from the go routine running the handshake.
+type ConnectionTracer interface {
+	// StartedConnection is called when the connection is created.
+	StartedConnection(local, remote net.Addr, version VersionNumber, srcConnID, destConnID ConnectionID)
+	// ClosedConnection is called when the connection is closed, either by us or by the peer.
+	ClosedConnection(err error, remote bool)
+	// SentTransportParameters is called with the transport parameters that we send to the peer.
+	SentTransportParameters(*TransportParameters)
+	// ReceivedTransportParameters is called with the transport parameters that the peer sent.
+	ReceivedTransportParameters(*TransportParameters)
+	// SentPacket is called when a packet is sent.
+	SentPacket(hdr *ExtendedHeader, packetSize ByteCount, frames []Frame)
+	// ReceivedRetry is called when a Retry packet is received and accepted.
+	ReceivedRetry(*Header)
+	// ReceivedPacket is called when a packet was successfully decrypted and parsed.
+	ReceivedPacket(hdr *ExtendedHeader, packetSize ByteCount, frames []Frame)
+	// BufferedPacket is called when a packet is queued until the keys needed to decrypt it become available.
+	BufferedPacket(PacketType)
+	// DroppedPacket is called when a packet is dropped.
+	DroppedPacket(PacketType, ByteCount, PacketDropReason)
+	// UpdatedMetrics is called when the RTT estimate or the congestion window changes.
+	UpdatedMetrics(rttStats *RTTStats, cwnd, bytesInFlight ByteCount, packetsInFlight int)
+	// UpdatedCongestionState is called when the congestion controller changes its state.
+	UpdatedCongestionState(CongestionState)
+	// LostPacket is called when a packet is declared lost.
+	LostPacket(EncryptionLevel, PacketNumber, PacketLossReason)
+	// UpdatedPTOCount is called when the PTO count changes.
+	UpdatedPTOCount(value uint32)
+	// UpdatedKeyFromTLS is called when TLS installs new keys.
+	UpdatedKeyFromTLS(EncryptionLevel, Perspective)
+	// UpdatedKey is called when the 1-RTT keys are updated.
+	// remote is set if the key update was initiated by the peer.
+	UpdatedKey(generation KeyPhase, remote bool)
+	// Close is called when the connection has been closed and no more events will be recorded.
+	Close()
+}
+
+// Metrics collects metrics about the handshakes and packets handled by a QUIC endpoint.
+// Unlike a ConnectionTracer, it is not bound to a single connection,
+// and the same Metrics are usually shared by all connections of a Listener.
+// Its methods are called concurrently from many go routines, and they must not block.
+type Metrics interface {
+	// StartedHandshake is called when a new connection starts the handshake.
+	// When a client restarts the handshake after receiving a Version Negotiation packet, this counts as a new handshake.
+	StartedHandshake(Perspective)
+	// CompletedHandshake is called when the handshake completes.
+	// The duration is measured from the creation of the connection.
+	CompletedHandshake(p Perspective, duration time.Duration)
+	// FailedHandshake is called when a connection is closed before the handshake completed.
+	FailedHandshake(Perspective)
+	// SentRetry is called when the server sends a Retry packet.
+	SentRetry()
+	// SentServerBusy is called when the server rejects a connection attempt because its accept queue is full.
+	SentServerBusy()
+	// SentVersionNegotiation is called when the server sends a Version Negotiation packet.
+	SentVersionNegotiation()
+	// SentStatelessReset is called when a stateless reset is sent.
+	SentStatelessReset()
+	// ReceivedStatelessReset is called when a stateless reset for one of our connections is received.
+	ReceivedStatelessReset()
+	// DroppedPacket is called when a packet is dropped before it could be passed to a connection,
+	// or because the connection is not processing packets fast enough.
+	DroppedPacket(PacketDropReason)
+}
@@ -0,0 +1,86 @@
+package logging
+
+import "github.com/lucas-clemente/quic-go/internal/protocol"
+
+// PacketType is the packet type of a QUIC packet
+type PacketType uint8
+
+const (
+	// PacketTypeInitial is the packet type of an Initial packet
+	PacketTypeInitial PacketType = iota
+	// PacketTypeHandshake is the packet type of a Handshake packet
+	PacketTypeHandshake
+	// PacketTypeRetry is the packet type of a Retry packet
+	PacketTypeRetry
+	// PacketType0RTT is the packet type of a 0-RTT packet
+	PacketType0RTT
+	// PacketTypeVersionNegotiation is the packet type of a Version Negotiation packet
+	PacketTypeVersionNegotiation
+	// PacketType1RTT is a 1-RTT packet
+	PacketType1RTT
+	// PacketTypeNotDetermined is the packet type when it could not be determined
+	PacketTypeNotDetermined
+)
+
+// PacketTypeFromHeader determines the packet type from a header.
+func PacketTypeFromHeader(hdr *Header) PacketType {
+	if !hdr.IsLongHeader {
+		return PacketType1RTT
+	}
+	if hdr.Version == 0 {
+		return PacketTypeVersionNegotiation
+	}
+	switch hdr.Type {
+	case protocol.PacketTypeInitial:
+		return PacketTypeInitial
+	case protocol.PacketTypeHandshake:
+		return PacketTypeHandshake
+	case protocol.PacketType0RTT:
+		return PacketType0RTT
+	case protocol.PacketTypeRetry:
+		return PacketTypeRetry
+	default:
+		return PacketTypeNotDetermined
+	}
+}
+
+// PacketDropReason is the reason why a packet was dropped
+type PacketDropReason uint8
+
+const (
+	// PacketDropKeyUnavailable is used when a packet is dropped because keys are unavailable
+	PacketDropKeyUnavailable PacketDropReason = iota
+	// PacketDropUnknownConnectionID is used when a packet is dropped because the connection ID is unknown
+	PacketDropUnknownConnectionID
+	// PacketDropHeaderParseError is used when a packet is dropped because header parsing failed
+	PacketDropHeaderParseError
+	// PacketDropPayloadDecryptError is used when a packet is dropped because decrypting the payload failed
+	PacketDropPayloadDecryptError
+	// PacketDropUnexpectedPacket is used when an unexpected packet is received
+	PacketDropUnexpectedPacket
+	// PacketDropUnexpectedSourceConnectionID is used when a packet with an unexpected source connection ID is received
+	PacketDropUnexpectedSourceConnectionID
+	// PacketDropDOSPrevention is used when a packet is dropped to mitigate a DoS attack
+	PacketDropDOSPrevention
+)
+
+// PacketLossReason is the reason why a packet was declared lost
+type PacketLossReason uint8
+
+const (
+	// PacketLossTimeThreshold is used when a packet is declared lost because it was sent
+	// more than the loss delay before the largest acknowledged packet was sent
+	PacketLossTimeThreshold PacketLossReason = iota
+)
+
+// CongestionState is the state of the congestion controller
+type CongestionState uint8
+
+const (
+	// CongestionStateSlowStart is the slow start phase
+	CongestionStateSlowStart CongestionState = iota
+	// CongestionStateCongestionAvoidance is the congestion avoidance phase
+	CongestionStateCongestionAvoidance
+	// CongestionStateRecovery is the recovery phase
+	CongestionStateRecovery
+)
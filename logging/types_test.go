@@ -0,0 +1,43 @@
+package logging
+
+import (
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Packet Type", func() {
+	It("determines the packet type from the header", func() {
+		Expect(PacketTypeFromHeader(&Header{
+			IsLongHeader: true,
+			Type:         protocol.PacketTypeInitial,
+			Version:      protocol.VersionTLS,
+		})).To(Equal(PacketTypeInitial))
+		Expect(PacketTypeFromHeader(&Header{
+			IsLongHeader: true,
+			Type:         protocol.PacketTypeHandshake,
+			Version:      protocol.VersionTLS,
+		})).To(Equal(PacketTypeHandshake))
+		Expect(PacketTypeFromHeader(&Header{
+			IsLongHeader: true,
+			Type:         protocol.PacketTypeRetry,
+			Version:      protocol.VersionTLS,
+		})).To(Equal(PacketTypeRetry))
+		Expect(PacketTypeFromHeader(&Header{
+			IsLongHeader: true,
+			Type:         protocol.PacketType0RTT,
+			Version:      protocol.VersionTLS,
+		})).To(Equal(PacketType0RTT))
+		Expect(PacketTypeFromHeader(&Header{
+			IsLongHeader: true,
+			Type:         protocol.PacketTypeHandshake,
+		})).To(Equal(PacketTypeVersionNegotiation))
+		Expect(PacketTypeFromHeader(&Header{
+			IsLongHeader: true,
+			Type:         42,
+			Version:      protocol.VersionTLS,
+		})).To(Equal(PacketTypeNotDetermined))
+		Expect(PacketTypeFromHeader(&Header{})).To(Equal(PacketType1RTT))
+	})
+})
@@ -0,0 +1,54 @@
+package metrics
+
+import (
+	"sort"
+	"sync"
+)
+
+// A histogram counts observations in buckets with configurable upper bounds.
+// The buckets are cumulative when they are exported.
+type histogram struct {
+	mutex sync.Mutex
+
+	upperBounds []float64 // sorted in ascending order
+	counts      []uint64  // the number of observations in each bucket, not cumulative
+	sum         float64
+	count       uint64
+}
+
+func newHistogram(upperBounds []float64) *histogram {
+	bounds := make([]float64, len(upperBounds))
+	copy(bounds, upperBounds)
+	sort.Float64s(bounds)
+	return &histogram{
+		upperBounds: bounds,
+		counts:      make([]uint64, len(bounds)),
+	}
+}
+
+func (h *histogram) Observe(v float64) {
+	// the first bucket with an upper bound >= v
+	i := sort.SearchFloat64s(h.upperBounds, v)
+
+	h.mutex.Lock()
+	if i < len(h.counts) {
+		h.counts[i]++
+	}
+	h.sum += v
+	h.count++
+	h.mutex.Unlock()
+}
+
+// snapshot returns the cumulative bucket counts, the sum and the total number of observations.
+func (h *histogram) snapshot() ([]uint64, float64, uint64) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	cumulative := make([]uint64, len(h.counts))
+	var total uint64
+	for i, c := range h.counts {
+		total += c
+		cumulative[i] = total
+	}
+	return cumulative, h.sum, h.count
+}
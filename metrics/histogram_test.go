@@ -0,0 +1,27 @@
+package metrics
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Histogram", func() {
+	It("sorts the upper bounds", func() {
+		bounds := []float64{1, 0.1, 0.5}
+		h := newHistogram(bounds)
+		Expect(h.upperBounds).To(Equal([]float64{0.1, 0.5, 1}))
+		Expect(bounds).To(Equal([]float64{1, 0.1, 0.5})) // the slice passed in is not modified
+	})
+
+	It("counts observations in cumulative buckets", func() {
+		h := newHistogram([]float64{0.1, 0.5, 1})
+		h.Observe(0.05)
+		h.Observe(0.1) // the upper bound is inclusive
+		h.Observe(0.3)
+		h.Observe(2)
+		counts, sum, count := h.snapshot()
+		Expect(counts).To(Equal([]uint64{2, 3, 3}))
+		Expect(sum).To(BeNumerically("~", 2.45, 1e-9))
+		Expect(count).To(BeEquivalentTo(4))
+	})
+})
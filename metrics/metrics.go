@@ -0,0 +1,256 @@
+// Package metrics collects metrics about QUIC endpoints and exposes them in the Prometheus text exposition format.
+// It doesn't depend on the Prometheus client library: The Collector is an http.Handler,
+// and can be registered as the endpoint that is scraped by Prometheus.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/lucas-clemente/quic-go/logging"
+)
+
+const contentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// DefaultHandshakeDurationBuckets are the upper bounds (in seconds) of the buckets of the handshake duration histogram.
+var DefaultHandshakeDurationBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// The drop reasons that are exported, in the order they are written.
+var dropReasons = []logging.PacketDropReason{
+	logging.PacketDropKeyUnavailable,
+	logging.PacketDropUnknownConnectionID,
+	logging.PacketDropHeaderParseError,
+	logging.PacketDropPayloadDecryptError,
+	logging.PacketDropUnexpectedPacket,
+	logging.PacketDropUnexpectedSourceConnectionID,
+	logging.PacketDropDOSPrevention,
+}
+
+func dropReasonLabel(r logging.PacketDropReason) string {
+	switch r {
+	case logging.PacketDropKeyUnavailable:
+		return "key_unavailable"
+	case logging.PacketDropUnknownConnectionID:
+		return "unknown_connection_id"
+	case logging.PacketDropHeaderParseError:
+		return "header_parse_error"
+	case logging.PacketDropPayloadDecryptError:
+		return "payload_decrypt_error"
+	case logging.PacketDropUnexpectedPacket:
+		return "unexpected_packet"
+	case logging.PacketDropUnexpectedSourceConnectionID:
+		return "unexpected_source_connection_id"
+	case logging.PacketDropDOSPrevention:
+		return "dos_prevention"
+	default:
+		return "unknown"
+	}
+}
+
+// perspectiveStats are the metrics that are collected separately for clients and servers.
+// They are allocated separately, such that the counters are 64 bit aligned on 32 bit platforms.
+type perspectiveStats struct {
+	// accessed atomically
+	handshakesStarted   uint64
+	handshakesCompleted uint64
+	handshakesFailed    uint64
+
+	handshakeDuration *histogram
+}
+
+// A Collector collects the metrics of QUIC endpoints.
+// It implements the logging.Metrics interface, and is used by setting it as the Metrics of the quic.Config.
+// The same Collector can be used for multiple Listeners and clients.
+type Collector struct {
+	// accessed atomically
+	retriesSent                 uint64
+	serverBusySent              uint64
+	versionNegotiationsSent     uint64
+	statelessResetsSent         uint64
+	statelessResetsReceived     uint64
+	packetsDropped              [logging.PacketDropDOSPrevention + 1]uint64
+	packetsDroppedUnknownReason uint64
+
+	namespace string
+	client    *perspectiveStats
+	server    *perspectiveStats
+}
+
+var _ logging.Metrics = &Collector{}
+var _ http.Handler = &Collector{}
+
+// NewCollector creates a new Collector.
+// The namespace is used as a prefix for the names of all metrics. If it is empty, "quic" is used.
+// If buckets is nil, the handshake durations are collected using the DefaultHandshakeDurationBuckets.
+func NewCollector(namespace string, buckets []float64) *Collector {
+	if namespace == "" {
+		namespace = "quic"
+	}
+	if buckets == nil {
+		buckets = DefaultHandshakeDurationBuckets
+	}
+	return &Collector{
+		namespace: namespace,
+		client:    &perspectiveStats{handshakeDuration: newHistogram(buckets)},
+		server:    &perspectiveStats{handshakeDuration: newHistogram(buckets)},
+	}
+}
+
+func (c *Collector) statsFor(p logging.Perspective) *perspectiveStats {
+	if p == logging.PerspectiveClient {
+		return c.client
+	}
+	return c.server
+}
+
+// StartedHandshake counts a handshake that was started.
+func (c *Collector) StartedHandshake(p logging.Perspective) {
+	atomic.AddUint64(&c.statsFor(p).handshakesStarted, 1)
+}
+
+// CompletedHandshake counts a completed handshake, and records its duration.
+func (c *Collector) CompletedHandshake(p logging.Perspective, duration time.Duration) {
+	stats := c.statsFor(p)
+	atomic.AddUint64(&stats.handshakesCompleted, 1)
+	stats.handshakeDuration.Observe(duration.Seconds())
+}
+
+// FailedHandshake counts a failed handshake.
+func (c *Collector) FailedHandshake(p logging.Perspective) {
+	atomic.AddUint64(&c.statsFor(p).handshakesFailed, 1)
+}
+
+// SentRetry counts a Retry packet.
+func (c *Collector) SentRetry() { atomic.AddUint64(&c.retriesSent, 1) }
+
+// SentServerBusy counts a connection attempt that was rejected because the server was busy.
+func (c *Collector) SentServerBusy() { atomic.AddUint64(&c.serverBusySent, 1) }
+
+// SentVersionNegotiation counts a Version Negotiation packet.
+func (c *Collector) SentVersionNegotiation() { atomic.AddUint64(&c.versionNegotiationsSent, 1) }
+
+// SentStatelessReset counts a stateless reset that was sent.
+func (c *Collector) SentStatelessReset() { atomic.AddUint64(&c.statelessResetsSent, 1) }
+
+// ReceivedStatelessReset counts a stateless reset that was received.
+func (c *Collector) ReceivedStatelessReset() { atomic.AddUint64(&c.statelessResetsReceived, 1) }
+
+// DroppedPacket counts a dropped packet.
+func (c *Collector) DroppedPacket(reason logging.PacketDropReason) {
+	if int(reason) >= len(c.packetsDropped) {
+		atomic.AddUint64(&c.packetsDroppedUnknownReason, 1)
+		return
+	}
+	atomic.AddUint64(&c.packetsDropped[reason], 1)
+}
+
+// ServeHTTP writes the metrics in the Prometheus text exposition format.
+func (c *Collector) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", contentType)
+	c.WriteTo(w)
+}
+
+// WriteTo writes the metrics in the Prometheus text exposition format.
+func (c *Collector) WriteTo(w io.Writer) (int64, error) {
+	cw := &countingWriter{w: w}
+	bw := bufio.NewWriter(cw)
+	mw := &metricsWriter{w: bw, namespace: c.namespace}
+
+	perspectives := []struct {
+		label string
+		stats *perspectiveStats
+	}{
+		{label: "client", stats: c.client},
+		{label: "server", stats: c.server},
+	}
+	mw.header("handshakes_started_total", "counter", "Number of QUIC handshakes started.")
+	for _, p := range perspectives {
+		mw.sample("handshakes_started_total", "perspective", p.label, float64(atomic.LoadUint64(&p.stats.handshakesStarted)))
+	}
+	mw.header("handshakes_completed_total", "counter", "Number of QUIC handshakes completed.")
+	for _, p := range perspectives {
+		mw.sample("handshakes_completed_total", "perspective", p.label, float64(atomic.LoadUint64(&p.stats.handshakesCompleted)))
+	}
+	mw.header("handshakes_failed_total", "counter", "Number of QUIC connections closed before the handshake completed.")
+	for _, p := range perspectives {
+		mw.sample("handshakes_failed_total", "perspective", p.label, float64(atomic.LoadUint64(&p.stats.handshakesFailed)))
+	}
+	mw.header("handshake_duration_seconds", "histogram", "Duration of completed QUIC handshakes.")
+	for _, p := range perspectives {
+		mw.histogram("handshake_duration_seconds", "perspective", p.label, p.stats.handshakeDuration)
+	}
+
+	mw.counter("retries_sent_total", "Number of Retry packets sent.", atomic.LoadUint64(&c.retriesSent))
+	mw.counter("server_busy_sent_total", "Number of connection attempts rejected because the server was busy.", atomic.LoadUint64(&c.serverBusySent))
+	mw.counter("version_negotiations_sent_total", "Number of Version Negotiation packets sent.", atomic.LoadUint64(&c.versionNegotiationsSent))
+	mw.counter("stateless_resets_sent_total", "Number of stateless resets sent.", atomic.LoadUint64(&c.statelessResetsSent))
+	mw.counter("stateless_resets_received_total", "Number of stateless resets received.", atomic.LoadUint64(&c.statelessResetsReceived))
+
+	mw.header("packets_dropped_total", "counter", "Number of packets dropped, by reason.")
+	for _, r := range dropReasons {
+		mw.sample("packets_dropped_total", "reason", dropReasonLabel(r), float64(atomic.LoadUint64(&c.packetsDropped[r])))
+	}
+	mw.sample("packets_dropped_total", "reason", "unknown", float64(atomic.LoadUint64(&c.packetsDroppedUnknownReason)))
+
+	if mw.err == nil {
+		mw.err = bw.Flush()
+	}
+	return cw.n, mw.err
+}
+
+// A metricsWriter writes metrics in the Prometheus text exposition format.
+// It stops writing after the first error.
+type metricsWriter struct {
+	w         *bufio.Writer
+	namespace string
+	err       error
+}
+
+func (w *metricsWriter) printf(format string, a ...interface{}) {
+	if w.err != nil {
+		return
+	}
+	_, w.err = fmt.Fprintf(w.w, format, a...)
+}
+
+func (w *metricsWriter) header(name, typ, help string) {
+	w.printf("# HELP %s_%s %s\n# TYPE %s_%s %s\n", w.namespace, name, help, w.namespace, name, typ)
+}
+
+func (w *metricsWriter) sample(name, labelName, labelValue string, value float64) {
+	w.printf("%s_%s{%s=%q} %s\n", w.namespace, name, labelName, labelValue, formatFloat(value))
+}
+
+func (w *metricsWriter) counter(name, help string, value uint64) {
+	w.header(name, "counter", help)
+	w.printf("%s_%s %d\n", w.namespace, name, value)
+}
+
+func (w *metricsWriter) histogram(name, labelName, labelValue string, h *histogram) {
+	counts, sum, count := h.snapshot()
+	for i, upperBound := range h.upperBounds {
+		w.printf("%s_%s_bucket{%s=%q,le=%q} %d\n", w.namespace, name, labelName, labelValue, formatFloat(upperBound), counts[i])
+	}
+	w.printf("%s_%s_bucket{%s=%q,le=\"+Inf\"} %d\n", w.namespace, name, labelName, labelValue, count)
+	w.printf("%s_%s_sum{%s=%q} %s\n", w.namespace, name, labelName, labelValue, formatFloat(sum))
+	w.printf("%s_%s_count{%s=%q} %d\n", w.namespace, name, labelName, labelValue, count)
+}
+
+func formatFloat(f float64) string {
+	return fmt.Sprintf("%g", f)
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	w.n += int64(n)
+	return n, err
+}
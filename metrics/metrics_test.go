@@ -0,0 +1,124 @@
+package metrics
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	"github.com/lucas-clemente/quic-go/logging"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Collector", func() {
+	var c *Collector
+
+	BeforeEach(func() {
+		c = NewCollector("", []float64{0.1, 1})
+	})
+
+	export := func() []string {
+		buf := &bytes.Buffer{}
+		n, err := c.WriteTo(buf)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(n).To(BeEquivalentTo(buf.Len()))
+		return strings.Split(buf.String(), "\n")
+	}
+
+	It("uses the namespace", func() {
+		c = NewCollector("myquic", nil)
+		c.SentRetry()
+		Expect(export()).To(ContainElement("myquic_retries_sent_total 1"))
+	})
+
+	It("exports all metrics, also if nothing was counted yet", func() {
+		lines := export()
+		Expect(lines).To(ContainElement("# TYPE quic_handshakes_started_total counter"))
+		Expect(lines).To(ContainElement(`quic_handshakes_started_total{perspective="client"} 0`))
+		Expect(lines).To(ContainElement(`quic_handshakes_started_total{perspective="server"} 0`))
+		Expect(lines).To(ContainElement("# TYPE quic_handshake_duration_seconds histogram"))
+		Expect(lines).To(ContainElement(`quic_handshake_duration_seconds_bucket{perspective="server",le="+Inf"} 0`))
+		Expect(lines).To(ContainElement("quic_stateless_resets_received_total 0"))
+		Expect(lines).To(ContainElement(`quic_packets_dropped_total{reason="dos_prevention"} 0`))
+		Expect(lines).To(ContainElement(`quic_packets_dropped_total{reason="unknown"} 0`))
+	})
+
+	It("counts handshakes", func() {
+		c.StartedHandshake(logging.PerspectiveServer)
+		c.StartedHandshake(logging.PerspectiveServer)
+		c.StartedHandshake(logging.PerspectiveServer)
+		c.StartedHandshake(logging.PerspectiveClient)
+		c.CompletedHandshake(logging.PerspectiveServer, 50*time.Millisecond)
+		c.CompletedHandshake(logging.PerspectiveServer, 500*time.Millisecond)
+		c.FailedHandshake(logging.PerspectiveServer)
+		lines := export()
+		Expect(lines).To(ContainElement(`quic_handshakes_started_total{perspective="client"} 1`))
+		Expect(lines).To(ContainElement(`quic_handshakes_started_total{perspective="server"} 3`))
+		Expect(lines).To(ContainElement(`quic_handshakes_completed_total{perspective="client"} 0`))
+		Expect(lines).To(ContainElement(`quic_handshakes_completed_total{perspective="server"} 2`))
+		Expect(lines).To(ContainElement(`quic_handshakes_failed_total{perspective="server"} 1`))
+		Expect(lines).To(ContainElement(`quic_handshake_duration_seconds_bucket{perspective="server",le="0.1"} 1`))
+		Expect(lines).To(ContainElement(`quic_handshake_duration_seconds_bucket{perspective="server",le="1"} 2`))
+		Expect(lines).To(ContainElement(`quic_handshake_duration_seconds_bucket{perspective="server",le="+Inf"} 2`))
+		Expect(lines).To(ContainElement(`quic_handshake_duration_seconds_sum{perspective="server"} 0.55`))
+		Expect(lines).To(ContainElement(`quic_handshake_duration_seconds_count{perspective="server"} 2`))
+		Expect(lines).To(ContainElement(`quic_handshake_duration_seconds_count{perspective="client"} 0`))
+	})
+
+	It("counts packets sent by the server", func() {
+		c.SentRetry()
+		c.SentRetry()
+		c.SentServerBusy()
+		c.SentVersionNegotiation()
+		c.SentStatelessReset()
+		c.ReceivedStatelessReset()
+		lines := export()
+		Expect(lines).To(ContainElement("quic_retries_sent_total 2"))
+		Expect(lines).To(ContainElement("quic_server_busy_sent_total 1"))
+		Expect(lines).To(ContainElement("quic_version_negotiations_sent_total 1"))
+		Expect(lines).To(ContainElement("quic_stateless_resets_sent_total 1"))
+		Expect(lines).To(ContainElement("quic_stateless_resets_received_total 1"))
+	})
+
+	It("counts dropped packets by reason", func() {
+		c.DroppedPacket(logging.PacketDropHeaderParseError)
+		c.DroppedPacket(logging.PacketDropDOSPrevention)
+		c.DroppedPacket(logging.PacketDropDOSPrevention)
+		c.DroppedPacket(logging.PacketDropReason(200))
+		lines := export()
+		Expect(lines).To(ContainElement(`quic_packets_dropped_total{reason="header_parse_error"} 1`))
+		Expect(lines).To(ContainElement(`quic_packets_dropped_total{reason="dos_prevention"} 2`))
+		Expect(lines).To(ContainElement(`quic_packets_dropped_total{reason="unknown_connection_id"} 0`))
+		Expect(lines).To(ContainElement(`quic_packets_dropped_total{reason="unknown"} 1`))
+	})
+
+	It("serves the metrics via HTTP", func() {
+		c.SentRetry()
+		w := httptest.NewRecorder()
+		c.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+		Expect(w.Header().Get("Content-Type")).To(Equal("text/plain; version=0.0.4; charset=utf-8"))
+		Expect(strings.Split(w.Body.String(), "\n")).To(ContainElement("quic_retries_sent_total 1"))
+	})
+
+	It("is safe for concurrent use", func() {
+		done := make(chan struct{})
+		for i := 0; i < 10; i++ {
+			go func() {
+				defer GinkgoRecover()
+				for j := 0; j < 100; j++ {
+					c.StartedHandshake(logging.PerspectiveClient)
+					c.CompletedHandshake(logging.PerspectiveClient, time.Millisecond)
+				}
+				done <- struct{}{}
+			}()
+		}
+		for i := 0; i < 10; i++ {
+			Eventually(done).Should(Receive())
+		}
+		lines := export()
+		Expect(lines).To(ContainElement(`quic_handshakes_started_total{perspective="client"} 1000`))
+		Expect(lines).To(ContainElement(`quic_handshake_duration_seconds_count{perspective="client"} 1000`))
+	})
+})
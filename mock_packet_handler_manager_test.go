@@ -145,3 +145,15 @@ func (mr *MockPacketHandlerManagerMockRecorder) SetServer(arg0 interface{}) *gom
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetServer", reflect.TypeOf((*MockPacketHandlerManager)(nil).SetServer), arg0)
 }
+
+// StopListening mocks base method
+func (m *MockPacketHandlerManager) StopListening() {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "StopListening")
+}
+
+// StopListening indicates an expected call of StopListening
+func (mr *MockPacketHandlerManagerMockRecorder) StopListening() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StopListening", reflect.TypeOf((*MockPacketHandlerManager)(nil).StopListening))
+}
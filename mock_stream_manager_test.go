@@ -136,6 +136,20 @@ func (mr *MockStreamManagerMockRecorder) HandleMaxStreamsFrame(arg0 interface{})
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "HandleMaxStreamsFrame", reflect.TypeOf((*MockStreamManager)(nil).HandleMaxStreamsFrame), arg0)
 }
 
+// NumStreams mocks base method
+func (m *MockStreamManager) NumStreams(arg0 protocol.StreamType) int {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "NumStreams", arg0)
+	ret0, _ := ret[0].(int)
+	return ret0
+}
+
+// NumStreams indicates an expected call of NumStreams
+func (mr *MockStreamManagerMockRecorder) NumStreams(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "NumStreams", reflect.TypeOf((*MockStreamManager)(nil).NumStreams), arg0)
+}
+
 // OpenStream mocks base method
 func (m *MockStreamManager) OpenStream() (Stream, error) {
 	m.ctrl.T.Helper()
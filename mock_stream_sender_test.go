@@ -59,6 +59,18 @@ func (mr *MockStreamSenderMockRecorder) onStreamCompleted(arg0 interface{}) *gom
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "onStreamCompleted", reflect.TypeOf((*MockStreamSender)(nil).onStreamCompleted), arg0)
 }
 
+// onStreamPriorityChanged mocks base method
+func (m *MockStreamSender) onStreamPriorityChanged(arg0 protocol.StreamID, arg1 StreamPriority) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "onStreamPriorityChanged", arg0, arg1)
+}
+
+// onStreamPriorityChanged indicates an expected call of onStreamPriorityChanged
+func (mr *MockStreamSenderMockRecorder) onStreamPriorityChanged(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "onStreamPriorityChanged", reflect.TypeOf((*MockStreamSender)(nil).onStreamPriorityChanged), arg0, arg1)
+}
+
 // queueControlFrame mocks base method
 func (m *MockStreamSender) queueControlFrame(arg0 wire.Frame) {
 	m.ctrl.T.Helper()
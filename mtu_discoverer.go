@@ -0,0 +1,172 @@
+package quic
+
+import (
+	"time"
+
+	"github.com/lucas-clemente/quic-go/internal/congestion"
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+)
+
+type mtuDiscoverer interface {
+	ShouldSendProbe(now time.Time) bool
+	// GetProbe returns the size of the next probe packet,
+	// and the callback that has to be called when it is acknowledged or declared lost.
+	GetProbe(now time.Time) (protocol.ByteCount, func(acked bool))
+	// TrackPacket is called for every packet sent, and returns the callback that needs to be set on the packet.
+	TrackPacket(size protocol.ByteCount, ackCallback func(acked bool)) func(acked bool)
+	OnProbeTimeout(now time.Time)
+	Reset(now time.Time)
+}
+
+const (
+	// At some point, we have to stop searching for a higher MTU.
+	// We're happy to send a packet that's 20 bytes smaller than the actual MTU.
+	maxMTUDiff = 20
+	// send a probe packet every mtuProbeDelay RTTs
+	mtuProbeDelay = 5
+	// When this many packets that are larger than the initial packet size are lost,
+	// and no large packet sent after them was acknowledged, we assume that the path is black-holing them.
+	// Every packet sent as a probe after a probe timeout counts as a lost packet as well.
+	mtuBlackHoleThreshold = 3
+)
+
+// The mtuFinder implements Datagram Packetization Layer Path MTU Discovery (DPLPMTUD).
+// It does a binary search between the initial packet size and the maximum packet size
+// allowed by the peer, by sending PING frames padded to the probed size.
+// The loss of a probe packet is not treated as a sign of congestion.
+// If packets that are larger than the initial packet size are lost repeatedly,
+// it falls back to the initial packet size and restarts the search.
+type mtuFinder struct {
+	setMaxPacketSize func(protocol.ByteCount)
+	rttStats         *congestion.RTTStats
+
+	start, max protocol.ByteCount // the initial packet size, and the maximum packet size allowed by the peer
+
+	lastProbeTime time.Time
+	probeInFlight bool
+	current       protocol.ByteCount // the largest packet size that is known to work
+	upperBound    protocol.ByteCount // the smallest packet size that is known to not work, or max + 1
+
+	numLost uint
+	// Packets larger than the initial packet size are numbered, such that we can tell if a later packet was acknowledged.
+	// In that case, the loss of a packet is caused by congestion, and not by a black hole.
+	numLargePackets   uint64
+	largestAckedLarge uint64
+	// epoch is incremented on every reset, such that callbacks for packets sent before are ignored
+	epoch uint64
+}
+
+var _ mtuDiscoverer = &mtuFinder{}
+
+func newMTUDiscoverer(
+	rttStats *congestion.RTTStats,
+	start, max protocol.ByteCount,
+	setMaxPacketSize func(protocol.ByteCount),
+	now time.Time,
+) mtuDiscoverer {
+	f := &mtuFinder{
+		setMaxPacketSize: setMaxPacketSize,
+		rttStats:         rttStats,
+		start:            start,
+		max:              max,
+	}
+	f.reset(now)
+	return f
+}
+
+func (f *mtuFinder) reset(now time.Time) {
+	f.epoch++
+	f.current = f.start
+	f.upperBound = f.max + 1
+	f.lastProbeTime = now // don't probe right away
+	f.probeInFlight = false
+	f.numLost = 0
+}
+
+func (f *mtuFinder) done() bool {
+	return f.upperBound-f.current <= maxMTUDiff+1
+}
+
+func (f *mtuFinder) ShouldSendProbe(now time.Time) bool {
+	if f.probeInFlight || f.done() {
+		return false
+	}
+	return !now.Before(f.lastProbeTime.Add(mtuProbeDelay * f.rttStats.SmoothedRTT()))
+}
+
+func (f *mtuFinder) GetProbe(now time.Time) (protocol.ByteCount, func(acked bool)) {
+	size := (f.current + f.upperBound) / 2
+	f.lastProbeTime = now
+	f.probeInFlight = true
+	epoch := f.epoch
+	return size, func(acked bool) {
+		if epoch != f.epoch {
+			return
+		}
+		f.probeInFlight = false
+		if !acked {
+			f.upperBound = size
+			return
+		}
+		f.current = size
+		f.numLost = 0
+		f.setMaxPacketSize(size)
+	}
+}
+
+func (f *mtuFinder) TrackPacket(size protocol.ByteCount, ackCallback func(acked bool)) func(acked bool) {
+	if size <= f.start {
+		return ackCallback
+	}
+	epoch := f.epoch
+	f.numLargePackets++
+	num := f.numLargePackets
+	return func(acked bool) {
+		if ackCallback != nil {
+			ackCallback(acked)
+		}
+		if epoch != f.epoch {
+			return
+		}
+		if acked {
+			f.numLost = 0
+			if num > f.largestAckedLarge {
+				f.largestAckedLarge = num
+			}
+			return
+		}
+		if num < f.largestAckedLarge {
+			return
+		}
+		f.onLoss(time.Now())
+	}
+}
+
+// OnProbeTimeout is called when a probe packet is sent after the probe timeout (PTO) fired.
+// Since packets are only declared lost when a later packet is acknowledged,
+// this is the only indication of a black hole when the path drops all large packets.
+func (f *mtuFinder) OnProbeTimeout(now time.Time) {
+	if f.current == f.start {
+		return
+	}
+	f.onLoss(now)
+}
+
+func (f *mtuFinder) onLoss(now time.Time) {
+	f.numLost++
+	if f.numLost < mtuBlackHoleThreshold {
+		return
+	}
+	// Restart the search. Probe packets will find out which packet size the path supports now.
+	f.reset(now)
+	f.setMaxPacketSize(f.start)
+}
+
+// Reset falls back to the initial packet size and restarts the search.
+// It is called when the path changes.
+func (f *mtuFinder) Reset(now time.Time) {
+	if f.current != f.start {
+		f.setMaxPacketSize(f.start)
+	}
+	f.reset(now)
+}
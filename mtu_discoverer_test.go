@@ -0,0 +1,175 @@
+package quic
+
+import (
+	"time"
+
+	"github.com/lucas-clemente/quic-go/internal/congestion"
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("MTU Discoverer", func() {
+	const (
+		rtt                         = 100 * time.Millisecond
+		startMTU protocol.ByteCount = 1000
+		maxMTU   protocol.ByteCount = 2000
+	)
+
+	var (
+		d        mtuDiscoverer
+		rttStats *congestion.RTTStats
+		now      time.Time
+		mtus     []protocol.ByteCount
+	)
+
+	BeforeEach(func() {
+		mtus = nil
+		rttStats = &congestion.RTTStats{}
+		rttStats.UpdateRTT(rtt, 0, time.Now())
+		Expect(rttStats.SmoothedRTT()).To(Equal(rtt))
+		now = time.Now()
+		d = newMTUDiscoverer(rttStats, startMTU, maxMTU, func(s protocol.ByteCount) { mtus = append(mtus, s) }, now)
+	})
+
+	It("only probes after a few RTTs", func() {
+		Expect(d.ShouldSendProbe(now)).To(BeFalse())
+		Expect(d.ShouldSendProbe(now.Add(mtuProbeDelay * rtt).Add(-time.Nanosecond))).To(BeFalse())
+		Expect(d.ShouldSendProbe(now.Add(mtuProbeDelay * rtt))).To(BeTrue())
+	})
+
+	It("doesn't probe while a probe is in flight", func() {
+		t := now.Add(mtuProbeDelay * rtt)
+		Expect(d.ShouldSendProbe(t)).To(BeTrue())
+		_, cb := d.GetProbe(t)
+		Expect(d.ShouldSendProbe(t.Add(time.Hour))).To(BeFalse())
+		cb(true)
+		Expect(d.ShouldSendProbe(t.Add(mtuProbeDelay * rtt))).To(BeTrue())
+	})
+
+	It("increases the MTU when a probe is acknowledged", func() {
+		size, cb := d.GetProbe(now.Add(mtuProbeDelay * rtt))
+		Expect(size).To(BeNumerically(">", startMTU))
+		Expect(size).To(BeNumerically("<=", maxMTU))
+		Expect(mtus).To(BeEmpty())
+		cb(true)
+		Expect(mtus).To(Equal([]protocol.ByteCount{size}))
+	})
+
+	It("doesn't increase the MTU when a probe is lost", func() {
+		size, cb := d.GetProbe(now.Add(mtuProbeDelay * rtt))
+		cb(false)
+		Expect(mtus).To(BeEmpty())
+		// the next probe is smaller
+		nextSize, _ := d.GetProbe(now.Add(2 * mtuProbeDelay * rtt))
+		Expect(nextSize).To(BeNumerically("<", size))
+		Expect(nextSize).To(BeNumerically(">", startMTU))
+	})
+
+	It("finds the MTU", func() {
+		const pathMTU = 1500
+		t := now
+		var lastMTU protocol.ByteCount
+		for i := 0; i < 100; i++ {
+			t = t.Add(mtuProbeDelay * rtt)
+			if !d.ShouldSendProbe(t) {
+				break
+			}
+			size, cb := d.GetProbe(t)
+			if size <= pathMTU {
+				lastMTU = size
+				cb(true)
+			} else {
+				cb(false)
+			}
+		}
+		Expect(d.ShouldSendProbe(t.Add(time.Hour))).To(BeFalse())
+		Expect(mtus).ToNot(BeEmpty())
+		Expect(mtus[len(mtus)-1]).To(Equal(lastMTU))
+		Expect(lastMTU).To(BeNumerically("<=", pathMTU))
+		Expect(lastMTU).To(BeNumerically(">=", pathMTU-maxMTUDiff))
+	})
+
+	It("doesn't probe if the initial packet size is already the maximum", func() {
+		d = newMTUDiscoverer(rttStats, maxMTU, maxMTU, func(protocol.ByteCount) { Fail("unexpected MTU change") }, now)
+		Expect(d.ShouldSendProbe(now.Add(time.Hour))).To(BeFalse())
+	})
+
+	It("doesn't wrap the callback of small packets", func() {
+		Expect(d.TrackPacket(startMTU, nil)).To(BeNil())
+	})
+
+	Context("black hole detection", func() {
+		var increasedMTU protocol.ByteCount
+
+		BeforeEach(func() {
+			var cb func(bool)
+			increasedMTU, cb = d.GetProbe(now.Add(mtuProbeDelay * rtt))
+			cb(true)
+			Expect(mtus).To(Equal([]protocol.ByteCount{increasedMTU}))
+		})
+
+		It("calls the callback of the packet", func() {
+			var acked []bool
+			d.TrackPacket(increasedMTU, func(a bool) { acked = append(acked, a) })(true)
+			d.TrackPacket(increasedMTU, func(a bool) { acked = append(acked, a) })(false)
+			Expect(acked).To(Equal([]bool{true, false}))
+		})
+
+		It("falls back to the initial packet size when large packets are lost", func() {
+			var cbs []func(bool)
+			for i := 0; i < mtuBlackHoleThreshold; i++ {
+				cbs = append(cbs, d.TrackPacket(increasedMTU, nil))
+			}
+			for _, cb := range cbs[:len(cbs)-1] {
+				cb(false)
+			}
+			Expect(mtus).To(HaveLen(1))
+			cbs[len(cbs)-1](false)
+			Expect(mtus).To(Equal([]protocol.ByteCount{increasedMTU, startMTU}))
+			// the search is restarted
+			Expect(d.ShouldSendProbe(time.Now())).To(BeFalse())
+			Expect(d.ShouldSendProbe(time.Now().Add(mtuProbeDelay * rtt))).To(BeTrue())
+		})
+
+		It("doesn't count losses if a later large packet was acknowledged", func() {
+			var cbs []func(bool)
+			for i := 0; i < mtuBlackHoleThreshold; i++ {
+				cbs = append(cbs, d.TrackPacket(increasedMTU, nil))
+			}
+			// the last packet is acknowledged, so the path obviously supports large packets
+			d.TrackPacket(increasedMTU, nil)(true)
+			for _, cb := range cbs {
+				cb(false)
+			}
+			Expect(mtus).To(HaveLen(1))
+		})
+
+		It("falls back to the initial packet size after repeated probe timeouts", func() {
+			for i := 0; i < mtuBlackHoleThreshold-1; i++ {
+				d.OnProbeTimeout(now)
+			}
+			Expect(mtus).To(HaveLen(1))
+			d.OnProbeTimeout(now)
+			Expect(mtus).To(Equal([]protocol.ByteCount{increasedMTU, startMTU}))
+		})
+
+		It("ignores callbacks for packets sent before the reset", func() {
+			cb := d.TrackPacket(increasedMTU, nil)
+			d.Reset(now)
+			Expect(mtus).To(Equal([]protocol.ByteCount{increasedMTU, startMTU}))
+			for i := 0; i < mtuBlackHoleThreshold; i++ {
+				cb(false)
+			}
+			Expect(mtus).To(HaveLen(2))
+		})
+	})
+
+	It("ignores probe timeouts before the MTU was increased", func() {
+		for i := 0; i < 2*mtuBlackHoleThreshold; i++ {
+			d.OnProbeTimeout(now)
+		}
+		Expect(mtus).To(BeEmpty())
+	})
+})
@@ -7,6 +7,7 @@ import (
 	"sync"
 
 	"github.com/lucas-clemente/quic-go/internal/utils"
+	"github.com/lucas-clemente/quic-go/logging"
 )
 
 var (
@@ -15,14 +16,22 @@ var (
 )
 
 type multiplexer interface {
-	AddConn(c net.PacketConn, connIDLen int, statelessResetKey []byte) (packetHandlerManager, error)
+	AddConn(c net.PacketConn, connIDLen int, statelessResetKey []byte, metrics logging.Metrics, batchedIO, pathMTUDiscovery, ecn bool) (packetHandlerManager, error)
+	// ReleaseConn is called when a net.PacketConn passed to AddConn is not used any more.
+	// Once it was released as many times as it was added, the packetHandlerManager stops reading from it.
+	ReleaseConn(net.PacketConn)
 	RemoveConn(net.PacketConn) error
 }
 
 type connManager struct {
 	connIDLen         int
 	statelessResetKey []byte
+	metrics           logging.Metrics
+	batchedIO         bool
+	pathMTUDiscovery  bool
+	ecn               bool
 	manager           packetHandlerManager
+	refCount          int // the number of times AddConn was called, minus the number of times ReleaseConn was called
 }
 
 // The connMultiplexer listens on multiple net.PacketConns and dispatches
@@ -31,7 +40,7 @@ type connMultiplexer struct {
 	mutex sync.Mutex
 
 	conns                   map[net.PacketConn]connManager
-	newPacketHandlerManager func(net.PacketConn, int, []byte, utils.Logger) packetHandlerManager // so it can be replaced in the tests
+	newPacketHandlerManager func(net.PacketConn, int, []byte, logging.Metrics, bool, bool, bool, utils.Logger) packetHandlerManager // so it can be replaced in the tests
 
 	logger utils.Logger
 }
@@ -53,16 +62,24 @@ func (m *connMultiplexer) AddConn(
 	c net.PacketConn,
 	connIDLen int,
 	statelessResetKey []byte,
+	metrics logging.Metrics,
+	batchedIO bool,
+	pathMTUDiscovery bool,
+	ecn bool,
 ) (packetHandlerManager, error) {
 	m.mutex.Lock()
 	defer m.mutex.Unlock()
 
 	p, ok := m.conns[c]
 	if !ok {
-		manager := m.newPacketHandlerManager(c, connIDLen, statelessResetKey, m.logger)
+		manager := m.newPacketHandlerManager(c, connIDLen, statelessResetKey, metrics, batchedIO, pathMTUDiscovery, ecn, m.logger)
 		p = connManager{
 			connIDLen:         connIDLen,
 			statelessResetKey: statelessResetKey,
+			metrics:           metrics,
+			batchedIO:         batchedIO,
+			pathMTUDiscovery:  pathMTUDiscovery,
+			ecn:               ecn,
 			manager:           manager,
 		}
 		m.conns[c] = p
@@ -73,9 +90,43 @@ func (m *connMultiplexer) AddConn(
 	if statelessResetKey != nil && !bytes.Equal(p.statelessResetKey, statelessResetKey) {
 		return nil, fmt.Errorf("cannot use different stateless reset keys on the same packet conn")
 	}
+	if metrics != p.metrics {
+		return nil, fmt.Errorf("cannot use different metrics on the same packet conn")
+	}
+	if batchedIO != p.batchedIO {
+		return nil, fmt.Errorf("cannot use batched and non-batched I/O on the same packet conn")
+	}
+	if pathMTUDiscovery != p.pathMTUDiscovery {
+		return nil, fmt.Errorf("cannot enable and disable Path MTU Discovery on the same packet conn")
+	}
+	if ecn != p.ecn {
+		return nil, fmt.Errorf("cannot enable and disable ECN on the same packet conn")
+	}
+	p.refCount++
+	m.conns[c] = p
 	return p.manager, nil
 }
 
+func (m *connMultiplexer) ReleaseConn(c net.PacketConn) {
+	m.mutex.Lock()
+	p, ok := m.conns[c]
+	if !ok {
+		m.mutex.Unlock()
+		return
+	}
+	p.refCount--
+	if p.refCount > 0 {
+		m.conns[c] = p
+		m.mutex.Unlock()
+		return
+	}
+	delete(m.conns, c)
+	m.mutex.Unlock()
+
+	// Stopping blocks until the packetHandlerManager stopped reading, so it must be done without holding the mutex.
+	p.manager.StopListening()
+}
+
 func (m *connMultiplexer) RemoveConn(c net.PacketConn) error {
 	m.mutex.Lock()
 	defer m.mutex.Unlock()
@@ -1,6 +1,8 @@
 package quic
 
 import (
+	mocklogging "github.com/lucas-clemente/quic-go/internal/mocks/logging"
+
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 )
@@ -8,23 +10,78 @@ import (
 var _ = Describe("Client Multiplexer", func() {
 	It("adds a new packet conn ", func() {
 		conn := newMockPacketConn()
-		_, err := getMultiplexer().AddConn(conn, 8, nil)
+		_, err := getMultiplexer().AddConn(conn, 8, nil, nil, false, false, false)
 		Expect(err).ToNot(HaveOccurred())
 	})
 
 	It("errors when adding an existing conn with a different connection ID length", func() {
 		conn := newMockPacketConn()
-		_, err := getMultiplexer().AddConn(conn, 5, nil)
+		_, err := getMultiplexer().AddConn(conn, 5, nil, nil, false, false, false)
 		Expect(err).ToNot(HaveOccurred())
-		_, err = getMultiplexer().AddConn(conn, 6, nil)
+		_, err = getMultiplexer().AddConn(conn, 6, nil, nil, false, false, false)
 		Expect(err).To(MatchError("cannot use 6 byte connection IDs on a connection that is already using 5 byte connction IDs"))
 	})
 
 	It("errors when adding an existing conn with a different stateless rest key", func() {
 		conn := newMockPacketConn()
-		_, err := getMultiplexer().AddConn(conn, 7, []byte("foobar"))
+		_, err := getMultiplexer().AddConn(conn, 7, []byte("foobar"), nil, false, false, false)
 		Expect(err).ToNot(HaveOccurred())
-		_, err = getMultiplexer().AddConn(conn, 7, []byte("raboof"))
+		_, err = getMultiplexer().AddConn(conn, 7, []byte("raboof"), nil, false, false, false)
 		Expect(err).To(MatchError("cannot use different stateless reset keys on the same packet conn"))
 	})
+
+	It("errors when adding an existing conn with different metrics", func() {
+		conn := newMockPacketConn()
+		_, err := getMultiplexer().AddConn(conn, 7, nil, mocklogging.NewMockMetrics(mockCtrl), false, false, false)
+		Expect(err).ToNot(HaveOccurred())
+		_, err = getMultiplexer().AddConn(conn, 7, nil, mocklogging.NewMockMetrics(mockCtrl), false, false, false)
+		Expect(err).To(MatchError("cannot use different metrics on the same packet conn"))
+	})
+
+	It("errors when adding an existing conn with a different batched I/O setting", func() {
+		conn := newMockPacketConn()
+		_, err := getMultiplexer().AddConn(conn, 7, nil, nil, true, false, false)
+		Expect(err).ToNot(HaveOccurred())
+		_, err = getMultiplexer().AddConn(conn, 7, nil, nil, false, false, false)
+		Expect(err).To(MatchError("cannot use batched and non-batched I/O on the same packet conn"))
+	})
+
+	It("errors when adding an existing conn with a different Path MTU Discovery setting", func() {
+		conn := newMockPacketConn()
+		_, err := getMultiplexer().AddConn(conn, 7, nil, nil, false, true, false)
+		Expect(err).ToNot(HaveOccurred())
+		_, err = getMultiplexer().AddConn(conn, 7, nil, nil, false, false, false)
+		Expect(err).To(MatchError("cannot enable and disable Path MTU Discovery on the same packet conn"))
+	})
+
+	It("stops reading from a conn once it was released as many times as it was added", func() {
+		conn := newMockPacketConn()
+		m, err := getMultiplexer().AddConn(conn, 7, nil, nil, false, false, false)
+		Expect(err).ToNot(HaveOccurred())
+		_, err = getMultiplexer().AddConn(conn, 7, nil, nil, false, false, false)
+		Expect(err).ToNot(HaveOccurred())
+		manager := m.(*packetHandlerMap)
+		getMultiplexer().ReleaseConn(conn)
+		Consistently(manager.listening).ShouldNot(BeClosed())
+		getMultiplexer().ReleaseConn(conn)
+		Eventually(manager.listening).Should(BeClosed())
+		Expect(conn.closed).To(BeFalse())
+		// the conn can be added again
+		m2, err := getMultiplexer().AddConn(conn, 8, nil, nil, false, false, false)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(m2).ToNot(Equal(m))
+		Expect(m2.Close()).To(Succeed())
+	})
+
+	It("ignores releasing a conn that was never added", func() {
+		getMultiplexer().ReleaseConn(newMockPacketConn())
+	})
+
+	It("errors when adding an existing conn with a different ECN setting", func() {
+		conn := newMockPacketConn()
+		_, err := getMultiplexer().AddConn(conn, 7, nil, nil, false, false, true)
+		Expect(err).ToNot(HaveOccurred())
+		_, err = getMultiplexer().AddConn(conn, 7, nil, nil, false, false, false)
+		Expect(err).To(MatchError("cannot enable and disable ECN on the same packet conn"))
+	})
 })
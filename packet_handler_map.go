@@ -13,6 +13,8 @@ import (
 	"github.com/lucas-clemente/quic-go/internal/protocol"
 	"github.com/lucas-clemente/quic-go/internal/utils"
 	"github.com/lucas-clemente/quic-go/internal/wire"
+	"github.com/lucas-clemente/quic-go/logging"
+	"golang.org/x/net/ipv4"
 )
 
 // The packetHandlerMap stores packetHandlers, identified by connection ID.
@@ -31,13 +33,19 @@ type packetHandlerMap struct {
 
 	listening chan struct{} // is closed when listen returns
 	closed    bool
+	stopped   bool // set by StopListening
 
 	deleteRetiredSessionsAfter time.Duration
 
 	statelessResetEnabled bool
 	statelessResetHasher  hash.Hash
 
-	logger utils.Logger
+	batchedIO    bool
+	largeBuffers bool // Path MTU Discovery allows the peer to send packets larger than MaxReceivePacketSize
+	ecn          bool // read the ECN codepoint of received packets
+
+	metrics logging.Metrics
+	logger  utils.Logger
 }
 
 var _ packetHandlerManager = &packetHandlerMap{}
@@ -46,6 +54,10 @@ func newPacketHandlerMap(
 	conn net.PacketConn,
 	connIDLen int,
 	statelessResetKey []byte,
+	metrics logging.Metrics,
+	batchedIO bool,
+	pathMTUDiscovery bool,
+	ecn bool,
 	logger utils.Logger,
 ) packetHandlerManager {
 	m := &packetHandlerMap{
@@ -57,6 +69,10 @@ func newPacketHandlerMap(
 		deleteRetiredSessionsAfter: protocol.RetiredConnectionIDDeleteTimeout,
 		statelessResetEnabled:      len(statelessResetKey) > 0,
 		statelessResetHasher:       hmac.New(sha256.New, statelessResetKey),
+		batchedIO:                  batchedIO,
+		largeBuffers:               pathMTUDiscovery,
+		ecn:                        ecn && setECN(conn),
+		metrics:                    metrics,
 		logger:                     logger,
 	}
 	go m.listen()
@@ -126,6 +142,24 @@ func (h *packetHandlerMap) CloseServer() {
 	wg.Wait()
 }
 
+// StopListening stops reading from the underlying connection, without closing it.
+// It is used when the connection is owned by the application, and no session uses it any more.
+func (h *packetHandlerMap) StopListening() {
+	h.mutex.Lock()
+	h.stopped = true
+	h.mutex.Unlock()
+	// make the blocking read return
+	_ = h.conn.SetReadDeadline(time.Now())
+	<-h.listening
+	_ = h.conn.SetReadDeadline(time.Time{})
+}
+
+func (h *packetHandlerMap) isStopped() bool {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	return h.stopped
+}
+
 // Close the underlying connection and wait until listen() has returned.
 func (h *packetHandlerMap) Close() error {
 	if err := h.conn.Close(); err != nil {
@@ -162,28 +196,101 @@ func (h *packetHandlerMap) close(e error) error {
 
 func (h *packetHandlerMap) listen() {
 	defer close(h.listening)
+	if h.batchedIO {
+		if r := newBatchReader(h.conn); r != nil {
+			h.listenBatch(r)
+			return
+		}
+	}
+	var oob []byte
+	if h.ecn {
+		oob = make([]byte, ecnControlMsgSize)
+	}
 	for {
-		buffer := getPacketBuffer()
+		buffer := h.getPacketBuffer()
 		data := buffer.Slice
-		// The packet size should not exceed protocol.MaxReceivePacketSize bytes
+		// The packet size should not exceed the size of the buffer
 		// If it does, we only read a truncated packet, which will then end up undecryptable
-		n, addr, err := h.conn.ReadFrom(data)
+		n, addr, ecn, err := h.readPacket(data, oob)
 		if err != nil {
-			h.close(err)
+			buffer.Release()
+			if !h.isStopped() {
+				h.close(err)
+			}
 			return
 		}
-		h.handlePacket(addr, buffer, data[:n])
+		h.handlePacket(addr, ecn, buffer, data[:n])
+	}
+}
+
+// getPacketBuffer gets a buffer that is large enough to receive the packets the peers are allowed to send.
+func (h *packetHandlerMap) getPacketBuffer() *packetBuffer {
+	if h.largeBuffers {
+		return getLargePacketBuffer()
+	}
+	return getPacketBuffer()
+}
+
+// readPacket reads a single packet.
+// If oob is set, the ECN codepoint is read from the control messages.
+func (h *packetHandlerMap) readPacket(data, oob []byte) (int, net.Addr, protocol.ECN, error) {
+	if c, ok := h.conn.(*net.UDPConn); ok && oob != nil {
+		n, oobn, _, addr, err := c.ReadMsgUDP(data, oob)
+		if err != nil {
+			return 0, nil, protocol.ECNNon, err
+		}
+		return n, addr, parseECN(oob[:oobn]), nil
+	}
+	n, addr, err := h.conn.ReadFrom(data)
+	return n, addr, protocol.ECNNon, err
+}
+
+// listenBatch reads multiple packets with a single system call.
+func (h *packetHandlerMap) listenBatch(r batchReader) {
+	msgs := make([]ipv4.Message, protocol.MaxBatchSize)
+	buffers := make([]*packetBuffer, protocol.MaxBatchSize)
+	for i := range msgs {
+		buffers[i] = h.getPacketBuffer()
+		msgs[i].Buffers = [][]byte{buffers[i].Slice}
+		if h.ecn {
+			msgs[i].OOB = make([]byte, ecnControlMsgSize)
+		}
+	}
+	for {
+		n, err := r.ReadBatch(msgs, 0)
+		if err != nil {
+			for _, buffer := range buffers {
+				buffer.Release()
+			}
+			if !h.isStopped() {
+				h.close(err)
+			}
+			return
+		}
+		for i := 0; i < n; i++ {
+			ecn := protocol.ECNNon
+			if h.ecn {
+				ecn = parseECN(msgs[i].OOB[:msgs[i].NN])
+			}
+			h.handlePacket(msgs[i].Addr, ecn, buffers[i], buffers[i].Slice[:msgs[i].N])
+			buffers[i] = h.getPacketBuffer()
+			msgs[i].Buffers[0] = buffers[i].Slice
+		}
 	}
 }
 
 func (h *packetHandlerMap) handlePacket(
 	addr net.Addr,
+	ecn protocol.ECN,
 	buffer *packetBuffer,
 	data []byte,
 ) {
 	connID, err := wire.ParseConnectionID(data, h.connIDLen)
 	if err != nil {
 		h.logger.Debugf("error parsing connection ID on packet from %s: %s", addr, err)
+		if h.metrics != nil {
+			h.metrics.DroppedPacket(logging.PacketDropHeaderParseError)
+		}
 		return
 	}
 	rcvTime := time.Now()
@@ -199,6 +306,7 @@ func (h *packetHandlerMap) handlePacket(
 
 	p := &receivedPacket{
 		remoteAddr: addr,
+		ecn:        ecn,
 		rcvTime:    rcvTime,
 		buffer:     buffer,
 		data:       data,
@@ -208,11 +316,17 @@ func (h *packetHandlerMap) handlePacket(
 		return
 	}
 	if data[0]&0x80 == 0 {
+		if h.metrics != nil {
+			h.metrics.DroppedPacket(logging.PacketDropUnknownConnectionID)
+		}
 		go h.maybeSendStatelessReset(p, connID)
 		return
 	}
 	if h.server == nil { // no server set
 		h.logger.Debugf("received a packet with an unexpected connection ID %s", connID)
+		if h.metrics != nil {
+			h.metrics.DroppedPacket(logging.PacketDropUnknownConnectionID)
+		}
 		return
 	}
 	h.server.handlePacket(p)
@@ -231,6 +345,9 @@ func (h *packetHandlerMap) maybeHandleStatelessReset(data []byte) bool {
 	copy(token[:], data[len(data)-16:])
 	if sess, ok := h.resetTokens[token]; ok {
 		h.logger.Debugf("Received a stateless retry with token %#x. Closing session.", token)
+		if h.metrics != nil {
+			h.metrics.ReceivedStatelessReset()
+		}
 		go sess.destroy(errors.New("received a stateless reset"))
 		return true
 	}
@@ -270,5 +387,9 @@ func (h *packetHandlerMap) maybeSendStatelessReset(p *receivedPacket, connID pro
 	data = append(data, token[:]...)
 	if _, err := h.conn.WriteTo(data, p.remoteAddr); err != nil {
 		h.logger.Debugf("Error sending Stateless Reset: %s", err)
+		return
+	}
+	if h.metrics != nil {
+		h.metrics.SentStatelessReset()
 	}
 }
@@ -8,13 +8,33 @@ import (
 	"time"
 
 	"github.com/golang/mock/gomock"
+	mocklogging "github.com/lucas-clemente/quic-go/internal/mocks/logging"
 	"github.com/lucas-clemente/quic-go/internal/protocol"
 	"github.com/lucas-clemente/quic-go/internal/utils"
 	"github.com/lucas-clemente/quic-go/internal/wire"
+	"github.com/lucas-clemente/quic-go/logging"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	"golang.org/x/net/ipv4"
 )
 
+type mockBatchReader struct {
+	batches chan [][]byte
+	addr    net.Addr
+}
+
+func (r *mockBatchReader) ReadBatch(ms []ipv4.Message, _ int) (int, error) {
+	batch, ok := <-r.batches
+	if !ok {
+		return 0, errors.New("connection closed")
+	}
+	for i, p := range batch {
+		ms[i].N = copy(ms[i].Buffers[0], p)
+		ms[i].Addr = r.addr
+	}
+	return len(batch), nil
+}
+
 var _ = Describe("Packet Handler Map", func() {
 	var (
 		handler *packetHandlerMap
@@ -22,6 +42,8 @@ var _ = Describe("Packet Handler Map", func() {
 
 		connIDLen         int
 		statelessResetKey []byte
+		metrics           logging.Metrics
+		pathMTUDiscovery  bool
 	)
 
 	getPacketWithLength := func(connID protocol.ConnectionID, length protocol.ByteCount) []byte {
@@ -46,11 +68,13 @@ var _ = Describe("Packet Handler Map", func() {
 	BeforeEach(func() {
 		statelessResetKey = nil
 		connIDLen = 0
+		metrics = nil
+		pathMTUDiscovery = false
 	})
 
 	JustBeforeEach(func() {
 		conn = newMockPacketConn()
-		handler = newPacketHandlerMap(conn, connIDLen, statelessResetKey, utils.DefaultLogger).(*packetHandlerMap)
+		handler = newPacketHandlerMap(conn, connIDLen, statelessResetKey, metrics, false, pathMTUDiscovery, false, utils.DefaultLogger).(*packetHandlerMap)
 	})
 
 	AfterEach(func() {
@@ -88,6 +112,16 @@ var _ = Describe("Packet Handler Map", func() {
 		handler.close(testErr)
 	})
 
+	It("stops listening without closing the connection", func() {
+		handler.StopListening()
+		Expect(handler.listening).To(BeClosed())
+		Expect(conn.closed).To(BeFalse())
+		// the read deadline was reset
+		conn.dataToRead <- []byte("foobar")
+		_, _, err := conn.ReadFrom(make([]byte, 10))
+		Expect(err).ToNot(HaveOccurred())
+	})
+
 	Context("handling packets", func() {
 		BeforeEach(func() {
 			connIDLen = 5
@@ -121,8 +155,78 @@ var _ = Describe("Packet Handler Map", func() {
 			Eventually(handledPacket2).Should(BeClosed())
 		})
 
+		It("truncates packets larger than MaxReceivePacketSize", func() {
+			connID := protocol.ConnectionID{1, 2, 3, 4, 5, 6, 7, 8}
+			packetHandler := NewMockPacketHandler(mockCtrl)
+			handled := make(chan struct{})
+			packetHandler.EXPECT().handlePacket(gomock.Any()).Do(func(p *receivedPacket) {
+				Expect(p.data).To(HaveLen(int(protocol.MaxReceivePacketSize)))
+				close(handled)
+			})
+			handler.Add(connID, packetHandler)
+			conn.dataToRead <- append(getPacketWithLength(connID, 3000), make([]byte, 3000)...)
+			Eventually(handled).Should(BeClosed())
+		})
+
+		Context("with Path MTU Discovery", func() {
+			BeforeEach(func() {
+				pathMTUDiscovery = true
+			})
+
+			It("receives packets larger than MaxReceivePacketSize", func() {
+				connID := protocol.ConnectionID{1, 2, 3, 4, 5, 6, 7, 8}
+				packet := append(getPacketWithLength(connID, 3000), make([]byte, 3000)...)
+				packetHandler := NewMockPacketHandler(mockCtrl)
+				handled := make(chan struct{})
+				packetHandler.EXPECT().handlePacket(gomock.Any()).Do(func(p *receivedPacket) {
+					Expect(p.data).To(Equal(packet))
+					close(handled)
+				})
+				handler.Add(connID, packetHandler)
+				conn.dataToRead <- packet
+				Eventually(handled).Should(BeClosed())
+			})
+		})
+
+		It("reads packets in batches", func() {
+			connID1 := protocol.ConnectionID{1, 2, 3, 4, 5, 6, 7, 8}
+			connID2 := protocol.ConnectionID{8, 7, 6, 5, 4, 3, 2, 1}
+			addr := &net.UDPAddr{IP: net.IPv4(1, 2, 3, 4), Port: 1234}
+			packetHandler1 := NewMockPacketHandler(mockCtrl)
+			packetHandler2 := NewMockPacketHandler(mockCtrl)
+			handledPacket1 := make(chan struct{})
+			handledPacket2 := make(chan struct{})
+			packetHandler1.EXPECT().handlePacket(gomock.Any()).Do(func(p *receivedPacket) {
+				Expect(p.remoteAddr).To(Equal(addr))
+				Expect(p.data).To(Equal(getPacket(connID1)))
+				close(handledPacket1)
+			})
+			packetHandler2.EXPECT().handlePacket(gomock.Any()).Do(func(p *receivedPacket) {
+				Expect(p.remoteAddr).To(Equal(addr))
+				Expect(p.data).To(Equal(getPacket(connID2)))
+				close(handledPacket2)
+			})
+			handler.Add(connID1, packetHandler1)
+			handler.Add(connID2, packetHandler2)
+
+			reader := &mockBatchReader{batches: make(chan [][]byte, 1), addr: addr}
+			reader.batches <- [][]byte{getPacket(connID1), getPacket(connID2)}
+			done := make(chan struct{})
+			go func() {
+				defer GinkgoRecover()
+				defer close(done)
+				handler.listenBatch(reader)
+			}()
+			Eventually(handledPacket1).Should(BeClosed())
+			Eventually(handledPacket2).Should(BeClosed())
+			handler.Remove(connID1)
+			handler.Remove(connID2)
+			close(reader.batches)
+			Eventually(done).Should(BeClosed())
+		})
+
 		It("drops unparseable packets", func() {
-			handler.handlePacket(nil, nil, []byte{0, 1, 2, 3})
+			handler.handlePacket(nil, protocol.ECNNon, nil, []byte{0, 1, 2, 3})
 		})
 
 		It("deletes removed sessions immediately", func() {
@@ -130,7 +234,7 @@ var _ = Describe("Packet Handler Map", func() {
 			connID := protocol.ConnectionID{1, 2, 3, 4, 5, 6, 7, 8}
 			handler.Add(connID, NewMockPacketHandler(mockCtrl))
 			handler.Remove(connID)
-			handler.handlePacket(nil, nil, getPacket(connID))
+			handler.handlePacket(nil, protocol.ECNNon, nil, getPacket(connID))
 			// don't EXPECT any calls to handlePacket of the MockPacketHandler
 		})
 
@@ -140,7 +244,7 @@ var _ = Describe("Packet Handler Map", func() {
 			handler.Add(connID, NewMockPacketHandler(mockCtrl))
 			handler.Retire(connID)
 			time.Sleep(scaleDuration(30 * time.Millisecond))
-			handler.handlePacket(nil, nil, getPacket(connID))
+			handler.handlePacket(nil, protocol.ECNNon, nil, getPacket(connID))
 			// don't EXPECT any calls to handlePacket of the MockPacketHandler
 		})
 
@@ -154,13 +258,13 @@ var _ = Describe("Packet Handler Map", func() {
 			})
 			handler.Add(connID, packetHandler)
 			handler.Retire(connID)
-			handler.handlePacket(nil, nil, getPacket(connID))
+			handler.handlePacket(nil, protocol.ECNNon, nil, getPacket(connID))
 			Eventually(handled).Should(BeClosed())
 		})
 
 		It("drops packets for unknown receivers", func() {
 			connID := protocol.ConnectionID{1, 2, 3, 4, 5, 6, 7, 8}
-			handler.handlePacket(nil, nil, getPacket(connID))
+			handler.handlePacket(nil, protocol.ECNNon, nil, getPacket(connID))
 		})
 
 		It("closes the packet handlers when reading from the conn fails", func() {
@@ -187,7 +291,7 @@ var _ = Describe("Packet Handler Map", func() {
 				Expect(cid).To(Equal(connID))
 			})
 			handler.SetServer(server)
-			handler.handlePacket(nil, nil, p)
+			handler.handlePacket(nil, protocol.ECNNon, nil, p)
 		})
 
 		It("closes all server sessions", func() {
@@ -209,7 +313,7 @@ var _ = Describe("Packet Handler Map", func() {
 			// don't EXPECT any calls to server.handlePacket
 			handler.SetServer(server)
 			handler.CloseServer()
-			handler.handlePacket(nil, nil, p)
+			handler.handlePacket(nil, protocol.ECNNon, nil, p)
 		})
 	})
 
@@ -260,7 +364,7 @@ var _ = Describe("Packet Handler Map", func() {
 				p := append([]byte{0x40} /* short header packet */, connID.Bytes()...)
 				p = append(p, make([]byte, 50)...)
 				p = append(p, token[:]...)
-				handler.handlePacket(nil, nil, p)
+				handler.handlePacket(nil, protocol.ECNNon, nil, p)
 				// destroy() would be called from a separate go routine
 				// make sure we give it enough time to be called to cause an error here
 				time.Sleep(scaleDuration(25 * time.Millisecond))
@@ -285,7 +389,7 @@ var _ = Describe("Packet Handler Map", func() {
 			It("sends stateless resets", func() {
 				addr := &net.UDPAddr{IP: net.IPv4(192, 168, 0, 1), Port: 1337}
 				p := append([]byte{40}, make([]byte, 100)...)
-				handler.handlePacket(addr, getPacketBuffer(), p)
+				handler.handlePacket(addr, protocol.ECNNon, getPacketBuffer(), p)
 				var reset mockPacketConnWrite
 				Eventually(conn.dataWritten).Should(Receive(&reset))
 				Expect(reset.to).To(Equal(addr))
@@ -296,7 +400,7 @@ var _ = Describe("Packet Handler Map", func() {
 			It("doesn't send stateless resets for small packets", func() {
 				addr := &net.UDPAddr{IP: net.IPv4(192, 168, 0, 1), Port: 1337}
 				p := append([]byte{40}, make([]byte, protocol.MinStatelessResetSize-2)...)
-				handler.handlePacket(addr, getPacketBuffer(), p)
+				handler.handlePacket(addr, protocol.ECNNon, getPacketBuffer(), p)
 				Consistently(conn.dataWritten).ShouldNot(Receive())
 			})
 		})
@@ -305,9 +409,52 @@ var _ = Describe("Packet Handler Map", func() {
 			It("doesn't send stateless resets", func() {
 				addr := &net.UDPAddr{IP: net.IPv4(192, 168, 0, 1), Port: 1337}
 				p := append([]byte{40}, make([]byte, 100)...)
-				handler.handlePacket(addr, getPacketBuffer(), p)
+				handler.handlePacket(addr, protocol.ECNNon, getPacketBuffer(), p)
 				Consistently(conn.dataWritten).ShouldNot(Receive())
 			})
 		})
 	})
+
+	Context("collecting metrics", func() {
+		var mockMetrics *mocklogging.MockMetrics
+
+		BeforeEach(func() {
+			connIDLen = 5
+			mockMetrics = mocklogging.NewMockMetrics(mockCtrl)
+			metrics = mockMetrics
+		})
+
+		It("counts unparseable packets", func() {
+			mockMetrics.EXPECT().DroppedPacket(logging.PacketDropHeaderParseError)
+			handler.handlePacket(nil, protocol.ECNNon, nil, []byte{0, 1, 2, 3})
+		})
+
+		It("counts packets for unknown receivers", func() {
+			mockMetrics.EXPECT().DroppedPacket(logging.PacketDropUnknownConnectionID)
+			handler.handlePacket(nil, protocol.ECNNon, nil, getPacket(protocol.ConnectionID{1, 2, 3, 4, 5, 6, 7, 8}))
+		})
+
+		It("counts short header packets for unknown receivers, and the stateless resets sent", func() {
+			handler.statelessResetEnabled = true
+			sent := make(chan struct{})
+			mockMetrics.EXPECT().DroppedPacket(logging.PacketDropUnknownConnectionID)
+			mockMetrics.EXPECT().SentStatelessReset().Do(func() { close(sent) })
+			addr := &net.UDPAddr{IP: net.IPv4(192, 168, 0, 1), Port: 1337}
+			handler.handlePacket(addr, protocol.ECNNon, getPacketBuffer(), append([]byte{0x40}, make([]byte, 100)...))
+			Eventually(conn.dataWritten).Should(Receive())
+			Eventually(sent).Should(BeClosed())
+		})
+
+		It("counts received stateless resets", func() {
+			packetHandler := NewMockPacketHandler(mockCtrl)
+			token := [16]byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}
+			handler.AddResetToken(token, packetHandler)
+			destroyed := make(chan struct{})
+			packetHandler.EXPECT().destroy(gomock.Any()).Do(func(error) { close(destroyed) })
+			mockMetrics.EXPECT().ReceivedStatelessReset()
+			packet := append([]byte{0x40} /* short header packet */, make([]byte, 50)...)
+			handler.handlePacket(nil, protocol.ECNNon, nil, append(packet, token[:]...))
+			Eventually(destroyed).Should(BeClosed())
+		})
+	})
 })
@@ -19,8 +19,11 @@ type packer interface {
 	MaybePackAckPacket() (*packedPacket, error)
 	PackRetransmission(packet *ackhandler.Packet) ([]*packedPacket, error)
 	PackConnectionClose(*wire.ConnectionCloseFrame) (*packedPacket, error)
+	PackPathValidationPacket(wire.Frame) (*packedPacket, error)
+	PackMTUProbePacket(size protocol.ByteCount) (*packedPacket, error)
 
 	HandleTransportParameters(*handshake.TransportParameters)
+	SetMaxPacketSize(protocol.ByteCount)
 	SetToken([]byte)
 	ChangeDestConnectionID(protocol.ConnectionID)
 }
@@ -30,6 +33,12 @@ type packedPacket struct {
 	raw    []byte
 	frames []wire.Frame
 
+	// ackCallback is called when the packet is acknowledged or declared lost.
+	// It is set if the packet contains a DATAGRAM frame.
+	ackCallback func(acked bool)
+	// isMTUProbePacket is set for packets that probe for a larger Path MTU.
+	isMTUProbePacket bool
+
 	buffer *packetBuffer
 }
 
@@ -59,6 +68,9 @@ func (p *packedPacket) ToAckHandlerPacket() *ackhandler.Packet {
 		Length:          protocol.ByteCount(len(p.raw)),
 		EncryptionLevel: p.EncryptionLevel(),
 		SendTime:        time.Now(),
+		AckCallback:     p.ackCallback,
+
+		IsPathMTUProbePacket: p.isMTUProbePacket,
 	}
 }
 
@@ -111,9 +123,10 @@ type packetPacker struct {
 
 	token []byte
 
-	pnManager packetNumberManager
-	framer    frameSource
-	acks      ackFrameSource
+	pnManager     packetNumberManager
+	framer        frameSource
+	acks          ackFrameSource
+	datagramQueue *datagramQueue
 
 	maxPacketSize          protocol.ByteCount
 	numNonAckElicitingAcks int
@@ -131,6 +144,7 @@ func newPacketPacker(
 	cryptoSetup sealingManager,
 	framer frameSource,
 	acks ackFrameSource,
+	datagramQueue *datagramQueue,
 	perspective protocol.Perspective,
 	version protocol.VersionNumber,
 ) *packetPacker {
@@ -144,6 +158,7 @@ func newPacketPacker(
 		version:         version,
 		framer:          framer,
 		acks:            acks,
+		datagramQueue:   datagramQueue,
 		pnManager:       packetNumberManager,
 		maxPacketSize:   getMaxPacketSize(remoteAddr),
 	}
@@ -157,6 +172,34 @@ func (p *packetPacker) PackConnectionClose(ccf *wire.ConnectionCloseFrame) (*pac
 	return p.writeAndSealPacket(header, frames, encLevel, sealer)
 }
 
+// PackPathValidationPacket packs a packet that ONLY contains a PATH_CHALLENGE or a PATH_RESPONSE frame.
+// It is used to send these frames on a path other than the current one.
+func (p *packetPacker) PackPathValidationPacket(f wire.Frame) (*packedPacket, error) {
+	sealer, err := p.cryptoSetup.GetSealerWithEncryptionLevel(protocol.Encryption1RTT)
+	if err != nil {
+		return nil, err
+	}
+	header := p.getHeader(protocol.Encryption1RTT)
+	return p.writeAndSealPacket(header, []wire.Frame{f}, protocol.Encryption1RTT, sealer)
+}
+
+// PackMTUProbePacket packs a packet that is padded to size bytes.
+// It only contains a PING frame, and is used to probe if the path supports packets of this size.
+// The size may be larger than the current maximum packet size.
+func (p *packetPacker) PackMTUProbePacket(size protocol.ByteCount) (*packedPacket, error) {
+	sealer, err := p.cryptoSetup.GetSealerWithEncryptionLevel(protocol.Encryption1RTT)
+	if err != nil {
+		return nil, err
+	}
+	header := p.getHeader(protocol.Encryption1RTT)
+	packet, err := p.writeAndSealPacketWithPadding(header, []wire.Frame{&wire.PingFrame{}}, protocol.Encryption1RTT, sealer, size)
+	if err != nil {
+		return nil, err
+	}
+	packet.isMTUProbePacket = true
+	return packet, nil
+}
+
 func (p *packetPacker) MaybePackAckPacket() (*packedPacket, error) {
 	ack := p.acks.GetAckFrame(protocol.Encryption1RTT)
 	if ack == nil {
@@ -179,13 +222,23 @@ func (p *packetPacker) PackRetransmission(packet *ackhandler.Packet) ([]*packedP
 		// CRYPTO frames are treated as control frames here.
 		// Since we're making sure that the header can never be larger for a retransmission,
 		// we never have to split CRYPTO frames.
-		if sf, ok := f.(*wire.StreamFrame); ok {
-			sf.DataLenPresent = true
-			streamFrames = append(streamFrames, sf)
-		} else {
+		switch frame := f.(type) {
+		case *wire.StreamFrame:
+			frame.DataLenPresent = true
+			streamFrames = append(streamFrames, frame)
+		case *wire.PathChallengeFrame, *wire.PathResponseFrame:
+			// PATH_CHALLENGE and PATH_RESPONSE frames are only valid on the path they were sent on.
+			// They are never retransmitted.
+		case *wire.DatagramFrame:
+			// DATAGRAM frames are unreliable. They are never retransmitted.
+		default:
 			controlFrames = append(controlFrames, f)
 		}
 	}
+	if len(controlFrames) == 0 && len(streamFrames) == 0 {
+		// make sure that the retransmission is still ack-eliciting
+		controlFrames = append(controlFrames, &wire.PingFrame{})
+	}
 
 	var packets []*packedPacket
 	encLevel := packet.EncryptionLevel
@@ -261,7 +314,7 @@ func (p *packetPacker) PackPacket() (*packedPacket, error) {
 	}
 
 	maxSize := p.maxPacketSize - protocol.ByteCount(sealer.Overhead()) - headerLen
-	frames, err := p.composeNextPacket(maxSize)
+	frames, ackCallback, err := p.composeNextPacket(maxSize, encLevel)
 	if err != nil {
 		return nil, err
 	}
@@ -282,7 +335,12 @@ func (p *packetPacker) PackPacket() (*packedPacket, error) {
 		p.numNonAckElicitingAcks = 0
 	}
 
-	return p.writeAndSealPacket(header, frames, encLevel, sealer)
+	packet, err = p.writeAndSealPacket(header, frames, encLevel, sealer)
+	if err != nil {
+		return nil, err
+	}
+	packet.ackCallback = ackCallback
+	return packet, nil
 }
 
 func (p *packetPacker) maybePackCryptoPacket() (*packedPacket, error) {
@@ -326,7 +384,7 @@ func (p *packetPacker) maybePackCryptoPacket() (*packedPacket, error) {
 	return p.writeAndSealPacket(hdr, frames, encLevel, sealer)
 }
 
-func (p *packetPacker) composeNextPacket(maxFrameSize protocol.ByteCount) ([]wire.Frame, error) {
+func (p *packetPacker) composeNextPacket(maxFrameSize protocol.ByteCount, encLevel protocol.EncryptionLevel) ([]wire.Frame, func(acked bool), error) {
 	var length protocol.ByteCount
 	var frames []wire.Frame
 
@@ -340,6 +398,15 @@ func (p *packetPacker) composeNextPacket(maxFrameSize protocol.ByteCount) ([]wir
 	frames, lengthAdded = p.framer.AppendControlFrames(frames, maxFrameSize-length)
 	length += lengthAdded
 
+	// DATAGRAM frames are only sent in 1-RTT packets, and they are never split.
+	// If the next DATAGRAM frame doesn't fit into this packet, it is sent in the next one.
+	var ackCallback func(acked bool)
+	if f := p.datagramQueue.Peek(); f != nil && encLevel == protocol.Encryption1RTT && length+f.Length(p.version) <= maxFrameSize {
+		frames = append(frames, f)
+		length += f.Length(p.version)
+		ackCallback = p.datagramQueue.Pop()
+	}
+
 	// temporarily increase the maxFrameSize by the (minimum) length of the DataLen field
 	// this leads to a properly sized packet in all cases, since we do all the packet length calculations with STREAM frames that have the DataLen set
 	// however, for the last STREAM frame in the packet, we can omit the DataLen, thus yielding a packet of exactly the correct size
@@ -353,7 +420,7 @@ func (p *packetPacker) composeNextPacket(maxFrameSize protocol.ByteCount) ([]wir
 			sf.DataLenPresent = false
 		}
 	}
-	return frames, nil
+	return frames, ackCallback, nil
 }
 
 func (p *packetPacker) getHeader(encLevel protocol.EncryptionLevel) *wire.ExtendedHeader {
@@ -391,18 +458,48 @@ func (p *packetPacker) writeAndSealPacket(
 	encLevel protocol.EncryptionLevel,
 	sealer handshake.Sealer,
 ) (*packedPacket, error) {
-	packetBuffer := getPacketBuffer()
-	buffer := bytes.NewBuffer(packetBuffer.Slice[:0])
+	var padTo protocol.ByteCount
+	if p.perspective == protocol.PerspectiveClient && header.Type == protocol.PacketTypeInitial {
+		padTo = protocol.MinInitialPacketSize
+	}
+	return p.writeAndSealPacketWithPadding(header, frames, encLevel, sealer, padTo)
+}
+
+// writeAndSealPacketWithPadding writes a packet.
+// If padTo is not 0, the packet is padded to padTo bytes.
+func (p *packetPacker) writeAndSealPacketWithPadding(
+	header *wire.ExtendedHeader,
+	frames []wire.Frame,
+	encLevel protocol.EncryptionLevel,
+	sealer handshake.Sealer,
+	padTo protocol.ByteCount,
+) (*packedPacket, error) {
+	if !header.IsLongHeader {
+		if s, ok := sealer.(handshake.ShortHeaderSealer); ok {
+			kp, err := s.KeyPhase()
+			if err != nil {
+				return nil, err
+			}
+			header.KeyPhase = kp
+		}
+	}
 
-	addPaddingForInitial := p.perspective == protocol.PerspectiveClient && header.Type == protocol.PacketTypeInitial
+	var packetBuffer *packetBuffer
+	// Large buffers are only needed when Path MTU Discovery increased the packet size.
+	if p.maxPacketSize > protocol.MaxReceivePacketSize || padTo > protocol.MaxReceivePacketSize {
+		packetBuffer = getLargePacketBuffer()
+	} else {
+		packetBuffer = getPacketBuffer()
+	}
+	buffer := bytes.NewBuffer(packetBuffer.Slice[:0])
 
 	if header.IsLongHeader {
 		if p.perspective == protocol.PerspectiveClient && header.Type == protocol.PacketTypeInitial {
 			header.Token = p.token
 		}
-		if addPaddingForInitial {
+		if padTo > 0 {
 			headerLen := header.GetLength(p.version)
-			header.Length = protocol.ByteCount(header.PacketNumberLen) + protocol.MinInitialPacketSize - headerLen
+			header.Length = protocol.ByteCount(header.PacketNumberLen) + padTo - headerLen
 		} else {
 			// long header packets always use 4 byte packet number, so we never need to pad short payloads
 			length := protocol.ByteCount(sealer.Overhead()) + protocol.ByteCount(header.PacketNumberLen)
@@ -425,7 +522,7 @@ func (p *packetPacker) writeAndSealPacket(
 		}
 	}
 	lastFrame := frames[len(frames)-1]
-	if addPaddingForInitial {
+	if padTo > 0 {
 		// when appending padding, we need to make sure that the last STREAM frames has the data length set
 		if sf, ok := lastFrame.(*wire.StreamFrame); ok {
 			sf.DataLenPresent = true
@@ -442,15 +539,16 @@ func (p *packetPacker) writeAndSealPacket(
 		return nil, err
 	}
 
-	if addPaddingForInitial {
-		paddingLen := protocol.MinInitialPacketSize - sealer.Overhead() - buffer.Len()
+	if padTo > 0 {
+		paddingLen := int(padTo) - sealer.Overhead() - buffer.Len()
 		if paddingLen > 0 {
 			buffer.Write(bytes.Repeat([]byte{0}, paddingLen))
 		}
 	}
 
-	if size := protocol.ByteCount(buffer.Len() + sealer.Overhead()); size > p.maxPacketSize {
-		return nil, fmt.Errorf("PacketPacker BUG: packet too large (%d bytes, allowed %d bytes)", size, p.maxPacketSize)
+	maxPacketSize := utils.MaxByteCount(p.maxPacketSize, padTo)
+	if size := protocol.ByteCount(buffer.Len() + sealer.Overhead()); size > maxPacketSize {
+		return nil, fmt.Errorf("PacketPacker BUG: packet too large (%d bytes, allowed %d bytes)", size, maxPacketSize)
 	}
 
 	raw := buffer.Bytes()
@@ -489,3 +587,9 @@ func (p *packetPacker) HandleTransportParameters(params *handshake.TransportPara
 		p.maxPacketSize = utils.MinByteCount(p.maxPacketSize, params.MaxPacketSize)
 	}
 }
+
+// SetMaxPacketSize sets the maximum packet size.
+// It is called when Path MTU Discovery changes the packet size.
+func (p *packetPacker) SetMaxPacketSize(s protocol.ByteCount) {
+	p.maxPacketSize = s
+}
@@ -2,6 +2,7 @@ package quic
 
 import (
 	"bytes"
+	"errors"
 	"math/rand"
 	"net"
 
@@ -11,6 +12,7 @@ import (
 	"github.com/lucas-clemente/quic-go/internal/mocks"
 	mockackhandler "github.com/lucas-clemente/quic-go/internal/mocks/ackhandler"
 	"github.com/lucas-clemente/quic-go/internal/protocol"
+	"github.com/lucas-clemente/quic-go/internal/qerr"
 	"github.com/lucas-clemente/quic-go/internal/wire"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -26,6 +28,7 @@ var _ = Describe("Packet packer", func() {
 		handshakeStream *MockCryptoStream
 		sealingManager  *MockSealingManager
 		pnManager       *mockackhandler.MockSentPacketHandler
+		datagramQueue   *datagramQueue
 	)
 
 	checkLength := func(data []byte) {
@@ -64,6 +67,7 @@ var _ = Describe("Packet packer", func() {
 		ackFramer = NewMockAckFrameSource(mockCtrl)
 		sealingManager = NewMockSealingManager(mockCtrl)
 		pnManager = mockackhandler.NewMockSentPacketHandler(mockCtrl)
+		datagramQueue = newDatagramQueue(func() {}, version)
 
 		packer = newPacketPacker(
 			protocol.ConnectionID{1, 2, 3, 4, 5, 6, 7, 8},
@@ -75,6 +79,7 @@ var _ = Describe("Packet packer", func() {
 			sealingManager,
 			framer,
 			ackFramer,
+			datagramQueue,
 			protocol.PerspectiveServer,
 			version,
 		)
@@ -257,6 +262,47 @@ var _ = Describe("Packet packer", func() {
 				Expect(p.EncryptionLevel()).To(Equal(protocol.Encryption1RTT))
 			})
 
+			It("sets the key phase for short header packets", func() {
+				shSealer := mocks.NewMockShortHeaderSealer(mockCtrl)
+				shSealer.EXPECT().Overhead().Return(7).AnyTimes()
+				shSealer.EXPECT().EncryptHeader(gomock.Any(), gomock.Any(), gomock.Any())
+				shSealer.EXPECT().Seal(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(func(dst, src []byte, pn protocol.PacketNumber, associatedData []byte) []byte {
+					return append(src, bytes.Repeat([]byte{0}, 7)...)
+				})
+				shSealer.EXPECT().KeyPhase().Return(protocol.KeyPhaseOne, nil)
+				pnManager.EXPECT().PeekPacketNumber(protocol.Encryption1RTT).Return(protocol.PacketNumber(0x42), protocol.PacketNumberLen2)
+				pnManager.EXPECT().PopPacketNumber(protocol.Encryption1RTT).Return(protocol.PacketNumber(0x42))
+				sealingManager.EXPECT().GetSealer().Return(protocol.Encryption1RTT, shSealer)
+				ackFramer.EXPECT().GetAckFrame(protocol.Encryption1RTT)
+				expectAppendControlFrames()
+				expectAppendStreamFrames(&wire.StreamFrame{
+					StreamID: 5,
+					Data:     []byte("foobar"),
+				})
+				p, err := packer.PackPacket()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(p.header.KeyPhase).To(Equal(protocol.KeyPhaseOne))
+				Expect(p.raw[0] & 0x4).ToNot(BeZero())
+			})
+
+			It("returns the error when the key phase can't be determined", func() {
+				shSealer := mocks.NewMockShortHeaderSealer(mockCtrl)
+				shSealer.EXPECT().Overhead().Return(7).AnyTimes()
+				testErr := qerr.Error(qerr.AEADLimitReached, "")
+				shSealer.EXPECT().KeyPhase().Return(protocol.KeyPhaseZero, testErr)
+				pnManager.EXPECT().PeekPacketNumber(protocol.Encryption1RTT).Return(protocol.PacketNumber(0x42), protocol.PacketNumberLen2)
+				// don't expect any calls to PopPacketNumber
+				sealingManager.EXPECT().GetSealer().Return(protocol.Encryption1RTT, shSealer)
+				ackFramer.EXPECT().GetAckFrame(protocol.Encryption1RTT)
+				expectAppendControlFrames()
+				expectAppendStreamFrames(&wire.StreamFrame{
+					StreamID: 5,
+					Data:     []byte("foobar"),
+				})
+				_, err := packer.PackPacket()
+				Expect(err).To(MatchError(testErr))
+			})
+
 			It("packs a single ACK", func() {
 				pnManager.EXPECT().PeekPacketNumber(protocol.Encryption1RTT).Return(protocol.PacketNumber(0x42), protocol.PacketNumberLen2)
 				pnManager.EXPECT().PopPacketNumber(protocol.Encryption1RTT).Return(protocol.PacketNumber(0x42))
@@ -286,6 +332,54 @@ var _ = Describe("Packet packer", func() {
 				Expect(p.frames[0]).To(Equal(&ccf))
 			})
 
+			It("packs a PATH_CHALLENGE", func() {
+				pnManager.EXPECT().PeekPacketNumber(protocol.Encryption1RTT).Return(protocol.PacketNumber(0x42), protocol.PacketNumberLen2)
+				pnManager.EXPECT().PopPacketNumber(protocol.Encryption1RTT).Return(protocol.PacketNumber(0x42))
+				// expect no calls to the framer
+				sealingManager.EXPECT().GetSealerWithEncryptionLevel(protocol.Encryption1RTT).Return(sealer, nil)
+				f := &wire.PathChallengeFrame{Data: [8]byte{1, 2, 3, 4, 5, 6, 7, 8}}
+				p, err := packer.PackPathValidationPacket(f)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(p.frames).To(Equal([]wire.Frame{f}))
+				Expect(p.EncryptionLevel()).To(Equal(protocol.Encryption1RTT))
+			})
+
+			It("doesn't pack a PATH_CHALLENGE before the handshake completes", func() {
+				testErr := errors.New("no 1-RTT sealer")
+				sealingManager.EXPECT().GetSealerWithEncryptionLevel(protocol.Encryption1RTT).Return(nil, testErr)
+				_, err := packer.PackPathValidationPacket(&wire.PathChallengeFrame{})
+				Expect(err).To(MatchError(testErr))
+			})
+
+			It("packs an MTU probe packet", func() {
+				pnManager.EXPECT().PeekPacketNumber(protocol.Encryption1RTT).Return(protocol.PacketNumber(0x43), protocol.PacketNumberLen2)
+				pnManager.EXPECT().PopPacketNumber(protocol.Encryption1RTT).Return(protocol.PacketNumber(0x43))
+				sealingManager.EXPECT().GetSealerWithEncryptionLevel(protocol.Encryption1RTT).Return(sealer, nil)
+				p, err := packer.PackMTUProbePacket(maxPacketSize + 100)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(p.raw).To(HaveLen(int(maxPacketSize + 100)))
+				Expect(p.frames).To(Equal([]wire.Frame{&wire.PingFrame{}}))
+				Expect(p.EncryptionLevel()).To(Equal(protocol.Encryption1RTT))
+				Expect(p.ToAckHandlerPacket().IsPathMTUProbePacket).To(BeTrue())
+			})
+
+			It("uses a large buffer for MTU probe packets larger than MaxReceivePacketSize", func() {
+				pnManager.EXPECT().PeekPacketNumber(protocol.Encryption1RTT).Return(protocol.PacketNumber(0x43), protocol.PacketNumberLen2)
+				pnManager.EXPECT().PopPacketNumber(protocol.Encryption1RTT).Return(protocol.PacketNumber(0x43))
+				sealingManager.EXPECT().GetSealerWithEncryptionLevel(protocol.Encryption1RTT).Return(sealer, nil)
+				p, err := packer.PackMTUProbePacket(protocol.MaxReceivePacketSize + 1)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(p.raw).To(HaveLen(int(protocol.MaxReceivePacketSize + 1)))
+				Expect(p.buffer.Slice).To(HaveCap(int(protocol.MaxPacketSizePMTUD)))
+			})
+
+			It("doesn't pack an MTU probe packet before the handshake completes", func() {
+				testErr := errors.New("no 1-RTT sealer")
+				sealingManager.EXPECT().GetSealerWithEncryptionLevel(protocol.Encryption1RTT).Return(nil, testErr)
+				_, err := packer.PackMTUProbePacket(maxPacketSize + 100)
+				Expect(err).To(MatchError(testErr))
+			})
+
 			It("packs control frames", func() {
 				pnManager.EXPECT().PeekPacketNumber(protocol.Encryption1RTT).Return(protocol.PacketNumber(0x42), protocol.PacketNumberLen2)
 				pnManager.EXPECT().PopPacketNumber(protocol.Encryption1RTT).Return(protocol.PacketNumber(0x42))
@@ -320,6 +414,71 @@ var _ = Describe("Packet packer", func() {
 				Expect(err).ToNot(HaveOccurred())
 			})
 
+			It("packs DATAGRAM frames", func() {
+				pnManager.EXPECT().PeekPacketNumber(protocol.Encryption1RTT).Return(protocol.PacketNumber(0x42), protocol.PacketNumberLen2)
+				pnManager.EXPECT().PopPacketNumber(protocol.Encryption1RTT).Return(protocol.PacketNumber(0x42))
+				sealingManager.EXPECT().GetSealer().Return(protocol.Encryption1RTT, sealer)
+				ackFramer.EXPECT().GetAckFrame(protocol.Encryption1RTT)
+				mdf := &wire.MaxDataFrame{ByteOffset: 0x1337}
+				expectAppendControlFrames(mdf)
+				sf := &wire.StreamFrame{
+					StreamID: 5,
+					Data:     []byte("foobar"),
+				}
+				expectAppendStreamFrames(sf)
+				datagramQueue.SetPeerMaxFrameSize(protocol.MaxDatagramFrameSize)
+				var acked []bool
+				Expect(datagramQueue.AddAndWait([]byte("lorem ipsum"), func(a bool) { acked = append(acked, a) })).To(Succeed())
+				p, err := packer.PackPacket()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(p.frames).To(HaveLen(3))
+				Expect(p.frames[0]).To(Equal(mdf))
+				Expect(p.frames[1]).To(BeAssignableToTypeOf(&wire.DatagramFrame{}))
+				Expect(p.frames[1].(*wire.DatagramFrame).Data).To(Equal([]byte("lorem ipsum")))
+				Expect(p.frames[2]).To(Equal(sf))
+				Expect(datagramQueue.Peek()).To(BeNil())
+				// the ack callback is passed on to the sent packet handler
+				ackhandlerPacket := p.ToAckHandlerPacket()
+				Expect(ackhandlerPacket.AckCallback).ToNot(BeNil())
+				ackhandlerPacket.AckCallback(true)
+				Expect(acked).To(Equal([]bool{true}))
+			})
+
+			It("doesn't pack a DATAGRAM frame that doesn't fit into the packet", func() {
+				pnManager.EXPECT().PeekPacketNumber(protocol.Encryption1RTT).Return(protocol.PacketNumber(0x42), protocol.PacketNumberLen2)
+				pnManager.EXPECT().PopPacketNumber(protocol.Encryption1RTT).Return(protocol.PacketNumber(0x42))
+				sealingManager.EXPECT().GetSealer().Return(protocol.Encryption1RTT, sealer)
+				ackFramer.EXPECT().GetAckFrame(protocol.Encryption1RTT)
+				framer.EXPECT().AppendControlFrames(gomock.Any(), gomock.Any()).DoAndReturn(func(fs []wire.Frame, maxLen protocol.ByteCount) ([]wire.Frame, protocol.ByteCount) {
+					f := &wire.PingFrame{}
+					return append(fs, f), maxLen - 100
+				})
+				expectAppendStreamFrames()
+				datagramQueue.SetPeerMaxFrameSize(protocol.MaxDatagramFrameSize)
+				Expect(datagramQueue.AddAndWait(make([]byte, 200), nil)).To(Succeed())
+				p, err := packer.PackPacket()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(p.frames).To(Equal([]wire.Frame{&wire.PingFrame{}}))
+				Expect(p.ackCallback).To(BeNil())
+				// the DATAGRAM frame will be sent in the next packet
+				Expect(datagramQueue.Peek()).ToNot(BeNil())
+			})
+
+			It("doesn't pack DATAGRAM frames into Handshake packets", func() {
+				pnManager.EXPECT().PeekPacketNumber(protocol.EncryptionHandshake).Return(protocol.PacketNumber(0x42), protocol.PacketNumberLen2)
+				pnManager.EXPECT().PopPacketNumber(protocol.EncryptionHandshake).Return(protocol.PacketNumber(0x42))
+				sealingManager.EXPECT().GetSealer().Return(protocol.EncryptionHandshake, sealer)
+				ackFramer.EXPECT().GetAckFrame(protocol.Encryption1RTT)
+				expectAppendControlFrames(&wire.PingFrame{})
+				expectAppendStreamFrames()
+				datagramQueue.SetPeerMaxFrameSize(protocol.MaxDatagramFrameSize)
+				Expect(datagramQueue.AddAndWait([]byte("foobar"), nil)).To(Succeed())
+				p, err := packer.PackPacket()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(p.frames).To(Equal([]wire.Frame{&wire.PingFrame{}}))
+				Expect(datagramQueue.Peek()).ToNot(BeNil())
+			})
+
 			Context("packing ACK packets", func() {
 				It("doesn't pack a packet if there's no ACK to send", func() {
 					ackFramer.EXPECT().GetAckFrame(protocol.Encryption1RTT)
@@ -499,6 +658,49 @@ var _ = Describe("Packet packer", func() {
 					Expect(p.frames).To(Equal(frames))
 				})
 
+				It("doesn't retransmit PATH_CHALLENGE and PATH_RESPONSE frames", func() {
+					pnManager.EXPECT().PeekPacketNumber(protocol.Encryption1RTT).Return(protocol.PacketNumber(0x42), protocol.PacketNumberLen2)
+					pnManager.EXPECT().PopPacketNumber(protocol.Encryption1RTT).Return(protocol.PacketNumber(0x42))
+					sealingManager.EXPECT().GetSealerWithEncryptionLevel(protocol.Encryption1RTT).Return(sealer, nil)
+					mdf := &wire.MaxDataFrame{ByteOffset: 0x1234}
+					packets, err := packer.PackRetransmission(&ackhandler.Packet{
+						EncryptionLevel: protocol.Encryption1RTT,
+						Frames:          []wire.Frame{&wire.PathChallengeFrame{}, mdf, &wire.PathResponseFrame{}},
+					})
+					Expect(err).ToNot(HaveOccurred())
+					Expect(packets).To(HaveLen(1))
+					Expect(packets[0].frames).To(Equal([]wire.Frame{mdf}))
+				})
+
+				It("doesn't retransmit DATAGRAM frames", func() {
+					pnManager.EXPECT().PeekPacketNumber(protocol.Encryption1RTT).Return(protocol.PacketNumber(0x42), protocol.PacketNumberLen2)
+					pnManager.EXPECT().PopPacketNumber(protocol.Encryption1RTT).Return(protocol.PacketNumber(0x42))
+					sealingManager.EXPECT().GetSealerWithEncryptionLevel(protocol.Encryption1RTT).Return(sealer, nil)
+					mdf := &wire.MaxDataFrame{ByteOffset: 0x1234}
+					packets, err := packer.PackRetransmission(&ackhandler.Packet{
+						EncryptionLevel: protocol.Encryption1RTT,
+						Frames:          []wire.Frame{&wire.DatagramFrame{Data: []byte("foobar")}, mdf},
+						AckCallback:     func(bool) { Fail("didn't expect the callback to be called") },
+					})
+					Expect(err).ToNot(HaveOccurred())
+					Expect(packets).To(HaveLen(1))
+					Expect(packets[0].frames).To(Equal([]wire.Frame{mdf}))
+					Expect(packets[0].ackCallback).To(BeNil())
+				})
+
+				It("sends a PING frame when retransmitting a packet that only contained a PATH_CHALLENGE", func() {
+					pnManager.EXPECT().PeekPacketNumber(protocol.Encryption1RTT).Return(protocol.PacketNumber(0x42), protocol.PacketNumberLen2)
+					pnManager.EXPECT().PopPacketNumber(protocol.Encryption1RTT).Return(protocol.PacketNumber(0x42))
+					sealingManager.EXPECT().GetSealerWithEncryptionLevel(protocol.Encryption1RTT).Return(sealer, nil)
+					packets, err := packer.PackRetransmission(&ackhandler.Packet{
+						EncryptionLevel: protocol.Encryption1RTT,
+						Frames:          []wire.Frame{&wire.PathChallengeFrame{}},
+					})
+					Expect(err).ToNot(HaveOccurred())
+					Expect(packets).To(HaveLen(1))
+					Expect(packets[0].frames).To(Equal([]wire.Frame{&wire.PingFrame{}}))
+				})
+
 				It("packs two packets for retransmission if the original packet contained many control frames", func() {
 					pnManager.EXPECT().PeekPacketNumber(protocol.Encryption1RTT).Return(protocol.PacketNumber(0x42), protocol.PacketNumberLen2).Times(2)
 					pnManager.EXPECT().PopPacketNumber(protocol.Encryption1RTT).Return(protocol.PacketNumber(0x42)).Times(2)
@@ -674,6 +876,28 @@ var _ = Describe("Packet packer", func() {
 					Expect(err).ToNot(HaveOccurred())
 				})
 
+				It("increases the max packet size when Path MTU Discovery found a larger MTU", func() {
+					pnManager.EXPECT().PeekPacketNumber(protocol.Encryption1RTT).Return(protocol.PacketNumber(0x42), protocol.PacketNumberLen2).Times(2)
+					sealingManager.EXPECT().GetSealer().Return(protocol.Encryption1RTT, sealer).Times(2)
+					ackFramer.EXPECT().GetAckFrame(protocol.Encryption1RTT).Times(2)
+					var initialMaxPacketSize protocol.ByteCount
+					framer.EXPECT().AppendControlFrames(gomock.Any(), gomock.Any()).Do(func(_ []wire.Frame, maxLen protocol.ByteCount) ([]wire.Frame, protocol.ByteCount) {
+						initialMaxPacketSize = maxLen
+						return nil, 0
+					})
+					expectAppendStreamFrames()
+					_, err := packer.PackPacket()
+					Expect(err).ToNot(HaveOccurred())
+					packer.SetMaxPacketSize(maxPacketSize + 1000)
+					framer.EXPECT().AppendControlFrames(gomock.Any(), gomock.Any()).Do(func(_ []wire.Frame, maxLen protocol.ByteCount) ([]wire.Frame, protocol.ByteCount) {
+						Expect(maxLen).To(Equal(initialMaxPacketSize + 1000))
+						return nil, 0
+					})
+					expectAppendStreamFrames()
+					_, err = packer.PackPacket()
+					Expect(err).ToNot(HaveOccurred())
+				})
+
 				It("doesn't increase the max packet size", func() {
 					pnManager.EXPECT().PeekPacketNumber(protocol.Encryption1RTT).Return(protocol.PacketNumber(0x42), protocol.PacketNumberLen2).Times(2)
 					sealingManager.EXPECT().GetSealer().Return(protocol.Encryption1RTT, sealer).Times(2)
@@ -3,6 +3,7 @@ package quic
 import (
 	"bytes"
 	"fmt"
+	"time"
 
 	"github.com/lucas-clemente/quic-go/internal/handshake"
 	"github.com/lucas-clemente/quic-go/internal/protocol"
@@ -35,10 +36,11 @@ func newPacketUnpacker(cs handshake.CryptoSetup, version protocol.VersionNumber)
 	}
 }
 
-func (u *packetUnpacker) Unpack(hdr *wire.Header, data []byte) (*unpackedPacket, error) {
-	r := bytes.NewReader(data)
-
+func (u *packetUnpacker) Unpack(hdr *wire.Header, rcvTime time.Time, data []byte) (*unpackedPacket, error) {
 	var encLevel protocol.EncryptionLevel
+	var extHdr *wire.ExtendedHeader
+	var pn protocol.PacketNumber
+	var decrypted []byte
 	switch hdr.Type {
 	case protocol.PacketTypeInitial:
 		encLevel = protocol.EncryptionInitial
@@ -50,10 +52,86 @@ func (u *packetUnpacker) Unpack(hdr *wire.Header, data []byte) (*unpackedPacket,
 		}
 		encLevel = protocol.Encryption1RTT
 	}
-	opener, err := u.cs.GetOpener(encLevel)
+	if encLevel == protocol.Encryption1RTT {
+		opener, err := u.cs.Get1RTTOpener()
+		if err != nil {
+			return nil, err
+		}
+		extHdr, pn, decrypted, err = u.unpackShortHeaderPacket(opener, hdr, rcvTime, data)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		opener, err := u.cs.GetOpener(encLevel)
+		if err != nil {
+			return nil, err
+		}
+		extHdr, pn, decrypted, err = u.unpackLongHeaderPacket(opener, hdr, data)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Only do this after decrypting, so we are sure the packet is not attacker-controlled
+	u.largestRcvdPacketNumber = utils.MaxPacketNumber(u.largestRcvdPacketNumber, pn)
+
+	return &unpackedPacket{
+		hdr:             extHdr,
+		packetNumber:    pn,
+		encryptionLevel: encLevel,
+		data:            decrypted,
+	}, nil
+}
+
+func (u *packetUnpacker) unpackLongHeaderPacket(opener handshake.Opener, hdr *wire.Header, data []byte) (*wire.ExtendedHeader, protocol.PacketNumber, []byte, error) {
+	extHdr, err := u.unprotectHeader(opener, hdr, data)
+	if err != nil {
+		return nil, 0, nil, err
+	}
+	extHdrLen := int(hdr.ParsedLen()) + int(extHdr.PacketNumberLen)
+	pn := u.decodePacketNumber(extHdr)
+	decrypted, err := opener.Open(data[extHdrLen:extHdrLen], data[extHdrLen:], pn, data[:extHdrLen])
 	if err != nil {
-		return nil, err
+		return nil, 0, nil, err
 	}
+	return extHdr, pn, decrypted, nil
+}
+
+func (u *packetUnpacker) unpackShortHeaderPacket(
+	opener handshake.ShortHeaderOpener,
+	hdr *wire.Header,
+	rcvTime time.Time,
+	data []byte,
+) (*wire.ExtendedHeader, protocol.PacketNumber, []byte, error) {
+	extHdr, err := u.unprotectHeader(opener, hdr, data)
+	if err != nil {
+		return nil, 0, nil, err
+	}
+	extHdrLen := int(hdr.ParsedLen()) + int(extHdr.PacketNumberLen)
+	pn := u.decodePacketNumber(extHdr)
+	decrypted, err := opener.Open(data[extHdrLen:extHdrLen], data[extHdrLen:], rcvTime, pn, extHdr.KeyPhase, data[:extHdrLen])
+	if err != nil {
+		return nil, 0, nil, err
+	}
+	return extHdr, pn, decrypted, nil
+}
+
+func (u *packetUnpacker) decodePacketNumber(extHdr *wire.ExtendedHeader) protocol.PacketNumber {
+	return protocol.DecodePacketNumber(
+		extHdr.PacketNumberLen,
+		u.largestRcvdPacketNumber,
+		extHdr.PacketNumber,
+	)
+}
+
+type headerDecryptor interface {
+	DecryptHeader(sample []byte, firstByte *byte, pnBytes []byte)
+}
+
+// unprotectHeader removes the header protection and parses the extended header.
+func (u *packetUnpacker) unprotectHeader(hd headerDecryptor, hdr *wire.Header, data []byte) (*wire.ExtendedHeader, error) {
+	r := bytes.NewReader(data)
+
 	hdrLen := int(hdr.ParsedLen())
 	if len(data) < hdrLen+4+16 {
 		return nil, fmt.Errorf("Packet too small. Expected at least 20 bytes after the header, got %d", len(data)-hdrLen)
@@ -63,7 +141,7 @@ func (u *packetUnpacker) Unpack(hdr *wire.Header, data []byte) (*unpackedPacket,
 	origPNBytes := make([]byte, 4)
 	copy(origPNBytes, data[hdrLen:hdrLen+4])
 	// 2. decrypt the header, assuming a 4 byte packet number
-	opener.DecryptHeader(
+	hd.DecryptHeader(
 		data[hdrLen+4:hdrLen+4+16],
 		&data[0],
 		data[hdrLen:hdrLen+4],
@@ -78,25 +156,5 @@ func (u *packetUnpacker) Unpack(hdr *wire.Header, data []byte) (*unpackedPacket,
 	if extHdr.PacketNumberLen != protocol.PacketNumberLen4 {
 		copy(data[extHdrLen:hdrLen+4], origPNBytes[int(extHdr.PacketNumberLen):])
 	}
-
-	pn := protocol.DecodePacketNumber(
-		extHdr.PacketNumberLen,
-		u.largestRcvdPacketNumber,
-		extHdr.PacketNumber,
-	)
-
-	decrypted, err := opener.Open(data[extHdrLen:extHdrLen], data[extHdrLen:], pn, data[:extHdrLen])
-	if err != nil {
-		return nil, err
-	}
-
-	// Only do this after decrypting, so we are sure the packet is not attacker-controlled
-	u.largestRcvdPacketNumber = utils.MaxPacketNumber(u.largestRcvdPacketNumber, pn)
-
-	return &unpackedPacket{
-		hdr:             extHdr,
-		packetNumber:    pn,
-		encryptionLevel: encLevel,
-		data:            decrypted,
-	}, nil
+	return extHdr, nil
 }
@@ -0,0 +1,200 @@
+package qlog
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"net"
+	"time"
+
+	"github.com/lucas-clemente/quic-go/logging"
+)
+
+// milliseconds is a duration that is serialized as a (fractional) number of milliseconds
+type milliseconds time.Duration
+
+func (d milliseconds) MarshalJSON() ([]byte, error) {
+	return json.Marshal(float64(d) / float64(time.Millisecond))
+}
+
+type eventConnectionStarted struct {
+	SrcAddr  *net.UDPAddr
+	DestAddr *net.UDPAddr
+
+	Version          logging.VersionNumber
+	SrcConnectionID  logging.ConnectionID
+	DestConnectionID logging.ConnectionID
+}
+
+func (e eventConnectionStarted) MarshalJSON() ([]byte, error) {
+	ipVersion := "ipv4"
+	if e.SrcAddr.IP.To4() == nil {
+		ipVersion = "ipv6"
+	}
+	return json.Marshal(struct {
+		IPVersion        string        `json:"ip_version"`
+		SrcIP            string        `json:"src_ip"`
+		SrcPort          int           `json:"src_port"`
+		DestIP           string        `json:"dst_ip"`
+		DestPort         int           `json:"dst_port"`
+		Version          versionNumber `json:"quic_version"`
+		SrcConnectionID  connectionID  `json:"src_cid"`
+		DestConnectionID connectionID  `json:"dst_cid"`
+	}{
+		IPVersion:        ipVersion,
+		SrcIP:            e.SrcAddr.IP.String(),
+		SrcPort:          e.SrcAddr.Port,
+		DestIP:           e.DestAddr.IP.String(),
+		DestPort:         e.DestAddr.Port,
+		Version:          versionNumber(e.Version),
+		SrcConnectionID:  connectionID(e.SrcConnectionID),
+		DestConnectionID: connectionID(e.DestConnectionID),
+	})
+}
+
+type eventConnectionClosed struct {
+	Owner  owner  `json:"owner"`
+	Reason string `json:"reason"`
+}
+
+type eventTransportParameters struct {
+	Owner owner `json:"owner"`
+
+	OriginalConnectionID connectionID `json:"original_connection_id,omitempty"`
+	StatelessResetToken  string       `json:"stateless_reset_token,omitempty"`
+	DisableMigration     bool         `json:"disable_active_migration,omitempty"`
+	IdleTimeout          milliseconds `json:"idle_timeout"`
+	MaxPacketSize        uint64       `json:"max_packet_size"`
+	AckDelayExponent     uint8        `json:"ack_delay_exponent"`
+
+	InitialMaxData                 uint64 `json:"initial_max_data"`
+	InitialMaxStreamDataBidiLocal  uint64 `json:"initial_max_stream_data_bidi_local"`
+	InitialMaxStreamDataBidiRemote uint64 `json:"initial_max_stream_data_bidi_remote"`
+	InitialMaxStreamDataUni        uint64 `json:"initial_max_stream_data_uni"`
+	InitialMaxStreamsBidi          uint64 `json:"initial_max_streams_bidi"`
+	InitialMaxStreamsUni           uint64 `json:"initial_max_streams_uni"`
+}
+
+func transportParametersEvent(o owner, tp *logging.TransportParameters) *eventTransportParameters {
+	ev := &eventTransportParameters{
+		Owner:                          o,
+		OriginalConnectionID:           connectionID(tp.OriginalConnectionID),
+		DisableMigration:               tp.DisableMigration,
+		IdleTimeout:                    milliseconds(tp.IdleTimeout),
+		MaxPacketSize:                  uint64(tp.MaxPacketSize),
+		AckDelayExponent:               tp.AckDelayExponent,
+		InitialMaxData:                 uint64(tp.InitialMaxData),
+		InitialMaxStreamDataBidiLocal:  uint64(tp.InitialMaxStreamDataBidiLocal),
+		InitialMaxStreamDataBidiRemote: uint64(tp.InitialMaxStreamDataBidiRemote),
+		InitialMaxStreamDataUni:        uint64(tp.InitialMaxStreamDataUni),
+		InitialMaxStreamsBidi:          tp.MaxBidiStreams,
+		InitialMaxStreamsUni:           tp.MaxUniStreams,
+	}
+	if tp.StatelessResetToken != nil {
+		ev.StatelessResetToken = hex.EncodeToString(tp.StatelessResetToken[:])
+	}
+	return ev
+}
+
+type packetHeader struct {
+	PacketNumber  logging.PacketNumber `json:"packet_number"`
+	PacketSize    logging.ByteCount    `json:"packet_size,omitempty"`
+	PayloadLength logging.ByteCount    `json:"payload_length,omitempty"`
+
+	Version          *versionNumber `json:"version,omitempty"`
+	SrcConnectionID  *connectionID  `json:"scid,omitempty"`
+	DestConnectionID connectionID   `json:"dcid"`
+	Token            string         `json:"token,omitempty"`
+}
+
+func transformHeader(hdr *logging.Header) *packetHeader {
+	h := &packetHeader{
+		PayloadLength:    hdr.Length,
+		DestConnectionID: connectionID(hdr.DestConnectionID),
+	}
+	if hdr.IsLongHeader {
+		v := versionNumber(hdr.Version)
+		srcConnID := connectionID(hdr.SrcConnectionID)
+		h.Version = &v
+		h.SrcConnectionID = &srcConnID
+		h.Token = hex.EncodeToString(hdr.Token)
+	}
+	return h
+}
+
+func transformExtendedHeader(hdr *logging.ExtendedHeader, packetSize logging.ByteCount) *packetHeader {
+	h := transformHeader(&hdr.Header)
+	h.PacketNumber = hdr.PacketNumber
+	h.PacketSize = packetSize
+	return h
+}
+
+type eventPacket struct {
+	PacketType packetType    `json:"packet_type"`
+	Header     *packetHeader `json:"header"`
+	Frames     []frame       `json:"frames"`
+}
+
+type eventPacketBuffered struct {
+	PacketType packetType `json:"packet_type"`
+}
+
+type eventPacketDropped struct {
+	PacketType packetType        `json:"packet_type"`
+	PacketSize logging.ByteCount `json:"packet_size"`
+	Trigger    packetDropReason  `json:"trigger"`
+}
+
+type metrics struct {
+	MinRTT      time.Duration
+	SmoothedRTT time.Duration
+	LatestRTT   time.Duration
+	RTTVariance time.Duration
+
+	CongestionWindow logging.ByteCount
+	BytesInFlight    logging.ByteCount
+	PacketsInFlight  int
+}
+
+type eventMetricsUpdated struct {
+	*metrics
+}
+
+func (e eventMetricsUpdated) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		MinRTT           milliseconds      `json:"min_rtt"`
+		SmoothedRTT      milliseconds      `json:"smoothed_rtt"`
+		LatestRTT        milliseconds      `json:"latest_rtt"`
+		RTTVariance      milliseconds      `json:"rtt_variance"`
+		CongestionWindow logging.ByteCount `json:"congestion_window"`
+		BytesInFlight    logging.ByteCount `json:"bytes_in_flight"`
+		PacketsInFlight  int               `json:"packets_in_flight"`
+	}{
+		MinRTT:           milliseconds(e.MinRTT),
+		SmoothedRTT:      milliseconds(e.SmoothedRTT),
+		LatestRTT:        milliseconds(e.LatestRTT),
+		RTTVariance:      milliseconds(e.RTTVariance),
+		CongestionWindow: e.CongestionWindow,
+		BytesInFlight:    e.BytesInFlight,
+		PacketsInFlight:  e.PacketsInFlight,
+	})
+}
+
+type eventPTOCountUpdated struct {
+	PTOCount uint32 `json:"pto_count"`
+}
+
+type eventCongestionStateUpdated struct {
+	State congestionState `json:"new"`
+}
+
+type eventPacketLost struct {
+	PacketType   packetType           `json:"packet_type"`
+	PacketNumber logging.PacketNumber `json:"packet_number"`
+	Trigger      packetLossReason     `json:"trigger"`
+}
+
+type eventKeyUpdated struct {
+	Trigger    keyUpdateTrigger `json:"trigger"`
+	KeyType    keyType          `json:"key_type"`
+	Generation logging.KeyPhase `json:"generation,omitempty"`
+}
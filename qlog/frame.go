@@ -0,0 +1,264 @@
+package qlog
+
+import (
+	"encoding/hex"
+
+	"github.com/lucas-clemente/quic-go/logging"
+)
+
+// A frame is the qlog representation of a QUIC frame.
+// It's a struct that is serialized as a JSON object with a frame_type field.
+type frame interface{}
+
+type ackFrame struct {
+	FrameType   string                    `json:"frame_type"`
+	AckDelay    milliseconds              `json:"ack_delay,omitempty"`
+	AckedRanges [][2]logging.PacketNumber `json:"acked_ranges"`
+	ECT0        uint64                    `json:"ect0,omitempty"`
+	ECT1        uint64                    `json:"ect1,omitempty"`
+	ECNCE       uint64                    `json:"ce,omitempty"`
+}
+
+type connectionCloseFrame struct {
+	FrameType  string `json:"frame_type"`
+	ErrorSpace string `json:"error_space"`
+	ErrorCode  uint64 `json:"error_code"`
+	Reason     string `json:"reason"`
+}
+
+type cryptoFrame struct {
+	FrameType string            `json:"frame_type"`
+	Offset    logging.ByteCount `json:"offset"`
+	Length    logging.ByteCount `json:"length"`
+}
+
+type dataBlockedFrame struct {
+	FrameType string            `json:"frame_type"`
+	Limit     logging.ByteCount `json:"limit"`
+}
+
+type datagramFrame struct {
+	FrameType string            `json:"frame_type"`
+	Length    logging.ByteCount `json:"length"`
+}
+
+type maxDataFrame struct {
+	FrameType string            `json:"frame_type"`
+	Maximum   logging.ByteCount `json:"maximum"`
+}
+
+type maxStreamDataFrame struct {
+	FrameType string            `json:"frame_type"`
+	StreamID  logging.StreamID  `json:"stream_id"`
+	Maximum   logging.ByteCount `json:"maximum"`
+}
+
+type maxStreamsFrame struct {
+	FrameType  string     `json:"frame_type"`
+	StreamType streamType `json:"stream_type"`
+	Maximum    uint64     `json:"maximum"`
+}
+
+type newConnectionIDFrame struct {
+	FrameType           string       `json:"frame_type"`
+	SequenceNumber      uint64       `json:"sequence_number"`
+	Length              int          `json:"length"`
+	ConnectionID        connectionID `json:"connection_id"`
+	StatelessResetToken string       `json:"reset_token"`
+}
+
+type newTokenFrame struct {
+	FrameType string `json:"frame_type"`
+	Length    int    `json:"length"`
+	Token     string `json:"token"`
+}
+
+type pathFrame struct {
+	FrameType string `json:"frame_type"`
+	Data      string `json:"data"`
+}
+
+type pingFrame struct {
+	FrameType string `json:"frame_type"`
+}
+
+type resetStreamFrame struct {
+	FrameType string            `json:"frame_type"`
+	StreamID  logging.StreamID  `json:"stream_id"`
+	ErrorCode uint64            `json:"error_code"`
+	FinalSize logging.ByteCount `json:"final_size"`
+}
+
+type retireConnectionIDFrame struct {
+	FrameType      string `json:"frame_type"`
+	SequenceNumber uint64 `json:"sequence_number"`
+}
+
+type stopSendingFrame struct {
+	FrameType string           `json:"frame_type"`
+	StreamID  logging.StreamID `json:"stream_id"`
+	ErrorCode uint64           `json:"error_code"`
+}
+
+type streamFrame struct {
+	FrameType string            `json:"frame_type"`
+	StreamID  logging.StreamID  `json:"stream_id"`
+	Offset    logging.ByteCount `json:"offset"`
+	Length    logging.ByteCount `json:"length"`
+	Fin       bool              `json:"fin,omitempty"`
+}
+
+type streamDataBlockedFrame struct {
+	FrameType string            `json:"frame_type"`
+	StreamID  logging.StreamID  `json:"stream_id"`
+	Limit     logging.ByteCount `json:"limit"`
+}
+
+type streamsBlockedFrame struct {
+	FrameType  string     `json:"frame_type"`
+	StreamType streamType `json:"stream_type"`
+	Limit      uint64     `json:"limit"`
+}
+
+type unknownFrame struct {
+	FrameType string `json:"frame_type"`
+}
+
+func transformFrames(frames []logging.Frame) []frame {
+	fs := make([]frame, len(frames))
+	for i, f := range frames {
+		fs[i] = transformFrame(f)
+	}
+	return fs
+}
+
+// transformFrame converts a QUIC frame to its qlog representation.
+// Frames that carry data (STREAM, CRYPTO and DATAGRAM frames) are logged without the data.
+func transformFrame(f logging.Frame) frame {
+	switch f := f.(type) {
+	case *logging.AckFrame:
+		ranges := make([][2]logging.PacketNumber, len(f.AckRanges))
+		// qlog lists the ACK ranges in ascending order
+		for i, r := range f.AckRanges {
+			ranges[len(ranges)-1-i] = [2]logging.PacketNumber{r.Smallest, r.Largest}
+		}
+		return &ackFrame{
+			FrameType:   "ack",
+			AckDelay:    milliseconds(f.DelayTime),
+			AckedRanges: ranges,
+			ECT0:        f.ECT0,
+			ECT1:        f.ECT1,
+			ECNCE:       f.ECNCE,
+		}
+	case *logging.ConnectionCloseFrame:
+		errorSpace := "transport"
+		if f.IsApplicationError {
+			errorSpace = "application"
+		}
+		return &connectionCloseFrame{
+			FrameType:  "connection_close",
+			ErrorSpace: errorSpace,
+			ErrorCode:  uint64(f.ErrorCode),
+			Reason:     f.ReasonPhrase,
+		}
+	case *logging.CryptoFrame:
+		return &cryptoFrame{
+			FrameType: "crypto",
+			Offset:    f.Offset,
+			Length:    logging.ByteCount(len(f.Data)),
+		}
+	case *logging.DataBlockedFrame:
+		return &dataBlockedFrame{
+			FrameType: "data_blocked",
+			Limit:     f.DataLimit,
+		}
+	case *logging.DatagramFrame:
+		return &datagramFrame{
+			FrameType: "datagram",
+			Length:    logging.ByteCount(len(f.Data)),
+		}
+	case *logging.MaxDataFrame:
+		return &maxDataFrame{
+			FrameType: "max_data",
+			Maximum:   f.ByteOffset,
+		}
+	case *logging.MaxStreamDataFrame:
+		return &maxStreamDataFrame{
+			FrameType: "max_stream_data",
+			StreamID:  f.StreamID,
+			Maximum:   f.ByteOffset,
+		}
+	case *logging.MaxStreamsFrame:
+		return &maxStreamsFrame{
+			FrameType:  "max_streams",
+			StreamType: streamType(f.Type),
+			Maximum:    f.MaxStreams,
+		}
+	case *logging.NewConnectionIDFrame:
+		return &newConnectionIDFrame{
+			FrameType:           "new_connection_id",
+			SequenceNumber:      f.SequenceNumber,
+			Length:              f.ConnectionID.Len(),
+			ConnectionID:        connectionID(f.ConnectionID),
+			StatelessResetToken: hex.EncodeToString(f.StatelessResetToken[:]),
+		}
+	case *logging.NewTokenFrame:
+		return &newTokenFrame{
+			FrameType: "new_token",
+			Length:    len(f.Token),
+			Token:     hex.EncodeToString(f.Token),
+		}
+	case *logging.PathChallengeFrame:
+		return &pathFrame{
+			FrameType: "path_challenge",
+			Data:      hex.EncodeToString(f.Data[:]),
+		}
+	case *logging.PathResponseFrame:
+		return &pathFrame{
+			FrameType: "path_response",
+			Data:      hex.EncodeToString(f.Data[:]),
+		}
+	case *logging.PingFrame:
+		return &pingFrame{FrameType: "ping"}
+	case *logging.ResetStreamFrame:
+		return &resetStreamFrame{
+			FrameType: "reset_stream",
+			StreamID:  f.StreamID,
+			ErrorCode: uint64(f.ErrorCode),
+			FinalSize: f.ByteOffset,
+		}
+	case *logging.RetireConnectionIDFrame:
+		return &retireConnectionIDFrame{
+			FrameType:      "retire_connection_id",
+			SequenceNumber: f.SequenceNumber,
+		}
+	case *logging.StopSendingFrame:
+		return &stopSendingFrame{
+			FrameType: "stop_sending",
+			StreamID:  f.StreamID,
+			ErrorCode: uint64(f.ErrorCode),
+		}
+	case *logging.StreamFrame:
+		return &streamFrame{
+			FrameType: "stream",
+			StreamID:  f.StreamID,
+			Offset:    f.Offset,
+			Length:    f.DataLen(),
+			Fin:       f.FinBit,
+		}
+	case *logging.StreamDataBlockedFrame:
+		return &streamDataBlockedFrame{
+			FrameType: "stream_data_blocked",
+			StreamID:  f.StreamID,
+			Limit:     f.DataLimit,
+		}
+	case *logging.StreamsBlockedFrame:
+		return &streamsBlockedFrame{
+			FrameType:  "streams_blocked",
+			StreamType: streamType(f.Type),
+			Limit:      f.StreamLimit,
+		}
+	default:
+		return &unknownFrame{FrameType: "unknown"}
+	}
+}
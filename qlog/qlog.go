@@ -0,0 +1,267 @@
+// Package qlog writes qlog traces of QUIC connections, as defined in draft-marx-qlog-main-schema-01.
+// The traces can be visualized using qvis (https://qvis.edm.uhasselt.be).
+package qlog
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/lucas-clemente/quic-go/logging"
+)
+
+type tracer struct {
+	getLogWriter func(p logging.Perspective, connectionID []byte) io.WriteCloser
+}
+
+var _ logging.Tracer = &tracer{}
+
+// NewTracer creates a new tracer that writes a qlog trace for every connection.
+// getLogWriter is called for every new connection, with the original destination connection ID.
+// If it returns nil, no qlog is written for this connection.
+func NewTracer(getLogWriter func(p logging.Perspective, connectionID []byte) io.WriteCloser) logging.Tracer {
+	return &tracer{getLogWriter: getLogWriter}
+}
+
+func (t *tracer) TracerForConnection(p logging.Perspective, odcid logging.ConnectionID) logging.ConnectionTracer {
+	if w := t.getLogWriter(p, odcid.Bytes()); w != nil {
+		return NewConnectionTracer(w, p, odcid)
+	}
+	return nil
+}
+
+// A connectionTracer streams the events of a connection to an io.WriteCloser.
+// The output is a valid JSON document once Close was called.
+type connectionTracer struct {
+	mutex sync.Mutex
+
+	w             io.WriteCloser
+	bw            *bufio.Writer
+	err           error // the first error that occurred when writing
+	wroteEvent    bool
+	referenceTime time.Time
+
+	perspective logging.Perspective
+	odcid       logging.ConnectionID
+
+	lastMetrics *metrics
+}
+
+var _ logging.ConnectionTracer = &connectionTracer{}
+
+// NewConnectionTracer creates a new tracer to record a qlog for a single connection.
+// The trace is written to w, which is closed when the connection tracer is closed.
+func NewConnectionTracer(w io.WriteCloser, p logging.Perspective, odcid logging.ConnectionID) logging.ConnectionTracer {
+	t := &connectionTracer{
+		w:             w,
+		bw:            bufio.NewWriter(w),
+		perspective:   p,
+		odcid:         odcid,
+		referenceTime: time.Now(),
+	}
+	t.writeHeader()
+	return t
+}
+
+func (t *connectionTracer) writeHeader() {
+	vantagePoint, err := json.Marshal(struct {
+		Type string `json:"type"`
+	}{Type: perspective(t.perspective).String()})
+	if err != nil {
+		t.err = err
+		return
+	}
+	commonFields, err := json.Marshal(struct {
+		ODCID         connectionID `json:"ODCID"`
+		GroupID       connectionID `json:"group_id"`
+		ReferenceTime float64      `json:"reference_time"`
+	}{
+		ODCID:         connectionID(t.odcid),
+		GroupID:       connectionID(t.odcid),
+		ReferenceTime: float64(t.referenceTime.UnixNano()) / 1e6,
+	})
+	if err != nil {
+		t.err = err
+		return
+	}
+	_, t.err = fmt.Fprintf(t.bw,
+		`{"qlog_version":"draft-01","title":"quic-go qlog","traces":[{"vantage_point":%s,"common_fields":%s,"event_fields":["relative_time","category","event","data"],"events":[`,
+		vantagePoint,
+		commonFields,
+	)
+}
+
+// recordEvent writes an event.
+// The relative time is the time since the creation of the tracer, in milliseconds.
+func (t *connectionTracer) recordEvent(category, name string, data interface{}) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if t.err != nil {
+		return
+	}
+	relativeTime := float64(time.Since(t.referenceTime).Nanoseconds()) / 1e6
+	ev, err := json.Marshal([]interface{}{relativeTime, category, name, data})
+	if err != nil {
+		t.err = err
+		return
+	}
+	if t.wroteEvent {
+		if err := t.bw.WriteByte(','); err != nil {
+			t.err = err
+			return
+		}
+	}
+	t.wroteEvent = true
+	_, t.err = t.bw.Write(ev)
+}
+
+func (t *connectionTracer) Close() {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if t.err == nil {
+		if _, err := t.bw.WriteString("]}]}\n"); err != nil {
+			t.err = err
+		}
+	}
+	if t.err == nil {
+		t.err = t.bw.Flush()
+	}
+	t.w.Close()
+}
+
+func (t *connectionTracer) StartedConnection(local, remote net.Addr, version logging.VersionNumber, srcConnID, destConnID logging.ConnectionID) {
+	// ignore this event if we're not dealing with UDP addresses here
+	localAddr, ok := local.(*net.UDPAddr)
+	if !ok {
+		return
+	}
+	remoteAddr, ok := remote.(*net.UDPAddr)
+	if !ok {
+		return
+	}
+	t.recordEvent("connectivity", "connection_started", &eventConnectionStarted{
+		SrcAddr:          localAddr,
+		DestAddr:         remoteAddr,
+		Version:          version,
+		SrcConnectionID:  srcConnID,
+		DestConnectionID: destConnID,
+	})
+}
+
+func (t *connectionTracer) ClosedConnection(err error, remote bool) {
+	owner := ownerLocal
+	if remote {
+		owner = ownerRemote
+	}
+	t.recordEvent("connectivity", "connection_closed", &eventConnectionClosed{
+		Owner:  owner,
+		Reason: err.Error(),
+	})
+}
+
+func (t *connectionTracer) SentTransportParameters(tp *logging.TransportParameters) {
+	t.recordEvent("transport", "parameters_set", transportParametersEvent(ownerLocal, tp))
+}
+
+func (t *connectionTracer) ReceivedTransportParameters(tp *logging.TransportParameters) {
+	t.recordEvent("transport", "parameters_set", transportParametersEvent(ownerRemote, tp))
+}
+
+func (t *connectionTracer) SentPacket(hdr *logging.ExtendedHeader, packetSize logging.ByteCount, frames []logging.Frame) {
+	t.recordEvent("transport", "packet_sent", &eventPacket{
+		PacketType: packetType(logging.PacketTypeFromHeader(&hdr.Header)),
+		Header:     transformExtendedHeader(hdr, packetSize),
+		Frames:     transformFrames(frames),
+	})
+}
+
+func (t *connectionTracer) ReceivedPacket(hdr *logging.ExtendedHeader, packetSize logging.ByteCount, frames []logging.Frame) {
+	t.recordEvent("transport", "packet_received", &eventPacket{
+		PacketType: packetType(logging.PacketTypeFromHeader(&hdr.Header)),
+		Header:     transformExtendedHeader(hdr, packetSize),
+		Frames:     transformFrames(frames),
+	})
+}
+
+func (t *connectionTracer) ReceivedRetry(hdr *logging.Header) {
+	t.recordEvent("transport", "packet_received", &eventPacket{
+		PacketType: packetType(logging.PacketTypeRetry),
+		Header:     transformHeader(hdr),
+		Frames:     []frame{},
+	})
+}
+
+func (t *connectionTracer) BufferedPacket(pt logging.PacketType) {
+	t.recordEvent("transport", "packet_buffered", &eventPacketBuffered{PacketType: packetType(pt)})
+}
+
+func (t *connectionTracer) DroppedPacket(pt logging.PacketType, size logging.ByteCount, reason logging.PacketDropReason) {
+	t.recordEvent("transport", "packet_dropped", &eventPacketDropped{
+		PacketType: packetType(pt),
+		PacketSize: size,
+		Trigger:    packetDropReason(reason),
+	})
+}
+
+func (t *connectionTracer) UpdatedMetrics(rttStats *logging.RTTStats, cwnd, bytesInFlight logging.ByteCount, packetsInFlight int) {
+	m := &metrics{
+		MinRTT:           rttStats.MinRTT(),
+		SmoothedRTT:      rttStats.SmoothedRTT(),
+		LatestRTT:        rttStats.LatestRTT(),
+		RTTVariance:      rttStats.MeanDeviation(),
+		CongestionWindow: cwnd,
+		BytesInFlight:    bytesInFlight,
+		PacketsInFlight:  packetsInFlight,
+	}
+	t.mutex.Lock()
+	// only log the metrics if they changed since the last time they were logged
+	changed := t.lastMetrics == nil || *t.lastMetrics != *m
+	t.lastMetrics = m
+	t.mutex.Unlock()
+	if changed {
+		t.recordEvent("recovery", "metrics_updated", &eventMetricsUpdated{m})
+	}
+}
+
+func (t *connectionTracer) UpdatedCongestionState(state logging.CongestionState) {
+	t.recordEvent("recovery", "congestion_state_updated", &eventCongestionStateUpdated{State: congestionState(state)})
+}
+
+func (t *connectionTracer) LostPacket(encLevel logging.EncryptionLevel, pn logging.PacketNumber, reason logging.PacketLossReason) {
+	t.recordEvent("recovery", "packet_lost", &eventPacketLost{
+		PacketType:   packetTypeFromEncryptionLevel(encLevel),
+		PacketNumber: pn,
+		Trigger:      packetLossReason(reason),
+	})
+}
+
+func (t *connectionTracer) UpdatedPTOCount(value uint32) {
+	t.recordEvent("recovery", "metrics_updated", &eventPTOCountUpdated{PTOCount: value})
+}
+
+func (t *connectionTracer) UpdatedKeyFromTLS(encLevel logging.EncryptionLevel, pers logging.Perspective) {
+	t.recordEvent("security", "key_updated", &eventKeyUpdated{
+		Trigger: keyUpdateTLS,
+		KeyType: encLevelToKeyType(encLevel, pers),
+	})
+}
+
+func (t *connectionTracer) UpdatedKey(generation logging.KeyPhase, remote bool) {
+	trigger := keyUpdateLocal
+	if remote {
+		trigger = keyUpdateRemote
+	}
+	for _, pers := range []logging.Perspective{logging.PerspectiveClient, logging.PerspectiveServer} {
+		t.recordEvent("security", "key_updated", &eventKeyUpdated{
+			Trigger:    trigger,
+			KeyType:    encLevelToKeyType(logging.Encryption1RTT, pers),
+			Generation: generation,
+		})
+	}
+}
@@ -0,0 +1,462 @@
+package qlog
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"time"
+
+	"github.com/lucas-clemente/quic-go/internal/congestion"
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+	"github.com/lucas-clemente/quic-go/logging"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type limitedWriter struct {
+	io.WriteCloser
+	N       int
+	written int
+}
+
+func (w *limitedWriter) Write(p []byte) (int, error) {
+	if w.written+len(p) > w.N {
+		return 0, errors.New("writer full")
+	}
+	n, err := w.WriteCloser.Write(p)
+	w.written += n
+	return n, err
+}
+
+type nopWriteCloserImpl struct {
+	io.Writer
+	closed bool
+}
+
+func (w *nopWriteCloserImpl) Close() error {
+	w.closed = true
+	return nil
+}
+
+type entry struct {
+	Time     time.Time
+	Category string
+	Name     string
+	Event    map[string]interface{}
+}
+
+var _ = Describe("Tracer", func() {
+	It("returns nil when there's no io.WriteCloser", func() {
+		t := NewTracer(func(logging.Perspective, []byte) io.WriteCloser { return nil })
+		Expect(t.TracerForConnection(logging.PerspectiveClient, logging.ConnectionID{1, 2, 3, 4})).To(BeNil())
+	})
+
+	It("passes the perspective and the connection ID to the callback", func() {
+		var pers logging.Perspective
+		var connID []byte
+		t := NewTracer(func(p logging.Perspective, c []byte) io.WriteCloser {
+			pers = p
+			connID = c
+			return &nopWriteCloserImpl{Writer: &bytes.Buffer{}}
+		})
+		Expect(t.TracerForConnection(logging.PerspectiveServer, logging.ConnectionID{1, 2, 3, 4})).ToNot(BeNil())
+		Expect(pers).To(Equal(logging.PerspectiveServer))
+		Expect(connID).To(Equal([]byte{1, 2, 3, 4}))
+	})
+})
+
+var _ = Describe("Connection Tracer", func() {
+	var (
+		tracer logging.ConnectionTracer
+		buf    *bytes.Buffer
+		wc     *nopWriteCloserImpl
+	)
+
+	BeforeEach(func() {
+		buf = &bytes.Buffer{}
+		wc = &nopWriteCloserImpl{Writer: buf}
+		tracer = NewConnectionTracer(wc, logging.PerspectiveServer, logging.ConnectionID{0xde, 0xad, 0xbe, 0xef})
+	})
+
+	It("exports a trace that has the right metadata", func() {
+		tracer.Close()
+		Expect(wc.closed).To(BeTrue())
+		m := make(map[string]interface{})
+		Expect(json.Unmarshal(buf.Bytes(), &m)).To(Succeed())
+		Expect(m).To(HaveKeyWithValue("qlog_version", "draft-01"))
+		Expect(m).To(HaveKey("title"))
+		Expect(m).To(HaveKey("traces"))
+		traces := m["traces"].([]interface{})
+		Expect(traces).To(HaveLen(1))
+		trace := traces[0].(map[string]interface{})
+		Expect(trace).To(HaveKey("common_fields"))
+		commonFields := trace["common_fields"].(map[string]interface{})
+		Expect(commonFields).To(HaveKeyWithValue("ODCID", "deadbeef"))
+		Expect(commonFields).To(HaveKeyWithValue("group_id", "deadbeef"))
+		Expect(commonFields).To(HaveKey("reference_time"))
+		referenceTime := time.Unix(0, int64(commonFields["reference_time"].(float64)*1e6))
+		Expect(referenceTime).To(BeTemporally("~", time.Now(), scaleDuration(10*time.Millisecond)))
+		Expect(trace).To(HaveKey("event_fields"))
+		for i, ef := range trace["event_fields"].([]interface{}) {
+			Expect(ef.(string)).To(Equal([]string{"relative_time", "category", "event", "data"}[i]))
+		}
+		Expect(trace).To(HaveKey("vantage_point"))
+		vantagePoint := trace["vantage_point"].(map[string]interface{})
+		Expect(vantagePoint).To(HaveKeyWithValue("type", "server"))
+		Expect(trace["events"]).To(BeEmpty())
+	})
+
+	It("stops writing after the first error", func() {
+		w := &limitedWriter{WriteCloser: wc, N: 300}
+		tracer = NewConnectionTracer(w, logging.PerspectiveServer, logging.ConnectionID{0xde, 0xad, 0xbe, 0xef})
+		for i := uint32(0); i < 1000; i++ {
+			tracer.UpdatedPTOCount(i)
+		}
+		tracer.Close()
+		Expect(wc.closed).To(BeTrue())
+		Expect(buf.Len()).To(BeNumerically("<=", 300))
+	})
+
+	Context("Events", func() {
+		exportAndParse := func() []entry {
+			tracer.Close()
+
+			m := make(map[string]interface{})
+			Expect(json.Unmarshal(buf.Bytes(), &m)).To(Succeed())
+			Expect(m).To(HaveKey("traces"))
+			var entries []entry
+			traces := m["traces"].([]interface{})
+			Expect(traces).To(HaveLen(1))
+			trace := traces[0].(map[string]interface{})
+			Expect(trace).To(HaveKey("common_fields"))
+			commonFields := trace["common_fields"].(map[string]interface{})
+			Expect(commonFields).To(HaveKey("reference_time"))
+			referenceTime := time.Unix(0, int64(commonFields["reference_time"].(float64)*1e6))
+			Expect(trace).To(HaveKey("events"))
+			for _, e := range trace["events"].([]interface{}) {
+				ev := e.([]interface{})
+				Expect(ev).To(HaveLen(4))
+				entries = append(entries, entry{
+					Time:     referenceTime.Add(time.Duration(ev[0].(float64)*1e6) * time.Nanosecond),
+					Category: ev[1].(string),
+					Name:     ev[2].(string),
+					Event:    ev[3].(map[string]interface{}),
+				})
+			}
+			return entries
+		}
+
+		exportAndParseSingle := func() entry {
+			entries := exportAndParse()
+			Expect(entries).To(HaveLen(1))
+			return entries[0]
+		}
+
+		It("records connection starts", func() {
+			tracer.StartedConnection(
+				&net.UDPAddr{IP: net.IPv4(192, 168, 13, 37), Port: 42},
+				&net.UDPAddr{IP: net.IPv4(192, 168, 12, 34), Port: 24},
+				0xdeadbeef,
+				logging.ConnectionID{1, 2, 3, 4},
+				logging.ConnectionID{5, 6, 7, 8, 9, 10, 11, 12},
+			)
+			entry := exportAndParseSingle()
+			Expect(entry.Time).To(BeTemporally("~", time.Now(), scaleDuration(10*time.Millisecond)))
+			Expect(entry.Category).To(Equal("connectivity"))
+			Expect(entry.Name).To(Equal("connection_started"))
+			ev := entry.Event
+			Expect(ev).To(HaveKeyWithValue("ip_version", "ipv4"))
+			Expect(ev).To(HaveKeyWithValue("src_ip", "192.168.13.37"))
+			Expect(ev).To(HaveKeyWithValue("src_port", float64(42)))
+			Expect(ev).To(HaveKeyWithValue("dst_ip", "192.168.12.34"))
+			Expect(ev).To(HaveKeyWithValue("dst_port", float64(24)))
+			Expect(ev).To(HaveKeyWithValue("quic_version", "deadbeef"))
+			Expect(ev).To(HaveKeyWithValue("src_cid", "01020304"))
+			Expect(ev).To(HaveKeyWithValue("dst_cid", "05060708090a0b0c"))
+		})
+
+		It("doesn't record connection starts on non-UDP connections", func() {
+			tracer.StartedConnection(
+				&net.TCPAddr{IP: net.IPv4(192, 168, 13, 37), Port: 42},
+				&net.TCPAddr{IP: net.IPv4(192, 168, 12, 34), Port: 24},
+				0xdeadbeef,
+				logging.ConnectionID{1, 2, 3, 4},
+				logging.ConnectionID{5, 6, 7, 8},
+			)
+			Expect(exportAndParse()).To(BeEmpty())
+		})
+
+		It("records connection closes", func() {
+			tracer.ClosedConnection(errors.New("idle timeout"), false)
+			entry := exportAndParseSingle()
+			Expect(entry.Category).To(Equal("connectivity"))
+			Expect(entry.Name).To(Equal("connection_closed"))
+			ev := entry.Event
+			Expect(ev).To(HaveKeyWithValue("owner", "local"))
+			Expect(ev).To(HaveKeyWithValue("reason", "idle timeout"))
+		})
+
+		It("records sent transport parameters", func() {
+			tracer.SentTransportParameters(&logging.TransportParameters{
+				InitialMaxStreamDataBidiLocal:  1000,
+				InitialMaxStreamDataBidiRemote: 2000,
+				InitialMaxStreamDataUni:        3000,
+				InitialMaxData:                 4000,
+				MaxBidiStreams:                 10,
+				MaxUniStreams:                  20,
+				MaxPacketSize:                  1234,
+				IdleTimeout:                    321 * time.Millisecond,
+				AckDelayExponent:               12,
+				DisableMigration:               true,
+				StatelessResetToken:            &[16]byte{0x11, 0x22, 0x33, 0x44, 0x55, 0x66, 0x77, 0x88, 0x99, 0xaa, 0xbb, 0xcc, 0xdd, 0xee, 0xff, 0x00},
+				OriginalConnectionID:           logging.ConnectionID{0xde, 0xad, 0xc0, 0xde},
+			})
+			entry := exportAndParseSingle()
+			Expect(entry.Category).To(Equal("transport"))
+			Expect(entry.Name).To(Equal("parameters_set"))
+			ev := entry.Event
+			Expect(ev).To(HaveKeyWithValue("owner", "local"))
+			Expect(ev).To(HaveKeyWithValue("original_connection_id", "deadc0de"))
+			Expect(ev).To(HaveKeyWithValue("stateless_reset_token", "112233445566778899aabbccddeeff00"))
+			Expect(ev).To(HaveKeyWithValue("disable_active_migration", true))
+			Expect(ev).To(HaveKeyWithValue("idle_timeout", float64(321)))
+			Expect(ev).To(HaveKeyWithValue("max_packet_size", float64(1234)))
+			Expect(ev).To(HaveKeyWithValue("ack_delay_exponent", float64(12)))
+			Expect(ev).To(HaveKeyWithValue("initial_max_data", float64(4000)))
+			Expect(ev).To(HaveKeyWithValue("initial_max_stream_data_bidi_local", float64(1000)))
+			Expect(ev).To(HaveKeyWithValue("initial_max_stream_data_bidi_remote", float64(2000)))
+			Expect(ev).To(HaveKeyWithValue("initial_max_stream_data_uni", float64(3000)))
+			Expect(ev).To(HaveKeyWithValue("initial_max_streams_bidi", float64(10)))
+			Expect(ev).To(HaveKeyWithValue("initial_max_streams_uni", float64(20)))
+		})
+
+		It("records received transport parameters", func() {
+			tracer.ReceivedTransportParameters(&logging.TransportParameters{})
+			entry := exportAndParseSingle()
+			Expect(entry.Name).To(Equal("parameters_set"))
+			ev := entry.Event
+			Expect(ev).To(HaveKeyWithValue("owner", "remote"))
+			Expect(ev).ToNot(HaveKey("original_connection_id"))
+			Expect(ev).ToNot(HaveKey("stateless_reset_token"))
+		})
+
+		It("records a sent packet, without an ACK", func() {
+			tracer.SentPacket(
+				&logging.ExtendedHeader{
+					Header: logging.Header{
+						IsLongHeader:     true,
+						Type:             protocol.PacketTypeInitial,
+						DestConnectionID: logging.ConnectionID{1, 2, 3, 4, 5, 6, 7, 8},
+						SrcConnectionID:  logging.ConnectionID{4, 3, 2, 1},
+						Version:          0x1337,
+					},
+					PacketNumber: 1337,
+				},
+				987,
+				[]logging.Frame{
+					&logging.MaxStreamDataFrame{StreamID: 42, ByteOffset: 987},
+					&logging.StreamFrame{StreamID: 123, Offset: 1234, Data: []byte("foobar"), FinBit: true},
+				},
+			)
+			entry := exportAndParseSingle()
+			Expect(entry.Category).To(Equal("transport"))
+			Expect(entry.Name).To(Equal("packet_sent"))
+			ev := entry.Event
+			Expect(ev).To(HaveKeyWithValue("packet_type", "initial"))
+			Expect(ev).To(HaveKey("header"))
+			hdr := ev["header"].(map[string]interface{})
+			Expect(hdr).To(HaveKeyWithValue("packet_number", float64(1337)))
+			Expect(hdr).To(HaveKeyWithValue("packet_size", float64(987)))
+			Expect(hdr).To(HaveKeyWithValue("scid", "04030201"))
+			Expect(hdr).To(HaveKeyWithValue("dcid", "0102030405060708"))
+			Expect(hdr).To(HaveKeyWithValue("version", "1337"))
+			Expect(ev).To(HaveKey("frames"))
+			frames := ev["frames"].([]interface{})
+			Expect(frames).To(HaveLen(2))
+			Expect(frames[0].(map[string]interface{})).To(HaveKeyWithValue("frame_type", "max_stream_data"))
+			Expect(frames[1].(map[string]interface{})).To(HaveKeyWithValue("frame_type", "stream"))
+		})
+
+		It("records a sent 1-RTT packet", func() {
+			tracer.SentPacket(
+				&logging.ExtendedHeader{
+					Header:       logging.Header{DestConnectionID: logging.ConnectionID{1, 2, 3, 4}},
+					PacketNumber: 1337,
+				},
+				123,
+				[]logging.Frame{&logging.AckFrame{AckRanges: []logging.AckRange{{Smallest: 1, Largest: 10}}}},
+			)
+			entry := exportAndParseSingle()
+			ev := entry.Event
+			Expect(ev).To(HaveKeyWithValue("packet_type", "1RTT"))
+			hdr := ev["header"].(map[string]interface{})
+			Expect(hdr).ToNot(HaveKey("scid"))
+			Expect(hdr).ToNot(HaveKey("version"))
+			frames := ev["frames"].([]interface{})
+			Expect(frames).To(HaveLen(1))
+			Expect(frames[0].(map[string]interface{})).To(HaveKeyWithValue("frame_type", "ack"))
+		})
+
+		It("records a received packet", func() {
+			tracer.ReceivedPacket(
+				&logging.ExtendedHeader{
+					Header: logging.Header{
+						IsLongHeader:     true,
+						Type:             protocol.PacketTypeInitial,
+						DestConnectionID: logging.ConnectionID{1, 2, 3, 4, 5, 6, 7, 8},
+						SrcConnectionID:  logging.ConnectionID{4, 3, 2, 1},
+						Token:            []byte{0xde, 0xad, 0xbe, 0xef},
+						Version:          0x1337,
+					},
+					PacketNumber: 1337,
+				},
+				789,
+				[]logging.Frame{
+					&logging.MaxStreamDataFrame{StreamID: 42, ByteOffset: 987},
+				},
+			)
+			entry := exportAndParseSingle()
+			Expect(entry.Category).To(Equal("transport"))
+			Expect(entry.Name).To(Equal("packet_received"))
+			ev := entry.Event
+			Expect(ev).To(HaveKeyWithValue("packet_type", "initial"))
+			hdr := ev["header"].(map[string]interface{})
+			Expect(hdr).To(HaveKeyWithValue("packet_size", float64(789)))
+			Expect(hdr).To(HaveKeyWithValue("token", "deadbeef"))
+			Expect(ev["frames"].([]interface{})).To(HaveLen(1))
+		})
+
+		It("records a received Retry packet", func() {
+			tracer.ReceivedRetry(
+				&logging.Header{
+					IsLongHeader:     true,
+					Type:             protocol.PacketTypeRetry,
+					DestConnectionID: logging.ConnectionID{1, 2, 3, 4, 5, 6, 7, 8},
+					SrcConnectionID:  logging.ConnectionID{4, 3, 2, 1},
+					Token:            []byte{0xde, 0xad, 0xbe, 0xef},
+					Version:          0x1337,
+				},
+			)
+			entry := exportAndParseSingle()
+			Expect(entry.Category).To(Equal("transport"))
+			Expect(entry.Name).To(Equal("packet_received"))
+			ev := entry.Event
+			Expect(ev).To(HaveKeyWithValue("packet_type", "retry"))
+			hdr := ev["header"].(map[string]interface{})
+			Expect(hdr).To(HaveKeyWithValue("token", "deadbeef"))
+			Expect(ev["frames"]).To(BeEmpty())
+		})
+
+		It("records buffered packets", func() {
+			tracer.BufferedPacket(logging.PacketTypeHandshake)
+			entry := exportAndParseSingle()
+			Expect(entry.Category).To(Equal("transport"))
+			Expect(entry.Name).To(Equal("packet_buffered"))
+			Expect(entry.Event).To(HaveKeyWithValue("packet_type", "handshake"))
+		})
+
+		It("records dropped packets", func() {
+			tracer.DroppedPacket(logging.PacketTypeHandshake, 1337, logging.PacketDropPayloadDecryptError)
+			entry := exportAndParseSingle()
+			Expect(entry.Category).To(Equal("transport"))
+			Expect(entry.Name).To(Equal("packet_dropped"))
+			ev := entry.Event
+			Expect(ev).To(HaveKeyWithValue("packet_type", "handshake"))
+			Expect(ev).To(HaveKeyWithValue("packet_size", float64(1337)))
+			Expect(ev).To(HaveKeyWithValue("trigger", "payload_decrypt_error"))
+		})
+
+		It("records metrics updates", func() {
+			now := time.Now()
+			rttStats := &congestion.RTTStats{}
+			rttStats.UpdateRTT(15*time.Millisecond, 0, now)
+			rttStats.UpdateRTT(20*time.Millisecond, 0, now)
+			rttStats.UpdateRTT(25*time.Millisecond, 0, now)
+			Expect(rttStats.MinRTT()).To(Equal(15 * time.Millisecond))
+			tracer.UpdatedMetrics(rttStats, 4321, 1234, 42)
+			entry := exportAndParseSingle()
+			Expect(entry.Category).To(Equal("recovery"))
+			Expect(entry.Name).To(Equal("metrics_updated"))
+			ev := entry.Event
+			Expect(ev).To(HaveKeyWithValue("min_rtt", float64(15)))
+			Expect(ev).To(HaveKeyWithValue("latest_rtt", float64(25)))
+			Expect(ev).To(HaveKey("smoothed_rtt"))
+			Expect(time.Duration(ev["smoothed_rtt"].(float64)) * time.Millisecond).To(BeNumerically("~", rttStats.SmoothedRTT(), time.Millisecond))
+			Expect(ev).To(HaveKey("rtt_variance"))
+			Expect(time.Duration(ev["rtt_variance"].(float64)) * time.Millisecond).To(BeNumerically("~", rttStats.MeanDeviation(), time.Millisecond))
+			Expect(ev).To(HaveKeyWithValue("congestion_window", float64(4321)))
+			Expect(ev).To(HaveKeyWithValue("bytes_in_flight", float64(1234)))
+			Expect(ev).To(HaveKeyWithValue("packets_in_flight", float64(42)))
+		})
+
+		It("only records metrics updates if they changed", func() {
+			rttStats := &congestion.RTTStats{}
+			rttStats.UpdateRTT(15*time.Millisecond, 0, time.Now())
+			tracer.UpdatedMetrics(rttStats, 4321, 1234, 42)
+			tracer.UpdatedMetrics(rttStats, 4321, 1234, 42)
+			tracer.UpdatedMetrics(rttStats, 4321, 1234, 43)
+			entries := exportAndParse()
+			Expect(entries).To(HaveLen(2))
+			Expect(entries[0].Event).To(HaveKeyWithValue("packets_in_flight", float64(42)))
+			Expect(entries[1].Event).To(HaveKeyWithValue("packets_in_flight", float64(43)))
+		})
+
+		It("records PTO count changes", func() {
+			tracer.UpdatedPTOCount(42)
+			entry := exportAndParseSingle()
+			Expect(entry.Category).To(Equal("recovery"))
+			Expect(entry.Name).To(Equal("metrics_updated"))
+			Expect(entry.Event).To(HaveKeyWithValue("pto_count", float64(42)))
+		})
+
+		It("records congestion state changes", func() {
+			tracer.UpdatedCongestionState(logging.CongestionStateRecovery)
+			entry := exportAndParseSingle()
+			Expect(entry.Category).To(Equal("recovery"))
+			Expect(entry.Name).To(Equal("congestion_state_updated"))
+			Expect(entry.Event).To(HaveKeyWithValue("new", "recovery"))
+		})
+
+		It("records lost packets", func() {
+			tracer.LostPacket(logging.EncryptionHandshake, 42, logging.PacketLossTimeThreshold)
+			entry := exportAndParseSingle()
+			Expect(entry.Category).To(Equal("recovery"))
+			Expect(entry.Name).To(Equal("packet_lost"))
+			ev := entry.Event
+			Expect(ev).To(HaveKeyWithValue("packet_type", "handshake"))
+			Expect(ev).To(HaveKeyWithValue("packet_number", float64(42)))
+			Expect(ev).To(HaveKeyWithValue("trigger", "time_threshold"))
+		})
+
+		It("records TLS key updates", func() {
+			tracer.UpdatedKeyFromTLS(logging.EncryptionHandshake, logging.PerspectiveClient)
+			entry := exportAndParseSingle()
+			Expect(entry.Category).To(Equal("security"))
+			Expect(entry.Name).To(Equal("key_updated"))
+			ev := entry.Event
+			Expect(ev).To(HaveKeyWithValue("key_type", "client_handshake_secret"))
+			Expect(ev).To(HaveKeyWithValue("trigger", "tls"))
+			Expect(ev).ToNot(HaveKey("generation"))
+		})
+
+		It("records QUIC key updates", func() {
+			tracer.UpdatedKey(1337, true)
+			entries := exportAndParse()
+			Expect(entries).To(HaveLen(2))
+			var keyTypes []string
+			for _, entry := range entries {
+				Expect(entry.Category).To(Equal("security"))
+				Expect(entry.Name).To(Equal("key_updated"))
+				ev := entry.Event
+				Expect(ev).To(HaveKeyWithValue("generation", float64(1337)))
+				Expect(ev).To(HaveKeyWithValue("trigger", "remote_update"))
+				keyTypes = append(keyTypes, ev["key_type"].(string))
+			}
+			Expect(keyTypes).To(ContainElement("server_1rtt_secret"))
+			Expect(keyTypes).To(ContainElement("client_1rtt_secret"))
+		})
+	})
+})
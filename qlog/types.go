@@ -0,0 +1,240 @@
+package qlog
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	"github.com/lucas-clemente/quic-go/logging"
+)
+
+type owner uint8
+
+const (
+	ownerLocal owner = iota
+	ownerRemote
+)
+
+func (o owner) String() string {
+	switch o {
+	case ownerLocal:
+		return "local"
+	case ownerRemote:
+		return "remote"
+	default:
+		return "unknown owner"
+	}
+}
+
+func (o owner) MarshalJSON() ([]byte, error) { return json.Marshal(o.String()) }
+
+type perspective logging.Perspective
+
+func (p perspective) String() string {
+	switch logging.Perspective(p) {
+	case logging.PerspectiveClient:
+		return "client"
+	case logging.PerspectiveServer:
+		return "server"
+	default:
+		return "unknown perspective"
+	}
+}
+
+type connectionID logging.ConnectionID
+
+func (c connectionID) MarshalJSON() ([]byte, error) {
+	return json.Marshal(hex.EncodeToString(c))
+}
+
+type versionNumber logging.VersionNumber
+
+func (v versionNumber) MarshalJSON() ([]byte, error) {
+	return json.Marshal(fmt.Sprintf("%x", uint32(v)))
+}
+
+type streamType logging.StreamType
+
+func (s streamType) String() string {
+	switch logging.StreamType(s) {
+	case logging.StreamTypeUni:
+		return "unidirectional"
+	case logging.StreamTypeBidi:
+		return "bidirectional"
+	default:
+		return "unknown stream type"
+	}
+}
+
+func (s streamType) MarshalJSON() ([]byte, error) { return json.Marshal(s.String()) }
+
+type packetType logging.PacketType
+
+func (t packetType) String() string {
+	switch logging.PacketType(t) {
+	case logging.PacketTypeInitial:
+		return "initial"
+	case logging.PacketTypeHandshake:
+		return "handshake"
+	case logging.PacketTypeRetry:
+		return "retry"
+	case logging.PacketType0RTT:
+		return "0RTT"
+	case logging.PacketTypeVersionNegotiation:
+		return "version_negotiation"
+	case logging.PacketType1RTT:
+		return "1RTT"
+	default:
+		return "unknown"
+	}
+}
+
+func (t packetType) MarshalJSON() ([]byte, error) { return json.Marshal(t.String()) }
+
+func packetTypeFromEncryptionLevel(encLevel logging.EncryptionLevel) packetType {
+	switch encLevel {
+	case logging.EncryptionInitial:
+		return packetType(logging.PacketTypeInitial)
+	case logging.EncryptionHandshake:
+		return packetType(logging.PacketTypeHandshake)
+	case logging.Encryption1RTT:
+		return packetType(logging.PacketType1RTT)
+	default:
+		return packetType(logging.PacketTypeNotDetermined)
+	}
+}
+
+type packetDropReason logging.PacketDropReason
+
+func (r packetDropReason) String() string {
+	switch logging.PacketDropReason(r) {
+	case logging.PacketDropKeyUnavailable:
+		return "key_unavailable"
+	case logging.PacketDropUnknownConnectionID:
+		return "unknown_connection_id"
+	case logging.PacketDropHeaderParseError:
+		return "header_parse_error"
+	case logging.PacketDropPayloadDecryptError:
+		return "payload_decrypt_error"
+	case logging.PacketDropUnexpectedPacket:
+		return "unexpected_packet"
+	case logging.PacketDropUnexpectedSourceConnectionID:
+		return "unexpected_source_connection_id"
+	case logging.PacketDropDOSPrevention:
+		return "dos_prevention"
+	default:
+		return "unknown packet drop reason"
+	}
+}
+
+func (r packetDropReason) MarshalJSON() ([]byte, error) { return json.Marshal(r.String()) }
+
+type packetLossReason logging.PacketLossReason
+
+func (r packetLossReason) String() string {
+	switch logging.PacketLossReason(r) {
+	case logging.PacketLossTimeThreshold:
+		return "time_threshold"
+	default:
+		return "unknown loss reason"
+	}
+}
+
+func (r packetLossReason) MarshalJSON() ([]byte, error) { return json.Marshal(r.String()) }
+
+type congestionState logging.CongestionState
+
+func (s congestionState) String() string {
+	switch logging.CongestionState(s) {
+	case logging.CongestionStateSlowStart:
+		return "slow_start"
+	case logging.CongestionStateCongestionAvoidance:
+		return "congestion_avoidance"
+	case logging.CongestionStateRecovery:
+		return "recovery"
+	default:
+		return "unknown congestion state"
+	}
+}
+
+func (s congestionState) MarshalJSON() ([]byte, error) { return json.Marshal(s.String()) }
+
+type keyType uint8
+
+const (
+	keyTypeServerInitial keyType = 1 + iota
+	keyTypeClientInitial
+	keyTypeServerHandshake
+	keyTypeClientHandshake
+	keyTypeServer1RTT
+	keyTypeClient1RTT
+)
+
+func encLevelToKeyType(encLevel logging.EncryptionLevel, pers logging.Perspective) keyType {
+	if pers == logging.PerspectiveServer {
+		switch encLevel {
+		case logging.EncryptionInitial:
+			return keyTypeServerInitial
+		case logging.EncryptionHandshake:
+			return keyTypeServerHandshake
+		case logging.Encryption1RTT:
+			return keyTypeServer1RTT
+		default:
+			return 0
+		}
+	}
+	switch encLevel {
+	case logging.EncryptionInitial:
+		return keyTypeClientInitial
+	case logging.EncryptionHandshake:
+		return keyTypeClientHandshake
+	case logging.Encryption1RTT:
+		return keyTypeClient1RTT
+	default:
+		return 0
+	}
+}
+
+func (t keyType) String() string {
+	switch t {
+	case keyTypeServerInitial:
+		return "server_initial_secret"
+	case keyTypeClientInitial:
+		return "client_initial_secret"
+	case keyTypeServerHandshake:
+		return "server_handshake_secret"
+	case keyTypeClientHandshake:
+		return "client_handshake_secret"
+	case keyTypeServer1RTT:
+		return "server_1rtt_secret"
+	case keyTypeClient1RTT:
+		return "client_1rtt_secret"
+	default:
+		return "unknown key type"
+	}
+}
+
+func (t keyType) MarshalJSON() ([]byte, error) { return json.Marshal(t.String()) }
+
+type keyUpdateTrigger uint8
+
+const (
+	keyUpdateTLS keyUpdateTrigger = iota
+	keyUpdateRemote
+	keyUpdateLocal
+)
+
+func (t keyUpdateTrigger) String() string {
+	switch t {
+	case keyUpdateTLS:
+		return "tls"
+	case keyUpdateRemote:
+		return "remote_update"
+	case keyUpdateLocal:
+		return "local_update"
+	default:
+		return "unknown key update trigger"
+	}
+}
+
+func (t keyUpdateTrigger) MarshalJSON() ([]byte, error) { return json.Marshal(t.String()) }
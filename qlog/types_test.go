@@ -0,0 +1,71 @@
+package qlog
+
+import (
+	"github.com/lucas-clemente/quic-go/logging"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Types", func() {
+	It("has a string representation for the owner", func() {
+		Expect(ownerLocal.String()).To(Equal("local"))
+		Expect(ownerRemote.String()).To(Equal("remote"))
+	})
+
+	It("has a string representation for the perspective", func() {
+		Expect(perspective(logging.PerspectiveClient).String()).To(Equal("client"))
+		Expect(perspective(logging.PerspectiveServer).String()).To(Equal("server"))
+	})
+
+	It("has a string representation for the packet type", func() {
+		Expect(packetType(logging.PacketTypeInitial).String()).To(Equal("initial"))
+		Expect(packetType(logging.PacketTypeHandshake).String()).To(Equal("handshake"))
+		Expect(packetType(logging.PacketTypeRetry).String()).To(Equal("retry"))
+		Expect(packetType(logging.PacketType0RTT).String()).To(Equal("0RTT"))
+		Expect(packetType(logging.PacketTypeVersionNegotiation).String()).To(Equal("version_negotiation"))
+		Expect(packetType(logging.PacketType1RTT).String()).To(Equal("1RTT"))
+		Expect(packetType(logging.PacketTypeNotDetermined).String()).To(Equal("unknown"))
+	})
+
+	It("determines the packet type from the encryption level", func() {
+		Expect(packetTypeFromEncryptionLevel(logging.EncryptionInitial)).To(BeEquivalentTo(logging.PacketTypeInitial))
+		Expect(packetTypeFromEncryptionLevel(logging.EncryptionHandshake)).To(BeEquivalentTo(logging.PacketTypeHandshake))
+		Expect(packetTypeFromEncryptionLevel(logging.Encryption1RTT)).To(BeEquivalentTo(logging.PacketType1RTT))
+	})
+
+	It("has a string representation for the packet drop reason", func() {
+		Expect(packetDropReason(logging.PacketDropKeyUnavailable).String()).To(Equal("key_unavailable"))
+		Expect(packetDropReason(logging.PacketDropUnknownConnectionID).String()).To(Equal("unknown_connection_id"))
+		Expect(packetDropReason(logging.PacketDropHeaderParseError).String()).To(Equal("header_parse_error"))
+		Expect(packetDropReason(logging.PacketDropPayloadDecryptError).String()).To(Equal("payload_decrypt_error"))
+		Expect(packetDropReason(logging.PacketDropUnexpectedPacket).String()).To(Equal("unexpected_packet"))
+		Expect(packetDropReason(logging.PacketDropUnexpectedSourceConnectionID).String()).To(Equal("unexpected_source_connection_id"))
+		Expect(packetDropReason(logging.PacketDropDOSPrevention).String()).To(Equal("dos_prevention"))
+	})
+
+	It("has a string representation for the packet loss reason", func() {
+		Expect(packetLossReason(logging.PacketLossTimeThreshold).String()).To(Equal("time_threshold"))
+	})
+
+	It("has a string representation for the congestion state", func() {
+		Expect(congestionState(logging.CongestionStateSlowStart).String()).To(Equal("slow_start"))
+		Expect(congestionState(logging.CongestionStateCongestionAvoidance).String()).To(Equal("congestion_avoidance"))
+		Expect(congestionState(logging.CongestionStateRecovery).String()).To(Equal("recovery"))
+	})
+
+	It("has a string representation for the key type", func() {
+		Expect(encLevelToKeyType(logging.EncryptionInitial, logging.PerspectiveClient).String()).To(Equal("client_initial_secret"))
+		Expect(encLevelToKeyType(logging.EncryptionInitial, logging.PerspectiveServer).String()).To(Equal("server_initial_secret"))
+		Expect(encLevelToKeyType(logging.EncryptionHandshake, logging.PerspectiveClient).String()).To(Equal("client_handshake_secret"))
+		Expect(encLevelToKeyType(logging.EncryptionHandshake, logging.PerspectiveServer).String()).To(Equal("server_handshake_secret"))
+		Expect(encLevelToKeyType(logging.Encryption1RTT, logging.PerspectiveClient).String()).To(Equal("client_1rtt_secret"))
+		Expect(encLevelToKeyType(logging.Encryption1RTT, logging.PerspectiveServer).String()).To(Equal("server_1rtt_secret"))
+	})
+
+	It("has a string representation for the key update trigger", func() {
+		Expect(keyUpdateTLS.String()).To(Equal("tls"))
+		Expect(keyUpdateRemote.String()).To(Equal("remote_update"))
+		Expect(keyUpdateLocal.String()).To(Equal("local_update"))
+	})
+})
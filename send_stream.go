@@ -311,6 +311,11 @@ func (s *sendStream) SetWriteDeadline(t time.Time) error {
 	return nil
 }
 
+func (s *sendStream) SetPriority(p StreamPriority) {
+	// must be called without holding the mutex, since the framer acquires it when popping STREAM frames
+	s.sender.onStreamPriorityChanged(s.streamID, p)
+}
+
 // CloseForShutdown closes a stream abruptly.
 // It makes Write unblock (and return the error) immediately.
 // The peer will NOT be informed about this: the stream is closed without sending a FIN or RST.
@@ -49,6 +49,11 @@ var _ = Describe("Send Stream", func() {
 		Expect(str.StreamID()).To(Equal(protocol.StreamID(1337)))
 	})
 
+	It("tells the sender when the priority changes", func() {
+		mockSender.EXPECT().onStreamPriorityChanged(streamID, StreamPriority{Urgency: 1, Incremental: true})
+		str.SetPriority(StreamPriority{Urgency: 1, Incremental: true})
+	})
+
 	Context("writing", func() {
 		It("writes and gets all data at once", func() {
 			mockSender.EXPECT().onHasStreamData(streamID)
@@ -11,11 +11,13 @@ import (
 	"sync/atomic"
 	"time"
 
+	"github.com/lucas-clemente/quic-go/congestion"
 	"github.com/lucas-clemente/quic-go/internal/handshake"
 	"github.com/lucas-clemente/quic-go/internal/protocol"
 	"github.com/lucas-clemente/quic-go/internal/qerr"
 	"github.com/lucas-clemente/quic-go/internal/utils"
 	"github.com/lucas-clemente/quic-go/internal/wire"
+	"github.com/lucas-clemente/quic-go/logging"
 )
 
 // packetHandler handles packets
@@ -41,6 +43,7 @@ type packetHandlerManager interface {
 	GetStatelessResetToken(protocol.ConnectionID) [16]byte
 	SetServer(unknownPacketHandler)
 	CloseServer()
+	StopListening()
 }
 
 type quicSession interface {
@@ -56,10 +59,12 @@ type quicSession interface {
 
 type sessionRunner interface {
 	OnHandshakeComplete(Session)
+	Add(protocol.ConnectionID, packetHandler)
 	Retire(protocol.ConnectionID)
 	Remove(protocol.ConnectionID)
 	AddResetToken([16]byte, packetHandler)
 	RemoveResetToken([16]byte)
+	GetStatelessResetToken(protocol.ConnectionID) [16]byte
 }
 
 type runner struct {
@@ -130,6 +135,7 @@ func ListenAddr(addr string, tlsConf *tls.Config, config *Config) (Listener, err
 // A single PacketConn only be used for a single call to Listen.
 // The PacketConn can be used for simultaneous calls to Dial.
 // QUIC connection IDs are used for demultiplexing the different connections.
+// To make sure that Dial and Listen use consistent settings on the PacketConn, use a Transport.
 // The tls.Config must not be nil and must contain a certificate configuration.
 // The quic.Config may be nil, in that case the default values will be used.
 func Listen(conn net.PacketConn, tlsConf *tls.Config, config *Config) (Listener, error) {
@@ -148,7 +154,7 @@ func listen(conn net.PacketConn, tlsConf *tls.Config, config *Config) (*server,
 		}
 	}
 
-	sessionHandler, err := getMultiplexer().AddConn(conn, config.ConnectionIDLength, config.StatelessResetKey)
+	sessionHandler, err := getMultiplexer().AddConn(conn, config.ConnectionIDLength, config.StatelessResetKey, config.Metrics, config.EnableBatchedIO, !config.DisablePathMTUDiscovery, config.EnableECN)
 	if err != nil {
 		return nil, err
 	}
@@ -255,6 +261,14 @@ func populateServerConfig(config *Config) *Config {
 	} else if maxIncomingUniStreams < 0 {
 		maxIncomingUniStreams = 0
 	}
+	keyUpdateInterval := config.KeyUpdateInterval
+	if keyUpdateInterval == 0 {
+		keyUpdateInterval = protocol.DefaultKeyUpdateInterval
+	}
+	congestionControl := config.CongestionControl
+	if congestionControl == nil {
+		congestionControl = congestion.NewCubic
+	}
 	connIDLen := config.ConnectionIDLength
 	if connIDLen == 0 {
 		connIDLen = protocol.DefaultConnectionIDLength
@@ -272,6 +286,14 @@ func populateServerConfig(config *Config) *Config {
 		MaxIncomingUniStreams:                 maxIncomingUniStreams,
 		ConnectionIDLength:                    connIDLen,
 		StatelessResetKey:                     config.StatelessResetKey,
+		KeyUpdateInterval:                     keyUpdateInterval,
+		CongestionControl:                     congestionControl,
+		EnableDatagrams:                       config.EnableDatagrams,
+		DisablePathMTUDiscovery:               config.DisablePathMTUDiscovery,
+		EnableBatchedIO:                       config.EnableBatchedIO,
+		EnableECN:                             config.EnableECN,
+		Tracer:                                config.Tracer,
+		Metrics:                               config.Metrics,
 	}
 }
 
@@ -338,6 +360,7 @@ func (s *server) handlePacket(p *receivedPacket) {
 func (s *server) handlePacketImpl(p *receivedPacket) bool /* was the packet passed on to a session */ {
 	if len(p.data) < protocol.MinInitialPacketSize {
 		s.logger.Debugf("Dropping a packet that is too small to be a valid Initial (%d bytes)", len(p.data))
+		s.droppedPacket(logging.PacketDropUnexpectedPacket)
 		return false
 	}
 	// If we're creating a new session, the packet will be passed to the session.
@@ -345,10 +368,21 @@ func (s *server) handlePacketImpl(p *receivedPacket) bool /* was the packet pass
 	hdr, _, _, err := wire.ParsePacket(p.data, s.config.ConnectionIDLength)
 	if err != nil {
 		s.logger.Debugf("Error parsing packet: %s", err)
+		s.droppedPacket(logging.PacketDropHeaderParseError)
 		return false
 	}
 	// Short header packets should never end up here in the first place
 	if !hdr.IsLongHeader {
+		s.droppedPacket(logging.PacketDropUnexpectedPacket)
+		return false
+	}
+	// Only clients receive Version Negotiation packets.
+	// If the packet conn is shared with dialed connections (see Transport),
+	// this might be a Version Negotiation packet for a connection that was already closed.
+	// Responding with a Version Negotiation packet could lead to an endless exchange of them.
+	if hdr.Version == 0 {
+		s.logger.Debugf("Dropping Version Negotiation packet for an unknown connection.")
+		s.droppedPacket(logging.PacketDropUnexpectedPacket)
 		return false
 	}
 	// send a Version Negotiation Packet if the client is speaking a different protocol version
@@ -360,6 +394,7 @@ func (s *server) handlePacketImpl(p *receivedPacket) bool /* was the packet pass
 		// Drop long header packets.
 		// There's litte point in sending a Stateless Reset, since the client
 		// might not have received the token yet.
+		s.droppedPacket(logging.PacketDropUnexpectedPacket)
 		return false
 	}
 
@@ -446,12 +481,17 @@ func (s *server) createNewSession(
 		MaxBidiStreams:                 uint64(s.config.MaxIncomingStreams),
 		MaxUniStreams:                  uint64(s.config.MaxIncomingUniStreams),
 		AckDelayExponent:               protocol.AckDelayExponent,
-		DisableMigration:               true,
 		StatelessResetToken:            &token,
 		OriginalConnectionID:           origDestConnID,
 	}
+	if !s.config.DisablePathMTUDiscovery {
+		params.MaxPacketSize = protocol.MaxPacketSizePMTUD
+	}
+	if s.config.EnableDatagrams {
+		params.MaxDatagramFrameSize = protocol.MaxDatagramFrameSize
+	}
 	sess, err := s.newSession(
-		&conn{pconn: s.conn, currentAddr: remoteAddr},
+		&conn{pconn: s.conn, currentAddr: remoteAddr, batchWriter: getBatchWriter(s.conn, s.config.EnableBatchedIO)},
 		s.sessionRunner,
 		clientDestConnID,
 		destConnID,
@@ -494,6 +534,10 @@ func (s *server) sendRetry(remoteAddr net.Addr, hdr *wire.Header) error {
 	}
 	if _, err := s.conn.WriteTo(buf.Bytes(), remoteAddr); err != nil {
 		s.logger.Debugf("Error sending Retry: %s", err)
+		return nil
+	}
+	if s.config.Metrics != nil {
+		s.config.Metrics.SentRetry()
 	}
 	return nil
 }
@@ -541,6 +585,10 @@ func (s *server) sendServerBusy(remoteAddr net.Addr, hdr *wire.Header) error {
 	wire.LogFrame(s.logger, ccf, true)
 	if _, err := s.conn.WriteTo(raw, remoteAddr); err != nil {
 		s.logger.Debugf("Error rejecting connection: %s", err)
+		return nil
+	}
+	if s.config.Metrics != nil {
+		s.config.Metrics.SentServerBusy()
 	}
 	return nil
 }
@@ -554,5 +602,15 @@ func (s *server) sendVersionNegotiationPacket(p *receivedPacket, hdr *wire.Heade
 	}
 	if _, err := s.conn.WriteTo(data, p.remoteAddr); err != nil {
 		s.logger.Debugf("Error sending Version Negotiation: %s", err)
+		return
+	}
+	if s.config.Metrics != nil {
+		s.config.Metrics.SentVersionNegotiation()
+	}
+}
+
+func (s *server) droppedPacket(reason logging.PacketDropReason) {
+	if s.config.Metrics != nil {
+		s.config.Metrics.DroppedPacket(reason)
 	}
 }
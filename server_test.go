@@ -10,11 +10,14 @@ import (
 	"sync"
 	"time"
 
+	"github.com/lucas-clemente/quic-go/congestion"
 	"github.com/lucas-clemente/quic-go/internal/handshake"
+	mocklogging "github.com/lucas-clemente/quic-go/internal/mocks/logging"
 	"github.com/lucas-clemente/quic-go/internal/protocol"
 	"github.com/lucas-clemente/quic-go/internal/testdata"
 	"github.com/lucas-clemente/quic-go/internal/utils"
 	"github.com/lucas-clemente/quic-go/internal/wire"
+	"github.com/lucas-clemente/quic-go/logging"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -71,6 +74,7 @@ var _ = Describe("Server", func() {
 		Expect(server.config.IdleTimeout).To(Equal(protocol.DefaultIdleTimeout))
 		Expect(reflect.ValueOf(server.config.AcceptCookie)).To(Equal(reflect.ValueOf(defaultAcceptCookie)))
 		Expect(server.config.KeepAlive).To(BeFalse())
+		Expect(reflect.ValueOf(server.config.CongestionControl)).To(Equal(reflect.ValueOf(congestion.NewCubic)))
 		// stop the listener
 		Expect(ln.Close()).To(Succeed())
 	})
@@ -78,6 +82,8 @@ var _ = Describe("Server", func() {
 	It("setups with the right values", func() {
 		supportedVersions := []protocol.VersionNumber{protocol.VersionTLS}
 		acceptCookie := func(_ net.Addr, _ *Cookie) bool { return true }
+		tracer := mocklogging.NewMockTracer(mockCtrl)
+		metrics := mocklogging.NewMockMetrics(mockCtrl)
 		config := Config{
 			Versions:          supportedVersions,
 			AcceptCookie:      acceptCookie,
@@ -85,6 +91,9 @@ var _ = Describe("Server", func() {
 			IdleTimeout:       42 * time.Minute,
 			KeepAlive:         true,
 			StatelessResetKey: []byte("foobar"),
+			CongestionControl: congestion.NewReno,
+			Tracer:            tracer,
+			Metrics:           metrics,
 		}
 		ln, err := Listen(conn, tlsConf, &config)
 		Expect(err).ToNot(HaveOccurred())
@@ -96,6 +105,9 @@ var _ = Describe("Server", func() {
 		Expect(reflect.ValueOf(server.config.AcceptCookie)).To(Equal(reflect.ValueOf(acceptCookie)))
 		Expect(server.config.KeepAlive).To(BeTrue())
 		Expect(server.config.StatelessResetKey).To(Equal([]byte("foobar")))
+		Expect(reflect.ValueOf(server.config.CongestionControl)).To(Equal(reflect.ValueOf(congestion.NewReno)))
+		Expect(server.config.Tracer).To(Equal(tracer))
+		Expect(server.config.Metrics).To(Equal(metrics))
 		// stop the listener
 		Expect(ln.Close()).To(Succeed())
 	})
@@ -249,6 +261,20 @@ var _ = Describe("Server", func() {
 			Expect(hdr.SupportedVersions).ToNot(ContainElement(protocol.VersionNumber(0x42)))
 		})
 
+		It("doesn't respond to Version Negotiation packets", func() {
+			data, err := wire.ComposeVersionNegotiation(protocol.ConnectionID{1, 2, 3, 4, 5}, protocol.ConnectionID{1, 2, 3, 4, 5, 6, 7, 8}, []protocol.VersionNumber{0x42})
+			Expect(err).ToNot(HaveOccurred())
+			// pad it, so that the packet isn't dropped for being too small
+			data = append(data, make([]byte, protocol.MinInitialPacketSize)...)
+			packet := &receivedPacket{
+				remoteAddr: &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 1337},
+				data:       data,
+				buffer:     getPacketBuffer(),
+			}
+			Expect(serv.handlePacketImpl(packet)).To(BeFalse())
+			Consistently(conn.dataWritten).ShouldNot(Receive())
+		})
+
 		It("replies with a Retry packet, if a Cookie is required", func() {
 			serv.config.AcceptCookie = func(_ net.Addr, _ *Cookie) bool { return false }
 			hdr := &wire.Header{
@@ -272,6 +298,84 @@ var _ = Describe("Server", func() {
 			Expect(replyHdr.Token).ToNot(BeEmpty())
 		})
 
+		Context("collecting metrics", func() {
+			var metrics *mocklogging.MockMetrics
+
+			BeforeEach(func() {
+				metrics = mocklogging.NewMockMetrics(mockCtrl)
+				serv.config.Metrics = metrics
+			})
+
+			It("counts too small packets", func() {
+				metrics.EXPECT().DroppedPacket(logging.PacketDropUnexpectedPacket)
+				Expect(serv.handlePacketImpl(getPacket(&wire.Header{
+					IsLongHeader:     true,
+					Type:             protocol.PacketTypeInitial,
+					DestConnectionID: protocol.ConnectionID{1, 2, 3, 4, 5, 6, 7, 8},
+					Version:          serv.config.Versions[0],
+				}, make([]byte, protocol.MinInitialPacketSize-100)))).To(BeFalse())
+			})
+
+			It("counts packets that can't be parsed", func() {
+				metrics.EXPECT().DroppedPacket(logging.PacketDropHeaderParseError)
+				data := make([]byte, protocol.MinInitialPacketSize)
+				data[0] = 0xc0 // long header, but no valid version or connection IDs follow
+				data[5] = 0xff // an invalid connection ID length
+				Expect(serv.handlePacketImpl(&receivedPacket{data: data, buffer: getPacketBuffer()})).To(BeFalse())
+			})
+
+			It("counts non-Initial packets", func() {
+				metrics.EXPECT().DroppedPacket(logging.PacketDropUnexpectedPacket)
+				Expect(serv.handlePacketImpl(getPacket(&wire.Header{
+					IsLongHeader:     true,
+					Type:             protocol.PacketTypeHandshake,
+					DestConnectionID: protocol.ConnectionID{1, 2, 3, 4, 5, 6, 7, 8},
+					Version:          serv.config.Versions[0],
+				}, make([]byte, protocol.MinInitialPacketSize)))).To(BeFalse())
+			})
+
+			It("counts Version Negotiation packets", func() {
+				metrics.EXPECT().SentVersionNegotiation()
+				packet := getPacket(&wire.Header{
+					IsLongHeader:     true,
+					Type:             protocol.PacketTypeInitial,
+					SrcConnectionID:  protocol.ConnectionID{1, 2, 3, 4, 5},
+					DestConnectionID: protocol.ConnectionID{1, 2, 3, 4, 5, 6, 7, 8},
+					Version:          0x42,
+				}, make([]byte, protocol.MinInitialPacketSize))
+				packet.remoteAddr = &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 1337}
+				Expect(serv.handlePacketImpl(packet)).To(BeFalse())
+				Expect(conn.dataWritten).To(Receive())
+			})
+
+			It("counts Retry packets", func() {
+				serv.config.AcceptCookie = func(_ net.Addr, _ *Cookie) bool { return false }
+				metrics.EXPECT().SentRetry()
+				packet := getPacket(&wire.Header{
+					IsLongHeader:     true,
+					Type:             protocol.PacketTypeInitial,
+					SrcConnectionID:  protocol.ConnectionID{5, 4, 3, 2, 1},
+					DestConnectionID: protocol.ConnectionID{1, 2, 3, 4, 5, 6, 7, 8, 9, 10},
+					Version:          protocol.VersionTLS,
+				}, make([]byte, protocol.MinInitialPacketSize))
+				packet.remoteAddr = &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 1337}
+				Expect(serv.handlePacketImpl(packet)).To(BeFalse())
+				Expect(conn.dataWritten).To(Receive())
+			})
+
+			It("counts rejected connection attempts", func() {
+				metrics.EXPECT().SentServerBusy()
+				Expect(serv.sendServerBusy(&net.UDPAddr{IP: net.IPv4(1, 2, 3, 4), Port: 42}, &wire.Header{
+					IsLongHeader:     true,
+					Type:             protocol.PacketTypeInitial,
+					SrcConnectionID:  protocol.ConnectionID{5, 4, 3, 2, 1},
+					DestConnectionID: protocol.ConnectionID{1, 2, 3, 4, 5, 6, 7, 8, 9, 10},
+					Version:          protocol.VersionTLS,
+				})).To(Succeed())
+				Expect(conn.dataWritten).To(Receive())
+			})
+		})
+
 		It("creates a session, if no Cookie is required", func() {
 			serv.config.AcceptCookie = func(_ net.Addr, _ *Cookie) bool { return true }
 			hdr := &wire.Header{
@@ -3,6 +3,7 @@ package quic
 import (
 	"bytes"
 	"context"
+	"crypto/rand"
 	"crypto/tls"
 	"errors"
 	"fmt"
@@ -20,10 +21,11 @@ import (
 	"github.com/lucas-clemente/quic-go/internal/qerr"
 	"github.com/lucas-clemente/quic-go/internal/utils"
 	"github.com/lucas-clemente/quic-go/internal/wire"
+	"github.com/lucas-clemente/quic-go/logging"
 )
 
 type unpacker interface {
-	Unpack(hdr *wire.Header, data []byte) (*unpackedPacket, error)
+	Unpack(hdr *wire.Header, rcvTime time.Time, data []byte) (*unpackedPacket, error)
 }
 
 type streamGetter interface {
@@ -43,18 +45,22 @@ type streamManager interface {
 	DeleteStream(protocol.StreamID) error
 	UpdateLimits(*handshake.TransportParameters) error
 	HandleMaxStreamsFrame(*wire.MaxStreamsFrame) error
+	NumStreams(protocol.StreamType) int
 	CloseWithError(error)
 }
 
 type cryptoStreamHandler interface {
 	RunHandshake() error
 	ChangeConnectionID(protocol.ConnectionID) error
+	SetLargestAcked(protocol.PacketNumber)
+	SetHandshakeConfirmed()
 	io.Closer
 	ConnectionState() tls.ConnectionState
 }
 
 type receivedPacket struct {
 	remoteAddr net.Addr
+	ecn        protocol.ECN
 	rcvTime    time.Time
 	data       []byte
 
@@ -64,6 +70,7 @@ type receivedPacket struct {
 func (p *receivedPacket) Clone() *receivedPacket {
 	return &receivedPacket{
 		remoteAddr: p.remoteAddr,
+		ecn:        p.ecn,
 		rcvTime:    p.rcvTime,
 		data:       p.data,
 		buffer:     p.buffer,
@@ -76,7 +83,30 @@ type closeError struct {
 	sendClose bool
 }
 
-var errCloseForRecreating = errors.New("closing session in order to recreate it")
+var (
+	errCloseForRecreating   = errors.New("closing session in order to recreate it")
+	errPathValidationFailed = errors.New("path validation failed")
+	errSessionClosed        = errors.New("session closed")
+)
+
+// A pathValidation is a path validation in progress, using PATH_CHALLENGE and PATH_RESPONSE frames.
+// The client validates a new path before migrating to a new packet conn.
+// The server validates the client's new address after the client migrated.
+type pathValidation struct {
+	data     [8]byte
+	deadline time.Time
+
+	// only used by the client
+	pconn   net.PacketConn // released from the multiplexer if the session doesn't use it (any more)
+	conn    connection
+	manager packetHandlerManager
+	result  chan error
+
+	// only used by the server
+	prevAddr      net.Addr           // the peer's address before it migrated
+	bytesReceived protocol.ByteCount // from the new address
+	bytesSent     protocol.ByteCount // to the new address
+}
 
 // A Session is a QUIC session
 type session struct {
@@ -91,7 +121,8 @@ type session struct {
 	version        protocol.VersionNumber
 	config         *Config
 
-	conn connection
+	conn      connection
+	connMutex sync.RWMutex // the conn is replaced when the client migrates
 
 	streamsMap streamManager
 
@@ -101,6 +132,8 @@ type session struct {
 	sentPacketHandler     ackhandler.SentPacketHandler
 	receivedPacketHandler ackhandler.ReceivedPacketHandler
 	framer                framer
+	datagramQueue         *datagramQueue
+	mtuDiscoverer         mtuDiscoverer // initialized when the transport parameters are received
 	windowUpdateQueue     *windowUpdateQueue
 	connFlowController    flowcontrol.ConnectionFlowController
 
@@ -128,6 +161,7 @@ type session struct {
 	clientHelloWritten    <-chan struct{}
 	handshakeCompleteChan chan struct{} // is closed when the handshake completes
 	handshakeComplete     bool
+	handshakeConfirmed    bool
 
 	receivedRetry                    bool
 	receivedFirstPacket              bool
@@ -140,14 +174,46 @@ type session struct {
 	firstAckElicitingPacketAfterIdleSentTime time.Time
 	// pacingDeadline is the time when the next packet should be sent
 	pacingDeadline time.Time
+	// During the send loop, packets are queued, and then written to the connection in a single batch.
+	batchPackets bool
+	sendQueue    []*packedPacket
+	sendQueueRaw [][]byte
 
 	peerParams *handshake.TransportParameters
 
+	// ECN is only used if it is enabled in the config, and if the congestion controller reacts to ECN-CE marks.
+	// This is set when the session is created.
+	useECN bool
+
+	// statistics, only accessed from the run loop
+	bytesSent, packetsSent         uint64
+	bytesReceived, packetsReceived uint64
+	flowControlBlockedSince        time.Time // zero if we're not blocked by connection-level flow control
+	flowControlBlockedTime         time.Duration
+	// used to pass requests for the statistics from Stats to the run loop
+	statsRequests chan chan<- ConnectionStats
+	// the statistics at the time the run loop exited
+	statsMutex sync.Mutex
+	stats      ConnectionStats
+
+	// used to pass migration requests from MigrateConn to the run loop
+	migrationQueue chan *pathValidation
+	pathValidation *pathValidation
+	// the packet conn that the client migrated to, and its packetHandlerManager
+	pathPacketConn net.PacketConn
+	pathManager    packetHandlerManager
+	// used by the server to detect when the client migrated
+	largestRcvd1RTTPacketNumber protocol.PacketNumber
+
+	connIDManager   *connIDManager
+	connIDGenerator *connIDGenerator
+
 	timer *utils.Timer
 	// keepAlivePingSent stores whether a Ping frame was sent to the peer or not
 	// it is reset as soon as we receive a packet from the peer
 	keepAlivePingSent bool
 
+	tracer logging.ConnectionTracer
 	logger utils.Logger
 }
 
@@ -177,8 +243,13 @@ var newSession = func(
 		logger:                logger,
 		version:               v,
 	}
+	if conf.Tracer != nil {
+		s.tracer = conf.Tracer.TracerForConnection(protocol.PerspectiveServer, clientDestConnID)
+	}
 	s.preSetup()
-	s.sentPacketHandler = ackhandler.NewSentPacketHandler(0, s.rttStats, s.logger)
+	congestionControl := s.config.CongestionControl(s.rttStats)
+	s.useECN = s.config.EnableECN && congestion.SupportsECN(congestionControl)
+	s.sentPacketHandler = ackhandler.NewSentPacketHandler(0, s.rttStats, congestionControl, s.useECN && s.conn.SetECN(), s.tracer, s.logger)
 	s.streamsMap = newStreamsMap(
 		s,
 		s.newFlowController,
@@ -200,6 +271,9 @@ var newSession = func(
 		params,
 		s.processTransportParameters,
 		tlsConf,
+		s.rttStats,
+		s.config.KeyUpdateInterval,
+		s.tracer,
 		logger,
 	)
 	if err != nil {
@@ -216,6 +290,7 @@ var newSession = func(
 		cs,
 		s.framer,
 		s.receivedPacketHandler,
+		s.datagramQueue,
 		s.perspective,
 		s.version,
 	)
@@ -225,6 +300,10 @@ var newSession = func(
 		return nil, err
 	}
 	s.unpacker = newPacketUnpacker(cs, s.version)
+	if s.tracer != nil {
+		s.tracer.StartedConnection(conn.LocalAddr(), conn.RemoteAddr(), s.version, srcConnID, destConnID)
+		s.tracer.SentTransportParameters(params)
+	}
 	return s, nil
 }
 
@@ -254,8 +333,13 @@ var newClientSession = func(
 		initialVersion:        initialVersion,
 		version:               v,
 	}
+	if conf.Tracer != nil {
+		s.tracer = conf.Tracer.TracerForConnection(protocol.PerspectiveClient, destConnID)
+	}
 	s.preSetup()
-	s.sentPacketHandler = ackhandler.NewSentPacketHandler(initialPacketNumber, s.rttStats, s.logger)
+	congestionControl := s.config.CongestionControl(s.rttStats)
+	s.useECN = s.config.EnableECN && congestion.SupportsECN(congestionControl)
+	s.sentPacketHandler = ackhandler.NewSentPacketHandler(initialPacketNumber, s.rttStats, congestionControl, s.useECN && s.conn.SetECN(), s.tracer, s.logger)
 	initialStream := newCryptoStream()
 	handshakeStream := newCryptoStream()
 	oneRTTStream := newPostHandshakeCryptoStream(s.framer)
@@ -268,6 +352,9 @@ var newClientSession = func(
 		params,
 		s.processTransportParameters,
 		tlsConf,
+		s.rttStats,
+		s.config.KeyUpdateInterval,
+		s.tracer,
 		logger,
 	)
 	if err != nil {
@@ -296,16 +383,25 @@ var newClientSession = func(
 		cs,
 		s.framer,
 		s.receivedPacketHandler,
+		s.datagramQueue,
 		s.perspective,
 		s.version,
 	)
-	return s, s.postSetup()
+	if err := s.postSetup(); err != nil {
+		return nil, err
+	}
+	if s.tracer != nil {
+		s.tracer.StartedConnection(conn.LocalAddr(), conn.RemoteAddr(), s.version, srcConnID, destConnID)
+		s.tracer.SentTransportParameters(params)
+	}
+	return s, nil
 }
 
 func (s *session) preSetup() {
 	s.frameParser = wire.NewFrameParser(s.version)
 	s.rttStats = &congestion.RTTStats{}
 	s.receivedPacketHandler = ackhandler.NewReceivedPacketHandler(s.rttStats, s.logger, s.version)
+	s.datagramQueue = newDatagramQueue(s.scheduleSending, s.version)
 	s.connFlowController = flowcontrol.NewConnectionFlowController(
 		protocol.InitialMaxData,
 		protocol.ByteCount(s.config.MaxReceiveConnectionFlowControlWindow),
@@ -319,6 +415,8 @@ func (s *session) postSetup() error {
 	s.receivedPackets = make(chan *receivedPacket, protocol.MaxSessionUnprocessedPackets)
 	s.closeChan = make(chan closeError, 1)
 	s.sendingScheduled = make(chan struct{}, 1)
+	s.migrationQueue = make(chan *pathValidation)
+	s.statsRequests = make(chan chan<- ConnectionStats)
 	s.undecryptablePackets = make([]*receivedPacket, 0, protocol.MaxUndecryptablePackets)
 	s.ctx, s.ctxCancel = context.WithCancel(context.Background())
 
@@ -328,6 +426,19 @@ func (s *session) postSetup() error {
 	s.sessionCreationTime = now
 
 	s.windowUpdateQueue = newWindowUpdateQueue(s.streamsMap, s.connFlowController, s.framer.QueueControlFrame)
+	s.connIDManager = newConnIDManager(
+		func(token [16]byte) { s.sessionRunner.AddResetToken(token, s) },
+		s.sessionRunner.RemoveResetToken,
+		s.changeDestConnectionID,
+		s.queueControlFrame,
+	)
+	s.connIDGenerator = newConnIDGenerator(
+		s.srcConnID,
+		s.addConnectionID,
+		s.retireConnectionID,
+		s.removeConnectionID,
+		s.queueControlFrame,
+	)
 	return nil
 }
 
@@ -335,6 +446,9 @@ func (s *session) postSetup() error {
 func (s *session) run() error {
 	defer s.ctxCancel()
 
+	if s.config.Metrics != nil {
+		s.config.Metrics.StartedHandshake(s.perspective)
+	}
 	go func() {
 		if err := s.cryptoStreamHandler.RunHandshake(); err != nil {
 			s.closeLocal(err)
@@ -386,6 +500,11 @@ runLoop:
 			}
 		case <-s.handshakeCompleteChan:
 			s.handleHandshakeComplete()
+		case v := <-s.migrationQueue:
+			s.startMigration(v)
+		case c := <-s.statsRequests:
+			c <- s.getStats()
+			continue
 		}
 
 		now := time.Now()
@@ -396,6 +515,9 @@ runLoop:
 				s.closeLocal(err)
 			}
 		}
+		if s.pathValidation != nil && !now.Before(s.pathValidation.deadline) {
+			s.failPathValidation()
+		}
 
 		var pacingDeadline time.Time
 		if s.pacingDeadline.IsZero() { // the timer didn't have a pacing deadline set
@@ -429,9 +551,36 @@ runLoop:
 	}
 
 	s.handleCloseError(closeErr)
+	if closeErr.sendClose {
+		s.connIDGenerator.RetireAll()
+	} else {
+		s.connIDGenerator.RemoveAll()
+	}
+	s.connIDManager.Close()
+	if v := s.pathValidation; v != nil && v.manager != nil {
+		s.abandonPath(v)
+	}
+	if s.pathManager != nil {
+		s.pathManager.Retire(s.srcConnID)
+		getMultiplexer().ReleaseConn(s.pathPacketConn)
+	}
+	s.statsMutex.Lock()
+	s.stats = s.getStats()
+	s.statsMutex.Unlock()
 	s.closed.Set(true)
+	if s.config.Metrics != nil && !s.handshakeComplete && closeErr.err != errCloseForRecreating {
+		s.config.Metrics.FailedHandshake(s.perspective)
+	}
 	s.logger.Infof("Connection %s closed.", s.srcConnID)
 	s.cryptoStreamHandler.Close()
+	if s.tracer != nil {
+		err := closeErr.err
+		if err == nil {
+			err = qerr.NoError
+		}
+		s.tracer.ClosedConnection(err, closeErr.remote)
+		s.tracer.Close()
+	}
 	return closeErr.err
 }
 
@@ -443,6 +592,67 @@ func (s *session) ConnectionState() tls.ConnectionState {
 	return s.cryptoStreamHandler.ConnectionState()
 }
 
+func (s *session) Stats() ConnectionStats {
+	var stats ConnectionStats
+	c := make(chan ConnectionStats, 1)
+	select {
+	case s.statsRequests <- c:
+		stats = <-c
+	case <-s.ctx.Done():
+		// The run loop already exited. Use the statistics at the time the connection was closed.
+		s.statsMutex.Lock()
+		stats = s.stats
+		s.statsMutex.Unlock()
+	}
+	// the streams map is safe for concurrent use
+	stats.OpenStreams = s.streamsMap.NumStreams(protocol.StreamTypeBidi)
+	stats.OpenUniStreams = s.streamsMap.NumStreams(protocol.StreamTypeUni)
+	return stats
+}
+
+func (s *session) SendMessage(msg []byte) error {
+	return s.SendMessageWithCallback(msg, nil)
+}
+
+func (s *session) SendMessageWithCallback(msg []byte, ackCallback func(acked bool)) error {
+	if !s.config.EnableDatagrams {
+		return errors.New("DATAGRAM support disabled")
+	}
+	return s.datagramQueue.AddAndWait(msg, ackCallback)
+}
+
+func (s *session) ReceiveMessage() ([]byte, error) {
+	if !s.config.EnableDatagrams {
+		return nil, errors.New("DATAGRAM support disabled")
+	}
+	return s.datagramQueue.Receive()
+}
+
+// getStats calculates the current statistics.
+// It must only be called from the run loop.
+func (s *session) getStats() ConnectionStats {
+	ackStats := s.sentPacketHandler.GetStats()
+	blockedTime := s.flowControlBlockedTime
+	if !s.flowControlBlockedSince.IsZero() {
+		blockedTime += time.Since(s.flowControlBlockedSince)
+	}
+	return ConnectionStats{
+		SmoothedRTT:            s.rttStats.SmoothedRTT(),
+		MinRTT:                 s.rttStats.MinRTT(),
+		LatestRTT:              s.rttStats.LatestRTT(),
+		CongestionWindow:       uint64(ackStats.CongestionWindow),
+		BytesInFlight:          uint64(ackStats.BytesInFlight),
+		BytesSent:              s.bytesSent,
+		PacketsSent:            s.packetsSent,
+		BytesReceived:          s.bytesReceived,
+		PacketsReceived:        s.packetsReceived,
+		PacketsLost:            ackStats.PacketsLost,
+		PacketsRetransmitted:   ackStats.PacketsRetransmitted,
+		PTOCount:               ackStats.PTOCount,
+		FlowControlBlockedTime: blockedTime,
+	}
+}
+
 func (s *session) maybeResetTimer() {
 	var deadline time.Time
 	if s.config.KeepAlive && s.handshakeComplete && !s.keepAlivePingSent {
@@ -464,6 +674,9 @@ func (s *session) maybeResetTimer() {
 	if !s.pacingDeadline.IsZero() {
 		deadline = utils.MinTime(deadline, s.pacingDeadline)
 	}
+	if s.pathValidation != nil {
+		deadline = utils.MinTime(deadline, s.pathValidation.deadline)
+	}
 
 	s.timer.Reset(deadline)
 }
@@ -475,7 +688,13 @@ func (s *session) idleTimeoutStartTime() time.Time {
 func (s *session) handleHandshakeComplete() {
 	s.handshakeComplete = true
 	s.handshakeCompleteChan = nil // prevent this case from ever being selected again
+	if s.config.Metrics != nil {
+		s.config.Metrics.CompletedHandshake(s.perspective, time.Since(s.sessionCreationTime))
+	}
 	s.sessionRunner.OnHandshakeComplete(s)
+	if err := s.connIDGenerator.IssueConnectionIDs(); err != nil {
+		s.closeLocal(err)
+	}
 
 	// The client completes the handshake first (after sending the CFIN).
 	// We need to make sure they learn about the peer completing the handshake,
@@ -486,6 +705,9 @@ func (s *session) handleHandshakeComplete() {
 	if s.perspective == protocol.PerspectiveServer {
 		s.queueControlFrame(&wire.PingFrame{})
 		s.sentPacketHandler.SetHandshakeComplete()
+		// For the server, the handshake is confirmed as soon as it completes.
+		s.handshakeConfirmed = true
+		s.cryptoStreamHandler.SetHandshakeConfirmed()
 	}
 }
 
@@ -503,11 +725,17 @@ func (s *session) handlePacketImpl(rp *receivedPacket) bool {
 
 		hdr, packetData, rest, err := wire.ParsePacket(p.data, s.srcConnID.Len())
 		if err != nil {
+			if s.tracer != nil {
+				s.tracer.DroppedPacket(logging.PacketTypeNotDetermined, protocol.ByteCount(len(data)), logging.PacketDropHeaderParseError)
+			}
 			s.logger.Debugf("error parsing packet: %s", err)
 			break
 		}
 
 		if counter > 0 && !hdr.DestConnectionID.Equal(lastConnID) {
+			if s.tracer != nil {
+				s.tracer.DroppedPacket(logging.PacketTypeFromHeader(hdr), protocol.ByteCount(len(packetData)), logging.PacketDropUnknownConnectionID)
+			}
 			s.logger.Debugf("coalesced packet has different destination connection ID: %s, expected %s", hdr.DestConnectionID, lastConnID)
 			break
 		}
@@ -549,25 +777,39 @@ func (s *session) handleSinglePacket(p *receivedPacket, hdr *wire.Header) bool /
 	// The server can change the source connection ID with the first Handshake packet.
 	// After this, all packets with a different source connection have to be ignored.
 	if s.receivedFirstPacket && hdr.IsLongHeader && !hdr.SrcConnectionID.Equal(s.destConnID) {
+		if s.tracer != nil {
+			s.tracer.DroppedPacket(logging.PacketTypeFromHeader(hdr), protocol.ByteCount(len(p.data)), logging.PacketDropUnexpectedSourceConnectionID)
+		}
 		s.logger.Debugf("Dropping packet with unexpected source connection ID: %s (expected %s)", hdr.SrcConnectionID, s.destConnID)
 		return false
 	}
 	// drop 0-RTT packets
 	if hdr.Type == protocol.PacketType0RTT {
+		if s.tracer != nil {
+			s.tracer.DroppedPacket(logging.PacketType0RTT, protocol.ByteCount(len(p.data)), logging.PacketDropUnexpectedPacket)
+		}
 		return false
 	}
 
-	packet, err := s.unpacker.Unpack(hdr, p.data)
+	packet, err := s.unpacker.Unpack(hdr, p.rcvTime, p.data)
 	if err != nil {
 		if err == handshake.ErrOpenerNotYetAvailable {
 			// Sealer for this encryption level not yet available.
 			// Try again later.
 			wasQueued = true
-			s.tryQueueingUndecryptablePacket(p)
+			s.tryQueueingUndecryptablePacket(p, hdr)
+			return false
+		}
+		// The AEAD limits were reached, or the peer violated the rules for key updates.
+		if quicErr, ok := err.(*qerr.QuicError); ok {
+			s.closeLocal(quicErr)
 			return false
 		}
 		// This might be a packet injected by an attacker.
 		// Drop it.
+		if s.tracer != nil {
+			s.tracer.DroppedPacket(logging.PacketTypeFromHeader(hdr), protocol.ByteCount(len(p.data)), logging.PacketDropPayloadDecryptError)
+		}
 		s.logger.Debugf("Dropping packet that could not be unpacked. Unpack error: %s", err)
 		return false
 	}
@@ -577,7 +819,7 @@ func (s *session) handleSinglePacket(p *receivedPacket, hdr *wire.Header) bool /
 		packet.hdr.Log(s.logger)
 	}
 
-	if err := s.handleUnpackedPacket(packet, p.rcvTime); err != nil {
+	if err := s.handleUnpackedPacket(packet, p.ecn, p.rcvTime, protocol.ByteCount(len(p.data)), p.remoteAddr); err != nil {
 		s.closeLocal(err)
 		return false
 	}
@@ -586,28 +828,36 @@ func (s *session) handleSinglePacket(p *receivedPacket, hdr *wire.Header) bool /
 
 func (s *session) handleRetryPacket(p *receivedPacket, hdr *wire.Header) bool /* was this a valid Retry */ {
 	if s.perspective == protocol.PerspectiveServer {
+		s.dropRetryPacket(p)
 		s.logger.Debugf("Ignoring Retry.")
 		return false
 	}
 	if s.receivedFirstPacket {
+		s.dropRetryPacket(p)
 		s.logger.Debugf("Ignoring Retry, since we already received a packet.")
 		return false
 	}
 	(&wire.ExtendedHeader{Header: *hdr}).Log(s.logger)
 	if !hdr.OrigDestConnectionID.Equal(s.destConnID) {
+		s.dropRetryPacket(p)
 		s.logger.Debugf("Ignoring spoofed Retry. Original Destination Connection ID: %s, expected: %s", hdr.OrigDestConnectionID, s.destConnID)
 		return false
 	}
 	if hdr.SrcConnectionID.Equal(s.destConnID) {
+		s.dropRetryPacket(p)
 		s.logger.Debugf("Ignoring Retry, since the server didn't change the Source Connection ID.")
 		return false
 	}
 	// If a token is already set, this means that we already received a Retry from the server.
 	// Ignore this Retry packet.
 	if s.receivedRetry {
+		s.dropRetryPacket(p)
 		s.logger.Debugf("Ignoring Retry, since a Retry was already received.")
 		return false
 	}
+	if s.tracer != nil {
+		s.tracer.ReceivedRetry(hdr)
+	}
 	s.logger.Debugf("<- Received Retry")
 	s.logger.Debugf("Switching destination connection ID to: %s", hdr.SrcConnectionID)
 	s.origDestConnID = s.destConnID
@@ -624,7 +874,13 @@ func (s *session) handleRetryPacket(p *receivedPacket, hdr *wire.Header) bool /*
 	return true
 }
 
-func (s *session) handleUnpackedPacket(packet *unpackedPacket, rcvTime time.Time) error {
+func (s *session) dropRetryPacket(p *receivedPacket) {
+	if s.tracer != nil {
+		s.tracer.DroppedPacket(logging.PacketTypeRetry, protocol.ByteCount(len(p.data)), logging.PacketDropUnexpectedPacket)
+	}
+}
+
+func (s *session) handleUnpackedPacket(packet *unpackedPacket, ecn protocol.ECN, rcvTime time.Time, packetSize protocol.ByteCount, remoteAddr net.Addr) error {
 	if len(packet.data) == 0 {
 		return qerr.Error(qerr.ProtocolViolation, "empty packet")
 	}
@@ -638,6 +894,8 @@ func (s *session) handleUnpackedPacket(packet *unpackedPacket, rcvTime time.Time
 
 	s.receivedFirstPacket = true
 	s.lastPacketReceivedTime = rcvTime
+	s.packetsReceived++
+	s.bytesReceived += uint64(packetSize)
 	s.firstAckElicitingPacketAfterIdleSentTime = time.Time{}
 	s.keepAlivePingSent = false
 
@@ -652,6 +910,8 @@ func (s *session) handleUnpackedPacket(packet *unpackedPacket, rcvTime time.Time
 
 	r := bytes.NewReader(packet.data)
 	var isAckEliciting bool
+	isProbing := true // does the packet only contain probing frames
+	var frames []wire.Frame
 	for {
 		frame, err := s.frameParser.ParseNext(r, packet.encryptionLevel)
 		if err != nil {
@@ -663,18 +923,39 @@ func (s *session) handleUnpackedPacket(packet *unpackedPacket, rcvTime time.Time
 		if ackhandler.IsFrameAckEliciting(frame) {
 			isAckEliciting = true
 		}
-		if err := s.handleFrame(frame, packet.packetNumber, packet.encryptionLevel); err != nil {
+		if !isProbingFrame(frame) {
+			isProbing = false
+		}
+		if s.tracer != nil {
+			frames = append(frames, frame)
+		}
+		if err := s.handleFrame(frame, packet.packetNumber, packet.encryptionLevel, remoteAddr); err != nil {
 			return err
 		}
 	}
+	if s.tracer != nil {
+		s.tracer.ReceivedPacket(packet.hdr, packetSize, frames)
+	}
 
-	if err := s.receivedPacketHandler.ReceivedPacket(packet.packetNumber, packet.encryptionLevel, rcvTime, isAckEliciting); err != nil {
+	if err := s.receivedPacketHandler.ReceivedPacket(packet.packetNumber, ecn, packet.encryptionLevel, rcvTime, isAckEliciting); err != nil {
 		return err
 	}
+
+	if packet.encryptionLevel == protocol.Encryption1RTT && packet.packetNumber > s.largestRcvd1RTTPacketNumber {
+		s.largestRcvd1RTTPacketNumber = packet.packetNumber
+		// The client migrated if it sends non-probing packets from a new address.
+		// Reordered packets don't cause a migration.
+		if s.perspective == protocol.PerspectiveServer && s.handshakeComplete && !isProbing && !s.isPeerAddr(remoteAddr) {
+			s.migrateToPeerAddr(remoteAddr)
+		}
+	}
+	if v := s.pathValidation; v != nil && v.conn == nil && s.isPeerAddr(remoteAddr) {
+		v.bytesReceived += packetSize
+	}
 	return nil
 }
 
-func (s *session) handleFrame(f wire.Frame, pn protocol.PacketNumber, encLevel protocol.EncryptionLevel) error {
+func (s *session) handleFrame(f wire.Frame, pn protocol.PacketNumber, encLevel protocol.EncryptionLevel, remoteAddr net.Addr) error {
 	var err error
 	wire.LogFrame(s.logger, f, false)
 	switch frame := f.(type) {
@@ -701,15 +982,16 @@ func (s *session) handleFrame(f wire.Frame, pn protocol.PacketNumber, encLevel p
 		err = s.handleStopSendingFrame(frame)
 	case *wire.PingFrame:
 	case *wire.PathChallengeFrame:
-		s.handlePathChallengeFrame(frame)
+		err = s.handlePathChallengeFrame(frame, remoteAddr)
 	case *wire.PathResponseFrame:
-		// since we don't send PATH_CHALLENGEs, we don't expect PATH_RESPONSEs
-		err = errors.New("unexpected PATH_RESPONSE frame")
+		s.handlePathResponseFrame(frame)
 	case *wire.NewTokenFrame:
 	case *wire.NewConnectionIDFrame:
+		err = s.handleNewConnectionIDFrame(frame)
 	case *wire.RetireConnectionIDFrame:
-		// since we don't send new connection IDs, we don't expect retirements
-		err = errors.New("unexpected RETIRE_CONNECTION_ID frame")
+		err = s.connIDGenerator.Retire(frame.SequenceNumber)
+	case *wire.DatagramFrame:
+		err = s.handleDatagramFrame(frame, encLevel)
 	default:
 		err = fmt.Errorf("unexpected frame type: %s", reflect.ValueOf(&frame).Elem().Type().Name())
 	}
@@ -720,12 +1002,16 @@ func (s *session) handleFrame(f wire.Frame, pn protocol.PacketNumber, encLevel p
 func (s *session) handlePacket(p *receivedPacket) {
 	if s.closed.Get() {
 		s.handlePacketAfterClosed(p)
+		return
 	}
 	// Discard packets once the amount of queued packets is larger than
 	// the channel size, protocol.MaxSessionUnprocessedPackets
 	select {
 	case s.receivedPackets <- p:
 	default:
+		if s.config.Metrics != nil {
+			s.config.Metrics.DroppedPacket(logging.PacketDropDOSPrevention)
+		}
 	}
 }
 
@@ -776,8 +1062,26 @@ func (s *session) handleStreamFrame(frame *wire.StreamFrame, encLevel protocol.E
 	return str.handleStreamFrame(frame)
 }
 
+func (s *session) handleDatagramFrame(frame *wire.DatagramFrame, encLevel protocol.EncryptionLevel) error {
+	if !s.config.EnableDatagrams {
+		return qerr.Error(qerr.ProtocolViolation, "received a DATAGRAM frame, but DATAGRAM support is disabled")
+	}
+	if encLevel < protocol.Encryption1RTT {
+		return qerr.Error(qerr.ProtocolViolation, "received an unencrypted DATAGRAM frame")
+	}
+	if frame.Length(s.version) > protocol.MaxDatagramFrameSize {
+		return qerr.Error(qerr.ProtocolViolation, "DATAGRAM frame too large")
+	}
+	s.datagramQueue.HandleDatagramFrame(frame)
+	return nil
+}
+
 func (s *session) handleMaxDataFrame(frame *wire.MaxDataFrame) {
 	s.connFlowController.UpdateSendWindow(frame.ByteOffset)
+	if !s.flowControlBlockedSince.IsZero() && s.connFlowController.SendWindowSize() > 0 {
+		s.flowControlBlockedTime += time.Since(s.flowControlBlockedSince)
+		s.flowControlBlockedSince = time.Time{}
+	}
 }
 
 func (s *session) handleMaxStreamDataFrame(frame *wire.MaxStreamDataFrame) error {
@@ -822,20 +1126,307 @@ func (s *session) handleStopSendingFrame(frame *wire.StopSendingFrame) error {
 	return nil
 }
 
-func (s *session) handlePathChallengeFrame(frame *wire.PathChallengeFrame) {
-	s.queueControlFrame(&wire.PathResponseFrame{Data: frame.Data})
+func (s *session) handlePathChallengeFrame(frame *wire.PathChallengeFrame, remoteAddr net.Addr) error {
+	f := &wire.PathResponseFrame{Data: frame.Data}
+	if s.isPeerAddr(remoteAddr) {
+		s.queueControlFrame(f)
+		return nil
+	}
+	// The peer is probing a new path.
+	// The PATH_RESPONSE has to be sent on that path.
+	packet, err := s.packer.PackPathValidationPacket(f)
+	if err != nil {
+		return err
+	}
+	defer packet.buffer.Release()
+	s.sentPacketHandler.SentPacket(packet.ToAckHandlerPacket())
+	s.logPacket(packet)
+	if err := s.conn.WriteTo(packet.raw, remoteAddr); err != nil {
+		s.logger.Debugf("Error sending PATH_RESPONSE to %s: %s", remoteAddr, err)
+	}
+	return nil
+}
+
+func (s *session) handlePathResponseFrame(frame *wire.PathResponseFrame) {
+	v := s.pathValidation
+	if v == nil || frame.Data != v.data {
+		// This might be the response to a PATH_CHALLENGE of a path validation that already failed.
+		s.logger.Debugf("Ignoring unexpected PATH_RESPONSE frame")
+		return
+	}
+	s.pathValidation = nil
+	if v.conn == nil {
+		s.logger.Infof("Validated new peer address %s.", s.conn.RemoteAddr())
+		return
+	}
+	s.completeMigration(v)
+}
+
+func (s *session) handleNewConnectionIDFrame(frame *wire.NewConnectionIDFrame) error {
+	if s.destConnID.Len() == 0 {
+		return qerr.Error(qerr.ProtocolViolation, "received NEW_CONNECTION_ID frame, but the peer uses a zero-length connection ID")
+	}
+	return s.connIDManager.Add(frame)
 }
 
 func (s *session) handleAckFrame(frame *wire.AckFrame, pn protocol.PacketNumber, encLevel protocol.EncryptionLevel) error {
 	if err := s.sentPacketHandler.ReceivedAck(frame, pn, encLevel, s.lastPacketReceivedTime); err != nil {
 		return err
 	}
+	s.updateECNMarking()
 	if encLevel == protocol.Encryption1RTT {
 		s.receivedPacketHandler.IgnoreBelow(s.sentPacketHandler.GetLowestPacketNotConfirmedAcked())
+		s.cryptoStreamHandler.SetLargestAcked(frame.LargestAcked())
+		// The client considers the handshake confirmed when the first 1-RTT packet is acknowledged.
+		if s.perspective == protocol.PerspectiveClient && !s.handshakeConfirmed {
+			s.handshakeConfirmed = true
+			s.cryptoStreamHandler.SetHandshakeConfirmed()
+		}
 	}
 	return nil
 }
 
+// updateECNMarking disables the ECT(0) marking of outgoing packets if ECN validation failed.
+// It re-enables the marking when validation is restarted after a connection migration.
+func (s *session) updateECNMarking() {
+	s.conn.SetECNMarking(s.sentPacketHandler.ECNMode() == protocol.ECT0)
+}
+
+// isProbingFrame says if a frame is a probing frame.
+// Receiving a packet that only contains probing frames from a new address doesn't cause a migration.
+func isProbingFrame(f wire.Frame) bool {
+	switch f.(type) {
+	case *wire.PathChallengeFrame, *wire.PathResponseFrame, *wire.NewConnectionIDFrame:
+		return true
+	default:
+		return false
+	}
+}
+
+// isPeerAddr says if addr is the current address of the peer.
+func (s *session) isPeerAddr(addr net.Addr) bool {
+	peerAddr := s.conn.RemoteAddr()
+	if a, ok := addr.(*net.UDPAddr); ok {
+		if b, ok := peerAddr.(*net.UDPAddr); ok {
+			return a.IP.Equal(b.IP) && a.Port == b.Port && a.Zone == b.Zone
+		}
+	}
+	return addr.String() == peerAddr.String()
+}
+
+// MigrateConn migrates the session to a new packet conn.
+func (s *session) MigrateConn(pconn net.PacketConn) error {
+	if s.perspective == protocol.PerspectiveServer {
+		return errors.New("only clients can migrate")
+	}
+	manager, err := getMultiplexer().AddConn(pconn, s.srcConnID.Len(), s.config.StatelessResetKey, s.config.Metrics, s.config.EnableBatchedIO, !s.config.DisablePathMTUDiscovery, s.config.EnableECN)
+	if err != nil {
+		return err
+	}
+	c := &conn{pconn: pconn, currentAddr: s.RemoteAddr(), batchWriter: getBatchWriter(pconn, s.config.EnableBatchedIO)}
+	if s.useECN {
+		c.SetECN()
+	}
+	v := &pathValidation{
+		pconn:   pconn,
+		conn:    c,
+		manager: manager,
+		result:  make(chan error, 1),
+	}
+	select {
+	case s.migrationQueue <- v:
+	case <-s.ctx.Done():
+		getMultiplexer().ReleaseConn(pconn)
+		return errSessionClosed
+	}
+	// From here on, the run loop releases the packet conn if the session doesn't migrate to it.
+	select {
+	case err := <-v.result:
+		return err
+	case <-s.ctx.Done():
+		return errSessionClosed
+	}
+}
+
+// startMigration is called by the client to start validating a new path
+func (s *session) startMigration(v *pathValidation) {
+	var err error
+	switch {
+	case !s.handshakeComplete:
+		err = errors.New("cannot migrate before the handshake completed")
+	case s.peerParams.DisableMigration:
+		err = errors.New("the server disabled connection migration")
+	case s.pathValidation != nil:
+		err = errors.New("path validation already in progress")
+	default:
+		for _, connID := range s.connIDGenerator.ConnectionIDs() {
+			v.manager.Add(connID, s)
+		}
+		if err = s.sendPathChallenge(v); err != nil {
+			s.removeConnectionIDs(v.manager)
+		}
+	}
+	if err != nil {
+		getMultiplexer().ReleaseConn(v.pconn)
+		v.result <- err
+		return
+	}
+	s.pathValidation = v
+}
+
+func (s *session) sendPathChallenge(v *pathValidation) error {
+	if _, err := rand.Read(v.data[:]); err != nil {
+		return err
+	}
+	packet, err := s.packer.PackPathValidationPacket(&wire.PathChallengeFrame{Data: v.data})
+	if err != nil {
+		return err
+	}
+	defer packet.buffer.Release()
+	s.sentPacketHandler.SentPacket(packet.ToAckHandlerPacket())
+	s.logger.Debugf("Probing new path %s -> %s", v.conn.LocalAddr(), v.conn.RemoteAddr())
+	s.logPacket(packet)
+	v.deadline = time.Now().Add(s.pathValidationTimeout())
+	return v.conn.Write(packet.raw)
+}
+
+// completeMigration is called by the client when path validation succeeded
+func (s *session) completeMigration(v *pathValidation) {
+	s.logger.Infof("Migrating to new path %s -> %s.", v.conn.LocalAddr(), v.conn.RemoteAddr())
+	s.connMutex.Lock()
+	s.conn = v.conn
+	s.connMutex.Unlock()
+	if s.pathManager != nil {
+		for _, connID := range s.connIDGenerator.ConnectionIDs() {
+			s.pathManager.Retire(connID)
+		}
+		getMultiplexer().ReleaseConn(s.pathPacketConn)
+	}
+	s.pathPacketConn = v.pconn
+	s.pathManager = v.manager
+	// connection IDs might have been issued while the path was validated
+	for _, connID := range s.connIDGenerator.ConnectionIDs() {
+		s.pathManager.Add(connID, s)
+	}
+	s.sentPacketHandler.OnConnectionMigration()
+	s.updateECNMarking()
+	if s.mtuDiscoverer != nil {
+		s.mtuDiscoverer.Reset(time.Now())
+		// Packets larger than the initial packet size can only be sent if they're not fragmented.
+		if !s.conn.SetDF() {
+			s.logger.Debugf("Setting the DF bit on the new path failed. Disabling Path MTU Discovery.")
+			s.mtuDiscoverer = nil
+		}
+	}
+	// Use a new connection ID, such that observers can't link the new path to the old one.
+	if !s.connIDManager.Rotate() {
+		s.logger.Debugf("No unused connection ID available. Continuing to use %s.", s.destConnID)
+	}
+	v.result <- nil
+}
+
+func (s *session) changeDestConnectionID(connID protocol.ConnectionID) {
+	s.logger.Debugf("Switching destination connection ID to: %s", connID)
+	s.destConnID = connID
+	s.packer.ChangeDestConnectionID(connID)
+}
+
+// addConnectionID is called when a new connection ID is issued to the peer.
+// It returns the stateless reset token for this connection ID.
+func (s *session) addConnectionID(connID protocol.ConnectionID) [16]byte {
+	s.sessionRunner.Add(connID, s)
+	if s.pathManager != nil {
+		s.pathManager.Add(connID, s)
+	}
+	return s.sessionRunner.GetStatelessResetToken(connID)
+}
+
+func (s *session) retireConnectionID(connID protocol.ConnectionID) {
+	s.sessionRunner.Retire(connID)
+	if s.pathManager != nil {
+		s.pathManager.Retire(connID)
+	}
+}
+
+func (s *session) removeConnectionID(connID protocol.ConnectionID) {
+	s.sessionRunner.Remove(connID)
+	if s.pathManager != nil {
+		s.pathManager.Remove(connID)
+	}
+}
+
+// removeConnectionIDs removes all connection IDs from the packetHandlerManager of a path that failed validation.
+func (s *session) removeConnectionIDs(manager packetHandlerManager) {
+	for _, connID := range s.connIDGenerator.ConnectionIDs() {
+		manager.Remove(connID)
+	}
+}
+
+// abandonPath is called by the client when it doesn't migrate to a path that is being validated.
+func (s *session) abandonPath(v *pathValidation) {
+	s.removeConnectionIDs(v.manager)
+	getMultiplexer().ReleaseConn(v.pconn)
+}
+
+// migrateToPeerAddr is called by the server when the client migrated to a new address.
+// The new address is used right away, and validated using a PATH_CHALLENGE.
+// Until validation succeeds, the amount of data sent to it is limited (see isAmplificationLimited).
+// If validation fails, the server reverts to the previous address.
+func (s *session) migrateToPeerAddr(addr net.Addr) {
+	prevAddr := s.conn.RemoteAddr()
+	if s.pathValidation != nil {
+		// The client migrated again, before the previous address was validated.
+		prevAddr = s.pathValidation.prevAddr
+	}
+	s.logger.Infof("Peer migrated from %s to %s. Validating the new address.", s.conn.RemoteAddr(), addr)
+	s.conn.SetCurrentRemoteAddr(addr)
+	s.sentPacketHandler.OnConnectionMigration()
+	s.updateECNMarking()
+	if s.mtuDiscoverer != nil {
+		s.mtuDiscoverer.Reset(time.Now())
+	}
+	v := &pathValidation{
+		prevAddr: prevAddr,
+		deadline: time.Now().Add(s.pathValidationTimeout()),
+	}
+	rand.Read(v.data[:])
+	s.pathValidation = v
+	s.queueControlFrame(&wire.PathChallengeFrame{Data: v.data})
+}
+
+func (s *session) failPathValidation() {
+	v := s.pathValidation
+	s.pathValidation = nil
+	if v.conn != nil {
+		s.logger.Infof("Validation of path %s -> %s failed.", v.conn.LocalAddr(), v.conn.RemoteAddr())
+		s.abandonPath(v)
+		v.result <- errPathValidationFailed
+		return
+	}
+	s.logger.Infof("Validation of peer address %s failed. Reverting to %s.", s.conn.RemoteAddr(), v.prevAddr)
+	s.conn.SetCurrentRemoteAddr(v.prevAddr)
+	// The state of the new path doesn't apply to the previous one.
+	s.sentPacketHandler.OnConnectionMigration()
+	s.updateECNMarking()
+	if s.mtuDiscoverer != nil {
+		s.mtuDiscoverer.Reset(time.Now())
+	}
+}
+
+// isAmplificationLimited says if the server has to stop sending to a peer address that hasn't been validated yet.
+// Until the PATH_RESPONSE is received, it sends at most protocol.AmplificationFactor times the bytes it received from that address.
+func (s *session) isAmplificationLimited() bool {
+	v := s.pathValidation
+	return v != nil && v.conn == nil && v.bytesSent >= protocol.AmplificationFactor*v.bytesReceived
+}
+
+// pathValidationTimeout is the time we wait for a PATH_RESPONSE.
+// Since the RTT of the new path is not known yet, it is at least protocol.MinPathValidationTimeout.
+func (s *session) pathValidationTimeout() time.Duration {
+	pto := s.rttStats.SmoothedOrInitialRTT() + 4*s.rttStats.MeanDeviation()
+	return utils.MaxDuration(3*pto, protocol.MinPathValidationTimeout)
+}
+
 // closeLocal closes the session and send a CONNECTION_CLOSE containing the error
 func (s *session) closeLocal(e error) {
 	s.closeOnce.Do(func() {
@@ -904,6 +1495,7 @@ func (s *session) handleCloseError(closeErr closeError) {
 	}
 
 	s.streamsMap.CloseWithError(quicErr)
+	s.datagramQueue.CloseWithError(quicErr)
 
 	if !closeErr.sendClose {
 		return
@@ -930,6 +1522,9 @@ func (s *session) processTransportParameters(data []byte) {
 		s.closeLocal(err)
 		return
 	}
+	if s.tracer != nil {
+		s.tracer.ReceivedTransportParameters(params)
+	}
 	s.logger.Debugf("Received Transport Parameters: %s", params)
 	s.peerParams = params
 	if err := s.streamsMap.UpdateLimits(params); err != nil {
@@ -937,10 +1532,24 @@ func (s *session) processTransportParameters(data []byte) {
 		return
 	}
 	s.packer.HandleTransportParameters(params)
+	if !s.config.DisablePathMTUDiscovery && s.conn.SetDF() {
+		maxPacketSize := protocol.MaxPacketSizePMTUD
+		if params.MaxPacketSize != 0 {
+			maxPacketSize = utils.MinByteCount(maxPacketSize, params.MaxPacketSize)
+		}
+		s.mtuDiscoverer = newMTUDiscoverer(
+			s.rttStats,
+			utils.MinByteCount(getMaxPacketSize(s.conn.RemoteAddr()), maxPacketSize),
+			maxPacketSize,
+			s.packer.SetMaxPacketSize,
+			time.Now(),
+		)
+	}
 	s.frameParser.SetAckDelayExponent(params.AckDelayExponent)
 	s.connFlowController.UpdateSendWindow(params.InitialMaxData)
+	s.datagramQueue.SetPeerMaxFrameSize(params.MaxDatagramFrameSize)
 	if params.StatelessResetToken != nil {
-		s.sessionRunner.AddResetToken(*params.StatelessResetToken, s)
+		s.connIDManager.SetStatelessResetToken(*params.StatelessResetToken)
 	}
 }
 
@@ -966,7 +1575,7 @@ func (s *session) processTransportParametersForServer(data []byte) (*handshake.T
 	return params, nil
 }
 
-func (s *session) sendPackets() error {
+func (s *session) sendPackets() (err error) {
 	s.pacingDeadline = time.Time{}
 
 	sendMode := s.sentPacketHandler.SendMode()
@@ -974,10 +1583,22 @@ func (s *session) sendPackets() error {
 		return nil
 	}
 
+	s.batchPackets = true
+	defer func() {
+		s.batchPackets = false
+		if flushErr := s.flushPackets(); err == nil {
+			err = flushErr
+		}
+	}()
+
 	numPackets := s.sentPacketHandler.ShouldSendNumPackets()
 	var numPacketsSent int
 sendLoop:
 	for {
+		if s.isAmplificationLimited() {
+			s.logger.Debugf("Amplification limited. Waiting for the validation of peer address %s.", s.conn.RemoteAddr())
+			return nil
+		}
 		switch sendMode {
 		case ackhandler.SendNone:
 			break sendLoop
@@ -1008,6 +1629,13 @@ sendLoop:
 				// e.g. when an Initial is queued, but we already received a packet from the server.
 			}
 		case ackhandler.SendAny:
+			if s.handshakeComplete && s.mtuDiscoverer != nil && s.mtuDiscoverer.ShouldSendProbe(time.Now()) {
+				if err := s.sendMTUProbePacket(); err != nil {
+					return err
+				}
+				numPacketsSent++
+				break
+			}
 			sentPacket, err := s.sendPacket()
 			if err != nil {
 				return err
@@ -1076,6 +1704,10 @@ func (s *session) sendProbePacket() error {
 		return err
 	}
 	s.logger.Debugf("Sending a retransmission for %#x as a probe packet.", p.PacketNumber)
+	if s.mtuDiscoverer != nil {
+		// This might reduce the packet size, if the path is black-holing large packets.
+		s.mtuDiscoverer.OnProbeTimeout(time.Now())
+	}
 
 	packets, err := s.packer.PackRetransmission(p)
 	if err != nil {
@@ -1097,6 +1729,9 @@ func (s *session) sendProbePacket() error {
 func (s *session) sendPacket() (bool, error) {
 	if isBlocked, offset := s.connFlowController.IsNewlyBlocked(); isBlocked {
 		s.framer.QueueControlFrame(&wire.DataBlockedFrame{DataLimit: offset})
+		if s.flowControlBlockedSince.IsZero() {
+			s.flowControlBlockedSince = time.Now()
+		}
 	}
 	s.windowUpdateQueue.QueueAll()
 
@@ -1104,20 +1739,79 @@ func (s *session) sendPacket() (bool, error) {
 	if err != nil || packet == nil {
 		return false, err
 	}
-	s.sentPacketHandler.SentPacket(packet.ToAckHandlerPacket())
+	ackhandlerPacket := packet.ToAckHandlerPacket()
+	if s.mtuDiscoverer != nil {
+		ackhandlerPacket.AckCallback = s.mtuDiscoverer.TrackPacket(ackhandlerPacket.Length, ackhandlerPacket.AckCallback)
+	}
+	s.sentPacketHandler.SentPacket(ackhandlerPacket)
 	if err := s.sendPackedPacket(packet); err != nil {
 		return false, err
 	}
 	return true, nil
 }
 
+func (s *session) sendMTUProbePacket() error {
+	size, ackCallback := s.mtuDiscoverer.GetProbe(time.Now())
+	packet, err := s.packer.PackMTUProbePacket(size)
+	if err != nil {
+		return err
+	}
+	packet.ackCallback = ackCallback
+	s.sentPacketHandler.SentPacket(packet.ToAckHandlerPacket())
+	// The probe packet is written right away (and not batched), so we can check the error.
+	// Sending fails if the probe packet is larger than the MTU of the interface.
+	// It will then be declared lost, just as if it had been dropped on the path.
+	// Packets queued before must be written first, so that they're not reordered.
+	if err := s.flushPackets(); err != nil {
+		return err
+	}
+	s.onPackedPacketSent(packet)
+	err = s.conn.Write(packet.raw)
+	packet.buffer.Release()
+	if err != nil && !isMsgSizeErr(err) {
+		return err
+	}
+	return nil
+}
+
 func (s *session) sendPackedPacket(packet *packedPacket) error {
+	s.onPackedPacketSent(packet)
+	if s.batchPackets {
+		s.sendQueue = append(s.sendQueue, packet)
+		return nil
+	}
 	defer packet.buffer.Release()
+	return s.conn.Write(packet.raw)
+}
+
+func (s *session) onPackedPacketSent(packet *packedPacket) {
 	if s.firstAckElicitingPacketAfterIdleSentTime.IsZero() && packet.IsAckEliciting() {
 		s.firstAckElicitingPacketAfterIdleSentTime = time.Now()
 	}
+	s.connIDManager.SentPacket()
+	if v := s.pathValidation; v != nil && v.conn == nil {
+		v.bytesSent += protocol.ByteCount(len(packet.raw))
+	}
 	s.logPacket(packet)
-	return s.conn.Write(packet.raw)
+}
+
+// flushPackets writes all packets queued during the send loop.
+func (s *session) flushPackets() error {
+	if len(s.sendQueue) == 0 {
+		return nil
+	}
+	s.sendQueueRaw = s.sendQueueRaw[:0]
+	for _, p := range s.sendQueue {
+		s.sendQueueRaw = append(s.sendQueueRaw, p.raw)
+	}
+	err := s.conn.WriteBatch(s.sendQueueRaw)
+	for i, p := range s.sendQueue {
+		p.buffer.Release()
+		s.sendQueue[i] = nil
+		s.sendQueueRaw[i] = nil
+	}
+	s.sendQueue = s.sendQueue[:0]
+	return err
 }
 
 func (s *session) sendConnectionClose(quicErr *qerr.QuicError) error {
@@ -1138,7 +1832,14 @@ func (s *session) sendConnectionClose(quicErr *qerr.QuicError) error {
 	return s.conn.Write(packet.raw)
 }
 
+// logPacket is called for every packet sent.
+// Besides logging and tracing, it also counts the packet for the statistics.
 func (s *session) logPacket(packet *packedPacket) {
+	s.packetsSent++
+	s.bytesSent += uint64(len(packet.raw))
+	if s.tracer != nil {
+		s.tracer.SentPacket(packet.header, protocol.ByteCount(len(packet.raw)), packet.frames)
+	}
 	if !s.logger.Debug() {
 		// We don't need to allocate the slices for calling the format functions
 		return
@@ -1224,15 +1925,24 @@ func (s *session) scheduleSending() {
 	}
 }
 
-func (s *session) tryQueueingUndecryptablePacket(p *receivedPacket) {
+func (s *session) tryQueueingUndecryptablePacket(p *receivedPacket, hdr *wire.Header) {
 	if s.handshakeComplete {
+		if s.tracer != nil {
+			s.tracer.DroppedPacket(logging.PacketTypeFromHeader(hdr), protocol.ByteCount(len(p.data)), logging.PacketDropKeyUnavailable)
+		}
 		s.logger.Debugf("Received undecryptable packet from %s after the handshake (%d bytes)", p.remoteAddr.String(), len(p.data))
 		return
 	}
 	if len(s.undecryptablePackets)+1 > protocol.MaxUndecryptablePackets {
+		if s.tracer != nil {
+			s.tracer.DroppedPacket(logging.PacketTypeFromHeader(hdr), protocol.ByteCount(len(p.data)), logging.PacketDropDOSPrevention)
+		}
 		s.logger.Infof("Dropping undecrytable packet (%d bytes). Undecryptable packet queue full.", len(p.data))
 		return
 	}
+	if s.tracer != nil {
+		s.tracer.BufferedPacket(logging.PacketTypeFromHeader(hdr))
+	}
 	s.logger.Infof("Queueing packet (%d bytes) for later decryption", len(p.data))
 	s.undecryptablePackets = append(s.undecryptablePackets, p)
 }
@@ -1264,17 +1974,28 @@ func (s *session) onHasStreamData(id protocol.StreamID) {
 	s.scheduleSending()
 }
 
+func (s *session) onStreamPriorityChanged(id protocol.StreamID, p StreamPriority) {
+	s.framer.SetStreamPriority(id, p)
+	s.scheduleSending()
+}
+
 func (s *session) onStreamCompleted(id protocol.StreamID) {
+	// Delete the stream first, so that the framer ignores priority changes that happen concurrently.
 	if err := s.streamsMap.DeleteStream(id); err != nil {
 		s.closeLocal(err)
 	}
+	s.framer.RemoveStream(id)
 }
 
 func (s *session) LocalAddr() net.Addr {
+	s.connMutex.RLock()
+	defer s.connMutex.RUnlock()
 	return s.conn.LocalAddr()
 }
 
 func (s *session) RemoteAddr() net.Addr {
+	s.connMutex.RLock()
+	defer s.connMutex.RUnlock()
 	return s.conn.RemoteAddr()
 }
 
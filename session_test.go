@@ -5,6 +5,7 @@ import (
 	"context"
 	"crypto/rand"
 	"errors"
+	"fmt"
 	"net"
 	"runtime/pprof"
 	"strings"
@@ -14,26 +15,36 @@ import (
 	. "github.com/onsi/gomega"
 
 	"github.com/golang/mock/gomock"
+	"github.com/lucas-clemente/quic-go/congestion"
 	"github.com/lucas-clemente/quic-go/internal/ackhandler"
 	"github.com/lucas-clemente/quic-go/internal/handshake"
 	"github.com/lucas-clemente/quic-go/internal/mocks"
 	mockackhandler "github.com/lucas-clemente/quic-go/internal/mocks/ackhandler"
+	mocklogging "github.com/lucas-clemente/quic-go/internal/mocks/logging"
 	"github.com/lucas-clemente/quic-go/internal/protocol"
 	"github.com/lucas-clemente/quic-go/internal/qerr"
 	"github.com/lucas-clemente/quic-go/internal/utils"
 	"github.com/lucas-clemente/quic-go/internal/wire"
+	"github.com/lucas-clemente/quic-go/logging"
 )
 
 type mockConnection struct {
 	remoteAddr net.Addr
 	localAddr  net.Addr
 	written    chan []byte
+	writtenTo  chan mockPacketConnWrite
+	batches    chan int // the number of packets in every call to WriteBatch
+	ecnMarking bool
+	ecnSet     bool // set when SetECN is called
+	df         bool // the return value of SetDF
 }
 
 func newMockConnection() *mockConnection {
 	return &mockConnection{
 		remoteAddr: &net.UDPAddr{},
 		written:    make(chan []byte, 100),
+		writtenTo:  make(chan mockPacketConnWrite, 100),
+		batches:    make(chan int, 100),
 	}
 }
 
@@ -47,14 +58,40 @@ func (m *mockConnection) Write(p []byte) error {
 	}
 	return nil
 }
+func (m *mockConnection) WriteBatch(ps [][]byte) error {
+	select {
+	case m.batches <- len(ps):
+	default:
+		panic("mockConnection channel full")
+	}
+	for _, p := range ps {
+		if err := m.Write(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+func (m *mockConnection) WriteTo(p []byte, addr net.Addr) error {
+	b := make([]byte, len(p))
+	copy(b, p)
+	select {
+	case m.writtenTo <- mockPacketConnWrite{data: b, to: addr}:
+	default:
+		panic("mockConnection channel full")
+	}
+	return nil
+}
 func (m *mockConnection) Read([]byte) (int, net.Addr, error) { panic("not implemented") }
 
 func (m *mockConnection) SetCurrentRemoteAddr(addr net.Addr) {
 	m.remoteAddr = addr
 }
-func (m *mockConnection) LocalAddr() net.Addr  { return m.localAddr }
-func (m *mockConnection) RemoteAddr() net.Addr { return m.remoteAddr }
-func (*mockConnection) Close() error           { panic("not implemented") }
+func (m *mockConnection) SetDF() bool                { return m.df }
+func (m *mockConnection) SetECN() bool               { m.ecnSet = true; return false }
+func (m *mockConnection) SetECNMarking(enabled bool) { m.ecnMarking = enabled }
+func (m *mockConnection) LocalAddr() net.Addr        { return m.localAddr }
+func (m *mockConnection) RemoteAddr() net.Addr       { return m.remoteAddr }
+func (*mockConnection) Close() error                 { panic("not implemented") }
 
 func areSessionsRunning() bool {
 	var b bytes.Buffer
@@ -155,6 +192,7 @@ var _ = Describe("Session", func() {
 				f := &wire.AckFrame{AckRanges: []wire.AckRange{{Smallest: 2, Largest: 3}}}
 				sph := mockackhandler.NewMockSentPacketHandler(mockCtrl)
 				sph.EXPECT().ReceivedAck(f, protocol.PacketNumber(42), protocol.EncryptionHandshake, gomock.Any())
+				sph.EXPECT().ECNMode().AnyTimes()
 				sess.sentPacketHandler = sph
 				err := sess.handleAckFrame(f, 42, protocol.EncryptionHandshake)
 				Expect(err).ToNot(HaveOccurred())
@@ -164,13 +202,28 @@ var _ = Describe("Session", func() {
 				ack := &wire.AckFrame{AckRanges: []wire.AckRange{{Smallest: 2, Largest: 3}}}
 				sph := mockackhandler.NewMockSentPacketHandler(mockCtrl)
 				sph.EXPECT().ReceivedAck(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any())
+				sph.EXPECT().ECNMode().AnyTimes()
 				sph.EXPECT().GetLowestPacketNotConfirmedAcked().Return(protocol.PacketNumber(0x42))
 				sess.sentPacketHandler = sph
 				rph := mockackhandler.NewMockReceivedPacketHandler(mockCtrl)
 				rph.EXPECT().IgnoreBelow(protocol.PacketNumber(0x42))
 				sess.receivedPacketHandler = rph
+				cryptoSetup.EXPECT().SetLargestAcked(protocol.PacketNumber(3))
 				Expect(sess.handleAckFrame(ack, 0, protocol.Encryption1RTT)).To(Succeed())
 			})
+
+			It("disables ECN marking if ECN validation failed", func() {
+				f := &wire.AckFrame{AckRanges: []wire.AckRange{{Smallest: 2, Largest: 3}}}
+				sph := mockackhandler.NewMockSentPacketHandler(mockCtrl)
+				sph.EXPECT().ReceivedAck(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(2)
+				sess.sentPacketHandler = sph
+				sph.EXPECT().ECNMode().Return(protocol.ECT0)
+				Expect(sess.handleAckFrame(f, 42, protocol.EncryptionHandshake)).To(Succeed())
+				Expect(mconn.ecnMarking).To(BeTrue())
+				sph.EXPECT().ECNMode().Return(protocol.ECNNon)
+				Expect(sess.handleAckFrame(f, 43, protocol.EncryptionHandshake)).To(Succeed())
+				Expect(mconn.ecnMarking).To(BeFalse())
+			})
 		})
 
 		Context("handling RESET_STREAM frames", func() {
@@ -205,7 +258,7 @@ var _ = Describe("Session", func() {
 				Expect(sess.handleFrame(&wire.ResetStreamFrame{
 					StreamID:  3,
 					ErrorCode: 42,
-				}, 0, protocol.EncryptionUnspecified)).To(Succeed())
+				}, 0, protocol.EncryptionUnspecified, nil)).To(Succeed())
 			})
 		})
 
@@ -240,7 +293,7 @@ var _ = Describe("Session", func() {
 				Expect(sess.handleFrame(&wire.MaxStreamDataFrame{
 					StreamID:   10,
 					ByteOffset: 1337,
-				}, 0, protocol.EncryptionUnspecified)).To(Succeed())
+				}, 0, protocol.EncryptionUnspecified, nil)).To(Succeed())
 			})
 		})
 
@@ -282,43 +335,139 @@ var _ = Describe("Session", func() {
 				Expect(sess.handleFrame(&wire.StopSendingFrame{
 					StreamID:  3,
 					ErrorCode: 1337,
-				}, 0, protocol.EncryptionUnspecified)).To(Succeed())
+				}, 0, protocol.EncryptionUnspecified, nil)).To(Succeed())
 			})
 		})
 
 		It("handles PING frames", func() {
-			err := sess.handleFrame(&wire.PingFrame{}, 0, protocol.EncryptionUnspecified)
+			err := sess.handleFrame(&wire.PingFrame{}, 0, protocol.EncryptionUnspecified, nil)
 			Expect(err).NotTo(HaveOccurred())
 		})
 
-		It("rejects PATH_RESPONSE frames", func() {
-			err := sess.handleFrame(&wire.PathResponseFrame{Data: [8]byte{1, 2, 3, 4, 5, 6, 7, 8}}, 0, protocol.EncryptionUnspecified)
-			Expect(err).To(MatchError("unexpected PATH_RESPONSE frame"))
+		It("ignores unexpected PATH_RESPONSE frames", func() {
+			err := sess.handleFrame(&wire.PathResponseFrame{Data: [8]byte{1, 2, 3, 4, 5, 6, 7, 8}}, 0, protocol.EncryptionUnspecified, nil)
+			Expect(err).ToNot(HaveOccurred())
 		})
 
 		It("handles PATH_CHALLENGE frames", func() {
 			data := [8]byte{1, 2, 3, 4, 5, 6, 7, 8}
-			err := sess.handleFrame(&wire.PathChallengeFrame{Data: data}, 0, protocol.EncryptionUnspecified)
+			err := sess.handleFrame(&wire.PathChallengeFrame{Data: data}, 0, protocol.EncryptionUnspecified, mconn.RemoteAddr())
 			Expect(err).ToNot(HaveOccurred())
 			frames, _ := sess.framer.AppendControlFrames(nil, 1000)
 			Expect(frames).To(Equal([]wire.Frame{&wire.PathResponseFrame{Data: data}}))
 		})
 
+		It("sends the PATH_RESPONSE on the path the PATH_CHALLENGE was received on", func() {
+			sph := mockackhandler.NewMockSentPacketHandler(mockCtrl)
+			sess.sentPacketHandler = sph
+			data := [8]byte{1, 2, 3, 4, 5, 6, 7, 8}
+			buffer := getPacketBuffer()
+			packer.EXPECT().PackPathValidationPacket(&wire.PathResponseFrame{Data: data}).Return(&packedPacket{
+				header: &wire.ExtendedHeader{PacketNumber: 42},
+				raw:    append(buffer.Slice[:0], []byte("foobar")...),
+				buffer: buffer,
+			}, nil)
+			sph.EXPECT().SentPacket(gomock.Any())
+			addr := &net.UDPAddr{IP: net.IPv4(192, 168, 0, 100), Port: 1337}
+			err := sess.handleFrame(&wire.PathChallengeFrame{Data: data}, 0, protocol.Encryption1RTT, addr)
+			Expect(err).ToNot(HaveOccurred())
+			var write mockPacketConnWrite
+			Expect(mconn.writtenTo).To(Receive(&write))
+			Expect(write.to).To(Equal(addr))
+			Expect(write.data).To(Equal([]byte("foobar")))
+			Expect(mconn.written).To(BeEmpty())
+			frames, _ := sess.framer.AppendControlFrames(nil, 1000)
+			Expect(frames).To(BeEmpty())
+		})
+
+		Context("handling NEW_CONNECTION_ID frames", func() {
+			It("stores the connection IDs", func() {
+				f1 := &wire.NewConnectionIDFrame{SequenceNumber: 1, ConnectionID: protocol.ConnectionID{1, 2, 3, 4}}
+				f2 := &wire.NewConnectionIDFrame{SequenceNumber: 2, ConnectionID: protocol.ConnectionID{5, 6, 7, 8}}
+				Expect(sess.handleFrame(f2, 0, protocol.Encryption1RTT, nil)).To(Succeed())
+				Expect(sess.handleFrame(f1, 0, protocol.Encryption1RTT, nil)).To(Succeed())
+				Expect(sess.connIDManager.queue).To(Equal([]*wire.NewConnectionIDFrame{f1, f2}))
+			})
+
+			It("errors when the peer uses a zero-length connection ID", func() {
+				sess.destConnID = protocol.ConnectionID{}
+				err := sess.handleFrame(&wire.NewConnectionIDFrame{SequenceNumber: 1, ConnectionID: protocol.ConnectionID{1, 2, 3, 4}}, 0, protocol.Encryption1RTT, nil)
+				Expect(err).To(MatchError("PROTOCOL_VIOLATION: received NEW_CONNECTION_ID frame, but the peer uses a zero-length connection ID"))
+			})
+		})
+
+		Context("handling RETIRE_CONNECTION_ID frames", func() {
+			BeforeEach(func() {
+				sessionRunner.EXPECT().Add(gomock.Any(), sess).Times(protocol.MaxActiveConnectionIDs - 1)
+				sessionRunner.EXPECT().GetStatelessResetToken(gomock.Any()).Times(protocol.MaxActiveConnectionIDs - 1)
+				Expect(sess.connIDGenerator.IssueConnectionIDs()).To(Succeed())
+				frames, _ := sess.framer.AppendControlFrames(nil, 1000)
+				Expect(frames).To(HaveLen(protocol.MaxActiveConnectionIDs - 1))
+			})
+
+			It("retires the connection ID and issues a new one", func() {
+				retired := sess.connIDGenerator.activeSrcConnIDs[1]
+				token := [16]byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}
+				sessionRunner.EXPECT().Retire(retired)
+				sessionRunner.EXPECT().Add(gomock.Any(), sess)
+				sessionRunner.EXPECT().GetStatelessResetToken(gomock.Any()).Return(token)
+				Expect(sess.handleFrame(&wire.RetireConnectionIDFrame{SequenceNumber: 1}, 0, protocol.Encryption1RTT, nil)).To(Succeed())
+				frames, _ := sess.framer.AppendControlFrames(nil, 1000)
+				Expect(frames).To(HaveLen(1))
+				Expect(frames[0]).To(BeAssignableToTypeOf(&wire.NewConnectionIDFrame{}))
+				f := frames[0].(*wire.NewConnectionIDFrame)
+				Expect(f.SequenceNumber).To(BeEquivalentTo(protocol.MaxActiveConnectionIDs))
+				Expect(f.StatelessResetToken).To(Equal(token))
+			})
+
+			It("errors when the peer retires a connection ID that wasn't issued yet", func() {
+				err := sess.handleFrame(&wire.RetireConnectionIDFrame{SequenceNumber: protocol.MaxActiveConnectionIDs}, 0, protocol.Encryption1RTT, nil)
+				Expect(err).To(MatchError(fmt.Sprintf("PROTOCOL_VIOLATION: tried to retire connection ID %d. Highest issued: %d", protocol.MaxActiveConnectionIDs, protocol.MaxActiveConnectionIDs-1)))
+			})
+		})
+
 		It("handles BLOCKED frames", func() {
-			err := sess.handleFrame(&wire.DataBlockedFrame{}, 0, protocol.EncryptionUnspecified)
+			err := sess.handleFrame(&wire.DataBlockedFrame{}, 0, protocol.EncryptionUnspecified, nil)
 			Expect(err).NotTo(HaveOccurred())
 		})
 
 		It("handles STREAM_BLOCKED frames", func() {
-			err := sess.handleFrame(&wire.StreamDataBlockedFrame{}, 0, protocol.EncryptionUnspecified)
+			err := sess.handleFrame(&wire.StreamDataBlockedFrame{}, 0, protocol.EncryptionUnspecified, nil)
 			Expect(err).NotTo(HaveOccurred())
 		})
 
 		It("handles STREAM_ID_BLOCKED frames", func() {
-			err := sess.handleFrame(&wire.StreamsBlockedFrame{}, 0, protocol.EncryptionUnspecified)
+			err := sess.handleFrame(&wire.StreamsBlockedFrame{}, 0, protocol.EncryptionUnspecified, nil)
 			Expect(err).NotTo(HaveOccurred())
 		})
 
+		Context("handling DATAGRAM frames", func() {
+			It("errors if DATAGRAM support is disabled", func() {
+				err := sess.handleFrame(&wire.DatagramFrame{Data: []byte("foobar")}, 0, protocol.Encryption1RTT, nil)
+				Expect(err).To(MatchError("PROTOCOL_VIOLATION: received a DATAGRAM frame, but DATAGRAM support is disabled"))
+			})
+
+			It("queues received DATAGRAM frames", func() {
+				sess.config.EnableDatagrams = true
+				Expect(sess.handleFrame(&wire.DatagramFrame{Data: []byte("foobar")}, 0, protocol.Encryption1RTT, nil)).To(Succeed())
+				data, err := sess.ReceiveMessage()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(data).To(Equal([]byte("foobar")))
+			})
+
+			It("errors on DATAGRAM frames in unencrypted packets", func() {
+				sess.config.EnableDatagrams = true
+				err := sess.handleFrame(&wire.DatagramFrame{Data: []byte("foobar")}, 0, protocol.EncryptionHandshake, nil)
+				Expect(err).To(MatchError("PROTOCOL_VIOLATION: received an unencrypted DATAGRAM frame"))
+			})
+
+			It("errors on DATAGRAM frames that are too large", func() {
+				sess.config.EnableDatagrams = true
+				err := sess.handleFrame(&wire.DatagramFrame{Data: make([]byte, protocol.MaxDatagramFrameSize)}, 0, protocol.Encryption1RTT, nil)
+				Expect(err).To(MatchError("PROTOCOL_VIOLATION: DATAGRAM frame too large"))
+			})
+		})
+
 		It("handles CONNECTION_CLOSE frames", func() {
 			testErr := qerr.Error(qerr.StreamLimitError, "foobar")
 			streamManager.EXPECT().CloseWithError(testErr)
@@ -334,11 +483,75 @@ var _ = Describe("Session", func() {
 				ErrorCode:    qerr.StreamLimitError,
 				ReasonPhrase: "foobar",
 			}
-			Expect(sess.handleFrame(ccf, 0, protocol.EncryptionUnspecified)).To(Succeed())
+			Expect(sess.handleFrame(ccf, 0, protocol.EncryptionUnspecified, nil)).To(Succeed())
 			Eventually(sess.Context().Done()).Should(BeClosed())
 		})
 	})
 
+	It("traces the start of the connection", func() {
+		tracer := mocklogging.NewMockTracer(mockCtrl)
+		connTracer := mocklogging.NewMockConnectionTracer(mockCtrl)
+		clientDestConnID := protocol.ConnectionID{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
+		params := &handshake.TransportParameters{IdleTimeout: time.Minute}
+		gomock.InOrder(
+			tracer.EXPECT().TracerForConnection(protocol.PerspectiveServer, clientDestConnID).Return(connTracer),
+			connTracer.EXPECT().UpdatedKeyFromTLS(protocol.EncryptionInitial, protocol.PerspectiveClient),
+			connTracer.EXPECT().UpdatedKeyFromTLS(protocol.EncryptionInitial, protocol.PerspectiveServer),
+			connTracer.EXPECT().StartedConnection(mconn.LocalAddr(), mconn.RemoteAddr(), protocol.VersionTLS, protocol.ConnectionID{1, 2, 3, 4, 5, 6, 7, 8}, protocol.ConnectionID{8, 7, 6, 5, 4, 3, 2, 1}),
+			connTracer.EXPECT().SentTransportParameters(params),
+		)
+		s, err := newSession(
+			mconn,
+			sessionRunner,
+			clientDestConnID,
+			protocol.ConnectionID{8, 7, 6, 5, 4, 3, 2, 1},
+			protocol.ConnectionID{1, 2, 3, 4, 5, 6, 7, 8},
+			populateServerConfig(&Config{Tracer: tracer}),
+			nil, // tls.Config
+			params,
+			utils.DefaultLogger,
+			protocol.VersionTLS,
+		)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(s.(*session).tracer).To(Equal(connTracer))
+	})
+
+	Context("enabling ECN", func() {
+		newSessionWithConfig := func(conf *Config) *session {
+			s, err := newSession(
+				mconn,
+				sessionRunner,
+				nil,
+				protocol.ConnectionID{8, 7, 6, 5, 4, 3, 2, 1},
+				protocol.ConnectionID{1, 2, 3, 4, 5, 6, 7, 8},
+				populateServerConfig(conf),
+				nil, // tls.Config
+				&handshake.TransportParameters{},
+				utils.DefaultLogger,
+				protocol.VersionTLS,
+			)
+			Expect(err).ToNot(HaveOccurred())
+			return s.(*session)
+		}
+
+		It("enables ECN on the connection", func() {
+			s := newSessionWithConfig(&Config{EnableECN: true})
+			Expect(s.useECN).To(BeTrue())
+			Expect(mconn.ecnSet).To(BeTrue())
+		})
+
+		It("doesn't enable ECN if the congestion controller doesn't react to ECN-CE marks", func() {
+			s := newSessionWithConfig(&Config{
+				EnableECN: true,
+				CongestionControl: func(congestion.RTTStats) congestion.SendAlgorithm {
+					return mocks.NewMockExtendedSendAlgorithm(mockCtrl)
+				},
+			})
+			Expect(s.useECN).To(BeFalse())
+			Expect(mconn.ecnSet).To(BeFalse())
+		})
+	})
+
 	It("tells its versions", func() {
 		sess.version = 4242
 		Expect(sess.GetVersion()).To(Equal(protocol.VersionNumber(4242)))
@@ -387,6 +600,36 @@ var _ = Describe("Session", func() {
 			Expect(sess.Context().Done()).To(BeClosed())
 		})
 
+		It("traces the close", func() {
+			tracer := mocklogging.NewMockConnectionTracer(mockCtrl)
+			sess.tracer = tracer
+			streamManager.EXPECT().CloseWithError(qerr.Error(qerr.NoError, ""))
+			sessionRunner.EXPECT().Retire(gomock.Any())
+			cryptoSetup.EXPECT().Close()
+			packer.EXPECT().PackConnectionClose(gomock.Any()).Return(&packedPacket{raw: []byte("connection close")}, nil)
+			gomock.InOrder(
+				tracer.EXPECT().SentPacket(gomock.Any(), protocol.ByteCount(len("connection close")), gomock.Any()),
+				tracer.EXPECT().ClosedConnection(qerr.NoError, false),
+				tracer.EXPECT().Close(),
+			)
+			Expect(sess.Close()).To(Succeed())
+			Eventually(areSessionsRunning).Should(BeFalse())
+		})
+
+		It("returns the statistics after closing", func() {
+			streamManager.EXPECT().CloseWithError(qerr.Error(qerr.NoError, ""))
+			sessionRunner.EXPECT().Retire(gomock.Any())
+			cryptoSetup.EXPECT().Close()
+			packer.EXPECT().PackConnectionClose(gomock.Any()).Return(&packedPacket{raw: []byte("connection close")}, nil)
+			Expect(sess.Close()).To(Succeed())
+			Eventually(areSessionsRunning).Should(BeFalse())
+			streamManager.EXPECT().NumStreams(protocol.StreamTypeBidi)
+			streamManager.EXPECT().NumStreams(protocol.StreamTypeUni)
+			stats := sess.Stats()
+			Expect(stats.PacketsSent).To(BeEquivalentTo(1))
+			Expect(stats.BytesSent).To(BeEquivalentTo(len("connection close")))
+		})
+
 		It("only closes once", func() {
 			streamManager.EXPECT().CloseWithError(qerr.Error(qerr.NoError, ""))
 			sessionRunner.EXPECT().Retire(gomock.Any())
@@ -500,14 +743,14 @@ var _ = Describe("Session", func() {
 				PacketNumberLen: protocol.PacketNumberLen1,
 			}
 			rcvTime := time.Now().Add(-10 * time.Second)
-			unpacker.EXPECT().Unpack(gomock.Any(), gomock.Any()).Return(&unpackedPacket{
+			unpacker.EXPECT().Unpack(gomock.Any(), gomock.Any(), gomock.Any()).Return(&unpackedPacket{
 				packetNumber:    0x1337,
 				encryptionLevel: protocol.EncryptionInitial,
 				hdr:             hdr,
 				data:            []byte{0}, // one PADDING frame
 			}, nil)
 			rph := mockackhandler.NewMockReceivedPacketHandler(mockCtrl)
-			rph.EXPECT().ReceivedPacket(protocol.PacketNumber(0x1337), protocol.EncryptionInitial, rcvTime, false)
+			rph.EXPECT().ReceivedPacket(protocol.PacketNumber(0x1337), protocol.ECNNon, protocol.EncryptionInitial, rcvTime, false)
 			sess.receivedPacketHandler = rph
 			packet := getPacket(hdr, nil)
 			packet.rcvTime = rcvTime
@@ -523,22 +766,93 @@ var _ = Describe("Session", func() {
 			rcvTime := time.Now().Add(-10 * time.Second)
 			buf := &bytes.Buffer{}
 			Expect((&wire.PingFrame{}).Write(buf, sess.version)).To(Succeed())
-			unpacker.EXPECT().Unpack(gomock.Any(), gomock.Any()).Return(&unpackedPacket{
+			unpacker.EXPECT().Unpack(gomock.Any(), gomock.Any(), gomock.Any()).Return(&unpackedPacket{
 				packetNumber:    0x1337,
 				encryptionLevel: protocol.EncryptionHandshake,
 				hdr:             hdr,
 				data:            buf.Bytes(),
 			}, nil)
 			rph := mockackhandler.NewMockReceivedPacketHandler(mockCtrl)
-			rph.EXPECT().ReceivedPacket(protocol.PacketNumber(0x1337), protocol.EncryptionHandshake, rcvTime, true)
+			rph.EXPECT().ReceivedPacket(protocol.PacketNumber(0x1337), protocol.ECNNon, protocol.EncryptionHandshake, rcvTime, true)
 			sess.receivedPacketHandler = rph
 			packet := getPacket(hdr, nil)
 			packet.rcvTime = rcvTime
 			Expect(sess.handlePacketImpl(packet)).To(BeTrue())
 		})
 
+		It("informs the ReceivedPacketHandler about the ECN codepoint", func() {
+			hdr := &wire.ExtendedHeader{
+				Header:          wire.Header{DestConnectionID: sess.srcConnID},
+				PacketNumber:    0x37,
+				PacketNumberLen: protocol.PacketNumberLen1,
+			}
+			unpacker.EXPECT().Unpack(gomock.Any(), gomock.Any(), gomock.Any()).Return(&unpackedPacket{
+				packetNumber:    0x1337,
+				encryptionLevel: protocol.Encryption1RTT,
+				hdr:             hdr,
+				data:            []byte{0}, // one PADDING frame
+			}, nil)
+			rph := mockackhandler.NewMockReceivedPacketHandler(mockCtrl)
+			rph.EXPECT().ReceivedPacket(protocol.PacketNumber(0x1337), protocol.ECNCE, protocol.Encryption1RTT, gomock.Any(), false)
+			sess.receivedPacketHandler = rph
+			packet := getPacket(hdr, nil)
+			packet.ecn = protocol.ECNCE
+			Expect(sess.handlePacketImpl(packet)).To(BeTrue())
+		})
+
+		It("counts received packets", func() {
+			hdr := &wire.ExtendedHeader{
+				Header:          wire.Header{DestConnectionID: sess.srcConnID},
+				PacketNumber:    0x37,
+				PacketNumberLen: protocol.PacketNumberLen1,
+			}
+			unpacker.EXPECT().Unpack(gomock.Any(), gomock.Any(), gomock.Any()).Return(&unpackedPacket{
+				packetNumber:    0x1337,
+				encryptionLevel: protocol.Encryption1RTT,
+				hdr:             hdr,
+				data:            []byte{0}, // one PADDING frame
+			}, nil)
+			packet := getPacket(hdr, []byte("foobar"))
+			Expect(sess.handlePacketImpl(packet)).To(BeTrue())
+			Expect(sess.packetsReceived).To(BeEquivalentTo(1))
+			Expect(sess.bytesReceived).To(BeEquivalentTo(len(packet.data)))
+		})
+
+		It("traces received packets", func() {
+			tracer := mocklogging.NewMockConnectionTracer(mockCtrl)
+			sess.tracer = tracer
+			hdr := &wire.ExtendedHeader{
+				Header:          wire.Header{DestConnectionID: sess.srcConnID},
+				PacketNumber:    0x37,
+				PacketNumberLen: protocol.PacketNumberLen1,
+			}
+			buf := &bytes.Buffer{}
+			Expect((&wire.PingFrame{}).Write(buf, sess.version)).To(Succeed())
+			unpacker.EXPECT().Unpack(gomock.Any(), gomock.Any(), gomock.Any()).Return(&unpackedPacket{
+				packetNumber:    0x1337,
+				encryptionLevel: protocol.Encryption1RTT,
+				hdr:             hdr,
+				data:            buf.Bytes(),
+			}, nil)
+			packet := getPacket(hdr, []byte("foobar"))
+			tracer.EXPECT().ReceivedPacket(hdr, protocol.ByteCount(len(packet.data)), []logging.Frame{&wire.PingFrame{}})
+			Expect(sess.handlePacketImpl(packet)).To(BeTrue())
+		})
+
+		It("traces packets that can't be decrypted", func() {
+			tracer := mocklogging.NewMockConnectionTracer(mockCtrl)
+			sess.tracer = tracer
+			unpacker.EXPECT().Unpack(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, errors.New("unpack error"))
+			packet := getPacket(&wire.ExtendedHeader{
+				Header:          wire.Header{DestConnectionID: sess.srcConnID},
+				PacketNumberLen: protocol.PacketNumberLen1,
+			}, nil)
+			tracer.EXPECT().DroppedPacket(logging.PacketType1RTT, protocol.ByteCount(len(packet.data)), logging.PacketDropPayloadDecryptError)
+			Expect(sess.handlePacketImpl(packet)).To(BeFalse())
+		})
+
 		It("drops a packet when unpacking fails", func() {
-			unpacker.EXPECT().Unpack(gomock.Any(), gomock.Any()).Return(nil, errors.New("unpack error"))
+			unpacker.EXPECT().Unpack(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, errors.New("unpack error"))
 			streamManager.EXPECT().CloseWithError(gomock.Any())
 			cryptoSetup.EXPECT().Close()
 			packer.EXPECT().PackConnectionClose(gomock.Any()).Return(&packedPacket{}, nil)
@@ -558,8 +872,29 @@ var _ = Describe("Session", func() {
 			Eventually(sess.Context().Done()).Should(BeClosed())
 		})
 
+		It("closes the session when unpacking fails with a QUIC error", func() {
+			unpacker.EXPECT().Unpack(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, qerr.Error(qerr.KeyUpdateError, "keys updated too quickly"))
+			streamManager.EXPECT().CloseWithError(gomock.Any())
+			cryptoSetup.EXPECT().Close()
+			packer.EXPECT().PackConnectionClose(gomock.Any()).Return(&packedPacket{}, nil)
+			done := make(chan struct{})
+			go func() {
+				defer GinkgoRecover()
+				cryptoSetup.EXPECT().RunHandshake().Do(func() { <-sess.Context().Done() })
+				err := sess.run()
+				Expect(err).To(MatchError("KEY_UPDATE_ERROR: keys updated too quickly"))
+				close(done)
+			}()
+			sessionRunner.EXPECT().Retire(gomock.Any())
+			sess.handlePacket(getPacket(&wire.ExtendedHeader{
+				Header:          wire.Header{DestConnectionID: sess.srcConnID},
+				PacketNumberLen: protocol.PacketNumberLen1,
+			}, nil))
+			Eventually(done).Should(BeClosed())
+		})
+
 		It("rejects packets with empty payload", func() {
-			unpacker.EXPECT().Unpack(gomock.Any(), gomock.Any()).Return(&unpackedPacket{
+			unpacker.EXPECT().Unpack(gomock.Any(), gomock.Any(), gomock.Any()).Return(&unpackedPacket{
 				hdr:  &wire.ExtendedHeader{},
 				data: []byte{}, // no payload
 			}, nil)
@@ -594,6 +929,24 @@ var _ = Describe("Session", func() {
 			Expect(sess.handlePacketImpl(getPacket(hdr, nil))).To(BeFalse())
 		})
 
+		It("traces dropped 0-RTT packets", func() {
+			tracer := mocklogging.NewMockConnectionTracer(mockCtrl)
+			sess.tracer = tracer
+			hdr := &wire.ExtendedHeader{
+				Header: wire.Header{
+					IsLongHeader:     true,
+					Type:             protocol.PacketType0RTT,
+					DestConnectionID: sess.srcConnID,
+					Length:           2,
+					Version:          sess.version,
+				},
+				PacketNumberLen: protocol.PacketNumberLen2,
+			}
+			packet := getPacket(hdr, nil)
+			tracer.EXPECT().DroppedPacket(logging.PacketType0RTT, protocol.ByteCount(len(packet.data)), logging.PacketDropUnexpectedPacket)
+			Expect(sess.handlePacketImpl(packet)).To(BeFalse())
+		})
+
 		It("ignores packets with a different source connection ID", func() {
 			hdr1 := &wire.ExtendedHeader{
 				Header: wire.Header{
@@ -622,7 +975,7 @@ var _ = Describe("Session", func() {
 			Expect(sess.srcConnID).ToNot(Equal(hdr2.SrcConnectionID))
 			// Send one packet, which might change the connection ID.
 			// only EXPECT one call to the unpacker
-			unpacker.EXPECT().Unpack(gomock.Any(), gomock.Any()).Return(&unpackedPacket{
+			unpacker.EXPECT().Unpack(gomock.Any(), gomock.Any(), gomock.Any()).Return(&unpackedPacket{
 				encryptionLevel: protocol.Encryption1RTT,
 				hdr:             hdr1,
 				data:            []byte{0}, // one PADDING frame
@@ -645,29 +998,187 @@ var _ = Describe("Session", func() {
 				PacketNumberLen: protocol.PacketNumberLen1,
 				PacketNumber:    1,
 			}
-			unpacker.EXPECT().Unpack(gomock.Any(), gomock.Any()).Return(nil, handshake.ErrOpenerNotYetAvailable)
+			unpacker.EXPECT().Unpack(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, handshake.ErrOpenerNotYetAvailable)
 			packet := getPacket(hdr, nil)
 			Expect(sess.handlePacketImpl(packet)).To(BeFalse())
 			Expect(sess.undecryptablePackets).To(Equal([]*receivedPacket{packet}))
 		})
 
+		It("traces queued undecryptable packets", func() {
+			tracer := mocklogging.NewMockConnectionTracer(mockCtrl)
+			sess.tracer = tracer
+			hdr := &wire.ExtendedHeader{
+				Header: wire.Header{
+					IsLongHeader:     true,
+					Type:             protocol.PacketTypeHandshake,
+					DestConnectionID: sess.destConnID,
+					SrcConnectionID:  sess.srcConnID,
+					Length:           1,
+					Version:          sess.version,
+				},
+				PacketNumberLen: protocol.PacketNumberLen1,
+				PacketNumber:    1,
+			}
+			unpacker.EXPECT().Unpack(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, handshake.ErrOpenerNotYetAvailable)
+			tracer.EXPECT().BufferedPacket(logging.PacketTypeHandshake)
+			Expect(sess.handlePacketImpl(getPacket(hdr, nil))).To(BeFalse())
+		})
+
 		Context("updating the remote address", func() {
-			It("doesn't support connection migration", func() {
-				unpacker.EXPECT().Unpack(gomock.Any(), gomock.Any()).Return(&unpackedPacket{
+			var (
+				sph     *mockackhandler.MockSentPacketHandler
+				newAddr *net.UDPAddr
+			)
+
+			BeforeEach(func() {
+				sph = mockackhandler.NewMockSentPacketHandler(mockCtrl)
+				sess.sentPacketHandler = sph
+				sess.handshakeComplete = true
+				newAddr = &net.UDPAddr{IP: net.IPv4(192, 168, 0, 100), Port: 1337}
+			})
+
+			receivePacket := func(pn protocol.PacketNumber, frame wire.Frame, remoteAddr net.Addr) {
+				buf := &bytes.Buffer{}
+				Expect(frame.Write(buf, sess.version)).To(Succeed())
+				unpacker.EXPECT().Unpack(gomock.Any(), gomock.Any(), gomock.Any()).Return(&unpackedPacket{
+					packetNumber:    pn,
 					encryptionLevel: protocol.Encryption1RTT,
-					hdr:             &wire.ExtendedHeader{},
-					data:            []byte{0}, // one PADDING frame
+					hdr:             &wire.ExtendedHeader{PacketNumber: pn},
+					data:            buf.Bytes(),
 				}, nil)
-				origAddr := sess.conn.(*mockConnection).remoteAddr
-				remoteIP := &net.IPAddr{IP: net.IPv4(192, 168, 0, 100)}
-				Expect(origAddr).ToNot(Equal(remoteIP))
 				packet := getPacket(&wire.ExtendedHeader{
 					Header:          wire.Header{DestConnectionID: sess.srcConnID},
+					PacketNumber:    pn,
 					PacketNumberLen: protocol.PacketNumberLen1,
 				}, nil)
-				packet.remoteAddr = remoteIP
-				Expect(sess.handlePacketImpl(packet)).To(BeTrue())
-				Expect(sess.conn.(*mockConnection).remoteAddr).To(Equal(origAddr))
+				packet.remoteAddr = remoteAddr
+				ExpectWithOffset(1, sess.handlePacketImpl(packet)).To(BeTrue())
+			}
+
+			getPathChallenge := func() *wire.PathChallengeFrame {
+				frames, _ := sess.framer.AppendControlFrames(nil, 1000)
+				ExpectWithOffset(1, frames).To(HaveLen(1))
+				ExpectWithOffset(1, frames[0]).To(BeAssignableToTypeOf(&wire.PathChallengeFrame{}))
+				return frames[0].(*wire.PathChallengeFrame)
+			}
+
+			It("migrates when receiving a non-probing packet from a new address", func() {
+				origAddr := mconn.RemoteAddr()
+				sph.EXPECT().OnConnectionMigration()
+				sph.EXPECT().ECNMode().AnyTimes()
+				receivePacket(10, &wire.PingFrame{}, newAddr)
+				Expect(mconn.RemoteAddr()).To(Equal(newAddr))
+				challenge := getPathChallenge()
+				Expect(sess.pathValidation.prevAddr).To(Equal(origAddr))
+				// receive the PATH_RESPONSE
+				receivePacket(11, &wire.PathResponseFrame{Data: challenge.Data}, newAddr)
+				Expect(sess.pathValidation).To(BeNil())
+				Expect(mconn.RemoteAddr()).To(Equal(newAddr))
+			})
+
+			It("reverts to the previous address if path validation fails", func() {
+				origAddr := mconn.RemoteAddr()
+				sph.EXPECT().OnConnectionMigration().Times(2)
+				sph.EXPECT().ECNMode().AnyTimes()
+				receivePacket(10, &wire.PingFrame{}, newAddr)
+				Expect(mconn.RemoteAddr()).To(Equal(newAddr))
+				sess.failPathValidation()
+				Expect(mconn.RemoteAddr()).To(Equal(origAddr))
+				Expect(sess.pathValidation).To(BeNil())
+			})
+
+			It("reverts to the last validated address, if the peer migrates again during path validation", func() {
+				origAddr := mconn.RemoteAddr()
+				sph.EXPECT().OnConnectionMigration().Times(3)
+				sph.EXPECT().ECNMode().AnyTimes()
+				receivePacket(10, &wire.PingFrame{}, newAddr)
+				otherAddr := &net.UDPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 4242}
+				receivePacket(11, &wire.PingFrame{}, otherAddr)
+				Expect(mconn.RemoteAddr()).To(Equal(otherAddr))
+				sess.failPathValidation()
+				Expect(mconn.RemoteAddr()).To(Equal(origAddr))
+			})
+
+			It("resets the path state when reverting to the previous address", func() {
+				mtuDiscoverer := NewMockMtuDiscoverer(mockCtrl)
+				sess.mtuDiscoverer = mtuDiscoverer
+				sph.EXPECT().OnConnectionMigration()
+				sph.EXPECT().ECNMode().Return(protocol.ECT0)
+				mtuDiscoverer.EXPECT().Reset(gomock.Any())
+				receivePacket(10, &wire.PingFrame{}, newAddr)
+				Expect(mconn.ecnMarking).To(BeTrue())
+				// ECN validation failed on the new path
+				sph.EXPECT().OnConnectionMigration()
+				sph.EXPECT().ECNMode().Return(protocol.ECT0)
+				mtuDiscoverer.EXPECT().Reset(gomock.Any())
+				mconn.ecnMarking = false
+				sess.failPathValidation()
+				Expect(mconn.ecnMarking).To(BeTrue())
+			})
+
+			It("limits the amount of data sent to the new address until it is validated", func() {
+				sph.EXPECT().OnConnectionMigration()
+				sph.EXPECT().ECNMode().AnyTimes()
+				Expect(sess.isAmplificationLimited()).To(BeFalse())
+				receivePacket(10, &wire.PingFrame{}, newAddr)
+				challenge := getPathChallenge()
+				received := sess.pathValidation.bytesReceived
+				Expect(received).ToNot(BeZero())
+				Expect(sess.isAmplificationLimited()).To(BeFalse())
+				sess.onPackedPacketSent(&packedPacket{
+					header: &wire.ExtendedHeader{},
+					raw:    make([]byte, 3*received-1),
+				})
+				Expect(sess.isAmplificationLimited()).To(BeFalse())
+				sess.onPackedPacketSent(&packedPacket{
+					header: &wire.ExtendedHeader{},
+					raw:    make([]byte, 1),
+				})
+				Expect(sess.isAmplificationLimited()).To(BeTrue())
+				// receiving more data from the new address allows sending more
+				receivePacket(11, &wire.PingFrame{}, newAddr)
+				Expect(sess.pathValidation.bytesReceived).To(Equal(2 * received))
+				Expect(sess.isAmplificationLimited()).To(BeFalse())
+				sess.onPackedPacketSent(&packedPacket{
+					header: &wire.ExtendedHeader{},
+					raw:    make([]byte, 10000),
+				})
+				Expect(sess.isAmplificationLimited()).To(BeTrue())
+				// receive the PATH_RESPONSE
+				receivePacket(12, &wire.PathResponseFrame{Data: challenge.Data}, newAddr)
+				Expect(sess.isAmplificationLimited()).To(BeFalse())
+			})
+
+			It("doesn't send packets when it is amplification limited", func() {
+				sph.EXPECT().OnConnectionMigration()
+				sph.EXPECT().ECNMode().AnyTimes()
+				receivePacket(10, &wire.PingFrame{}, newAddr)
+				sess.pathValidation.bytesSent = 3 * sess.pathValidation.bytesReceived
+				sph.EXPECT().SendMode().Return(ackhandler.SendAny)
+				sph.EXPECT().ShouldSendNumPackets().Return(1000)
+				Expect(sess.sendPackets()).To(Succeed())
+				Expect(mconn.written).To(BeEmpty())
+			})
+
+			It("doesn't migrate when receiving a packet that only contains probing frames", func() {
+				origAddr := mconn.RemoteAddr()
+				receivePacket(10, &wire.NewConnectionIDFrame{SequenceNumber: 1, ConnectionID: protocol.ConnectionID{1, 2, 3, 4}}, newAddr)
+				Expect(mconn.RemoteAddr()).To(Equal(origAddr))
+				Expect(sess.pathValidation).To(BeNil())
+			})
+
+			It("doesn't migrate when receiving a reordered packet from a new address", func() {
+				origAddr := mconn.RemoteAddr()
+				receivePacket(10, &wire.PingFrame{}, origAddr)
+				receivePacket(9, &wire.PingFrame{}, newAddr)
+				Expect(mconn.RemoteAddr()).To(Equal(origAddr))
+			})
+
+			It("doesn't migrate before the handshake completes", func() {
+				sess.handshakeComplete = false
+				origAddr := mconn.RemoteAddr()
+				receivePacket(10, &wire.PingFrame{}, newAddr)
+				Expect(mconn.RemoteAddr()).To(Equal(origAddr))
 			})
 		})
 
@@ -693,7 +1204,7 @@ var _ = Describe("Session", func() {
 
 			It("cuts packets to the right length", func() {
 				hdrLen, packet := getPacketWithLength(sess.srcConnID, 456)
-				unpacker.EXPECT().Unpack(gomock.Any(), gomock.Any()).DoAndReturn(func(_ *wire.Header, data []byte) (*unpackedPacket, error) {
+				unpacker.EXPECT().Unpack(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(func(_ *wire.Header, _ time.Time, data []byte) (*unpackedPacket, error) {
 					Expect(data).To(HaveLen(hdrLen + 456 - 3))
 					return &unpackedPacket{
 						encryptionLevel: protocol.EncryptionHandshake,
@@ -705,7 +1216,7 @@ var _ = Describe("Session", func() {
 
 			It("handles coalesced packets", func() {
 				hdrLen1, packet1 := getPacketWithLength(sess.srcConnID, 456)
-				unpacker.EXPECT().Unpack(gomock.Any(), gomock.Any()).DoAndReturn(func(_ *wire.Header, data []byte) (*unpackedPacket, error) {
+				unpacker.EXPECT().Unpack(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(func(_ *wire.Header, _ time.Time, data []byte) (*unpackedPacket, error) {
 					Expect(data).To(HaveLen(hdrLen1 + 456 - 3))
 					return &unpackedPacket{
 						encryptionLevel: protocol.EncryptionHandshake,
@@ -713,7 +1224,7 @@ var _ = Describe("Session", func() {
 					}, nil
 				})
 				hdrLen2, packet2 := getPacketWithLength(sess.srcConnID, 123)
-				unpacker.EXPECT().Unpack(gomock.Any(), gomock.Any()).DoAndReturn(func(_ *wire.Header, data []byte) (*unpackedPacket, error) {
+				unpacker.EXPECT().Unpack(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(func(_ *wire.Header, _ time.Time, data []byte) (*unpackedPacket, error) {
 					Expect(data).To(HaveLen(hdrLen2 + 123 - 3))
 					return &unpackedPacket{
 						encryptionLevel: protocol.EncryptionHandshake,
@@ -728,8 +1239,8 @@ var _ = Describe("Session", func() {
 				hdrLen1, packet1 := getPacketWithLength(sess.srcConnID, 456)
 				hdrLen2, packet2 := getPacketWithLength(sess.srcConnID, 123)
 				gomock.InOrder(
-					unpacker.EXPECT().Unpack(gomock.Any(), gomock.Any()).Return(nil, handshake.ErrOpenerNotYetAvailable),
-					unpacker.EXPECT().Unpack(gomock.Any(), gomock.Any()).DoAndReturn(func(_ *wire.Header, data []byte) (*unpackedPacket, error) {
+					unpacker.EXPECT().Unpack(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, handshake.ErrOpenerNotYetAvailable),
+					unpacker.EXPECT().Unpack(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(func(_ *wire.Header, _ time.Time, data []byte) (*unpackedPacket, error) {
 						Expect(data).To(HaveLen(hdrLen2 + 123 - 3))
 						return &unpackedPacket{
 							encryptionLevel: protocol.EncryptionHandshake,
@@ -748,7 +1259,7 @@ var _ = Describe("Session", func() {
 				wrongConnID := protocol.ConnectionID{0xde, 0xad, 0xbe, 0xef}
 				Expect(sess.srcConnID).ToNot(Equal(wrongConnID))
 				hdrLen1, packet1 := getPacketWithLength(sess.srcConnID, 456)
-				unpacker.EXPECT().Unpack(gomock.Any(), gomock.Any()).DoAndReturn(func(_ *wire.Header, data []byte) (*unpackedPacket, error) {
+				unpacker.EXPECT().Unpack(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(func(_ *wire.Header, _ time.Time, data []byte) (*unpackedPacket, error) {
 					Expect(data).To(HaveLen(hdrLen1 + 456 - 3))
 					return &unpackedPacket{
 						encryptionLevel: protocol.EncryptionHandshake,
@@ -777,7 +1288,19 @@ var _ = Describe("Session", func() {
 
 		It("sends packets", func() {
 			packer.EXPECT().PackPacket().Return(getPacket(1), nil)
-			Expect(sess.receivedPacketHandler.ReceivedPacket(0x035e, protocol.Encryption1RTT, time.Now(), true)).To(Succeed())
+			Expect(sess.receivedPacketHandler.ReceivedPacket(0x035e, protocol.ECNNon, protocol.Encryption1RTT, time.Now(), true)).To(Succeed())
+			sent, err := sess.sendPacket()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(sent).To(BeTrue())
+		})
+
+		It("traces sent packets", func() {
+			tracer := mocklogging.NewMockConnectionTracer(mockCtrl)
+			sess.tracer = tracer
+			packet := getPacket(1)
+			packet.frames = []wire.Frame{&wire.PingFrame{}}
+			packer.EXPECT().PackPacket().Return(packet, nil)
+			tracer.EXPECT().SentPacket(packet.header, protocol.ByteCount(6), []logging.Frame{&wire.PingFrame{}})
 			sent, err := sess.sendPacket()
 			Expect(err).NotTo(HaveOccurred())
 			Expect(sent).To(BeTrue())
@@ -785,7 +1308,7 @@ var _ = Describe("Session", func() {
 
 		It("doesn't send packets if there's nothing to send", func() {
 			packer.EXPECT().PackPacket().Return(getPacket(2), nil)
-			Expect(sess.receivedPacketHandler.ReceivedPacket(0x035e, protocol.Encryption1RTT, time.Now(), true)).To(Succeed())
+			Expect(sess.receivedPacketHandler.ReceivedPacket(0x035e, protocol.ECNNon, protocol.Encryption1RTT, time.Now(), true)).To(Succeed())
 			sent, err := sess.sendPacket()
 			Expect(err).NotTo(HaveOccurred())
 			Expect(sent).To(BeTrue())
@@ -794,6 +1317,7 @@ var _ = Describe("Session", func() {
 		It("sends ACK only packets", func() {
 			sph := mockackhandler.NewMockSentPacketHandler(mockCtrl)
 			sph.EXPECT().GetAlarmTimeout().AnyTimes()
+			sph.EXPECT().GetStats().AnyTimes()
 			sph.EXPECT().SendMode().Return(ackhandler.SendAck)
 			sph.EXPECT().ShouldSendNumPackets().Return(1000)
 			packer.EXPECT().MaybePackAckPacket()
@@ -895,12 +1419,134 @@ var _ = Describe("Session", func() {
 			Expect(err).ToNot(HaveOccurred())
 		})
 
+		It("writes all packets sent in one run of the send loop in a single batch", func() {
+			sph := mockackhandler.NewMockSentPacketHandler(mockCtrl)
+			sph.EXPECT().SendMode().Return(ackhandler.SendAny).Times(3)
+			sph.EXPECT().ShouldSendNumPackets().Return(3)
+			sph.EXPECT().SentPacket(gomock.Any()).Times(3)
+			sph.EXPECT().TimeUntilSend()
+			sess.sentPacketHandler = sph
+			packer.EXPECT().PackPacket().Return(getPacket(10), nil)
+			packer.EXPECT().PackPacket().Return(getPacket(11), nil)
+			packer.EXPECT().PackPacket().Return(getPacket(12), nil)
+			Expect(sess.sendPackets()).To(Succeed())
+			Expect(mconn.batches).To(Receive(Equal(3)))
+			Expect(mconn.written).To(HaveLen(3))
+		})
+
+		It("writes the packets queued before an error occurred", func() {
+			testErr := errors.New("packing error")
+			sph := mockackhandler.NewMockSentPacketHandler(mockCtrl)
+			sph.EXPECT().SendMode().Return(ackhandler.SendAny).Times(2)
+			sph.EXPECT().ShouldSendNumPackets().Return(3)
+			sph.EXPECT().SentPacket(gomock.Any())
+			sess.sentPacketHandler = sph
+			packer.EXPECT().PackPacket().Return(getPacket(10), nil)
+			packer.EXPECT().PackPacket().Return(nil, testErr)
+			Expect(sess.sendPackets()).To(MatchError(testErr))
+			Expect(mconn.batches).To(Receive(Equal(1)))
+			Expect(mconn.written).To(HaveLen(1))
+		})
+
+		Context("Path MTU Discovery", func() {
+			var mtuDiscoverer *MockMtuDiscoverer
+
+			BeforeEach(func() {
+				mtuDiscoverer = NewMockMtuDiscoverer(mockCtrl)
+				sess.mtuDiscoverer = mtuDiscoverer
+				sess.handshakeComplete = true
+			})
+
+			It("sends MTU probe packets", func() {
+				sph := mockackhandler.NewMockSentPacketHandler(mockCtrl)
+				sph.EXPECT().SendMode().Return(ackhandler.SendAny)
+				sph.EXPECT().ShouldSendNumPackets().Return(1)
+				sph.EXPECT().TimeUntilSend()
+				sess.sentPacketHandler = sph
+				var acked []bool
+				mtuDiscoverer.EXPECT().ShouldSendProbe(gomock.Any()).Return(true)
+				mtuDiscoverer.EXPECT().GetProbe(gomock.Any()).Return(protocol.ByteCount(1500), func(a bool) { acked = append(acked, a) })
+				probe := getPacket(1)
+				probe.isMTUProbePacket = true
+				packer.EXPECT().PackMTUProbePacket(protocol.ByteCount(1500)).Return(probe, nil)
+				sph.EXPECT().SentPacket(gomock.Any()).Do(func(p *ackhandler.Packet) {
+					Expect(p.IsPathMTUProbePacket).To(BeTrue())
+					p.AckCallback(true)
+				})
+				Expect(sess.sendPackets()).To(Succeed())
+				Expect(acked).To(Equal([]bool{true}))
+				Expect(mconn.written).To(HaveLen(1))
+			})
+
+			It("writes the packets queued before the MTU probe packet first", func() {
+				sph := mockackhandler.NewMockSentPacketHandler(mockCtrl)
+				sph.EXPECT().SendMode().Return(ackhandler.SendAny).Times(2)
+				sph.EXPECT().ShouldSendNumPackets().Return(2)
+				sph.EXPECT().SentPacket(gomock.Any()).Times(2)
+				sph.EXPECT().TimeUntilSend()
+				sess.sentPacketHandler = sph
+				mtuDiscoverer.EXPECT().TrackPacket(gomock.Any(), gomock.Any())
+				gomock.InOrder(
+					mtuDiscoverer.EXPECT().ShouldSendProbe(gomock.Any()).Return(false),
+					mtuDiscoverer.EXPECT().ShouldSendProbe(gomock.Any()).Return(true),
+				)
+				mtuDiscoverer.EXPECT().GetProbe(gomock.Any()).Return(protocol.ByteCount(1500), func(bool) {})
+				packer.EXPECT().PackPacket().Return(getPacket(10), nil)
+				probe := getPacket(11)
+				probe.raw = append(probe.buffer.Slice[:0], []byte("probe")...)
+				probe.isMTUProbePacket = true
+				packer.EXPECT().PackMTUProbePacket(protocol.ByteCount(1500)).Return(probe, nil)
+				Expect(sess.sendPackets()).To(Succeed())
+				Expect(mconn.batches).To(Receive(Equal(1)))
+				Expect(mconn.written).To(Receive(Equal([]byte("foobar"))))
+				Expect(mconn.written).To(Receive(Equal([]byte("probe"))))
+				Expect(mconn.batches).To(BeEmpty())
+			})
+
+			It("doesn't send MTU probe packets before the handshake completes", func() {
+				sess.handshakeComplete = false
+				sph := mockackhandler.NewMockSentPacketHandler(mockCtrl)
+				sph.EXPECT().SendMode().Return(ackhandler.SendAny)
+				sph.EXPECT().ShouldSendNumPackets().Return(1)
+				sess.sentPacketHandler = sph
+				packer.EXPECT().PackPacket()
+				Expect(sess.sendPackets()).To(Succeed())
+			})
+
+			It("tracks the packets sent", func() {
+				var acked []bool
+				mtuDiscoverer.EXPECT().TrackPacket(protocol.ByteCount(6), gomock.Any()).Return(func(a bool) { acked = append(acked, a) })
+				sph := mockackhandler.NewMockSentPacketHandler(mockCtrl)
+				sph.EXPECT().SentPacket(gomock.Any()).Do(func(p *ackhandler.Packet) { p.AckCallback(false) })
+				sess.sentPacketHandler = sph
+				packer.EXPECT().PackPacket().Return(getPacket(1), nil)
+				sent, err := sess.sendPacket()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(sent).To(BeTrue())
+				Expect(acked).To(Equal([]bool{false}))
+			})
+
+			It("tells the MTU discoverer about probe timeouts", func() {
+				packetToRetransmit := &ackhandler.Packet{PacketNumber: 0x42}
+				sph := mockackhandler.NewMockSentPacketHandler(mockCtrl)
+				sph.EXPECT().DequeueProbePacket().Return(packetToRetransmit, nil)
+				sph.EXPECT().SentPacketsAsRetransmission(gomock.Any(), protocol.PacketNumber(0x42))
+				sess.sentPacketHandler = sph
+				gomock.InOrder(
+					mtuDiscoverer.EXPECT().OnProbeTimeout(gomock.Any()),
+					packer.EXPECT().PackRetransmission(packetToRetransmit).Return([]*packedPacket{getPacket(123)}, nil),
+				)
+				Expect(sess.sendProbePacket()).To(Succeed())
+			})
+		})
+
 		Context("packet pacing", func() {
 			var sph *mockackhandler.MockSentPacketHandler
 
 			BeforeEach(func() {
 				sph = mockackhandler.NewMockSentPacketHandler(mockCtrl)
 				sph.EXPECT().GetAlarmTimeout().AnyTimes()
+				sph.EXPECT().GetStats().AnyTimes()
 				sph.EXPECT().DequeuePacketForRetransmission().AnyTimes()
 				sess.sentPacketHandler = sph
 				streamManager.EXPECT().CloseWithError(gomock.Any())
@@ -1041,6 +1687,7 @@ var _ = Describe("Session", func() {
 			It("sends when scheduleSending is called", func() {
 				sph := mockackhandler.NewMockSentPacketHandler(mockCtrl)
 				sph.EXPECT().GetAlarmTimeout().AnyTimes()
+				sph.EXPECT().GetStats().AnyTimes()
 				sph.EXPECT().TimeUntilSend().AnyTimes()
 				sph.EXPECT().SendMode().Return(ackhandler.SendAny).AnyTimes()
 				sph.EXPECT().ShouldSendNumPackets().AnyTimes().Return(1)
@@ -1071,6 +1718,7 @@ var _ = Describe("Session", func() {
 				sph.EXPECT().TimeUntilSend().Return(time.Now())
 				sph.EXPECT().TimeUntilSend().Return(time.Now().Add(time.Hour))
 				sph.EXPECT().GetAlarmTimeout().AnyTimes()
+				sph.EXPECT().GetStats().AnyTimes()
 				sph.EXPECT().SendMode().Return(ackhandler.SendAny).AnyTimes()
 				sph.EXPECT().ShouldSendNumPackets().Return(1)
 				sph.EXPECT().SentPacket(gomock.Any()).Do(func(p *ackhandler.Packet) {
@@ -1115,28 +1763,120 @@ var _ = Describe("Session", func() {
 		Eventually(sess.Context().Done()).Should(BeClosed())
 	})
 
+	It("counts failed handshakes", func() {
+		metrics := mocklogging.NewMockMetrics(mockCtrl)
+		sess.config.Metrics = metrics
+		testErr := errors.New("crypto setup error")
+		streamManager.EXPECT().CloseWithError(gomock.Any())
+		sessionRunner.EXPECT().Retire(gomock.Any())
+		cryptoSetup.EXPECT().Close()
+		packer.EXPECT().PackConnectionClose(gomock.Any()).Return(&packedPacket{}, nil)
+		gomock.InOrder(
+			metrics.EXPECT().StartedHandshake(protocol.PerspectiveServer),
+			metrics.EXPECT().FailedHandshake(protocol.PerspectiveServer),
+		)
+		done := make(chan struct{})
+		go func() {
+			defer GinkgoRecover()
+			cryptoSetup.EXPECT().RunHandshake().Return(testErr)
+			Expect(sess.run()).To(MatchError(testErr))
+			close(done)
+		}()
+		Eventually(done).Should(BeClosed())
+	})
+
+	It("counts completed handshakes", func() {
+		metrics := mocklogging.NewMockMetrics(mockCtrl)
+		sess.config.Metrics = metrics
+		packer.EXPECT().PackPacket().AnyTimes()
+		sessionRunner.EXPECT().Add(gomock.Any(), sess).Times(protocol.MaxActiveConnectionIDs - 1)
+		sessionRunner.EXPECT().GetStatelessResetToken(gomock.Any()).Times(protocol.MaxActiveConnectionIDs - 1)
+		sessionRunner.EXPECT().OnHandshakeComplete(gomock.Any())
+		cryptoSetup.EXPECT().SetHandshakeConfirmed()
+		metrics.EXPECT().StartedHandshake(protocol.PerspectiveServer)
+		completed := make(chan struct{})
+		metrics.EXPECT().CompletedHandshake(protocol.PerspectiveServer, gomock.Any()).Do(func(_ protocol.Perspective, d time.Duration) {
+			Expect(d).To(BeNumerically(">", 0))
+			close(completed)
+		})
+		done := make(chan struct{})
+		go func() {
+			defer GinkgoRecover()
+			cryptoSetup.EXPECT().RunHandshake()
+			sess.run()
+			close(done)
+		}()
+		Eventually(completed).Should(BeClosed())
+		// make sure the go routine returns
+		sessionRunner.EXPECT().Retire(gomock.Any()).Times(protocol.MaxActiveConnectionIDs)
+		streamManager.EXPECT().CloseWithError(gomock.Any())
+		packer.EXPECT().PackConnectionClose(gomock.Any()).Return(&packedPacket{}, nil)
+		cryptoSetup.EXPECT().Close()
+		Expect(sess.Close()).To(Succeed())
+		Eventually(done).Should(BeClosed())
+	})
+
 	It("calls the onHandshakeComplete callback when the handshake completes", func() {
 		packer.EXPECT().PackPacket().AnyTimes()
+		sessionRunner.EXPECT().Add(gomock.Any(), sess).Times(protocol.MaxActiveConnectionIDs - 1)
+		sessionRunner.EXPECT().GetStatelessResetToken(gomock.Any()).Times(protocol.MaxActiveConnectionIDs - 1)
 		go func() {
 			defer GinkgoRecover()
 			sessionRunner.EXPECT().OnHandshakeComplete(gomock.Any())
+			cryptoSetup.EXPECT().SetHandshakeConfirmed()
 			cryptoSetup.EXPECT().RunHandshake()
 			sess.run()
 		}()
 		Consistently(sess.Context().Done()).ShouldNot(BeClosed())
 		// make sure the go routine returns
-		sessionRunner.EXPECT().Retire(gomock.Any())
+		sessionRunner.EXPECT().Retire(gomock.Any()).Times(protocol.MaxActiveConnectionIDs)
+		streamManager.EXPECT().CloseWithError(gomock.Any())
+		packer.EXPECT().PackConnectionClose(gomock.Any()).Return(&packedPacket{}, nil)
+		cryptoSetup.EXPECT().Close()
+		Expect(sess.Close()).To(Succeed())
+		Eventually(sess.Context().Done()).Should(BeClosed())
+	})
+
+	It("issues new connection IDs when the handshake completes", func() {
+		packer.EXPECT().PackPacket().AnyTimes()
+		var connIDs []protocol.ConnectionID
+		sessionRunner.EXPECT().Add(gomock.Any(), sess).Do(func(c protocol.ConnectionID, _ packetHandler) {
+			connIDs = append(connIDs, c)
+		}).Times(protocol.MaxActiveConnectionIDs - 1)
+		sessionRunner.EXPECT().GetStatelessResetToken(gomock.Any()).Times(protocol.MaxActiveConnectionIDs - 1)
+		sessionRunner.EXPECT().OnHandshakeComplete(gomock.Any())
+		cryptoSetup.EXPECT().SetHandshakeConfirmed()
+		cryptoSetup.EXPECT().RunHandshake()
+		go func() {
+			defer GinkgoRecover()
+			sess.run()
+		}()
+		Consistently(sess.Context().Done()).ShouldNot(BeClosed())
+		// make sure the go routine returns
+		var retired []protocol.ConnectionID
+		sessionRunner.EXPECT().Retire(gomock.Any()).Do(func(c protocol.ConnectionID) {
+			retired = append(retired, c)
+		}).Times(protocol.MaxActiveConnectionIDs)
 		streamManager.EXPECT().CloseWithError(gomock.Any())
 		packer.EXPECT().PackConnectionClose(gomock.Any()).Return(&packedPacket{}, nil)
 		cryptoSetup.EXPECT().Close()
 		Expect(sess.Close()).To(Succeed())
 		Eventually(sess.Context().Done()).Should(BeClosed())
+		Expect(connIDs).To(HaveLen(protocol.MaxActiveConnectionIDs - 1))
+		for _, c := range connIDs {
+			Expect(c.Len()).To(Equal(sess.srcConnID.Len()))
+			Expect(retired).To(ContainElement(c))
+		}
+		Expect(retired).To(ContainElement(sess.srcConnID))
 	})
 
 	It("sends a forward-secure packet when the handshake completes", func() {
 		done := make(chan struct{})
+		sessionRunner.EXPECT().Add(gomock.Any(), sess).Times(protocol.MaxActiveConnectionIDs - 1)
+		sessionRunner.EXPECT().GetStatelessResetToken(gomock.Any()).Times(protocol.MaxActiveConnectionIDs - 1)
 		gomock.InOrder(
 			sessionRunner.EXPECT().OnHandshakeComplete(gomock.Any()),
+			cryptoSetup.EXPECT().SetHandshakeConfirmed(),
 			packer.EXPECT().PackPacket().DoAndReturn(func() (*packedPacket, error) {
 				defer close(done)
 				return &packedPacket{
@@ -1154,7 +1894,7 @@ var _ = Describe("Session", func() {
 		Eventually(done).Should(BeClosed())
 		//make sure the go routine returns
 		streamManager.EXPECT().CloseWithError(gomock.Any())
-		sessionRunner.EXPECT().Retire(gomock.Any())
+		sessionRunner.EXPECT().Retire(gomock.Any()).Times(protocol.MaxActiveConnectionIDs)
 		packer.EXPECT().PackConnectionClose(gomock.Any()).Return(&packedPacket{}, nil)
 		cryptoSetup.EXPECT().Close()
 		Expect(sess.Close()).To(Succeed())
@@ -1222,12 +1962,20 @@ var _ = Describe("Session", func() {
 				IdleTimeout:                   90 * time.Second,
 				InitialMaxStreamDataBidiLocal: 0x5000,
 				InitialMaxData:                0x5000,
-				// marshaling always sets it to this value
-				MaxPacketSize: protocol.MaxReceivePacketSize,
+				// marshaling sets it to this value, if it is not set
+				MaxPacketSize:        protocol.MaxReceivePacketSize,
+				MaxDatagramFrameSize: 1000,
 			}
+			tracer := mocklogging.NewMockConnectionTracer(mockCtrl)
+			sess.tracer = tracer
+			tracer.EXPECT().ReceivedTransportParameters(params)
+			tracer.EXPECT().SentPacket(gomock.Any(), gomock.Any(), gomock.Any())
+			tracer.EXPECT().ClosedConnection(gomock.Any(), gomock.Any())
+			tracer.EXPECT().Close()
 			streamManager.EXPECT().UpdateLimits(params)
 			packer.EXPECT().HandleTransportParameters(params)
 			sess.processTransportParameters(params.Marshal())
+			Expect(sess.datagramQueue.peerMaxSize).To(Equal(protocol.ByteCount(1000)))
 			// make the go routine return
 			streamManager.EXPECT().CloseWithError(gomock.Any())
 			sessionRunner.EXPECT().Retire(gomock.Any())
@@ -1238,6 +1986,52 @@ var _ = Describe("Session", func() {
 		})
 	})
 
+	Context("sending and receiving messages", func() {
+		It("doesn't send messages if DATAGRAM support is disabled", func() {
+			Expect(sess.SendMessage([]byte("foobar"))).To(MatchError("DATAGRAM support disabled"))
+		})
+
+		It("doesn't receive messages if DATAGRAM support is disabled", func() {
+			_, err := sess.ReceiveMessage()
+			Expect(err).To(MatchError("DATAGRAM support disabled"))
+		})
+
+		It("queues messages for sending", func() {
+			sess.config.EnableDatagrams = true
+			sess.datagramQueue.SetPeerMaxFrameSize(protocol.MaxDatagramFrameSize)
+			Expect(sess.SendMessageWithCallback([]byte("foobar"), func(bool) {})).To(Succeed())
+			f := sess.datagramQueue.Peek()
+			Expect(f).ToNot(BeNil())
+			Expect(f.Data).To(Equal([]byte("foobar")))
+			Expect(sess.datagramQueue.Pop()).ToNot(BeNil())
+		})
+
+		It("unblocks ReceiveMessage when the session is closed", func() {
+			sess.config.EnableDatagrams = true
+			errChan := make(chan error, 1)
+			go func() {
+				_, err := sess.ReceiveMessage()
+				errChan <- err
+			}()
+			go func() {
+				defer GinkgoRecover()
+				cryptoSetup.EXPECT().RunHandshake().Do(func() { <-sess.Context().Done() })
+				sess.run()
+			}()
+			Consistently(errChan).ShouldNot(Receive())
+			streamManager.EXPECT().CloseWithError(gomock.Any())
+			sessionRunner.EXPECT().Retire(gomock.Any())
+			packer.EXPECT().PackConnectionClose(gomock.Any()).Return(&packedPacket{}, nil)
+			cryptoSetup.EXPECT().Close()
+			sess.Close()
+			var err error
+			Eventually(errChan).Should(Receive(&err))
+			Expect(err).To(BeAssignableToTypeOf(&qerr.QuicError{}))
+			Expect(err.(*qerr.QuicError).ErrorCode).To(Equal(qerr.NoError))
+			Eventually(sess.Context().Done()).Should(BeClosed())
+		})
+	})
+
 	Context("keep-alives", func() {
 		// should be shorter than the local timeout for these tests
 		// otherwise we'd send a CONNECTION_CLOSE in the tests where we're testing that no PING is sent
@@ -1382,13 +2176,16 @@ var _ = Describe("Session", func() {
 
 		It("closes the session due to the idle timeout after handshake", func() {
 			packer.EXPECT().PackPacket().AnyTimes()
-			sessionRunner.EXPECT().Remove(gomock.Any())
+			sessionRunner.EXPECT().Add(gomock.Any(), sess).Times(protocol.MaxActiveConnectionIDs - 1)
+			sessionRunner.EXPECT().GetStatelessResetToken(gomock.Any()).Times(protocol.MaxActiveConnectionIDs - 1)
+			sessionRunner.EXPECT().Remove(gomock.Any()).Times(protocol.MaxActiveConnectionIDs)
 			cryptoSetup.EXPECT().Close()
 			sess.config.IdleTimeout = 0
 			done := make(chan struct{})
 			go func() {
 				defer GinkgoRecover()
 				sessionRunner.EXPECT().OnHandshakeComplete(sess)
+				cryptoSetup.EXPECT().SetHandshakeConfirmed()
 				cryptoSetup.EXPECT().RunHandshake()
 				err := sess.run()
 				nerr, ok := err.(net.Error)
@@ -1428,6 +2225,15 @@ var _ = Describe("Session", func() {
 		close(done)
 	}, 0.5)
 
+	It("counts packets that are dropped because too many packets are queued", func() {
+		metrics := mocklogging.NewMockMetrics(mockCtrl)
+		sess.config.Metrics = metrics
+		metrics.EXPECT().DroppedPacket(logging.PacketDropDOSPrevention).Times(10)
+		for i := protocol.PacketNumber(0); i < protocol.MaxSessionUnprocessedPackets+10; i++ {
+			sess.handlePacket(&receivedPacket{})
+		}
+	})
+
 	Context("getting streams", func() {
 		It("returns a new stream", func() {
 			mstr := NewMockStreamI(mockCtrl)
@@ -1502,6 +2308,96 @@ var _ = Describe("Session", func() {
 		})
 	})
 
+	Context("statistics", func() {
+		It("calculates the statistics", func() {
+			sess.rttStats.UpdateRTT(100*time.Millisecond, 0, time.Now())
+			sess.packetsSent = 10
+			sess.bytesSent = 12345
+			sess.packetsReceived = 7
+			sess.bytesReceived = 5432
+			stats := sess.getStats()
+			Expect(stats.SmoothedRTT).To(Equal(100 * time.Millisecond))
+			Expect(stats.MinRTT).To(Equal(100 * time.Millisecond))
+			Expect(stats.LatestRTT).To(Equal(100 * time.Millisecond))
+			Expect(stats.CongestionWindow).ToNot(BeZero())
+			Expect(stats.PacketsSent).To(BeEquivalentTo(10))
+			Expect(stats.BytesSent).To(BeEquivalentTo(12345))
+			Expect(stats.PacketsReceived).To(BeEquivalentTo(7))
+			Expect(stats.BytesReceived).To(BeEquivalentTo(5432))
+		})
+
+		It("gets the current statistics from the run loop", func() {
+			sph := mockackhandler.NewMockSentPacketHandler(mockCtrl)
+			sph.EXPECT().GetAlarmTimeout().AnyTimes()
+			sph.EXPECT().TimeUntilSend().AnyTimes()
+			gomock.InOrder(
+				sph.EXPECT().GetStats().Return(ackhandler.Stats{PTOCount: 1}),
+				sph.EXPECT().GetStats().Return(ackhandler.Stats{PTOCount: 2}),
+			)
+			sess.sentPacketHandler = sph
+			streamManager.EXPECT().NumStreams(protocol.StreamTypeBidi).Return(3).AnyTimes()
+			streamManager.EXPECT().NumStreams(protocol.StreamTypeUni).Return(2).AnyTimes()
+			done := make(chan struct{})
+			go func() {
+				defer GinkgoRecover()
+				cryptoSetup.EXPECT().RunHandshake().Do(func() { <-sess.Context().Done() })
+				sess.run()
+				close(done)
+			}()
+			stats := sess.Stats()
+			Expect(stats.PTOCount).To(BeEquivalentTo(1))
+			Expect(stats.OpenStreams).To(Equal(3))
+			Expect(stats.OpenUniStreams).To(Equal(2))
+			Expect(sess.Stats().PTOCount).To(BeEquivalentTo(2))
+			// make the go routine return
+			sph.EXPECT().GetStats().Return(ackhandler.Stats{PTOCount: 3})
+			streamManager.EXPECT().CloseWithError(gomock.Any())
+			packer.EXPECT().PackConnectionClose(gomock.Any()).Return(&packedPacket{}, nil)
+			sessionRunner.EXPECT().Retire(gomock.Any())
+			cryptoSetup.EXPECT().Close()
+			sess.Close()
+			Eventually(done).Should(BeClosed())
+			Expect(sess.Stats().PTOCount).To(BeEquivalentTo(3))
+		})
+
+		It("counts sent packets", func() {
+			packer.EXPECT().PackPacket().Return(&packedPacket{
+				raw:    []byte("foobar"),
+				buffer: getPacketBuffer(),
+				header: &wire.ExtendedHeader{PacketNumber: 1},
+			}, nil)
+			sent, err := sess.sendPacket()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(sent).To(BeTrue())
+			Expect(sess.packetsSent).To(BeEquivalentTo(1))
+			Expect(sess.bytesSent).To(BeEquivalentTo(6))
+		})
+
+		It("measures the time blocked by connection-level flow control", func() {
+			fc := mocks.NewMockConnectionFlowController(mockCtrl)
+			sess.connFlowController = fc
+			fc.EXPECT().IsNewlyBlocked().Return(true, protocol.ByteCount(1337))
+			packer.EXPECT().PackPacket()
+			_, err := sess.sendPacket()
+			Expect(err).ToNot(HaveOccurred())
+			time.Sleep(scaleDuration(10 * time.Millisecond))
+			// the time is also counted while we're still blocked
+			Expect(sess.getStats().FlowControlBlockedTime).To(BeNumerically(">=", scaleDuration(10*time.Millisecond)))
+			// a MAX_DATA frame that doesn't open the window
+			fc.EXPECT().UpdateSendWindow(protocol.ByteCount(1337))
+			fc.EXPECT().SendWindowSize().Return(protocol.ByteCount(0))
+			sess.handleMaxDataFrame(&wire.MaxDataFrame{ByteOffset: 1337})
+			Expect(sess.flowControlBlockedTime).To(BeZero())
+			fc.EXPECT().UpdateSendWindow(protocol.ByteCount(2000))
+			fc.EXPECT().SendWindowSize().Return(protocol.ByteCount(663))
+			sess.handleMaxDataFrame(&wire.MaxDataFrame{ByteOffset: 2000})
+			blockedTime := sess.flowControlBlockedTime
+			Expect(blockedTime).To(BeNumerically(">=", scaleDuration(10*time.Millisecond)))
+			time.Sleep(scaleDuration(10 * time.Millisecond))
+			Expect(sess.getStats().FlowControlBlockedTime).To(Equal(blockedTime))
+		})
+	})
+
 	It("returns the local address", func() {
 		addr := &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 1337}
 		mconn.localAddr = addr
@@ -1513,6 +2409,10 @@ var _ = Describe("Session", func() {
 		mconn.remoteAddr = addr
 		Expect(sess.RemoteAddr()).To(Equal(addr))
 	})
+
+	It("doesn't allow the server to migrate", func() {
+		Expect(sess.MigrateConn(newMockPacketConn())).To(MatchError("only clients can migrate"))
+	})
 })
 
 var _ = Describe("Client Session", func() {
@@ -1561,7 +2461,7 @@ var _ = Describe("Client Session", func() {
 
 	It("changes the connection ID when receiving the first packet from the server", func() {
 		unpacker := NewMockUnpacker(mockCtrl)
-		unpacker.EXPECT().Unpack(gomock.Any(), gomock.Any()).DoAndReturn(func(hdr *wire.Header, data []byte) (*unpackedPacket, error) {
+		unpacker.EXPECT().Unpack(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(func(hdr *wire.Header, _ time.Time, data []byte) (*unpackedPacket, error) {
 			return &unpackedPacket{
 				encryptionLevel: protocol.Encryption1RTT,
 				hdr:             &wire.ExtendedHeader{Header: *hdr},
@@ -1594,6 +2494,23 @@ var _ = Describe("Client Session", func() {
 		Eventually(sess.Context().Done()).Should(BeClosed())
 	})
 
+	It("confirms the handshake when the first 1-RTT packet is acknowledged", func() {
+		sph := mockackhandler.NewMockSentPacketHandler(mockCtrl)
+		sph.EXPECT().ReceivedAck(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(2)
+		sph.EXPECT().ECNMode().AnyTimes()
+		sph.EXPECT().GetLowestPacketNotConfirmedAcked().Times(2)
+		sess.sentPacketHandler = sph
+		ack := &wire.AckFrame{AckRanges: []wire.AckRange{{Smallest: 1, Largest: 5}}}
+		gomock.InOrder(
+			cryptoSetup.EXPECT().SetLargestAcked(protocol.PacketNumber(5)),
+			cryptoSetup.EXPECT().SetHandshakeConfirmed(),
+			cryptoSetup.EXPECT().SetLargestAcked(protocol.PacketNumber(7)),
+		)
+		Expect(sess.handleAckFrame(ack, 1, protocol.Encryption1RTT)).To(Succeed())
+		ack = &wire.AckFrame{AckRanges: []wire.AckRange{{Smallest: 1, Largest: 7}}}
+		Expect(sess.handleAckFrame(ack, 2, protocol.Encryption1RTT)).To(Succeed())
+	})
+
 	Context("handling Retry", func() {
 		var validRetryHdr *wire.ExtendedHeader
 
@@ -1618,11 +2535,33 @@ var _ = Describe("Client Session", func() {
 			Expect(sess.handlePacketImpl(getPacket(validRetryHdr, nil))).To(BeTrue())
 		})
 
+		It("traces Retry packets", func() {
+			tracer := mocklogging.NewMockConnectionTracer(mockCtrl)
+			sess.tracer = tracer
+			cryptoSetup.EXPECT().ChangeConnectionID(gomock.Any())
+			packer.EXPECT().SetToken(gomock.Any())
+			packer.EXPECT().ChangeDestConnectionID(gomock.Any())
+			tracer.EXPECT().ReceivedRetry(gomock.Any()).Do(func(hdr *logging.Header) {
+				Expect(hdr.SrcConnectionID).To(Equal(protocol.ConnectionID{0xde, 0xad, 0xbe, 0xef}))
+				Expect(hdr.Token).To(Equal([]byte("foobar")))
+			})
+			Expect(sess.handlePacketImpl(getPacket(validRetryHdr, nil))).To(BeTrue())
+		})
+
 		It("ignores Retry packets after receiving a regular packet", func() {
 			sess.receivedFirstPacket = true
 			Expect(sess.handlePacketImpl(getPacket(validRetryHdr, nil))).To(BeFalse())
 		})
 
+		It("traces ignored Retry packets", func() {
+			tracer := mocklogging.NewMockConnectionTracer(mockCtrl)
+			sess.tracer = tracer
+			sess.receivedFirstPacket = true
+			packet := getPacket(validRetryHdr, nil)
+			tracer.EXPECT().DroppedPacket(logging.PacketTypeRetry, protocol.ByteCount(len(packet.data)), logging.PacketDropUnexpectedPacket)
+			Expect(sess.handlePacketImpl(packet)).To(BeFalse())
+		})
+
 		It("ignores Retry packets if the server didn't change the connection ID", func() {
 			validRetryHdr.SrcConnectionID = sess.destConnID
 			Expect(sess.handlePacketImpl(getPacket(validRetryHdr, nil))).To(BeFalse())
@@ -1680,4 +2619,257 @@ var _ = Describe("Client Session", func() {
 			Expect(err).To(MatchError("expected original_connection_id to equal 0xdeadbeef, is 0xdecafbad"))
 		})
 	})
+
+	Context("connection migration", func() {
+		var (
+			sph             *mockackhandler.MockSentPacketHandler
+			manager         *MockPacketHandlerManager
+			newConn         *mockConnection
+			newPacketConn   *mockPacketConn
+			challenge       *wire.PathChallengeFrame
+			mockMultiplexer *MockMultiplexer
+			origMultiplexer multiplexer
+		)
+
+		BeforeEach(func() {
+			sph = mockackhandler.NewMockSentPacketHandler(mockCtrl)
+			sess.sentPacketHandler = sph
+			manager = NewMockPacketHandlerManager(mockCtrl)
+			newConn = newMockConnection()
+			newPacketConn = newMockPacketConn()
+			sess.handshakeComplete = true
+			sess.peerParams = &handshake.TransportParameters{}
+			challenge = nil
+			getMultiplexer() // make the sync.Once execute
+			mockMultiplexer = NewMockMultiplexer(mockCtrl)
+			origMultiplexer = connMuxer
+			connMuxer = mockMultiplexer
+		})
+
+		AfterEach(func() {
+			connMuxer = origMultiplexer
+		})
+
+		expectPathChallenge := func() {
+			packer.EXPECT().PackPathValidationPacket(gomock.Any()).DoAndReturn(func(f wire.Frame) (*packedPacket, error) {
+				ExpectWithOffset(1, f).To(BeAssignableToTypeOf(&wire.PathChallengeFrame{}))
+				challenge = f.(*wire.PathChallengeFrame)
+				buffer := getPacketBuffer()
+				return &packedPacket{
+					header: &wire.ExtendedHeader{PacketNumber: 42},
+					raw:    append(buffer.Slice[:0], []byte("probe")...),
+					buffer: buffer,
+				}, nil
+			})
+			sph.EXPECT().SentPacket(gomock.Any())
+			manager.EXPECT().Add(sess.srcConnID, sess)
+		}
+
+		startMigration := func() *pathValidation {
+			v := &pathValidation{
+				pconn:   newPacketConn,
+				conn:    newConn,
+				manager: manager,
+				result:  make(chan error, 1),
+			}
+			sess.startMigration(v)
+			return v
+		}
+
+		It("sends a PATH_CHALLENGE on the new path", func() {
+			expectPathChallenge()
+			v := startMigration()
+			Expect(newConn.written).To(Receive(Equal([]byte("probe"))))
+			Expect(mconn.written).To(BeEmpty())
+			Expect(sess.pathValidation).To(Equal(v))
+			Expect(v.deadline).To(BeTemporally("~", time.Now().Add(sess.pathValidationTimeout()), scaleDuration(10*time.Millisecond)))
+			Expect(v.result).To(BeEmpty())
+		})
+
+		It("migrates when receiving the PATH_RESPONSE", func() {
+			expectPathChallenge()
+			v := startMigration()
+			sph.EXPECT().OnConnectionMigration()
+			sph.EXPECT().ECNMode().AnyTimes()
+			manager.EXPECT().Add(sess.srcConnID, sess)
+			Expect(sess.handleFrame(&wire.PathResponseFrame{Data: challenge.Data}, 0, protocol.Encryption1RTT, newConn.RemoteAddr())).To(Succeed())
+			Expect(v.result).To(Receive(BeNil()))
+			Expect(sess.conn).To(Equal(newConn))
+			Expect(sess.pathValidation).To(BeNil())
+		})
+
+		It("sets the DF bit on the new path, if Path MTU Discovery is used", func() {
+			mtuDiscoverer := NewMockMtuDiscoverer(mockCtrl)
+			sess.mtuDiscoverer = mtuDiscoverer
+			newConn.df = true
+			expectPathChallenge()
+			v := startMigration()
+			sph.EXPECT().OnConnectionMigration()
+			sph.EXPECT().ECNMode().AnyTimes()
+			manager.EXPECT().Add(sess.srcConnID, sess)
+			mtuDiscoverer.EXPECT().Reset(gomock.Any())
+			Expect(sess.handleFrame(&wire.PathResponseFrame{Data: challenge.Data}, 0, protocol.Encryption1RTT, newConn.RemoteAddr())).To(Succeed())
+			Expect(v.result).To(Receive(BeNil()))
+			Expect(sess.mtuDiscoverer).To(Equal(mtuDiscoverer))
+		})
+
+		It("disables Path MTU Discovery, if the DF bit can't be set on the new path", func() {
+			mtuDiscoverer := NewMockMtuDiscoverer(mockCtrl)
+			sess.mtuDiscoverer = mtuDiscoverer
+			expectPathChallenge()
+			v := startMigration()
+			sph.EXPECT().OnConnectionMigration()
+			sph.EXPECT().ECNMode().AnyTimes()
+			manager.EXPECT().Add(sess.srcConnID, sess)
+			mtuDiscoverer.EXPECT().Reset(gomock.Any())
+			Expect(sess.handleFrame(&wire.PathResponseFrame{Data: challenge.Data}, 0, protocol.Encryption1RTT, newConn.RemoteAddr())).To(Succeed())
+			Expect(v.result).To(Receive(BeNil()))
+			Expect(sess.mtuDiscoverer).To(BeNil())
+		})
+
+		It("switches to a new connection ID when migrating", func() {
+			f := &wire.NewConnectionIDFrame{
+				SequenceNumber:      1,
+				ConnectionID:        protocol.ConnectionID{0xde, 0xca, 0xfb, 0xad},
+				StatelessResetToken: [16]byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16},
+			}
+			Expect(sess.handleFrame(f, 0, protocol.Encryption1RTT, nil)).To(Succeed())
+			expectPathChallenge()
+			v := startMigration()
+			sph.EXPECT().OnConnectionMigration()
+			sph.EXPECT().ECNMode().AnyTimes()
+			manager.EXPECT().Add(sess.srcConnID, sess)
+			packer.EXPECT().ChangeDestConnectionID(f.ConnectionID)
+			sessionRunner.EXPECT().AddResetToken(f.StatelessResetToken, sess)
+			Expect(sess.handleFrame(&wire.PathResponseFrame{Data: challenge.Data}, 0, protocol.Encryption1RTT, newConn.RemoteAddr())).To(Succeed())
+			Expect(v.result).To(Receive(BeNil()))
+			Expect(sess.destConnID).To(Equal(f.ConnectionID))
+			Expect(sess.connIDManager.queue).To(BeEmpty())
+			frames, _ := sess.framer.AppendControlFrames(nil, 1000)
+			Expect(frames).To(Equal([]wire.Frame{&wire.RetireConnectionIDFrame{SequenceNumber: 0}}))
+		})
+
+		It("retires the previous path when migrating again", func() {
+			expectPathChallenge()
+			startMigration()
+			sph.EXPECT().OnConnectionMigration()
+			sph.EXPECT().ECNMode().AnyTimes()
+			manager.EXPECT().Add(sess.srcConnID, sess)
+			Expect(sess.handleFrame(&wire.PathResponseFrame{Data: challenge.Data}, 0, protocol.Encryption1RTT, newConn.RemoteAddr())).To(Succeed())
+			prevManager := manager
+			prevPacketConn := newPacketConn
+			manager = NewMockPacketHandlerManager(mockCtrl)
+			newConn = newMockConnection()
+			newPacketConn = newMockPacketConn()
+			expectPathChallenge()
+			v := startMigration()
+			sph.EXPECT().OnConnectionMigration()
+			sph.EXPECT().ECNMode().AnyTimes()
+			prevManager.EXPECT().Retire(sess.srcConnID)
+			mockMultiplexer.EXPECT().ReleaseConn(prevPacketConn)
+			manager.EXPECT().Add(sess.srcConnID, sess)
+			Expect(sess.handleFrame(&wire.PathResponseFrame{Data: challenge.Data}, 0, protocol.Encryption1RTT, newConn.RemoteAddr())).To(Succeed())
+			Expect(v.result).To(Receive(BeNil()))
+			Expect(sess.conn).To(Equal(newConn))
+		})
+
+		It("ignores PATH_RESPONSE frames that don't match the PATH_CHALLENGE", func() {
+			expectPathChallenge()
+			v := startMigration()
+			data := challenge.Data
+			data[0]++
+			Expect(sess.handleFrame(&wire.PathResponseFrame{Data: data}, 0, protocol.Encryption1RTT, newConn.RemoteAddr())).To(Succeed())
+			Expect(v.result).To(BeEmpty())
+			Expect(sess.conn).To(Equal(mconn))
+		})
+
+		It("keeps using the old path if path validation fails", func() {
+			expectPathChallenge()
+			v := startMigration()
+			manager.EXPECT().Remove(sess.srcConnID)
+			mockMultiplexer.EXPECT().ReleaseConn(newPacketConn)
+			sess.failPathValidation()
+			Expect(v.result).To(Receive(Equal(errPathValidationFailed)))
+			Expect(sess.conn).To(Equal(mconn))
+			Expect(sess.pathValidation).To(BeNil())
+		})
+
+		It("refuses to migrate before the handshake completed", func() {
+			sess.handshakeComplete = false
+			mockMultiplexer.EXPECT().ReleaseConn(newPacketConn)
+			v := startMigration()
+			Expect(v.result).To(Receive(MatchError("cannot migrate before the handshake completed")))
+			Expect(sess.pathValidation).To(BeNil())
+		})
+
+		It("refuses to migrate if the server disabled migration", func() {
+			sess.peerParams.DisableMigration = true
+			mockMultiplexer.EXPECT().ReleaseConn(newPacketConn)
+			v := startMigration()
+			Expect(v.result).To(Receive(MatchError("the server disabled connection migration")))
+		})
+
+		It("refuses to migrate while path validation is in progress", func() {
+			expectPathChallenge()
+			startMigration()
+			pconn := newMockPacketConn()
+			v := &pathValidation{pconn: pconn, conn: newMockConnection(), manager: NewMockPacketHandlerManager(mockCtrl), result: make(chan error, 1)}
+			mockMultiplexer.EXPECT().ReleaseConn(pconn)
+			sess.startMigration(v)
+			Expect(v.result).To(Receive(MatchError("path validation already in progress")))
+		})
+
+		Context("closing", func() {
+			runAndClose := func() {
+				sph.EXPECT().GetAlarmTimeout().AnyTimes()
+				sph.EXPECT().TimeUntilSend().AnyTimes()
+				sph.EXPECT().GetStats().AnyTimes()
+				done := make(chan struct{})
+				go func() {
+					defer GinkgoRecover()
+					cryptoSetup.EXPECT().RunHandshake().Do(func() { <-sess.Context().Done() }).AnyTimes()
+					sess.run()
+					close(done)
+				}()
+				packer.EXPECT().PackConnectionClose(gomock.Any()).Return(&packedPacket{}, nil)
+				sessionRunner.EXPECT().Retire(gomock.Any())
+				cryptoSetup.EXPECT().Close()
+				sess.Close()
+				Eventually(done).Should(BeClosed())
+			}
+
+			It("releases the packet conn of a path that is being validated", func() {
+				expectPathChallenge()
+				v := startMigration()
+				manager.EXPECT().Remove(sess.srcConnID)
+				mockMultiplexer.EXPECT().ReleaseConn(newPacketConn)
+				runAndClose()
+				Expect(v.result).To(BeEmpty())
+			})
+
+			It("releases the packet conn of the path it migrated to", func() {
+				expectPathChallenge()
+				startMigration()
+				sph.EXPECT().OnConnectionMigration()
+				sph.EXPECT().ECNMode().AnyTimes()
+				manager.EXPECT().Add(sess.srcConnID, sess)
+				Expect(sess.handleFrame(&wire.PathResponseFrame{Data: challenge.Data}, 0, protocol.Encryption1RTT, newConn.RemoteAddr())).To(Succeed())
+				manager.EXPECT().Retire(sess.srcConnID)
+				mockMultiplexer.EXPECT().ReleaseConn(newPacketConn)
+				runAndClose()
+			})
+		})
+
+		It("releases the packet conn if sending the PATH_CHALLENGE fails", func() {
+			packer.EXPECT().PackPathValidationPacket(gomock.Any()).Return(nil, errors.New("packing failed"))
+			gomock.InOrder(
+				manager.EXPECT().Add(sess.srcConnID, sess),
+				manager.EXPECT().Remove(sess.srcConnID),
+			)
+			mockMultiplexer.EXPECT().ReleaseConn(newPacketConn)
+			v := startMigration()
+			Expect(v.result).To(Receive(MatchError("packing failed")))
+			Expect(sess.pathValidation).To(BeNil())
+		})
+	})
 })
@@ -16,6 +16,7 @@ const errorCodeStopping protocol.ApplicationErrorCode = 0
 type streamSender interface {
 	queueControlFrame(wire.Frame)
 	onHasStreamData(protocol.StreamID)
+	onStreamPriorityChanged(protocol.StreamID, StreamPriority)
 	// must be called without holding the mutex that is acquired by closeForShutdown
 	onStreamCompleted(protocol.StreamID)
 }
@@ -35,6 +36,10 @@ func (s *uniStreamSender) onHasStreamData(id protocol.StreamID) {
 	s.streamSender.onHasStreamData(id)
 }
 
+func (s *uniStreamSender) onStreamPriorityChanged(id protocol.StreamID, p StreamPriority) {
+	s.streamSender.onStreamPriorityChanged(id, p)
+}
+
 func (s *uniStreamSender) onStreamCompleted(protocol.StreamID) {
 	s.onStreamCompletedImpl()
 }
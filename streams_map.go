@@ -184,6 +184,19 @@ func (m *streamsMap) UpdateLimits(p *handshake.TransportParameters) error {
 	return nil
 }
 
+// NumStreams returns the number of open streams of the given type,
+// opened by either peer.
+func (m *streamsMap) NumStreams(t protocol.StreamType) int {
+	switch t {
+	case protocol.StreamTypeUni:
+		return m.outgoingUniStreams.NumStreams() + m.incomingUniStreams.NumStreams()
+	case protocol.StreamTypeBidi:
+		return m.outgoingBidiStreams.NumStreams() + m.incomingBidiStreams.NumStreams()
+	default:
+		return 0
+	}
+}
+
 func (m *streamsMap) CloseWithError(err error) {
 	m.outgoingBidiStreams.CloseWithError(err)
 	m.outgoingUniStreams.CloseWithError(err)
@@ -151,6 +151,15 @@ func (m *incomingBidiStreamsMap) deleteStream(id protocol.StreamID) error {
 	return nil
 }
 
+// NumStreams returns the number of open streams.
+// Streams that were closed but not accepted yet are not counted.
+func (m *incomingBidiStreamsMap) NumStreams() int {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	return len(m.streams) - len(m.streamsToDelete)
+}
+
 func (m *incomingBidiStreamsMap) CloseWithError(err error) {
 	m.mutex.Lock()
 	m.closeErr = err
@@ -149,6 +149,15 @@ func (m *incomingItemsMap) deleteStream(id protocol.StreamID) error {
 	return nil
 }
 
+// NumStreams returns the number of open streams.
+// Streams that were closed but not accepted yet are not counted.
+func (m *incomingItemsMap) NumStreams() int {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	return len(m.streams) - len(m.streamsToDelete)
+}
+
 func (m *incomingItemsMap) CloseWithError(err error) {
 	m.mutex.Lock()
 	m.closeErr = err
@@ -197,6 +197,16 @@ var _ = Describe("Streams Map (incoming)", func() {
 		Expect(str).ToNot(BeNil())
 	})
 
+	It("counts the open streams", func() {
+		Expect(m.NumStreams()).To(BeZero())
+		_, err := m.GetOrOpenStream(firstNewStream + 4)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(m.NumStreams()).To(Equal(2))
+		// the stream is only deleted when it is accepted, but it isn't counted any more
+		Expect(m.DeleteStream(firstNewStream + 4)).To(Succeed())
+		Expect(m.NumStreams()).To(Equal(1))
+	})
+
 	It("errors when deleting a non-existing stream", func() {
 		err := m.DeleteStream(1337)
 		Expect(err).To(MatchError("Tried to delete unknown stream 1337"))
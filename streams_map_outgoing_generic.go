@@ -134,6 +134,14 @@ func (m *outgoingItemsMap) SetMaxStream(id protocol.StreamID) {
 	m.mutex.Unlock()
 }
 
+// NumStreams returns the number of open streams.
+func (m *outgoingItemsMap) NumStreams() int {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	return len(m.streams)
+}
+
 func (m *outgoingItemsMap) CloseWithError(err error) {
 	m.mutex.Lock()
 	m.closeErr = err
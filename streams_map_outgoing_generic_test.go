@@ -72,6 +72,17 @@ var _ = Describe("Streams Map (outgoing)", func() {
 			Expect(str).To(BeNil())
 		})
 
+		It("counts the open streams", func() {
+			Expect(m.NumStreams()).To(BeZero())
+			_, err := m.OpenStream()
+			Expect(err).ToNot(HaveOccurred())
+			_, err = m.OpenStream()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(m.NumStreams()).To(Equal(2))
+			Expect(m.DeleteStream(firstNewStream)).To(Succeed())
+			Expect(m.NumStreams()).To(Equal(1))
+		})
+
 		It("errors when deleting a non-existing stream", func() {
 			err := m.DeleteStream(1337)
 			Expect(err).To(MatchError("Tried to delete unknown stream 1337"))
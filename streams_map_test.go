@@ -191,6 +191,19 @@ var _ = Describe("Streams Map", func() {
 					Expect(dstr).To(BeNil())
 				})
 
+				It("counts the open streams", func() {
+					_, err := m.OpenStream()
+					Expect(err).ToNot(HaveOccurred())
+					_, err = m.GetOrOpenReceiveStream(ids.firstIncomingBidiStream)
+					Expect(err).ToNot(HaveOccurred())
+					_, err = m.OpenUniStream()
+					Expect(err).ToNot(HaveOccurred())
+					Expect(m.NumStreams(protocol.StreamTypeBidi)).To(Equal(2))
+					Expect(m.NumStreams(protocol.StreamTypeUni)).To(Equal(1))
+					Expect(m.DeleteStream(ids.firstOutgoingBidiStream)).To(Succeed())
+					Expect(m.NumStreams(protocol.StreamTypeBidi)).To(Equal(1))
+				})
+
 				It("accepts unirectional streams after they have been deleted", func() {
 					id := ids.firstIncomingUniStream
 					_, err := m.GetOrOpenReceiveStream(id)
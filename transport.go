@@ -0,0 +1,145 @@
+package quic
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"sync"
+
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+)
+
+// A Transport is a QUIC endpoint on a single net.PacketConn.
+// It accepts incoming connections and dials outgoing connections at the same time.
+// This is useful for peer-to-peer applications, which need to use a single port
+// (for example after NAT traversal) for all their connections.
+//
+// Packets are demultiplexed using the QUIC connection IDs:
+// Packets for known connection IDs are passed to the respective session, no matter if it was dialed or accepted.
+// Long header packets for unknown connection IDs are passed to the listener, if there is one.
+// The listener only accepts Initial packets, and drops Version Negotiation packets:
+// those can only belong to a dialed connection that was already closed.
+// Short header packets for unknown connection IDs are answered with a stateless reset,
+// if a StatelessResetKey is configured, no matter if the connection was dialed or accepted.
+type Transport struct {
+	mutex sync.Mutex
+
+	conn   net.PacketConn
+	config *Config // contains the settings that apply to all connections on this Transport
+
+	packetHandlers packetHandlerManager
+	server         *server
+	closed         bool
+}
+
+// NewTransport creates a new Transport.
+// The ConnectionIDLength, StatelessResetKey, Metrics, EnableBatchedIO, DisablePathMTUDiscovery and EnableECN fields of the config apply to all
+// connections using this Transport, and override the values of the Configs passed to Dial and Listen.
+// If the ConnectionIDLength is 0, the default connection ID length is used, since the connection IDs are
+// needed to demultiplex incoming packets.
+// The config may be nil.
+// The Transport takes ownership of the net.PacketConn, and closes it when it is closed.
+func NewTransport(conn net.PacketConn, config *Config) (*Transport, error) {
+	if config == nil {
+		config = &Config{}
+	}
+	connIDLen := config.ConnectionIDLength
+	if connIDLen == 0 {
+		connIDLen = protocol.DefaultConnectionIDLength
+	}
+	conf := &Config{
+		ConnectionIDLength:      connIDLen,
+		StatelessResetKey:       config.StatelessResetKey,
+		Metrics:                 config.Metrics,
+		EnableBatchedIO:         config.EnableBatchedIO,
+		DisablePathMTUDiscovery: config.DisablePathMTUDiscovery,
+		EnableECN:               config.EnableECN,
+	}
+	packetHandlers, err := getMultiplexer().AddConn(conn, conf.ConnectionIDLength, conf.StatelessResetKey, conf.Metrics, conf.EnableBatchedIO, !conf.DisablePathMTUDiscovery, conf.EnableECN)
+	if err != nil {
+		return nil, err
+	}
+	return &Transport{
+		conn:           conn,
+		config:         conf,
+		packetHandlers: packetHandlers,
+	}, nil
+}
+
+// Listen starts listening for incoming QUIC connections.
+// There can only be a single listener at a time. A new listener can be started once the previous one was closed.
+// See the package level Listen function for details.
+func (t *Transport) Listen(tlsConf *tls.Config, config *Config) (Listener, error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if t.closed {
+		return nil, errors.New("quic: Transport closed")
+	}
+	if t.server != nil {
+		select {
+		case <-t.server.errorChan:
+		default:
+			return nil, errors.New("quic: Transport already has a listener")
+		}
+	}
+	s, err := listen(t.conn, tlsConf, t.populateConfig(config))
+	if err != nil {
+		return nil, err
+	}
+	t.server = s
+	return s, nil
+}
+
+// Dial establishes a new QUIC connection to a server.
+// See the package level Dial function for details.
+func (t *Transport) Dial(remoteAddr net.Addr, host string, tlsConf *tls.Config, config *Config) (Session, error) {
+	return t.DialContext(context.Background(), remoteAddr, host, tlsConf, config)
+}
+
+// DialContext establishes a new QUIC connection to a server using the provided context.
+func (t *Transport) DialContext(ctx context.Context, remoteAddr net.Addr, host string, tlsConf *tls.Config, config *Config) (Session, error) {
+	t.mutex.Lock()
+	closed := t.closed
+	t.mutex.Unlock()
+	if closed {
+		return nil, errors.New("quic: Transport closed")
+	}
+	return dialContext(ctx, t.conn, remoteAddr, host, tlsConf, t.populateConfig(config), false)
+}
+
+// populateConfig applies the settings of the Transport to a Config passed to Dial or Listen.
+func (t *Transport) populateConfig(config *Config) *Config {
+	var conf Config
+	if config != nil {
+		conf = *config
+	}
+	conf.ConnectionIDLength = t.config.ConnectionIDLength
+	conf.StatelessResetKey = t.config.StatelessResetKey
+	conf.Metrics = t.config.Metrics
+	conf.EnableBatchedIO = t.config.EnableBatchedIO
+	conf.DisablePathMTUDiscovery = t.config.DisablePathMTUDiscovery
+	conf.EnableECN = t.config.EnableECN
+	return &conf
+}
+
+// LocalAddr returns the local address of the Transport.
+func (t *Transport) LocalAddr() net.Addr {
+	return t.conn.LocalAddr()
+}
+
+// Close closes the listener and all connections, and then closes the underlying net.PacketConn.
+func (t *Transport) Close() error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if t.closed {
+		return nil
+	}
+	t.closed = true
+	if t.server != nil {
+		t.server.Close()
+	}
+	return t.packetHandlers.Close()
+}
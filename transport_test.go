@@ -0,0 +1,330 @@
+package quic
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+	"github.com/lucas-clemente/quic-go/internal/testdata"
+	"github.com/lucas-clemente/quic-go/internal/wire"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Transport", func() {
+	var tlsConf *tls.Config
+
+	newMockConn := func() *mockPacketConn {
+		conn := newMockPacketConn()
+		conn.addr = &net.UDPAddr{}
+		return conn
+	}
+
+	BeforeEach(func() {
+		tlsConf = testdata.GetTLSConfig()
+	})
+
+	It("uses the default connection ID length", func() {
+		tr, err := NewTransport(newMockConn(), nil)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(tr.config.ConnectionIDLength).To(Equal(protocol.DefaultConnectionIDLength))
+		Expect(tr.Close()).To(Succeed())
+	})
+
+	It("errors if the packet conn is already used with different settings", func() {
+		conn := newMockConn()
+		ln, err := Listen(conn, tlsConf, &Config{ConnectionIDLength: 5})
+		Expect(err).ToNot(HaveOccurred())
+		defer ln.Close()
+		_, err = NewTransport(conn, &Config{ConnectionIDLength: 6})
+		Expect(err).To(MatchError("cannot use 6 byte connection IDs on a connection that is already using 5 byte connction IDs"))
+	})
+
+	It("applies its settings to the listener", func() {
+		tr, err := NewTransport(newMockConn(), &Config{ConnectionIDLength: 7, StatelessResetKey: []byte("foobar")})
+		Expect(err).ToNot(HaveOccurred())
+		defer tr.Close()
+		ln, err := tr.Listen(tlsConf, &Config{ConnectionIDLength: 12, IdleTimeout: 42 * time.Minute})
+		Expect(err).ToNot(HaveOccurred())
+		server := ln.(*server)
+		Expect(server.config.ConnectionIDLength).To(Equal(7))
+		Expect(server.config.StatelessResetKey).To(Equal([]byte("foobar")))
+		Expect(server.config.IdleTimeout).To(Equal(42 * time.Minute))
+		Expect(server.sessionHandler).To(Equal(tr.packetHandlers))
+	})
+
+	It("only allows a single listener at a time", func() {
+		tr, err := NewTransport(newMockConn(), nil)
+		Expect(err).ToNot(HaveOccurred())
+		defer tr.Close()
+		ln, err := tr.Listen(tlsConf, nil)
+		Expect(err).ToNot(HaveOccurred())
+		_, err = tr.Listen(tlsConf, nil)
+		Expect(err).To(MatchError("quic: Transport already has a listener"))
+		Expect(ln.Close()).To(Succeed())
+		_, err = tr.Listen(tlsConf, nil)
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	It("closes the listener and the packet conn", func() {
+		conn := newMockConn()
+		tr, err := NewTransport(conn, nil)
+		Expect(err).ToNot(HaveOccurred())
+		ln, err := tr.Listen(tlsConf, nil)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(tr.Close()).To(Succeed())
+		Expect(conn.closed).To(BeTrue())
+		_, err = ln.Accept()
+		Expect(err).To(MatchError("server closed"))
+		// closing a second time is a no-op
+		Expect(tr.Close()).To(Succeed())
+	})
+
+	It("errors when listening or dialing after it was closed", func() {
+		tr, err := NewTransport(newMockConn(), nil)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(tr.Close()).To(Succeed())
+		_, err = tr.Listen(tlsConf, nil)
+		Expect(err).To(MatchError("quic: Transport closed"))
+		_, err = tr.Dial(&net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 1234}, "localhost:1234", nil, nil)
+		Expect(err).To(MatchError("quic: Transport closed"))
+	})
+
+	It("dials and accepts connections on the same packet conn", func() {
+		newTransport := func() *Transport {
+			conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+			Expect(err).ToNot(HaveOccurred())
+			tr, err := NewTransport(conn, nil)
+			Expect(err).ToNot(HaveOccurred())
+			return tr
+		}
+		// The testdata certificate is valid for quic.clemente.io.
+		clientTLSConf := &tls.Config{InsecureSkipVerify: true, ServerName: "quic.clemente.io"}
+
+		// Each peer accepts a connection from the other peer, and dials the other peer.
+		peers := []*Transport{newTransport(), newTransport()}
+		for _, tr := range peers {
+			defer tr.Close()
+		}
+		for i, tr := range peers {
+			ln, err := tr.Listen(tlsConf, nil)
+			Expect(err).ToNot(HaveOccurred())
+			message := []byte{byte(i)}
+			go func() {
+				defer GinkgoRecover()
+				sess, err := ln.Accept()
+				if err != nil {
+					return
+				}
+				str, err := sess.OpenUniStream()
+				Expect(err).ToNot(HaveOccurred())
+				_, err = str.Write(message)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(str.Close()).To(Succeed())
+			}()
+		}
+		for i, tr := range peers {
+			other := peers[1-i]
+			sess, err := tr.Dial(other.LocalAddr(), "localhost:1337", clientTLSConf, &Config{HandshakeTimeout: 5 * time.Second})
+			Expect(err).ToNot(HaveOccurred())
+			str, err := sess.AcceptUniStream()
+			Expect(err).ToNot(HaveOccurred())
+			data, err := ioutil.ReadAll(str)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(data).To(Equal([]byte{byte(1 - i)}))
+		}
+	})
+
+	It("exchanges data on dialed and accepted sessions at the same time", func() {
+		newTransport := func() *Transport {
+			conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+			Expect(err).ToNot(HaveOccurred())
+			tr, err := NewTransport(conn, nil)
+			Expect(err).ToNot(HaveOccurred())
+			return tr
+		}
+		clientTLSConf := &tls.Config{InsecureSkipVerify: true, ServerName: "quic.clemente.io"}
+		data := make([]byte, 200*1024)
+		rand.Read(data)
+
+		var wg sync.WaitGroup
+		// Every session echoes the stream opened by the peer, and sends data on a stream it opens itself.
+		// Both happen concurrently on all sessions.
+		run := func(sess Session) {
+			wg.Add(2)
+			go func() {
+				defer GinkgoRecover()
+				defer wg.Done()
+				str, err := sess.AcceptStream()
+				Expect(err).ToNot(HaveOccurred())
+				_, err = io.Copy(str, str)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(str.Close()).To(Succeed())
+			}()
+			go func() {
+				defer GinkgoRecover()
+				defer wg.Done()
+				str, err := sess.OpenStreamSync()
+				Expect(err).ToNot(HaveOccurred())
+				go func() {
+					defer GinkgoRecover()
+					_, err := str.Write(data)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(str.Close()).To(Succeed())
+				}()
+				echoed, err := ioutil.ReadAll(str)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(bytes.Equal(echoed, data)).To(BeTrue())
+			}()
+		}
+
+		peers := []*Transport{newTransport(), newTransport()}
+		for _, tr := range peers {
+			defer tr.Close()
+		}
+		accepted := make(chan Session, 2)
+		for _, tr := range peers {
+			ln, err := tr.Listen(tlsConf, nil)
+			Expect(err).ToNot(HaveOccurred())
+			go func() {
+				defer GinkgoRecover()
+				sess, err := ln.Accept()
+				if err != nil {
+					return
+				}
+				accepted <- sess
+			}()
+		}
+		var sessions []Session
+		for i, tr := range peers {
+			sess, err := tr.Dial(peers[1-i].LocalAddr(), "localhost:1337", clientTLSConf, &Config{HandshakeTimeout: 5 * time.Second})
+			Expect(err).ToNot(HaveOccurred())
+			sessions = append(sessions, sess)
+		}
+		for range peers {
+			var sess Session
+			Eventually(accepted).Should(Receive(&sess))
+			sessions = append(sessions, sess)
+		}
+		for _, sess := range sessions {
+			run(sess)
+		}
+		done := make(chan struct{})
+		go func() {
+			wg.Wait()
+			close(done)
+		}()
+		Eventually(done, 10*time.Second).Should(BeClosed())
+	})
+
+	Context("handling packets with unknown connection IDs", func() {
+		var (
+			conn *mockPacketConn
+			tr   *Transport
+			addr = &net.UDPAddr{IP: net.IPv4(192, 168, 0, 1), Port: 1337}
+		)
+
+		getLongHeaderPacket := func(connID protocol.ConnectionID) []byte {
+			buf := &bytes.Buffer{}
+			Expect((&wire.ExtendedHeader{
+				Header: wire.Header{
+					IsLongHeader:     true,
+					Type:             protocol.PacketTypeInitial,
+					DestConnectionID: connID,
+					Length:           protocol.MinInitialPacketSize,
+					Version:          protocol.VersionTLS,
+				},
+				PacketNumberLen: protocol.PacketNumberLen4,
+			}).Write(buf, protocol.VersionTLS)).To(Succeed())
+			return append(buf.Bytes(), make([]byte, protocol.MinInitialPacketSize)...)
+		}
+
+		// The Transport uses the default connection ID length of 4 bytes.
+		getShortHeaderPacket := func(connID protocol.ConnectionID) []byte {
+			return append(append([]byte{0x40}, connID...), make([]byte, 100)...)
+		}
+
+		BeforeEach(func() {
+			conn = newMockConn()
+			conn.dataReadFrom = addr
+			var err error
+			tr, err = NewTransport(conn, &Config{StatelessResetKey: []byte("foobar")})
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		AfterEach(func() {
+			// remove the mocks, so that closing the Transport doesn't close them
+			tr.packetHandlers.(*packetHandlerMap).mutex.Lock()
+			tr.packetHandlers.(*packetHandlerMap).handlers = make(map[string]packetHandler)
+			tr.packetHandlers.(*packetHandlerMap).server = nil
+			tr.packetHandlers.(*packetHandlerMap).mutex.Unlock()
+			Expect(tr.Close()).To(Succeed())
+		})
+
+		// addSessions adds a dialed and an accepted session, and checks that packets are passed to them.
+		addSessions := func() {
+			dialed := NewMockPacketHandler(mockCtrl)
+			accepted := NewMockPacketHandler(mockCtrl)
+			dialedConnID := protocol.ConnectionID{1, 1, 1, 1}
+			acceptedConnID := protocol.ConnectionID{2, 2, 2, 2}
+			tr.packetHandlers.Add(dialedConnID, dialed)
+			tr.packetHandlers.Add(acceptedConnID, accepted)
+			handled := make(chan protocol.ConnectionID, 2)
+			dialed.EXPECT().handlePacket(gomock.Any()).Do(func(*receivedPacket) { handled <- dialedConnID })
+			accepted.EXPECT().handlePacket(gomock.Any()).Do(func(*receivedPacket) { handled <- acceptedConnID })
+			conn.dataToRead <- getShortHeaderPacket(dialedConnID)
+			Eventually(handled).Should(Receive(Equal(dialedConnID)))
+			conn.dataToRead <- getShortHeaderPacket(acceptedConnID)
+			Eventually(handled).Should(Receive(Equal(acceptedConnID)))
+		}
+
+		It("drops long header packets, if there's no listener", func() {
+			addSessions()
+			conn.dataToRead <- getLongHeaderPacket(protocol.ConnectionID{3, 3, 3, 3})
+			Consistently(conn.dataWritten).ShouldNot(Receive())
+		})
+
+		It("passes long header packets to the listener", func() {
+			server := NewMockUnknownPacketHandler(mockCtrl)
+			tr.packetHandlers.SetServer(server)
+			addSessions()
+			connID := protocol.ConnectionID{3, 3, 3, 3}
+			handled := make(chan struct{})
+			server.EXPECT().handlePacket(gomock.Any()).Do(func(p *receivedPacket) {
+				defer GinkgoRecover()
+				Expect(p.remoteAddr).To(Equal(addr))
+				Expect(p.data).To(Equal(getLongHeaderPacket(connID)))
+				close(handled)
+			})
+			conn.dataToRead <- getLongHeaderPacket(connID)
+			Eventually(handled).Should(BeClosed())
+		})
+
+		for _, l := range []bool{false, true} {
+			withListener := l
+
+			It(fmt.Sprintf("sends stateless resets for short header packets (listener: %t)", withListener), func() {
+				if withListener {
+					tr.packetHandlers.SetServer(NewMockUnknownPacketHandler(mockCtrl))
+				}
+				addSessions()
+				connID := protocol.ConnectionID{3, 3, 3, 3}
+				conn.dataToRead <- getShortHeaderPacket(connID)
+				var reset mockPacketConnWrite
+				Eventually(conn.dataWritten).Should(Receive(&reset))
+				Expect(reset.to).To(Equal(addr))
+				token := tr.packetHandlers.GetStatelessResetToken(connID)
+				Expect(reset.data[len(reset.data)-16:]).To(Equal(token[:]))
+			})
+		}
+	})
+})
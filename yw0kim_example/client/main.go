@@ -19,15 +19,17 @@ import (
 	"strings"
 	"time"
 
+	quic "github.com/lucas-clemente/quic-go"
 	"github.com/lucas-clemente/quic-go/http3"
 	"github.com/lucas-clemente/quic-go/internal/utils"
 	jsonstruct "github.com/lucas-clemente/quic-go/yw0kim_example"
 	"github.com/lucas-clemente/quic-go/yw0kim_example/tlsdata"
+	ywutils "github.com/lucas-clemente/quic-go/yw0kim_example/utils"
 	"golang.org/x/net/http2"
 	pb "gopkg.in/cheggaaa/pb.v1"
 )
 
-func getHTTPClient(proto string) http.Client {
+func getHTTPClient(proto string, quicConfig *quic.Config) http.Client {
 	var hclient http.Client
 	tlsConfig := &tls.Config{
 		RootCAs: tlsdata.GetRootCA(),
@@ -53,6 +55,13 @@ func getHTTPClient(proto string) http.Client {
 		*/
 		hclient.Transport = &http3.RoundTripper{
 			TLSClientConfig: tlsConfig,
+			QuicConfig:      quicConfig,
+		}
+	case "a":
+		// uses HTTP/3 once the server announced it, and HTTP/2 otherwise
+		hclient.Transport = &http3.HappyEyeballsRoundTripper{
+			TLSClientConfig: tlsConfig,
+			QuicConfig:      quicConfig,
 		}
 	}
 
@@ -147,6 +156,8 @@ func makeRequest(command, pathOrMsg, reqURL, proto string) *http.Request {
 		url.Host += ":6002"
 	case "h3":
 		url.Host += ":6003"
+	case "a":
+		url.Host += ":6004"
 	}
 
 	switch command {
@@ -263,12 +274,13 @@ func main() {
 	verbose := flag.Bool("v", false, "verbose")
 	// quiet := flag.Bool("q", false, "don't print the data")
 	echo := flag.String("e", "not set", "echo msg for test")
-	proto := flag.String("p", "h1", "Request Protocol h1(http/1), h2(http/2), h3(http/3)\n")
+	proto := flag.String("p", "h1", "Request Protocol h1(http/1), h2(http/2), h3(http/3), a(http/3 with fallback to http/2)\n")
 	command := flag.String("c", "L", "W/R/E\n"+
 		"W(Write/POST),\n"+
 		"R(Read/GET),\n"+
 		"E(Echo/POST) echo for test\n")
 	file := flag.String("f", "", "local or remote path(file or dir)\n")
+	cc := flag.String("cc", "cubic", "congestion control algorithm used for h3: cubic, reno or bbr\n")
 	flag.Parse()
 	urls := flag.Args()
 
@@ -293,10 +305,16 @@ func main() {
 		return
 	}
 
+	quicConfig, err := ywutils.GetQuicConfig(*cc)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
 	var req *http.Request
 	req = makeRequest(*command, *file, urls[0], *proto)
 	start := time.Now()
-	hclient := getHTTPClient(*proto)
+	hclient := getHTTPClient(*proto, quicConfig)
 	// fmt.Printf("hclient : %#v\n", hclient)
 	// fmt.Printf("reqBody : %#v\n %s\n", req.Body, req.Body.Read())
 	rsp, err := hclient.Do(req)
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"flag"
 	"fmt"
@@ -8,9 +9,11 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"syscall"
 	"time"
 
 	"golang.org/x/net/http2"
@@ -19,6 +22,7 @@ import (
 	"github.com/lucas-clemente/quic-go/http3"
 	jsonstruct "github.com/lucas-clemente/quic-go/yw0kim_example"
 	"github.com/lucas-clemente/quic-go/yw0kim_example/tlsdata"
+	ywutils "github.com/lucas-clemente/quic-go/yw0kim_example/utils"
 
 	_ "net/http/pprof"
 
@@ -188,6 +192,7 @@ func main() {
 	verbose := flag.Bool("v", false, "verbose")
 	bs := flag.String("bind", "quic.yw.com", "bind address")
 	proto := flag.String("p", "h1", "h1(http/1.1), h2(http/2), h3(http/3), a(All protocol work)")
+	cc := flag.String("cc", "cubic", "congestion control algorithm used for h3: cubic, reno or bbr")
 	flag.Parse()
 
 	logger := utils.DefaultLogger
@@ -204,7 +209,11 @@ func main() {
 	r.PathPrefix("/data").HandlerFunc(requestLogger(getHandler)).Methods("GET")
 	r.PathPrefix("/data").HandlerFunc(requestLogger(postHandler)).Methods("POST")
 	http.Handle("/", r)
-	var err error
+	quicConfig, err := ywutils.GetQuicConfig(*cc)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
 	certFile, keyFile := tlsdata.GetCertificatePaths()
 	switch *proto {
 	case "h1":
@@ -223,9 +232,28 @@ func main() {
 		// err = http3.ListenAndServe(bCap, certFile, keyFile, nil)
 		// pure http/3
 		server := http3.Server{
-			Server: &http.Server{Addr: bCap},
+			Server:     &http.Server{Addr: bCap},
+			QuicConfig: quicConfig,
 		}
 		err = server.ListenAndServeTLS(certFile, keyFile)
+	case "a":
+		bCap := *bs + ":6004"
+		server := http3.NewCombinedServer(bCap, nil, nil)
+		server.QUICServer.QuicConfig = quicConfig
+		go func() {
+			sig := make(chan os.Signal, 1)
+			signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+			<-sig
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			if err := server.Shutdown(ctx); err != nil {
+				logger.Errorf("Shutdown failed: %s", err)
+			}
+		}()
+		err = server.ListenAndServeTLS(certFile, keyFile)
+		if err == http.ErrServerClosed {
+			err = nil
+		}
 	}
 
 	if err != nil {
@@ -0,0 +1,25 @@
+package utils
+
+import (
+	"fmt"
+
+	quic "github.com/lucas-clemente/quic-go"
+	"github.com/lucas-clemente/quic-go/congestion"
+)
+
+// GetQuicConfig returns a quic.Config that uses the congestion control algorithm with the given name.
+// Supported algorithms are "cubic", "reno" and "bbr".
+func GetQuicConfig(congestionControl string) (*quic.Config, error) {
+	var cc func(congestion.RTTStats) congestion.SendAlgorithm
+	switch congestionControl {
+	case "cubic":
+		cc = congestion.NewCubic
+	case "reno":
+		cc = congestion.NewReno
+	case "bbr":
+		cc = congestion.NewBBR
+	default:
+		return nil, fmt.Errorf("unknown congestion control algorithm: %s", congestionControl)
+	}
+	return &quic.Config{CongestionControl: cc}, nil
+}